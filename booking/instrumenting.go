@@ -1,6 +1,7 @@
 package booking
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
@@ -23,65 +24,209 @@ func NewInstrumentingService(counter metrics.Counter, latency metrics.Histogram,
 	}
 }
 
-func (s *instrumentingService) BookNewCargo(origin, destination shipping.UNLocode, deadline time.Time) (shipping.TrackingID, error) {
+func (s *instrumentingService) BookNewCargo(ctx context.Context, origin, destination shipping.UNLocode, deadline time.Time, opts ...BookingOption) (shipping.TrackingID, error) {
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "book").Add(1)
 		s.requestLatency.With("method", "book").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.BookNewCargo(origin, destination, deadline)
+	return s.next.BookNewCargo(ctx, origin, destination, deadline, opts...)
 }
 
-func (s *instrumentingService) LoadCargo(id shipping.TrackingID) (c Cargo, err error) {
+func (s *instrumentingService) EnsureCargo(ctx context.Context, externalRef string, rs shipping.RouteSpecification) (shipping.TrackingID, bool, error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "ensure").Add(1)
+		s.requestLatency.With("method", "ensure").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.EnsureCargo(ctx, externalRef, rs)
+}
+
+func (s *instrumentingService) LoadCargo(ctx context.Context, id shipping.TrackingID) (c Cargo, err error) {
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "load").Add(1)
 		s.requestLatency.With("method", "load").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.LoadCargo(id)
+	return s.next.LoadCargo(ctx, id)
 }
 
-func (s *instrumentingService) RequestPossibleRoutesForCargo(id shipping.TrackingID) []shipping.Itinerary {
+func (s *instrumentingService) RequestPossibleRoutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary {
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "request_routes").Add(1)
 		s.requestLatency.With("method", "request_routes").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.RequestPossibleRoutesForCargo(id)
+	return s.next.RequestPossibleRoutesForCargo(ctx, id)
+}
+
+func (s *instrumentingService) RequestPossibleRoutesForCargoBySpecification(ctx context.Context, id shipping.TrackingID) []Route {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "request_routes_by_specification").Add(1)
+		s.requestLatency.With("method", "request_routes_by_specification").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.RequestPossibleRoutesForCargoBySpecification(ctx, id)
+}
+
+func (s *instrumentingService) RequestPossibleReroutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "request_reroutes").Add(1)
+		s.requestLatency.With("method", "request_reroutes").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.RequestPossibleReroutesForCargo(ctx, id)
+}
+
+func (s *instrumentingService) RequestRoutesFromOrigins(ctx context.Context, origins []shipping.UNLocode, rs shipping.RouteSpecification) []shipping.Itinerary {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "request_routes_from_origins").Add(1)
+		s.requestLatency.With("method", "request_routes_from_origins").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.RequestRoutesFromOrigins(ctx, origins, rs)
 }
 
-func (s *instrumentingService) AssignCargoToRoute(id shipping.TrackingID, itinerary shipping.Itinerary) (err error) {
+func (s *instrumentingService) AssignCargoToRoute(ctx context.Context, id shipping.TrackingID, itinerary shipping.Itinerary) (err error) {
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "assign_to_route").Add(1)
 		s.requestLatency.With("method", "assign_to_route").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.AssignCargoToRoute(id, itinerary)
+	return s.next.AssignCargoToRoute(ctx, id, itinerary)
 }
 
-func (s *instrumentingService) ChangeDestination(id shipping.TrackingID, l shipping.UNLocode) (err error) {
+func (s *instrumentingService) ChangeDestination(ctx context.Context, id shipping.TrackingID, l shipping.UNLocode) (err error) {
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "change_destination").Add(1)
 		s.requestLatency.With("method", "change_destination").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.ChangeDestination(id, l)
+	return s.next.ChangeDestination(ctx, id, l)
+}
+
+func (s *instrumentingService) UpdateRouteSpecification(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) (err error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "update_route_specification").Add(1)
+		s.requestLatency.With("method", "update_route_specification").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.UpdateRouteSpecification(ctx, id, rs)
+}
+
+func (s *instrumentingService) CancelCargo(ctx context.Context, id shipping.TrackingID) (err error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "cancel").Add(1)
+		s.requestLatency.With("method", "cancel").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.CancelCargo(ctx, id)
+}
+
+func (s *instrumentingService) HoldCargo(ctx context.Context, id shipping.TrackingID, reason string) (err error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "hold").Add(1)
+		s.requestLatency.With("method", "hold").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.HoldCargo(ctx, id, reason)
+}
+
+func (s *instrumentingService) ReleaseCargo(ctx context.Context, id shipping.TrackingID) (err error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "release").Add(1)
+		s.requestLatency.With("method", "release").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.ReleaseCargo(ctx, id)
 }
 
-func (s *instrumentingService) Cargos() []Cargo {
+func (s *instrumentingService) SetCargoTags(ctx context.Context, id shipping.TrackingID, tags map[string]string) (err error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "set_tags").Add(1)
+		s.requestLatency.With("method", "set_tags").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.SetCargoTags(ctx, id, tags)
+}
+
+func (s *instrumentingService) TransferCargo(ctx context.Context, id shipping.TrackingID, newCustomer string) (err error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "transfer").Add(1)
+		s.requestLatency.With("method", "transfer").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.TransferCargo(ctx, id, newCustomer)
+}
+
+func (s *instrumentingService) ReopenCargo(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) (err error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "reopen").Add(1)
+		s.requestLatency.With("method", "reopen").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.ReopenCargo(ctx, id, rs)
+}
+
+func (s *instrumentingService) Cargos(ctx context.Context) []Cargo {
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "list_cargos").Add(1)
 		s.requestLatency.With("method", "list_cargos").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.Cargos()
+	return s.next.Cargos(ctx)
 }
 
-func (s *instrumentingService) Locations() []Location {
+func (s *instrumentingService) Locations(ctx context.Context) []Location {
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "list_locations").Add(1)
 		s.requestLatency.With("method", "list_locations").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.Locations()
+	return s.next.Locations(ctx)
+}
+
+func (s *instrumentingService) AssignRoutes(ctx context.Context, assignments map[shipping.TrackingID]shipping.Itinerary) map[shipping.TrackingID]error {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "assign_routes").Add(1)
+		s.requestLatency.With("method", "assign_routes").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.AssignRoutes(ctx, assignments)
+}
+
+func (s *instrumentingService) ConsolidateCargos(ctx context.Context, parent shipping.TrackingID, children []shipping.TrackingID) error {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "consolidate").Add(1)
+		s.requestLatency.With("method", "consolidate").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.ConsolidateCargos(ctx, parent, children)
+}
+
+func (s *instrumentingService) RecomputeAllDeliveries(ctx context.Context) (int, []error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "recompute_deliveries").Add(1)
+		s.requestLatency.With("method", "recompute_deliveries").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.RecomputeAllDeliveries(ctx)
+}
+
+func (s *instrumentingService) NextSailingWithCapacity(ctx context.Context, rs shipping.RouteSpecification, weight, volume float64, lookahead time.Duration) (Sailing, error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "next_sailing_with_capacity").Add(1)
+		s.requestLatency.With("method", "next_sailing_with_capacity").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.NextSailingWithCapacity(ctx, rs, weight, volume, lookahead)
+}
+
+func (s *instrumentingService) VoyageLoad(ctx context.Context, number shipping.VoyageNumber) (weight, volume float64, err error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "voyage_load").Add(1)
+		s.requestLatency.With("method", "voyage_load").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.VoyageLoad(ctx, number)
 }
@@ -0,0 +1,77 @@
+package booking
+
+import (
+	"context"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// LeadTimePolicy tells the standard lead time of a route: how long after
+// booking a cargo is expected to arrive at its destination. It gives the
+// arrival deadline of cargos booked without one.
+type LeadTimePolicy interface {
+	LeadTime(ctx context.Context, origin, destination shipping.UNLocode) (time.Duration, error)
+}
+
+// LeadTimePolicyFunc is an adapter to allow the use of ordinary functions as
+// lead time policies.
+type LeadTimePolicyFunc func(ctx context.Context, origin, destination shipping.UNLocode) (time.Duration, error)
+
+// LeadTime calls f(ctx, origin, destination).
+func (f LeadTimePolicyFunc) LeadTime(ctx context.Context, origin, destination shipping.UNLocode) (time.Duration, error) {
+	return f(ctx, origin, destination)
+}
+
+// FixedLeadTime returns a policy that gives every route the lead time d.
+func FixedLeadTime(d time.Duration) LeadTimePolicy {
+	return LeadTimePolicyFunc(func(ctx context.Context, origin, destination shipping.UNLocode) (time.Duration, error) {
+		return d, nil
+	})
+}
+
+// DistanceLeadTime returns a policy that gives a route the lead time base,
+// plus perKm for every kilometer of the great-circle distance between its
+// origin and destination, as found in locations. Routes between locations
+// without coordinates are given base alone.
+func DistanceLeadTime(locations shipping.LocationRepository, base, perKm time.Duration) LeadTimePolicy {
+	return LeadTimePolicyFunc(func(ctx context.Context, origin, destination shipping.UNLocode) (time.Duration, error) {
+		from, err := locations.Find(ctx, origin)
+		if err != nil {
+			return 0, err
+		}
+		to, err := locations.Find(ctx, destination)
+		if err != nil {
+			return 0, err
+		}
+
+		km, err := shipping.Distance(*from, *to)
+		if err == shipping.ErrMissingCoordinates {
+			return base, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		return base + time.Duration(km*float64(perKm)), nil
+	})
+}
+
+// defaultDeadline returns the arrival deadline of a cargo booked now on the
+// route, by the lead time policy of the service.
+func (s *service) defaultDeadline(ctx context.Context, origin, destination shipping.UNLocode) (time.Time, error) {
+	var err error
+	if origin, err = shipping.NewUNLocode(string(origin)); err != nil {
+		return time.Time{}, ErrInvalidArgument
+	}
+	if destination, err = shipping.NewUNLocode(string(destination)); err != nil {
+		return time.Time{}, ErrInvalidArgument
+	}
+
+	d, err := s.leadTimes.LeadTime(ctx, origin, destination)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return s.clock.Now().Add(d), nil
+}
@@ -1,6 +1,7 @@
 package booking
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -18,10 +19,11 @@ func NewLoggingService(logger log.Logger, s Service) Service {
 	return &loggingService{logger, s}
 }
 
-func (s *loggingService) BookNewCargo(origin shipping.UNLocode, destination shipping.UNLocode, deadline time.Time) (id shipping.TrackingID, err error) {
+func (s *loggingService) BookNewCargo(ctx context.Context, origin shipping.UNLocode, destination shipping.UNLocode, deadline time.Time, opts ...BookingOption) (id shipping.TrackingID, err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "book",
+			"tracking_id", id,
 			"origin", origin,
 			"destination", destination,
 			"arrival_deadline", deadline,
@@ -29,10 +31,24 @@ func (s *loggingService) BookNewCargo(origin shipping.UNLocode, destination ship
 			"err", err,
 		)
 	}(time.Now())
-	return s.next.BookNewCargo(origin, destination, deadline)
+	return s.next.BookNewCargo(ctx, origin, destination, deadline, opts...)
 }
 
-func (s *loggingService) LoadCargo(id shipping.TrackingID) (c Cargo, err error) {
+func (s *loggingService) EnsureCargo(ctx context.Context, externalRef string, rs shipping.RouteSpecification) (id shipping.TrackingID, created bool, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "ensure",
+			"external_ref", externalRef,
+			"tracking_id", id,
+			"created", created,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.EnsureCargo(ctx, externalRef, rs)
+}
+
+func (s *loggingService) LoadCargo(ctx context.Context, id shipping.TrackingID) (c Cargo, err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "load",
@@ -41,10 +57,10 @@ func (s *loggingService) LoadCargo(id shipping.TrackingID) (c Cargo, err error)
 			"err", err,
 		)
 	}(time.Now())
-	return s.next.LoadCargo(id)
+	return s.next.LoadCargo(ctx, id)
 }
 
-func (s *loggingService) RequestPossibleRoutesForCargo(id shipping.TrackingID) []shipping.Itinerary {
+func (s *loggingService) RequestPossibleRoutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "request_routes",
@@ -52,22 +68,58 @@ func (s *loggingService) RequestPossibleRoutesForCargo(id shipping.TrackingID) [
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return s.next.RequestPossibleRoutesForCargo(id)
+	return s.next.RequestPossibleRoutesForCargo(ctx, id)
+}
+
+func (s *loggingService) RequestPossibleRoutesForCargoBySpecification(ctx context.Context, id shipping.TrackingID) []Route {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "request_routes_by_specification",
+			"tracking_id", id,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.next.RequestPossibleRoutesForCargoBySpecification(ctx, id)
+}
+
+func (s *loggingService) RequestPossibleReroutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "request_reroutes",
+			"tracking_id", id,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.next.RequestPossibleReroutesForCargo(ctx, id)
 }
 
-func (s *loggingService) AssignCargoToRoute(id shipping.TrackingID, itinerary shipping.Itinerary) (err error) {
+func (s *loggingService) RequestRoutesFromOrigins(ctx context.Context, origins []shipping.UNLocode, rs shipping.RouteSpecification) []shipping.Itinerary {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "request_routes_from_origins",
+			"origins", len(origins),
+			"destination", rs.Destination,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.next.RequestRoutesFromOrigins(ctx, origins, rs)
+}
+
+func (s *loggingService) AssignCargoToRoute(ctx context.Context, id shipping.TrackingID, itinerary shipping.Itinerary) (err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "assign_to_route",
 			"tracking_id", id,
+			"origin", itinerary.InitialDepartureLocation(),
+			"destination", itinerary.FinalArrivalLocation(),
 			"took", time.Since(begin),
 			"err", err,
 		)
 	}(time.Now())
-	return s.next.AssignCargoToRoute(id, itinerary)
+	return s.next.AssignCargoToRoute(ctx, id, itinerary)
 }
 
-func (s *loggingService) ChangeDestination(id shipping.TrackingID, l shipping.UNLocode) (err error) {
+func (s *loggingService) ChangeDestination(ctx context.Context, id shipping.TrackingID, l shipping.UNLocode) (err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "change_destination",
@@ -77,25 +129,184 @@ func (s *loggingService) ChangeDestination(id shipping.TrackingID, l shipping.UN
 			"err", err,
 		)
 	}(time.Now())
-	return s.next.ChangeDestination(id, l)
+	return s.next.ChangeDestination(ctx, id, l)
+}
+
+func (s *loggingService) UpdateRouteSpecification(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "update_route_specification",
+			"tracking_id", id,
+			"origin", rs.Origin,
+			"destination", rs.Destination,
+			"arrival_deadline", rs.ArrivalDeadline,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.UpdateRouteSpecification(ctx, id, rs)
+}
+
+func (s *loggingService) CancelCargo(ctx context.Context, id shipping.TrackingID) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "cancel",
+			"tracking_id", id,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.CancelCargo(ctx, id)
+}
+
+func (s *loggingService) HoldCargo(ctx context.Context, id shipping.TrackingID, reason string) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "hold",
+			"tracking_id", id,
+			"reason", reason,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.HoldCargo(ctx, id, reason)
+}
+
+func (s *loggingService) ReleaseCargo(ctx context.Context, id shipping.TrackingID) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "release",
+			"tracking_id", id,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.ReleaseCargo(ctx, id)
+}
+
+func (s *loggingService) SetCargoTags(ctx context.Context, id shipping.TrackingID, tags map[string]string) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "set_tags",
+			"tracking_id", id,
+			"tags", len(tags),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.SetCargoTags(ctx, id, tags)
+}
+
+func (s *loggingService) TransferCargo(ctx context.Context, id shipping.TrackingID, newCustomer string) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "transfer",
+			"tracking_id", id,
+			"customer_id", newCustomer,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.TransferCargo(ctx, id, newCustomer)
 }
 
-func (s *loggingService) Cargos() []Cargo {
+func (s *loggingService) ReopenCargo(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "reopen",
+			"tracking_id", id,
+			"origin", rs.Origin,
+			"destination", rs.Destination,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.ReopenCargo(ctx, id, rs)
+}
+
+func (s *loggingService) Cargos(ctx context.Context) []Cargo {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "list_cargos",
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return s.next.Cargos()
+	return s.next.Cargos(ctx)
 }
 
-func (s *loggingService) Locations() []Location {
+func (s *loggingService) Locations(ctx context.Context) []Location {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "list_locations",
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return s.next.Locations()
+	return s.next.Locations(ctx)
+}
+
+func (s *loggingService) AssignRoutes(ctx context.Context, assignments map[shipping.TrackingID]shipping.Itinerary) (errs map[shipping.TrackingID]error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "assign_routes",
+			"assignments", len(assignments),
+			"failed", len(errs),
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.next.AssignRoutes(ctx, assignments)
+}
+
+func (s *loggingService) ConsolidateCargos(ctx context.Context, parent shipping.TrackingID, children []shipping.TrackingID) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "consolidate",
+			"parent", parent,
+			"children", len(children),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.ConsolidateCargos(ctx, parent, children)
+}
+
+func (s *loggingService) RecomputeAllDeliveries(ctx context.Context) (updated int, errs []error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "recompute_deliveries",
+			"updated", updated,
+			"failed", len(errs),
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.next.RecomputeAllDeliveries(ctx)
+}
+
+func (s *loggingService) NextSailingWithCapacity(ctx context.Context, rs shipping.RouteSpecification, weight, volume float64, lookahead time.Duration) (sailing Sailing, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "next_sailing_with_capacity",
+			"origin", rs.Origin,
+			"destination", rs.Destination,
+			"weight", weight,
+			"volume", volume,
+			"voyage_number", sailing.VoyageNumber,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.NextSailingWithCapacity(ctx, rs, weight, volume, lookahead)
+}
+
+func (s *loggingService) VoyageLoad(ctx context.Context, number shipping.VoyageNumber) (weight, volume float64, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "voyage_load",
+			"voyage_number", number,
+			"weight", weight,
+			"volume", volume,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.VoyageLoad(ctx, number)
 }
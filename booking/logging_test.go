@@ -0,0 +1,94 @@
+package booking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type recordingLogger struct {
+	logged []map[interface{}]interface{}
+}
+
+func (l *recordingLogger) Log(keyvals ...interface{}) error {
+	m := make(map[interface{}]interface{})
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		m[keyvals[i]] = keyvals[i+1]
+	}
+	l.logged = append(l.logged, m)
+	return nil
+}
+
+func TestLoggingService_BookNewCargoError(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos mockCargoRepository
+		logger recordingLogger
+	)
+
+	s := NewLoggingService(&logger, NewService(&cargos, nil, nil, nil))
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	if _, err := s.BookNewCargo(ctx, "", shipping.AUMEL, deadline); err != ErrInvalidArgument {
+		t.Fatalf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+
+	if len(logger.logged) != 1 {
+		t.Fatalf("len(logger.logged) = %d; want = %d", len(logger.logged), 1)
+	}
+
+	line := logger.logged[0]
+	if line["method"] != "book" {
+		t.Errorf("method = %v; want = %v", line["method"], "book")
+	}
+	if line["destination"] != shipping.AUMEL {
+		t.Errorf("destination = %v; want = %v", line["destination"], shipping.AUMEL)
+	}
+	if line["err"] != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", line["err"], ErrInvalidArgument)
+	}
+	if _, ok := line["took"]; !ok {
+		t.Errorf("took should be logged")
+	}
+}
+
+func TestLoggingService_AssignCargoToRouteError(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos mockCargoRepository
+		logger recordingLogger
+	)
+
+	s := NewLoggingService(&logger, NewService(&cargos, nil, nil, nil))
+
+	itinerary := shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.AUMEL},
+	}}
+
+	if err := s.AssignCargoToRoute(ctx, "ABC123", itinerary); err != shipping.ErrUnknownCargo {
+		t.Fatalf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+
+	if len(logger.logged) != 1 {
+		t.Fatalf("len(logger.logged) = %d; want = %d", len(logger.logged), 1)
+	}
+
+	line := logger.logged[0]
+	if line["method"] != "assign_to_route" {
+		t.Errorf("method = %v; want = %v", line["method"], "assign_to_route")
+	}
+	if line["tracking_id"] != shipping.TrackingID("ABC123") {
+		t.Errorf("tracking_id = %v; want = %v", line["tracking_id"], "ABC123")
+	}
+	if line["origin"] != shipping.SESTO {
+		t.Errorf("origin = %v; want = %v", line["origin"], shipping.SESTO)
+	}
+	if line["err"] != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", line["err"], shipping.ErrUnknownCargo)
+	}
+}
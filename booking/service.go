@@ -3,40 +3,313 @@
 package booking
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-kit/kit/log"
+
 	shipping "github.com/marcusolsson/goddd"
 )
 
 // ErrInvalidArgument is returned when one or more arguments are invalid.
-var ErrInvalidArgument = errors.New("invalid argument")
+var ErrInvalidArgument = shipping.ErrInvalidArgument
+
+// ErrAlreadyRouted is returned when consolidating a cargo that has already
+// been routed.
+var ErrAlreadyRouted = shipping.NewError(shipping.CodeFailedPrecondition, "cargo has already been routed")
+
+// ErrAlreadyConsolidated is returned when consolidating a cargo that already
+// belongs to, or is consolidated into, another cargo.
+var ErrAlreadyConsolidated = shipping.NewError(shipping.CodeFailedPrecondition, "cargo has already been consolidated")
+
+// ErrRouteSpecificationMismatch is returned when consolidating cargos with
+// different route specifications.
+var ErrRouteSpecificationMismatch = shipping.NewError(shipping.CodeFailedPrecondition, "route specifications do not match")
+
+// ErrNoCapacity is returned when no voyage departing within the lookahead
+// window has room for a cargo.
+var ErrNoCapacity = shipping.NewError(shipping.CodeNotFound, "no voyage with capacity")
+
+// maxTrackingIDAttempts is the number of tracking IDs that are tried before
+// giving up on booking a cargo.
+const maxTrackingIDAttempts = 5
+
+// TrackingIDCollisionError is returned when every generated tracking ID was
+// already in use, or could not be reserved.
+type TrackingIDCollisionError struct {
+	Attempts int
+}
+
+func (e *TrackingIDCollisionError) Error() string {
+	return fmt.Sprintf("no unused tracking id after %d attempts", e.Attempts)
+}
+
+// RouteSpecificationLockedError is returned when updating the route
+// specification of a cargo that has already been routed or handled.
+type RouteSpecificationLockedError struct {
+	TrackingID shipping.TrackingID
+	Reason     string
+}
+
+func (e *RouteSpecificationLockedError) Error() string {
+	return fmt.Sprintf("route specification of cargo %s can not be updated: %s", e.TrackingID, e.Reason)
+}
+
+// RecomputeError is returned by RecomputeAllDeliveries for a cargo whose
+// recomputed delivery could not be stored.
+type RecomputeError struct {
+	TrackingID shipping.TrackingID
+	Err        error
+}
+
+func (e *RecomputeError) Error() string {
+	return fmt.Sprintf("delivery of cargo %s not recomputed: %v", e.TrackingID, e.Err)
+}
 
 // Service is the interface that provides booking methods.
 type Service interface {
 	// BookNewCargo registers a new cargo in the tracking system, not yet
-	// routed.
-	BookNewCargo(origin shipping.UNLocode, destination shipping.UNLocode, deadline time.Time) (shipping.TrackingID, error)
+	// routed. A zero deadline is defaulted by the lead time policy given
+	// with WithDefaultDeadline, if any. A deadline in the past returns
+	// ErrInvalidArgument.
+	BookNewCargo(ctx context.Context, origin shipping.UNLocode, destination shipping.UNLocode, deadline time.Time, opts ...BookingOption) (shipping.TrackingID, error)
+
+	// EnsureCargo books a cargo with the given external reference and route
+	// specification, unless one with the reference has already been booked.
+	// It returns the tracking ID of the cargo, and whether it was booked by
+	// this call. Concurrent calls through the same service book at most one
+	// cargo per reference.
+	EnsureCargo(ctx context.Context, externalRef string, rs shipping.RouteSpecification) (shipping.TrackingID, bool, error)
 
 	// LoadCargo returns a read model of a shipping.
-	LoadCargo(id shipping.TrackingID) (Cargo, error)
+	LoadCargo(ctx context.Context, id shipping.TrackingID) (Cargo, error)
 
 	// RequestPossibleRoutesForCargo requests a list of itineraries describing
-	// possible routes for this shipping.
-	RequestPossibleRoutesForCargo(id shipping.TrackingID) []shipping.Itinerary
+	// possible routes for this shipping, satisfying any of its route
+	// specifications. Itineraries arriving after the arrival deadline are
+	// left out.
+	RequestPossibleRoutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary
+
+	// RequestPossibleRoutesForCargoBySpecification requests the same routes
+	// as RequestPossibleRoutesForCargo, each tagged with the route
+	// specification that it satisfies.
+	RequestPossibleRoutesForCargoBySpecification(ctx context.Context, id shipping.TrackingID) []Route
+
+	// RequestPossibleReroutesForCargo requests a list of itineraries
+	// describing possible routes for a misdirected cargo, starting from its
+	// last known location.
+	RequestPossibleReroutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary
+
+	// RequestRoutesFromOrigins requests the itineraries that satisfy the
+	// route specification from any of the given origins, in place of the
+	// origin of the specification. Itineraries found from more than one
+	// origin are only returned once, and the shortest transit comes first.
+	RequestRoutesFromOrigins(ctx context.Context, origins []shipping.UNLocode, rs shipping.RouteSpecification) []shipping.Itinerary
 
 	// AssignCargoToRoute assigns a cargo to the route specified by the
-	// itinerary.
-	AssignCargoToRoute(id shipping.TrackingID, itinerary shipping.Itinerary) error
+	// itinerary. If the cargo is misdirected, the itinerary is expected to
+	// start from the last known location of the cargo. An itinerary that
+	// satisfies an alternate route specification makes it the route
+	// specification of the cargo. A partial itinerary is accepted if it
+	// could be the first part of one that satisfies a route specification.
+	// An itinerary that does not satisfy any route specification, or that
+	// sails on a voyage without capacity for the cargo, is rejected.
+	AssignCargoToRoute(ctx context.Context, id shipping.TrackingID, itinerary shipping.Itinerary) error
+
+	// AssignRoutes assigns each cargo to its itinerary as AssignCargoToRoute
+	// does, and returns the error of every assignment that failed, keyed by
+	// tracking ID. A failure does not stop the remaining cargos from being
+	// assigned.
+	AssignRoutes(ctx context.Context, assignments map[shipping.TrackingID]shipping.Itinerary) map[shipping.TrackingID]error
 
 	// ChangeDestination changes the destination of a shipping.
-	ChangeDestination(id shipping.TrackingID, destination shipping.UNLocode) error
+	ChangeDestination(ctx context.Context, id shipping.TrackingID, destination shipping.UNLocode) error
+
+	// UpdateRouteSpecification replaces the origin, destination and arrival
+	// deadline of a cargo that has neither been routed nor handled yet.
+	UpdateRouteSpecification(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) error
+
+	// CancelCargo cancels the booking of a cargo that has not yet been
+	// claimed.
+	CancelCargo(ctx context.Context, id shipping.TrackingID) error
+
+	// HoldCargo places a cargo that has not yet been claimed on hold for the
+	// given reason. A cargo on hold can not be loaded until it is released.
+	HoldCargo(ctx context.Context, id shipping.TrackingID, reason string) error
+
+	// ReleaseCargo releases a cargo from hold.
+	ReleaseCargo(ctx context.Context, id shipping.TrackingID) error
+
+	// SetCargoTags sets the given tags of a cargo, as by
+	// shipping.Cargo.SetTags. Setting the same tags again changes nothing.
+	SetCargoTags(ctx context.Context, id shipping.TrackingID, tags map[string]string) error
+
+	// TransferCargo makes another customer responsible for a cargo that has
+	// not yet been claimed, as by shipping.Cargo.TransferTo.
+	TransferCargo(ctx context.Context, id shipping.TrackingID, newCustomer string) error
+
+	// ReopenCargo reopens a claimed cargo for redelivery under a new route
+	// specification, as by shipping.Cargo.Reopen. The cargo has to be routed
+	// again. It returns shipping.ErrCargoNotClaimed if the cargo has not been
+	// claimed.
+	ReopenCargo(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) error
 
 	// Cargos returns a list of all cargos that have been booked.
-	Cargos() []Cargo
+	Cargos(ctx context.Context) []Cargo
 
 	// Locations returns a list of registered locations.
-	Locations() []Location
+	Locations(ctx context.Context) []Location
+
+	// ConsolidateCargos consolidates the child cargos into the parent cargo,
+	// to be shipped under it. The parent and its children must all be
+	// unrouted and share the same route specification. The children of a
+	// cargo are found with shipping.CargoRepository.FindChildren.
+	ConsolidateCargos(ctx context.Context, parent shipping.TrackingID, children []shipping.TrackingID) error
+
+	// VoyageLoad returns the total weight and volume of the cargos, that are
+	// not cancelled, whose itinerary has a leg on the given voyage.
+	VoyageLoad(ctx context.Context, number shipping.VoyageNumber) (weight, volume float64, err error)
+
+	// NextSailingWithCapacity returns the first voyage, by departure, that
+	// sails directly from the origin to the destination of the route
+	// specification within the given lookahead, arrives in time, and has room
+	// for a cargo of the given weight and volume on top of its load, as
+	// returned by VoyageLoad. It returns ErrNoCapacity if there is none.
+	NextSailingWithCapacity(ctx context.Context, rs shipping.RouteSpecification, weight, volume float64, lookahead time.Duration) (Sailing, error)
+
+	// RecomputeAllDeliveries derives the delivery of every cargo, archived
+	// or not, from its handling history again, and stores the cargos whose
+	// delivery changed. It returns the number of cargos updated, and a
+	// *RecomputeError for every cargo that could not be stored. Running it
+	// again without new handling updates nothing.
+	RecomputeAllDeliveries(ctx context.Context) (int, []error)
+}
+
+// Option configures optional dependencies of the service.
+type Option func(*service)
+
+// WithTrackingIDFactory makes the service use f to generate the tracking IDs
+// of new cargos, instead of shipping.DefaultTrackingIDFactory.
+func WithTrackingIDFactory(f shipping.TrackingIDFactory) Option {
+	return func(s *service) {
+		s.trackingIDs = f
+	}
+}
+
+// WithTrackingIDReserver makes the service reserve the tracking IDs of new
+// cargos with r, and ask the factory for another ID whenever one has been
+// taken. Without it, the IDs are only checked against the cargo repository.
+func WithTrackingIDReserver(r shipping.TrackingIDReserver) Option {
+	return func(s *service) {
+		s.reserver = r
+	}
+}
+
+// WithVoyageRepository makes the service check the capacity of the voyages
+// in an itinerary before assigning a cargo to it. Without it, capacity is
+// not checked.
+func WithVoyageRepository(voyages shipping.VoyageRepository) Option {
+	return func(s *service) {
+		s.voyages = voyages
+	}
+}
+
+// WithLateRoutes makes the service keep itineraries that arrive after the
+// arrival deadline when requesting routes, logging a warning for each of
+// them. It is intended for debugging the routing service.
+func WithLateRoutes(logger log.Logger) Option {
+	return func(s *service) {
+		s.lateRoutesLogger = logger
+	}
+}
+
+// WithMaxLegs makes the service drop itineraries of more than n legs when
+// requesting routes for a cargo, after those arriving too late have been
+// dropped. Without it, the number of legs is not limited.
+func WithMaxLegs(n int) Option {
+	return func(s *service) {
+		s.maxLegs = n
+	}
+}
+
+// WithClock makes the service read the time of ownership transfers, the
+// start of the lookahead for sailings, and the booking time of cargos given
+// a default deadline, from c, instead of shipping.SystemClock.
+func WithClock(c shipping.Clock) Option {
+	return func(s *service) {
+		s.clock = c
+	}
+}
+
+// WithMinConnectionTime makes the service reject itineraries that transfer a
+// cargo between voyages in less than d: they are dropped when requesting
+// routes, and assigning a cargo to one returns
+// shipping.ErrConnectionTooShort. Without it, connections are not checked.
+func WithMinConnectionTime(d time.Duration) Option {
+	return func(s *service) {
+		s.minConnection = d
+	}
+}
+
+// WithDefaultDeadline makes the service book cargos without an arrival
+// deadline, giving them one that is the lead time of their route by p from
+// now. Without it, booking a cargo without a deadline returns
+// ErrInvalidArgument.
+func WithDefaultDeadline(p LeadTimePolicy) Option {
+	return func(s *service) {
+		s.leadTimes = p
+	}
+}
+
+// BookingOption sets optional attributes of a cargo being booked.
+type BookingOption func(*shipping.Cargo)
+
+// WithWeight sets the weight of the booked cargo, in kilograms.
+func WithWeight(kg float64) BookingOption {
+	return func(c *shipping.Cargo) {
+		c.Weight = kg
+	}
+}
+
+// WithVolume sets the volume of the booked cargo, in cubic meters.
+func WithVolume(m3 float64) BookingOption {
+	return func(c *shipping.Cargo) {
+		c.Volume = m3
+	}
+}
+
+// WithServiceLevel sets the level of service booked for the cargo. Cargos
+// are booked at shipping.Standard by default.
+func WithServiceLevel(level shipping.ServiceLevel) BookingOption {
+	return func(c *shipping.Cargo) {
+		c.ServiceLevel = level
+	}
+}
+
+// WithAlternateRouteSpecifications sets route specifications that the
+// customer accepts instead of the primary one.
+func WithAlternateRouteSpecifications(specs ...shipping.RouteSpecification) BookingOption {
+	return func(c *shipping.Cargo) {
+		c.AlternateRouteSpecifications = append(c.AlternateRouteSpecifications, specs...)
+	}
+}
+
+// WithCustomerID makes the given customer responsible for the booked cargo.
+func WithCustomerID(customer string) BookingOption {
+	return func(c *shipping.Cargo) {
+		c.CustomerID = customer
+	}
+}
+
+// WithTags tags the booked cargo, as by shipping.Cargo.SetTags.
+func WithTags(tags map[string]string) BookingOption {
+	return func(c *shipping.Cargo) {
+		c.SetTags(tags)
+	}
 }
 
 type service struct {
@@ -44,50 +317,251 @@ type service struct {
 	locations      shipping.LocationRepository
 	handlingEvents shipping.HandlingEventRepository
 	routingService shipping.RoutingService
+	trackingIDs    shipping.TrackingIDFactory
+	reserver       shipping.TrackingIDReserver
+	voyages        shipping.VoyageRepository
+	clock          shipping.Clock
+
+	// lateRoutesLogger is set if late itineraries should be kept.
+	lateRoutesLogger log.Logger
+
+	// maxLegs is the most legs of a requested itinerary, if positive.
+	maxLegs int
+
+	// minConnection is the shortest allowed connection between voyages.
+	minConnection time.Duration
+
+	// leadTimes is set if cargos may be booked without a deadline.
+	leadTimes LeadTimePolicy
+
+	// ensureMtx serializes EnsureCargo, so that a reference is looked up and
+	// booked as one step.
+	ensureMtx sync.Mutex
 }
 
-func (s *service) AssignCargoToRoute(id shipping.TrackingID, itinerary shipping.Itinerary) error {
+func (s *service) AssignCargoToRoute(ctx context.Context, id shipping.TrackingID, itinerary shipping.Itinerary) error {
 	if id == "" || len(itinerary.Legs) == 0 {
 		return ErrInvalidArgument
 	}
 
-	c, err := s.cargos.Find(id)
+	c, err := s.cargos.Find(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	specs := c.RouteSpecifications()
+	if c.Delivery.IsMisdirected {
+		specs = []shipping.RouteSpecification{rerouteSpecification(c)}
+	}
+
+	rs, ok := shipping.AnySatisfiedBy(specs, itinerary)
+	if !ok && itinerary.Partial {
+		rs, ok = anyPartiallySatisfiedBy(specs, itinerary)
+	}
+	if !ok {
+		return notSatisfiedError(specs[0], itinerary)
+	}
+
+	if err := itinerary.ValidateConnections(s.minConnection); err != nil {
+		return err
+	}
+
+	if err := s.checkVoyages(ctx, c, itinerary); err != nil {
+		return err
+	}
+
+	if !rs.Equal(c.RouteSpecification) {
+		c.SpecifyNewRoute(rs)
+	}
+
 	c.AssignToRoute(itinerary)
+	c.DeriveDeliveryProgress(s.history(ctx, id))
 
-	return s.cargos.Store(c)
+	return s.cargos.Store(ctx, c)
+}
+
+func (s *service) AssignRoutes(ctx context.Context, assignments map[shipping.TrackingID]shipping.Itinerary) map[shipping.TrackingID]error {
+	errs := make(map[shipping.TrackingID]error)
+	for id, itinerary := range assignments {
+		if err := s.AssignCargoToRoute(ctx, id, itinerary); err != nil {
+			errs[id] = err
+		}
+	}
+	return errs
+}
+
+// history returns the handling history of the cargo. Without a handling
+// event repository the cargo is taken to be unhandled, and the history is
+// empty.
+func (s *service) history(ctx context.Context, id shipping.TrackingID) shipping.HandlingHistory {
+	if s.handlingEvents == nil {
+		return shipping.HandlingHistory{}
+	}
+	return s.handlingEvents.QueryHandlingHistory(ctx, id)
 }
 
-func (s *service) BookNewCargo(origin, destination shipping.UNLocode, deadline time.Time) (shipping.TrackingID, error) {
-	if origin == "" || destination == "" || deadline.IsZero() {
+// checkVoyages returns shipping.ErrVoyageCancelled if the itinerary travels
+// on a cancelled voyage, and shipping.ErrInsufficientCapacity if any carrier
+// movement sailed by the itinerary can not fit the cargo.
+func (s *service) checkVoyages(ctx context.Context, c *shipping.Cargo, itinerary shipping.Itinerary) error {
+	if s.voyages == nil {
+		return nil
+	}
+
+	for _, leg := range itinerary.Legs {
+		v, err := s.voyages.Find(ctx, leg.VoyageNumber)
+		if err != nil {
+			return err
+		}
+		if v.Cancelled {
+			return shipping.ErrVoyageCancelled
+		}
+		movements := v.Schedule.MovementsBetween(leg.LoadLocation, leg.UnloadLocation)
+		if len(movements) == 0 {
+			return shipping.ErrLegNotScheduled
+		}
+		for _, m := range movements {
+			if !m.Fits(c.Weight, c.Volume) {
+				return shipping.ErrInsufficientCapacity
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *service) BookNewCargo(ctx context.Context, origin, destination shipping.UNLocode, deadline time.Time, opts ...BookingOption) (shipping.TrackingID, error) {
+	if deadline.IsZero() && s.leadTimes != nil {
+		var err error
+		if deadline, err = s.defaultDeadline(ctx, origin, destination); err != nil {
+			return "", err
+		}
+	}
+	if deadline.Before(s.clock.Now()) {
 		return "", ErrInvalidArgument
 	}
 
-	id := shipping.NextTrackingID()
-	rs := shipping.RouteSpecification{
+	rs, err := validSpec(shipping.RouteSpecification{
 		Origin:          origin,
 		Destination:     destination,
 		ArrivalDeadline: deadline,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c := shipping.NewCargo("", rs)
+	for _, opt := range opts {
+		opt(c)
 	}
 
-	c := shipping.NewCargo(id, rs)
+	if c.Weight < 0 || c.Volume < 0 || !c.ServiceLevel.IsValid() {
+		return "", ErrInvalidArgument
+	}
+	for i, alt := range c.AlternateRouteSpecifications {
+		if c.AlternateRouteSpecifications[i], err = validSpec(alt); err != nil {
+			return "", err
+		}
+	}
 
-	if err := s.cargos.Store(c); err != nil {
+	id, err := s.nextTrackingID(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.TrackingID = id
+
+	if err := s.cargos.Store(ctx, c); err != nil {
 		return "", err
 	}
 
 	return c.TrackingID, nil
 }
 
-func (s *service) LoadCargo(id shipping.TrackingID) (Cargo, error) {
+func (s *service) EnsureCargo(ctx context.Context, externalRef string, rs shipping.RouteSpecification) (shipping.TrackingID, bool, error) {
+	if externalRef == "" {
+		return "", false, ErrInvalidArgument
+	}
+
+	s.ensureMtx.Lock()
+	defer s.ensureMtx.Unlock()
+
+	c, err := s.cargos.FindByExternalRef(ctx, externalRef)
+	if err == nil {
+		return c.TrackingID, false, nil
+	}
+	if err != shipping.ErrUnknownCargo {
+		return "", false, err
+	}
+
+	id, err := s.BookNewCargo(ctx, rs.Origin, rs.Destination, rs.ArrivalDeadline, func(c *shipping.Cargo) {
+		c.ExternalRef = externalRef
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return id, true, nil
+}
+
+// validSpec returns the route specification with its UN/LOCODEs in canonical
+// form, or ErrInvalidArgument if it is incomplete or they are malformed.
+func validSpec(rs shipping.RouteSpecification) (shipping.RouteSpecification, error) {
+	if rs.ArrivalDeadline.IsZero() {
+		return shipping.RouteSpecification{}, ErrInvalidArgument
+	}
+
+	var err error
+	if rs.Origin, err = shipping.NewUNLocode(string(rs.Origin)); err != nil {
+		return shipping.RouteSpecification{}, ErrInvalidArgument
+	}
+	if rs.Destination, err = shipping.NewUNLocode(string(rs.Destination)); err != nil {
+		return shipping.RouteSpecification{}, ErrInvalidArgument
+	}
+
+	return rs, nil
+}
+
+// nextTrackingID returns a generated tracking ID that has been reserved for a
+// new cargo.
+func (s *service) nextTrackingID(ctx context.Context) (shipping.TrackingID, error) {
+	for i := 0; i < maxTrackingIDAttempts; i++ {
+		id := s.trackingIDs.NextTrackingID()
+
+		err := s.reserver.Reserve(ctx, id)
+		if err == nil {
+			return id, nil
+		}
+		if err != shipping.ErrTrackingIDTaken {
+			return "", err
+		}
+	}
+	return "", &TrackingIDCollisionError{Attempts: maxTrackingIDAttempts}
+}
+
+// repositoryReserver reserves the tracking IDs that are not used by any
+// stored cargo. It does not remember reservations, so concurrent bookings
+// may be handed the same ID.
+type repositoryReserver struct {
+	cargos shipping.CargoRepository
+}
+
+func (r repositoryReserver) Reserve(ctx context.Context, id shipping.TrackingID) error {
+	_, err := r.cargos.Find(ctx, id)
+	if err == nil {
+		return shipping.ErrTrackingIDTaken
+	}
+	if err == shipping.ErrUnknownCargo {
+		return nil
+	}
+	return err
+}
+
+func (s *service) LoadCargo(ctx context.Context, id shipping.TrackingID) (Cargo, error) {
 	if id == "" {
 		return Cargo{}, ErrInvalidArgument
 	}
 
-	c, err := s.cargos.Find(id)
+	c, err := s.cargos.Find(ctx, id)
 	if err != nil {
 		return Cargo{}, err
 	}
@@ -95,17 +569,17 @@ func (s *service) LoadCargo(id shipping.TrackingID) (Cargo, error) {
 	return assemble(c, s.handlingEvents), nil
 }
 
-func (s *service) ChangeDestination(id shipping.TrackingID, destination shipping.UNLocode) error {
+func (s *service) ChangeDestination(ctx context.Context, id shipping.TrackingID, destination shipping.UNLocode) error {
 	if id == "" || destination == "" {
 		return ErrInvalidArgument
 	}
 
-	c, err := s.cargos.Find(id)
+	c, err := s.cargos.Find(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	l, err := s.locations.Find(destination)
+	l, err := s.locations.Find(ctx, destination)
 	if err != nil {
 		return err
 	}
@@ -116,37 +590,343 @@ func (s *service) ChangeDestination(id shipping.TrackingID, destination shipping
 		ArrivalDeadline: c.RouteSpecification.ArrivalDeadline,
 	})
 
-	if err := s.cargos.Store(c); err != nil {
+	if err := s.cargos.Store(ctx, c); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *service) RequestPossibleRoutesForCargo(id shipping.TrackingID) []shipping.Itinerary {
+func (s *service) UpdateRouteSpecification(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	rs, err := validSpec(rs)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.cargos.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !c.Itinerary.IsEmpty() {
+		return &RouteSpecificationLockedError{TrackingID: id, Reason: "cargo has been routed"}
+	}
+	if len(s.history(ctx, id).HandlingEvents) > 0 {
+		return &RouteSpecificationLockedError{TrackingID: id, Reason: "cargo has been handled"}
+	}
+
+	for _, locode := range []shipping.UNLocode{rs.Origin, rs.Destination} {
+		if _, err := s.locations.Find(ctx, locode); err != nil {
+			return err
+		}
+	}
+
+	c.Origin = rs.Origin
+	c.SpecifyNewRoute(rs)
+
+	return s.cargos.Store(ctx, c)
+}
+
+func (s *service) CancelCargo(ctx context.Context, id shipping.TrackingID) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	c, err := s.cargos.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Cancel(); err != nil {
+		return err
+	}
+
+	return s.cargos.Store(ctx, c)
+}
+
+func (s *service) HoldCargo(ctx context.Context, id shipping.TrackingID, reason string) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	c, err := s.cargos.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Hold(reason); err != nil {
+		return err
+	}
+
+	return s.cargos.Store(ctx, c)
+}
+
+func (s *service) ReleaseCargo(ctx context.Context, id shipping.TrackingID) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	c, err := s.cargos.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	c.Release()
+
+	return s.cargos.Store(ctx, c)
+}
+
+func (s *service) SetCargoTags(ctx context.Context, id shipping.TrackingID, tags map[string]string) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	c, err := s.cargos.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	c.SetTags(tags)
+
+	return s.cargos.Store(ctx, c)
+}
+
+func (s *service) TransferCargo(ctx context.Context, id shipping.TrackingID, newCustomer string) error {
+	if id == "" || newCustomer == "" {
+		return ErrInvalidArgument
+	}
+
+	c, err := s.cargos.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := c.TransferTo(newCustomer, s.clock.Now()); err != nil {
+		return err
+	}
+
+	return s.cargos.Store(ctx, c)
+}
+
+func (s *service) ReopenCargo(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	rs, err := validSpec(rs)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.cargos.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Reopen(rs); err != nil {
+		return err
+	}
+
+	return s.cargos.Store(ctx, c)
+}
+
+func (s *service) RequestPossibleRoutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary {
+	if id == "" {
+		return nil
+	}
+
+	itineraries := []shipping.Itinerary{}
+	for _, r := range s.RequestPossibleRoutesForCargoBySpecification(ctx, id) {
+		itineraries = append(itineraries, r.Itinerary)
+	}
+	return itineraries
+}
+
+func (s *service) RequestPossibleRoutesForCargoBySpecification(ctx context.Context, id shipping.TrackingID) []Route {
+	if id == "" {
+		return nil
+	}
+
+	c, err := s.cargos.Find(ctx, id)
+	if err != nil {
+		return []Route{}
+	}
+
+	routes := []Route{}
+	for _, rs := range c.RouteSpecifications() {
+		for _, itinerary := range s.withinMaxLegs(s.withConnections(s.inTime(c.TrackingID, rs, s.routingService.FetchRoutesForSpecification(ctx, rs)))) {
+			routes = append(routes, Route{Itinerary: itinerary, RouteSpecification: rs})
+		}
+	}
+	return routes
+}
+
+func (s *service) RequestPossibleReroutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary {
 	if id == "" {
 		return nil
 	}
 
-	c, err := s.cargos.Find(id)
+	c, err := s.cargos.Find(ctx, id)
 	if err != nil {
 		return []shipping.Itinerary{}
 	}
 
-	return s.routingService.FetchRoutesForSpecification(c.RouteSpecification)
+	if !c.Delivery.IsMisdirected {
+		return []shipping.Itinerary{}
+	}
+
+	rs := rerouteSpecification(c)
+
+	return s.withinMaxLegs(s.withConnections(s.inTime(c.TrackingID, rs, s.routingService.FetchRoutesForSpecification(ctx, rs))))
+}
+
+func (s *service) RequestRoutesFromOrigins(ctx context.Context, origins []shipping.UNLocode, rs shipping.RouteSpecification) []shipping.Itinerary {
+	itineraries := []shipping.Itinerary{}
+	for _, origin := range origins {
+		spec := rs
+		spec.Origin = origin
+
+		for _, itinerary := range s.withinMaxLegs(s.withConnections(s.inTime("", spec, s.routingService.FetchRoutesForSpecification(ctx, spec)))) {
+			if !containsItinerary(itineraries, itinerary) {
+				itineraries = append(itineraries, itinerary)
+			}
+		}
+	}
+
+	sort.SliceStable(itineraries, func(i, j int) bool {
+		return itineraries[i].TransitTime() < itineraries[j].TransitTime()
+	})
+
+	return itineraries
+}
+
+// containsItinerary returns whether any of the itineraries has the same legs
+// as the given itinerary.
+func containsItinerary(itineraries []shipping.Itinerary, itinerary shipping.Itinerary) bool {
+	for _, i := range itineraries {
+		if sameLegs(i.Legs, itinerary.Legs) {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *service) Cargos() []Cargo {
+func sameLegs(a, b []shipping.Leg) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].VoyageNumber != b[i].VoyageNumber ||
+			a[i].LoadLocation != b[i].LoadLocation ||
+			a[i].UnloadLocation != b[i].UnloadLocation ||
+			!a[i].LoadTime.Equal(b[i].LoadTime) ||
+			!a[i].UnloadTime.Equal(b[i].UnloadTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// inTime returns the itineraries that arrive in time according to the route
+// specification.
+func (s *service) inTime(id shipping.TrackingID, rs shipping.RouteSpecification, itineraries []shipping.Itinerary) []shipping.Itinerary {
+	result := []shipping.Itinerary{}
+	for _, itinerary := range itineraries {
+		if rs.ArrivesInTime(itinerary) {
+			result = append(result, itinerary)
+			continue
+		}
+		if s.lateRoutesLogger != nil {
+			s.lateRoutesLogger.Log(
+				"msg", "itinerary arrives after deadline",
+				"tracking_id", id,
+				"arrival", itinerary.FinalArrivalTime(),
+				"arrival_deadline", rs.ArrivalDeadline,
+			)
+			result = append(result, itinerary)
+		}
+	}
+	return result
+}
+
+// withinMaxLegs returns the itineraries that have no more legs than allowed.
+func (s *service) withinMaxLegs(itineraries []shipping.Itinerary) []shipping.Itinerary {
+	if s.maxLegs <= 0 {
+		return itineraries
+	}
+
+	result := []shipping.Itinerary{}
+	for _, itinerary := range itineraries {
+		if len(itinerary.Legs) <= s.maxLegs {
+			result = append(result, itinerary)
+		}
+	}
+	return result
+}
+
+// withConnections returns the itineraries whose connections between voyages
+// are no shorter than the minimum connection time.
+func (s *service) withConnections(itineraries []shipping.Itinerary) []shipping.Itinerary {
+	if s.minConnection <= 0 {
+		return itineraries
+	}
+
+	result := []shipping.Itinerary{}
+	for _, itinerary := range itineraries {
+		if itinerary.ValidateConnections(s.minConnection) == nil {
+			result = append(result, itinerary)
+		}
+	}
+	return result
+}
+
+// notSatisfiedError returns ErrItineraryDoesNotSatisfySpec along with the
+// reasons why the itinerary does not satisfy the route specification.
+func notSatisfiedError(rs shipping.RouteSpecification, itinerary shipping.Itinerary) error {
+	reasons := rs.Explain(itinerary)
+	if len(reasons) == 0 {
+		return shipping.ErrItineraryDoesNotSatisfySpec
+	}
+	return fmt.Errorf("%w: %s", shipping.ErrItineraryDoesNotSatisfySpec, strings.Join(reasons, "; "))
+}
+
+// anyPartiallySatisfiedBy returns the first of the route specifications that
+// is partially satisfied by the itinerary.
+func anyPartiallySatisfiedBy(specs []shipping.RouteSpecification, itinerary shipping.Itinerary) (shipping.RouteSpecification, bool) {
+	for _, rs := range specs {
+		if rs.IsPartiallySatisfiedBy(itinerary) {
+			return rs, true
+		}
+	}
+	return shipping.RouteSpecification{}, false
+}
+
+// rerouteSpecification returns a route specification from where a misdirected
+// cargo currently is to its original destination, keeping the original
+// arrival deadline.
+func rerouteSpecification(c *shipping.Cargo) shipping.RouteSpecification {
+	return shipping.RouteSpecification{
+		Origin:          c.Delivery.LastKnownLocation,
+		Destination:     c.RouteSpecification.Destination,
+		ArrivalDeadline: c.RouteSpecification.ArrivalDeadline,
+	}
+}
+
+func (s *service) Cargos(ctx context.Context) []Cargo {
 	var result []Cargo
-	for _, c := range s.cargos.FindAll() {
+	for _, c := range s.cargos.FindAll(ctx) {
 		result = append(result, assemble(c, s.handlingEvents))
 	}
 	return result
 }
 
-func (s *service) Locations() []Location {
+func (s *service) Locations(ctx context.Context) []Location {
 	var result []Location
-	for _, v := range s.locations.FindAll() {
+	for _, v := range s.locations.FindAll(ctx) {
 		result = append(result, Location{
 			UNLocode: string(v.UNLocode),
 			Name:     v.Name,
@@ -155,14 +935,175 @@ func (s *service) Locations() []Location {
 	return result
 }
 
+func (s *service) ConsolidateCargos(ctx context.Context, parent shipping.TrackingID, children []shipping.TrackingID) error {
+	if parent == "" || len(children) == 0 {
+		return ErrInvalidArgument
+	}
+
+	p, err := s.cargos.Find(ctx, parent)
+	if err != nil {
+		return err
+	}
+	if p.ParentID != "" {
+		return ErrAlreadyConsolidated
+	}
+	if !p.Itinerary.IsEmpty() {
+		return ErrAlreadyRouted
+	}
+
+	// Check every child before linking any, so that a rejected
+	// consolidation leaves all cargos untouched.
+	cargos := make([]*shipping.Cargo, 0, len(children))
+	for _, id := range children {
+		if id == "" || id == parent {
+			return ErrInvalidArgument
+		}
+
+		c, err := s.cargos.Find(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// Consolidations are not nested, so a child can not itself be the
+		// parent of other cargos.
+		switch {
+		case c.ParentID != "" && c.ParentID != parent:
+			return ErrAlreadyConsolidated
+		case len(s.cargos.FindChildren(ctx, id)) > 0:
+			return ErrAlreadyConsolidated
+		case !c.Itinerary.IsEmpty():
+			return ErrAlreadyRouted
+		case !c.RouteSpecification.Equal(p.RouteSpecification):
+			return ErrRouteSpecificationMismatch
+		}
+
+		cargos = append(cargos, c)
+	}
+
+	for _, c := range cargos {
+		c.ParentID = parent
+		if err := s.cargos.Store(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) VoyageLoad(ctx context.Context, number shipping.VoyageNumber) (weight, volume float64, err error) {
+	if number == "" {
+		return 0, 0, ErrInvalidArgument
+	}
+
+	for _, c := range s.cargos.FindAll(ctx) {
+		if c.Cancelled || !onVoyage(c.Itinerary, number) {
+			continue
+		}
+		weight += c.Weight
+		volume += c.Volume
+	}
+
+	return weight, volume, nil
+}
+
+func (s *service) RecomputeAllDeliveries(ctx context.Context) (int, []error) {
+	var (
+		updated int
+		errs    []error
+	)
+	for _, c := range s.cargos.FindAllIncludingArchived(ctx) {
+		if len(c.DeriveDeliveryProgress(s.history(ctx, c.TrackingID))) == 0 {
+			continue
+		}
+		if err := s.cargos.Store(ctx, c); err != nil {
+			errs = append(errs, &RecomputeError{TrackingID: c.TrackingID, Err: err})
+			continue
+		}
+		updated++
+	}
+	return updated, errs
+}
+
+func (s *service) NextSailingWithCapacity(ctx context.Context, rs shipping.RouteSpecification, weight, volume float64, lookahead time.Duration) (Sailing, error) {
+	if rs.Origin == "" || rs.Destination == "" || weight < 0 || volume < 0 || lookahead <= 0 {
+		return Sailing{}, ErrInvalidArgument
+	}
+	if s.voyages == nil {
+		return Sailing{}, ErrNoCapacity
+	}
+
+	var (
+		start = s.clock.Now()
+		end   = start.Add(lookahead)
+	)
+
+	for _, v := range s.voyages.FindDepartingBetween(ctx, rs.Origin, start, end) {
+		if v.Cancelled {
+			continue
+		}
+		movements := v.Schedule.MovementsBetween(rs.Origin, rs.Destination)
+		if len(movements) == 0 || movements[0].DepartureTime.Before(start) || !movements[0].DepartureTime.Before(end) {
+			continue
+		}
+		last := movements[len(movements)-1]
+		if !rs.ArrivalDeadline.IsZero() && last.ArrivalTime.After(rs.ArrivalDeadline) {
+			continue
+		}
+
+		loadWeight, loadVolume, err := s.VoyageLoad(ctx, v.VoyageNumber)
+		if err != nil {
+			return Sailing{}, err
+		}
+		if !fitsAll(movements, loadWeight+weight, loadVolume+volume) {
+			continue
+		}
+
+		return Sailing{
+			VoyageNumber:  string(v.VoyageNumber),
+			DepartureTime: movements[0].DepartureTime,
+			ArrivalTime:   last.ArrivalTime,
+		}, nil
+	}
+
+	return Sailing{}, ErrNoCapacity
+}
+
+// fitsAll returns whether every movement can carry the given weight and
+// volume.
+func fitsAll(movements []shipping.CarrierMovement, weight, volume float64) bool {
+	for _, m := range movements {
+		if !m.Fits(weight, volume) {
+			return false
+		}
+	}
+	return true
+}
+
+// onVoyage returns whether any leg of the itinerary is sailed on the voyage.
+func onVoyage(itinerary shipping.Itinerary, number shipping.VoyageNumber) bool {
+	for _, leg := range itinerary.Legs {
+		if leg.VoyageNumber == number {
+			return true
+		}
+	}
+	return false
+}
+
 // NewService creates a booking service with necessary dependencies.
-func NewService(cargos shipping.CargoRepository, locations shipping.LocationRepository, events shipping.HandlingEventRepository, rs shipping.RoutingService) Service {
-	return &service{
+func NewService(cargos shipping.CargoRepository, locations shipping.LocationRepository, events shipping.HandlingEventRepository, rs shipping.RoutingService, opts ...Option) Service {
+	s := &service{
 		cargos:         cargos,
 		locations:      locations,
 		handlingEvents: events,
 		routingService: rs,
+		trackingIDs:    shipping.DefaultTrackingIDFactory,
+		reserver:       repositoryReserver{cargos: cargos},
+		clock:          shipping.SystemClock,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Location is a read model for booking views.
@@ -171,15 +1112,33 @@ type Location struct {
 	Name     string `json:"name"`
 }
 
+// Sailing is a read model of a voyage that a cargo could be booked on.
+type Sailing struct {
+	VoyageNumber  string    `json:"voyage_number"`
+	DepartureTime time.Time `json:"departure_time"`
+	ArrivalTime   time.Time `json:"arrival_time"`
+}
+
+// Route is a read model of a possible route for a cargo, tagged with the
+// route specification that the itinerary satisfies.
+type Route struct {
+	Itinerary          shipping.Itinerary          `json:"itinerary"`
+	RouteSpecification shipping.RouteSpecification `json:"route_specification"`
+}
+
 // Cargo is a read model for booking views.
 type Cargo struct {
-	ArrivalDeadline time.Time      `json:"arrival_deadline"`
-	Destination     string         `json:"destination"`
-	Legs            []shipping.Leg `json:"legs,omitempty"`
-	Misrouted       bool           `json:"misrouted"`
-	Origin          string         `json:"origin"`
-	Routed          bool           `json:"routed"`
-	TrackingID      string         `json:"tracking_id"`
+	ArrivalDeadline time.Time         `json:"arrival_deadline"`
+	Destination     string            `json:"destination"`
+	Legs            []shipping.Leg    `json:"legs,omitempty"`
+	Misrouted       bool              `json:"misrouted"`
+	Origin          string            `json:"origin"`
+	Routed          bool              `json:"routed"`
+	Cancelled       bool              `json:"cancelled"`
+	CustomerID      string            `json:"customer_id,omitempty"`
+	ServiceLevel    string            `json:"service_level"`
+	Tags            map[string]string `json:"tags,omitempty"`
+	TrackingID      string            `json:"tracking_id"`
 }
 
 func assemble(c *shipping.Cargo, events shipping.HandlingEventRepository) Cargo {
@@ -189,7 +1148,11 @@ func assemble(c *shipping.Cargo, events shipping.HandlingEventRepository) Cargo
 		Destination:     string(c.RouteSpecification.Destination),
 		Misrouted:       c.Delivery.RoutingStatus == shipping.Misrouted,
 		Routed:          !c.Itinerary.IsEmpty(),
+		Cancelled:       c.Cancelled,
+		ServiceLevel:    c.ServiceLevel.String(),
 		ArrivalDeadline: c.RouteSpecification.ArrivalDeadline,
 		Legs:            c.Itinerary.Legs,
+		Tags:            c.Tags,
+		CustomerID:      c.CustomerID,
 	}
 }
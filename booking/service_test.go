@@ -1,14 +1,29 @@
 package booking
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
 	"github.com/marcusolsson/goddd/mock"
 )
 
+// beforeDeadline makes the service book cargos at a time before the arrival
+// deadlines used by the tests, which are otherwise in the past.
+var beforeDeadline = WithClock(shipping.ClockFunc(func() time.Time {
+	return time.Date(2015, time.November, 1, 0, 0, 0, 0, time.UTC)
+}))
+
 func TestBookNewCargo(t *testing.T) {
+	ctx := context.Background()
+
 	var (
 		origin      = shipping.SESTO
 		destination = shipping.AUMEL
@@ -17,14 +32,14 @@ func TestBookNewCargo(t *testing.T) {
 
 	var cargos mockCargoRepository
 
-	s := NewService(&cargos, nil, nil, nil)
+	s := NewService(&cargos, nil, nil, nil, beforeDeadline)
 
-	id, err := s.BookNewCargo(origin, destination, deadline)
+	id, err := s.BookNewCargo(ctx, origin, destination, deadline)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	c, err := cargos.Find(id)
+	c, err := cargos.Find(ctx, id)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -43,11 +58,234 @@ func TestBookNewCargo(t *testing.T) {
 		t.Errorf("c.RouteSpecification.ArrivalDeadline = %s; want = %s",
 			c.RouteSpecification.ArrivalDeadline, deadline)
 	}
+	if c.ServiceLevel != shipping.Standard {
+		t.Errorf("c.ServiceLevel = %v; want = %v", c.ServiceLevel, shipping.Standard)
+	}
+
+	id, err = s.BookNewCargo(ctx, origin, destination, deadline, WithWeight(1200), WithVolume(14.5), WithServiceLevel(shipping.Critical))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = cargos.Find(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Weight != 1200 {
+		t.Errorf("c.Weight = %v; want = %v", c.Weight, 1200)
+	}
+	if c.Volume != 14.5 {
+		t.Errorf("c.Volume = %v; want = %v", c.Volume, 14.5)
+	}
+	if c.ServiceLevel != shipping.Critical {
+		t.Errorf("c.ServiceLevel = %v; want = %v", c.ServiceLevel, shipping.Critical)
+	}
+
+	if _, err := s.BookNewCargo(ctx, origin, destination, deadline, WithWeight(-1)); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+	if _, err := s.BookNewCargo(ctx, origin, destination, deadline, WithServiceLevel(10)); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+	if _, err := s.BookNewCargo(ctx, origin, destination, deadline, WithWeight(-1)); !errors.Is(err, shipping.ErrInvalidArgument) {
+		t.Errorf("errors.Is(%v, shipping.ErrInvalidArgument) = %v; want = %v", err, false, true)
+	}
+}
+
+func TestBookNewCargo_TrackingIDCollision(t *testing.T) {
+	ctx := context.Background()
+
+	ids := []shipping.TrackingID{"2017-0001", "2017-0002"}
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		if id == "2017-0001" {
+			return &shipping.Cargo{TrackingID: id}, nil
+		}
+		return nil, shipping.ErrUnknownCargo
+	}
+	cargos.StoreFn = func(c *shipping.Cargo) error {
+		return nil
+	}
+
+	s := NewService(&cargos, nil, nil, nil, beforeDeadline, WithTrackingIDFactory(shipping.TrackingIDFactoryFunc(func() shipping.TrackingID {
+		id := ids[0]
+		ids = ids[1:]
+		return id
+	})))
+
+	id, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.AUMEL, time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != "2017-0002" {
+		t.Errorf("id = %s; want = %s", id, "2017-0002")
+	}
+}
+
+func TestBookNewCargo_TrackingIDReserver(t *testing.T) {
+	ctx := context.Background()
+
+	var tests = []struct {
+		rejected int
+		want     shipping.TrackingID
+	}{
+		{0, "ID-0"},
+		{1, "ID-1"},
+		{maxTrackingIDAttempts - 1, shipping.TrackingID(fmt.Sprintf("ID-%d", maxTrackingIDAttempts-1))},
+		{maxTrackingIDAttempts, ""},
+	}
+
+	for _, tt := range tests {
+		var (
+			cargos   mockCargoRepository
+			next     int
+			reserved []shipping.TrackingID
+		)
+
+		factory := shipping.TrackingIDFactoryFunc(func() shipping.TrackingID {
+			id := shipping.TrackingID(fmt.Sprintf("ID-%d", next))
+			next++
+			return id
+		})
+
+		// The reserver rejects the first candidates, as if they had been
+		// taken by another shard.
+		reserver := shipping.TrackingIDReserverFunc(func(ctx context.Context, id shipping.TrackingID) error {
+			reserved = append(reserved, id)
+			if len(reserved) <= tt.rejected {
+				return shipping.ErrTrackingIDTaken
+			}
+			return nil
+		})
+
+		s := NewService(&cargos, nil, nil, nil, beforeDeadline, WithTrackingIDFactory(factory), WithTrackingIDReserver(reserver))
+
+		id, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.AUMEL, time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC))
+
+		if tt.want == "" {
+			if _, ok := err.(*TrackingIDCollisionError); !ok {
+				t.Errorf("rejected %d: err = %v; want = %T", tt.rejected, err, &TrackingIDCollisionError{})
+			}
+			if len(reserved) != maxTrackingIDAttempts {
+				t.Errorf("rejected %d: len(reserved) = %d; want = %d", tt.rejected, len(reserved), maxTrackingIDAttempts)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != tt.want {
+			t.Errorf("rejected %d: id = %s; want = %s", tt.rejected, id, tt.want)
+		}
+	}
+}
+
+func TestBookNewCargo_DefaultDeadline(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	var cargos mockCargoRepository
+
+	s := NewService(&cargos, nil, nil, nil,
+		WithClock(shipping.ClockFunc(func() time.Time { return now })),
+		WithDefaultDeadline(FixedLeadTime(21*24*time.Hour)),
+	)
+
+	var tests = []struct {
+		name     string
+		deadline time.Time
+		want     time.Time
+	}{
+		{"defaulted", time.Time{}, now.AddDate(0, 0, 21)},
+		{"explicit", now.AddDate(0, 0, 7), now.AddDate(0, 0, 7)},
+	}
+
+	for _, tt := range tests {
+		id, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.AUMEL, tt.deadline)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		c, err := cargos.Find(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.RouteSpecification.ArrivalDeadline; !got.Equal(tt.want) {
+			t.Errorf("%s: ArrivalDeadline = %v; want = %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := s.BookNewCargo(ctx, "", shipping.AUMEL, time.Time{}); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+
+	if _, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.AUMEL, now.AddDate(0, 0, -1)); err != ErrInvalidArgument {
+		t.Errorf("past deadline: err = %v; want = %v", err, ErrInvalidArgument)
+	}
+
+	s = NewService(&cargos, nil, nil, nil)
+
+	if _, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.AUMEL, time.Time{}); err != ErrInvalidArgument {
+		t.Errorf("without a policy: err = %v; want = %v", err, ErrInvalidArgument)
+	}
+}
+
+func TestDistanceLeadTime(t *testing.T) {
+	ctx := context.Background()
+
+	p := DistanceLeadTime(inmem.NewLocationRepository(), 24*time.Hour, time.Minute)
+
+	km, err := shipping.Distance(*shipping.Stockholm, *shipping.Melbourne)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := p.LeadTime(ctx, shipping.SESTO, shipping.AUMEL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 24*time.Hour + time.Duration(km*float64(time.Minute)); got != want {
+		t.Errorf("LeadTime(SESTO, AUMEL) = %v; want = %v", got, want)
+	}
+
+	if _, err := p.LeadTime(ctx, shipping.SESTO, "XXXXX"); err != shipping.ErrUnknownLocation {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownLocation)
+	}
+}
+
+func TestBookNewCargo_TrackingIDExhausted(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return &shipping.Cargo{TrackingID: id}, nil
+	}
+
+	s := NewService(&cargos, nil, nil, nil, beforeDeadline, WithTrackingIDFactory(shipping.TrackingIDFactoryFunc(func() shipping.TrackingID {
+		return "2017-0001"
+	})))
+
+	_, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.AUMEL, time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC))
+
+	e, ok := err.(*TrackingIDCollisionError)
+	if !ok {
+		t.Fatalf("err = %v; want = %T", err, e)
+	}
+	if e.Attempts != maxTrackingIDAttempts {
+		t.Errorf("e.Attempts = %d; want = %d", e.Attempts, maxTrackingIDAttempts)
+	}
+	if cargos.StoreInvoked {
+		t.Errorf("no cargo should be stored")
+	}
 }
 
 type stubRoutingService struct{}
 
-func (s *stubRoutingService) FetchRoutesForSpecification(rs shipping.RouteSpecification) []shipping.Itinerary {
+func (s *stubRoutingService) FetchRoutesForSpecification(ctx context.Context, rs shipping.RouteSpecification) []shipping.Itinerary {
 	legs := []shipping.Leg{
 		{LoadLocation: rs.Origin, UnloadLocation: rs.Destination},
 	}
@@ -58,6 +296,8 @@ func (s *stubRoutingService) FetchRoutesForSpecification(rs shipping.RouteSpecif
 }
 
 func TestRequestPossibleRoutesForCargo(t *testing.T) {
+	ctx := context.Background()
+
 	var (
 		origin      = shipping.SESTO
 		destination = shipping.AUMEL
@@ -68,174 +308,1842 @@ func TestRequestPossibleRoutesForCargo(t *testing.T) {
 
 	var rs stubRoutingService
 
-	s := NewService(&cargos, nil, nil, &rs)
+	s := NewService(&cargos, nil, nil, &rs, beforeDeadline)
 
-	r := s.RequestPossibleRoutesForCargo("no_such_id")
+	r := s.RequestPossibleRoutesForCargo(ctx, "no_such_id")
 
 	if len(r) != 0 {
 		t.Errorf("len(r) = %d; want = %d", len(r), 0)
 	}
 
-	id, err := s.BookNewCargo(origin, destination, deadline)
+	id, err := s.BookNewCargo(ctx, origin, destination, deadline)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	i := s.RequestPossibleRoutesForCargo(id)
+	i := s.RequestPossibleRoutesForCargo(ctx, id)
 
 	if len(i) != 1 {
 		t.Errorf("len(i) = %d; want = %d", len(i), 1)
 	}
 }
 
-func TestAssignCargoToRoute(t *testing.T) {
-	var cargos mockCargoRepository
+func TestRequestPossibleRoutesForCargo_Deadline(t *testing.T) {
+	ctx := context.Background()
 
-	var rs stubRoutingService
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
 
-	s := NewService(&cargos, nil, nil, &rs)
+	arriving := func(arrival time.Time) shipping.Itinerary {
+		return shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V100", shipping.SESTO, shipping.AUMEL, deadline.AddDate(0, 0, -5), arrival),
+		}}
+	}
 
 	var (
-		origin      = shipping.SESTO
-		destination = shipping.AUMEL
-		deadline    = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		before = arriving(deadline.Add(-time.Hour))
+		at     = arriving(deadline)
+		after  = arriving(deadline.Add(time.Hour))
 	)
 
-	id, err := s.BookNewCargo(origin, destination, deadline)
-	if err != nil {
+	var rs mock.RoutingService
+	rs.FetchRoutesFn = func(shipping.RouteSpecification) []shipping.Itinerary {
+		return []shipping.Itinerary{before, at, after}
+	}
+
+	var cargos mockCargoRepository
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: deadline,
+	})); err != nil {
 		t.Fatal(err)
 	}
 
-	i := s.RequestPossibleRoutesForCargo(id)
+	s := NewService(&cargos, nil, nil, &rs)
 
-	if len(i) != 1 {
-		t.Errorf("len(i) = %d; want = %d", len(i), 1)
+	got := s.RequestPossibleRoutesForCargo(ctx, "ABC")
+	if want := []shipping.Itinerary{before, at}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RequestPossibleRoutesForCargo() = %v; want = %v", got, want)
 	}
 
-	if err := s.AssignCargoToRoute(id, i[0]); err != nil {
-		t.Fatal(err)
-	}
+	var logger recordingLogger
 
-	if err := s.AssignCargoToRoute("no_such_id", shipping.Itinerary{}); err != ErrInvalidArgument {
-		t.Errorf("err = %s; want = %s", err, ErrInvalidArgument)
+	s = NewService(&cargos, nil, nil, &rs, WithLateRoutes(&logger))
+
+	got = s.RequestPossibleRoutesForCargo(ctx, "ABC")
+	if want := []shipping.Itinerary{before, at, after}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RequestPossibleRoutesForCargo() = %v; want = %v", got, want)
+	}
+	if len(logger.logged) != 1 {
+		t.Errorf("len(logger.logged) = %d; want = %d", len(logger.logged), 1)
 	}
 }
 
-func TestChangeCargoDestination(t *testing.T) {
-	var cargos mockCargoRepository
-	var locations mock.LocationRepository
+func TestRequestPossibleRoutesForCargo_MaxLegs(t *testing.T) {
+	ctx := context.Background()
 
-	locations.FindFn = func(loc shipping.UNLocode) (*shipping.Location, error) {
-		if loc != shipping.AUMEL {
-			return nil, shipping.ErrUnknownLocation
+	var (
+		deadline = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		depart   = deadline.AddDate(0, 0, -10)
+	)
+
+	legs := func(n int, days int) shipping.Itinerary {
+		var itinerary shipping.Itinerary
+		for i := 0; i < n; i++ {
+			itinerary.Legs = append(itinerary.Legs, shipping.NewLeg("V100", shipping.SESTO, shipping.AUMEL, depart, depart.AddDate(0, 0, days)))
 		}
-		return shipping.Melbourne, nil
+		return itinerary
 	}
 
-	var rs stubRoutingService
+	var (
+		one     = legs(1, 5)
+		two     = legs(2, 5)
+		three   = legs(3, 5)
+		tooLate = legs(1, 11)
+	)
 
-	s := NewService(&cargos, &locations, nil, &rs)
+	var rs mock.RoutingService
+	rs.FetchRoutesFn = func(shipping.RouteSpecification) []shipping.Itinerary {
+		return []shipping.Itinerary{three, tooLate, one, two}
+	}
 
-	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+	var cargos mockCargoRepository
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC", shipping.RouteSpecification{
 		Origin:          shipping.SESTO,
-		Destination:     shipping.CNHKG,
-		ArrivalDeadline: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
-	})
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: deadline,
+	})); err != nil {
+		t.Fatal(err)
+	}
 
-	if err := s.ChangeDestination("no_such_id", shipping.SESTO); err != shipping.ErrUnknownCargo {
-		t.Errorf("err = %s; want = %s", err, shipping.ErrUnknownCargo)
+	var tests = []struct {
+		maxLegs int
+		want    []shipping.Itinerary
+	}{
+		{0, []shipping.Itinerary{three, one, two}},
+		{1, []shipping.Itinerary{one}},
+		{2, []shipping.Itinerary{one, two}},
+		{3, []shipping.Itinerary{three, one, two}},
 	}
 
-	if err := cargos.Store(c); err != nil {
-		t.Fatal(err)
+	for _, tt := range tests {
+		s := NewService(&cargos, nil, nil, &rs, WithMaxLegs(tt.maxLegs))
+
+		if got := s.RequestPossibleRoutesForCargo(ctx, "ABC"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("maxLegs %d: RequestPossibleRoutesForCargo() = %v; want = %v", tt.maxLegs, got, tt.want)
+		}
 	}
+}
 
-	if err := s.ChangeDestination(c.TrackingID, "no_such_unlocode"); err != shipping.ErrUnknownLocation {
-		t.Errorf("err = %s; want = %s", err, shipping.ErrUnknownLocation)
+func TestMinConnectionTime(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		deadline = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		unload   = deadline.AddDate(0, 0, -5)
+	)
+
+	connecting := func(layover time.Duration) shipping.Itinerary {
+		return shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V100", shipping.SESTO, shipping.DEHAM, unload.AddDate(0, 0, -2), unload),
+			shipping.NewLeg("V200", shipping.DEHAM, shipping.AUMEL, unload.Add(layover), unload.AddDate(0, 0, 2)),
+		}}
 	}
 
-	if c.RouteSpecification.Destination != shipping.CNHKG {
-		t.Errorf("c.RouteSpecification.Destination = %s; want = %s",
-			c.RouteSpecification.Destination, shipping.CNHKG)
+	var (
+		tight      = connecting(5 * time.Minute)
+		acceptable = connecting(4 * time.Hour)
+	)
+
+	var rs mock.RoutingService
+	rs.FetchRoutesFn = func(shipping.RouteSpecification) []shipping.Itinerary {
+		return []shipping.Itinerary{tight, acceptable}
 	}
 
-	if err := s.ChangeDestination(c.TrackingID, shipping.AUMEL); err != nil {
+	var cargos mockCargoRepository
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: deadline,
+	})); err != nil {
 		t.Fatal(err)
 	}
 
-	uc, err := cargos.Find(c.TrackingID)
-	if err != nil {
-		t.Fatal(err)
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
 	}
 
-	if uc.RouteSpecification.Destination != shipping.AUMEL {
-		t.Errorf("uc.RouteSpecification.Destination = %s; want = %s",
-			uc.RouteSpecification.Destination, shipping.AUMEL)
+	s := NewService(&cargos, nil, &events, &rs, WithMinConnectionTime(time.Hour))
+
+	want := []shipping.Itinerary{acceptable}
+	if got := s.RequestPossibleRoutesForCargo(ctx, "ABC"); !reflect.DeepEqual(got, want) {
+		t.Errorf("RequestPossibleRoutesForCargo() = %v; want = %v", got, want)
+	}
+
+	if err := s.AssignCargoToRoute(ctx, "ABC", tight); err != shipping.ErrConnectionTooShort {
+		t.Errorf("AssignCargoToRoute(tight) = %v; want = %v", err, shipping.ErrConnectionTooShort)
+	}
+	if err := s.AssignCargoToRoute(ctx, "ABC", acceptable); err != nil {
+		t.Errorf("AssignCargoToRoute(acceptable) = %v; want = %v", err, nil)
 	}
 }
 
-func TestLoadCargo(t *testing.T) {
+func TestRequestPossibleRoutesForCargo_Alternates(t *testing.T) {
+	ctx := context.Background()
+
 	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
 
-	var cargos mock.CargoRepository
-	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
-		return &shipping.Cargo{
-			TrackingID: "test_id",
-			Origin:     shipping.SESTO,
-			RouteSpecification: shipping.RouteSpecification{
-				Origin:          shipping.SESTO,
-				Destination:     shipping.AUMEL,
-				ArrivalDeadline: deadline,
-			},
-		}, nil
+	var (
+		primary   = shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.NLRTM, ArrivalDeadline: deadline}
+		alternate = shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.DEHAM, ArrivalDeadline: deadline}
+	)
+
+	var cargos mockCargoRepository
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
 	}
 
-	s := NewService(&cargos, nil, nil, nil)
+	s := NewService(&cargos, nil, &events, &stubRoutingService{}, beforeDeadline)
 
-	c, err := s.LoadCargo("test_id")
+	id, err := s.BookNewCargo(ctx, primary.Origin, primary.Destination, primary.ArrivalDeadline,
+		WithAlternateRouteSpecifications(alternate),
+	)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if c.TrackingID != "test_id" {
-		t.Errorf("c.TrackingID = %s; want = %s", c.TrackingID, "test_id")
+	routes := s.RequestPossibleRoutesForCargoBySpecification(ctx, id)
+
+	if len(routes) != 2 {
+		t.Fatalf("len(routes) = %d; want = %d", len(routes), 2)
 	}
-	if c.Origin != "SESTO" {
-		t.Errorf("c.Origin = %s; want = %s", c.Origin, "SESTO")
+	for i, want := range []shipping.RouteSpecification{primary, alternate} {
+		if !routes[i].RouteSpecification.Equal(want) {
+			t.Errorf("routes[%d].RouteSpecification = %v; want = %v", i, routes[i].RouteSpecification, want)
+		}
+		if got := routes[i].Itinerary.FinalArrivalLocation(); got != want.Destination {
+			t.Errorf("routes[%d] arrives at %s; want = %s", i, got, want.Destination)
+		}
 	}
-	if c.Destination != "AUMEL" {
-		t.Errorf("c.Destination = %s; want = %s", c.Origin, "AUMEL")
+
+	if got := s.RequestPossibleRoutesForCargo(ctx, id); len(got) != 2 {
+		t.Errorf("len(RequestPossibleRoutesForCargo()) = %d; want = %d", len(got), 2)
 	}
-	if c.ArrivalDeadline != deadline {
-		t.Errorf("c.ArrivalDeadline = %s; want = %s", c.ArrivalDeadline, deadline)
+
+	// Assigning a route to the alternate destination makes it the route
+	// specification of the cargo.
+	if err := s.AssignCargoToRoute(ctx, id, routes[1].Itinerary); err != nil {
+		t.Fatal(err)
 	}
-	if c.Misrouted {
-		t.Errorf("cargo should not be misrouted")
+
+	c, err := cargos.Find(ctx, id)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if c.Routed {
-		t.Errorf("cargo should not have been routed")
+	if c.RouteSpecification.Destination != shipping.DEHAM {
+		t.Errorf("c.RouteSpecification.Destination = %s; want = %s", c.RouteSpecification.Destination, shipping.DEHAM)
 	}
-	if len(c.Legs) != 0 {
-		t.Errorf("len(c.Legs) = %d; want = %d", len(c.Legs), 0)
+	if c.Delivery.RoutingStatus != shipping.Routed {
+		t.Errorf("c.Delivery.RoutingStatus = %s; want = %s", c.Delivery.RoutingStatus, shipping.Routed)
 	}
 }
 
-type mockCargoRepository struct {
-	cargo *shipping.Cargo
-}
+func TestAssignCargoToRoute(t *testing.T) {
+	ctx := context.Background()
 
-func (r *mockCargoRepository) Store(c *shipping.Cargo) error {
-	r.cargo = c
-	return nil
-}
+	var cargos mockCargoRepository
 
-func (r *mockCargoRepository) Find(id shipping.TrackingID) (*shipping.Cargo, error) {
-	if r.cargo != nil {
-		return r.cargo, nil
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
+	}
+
+	var rs stubRoutingService
+
+	s := NewService(&cargos, nil, &events, &rs, beforeDeadline)
+
+	var (
+		origin      = shipping.SESTO
+		destination = shipping.AUMEL
+		deadline    = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	)
+
+	id, err := s.BookNewCargo(ctx, origin, destination, deadline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := s.RequestPossibleRoutesForCargo(ctx, id)
+
+	if len(i) != 1 {
+		t.Errorf("len(i) = %d; want = %d", len(i), 1)
+	}
+
+	if err := s.AssignCargoToRoute(ctx, id, i[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AssignCargoToRoute(ctx, "no_such_id", shipping.Itinerary{}); err != ErrInvalidArgument {
+		t.Errorf("err = %s; want = %s", err, ErrInvalidArgument)
 	}
-	return nil, shipping.ErrUnknownCargo
 }
 
-func (r *mockCargoRepository) FindAll() []*shipping.Cargo {
-	return []*shipping.Cargo{r.cargo}
+func TestAssignRoutes(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var (
+		cargos = inmem.NewCargoRepository()
+		events = inmem.NewHandlingEventRepository()
+	)
+
+	for id, dest := range map[shipping.TrackingID]shipping.UNLocode{"ABC": shipping.CNHKG, "DEF": shipping.AUMEL} {
+		if err := cargos.Store(ctx, shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:          shipping.SESTO,
+			Destination:     dest,
+			ArrivalDeadline: deadline,
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewService(cargos, nil, events, nil)
+
+	leg := func(from, to shipping.UNLocode) shipping.Itinerary {
+		return shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V100", from, to, deadline.AddDate(0, 0, -5), deadline.AddDate(0, 0, -1)),
+		}}
+	}
+
+	errs := s.AssignRoutes(ctx, map[shipping.TrackingID]shipping.Itinerary{
+		"ABC":        leg(shipping.SESTO, shipping.CNHKG),
+		"DEF":        leg(shipping.SESTO, shipping.CNHKG),
+		"no_such_id": leg(shipping.SESTO, shipping.CNHKG),
+	})
+
+	want := map[shipping.TrackingID]error{
+		"DEF":        shipping.ErrItineraryDoesNotSatisfySpec,
+		"no_such_id": shipping.ErrUnknownCargo,
+	}
+	if len(errs) != len(want) {
+		t.Errorf("AssignRoutes() = %v; want = %v", errs, want)
+	}
+	for id, err := range want {
+		if !errors.Is(errs[id], err) {
+			t.Errorf("%s: err = %v; want = %v", id, errs[id], err)
+		}
+	}
+
+	// The valid assignment is stored despite the failures.
+	c, err := cargos.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Delivery.RoutingStatus != shipping.Routed {
+		t.Errorf("RoutingStatus = %v; want = %v", c.Delivery.RoutingStatus, shipping.Routed)
+	}
+
+	c, err = cargos.Find(ctx, "DEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Itinerary.IsEmpty() {
+		t.Errorf("Itinerary = %v; want empty", c.Itinerary)
+	}
+}
+
+func TestAssignCargoToRoute_DoesNotSatisfySpec(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: deadline,
+	})
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return c, nil
+	}
+
+	s := NewService(&cargos, nil, nil, nil)
+
+	leg := func(from, to shipping.UNLocode, arrival time.Time) shipping.Itinerary {
+		return shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V100", from, to, deadline.AddDate(0, 0, -5), arrival),
+		}}
+	}
+
+	var tests = []struct {
+		name      string
+		itinerary shipping.Itinerary
+		reason    string
+	}{
+		{"wrong origin", leg(shipping.AUMEL, shipping.CNHKG, deadline), "departs from AUMEL instead of origin SESTO"},
+		{"wrong destination", leg(shipping.SESTO, shipping.AUMEL, deadline), "arrives at AUMEL instead of destination CNHKG"},
+		{"arrives after deadline", leg(shipping.SESTO, shipping.CNHKG, deadline.Add(time.Hour)), "after arrival deadline"},
+	}
+
+	for _, tt := range tests {
+		err := s.AssignCargoToRoute(ctx, "ABC", tt.itinerary)
+		if !errors.Is(err, shipping.ErrItineraryDoesNotSatisfySpec) {
+			t.Errorf("%s: err = %v; want = %v", tt.name, err, shipping.ErrItineraryDoesNotSatisfySpec)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("%s: err = %q; want reason %q", tt.name, err, tt.reason)
+		}
+	}
+
+	if cargos.StoreInvoked {
+		t.Errorf("no cargo should be stored")
+	}
+	if !c.Itinerary.IsEmpty() {
+		t.Errorf("cargo should not have been routed")
+	}
+}
+
+func TestAssignCargoToRoute_Instructions(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var (
+		cargos = inmem.NewCargoRepository()
+		events = inmem.NewHandlingEventRepository()
+	)
+
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: deadline,
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewService(cargos, nil, events, nil)
+
+	itinerary := shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.NLRTM, Instructions: "Keep refrigerated"},
+		{VoyageNumber: "V300", LoadLocation: shipping.NLRTM, UnloadLocation: shipping.CNHKG},
+	}}
+
+	if err := s.AssignCargoToRoute(ctx, "ABC", itinerary); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cargos.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Itinerary, itinerary) {
+		t.Errorf("Itinerary = %v; want = %v", c.Itinerary, itinerary)
+	}
+}
+
+func TestAssignCargoToRoute_NoHandlingEvents(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := inmem.NewCargoRepository()
+
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewService(cargos, nil, nil, nil)
+
+	itinerary := shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+	}}
+
+	if err := s.AssignCargoToRoute(ctx, "ABC", itinerary); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cargos.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Delivery.RoutingStatus != shipping.Routed {
+		t.Errorf("RoutingStatus = %v; want = %v", c.Delivery.RoutingStatus, shipping.Routed)
+	}
+	if c.Delivery.TransportStatus != shipping.NotReceived {
+		t.Errorf("TransportStatus = %v; want = %v", c.Delivery.TransportStatus, shipping.NotReceived)
+	}
+}
+
+func TestNoHandlingEvents(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := inmem.NewCargoRepository()
+
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewService(cargos, inmem.NewLocationRepository(), nil, nil)
+
+	rs := shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: time.Date(2015, time.November, 20, 23, 0, 0, 0, time.UTC),
+	}
+	if err := s.UpdateRouteSpecification(ctx, "ABC", rs); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, errs := s.RecomputeAllDeliveries(ctx); n != 0 || len(errs) != 0 {
+		t.Errorf("RecomputeAllDeliveries() = %d, %v; want = %d, %v", n, errs, 0, nil)
+	}
+
+	c, err := cargos.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RouteSpecification != rs {
+		t.Errorf("RouteSpecification = %v; want = %v", c.RouteSpecification, rs)
+	}
+	if c.Delivery.TransportStatus != shipping.NotReceived {
+		t.Errorf("TransportStatus = %v; want = %v", c.Delivery.TransportStatus, shipping.NotReceived)
+	}
+}
+
+func TestAssignCargoToRoute_Partial(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var (
+		cargos = inmem.NewCargoRepository()
+		events = inmem.NewHandlingEventRepository()
+	)
+
+	s := NewService(cargos, nil, events, nil)
+
+	partial := func(to shipping.UNLocode, arrival time.Time) shipping.Itinerary {
+		return shipping.Itinerary{Partial: true, Legs: []shipping.Leg{
+			shipping.NewLeg("V100", shipping.SESTO, to, deadline.AddDate(0, 0, -5), arrival),
+		}}
+	}
+
+	var tests = []struct {
+		id        shipping.TrackingID
+		name      string
+		itinerary shipping.Itinerary
+		want      error
+		status    shipping.RoutingStatus
+	}{
+		{"ABC", "partial", partial(shipping.NLRTM, deadline.AddDate(0, 0, -3)), nil, shipping.PartiallyRouted},
+		{"DEF", "partial arrives after deadline", partial(shipping.NLRTM, deadline.Add(time.Hour)), shipping.ErrItineraryDoesNotSatisfySpec, shipping.NotRouted},
+		{"GHI", "partial to destination", partial(shipping.CNHKG, deadline), nil, shipping.Routed},
+	}
+
+	for _, tt := range tests {
+		if err := cargos.Store(ctx, shipping.NewCargo(tt.id, shipping.RouteSpecification{
+			Origin:          shipping.SESTO,
+			Destination:     shipping.CNHKG,
+			ArrivalDeadline: deadline,
+		})); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.AssignCargoToRoute(ctx, tt.id, tt.itinerary); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v; want = %v", tt.name, err, tt.want)
+		}
+
+		c, err := cargos.Find(ctx, tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Delivery.RoutingStatus != tt.status {
+			t.Errorf("%s: RoutingStatus = %v; want = %v", tt.name, c.Delivery.RoutingStatus, tt.status)
+		}
+	}
+}
+
+func TestAssignCargoToRoute_Capacity(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(number shipping.VoyageNumber) (*shipping.Voyage, error) {
+		return shipping.NewVoyage(number, shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+			{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.DEHAM, CapacityWeight: 2000, CapacityVolume: 40},
+			{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.CNHKG, CapacityWeight: 1000, CapacityVolume: 20},
+		}})
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
+	}
+
+	var (
+		scheduled = shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V500", shipping.SESTO, shipping.CNHKG, deadline.AddDate(0, 0, -5), deadline),
+		}}
+		unscheduled = shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V500", shipping.SESTO, shipping.DEHAM, deadline.AddDate(0, 0, -5), deadline.AddDate(0, 0, -3)),
+			shipping.NewLeg("V500", shipping.DEHAM, shipping.NLRTM, deadline.AddDate(0, 0, -2), deadline.AddDate(0, 0, -1)),
+			shipping.NewLeg("V500", shipping.NLRTM, shipping.CNHKG, deadline.AddDate(0, 0, -1), deadline),
+		}}
+	)
+
+	var tests = []struct {
+		name           string
+		weight, volume float64
+		itinerary      shipping.Itinerary
+		want           error
+	}{
+		{"fits", 1000, 20, scheduled, nil},
+		{"too heavy", 1500, 20, scheduled, shipping.ErrInsufficientCapacity},
+		{"too large", 1000, 30, scheduled, shipping.ErrInsufficientCapacity},
+		{"leg not scheduled", 1000, 20, unscheduled, shipping.ErrLegNotScheduled},
+	}
+
+	for _, tt := range tests {
+		var cargos mockCargoRepository
+
+		s := NewService(&cargos, nil, &events, nil, beforeDeadline, WithVoyageRepository(&voyages))
+
+		id, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.CNHKG, deadline, WithWeight(tt.weight), WithVolume(tt.volume))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.AssignCargoToRoute(ctx, id, tt.itinerary); err != tt.want {
+			t.Errorf("%s: err = %v; want = %v", tt.name, err, tt.want)
+		}
+
+		c, err := cargos.Find(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if routed := !c.Itinerary.IsEmpty(); routed != (tt.want == nil) {
+			t.Errorf("%s: routed = %v; want = %v", tt.name, routed, tt.want == nil)
+		}
+	}
+}
+
+func TestAssignCargoToRoute_CancelledVoyage(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(number shipping.VoyageNumber) (*shipping.Voyage, error) {
+		v, err := shipping.NewVoyage(number, shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+			{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.CNHKG},
+		}})
+		if err != nil {
+			return nil, err
+		}
+		v.Cancelled = number == "V500"
+		return v, nil
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
+	}
+
+	var cargos mockCargoRepository
+
+	s := NewService(&cargos, nil, &events, nil, beforeDeadline, WithVoyageRepository(&voyages))
+
+	id, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.CNHKG, deadline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	on := func(voyage shipping.VoyageNumber) shipping.Itinerary {
+		return shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg(voyage, shipping.SESTO, shipping.CNHKG, deadline.AddDate(0, 0, -5), deadline),
+		}}
+	}
+
+	if err := s.AssignCargoToRoute(ctx, id, on("V500")); err != shipping.ErrVoyageCancelled {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrVoyageCancelled)
+	}
+	if err := s.AssignCargoToRoute(ctx, id, on("V600")); err != nil {
+		t.Errorf("err = %v; want = %v", err, nil)
+	}
+}
+
+func TestRerouteMisdirectedCargo(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: deadline,
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+	}})
+
+	history := shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}},
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}},
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.AUMEL, VoyageNumber: "V100"}},
+	}}
+
+	c.DeriveDeliveryProgress(history)
+
+	if !c.Delivery.IsMisdirected {
+		t.Fatal("cargo should be misdirected")
+	}
+
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return history
+	}
+
+	var rs stubRoutingService
+
+	s := NewService(&cargos, nil, &events, &rs)
+
+	routes := s.RequestPossibleReroutesForCargo(ctx, c.TrackingID)
+	if len(routes) != 1 {
+		t.Fatalf("len(routes) = %d; want = %d", len(routes), 1)
+	}
+
+	if got := routes[0].InitialDepartureLocation(); got != shipping.AUMEL {
+		t.Errorf("InitialDepartureLocation() = %s; want = %s", got, shipping.AUMEL)
+	}
+
+	if err := s.AssignCargoToRoute(ctx, c.TrackingID, routes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	uc, err := cargos.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uc.RouteSpecification.Origin != shipping.AUMEL {
+		t.Errorf("uc.RouteSpecification.Origin = %s; want = %s", uc.RouteSpecification.Origin, shipping.AUMEL)
+	}
+	if uc.RouteSpecification.ArrivalDeadline != deadline {
+		t.Errorf("uc.RouteSpecification.ArrivalDeadline = %s; want = %s", uc.RouteSpecification.ArrivalDeadline, deadline)
+	}
+	if uc.Origin != shipping.SESTO {
+		t.Errorf("uc.Origin = %s; want = %s", uc.Origin, shipping.SESTO)
+	}
+	if uc.Delivery.RoutingStatus != shipping.Routed {
+		t.Errorf("uc.Delivery.RoutingStatus = %s; want = %s", uc.Delivery.RoutingStatus, shipping.Routed)
+	}
+	if uc.Delivery.LastEvent.Activity.Location != shipping.AUMEL {
+		t.Errorf("delivery should be derived from the handling history")
+	}
+	if uc.Delivery.IsMisdirected {
+		t.Errorf("uc.Delivery.IsMisdirected = %v; want = %v", uc.Delivery.IsMisdirected, false)
+	}
+}
+
+func TestRecomputeAllDeliveries(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos = inmem.NewCargoRepository()
+		events = inmem.NewHandlingEventRepository()
+	)
+
+	s := NewService(cargos, nil, events, nil)
+
+	rs := shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL}
+	for _, id := range []shipping.TrackingID{"ABC", "DEF"} {
+		if err := cargos.Store(ctx, shipping.NewCargo(id, rs)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The event is stored without the cargo being inspected.
+	if err := events.Store(ctx, shipping.HandlingEvent{
+		TrackingID: "ABC",
+		Activity:   shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, errs := s.RecomputeAllDeliveries(ctx)
+	if updated != 1 {
+		t.Errorf("updated = %d; want = %d", updated, 1)
+	}
+	if len(errs) != 0 {
+		t.Errorf("errs = %v; want none", errs)
+	}
+
+	c, err := cargos.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Delivery.TransportStatus != shipping.InPort {
+		t.Errorf("TransportStatus = %v; want = %v", c.Delivery.TransportStatus, shipping.InPort)
+	}
+
+	if updated, _ := s.RecomputeAllDeliveries(ctx); updated != 0 {
+		t.Errorf("updated = %d; want = %d", updated, 0)
+	}
+}
+
+func TestRequestRoutesFromOrigins(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		deadline = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		depart   = deadline.AddDate(0, 0, -10)
+	)
+
+	leg := func(voyage shipping.VoyageNumber, from, to shipping.UNLocode, days int) shipping.Leg {
+		return shipping.NewLeg(voyage, from, to, depart, depart.AddDate(0, 0, days))
+	}
+
+	var (
+		direct  = shipping.Itinerary{Legs: []shipping.Leg{leg("V100", shipping.SESTO, shipping.AUMEL, 5)}}
+		viaHKG  = shipping.Itinerary{Legs: []shipping.Leg{leg("V200", shipping.CNHKG, shipping.AUMEL, 3)}}
+		shared  = shipping.Itinerary{Legs: []shipping.Leg{leg("V300", shipping.DEHAM, shipping.AUMEL, 7)}}
+		tooLate = shipping.Itinerary{Legs: []shipping.Leg{leg("V400", shipping.CNHKG, shipping.AUMEL, 11)}}
+
+		transshipped = shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V500", shipping.SESTO, shipping.DEHAM, depart, depart.AddDate(0, 0, 1)),
+			shipping.NewLeg("V600", shipping.DEHAM, shipping.AUMEL, depart.AddDate(0, 0, 2), depart.AddDate(0, 0, 4)),
+		}}
+	)
+
+	var rs mock.RoutingService
+	rs.FetchRoutesFn = func(spec shipping.RouteSpecification) []shipping.Itinerary {
+		switch spec.Origin {
+		case shipping.SESTO:
+			return []shipping.Itinerary{shared, direct, transshipped}
+		case shipping.CNHKG:
+			// The same itinerary with its times in another zone.
+			shared := shipping.Itinerary{Legs: []shipping.Leg{shared.Legs[0]}}
+			shared.Legs[0].LoadTime = shared.Legs[0].LoadTime.In(time.FixedZone("HKT", 8*60*60))
+			return []shipping.Itinerary{tooLate, viaHKG, shared}
+		}
+		return nil
+	}
+
+	spec := shipping.RouteSpecification{
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: deadline,
+	}
+
+	var tests = []struct {
+		maxLegs int
+		want    []shipping.Itinerary
+	}{
+		{0, []shipping.Itinerary{viaHKG, transshipped, direct, shared}},
+		{1, []shipping.Itinerary{viaHKG, direct, shared}},
+	}
+	for _, tt := range tests {
+		s := NewService(nil, nil, nil, &rs, WithMaxLegs(tt.maxLegs))
+
+		got := s.RequestRoutesFromOrigins(ctx, []shipping.UNLocode{shipping.SESTO, shipping.CNHKG}, spec)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("maxLegs %d: RequestRoutesFromOrigins() = %v; want = %v", tt.maxLegs, got, tt.want)
+		}
+	}
+}
+
+func TestRequestPossibleReroutesForCargo_NotMisdirected(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	var rs stubRoutingService
+
+	s := NewService(&cargos, nil, nil, &rs, beforeDeadline)
+
+	id, err := s.BookNewCargo(ctx, shipping.SESTO, shipping.AUMEL, time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := s.RequestPossibleReroutesForCargo(ctx, id); len(r) != 0 {
+		t.Errorf("len(r) = %d; want = %d", len(r), 0)
+	}
+}
+
+func TestUpdateRouteSpecification(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var locations mock.LocationRepository
+	locations.FindFn = func(loc shipping.UNLocode) (*shipping.Location, error) {
+		return &shipping.Location{UNLocode: loc}, nil
+	}
+
+	handled := shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}},
+	}}
+
+	routed := shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.AUMEL},
+	}}
+
+	var tests = []struct {
+		name      string
+		itinerary shipping.Itinerary
+		history   shipping.HandlingHistory
+		locked    bool
+	}{
+		{"unrouted and unhandled", shipping.Itinerary{}, shipping.HandlingHistory{}, false},
+		{"routed", routed, shipping.HandlingHistory{}, true},
+		{"handled", shipping.Itinerary{}, handled, true},
+	}
+
+	for _, tt := range tests {
+		var cargos mockCargoRepository
+
+		c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+			Origin:          shipping.SESTO,
+			Destination:     shipping.AUMEL,
+			ArrivalDeadline: deadline,
+		})
+		c.AssignToRoute(tt.itinerary)
+		cargos.Store(ctx, c)
+
+		history := tt.history
+
+		var events mock.HandlingEventRepository
+		events.QueryHandlingHistoryFn = func(shipping.TrackingID) shipping.HandlingHistory {
+			return history
+		}
+
+		s := NewService(&cargos, &locations, &events, nil)
+
+		rs := shipping.RouteSpecification{
+			Origin:          shipping.NLRTM,
+			Destination:     shipping.CNHKG,
+			ArrivalDeadline: deadline.AddDate(0, 0, 7),
+		}
+
+		err := s.UpdateRouteSpecification(ctx, "ABC", rs)
+
+		if tt.locked {
+			if _, ok := err.(*RouteSpecificationLockedError); !ok {
+				t.Errorf("%s: err = %v; want = %T", tt.name, err, &RouteSpecificationLockedError{})
+			}
+			if c.RouteSpecification.Destination != shipping.AUMEL {
+				t.Errorf("%s: the route specification should not be updated", tt.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: err = %v", tt.name, err)
+		}
+
+		uc, err := cargos.Find(ctx, "ABC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uc.RouteSpecification != rs {
+			t.Errorf("%s: uc.RouteSpecification = %v; want = %v", tt.name, uc.RouteSpecification, rs)
+		}
+		if uc.Origin != shipping.NLRTM {
+			t.Errorf("%s: uc.Origin = %s; want = %s", tt.name, uc.Origin, shipping.NLRTM)
+		}
+		if uc.Delivery.RouteSpecification != rs {
+			t.Errorf("%s: the delivery should be recomputed", tt.name)
+		}
+	}
+}
+
+func TestChangeCargoDestination(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+	var locations mock.LocationRepository
+
+	locations.FindFn = func(loc shipping.UNLocode) (*shipping.Location, error) {
+		if loc != shipping.AUMEL {
+			return nil, shipping.ErrUnknownLocation
+		}
+		return shipping.Melbourne, nil
+	}
+
+	var rs stubRoutingService
+
+	s := NewService(&cargos, &locations, nil, &rs)
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+	})
+
+	if err := s.ChangeDestination(ctx, "no_such_id", shipping.SESTO); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %s; want = %s", err, shipping.ErrUnknownCargo)
+	}
+
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ChangeDestination(ctx, c.TrackingID, "no_such_unlocode"); err != shipping.ErrUnknownLocation {
+		t.Errorf("err = %s; want = %s", err, shipping.ErrUnknownLocation)
+	}
+
+	if c.RouteSpecification.Destination != shipping.CNHKG {
+		t.Errorf("c.RouteSpecification.Destination = %s; want = %s",
+			c.RouteSpecification.Destination, shipping.CNHKG)
+	}
+
+	if err := s.ChangeDestination(ctx, c.TrackingID, shipping.AUMEL); err != nil {
+		t.Fatal(err)
+	}
+
+	uc, err := cargos.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uc.RouteSpecification.Destination != shipping.AUMEL {
+		t.Errorf("uc.RouteSpecification.Destination = %s; want = %s",
+			uc.RouteSpecification.Destination, shipping.AUMEL)
+	}
+}
+
+func TestCancelCargo(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	s := NewService(&cargos, nil, nil, nil)
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+
+	if err := s.CancelCargo(ctx, "no_such_id"); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CancelCargo(ctx, c.TrackingID); err != nil {
+		t.Fatal(err)
+	}
+
+	uc, err := cargos.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !uc.Cancelled {
+		t.Errorf("cargo should be cancelled")
+	}
+
+	lc, err := s.LoadCargo(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lc.Cancelled {
+		t.Errorf("read model should be cancelled")
+	}
+}
+
+func TestHoldCargo(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	s := NewService(&cargos, nil, nil, nil)
+
+	if err := s.HoldCargo(ctx, "no_such_id", "payment dispute"); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.HoldCargo(ctx, c.TrackingID, "payment dispute"); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := cargos.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !held.OnHold || held.HoldReason != "payment dispute" {
+		t.Errorf("OnHold, HoldReason = %v, %q; want = %v, %q", held.OnHold, held.HoldReason, true, "payment dispute")
+	}
+
+	if err := s.ReleaseCargo(ctx, c.TrackingID); err != nil {
+		t.Fatal(err)
+	}
+
+	released, err := cargos.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.OnHold || released.HoldReason != "" {
+		t.Errorf("OnHold, HoldReason = %v, %q; want = %v, %q", released.OnHold, released.HoldReason, false, "")
+	}
+
+	released.Delivery.TransportStatus = shipping.Claimed
+	if err := s.HoldCargo(ctx, c.TrackingID, "inspection"); err != shipping.ErrCargoClaimed {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrCargoClaimed)
+	}
+}
+
+func TestTransferCargo(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var cargos mockCargoRepository
+
+	s := NewService(&cargos, nil, nil, nil, WithClock(shipping.ClockFunc(func() time.Time { return now })))
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	c.CustomerID = "ACME"
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.TransferCargo(ctx, c.TrackingID, ""); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+
+	if err := s.TransferCargo(ctx, c.TrackingID, "Globex"); err != nil {
+		t.Fatal(err)
+	}
+
+	transferred, err := cargos.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transferred.CustomerID != "Globex" {
+		t.Errorf("CustomerID = %q; want = %q", transferred.CustomerID, "Globex")
+	}
+
+	want := []shipping.OwnershipChange{{From: "ACME", To: "Globex", At: now}}
+	if !reflect.DeepEqual(transferred.OwnershipLog, want) {
+		t.Errorf("OwnershipLog = %v; want = %v", transferred.OwnershipLog, want)
+	}
+
+	transferred.Delivery.TransportStatus = shipping.Claimed
+	if err := s.TransferCargo(ctx, c.TrackingID, "Initech"); err != shipping.ErrCargoClaimed {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrCargoClaimed)
+	}
+	if transferred.CustomerID != "Globex" {
+		t.Errorf("CustomerID = %q; want = %q", transferred.CustomerID, "Globex")
+	}
+}
+
+func TestSetCargoTags(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := inmem.NewCargoRepository()
+
+	s := NewService(cargos, nil, nil, nil)
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	c.Weight = 100
+	c.SetTags(map[string]string{"customer": "acme"})
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetCargoTags(ctx, "no_such_id", map[string]string{"project": "apollo"}); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+
+	for _, tags := range []map[string]string{
+		{"project": "apollo"},
+		{"project": "gemini", "campaign": "spring"},
+		{"campaign": ""},
+		{"campaign": ""},
+	} {
+		if err := s.SetCargoTags(ctx, c.TrackingID, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := cargos.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"customer": "acme", "project": "gemini"}
+	if !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("Tags = %v; want = %v", got.Tags, want)
+	}
+	if got.Weight != 100 {
+		t.Errorf("Weight = %v; want = %v", got.Weight, 100)
+	}
+
+	if found := cargos.FindByTag(ctx, "project", "gemini"); len(found) != 1 || found[0].TrackingID != c.TrackingID {
+		t.Errorf("FindByTag(project, gemini) = %v; want = [%v]", found, c.TrackingID)
+	}
+	if found := cargos.FindByTag(ctx, "project", "apollo"); len(found) != 0 {
+		t.Errorf("len(FindByTag(project, apollo)) = %d; want = %d", len(found), 0)
+	}
+}
+
+func TestVoyageLoad(t *testing.T) {
+	ctx := context.Background()
+
+	newCargo := func(id shipping.TrackingID, weight, volume float64, voyages ...shipping.VoyageNumber) *shipping.Cargo {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:      shipping.SESTO,
+			Destination: shipping.CNHKG,
+		})
+		c.Weight = weight
+		c.Volume = volume
+
+		var legs []shipping.Leg
+		for _, v := range voyages {
+			legs = append(legs, shipping.Leg{VoyageNumber: v})
+		}
+		c.AssignToRoute(shipping.Itinerary{Legs: legs})
+
+		return c
+	}
+
+	cancelled := newCargo("CAN", 5000, 50, "V100")
+	cancelled.Cancelled = true
+
+	var cargos mock.CargoRepository
+	cargos.FindAllFn = func() []*shipping.Cargo {
+		return []*shipping.Cargo{
+			newCargo("ABC", 1000, 10, "V100", "V400"),
+			newCargo("DEF", 500, 2.5, "V300", "V100"),
+			newCargo("GHI", 700, 7, "V300"),
+			cancelled,
+		}
+	}
+
+	s := NewService(&cargos, nil, nil, nil)
+
+	weight, volume, err := s.VoyageLoad(ctx, "V100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weight != 1500 {
+		t.Errorf("weight = %v; want = %v", weight, 1500)
+	}
+	if volume != 12.5 {
+		t.Errorf("volume = %v; want = %v", volume, 12.5)
+	}
+
+	if _, _, err := s.VoyageLoad(ctx, ""); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+}
+
+func TestCancelClaimedCargo(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	s := NewService(&cargos, nil, nil, nil)
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL}},
+	}})
+
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CancelCargo(ctx, c.TrackingID); err != shipping.ErrCargoClaimed {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrCargoClaimed)
+	}
+
+	if c.Cancelled {
+		t.Errorf("claimed cargo should not be cancelled")
+	}
+}
+
+func TestReopenCargo(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos = inmem.NewCargoRepository()
+		events = inmem.NewHandlingEventRepository()
+	)
+
+	s := NewService(cargos, nil, events, nil)
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+	})
+
+	claim := shipping.HandlingEvent{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL}, CompletionTime: time.Date(2015, time.November, 9, 12, 0, 0, 0, time.UTC)}
+	if err := events.Store(ctx, claim); err != nil {
+		t.Fatal(err)
+	}
+	c.DeriveDeliveryProgress(events.QueryHandlingHistory(ctx, "ABC"))
+
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := shipping.RouteSpecification{
+		Origin:          shipping.AUMEL,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: time.Date(2015, time.December, 10, 23, 0, 0, 0, time.UTC),
+	}
+
+	if err := s.ReopenCargo(ctx, "ABC", rs); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cargos.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.RouteSpecification != rs {
+		t.Errorf("RouteSpecification = %v; want = %v", got.RouteSpecification, rs)
+	}
+	if got.Delivery.RoutingStatus != shipping.NotRouted {
+		t.Errorf("RoutingStatus = %v; want = %v", got.Delivery.RoutingStatus, shipping.NotRouted)
+	}
+	if got.Delivery.TransportStatus == shipping.Claimed {
+		t.Errorf("TransportStatus = %v; want not claimed", got.Delivery.TransportStatus)
+	}
+	if !got.Itinerary.IsEmpty() {
+		t.Errorf("Itinerary = %v; want empty", got.Itinerary)
+	}
+	if n := len(events.QueryHandlingHistory(ctx, "ABC").HandlingEvents); n != 1 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", n, 1)
+	}
+	if got.Origin != rs.Origin {
+		t.Errorf("Origin = %v; want = %v", got.Origin, rs.Origin)
+	}
+
+	// Routing the reopened cargo derives its delivery from the stored
+	// history again, where the old claim is no longer counted.
+	itinerary := shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.AUMEL, UnloadLocation: shipping.CNHKG},
+	}}
+	if err := s.AssignCargoToRoute(ctx, "ABC", itinerary); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = cargos.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Origin != rs.Origin {
+		t.Errorf("after routing: Origin = %v; want = %v", got.Origin, rs.Origin)
+	}
+	if got.Delivery.TransportStatus != shipping.NotReceived {
+		t.Errorf("after routing: TransportStatus = %v; want = %v", got.Delivery.TransportStatus, shipping.NotReceived)
+	}
+	if got.Delivery.RoutingStatus != shipping.Routed {
+		t.Errorf("after routing: RoutingStatus = %v; want = %v", got.Delivery.RoutingStatus, shipping.Routed)
+	}
+}
+
+func TestReopenUnclaimedCargo(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := inmem.NewCargoRepository()
+
+	s := NewService(cargos, nil, nil, nil)
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := shipping.RouteSpecification{
+		Origin:          shipping.AUMEL,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: time.Date(2015, time.December, 10, 23, 0, 0, 0, time.UTC),
+	}
+
+	if err := s.ReopenCargo(ctx, "ABC", rs); err != shipping.ErrCargoNotClaimed {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrCargoNotClaimed)
+	}
+}
+
+func TestLoadCargo(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return &shipping.Cargo{
+			TrackingID: "test_id",
+			Origin:     shipping.SESTO,
+			RouteSpecification: shipping.RouteSpecification{
+				Origin:          shipping.SESTO,
+				Destination:     shipping.AUMEL,
+				ArrivalDeadline: deadline,
+			},
+		}, nil
+	}
+
+	s := NewService(&cargos, nil, nil, nil)
+
+	c, err := s.LoadCargo(ctx, "test_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.TrackingID != "test_id" {
+		t.Errorf("c.TrackingID = %s; want = %s", c.TrackingID, "test_id")
+	}
+	if c.Origin != "SESTO" {
+		t.Errorf("c.Origin = %s; want = %s", c.Origin, "SESTO")
+	}
+	if c.Destination != "AUMEL" {
+		t.Errorf("c.Destination = %s; want = %s", c.Origin, "AUMEL")
+	}
+	if c.ArrivalDeadline != deadline {
+		t.Errorf("c.ArrivalDeadline = %s; want = %s", c.ArrivalDeadline, deadline)
+	}
+	if c.Misrouted {
+		t.Errorf("cargo should not be misrouted")
+	}
+	if c.Routed {
+		t.Errorf("cargo should not have been routed")
+	}
+	if len(c.Legs) != 0 {
+		t.Errorf("len(c.Legs) = %d; want = %d", len(c.Legs), 0)
+	}
+}
+
+func TestConsolidateCargos(t *testing.T) {
+	ctx := context.Background()
+
+	rs := shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	}
+
+	cargos := inmem.NewCargoRepository()
+	for _, id := range []shipping.TrackingID{"PARENT", "CHILD1", "CHILD2", "OTHER"} {
+		if err := cargos.Store(ctx, shipping.NewCargo(id, rs)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewService(cargos, nil, nil, nil)
+
+	if err := s.ConsolidateCargos(ctx, "PARENT", []shipping.TrackingID{"CHILD1", "CHILD2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	children := cargos.FindChildren(ctx, "PARENT")
+	if len(children) != 2 {
+		t.Fatalf("len(children) = %d; want = %d", len(children), 2)
+	}
+	for _, c := range children {
+		if c.ParentID != "PARENT" {
+			t.Errorf("c.ParentID = %s; want = %s", c.ParentID, "PARENT")
+		}
+	}
+
+	if err := s.ConsolidateCargos(ctx, "PARENT", nil); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+	if err := s.ConsolidateCargos(ctx, "CHILD1", []shipping.TrackingID{"CHILD2"}); err != ErrAlreadyConsolidated {
+		t.Errorf("err = %v; want = %v", err, ErrAlreadyConsolidated)
+	}
+
+	// A child can not become a parent, nor a parent a child.
+	if err := s.ConsolidateCargos(ctx, "CHILD1", []shipping.TrackingID{"OTHER"}); err != ErrAlreadyConsolidated {
+		t.Errorf("err = %v; want = %v", err, ErrAlreadyConsolidated)
+	}
+	if err := s.ConsolidateCargos(ctx, "OTHER", []shipping.TrackingID{"PARENT"}); err != ErrAlreadyConsolidated {
+		t.Errorf("err = %v; want = %v", err, ErrAlreadyConsolidated)
+	}
+	if c, err := cargos.Find(ctx, "OTHER"); err != nil || c.ParentID != "" {
+		t.Errorf("Find(OTHER) = %v, %v; want unconsolidated", c, err)
+	}
+}
+
+func TestConsolidateCargos_RouteSpecificationMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := inmem.NewCargoRepository()
+
+	parent := shipping.NewCargo("PARENT", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	child := shipping.NewCargo("CHILD", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.CNHKG,
+	})
+	for _, c := range []*shipping.Cargo{parent, child} {
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewService(cargos, nil, nil, nil)
+
+	if err := s.ConsolidateCargos(ctx, "PARENT", []shipping.TrackingID{"CHILD"}); err != ErrRouteSpecificationMismatch {
+		t.Errorf("err = %v; want = %v", err, ErrRouteSpecificationMismatch)
+	}
+	if children := cargos.FindChildren(ctx, "PARENT"); len(children) != 0 {
+		t.Errorf("len(children) = %d; want = %d", len(children), 0)
+	}
+}
+
+func TestConsolidateCargos_AlreadyRouted(t *testing.T) {
+	ctx := context.Background()
+
+	rs := shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	}
+
+	cargos := inmem.NewCargoRepository()
+
+	routed := shipping.NewCargo("ROUTED", rs)
+	routed.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.AUMEL},
+	}})
+	for _, c := range []*shipping.Cargo{shipping.NewCargo("PARENT", rs), shipping.NewCargo("CHILD", rs), routed} {
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewService(cargos, nil, nil, nil)
+
+	if err := s.ConsolidateCargos(ctx, "PARENT", []shipping.TrackingID{"CHILD", "ROUTED"}); err != ErrAlreadyRouted {
+		t.Errorf("err = %v; want = %v", err, ErrAlreadyRouted)
+	}
+	if children := cargos.FindChildren(ctx, "PARENT"); len(children) != 0 {
+		t.Errorf("len(children) = %d; want = %d", len(children), 0)
+	}
+}
+
+type mockCargoRepository struct {
+	cargo *shipping.Cargo
+}
+
+func (r *mockCargoRepository) Store(ctx context.Context, c *shipping.Cargo) error {
+	r.cargo = c
+	return nil
+}
+
+func (r *mockCargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
+	if r.cargo != nil && r.cargo.TrackingID == id {
+		return r.cargo, nil
+	}
+	return nil, shipping.ErrUnknownCargo
+}
+
+func (r *mockCargoRepository) FindAll(ctx context.Context) []*shipping.Cargo {
+	return []*shipping.Cargo{r.cargo}
+}
+
+func (r *mockCargoRepository) FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*shipping.Cargo {
+	if r.cargo != nil && !r.cargo.Cancelled && r.cargo.IsAwaitingClaimBeyond(d, now) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindOverdue(ctx context.Context, now time.Time) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.IsOverdue(now) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAllPaged(ctx context.Context, offset, limit int) ([]*shipping.Cargo, int, error) {
+	return []*shipping.Cargo{r.cargo}, 1, nil
+}
+
+func (r *mockCargoRepository) FindByRoutingStatus(ctx context.Context, status shipping.RoutingStatus) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.Delivery.RoutingStatus == status {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByExternalRef(ctx context.Context, ref string) (*shipping.Cargo, error) {
+	if r.cargo != nil && ref != "" && r.cargo.ExternalRef == ref {
+		return r.cargo, nil
+	}
+	return nil, shipping.ErrUnknownCargo
+}
+
+func (r *mockCargoRepository) Watch(ctx context.Context) (<-chan shipping.CargoChange, error) {
+	return nil, shipping.ErrWatchNotSupported
+}
+
+func (r *mockCargoRepository) FindByServiceLevel(ctx context.Context, level shipping.ServiceLevel) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.ServiceLevel == level {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByTag(ctx context.Context, key, value string) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.Tags[key] == value && value != "" {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByVoyage(ctx context.Context, number shipping.VoyageNumber) []*shipping.Cargo {
+	if r.cargo != nil && !r.cargo.Cancelled && r.cargo.Itinerary.IsOnVoyage(number) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAtLocation(ctx context.Context, loc shipping.UNLocode) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.IsAt(loc) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAllIncludingArchived(ctx context.Context) []*shipping.Cargo {
+	return []*shipping.Cargo{r.cargo}
+}
+
+func (r *mockCargoRepository) FindChildren(ctx context.Context, parent shipping.TrackingID) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.ParentID == parent {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByDestination(ctx context.Context, dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.RouteSpecification.Destination == dest && (!activeOnly || r.cargo.IsActive()) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) Archive(ctx context.Context, id shipping.TrackingID) error {
+	if r.cargo == nil || r.cargo.TrackingID != id {
+		return shipping.ErrUnknownCargo
+	}
+	return r.cargo.Archive()
+}
+
+func TestEnsureCargo(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := inmem.NewCargoRepository()
+
+	s := NewService(cargos, nil, nil, nil, beforeDeadline)
+
+	rs := shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+	}
+
+	id, created, err := s.EnsureCargo(ctx, "PO-1", rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Errorf("created = %v; want = %v", created, true)
+	}
+
+	again, created, err := s.EnsureCargo(ctx, "PO-1", rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Errorf("created = %v; want = %v", created, false)
+	}
+	if again != id {
+		t.Errorf("id = %s; want = %s", again, id)
+	}
+
+	c, err := cargos.FindByExternalRef(ctx, "PO-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.TrackingID != id {
+		t.Errorf("TrackingID = %s; want = %s", c.TrackingID, id)
+	}
+
+	if _, _, err := s.EnsureCargo(ctx, "", rs); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+}
+
+func TestEnsureCargo_Concurrent(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := inmem.NewCargoRepository()
+
+	s := NewService(cargos, nil, nil, nil, beforeDeadline)
+
+	rs := shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+	}
+
+	const n = 10
+
+	var (
+		wg      sync.WaitGroup
+		ids     = make([]shipping.TrackingID, n)
+		created = make([]bool, n)
+		errs    = make([]error, n)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], created[i], errs[i] = s.EnsureCargo(ctx, "PO-1", rs)
+		}(i)
+	}
+	wg.Wait()
+
+	var booked int
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Errorf("ids[%d] = %s; want = %s", i, ids[i], ids[0])
+		}
+		if created[i] {
+			booked++
+		}
+	}
+	if booked != 1 {
+		t.Errorf("booked = %d; want = %d", booked, 1)
+	}
+	if got := len(cargos.FindAll(ctx)); got != 1 {
+		t.Errorf("len(FindAll()) = %d; want = %d", got, 1)
+	}
+}
+
+func TestNextSailingWithCapacity(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	voyage := func(number shipping.VoyageNumber, departure time.Time) *shipping.Voyage {
+		return &shipping.Voyage{VoyageNumber: number, Schedule: shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+			{
+				DepartureLocation: shipping.SESTO,
+				ArrivalLocation:   shipping.CNHKG,
+				DepartureTime:     departure,
+				ArrivalTime:       departure.AddDate(0, 0, 10),
+				CapacityWeight:    2000,
+			},
+		}}}
+	}
+
+	var (
+		early = voyage("V100", now.AddDate(0, 0, 2))
+		later = voyage("V200", now.AddDate(0, 0, 9))
+	)
+
+	var voyages mock.VoyageRepository
+	voyages.FindDepartingBetweenFn = func(from shipping.UNLocode, start, end time.Time) []*shipping.Voyage {
+		var vs []*shipping.Voyage
+		for _, v := range []*shipping.Voyage{early, later} {
+			if d := v.Schedule.CarrierMovements[0].DepartureTime; !d.Before(start) && d.Before(end) {
+				vs = append(vs, v)
+			}
+		}
+		return vs
+	}
+
+	booked := shipping.NewCargo("ABC", shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG})
+	booked.Weight = 1800
+	booked.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+	}})
+
+	var cargos mock.CargoRepository
+	cargos.FindAllFn = func() []*shipping.Cargo {
+		return []*shipping.Cargo{booked}
+	}
+
+	s := NewService(&cargos, nil, nil, nil,
+		WithVoyageRepository(&voyages),
+		WithClock(shipping.ClockFunc(func() time.Time { return now })),
+	)
+
+	rs := shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG}
+
+	got, err := s.NextSailingWithCapacity(ctx, rs, 500, 0, 14*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Sailing{
+		VoyageNumber:  "V200",
+		DepartureTime: later.Schedule.CarrierMovements[0].DepartureTime,
+		ArrivalTime:   later.Schedule.CarrierMovements[0].ArrivalTime,
+	}
+	if got != want {
+		t.Errorf("NextSailingWithCapacity() = %v; want = %v", got, want)
+	}
+
+	if got, err := s.NextSailingWithCapacity(ctx, rs, 100, 0, 14*24*time.Hour); err != nil || got.VoyageNumber != "V100" {
+		t.Errorf("NextSailingWithCapacity() = %v, %v; want = %v, %v", got.VoyageNumber, err, "V100", nil)
+	}
+
+	if _, err := s.NextSailingWithCapacity(ctx, rs, 500, 0, 7*24*time.Hour); err != ErrNoCapacity {
+		t.Errorf("err = %v; want = %v", err, ErrNoCapacity)
+	}
 }
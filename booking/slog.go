@@ -0,0 +1,230 @@
+//go:build go1.21
+
+package booking
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type slogService struct {
+	next Service
+}
+
+// NewSlogService returns a Service that logs every call to the logger of the
+// context, as by shipping.LogCall.
+func NewSlogService(s Service) Service {
+	return &slogService{s}
+}
+
+func trackingID(id shipping.TrackingID) slog.Attr {
+	return slog.String("tracking_id", string(id))
+}
+
+func (s *slogService) BookNewCargo(ctx context.Context, origin shipping.UNLocode, destination shipping.UNLocode, deadline time.Time, opts ...BookingOption) (id shipping.TrackingID, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "book", begin, err,
+			trackingID(id),
+			slog.String("origin", string(origin)),
+			slog.String("destination", string(destination)),
+			slog.Time("arrival_deadline", deadline),
+		)
+	}(time.Now())
+	return s.next.BookNewCargo(ctx, origin, destination, deadline, opts...)
+}
+
+func (s *slogService) EnsureCargo(ctx context.Context, externalRef string, rs shipping.RouteSpecification) (id shipping.TrackingID, created bool, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "ensure", begin, err,
+			slog.String("external_ref", externalRef),
+			trackingID(id),
+			slog.Bool("created", created),
+		)
+	}(time.Now())
+	return s.next.EnsureCargo(ctx, externalRef, rs)
+}
+
+func (s *slogService) LoadCargo(ctx context.Context, id shipping.TrackingID) (c Cargo, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "load", begin, err, trackingID(id))
+	}(time.Now())
+	return s.next.LoadCargo(ctx, id)
+}
+
+func (s *slogService) RequestPossibleRoutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "request_routes", begin, nil, trackingID(id))
+	}(time.Now())
+	return s.next.RequestPossibleRoutesForCargo(ctx, id)
+}
+
+func (s *slogService) RequestPossibleRoutesForCargoBySpecification(ctx context.Context, id shipping.TrackingID) []Route {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "request_routes_by_specification", begin, nil, trackingID(id))
+	}(time.Now())
+	return s.next.RequestPossibleRoutesForCargoBySpecification(ctx, id)
+}
+
+func (s *slogService) RequestPossibleReroutesForCargo(ctx context.Context, id shipping.TrackingID) []shipping.Itinerary {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "request_reroutes", begin, nil, trackingID(id))
+	}(time.Now())
+	return s.next.RequestPossibleReroutesForCargo(ctx, id)
+}
+
+func (s *slogService) RequestRoutesFromOrigins(ctx context.Context, origins []shipping.UNLocode, rs shipping.RouteSpecification) []shipping.Itinerary {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "request_routes_from_origins", begin, nil,
+			slog.Int("origins", len(origins)),
+			slog.String("destination", string(rs.Destination)),
+		)
+	}(time.Now())
+	return s.next.RequestRoutesFromOrigins(ctx, origins, rs)
+}
+
+func (s *slogService) AssignCargoToRoute(ctx context.Context, id shipping.TrackingID, itinerary shipping.Itinerary) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "assign_to_route", begin, err,
+			trackingID(id),
+			slog.String("origin", string(itinerary.InitialDepartureLocation())),
+			slog.String("destination", string(itinerary.FinalArrivalLocation())),
+		)
+	}(time.Now())
+	return s.next.AssignCargoToRoute(ctx, id, itinerary)
+}
+
+func (s *slogService) AssignRoutes(ctx context.Context, assignments map[shipping.TrackingID]shipping.Itinerary) (errs map[shipping.TrackingID]error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "assign_routes", begin, nil,
+			slog.Int("assignments", len(assignments)),
+			slog.Int("failed", len(errs)),
+		)
+	}(time.Now())
+	return s.next.AssignRoutes(ctx, assignments)
+}
+
+func (s *slogService) ChangeDestination(ctx context.Context, id shipping.TrackingID, l shipping.UNLocode) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "change_destination", begin, err, trackingID(id), slog.String("destination", string(l)))
+	}(time.Now())
+	return s.next.ChangeDestination(ctx, id, l)
+}
+
+func (s *slogService) UpdateRouteSpecification(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "update_route_specification", begin, err,
+			trackingID(id),
+			slog.String("origin", string(rs.Origin)),
+			slog.String("destination", string(rs.Destination)),
+			slog.Time("arrival_deadline", rs.ArrivalDeadline),
+		)
+	}(time.Now())
+	return s.next.UpdateRouteSpecification(ctx, id, rs)
+}
+
+func (s *slogService) CancelCargo(ctx context.Context, id shipping.TrackingID) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "cancel", begin, err, trackingID(id))
+	}(time.Now())
+	return s.next.CancelCargo(ctx, id)
+}
+
+func (s *slogService) HoldCargo(ctx context.Context, id shipping.TrackingID, reason string) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "hold", begin, err, trackingID(id), slog.String("reason", reason))
+	}(time.Now())
+	return s.next.HoldCargo(ctx, id, reason)
+}
+
+func (s *slogService) ReleaseCargo(ctx context.Context, id shipping.TrackingID) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "release", begin, err, trackingID(id))
+	}(time.Now())
+	return s.next.ReleaseCargo(ctx, id)
+}
+
+func (s *slogService) SetCargoTags(ctx context.Context, id shipping.TrackingID, tags map[string]string) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "set_tags", begin, err, trackingID(id), slog.Int("tags", len(tags)))
+	}(time.Now())
+	return s.next.SetCargoTags(ctx, id, tags)
+}
+
+func (s *slogService) TransferCargo(ctx context.Context, id shipping.TrackingID, newCustomer string) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "transfer", begin, err, trackingID(id), slog.String("customer_id", newCustomer))
+	}(time.Now())
+	return s.next.TransferCargo(ctx, id, newCustomer)
+}
+
+func (s *slogService) ReopenCargo(ctx context.Context, id shipping.TrackingID, rs shipping.RouteSpecification) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "reopen", begin, err,
+			trackingID(id),
+			slog.String("origin", string(rs.Origin)),
+			slog.String("destination", string(rs.Destination)),
+		)
+	}(time.Now())
+	return s.next.ReopenCargo(ctx, id, rs)
+}
+
+func (s *slogService) Cargos(ctx context.Context) []Cargo {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "list_cargos", begin, nil)
+	}(time.Now())
+	return s.next.Cargos(ctx)
+}
+
+func (s *slogService) Locations(ctx context.Context) []Location {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "list_locations", begin, nil)
+	}(time.Now())
+	return s.next.Locations(ctx)
+}
+
+func (s *slogService) ConsolidateCargos(ctx context.Context, parent shipping.TrackingID, children []shipping.TrackingID) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "consolidate", begin, err,
+			slog.String("parent", string(parent)),
+			slog.Int("children", len(children)),
+		)
+	}(time.Now())
+	return s.next.ConsolidateCargos(ctx, parent, children)
+}
+
+func (s *slogService) NextSailingWithCapacity(ctx context.Context, rs shipping.RouteSpecification, weight, volume float64, lookahead time.Duration) (sailing Sailing, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "next_sailing_with_capacity", begin, err,
+			slog.String("origin", string(rs.Origin)),
+			slog.String("destination", string(rs.Destination)),
+			slog.Float64("weight", weight),
+			slog.Float64("volume", volume),
+			slog.String("voyage_number", sailing.VoyageNumber),
+		)
+	}(time.Now())
+	return s.next.NextSailingWithCapacity(ctx, rs, weight, volume, lookahead)
+}
+
+func (s *slogService) VoyageLoad(ctx context.Context, number shipping.VoyageNumber) (weight, volume float64, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "voyage_load", begin, err,
+			slog.String("voyage_number", string(number)),
+			slog.Float64("weight", weight),
+			slog.Float64("volume", volume),
+		)
+	}(time.Now())
+	return s.next.VoyageLoad(ctx, number)
+}
+
+func (s *slogService) RecomputeAllDeliveries(ctx context.Context) (updated int, errs []error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "recompute_deliveries", begin, nil,
+			slog.Int("updated", updated),
+			slog.Int("failed", len(errs)),
+		)
+	}(time.Now())
+	return s.next.RecomputeAllDeliveries(ctx)
+}
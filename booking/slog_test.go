@@ -0,0 +1,52 @@
+//go:build go1.21
+
+package booking
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestSlogService_BookNewCargoError(t *testing.T) {
+	var (
+		cargos mockCargoRepository
+		buf    bytes.Buffer
+	)
+
+	ctx := shipping.NewLoggerContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	s := NewSlogService(NewService(&cargos, nil, nil, nil))
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	if _, err := s.BookNewCargo(ctx, "", shipping.AUMEL, deadline); err != ErrInvalidArgument {
+		t.Fatalf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+
+	if line["level"] != "WARN" {
+		t.Errorf("level = %v; want = %v", line["level"], "WARN")
+	}
+	if line["method"] != "book" {
+		t.Errorf("method = %v; want = %v", line["method"], "book")
+	}
+	if line["destination"] != string(shipping.AUMEL) {
+		t.Errorf("destination = %v; want = %v", line["destination"], shipping.AUMEL)
+	}
+	if line["err"] != ErrInvalidArgument.Error() {
+		t.Errorf("err = %v; want = %v", line["err"], ErrInvalidArgument)
+	}
+	if _, ok := line["took"]; !ok {
+		t.Errorf("took should be logged")
+	}
+}
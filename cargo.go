@@ -2,7 +2,11 @@
 package shipping
 
 import (
-	"errors"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,24 +23,425 @@ type Cargo struct {
 	RouteSpecification RouteSpecification
 	Itinerary          Itinerary
 	Delivery           Delivery
+	Cancelled          bool
+
+	// Archived cargos have been claimed and are kept for the record only.
+	// They are left out of listings.
+	Archived bool
+
+	// ReopenedAfter is the completion time of the claim that the cargo was
+	// last reopened after, if any. Its delivery is derived from the events
+	// completed after it only.
+	ReopenedAfter time.Time
+
+	// ParentID is the tracking ID of the cargo that this cargo has been
+	// consolidated into, if any.
+	ParentID TrackingID
+
+	// ExternalRef optionally identifies the cargo in the system of the
+	// customer that booked it. No two cargos have the same reference.
+	ExternalRef string
+
+	// OnHold cargos have been held administratively, for example over a
+	// payment dispute, and may not be loaded until they are released.
+	// HoldReason tells why.
+	OnHold     bool
+	HoldReason string
+
+	// Tags are free-form labels that the cargo is grouped by, such as a
+	// project code or a campaign.
+	Tags map[string]string
+
+	// CustomerID identifies the customer responsible for the cargo.
+	// OwnershipLog records every transfer of the cargo from one customer to
+	// another, oldest first.
+	CustomerID   string
+	OwnershipLog []OwnershipChange
+
+	// Version is the number of times the cargo has been stored. A cargo
+	// must be stored with the version it was read with, so that concurrent
+	// changes are not overwritten.
+	Version int
+
+	// Weight in kilograms and Volume in cubic meters of the cargo. Zero
+	// means that the measure is unknown.
+	Weight float64
+	Volume float64
+
+	// ServiceLevel is the level of service that the customer has booked.
+	// It defaults to Standard.
+	ServiceLevel ServiceLevel
+
+	// AlternateRouteSpecifications are route specifications that the
+	// customer accepts instead of the primary one, for example to a nearby
+	// destination.
+	AlternateRouteSpecifications []RouteSpecification
+
+	// StatusChanges is the append-only log of status changes of the
+	// delivery, as derived from the handling history.
+	StatusChanges []StatusChange
+}
+
+// OwnershipChange records the transfer of a cargo from one customer to
+// another.
+type OwnershipChange struct {
+	From, To string
+	At       time.Time
 }
 
-// SpecifyNewRoute specifies a new route for this cargo.
-func (c *Cargo) SpecifyNewRoute(rs RouteSpecification) {
+// StatusChange records a change to the transport or routing status of the
+// delivery of a cargo.
+type StatusChange struct {
+	FromTransportStatus TransportStatus
+	ToTransportStatus   TransportStatus
+	FromRoutingStatus   RoutingStatus
+	ToRoutingStatus     RoutingStatus
+
+	// CompletionTime is the completion time of the handling event that
+	// triggered the change.
+	CompletionTime time.Time
+}
+
+func (c StatusChange) equal(other StatusChange) bool {
+	return c.FromTransportStatus == other.FromTransportStatus &&
+		c.ToTransportStatus == other.ToTransportStatus &&
+		c.FromRoutingStatus == other.FromRoutingStatus &&
+		c.ToRoutingStatus == other.ToRoutingStatus &&
+		c.CompletionTime.Equal(other.CompletionTime)
+}
+
+// RouteSpecifications returns the primary route specification followed by
+// the alternates.
+func (c *Cargo) RouteSpecifications() []RouteSpecification {
+	return append([]RouteSpecification{c.RouteSpecification}, c.AlternateRouteSpecifications...)
+}
+
+// SpecifyNewRoute specifies a new route for this cargo, and returns the
+// resulting changes of its delivery.
+func (c *Cargo) SpecifyNewRoute(rs RouteSpecification) []DeliveryChange {
 	c.RouteSpecification = rs
-	c.Delivery = c.Delivery.UpdateOnRouting(c.RouteSpecification, c.Itinerary)
+	return c.updateDelivery(c.Delivery.UpdateOnRouting(c.RouteSpecification, c.Itinerary))
 }
 
-// AssignToRoute attaches a new itinerary to this cargo.
-func (c *Cargo) AssignToRoute(itinerary Itinerary) {
+// AssignToRoute attaches a new itinerary to this cargo, and returns the
+// resulting changes of its delivery.
+func (c *Cargo) AssignToRoute(itinerary Itinerary) []DeliveryChange {
 	c.Itinerary = itinerary
-	c.Delivery = c.Delivery.UpdateOnRouting(c.RouteSpecification, c.Itinerary)
+	return c.updateDelivery(c.Delivery.UpdateOnRouting(c.RouteSpecification, c.Itinerary))
 }
 
 // DeriveDeliveryProgress updates all aspects of the cargo aggregate status
-// based on the current route specification, itinerary and handling of the cargo.
-func (c *Cargo) DeriveDeliveryProgress(history HandlingHistory) {
-	c.Delivery = DeriveDeliveryFrom(c.RouteSpecification, c.Itinerary, history)
+// based on the current route specification, itinerary and handling of the
+// cargo, and returns the resulting changes of its delivery. The delivery is
+// derived from the last event by completion time, so that an earlier event
+// registered late does not set the cargo back.
+func (c *Cargo) DeriveDeliveryProgress(history HandlingHistory) []DeliveryChange {
+	return c.deriveDelivery(history)
+}
+
+// Replay rebuilds the delivery of the cargo from scratch by applying its
+// handling events in order of completion, regardless of the order in which
+// they were stored. The returned changes are relative to the delivery before
+// the replay.
+func (c *Cargo) Replay(history HandlingHistory) []DeliveryChange {
+	return c.deriveDelivery(history.SortedByCompletionTime())
+}
+
+// DeliveryAt returns the delivery of the cargo as it was at the given time,
+// derived from the events in the handling history completed on or before
+// then. The cargo is left untouched. The current route specification and
+// itinerary are used, as changes to them are not recorded.
+func (c *Cargo) DeliveryAt(history HandlingHistory, t time.Time) Delivery {
+	return DeriveDeliveryFrom(c.RouteSpecification, c.Itinerary, c.sinceReopened(history).CompletedBy(t).SortedByCompletionTime())
+}
+
+// History returns the status changes of the delivery of the cargo, oldest
+// first.
+func (c *Cargo) History() []StatusChange {
+	return append([]StatusChange(nil), c.StatusChanges...)
+}
+
+// Fingerprint returns a hash of the route specifications, itinerary, delivery
+// status, last known location and version of the cargo, for detecting
+// whether it has changed downstream. The itinerary is normalized and the
+// alternate route specifications sorted before hashing, and times are
+// compared as instants, so cargos that only differ in representation have
+// the same fingerprint.
+func (c *Cargo) Fingerprint() string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "spec %s;", fingerprintSpec(c.RouteSpecification))
+
+	alternates := make([]string, len(c.AlternateRouteSpecifications))
+	for i, rs := range c.AlternateRouteSpecifications {
+		alternates[i] = fingerprintSpec(rs)
+	}
+	sort.Strings(alternates)
+	for _, rs := range alternates {
+		fmt.Fprintf(h, "alternate %s;", rs)
+	}
+
+	for _, l := range c.Itinerary.Normalized().Legs {
+		fmt.Fprintf(h, "leg %s %s %s %s %s;", l.VoyageNumber,
+			l.LoadLocation, l.UnloadLocation,
+			l.LoadTime.UTC().Format(time.RFC3339Nano), l.UnloadTime.UTC().Format(time.RFC3339Nano))
+		if l.Instructions != "" {
+			fmt.Fprintf(h, "instructions %q;", l.Instructions)
+		}
+	}
+
+	if c.Itinerary.Partial {
+		fmt.Fprint(h, "partial;")
+	}
+
+	if c.ServiceLevel != Standard {
+		fmt.Fprintf(h, "level %s;", c.ServiceLevel)
+	}
+
+	fmt.Fprintf(h, "delivery %s %s %s;", c.Delivery.RoutingStatus, c.Delivery.TransportStatus, c.Delivery.LastKnownLocation)
+	fmt.Fprintf(h, "version %d;", c.Version)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func fingerprintSpec(rs RouteSpecification) string {
+	return fmt.Sprintf("%s %s %s", rs.Origin, rs.Destination, rs.ArrivalDeadline.UTC().Format(time.RFC3339Nano))
+}
+
+// deriveDelivery derives the delivery from the handling history, and logs the
+// change of status. The change is compared with the last one logged, so that
+// replaying events that have already been logged adds nothing: a change equal
+// to the last one, or triggered by an event completed before it, is a replay.
+func (c *Cargo) deriveDelivery(history HandlingHistory) []DeliveryChange {
+	prev := c.Delivery
+
+	history = c.sinceReopened(history).Current().SortedByCompletionTime()
+	changes := c.updateDelivery(DeriveDeliveryFrom(c.RouteSpecification, c.Itinerary, history))
+	if len(changes) == 0 {
+		return changes
+	}
+
+	last, _ := history.MostRecentlyCompletedEvent()
+
+	sc := StatusChange{
+		FromTransportStatus: prev.TransportStatus,
+		ToTransportStatus:   c.Delivery.TransportStatus,
+		FromRoutingStatus:   prev.RoutingStatus,
+		ToRoutingStatus:     c.Delivery.RoutingStatus,
+		CompletionTime:      last.CompletionTime,
+	}
+	if n := len(c.StatusChanges); n > 0 {
+		logged := c.StatusChanges[n-1]
+		replayed := !sc.CompletionTime.IsZero() && sc.CompletionTime.Before(logged.CompletionTime)
+		if logged.equal(sc) || replayed {
+			return changes
+		}
+	}
+	c.StatusChanges = append(c.StatusChanges, sc)
+
+	return changes
+}
+
+func (c *Cargo) updateDelivery(d Delivery) []DeliveryChange {
+	changes := c.Delivery.changesTo(d)
+	c.Delivery = d
+	return changes
+}
+
+// Cancel cancels the booking of this cargo. A cargo that has already been
+// claimed can not be cancelled.
+func (c *Cargo) Cancel() error {
+	if c.Delivery.TransportStatus == Claimed {
+		return ErrCargoClaimed
+	}
+	c.Cancelled = true
+	return nil
+}
+
+// Hold places the cargo on hold for the given reason, replacing the reason of
+// any previous hold. A cargo that has already been claimed can not be held.
+func (c *Cargo) Hold(reason string) error {
+	if c.Delivery.TransportStatus == Claimed {
+		return ErrCargoClaimed
+	}
+	c.OnHold = true
+	c.HoldReason = reason
+	return nil
+}
+
+// Release releases the cargo from hold. Releasing a cargo that is not on
+// hold does nothing.
+func (c *Cargo) Release() {
+	c.OnHold = false
+	c.HoldReason = ""
+}
+
+// TransferTo makes the customer responsible for the cargo, and records the
+// transfer at the given time in the ownership log. Transferring a cargo to
+// the customer already responsible for it does nothing. A cargo that has
+// already been claimed can not be transferred.
+func (c *Cargo) TransferTo(customer string, at time.Time) error {
+	if c.Delivery.TransportStatus == Claimed {
+		return ErrCargoClaimed
+	}
+	if customer == c.CustomerID {
+		return nil
+	}
+	c.OwnershipLog = append(c.OwnershipLog, OwnershipChange{From: c.CustomerID, To: customer, At: at})
+	c.CustomerID = customer
+	return nil
+}
+
+// SetTags sets the given tags of the cargo, replacing the values of the tags
+// it already has. A tag with an empty value is removed. Other tags are left
+// untouched.
+func (c *Cargo) SetTags(tags map[string]string) {
+	for k, v := range tags {
+		if v == "" {
+			delete(c.Tags, k)
+			continue
+		}
+		if c.Tags == nil {
+			c.Tags = make(map[string]string)
+		}
+		c.Tags[k] = v
+	}
+	if len(c.Tags) == 0 {
+		c.Tags = nil
+	}
+}
+
+// Archive marks the cargo as archived. Only a cargo that has been claimed can
+// be archived.
+func (c *Cargo) Archive() error {
+	if c.Delivery.TransportStatus != Claimed {
+		return ErrCargoNotClaimed
+	}
+	c.Archived = true
+	return nil
+}
+
+// Reopen prepares a claimed cargo for redelivery, for example after a failed
+// claim. The cargo is given the new route specification and loses its
+// itinerary, and is expected to be received again at the new origin. Its
+// handling history is left untouched, but the events completed by the claim
+// no longer count towards its delivery. Only a cargo that has been claimed
+// can be reopened.
+func (c *Cargo) Reopen(rs RouteSpecification) error {
+	if c.Delivery.TransportStatus != Claimed {
+		return ErrCargoNotClaimed
+	}
+	c.ReopenedAfter = c.Delivery.LastEvent.CompletionTime
+	c.Origin = rs.Origin
+	c.RouteSpecification = rs
+	c.Itinerary = Itinerary{}
+	c.Archived = false
+	c.updateDelivery(DeriveDeliveryFrom(rs, c.Itinerary, HandlingHistory{}))
+	return nil
+}
+
+// sinceReopened returns the events of the history that the delivery of the
+// cargo is derived from, that is, those completed after it was last reopened.
+func (c *Cargo) sinceReopened(history HandlingHistory) HandlingHistory {
+	if c.ReopenedAfter.IsZero() {
+		return history
+	}
+	return history.CompletedAfter(c.ReopenedAfter)
+}
+
+// IsAt checks whether the cargo was last handled at the given location, and
+// has been neither claimed nor cancelled. A cargo that has not been received
+// is not at any location.
+func (c *Cargo) IsAt(loc UNLocode) bool {
+	switch {
+	case c.Cancelled:
+		return false
+	case c.Delivery.TransportStatus == NotReceived, c.Delivery.TransportStatus == Claimed:
+		return false
+	}
+	return c.Delivery.LastKnownLocation == loc
+}
+
+// IsActive checks whether the cargo is still to be delivered, that is, has
+// been neither cancelled nor claimed.
+func (c *Cargo) IsActive() bool {
+	return !c.Cancelled && c.Delivery.TransportStatus != Claimed
+}
+
+// IsOverdue checks whether the cargo will miss, or has already missed, its
+// arrival deadline. A claimed or cancelled cargo is never overdue.
+func (c *Cargo) IsOverdue(now time.Time) bool {
+	return c.IsOverdueWithGrace(now, 0)
+}
+
+// IsOverdueWithGrace checks like IsOverdue, but allows the cargo to arrive
+// up to grace past its arrival deadline before it is overdue.
+func (c *Cargo) IsOverdueWithGrace(now time.Time, grace time.Duration) bool {
+	d := c.Delivery
+	deadline := c.RouteSpecification.ArrivalDeadline
+
+	if !c.IsActive() || deadline.IsZero() {
+		return false
+	}
+
+	deadline = deadline.Add(grace)
+
+	if !d.ETA.IsZero() && d.ETA.After(deadline) {
+		return true
+	}
+
+	return now.After(deadline) && !d.IsUnloadedAtDestination
+}
+
+// AwaitingClaimSince returns when the cargo was last unloaded at its final
+// destination, and whether it is still awaiting claim there. The cargo awaits
+// claim until it is claimed or loaded again, whatever other handling, such as
+// by customs, it gets in the meantime.
+func (c *Cargo) AwaitingClaimSince() (time.Time, bool) {
+	if c.Delivery.TransportStatus == Claimed {
+		return time.Time{}, false
+	}
+	if since := c.Delivery.UnloadedAtDestinationTime; !since.IsZero() {
+		return since, true
+	}
+	// A delivery derived before the unload time was recorded only knows
+	// the last event.
+	if c.Delivery.IsUnloadedAtDestination {
+		return c.Delivery.LastEvent.CompletionTime, true
+	}
+	return time.Time{}, false
+}
+
+// IsAwaitingClaimBeyond returns whether the cargo has been awaiting claim for
+// longer than the claim window by now.
+func (c *Cargo) IsAwaitingClaimBeyond(window time.Duration, now time.Time) bool {
+	since, ok := c.AwaitingClaimSince()
+	return ok && now.Sub(since) > window
+}
+
+// SlackTime returns how long before its arrival deadline the cargo is
+// expected to arrive, which is negative if it is expected to arrive late. A
+// cargo that is still to arrive, but is past its expected arrival, is
+// expected to arrive now. It returns ErrNoETA if there is no expected
+// arrival, such as when the cargo has not been routed, and
+// ErrInvalidArgument if the cargo has no arrival deadline.
+func (c *Cargo) SlackTime(now time.Time) (time.Duration, error) {
+	deadline := c.RouteSpecification.ArrivalDeadline
+	if deadline.IsZero() {
+		return 0, ErrInvalidArgument
+	}
+
+	d := c.Delivery
+	if d.ETA.IsZero() {
+		return 0, ErrNoETA
+	}
+
+	arrival := d.ETA
+	if arrival.Before(now) && !d.IsUnloadedAtDestination && d.TransportStatus != Claimed {
+		arrival = now
+	}
+
+	return deadline.Sub(arrival), nil
 }
 
 // NewCargo creates a new, unrouted cargo.
@@ -52,15 +457,143 @@ func NewCargo(id TrackingID, rs RouteSpecification) *Cargo {
 	}
 }
 
-// CargoRepository provides access a cargo store.
+// CargoRepository provides access a cargo store. Implementations should stop
+// early and return an empty result, or the context error, once the context is
+// done.
 type CargoRepository interface {
-	Store(cargo *Cargo) error
-	Find(id TrackingID) (*Cargo, error)
-	FindAll() []*Cargo
+	// Store saves the cargo and increments its version. It returns
+	// ErrConcurrentModification if the cargo has been stored with another
+	// version since it was read.
+	Store(ctx context.Context, cargo *Cargo) error
+	Find(ctx context.Context, id TrackingID) (*Cargo, error)
+
+	// FindAll returns all cargos that have not been archived.
+	FindAll(ctx context.Context) []*Cargo
+
+	// FindAllIncludingArchived returns all cargos, archived or not.
+	FindAllIncludingArchived(ctx context.Context) []*Cargo
+
+	FindOverdue(ctx context.Context, now time.Time) []*Cargo
+
+	// FindAwaitingClaimBeyond returns the cargos, that have been neither
+	// archived nor cancelled, that have been awaiting claim for longer than
+	// d by now, as decided by AwaitingClaimSince.
+	FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*Cargo
+
+	// FindAllPaged returns at most limit cargos ordered by tracking ID,
+	// starting at offset, together with the total number of cargos. Archived
+	// cargos are left out.
+	FindAllPaged(ctx context.Context, offset, limit int) ([]*Cargo, int, error)
+
+	// FindByRoutingStatus returns all cargos whose delivery currently has
+	// the given routing status.
+	FindByRoutingStatus(ctx context.Context, status RoutingStatus) []*Cargo
+
+	// FindAtLocation returns all cargos that are currently at the given
+	// location, as decided by IsAt.
+	FindAtLocation(ctx context.Context, loc UNLocode) []*Cargo
+
+	// FindByVoyage returns the cargos, that have been neither archived nor
+	// cancelled, whose itinerary has a leg on the given voyage.
+	FindByVoyage(ctx context.Context, number VoyageNumber) []*Cargo
+
+	// FindByTag returns the cargos, that have not been archived, tagged with
+	// the given key and value.
+	FindByTag(ctx context.Context, key, value string) []*Cargo
+
+	// FindByExternalRef returns the cargo, archived or not, with the given
+	// external reference. It returns ErrUnknownCargo if there is none.
+	FindByExternalRef(ctx context.Context, ref string) (*Cargo, error)
+
+	// FindChildren returns the cargos that have been consolidated into the
+	// given parent cargo.
+	FindChildren(ctx context.Context, parent TrackingID) []*Cargo
+
+	// FindByServiceLevel returns the cargos, that have not been archived,
+	// booked with the given service level, ordered by arrival deadline.
+	FindByServiceLevel(ctx context.Context, level ServiceLevel) []*Cargo
+
+	// FindByDestination returns the cargos, that have not been archived,
+	// whose route specification has the given destination, ordered by
+	// arrival deadline. If activeOnly is set, cargos that have been cancelled
+	// or claimed are left out, as decided by IsActive.
+	FindByDestination(ctx context.Context, dest UNLocode, activeOnly bool) []*Cargo
+
+	// Archive archives a claimed cargo, as by Cargo.Archive. It returns
+	// ErrCargoNotClaimed if the cargo has not been claimed.
+	Archive(ctx context.Context, id TrackingID) error
+
+	// Watch returns a channel of the changes made to cargos from now on,
+	// which is closed when the context is done. Writers are never held up
+	// by a slow watcher: once its buffer is full, the oldest change is
+	// dropped to make room. It returns ErrWatchNotSupported if the
+	// repository can not notify of changes.
+	Watch(ctx context.Context) (<-chan CargoChange, error)
+}
+
+// CargoChangeType tells how a cargo has been changed.
+type CargoChangeType int
+
+// Valid cargo change types.
+const (
+	CargoCreated CargoChangeType = iota
+	CargoUpdated
+	CargoRemoved
+)
+
+func (t CargoChangeType) String() string {
+	switch t {
+	case CargoCreated:
+		return "Created"
+	case CargoUpdated:
+		return "Updated"
+	case CargoRemoved:
+		return "Removed"
+	}
+	return ""
+}
+
+// CargoChange is sent to the watchers of a cargo repository when a cargo has
+// been stored, or removed from the repository.
+type CargoChange struct {
+	Type       CargoChangeType
+	TrackingID TrackingID
 }
 
 // ErrUnknownCargo is used when a cargo could not be found.
-var ErrUnknownCargo = errors.New("unknown cargo")
+var ErrUnknownCargo = NewError(CodeNotFound, "unknown cargo")
+
+// ErrInvalidArgument is returned when one or more arguments are invalid.
+var ErrInvalidArgument = NewError(CodeInvalidArgument, "invalid argument")
+
+// ErrCargoClaimed is used when an operation is not permitted because the
+// cargo has already been claimed.
+var ErrCargoClaimed = NewError(CodeFailedPrecondition, "cargo has been claimed")
+
+// ErrCargoOnHold is used when a cargo that is on hold is loaded.
+var ErrCargoOnHold = NewError(CodeFailedPrecondition, "cargo is on hold")
+
+// ErrConcurrentModification is used when a cargo is stored with a version
+// other than the one in the store, because it has been changed since it was
+// read.
+var ErrConcurrentModification = NewError(CodeConflict, "cargo has been modified concurrently")
+
+// ErrCargoNotClaimed is used when an operation is not permitted because the
+// cargo has not been claimed yet.
+var ErrCargoNotClaimed = NewError(CodeFailedPrecondition, "cargo has not been claimed")
+
+// ErrNoETA is used when the expected arrival of a cargo is asked for, but
+// the cargo is not on track to arrive, for example because it has not been
+// routed.
+var ErrNoETA = NewError(CodeFailedPrecondition, "cargo has no estimated time of arrival")
+
+// ErrWatchNotSupported is returned by repositories that can not notify
+// watchers of changes to cargos.
+var ErrWatchNotSupported = NewError(CodeUnimplemented, "watching changes is not supported")
+
+// ErrItineraryDoesNotSatisfySpec is used when an itinerary is assigned to a
+// cargo whose route specification it does not satisfy.
+var ErrItineraryDoesNotSatisfySpec = NewError(CodeFailedPrecondition, "itinerary does not satisfy route specification")
 
 // NextTrackingID generates a new tracking ID.
 // TODO: Move to infrastructure(?)
@@ -68,22 +601,189 @@ func NextTrackingID() TrackingID {
 	return TrackingID(strings.Split(strings.ToUpper(uuid.New()), "-")[0])
 }
 
+// TrackingIDFactory generates tracking IDs for new cargos. The generated IDs
+// are not required to be unique; callers check for collisions.
+type TrackingIDFactory interface {
+	NextTrackingID() TrackingID
+}
+
+// TrackingIDFactoryFunc is an adapter to allow the use of ordinary functions
+// as tracking ID factories.
+type TrackingIDFactoryFunc func() TrackingID
+
+// NextTrackingID calls f().
+func (f TrackingIDFactoryFunc) NextTrackingID() TrackingID {
+	return f()
+}
+
+// DefaultTrackingIDFactory generates random tracking IDs using
+// NextTrackingID.
+var DefaultTrackingIDFactory TrackingIDFactory = TrackingIDFactoryFunc(NextTrackingID)
+
+// TrackingIDReserver claims generated tracking IDs before they are used, so
+// that no two cargos are booked with the same ID, even by services storing
+// them in different repositories.
+type TrackingIDReserver interface {
+	// Reserve atomically claims the ID. It returns ErrTrackingIDTaken if the
+	// ID has already been claimed or is in use.
+	Reserve(ctx context.Context, id TrackingID) error
+}
+
+// TrackingIDReserverFunc is an adapter to allow the use of ordinary
+// functions, such as clients of a distributed lock, as tracking ID
+// reservers.
+type TrackingIDReserverFunc func(ctx context.Context, id TrackingID) error
+
+// Reserve calls f(ctx, id).
+func (f TrackingIDReserverFunc) Reserve(ctx context.Context, id TrackingID) error {
+	return f(ctx, id)
+}
+
+// ErrTrackingIDTaken is used when reserving a tracking ID that has already
+// been claimed.
+var ErrTrackingIDTaken = NewError(CodeAlreadyExists, "tracking id has already been taken")
+
 // RouteSpecification Contains information about a route: its origin,
 // destination and arrival deadline.
+//
+// New specifications should be created with NewRouteSpecification, which
+// rejects those that can not be satisfied.
 type RouteSpecification struct {
 	Origin          UNLocode
 	Destination     UNLocode
 	ArrivalDeadline time.Time
 }
 
+// ErrSameOriginAndDestination is used when a route specification starts where
+// it ends.
+var ErrSameOriginAndDestination = NewError(CodeInvalidArgument, "origin and destination are the same")
+
+// ErrDeadlineNotInFuture is used when a route specification has an arrival
+// deadline that has already passed.
+var ErrDeadlineNotInFuture = NewError(CodeInvalidArgument, "arrival deadline is not in the future")
+
+// NewRouteSpecification creates a route specification from origin to
+// destination, arriving by the deadline. It returns ErrInvalidUNLocode if
+// either location is malformed, ErrSameOriginAndDestination if they are the
+// same, and ErrDeadlineNotInFuture unless the deadline is after the current
+// time of the clock.
+func NewRouteSpecification(origin, destination UNLocode, deadline time.Time, clock Clock) (RouteSpecification, error) {
+	origin, err := NewUNLocode(string(origin))
+	if err != nil {
+		return RouteSpecification{}, err
+	}
+	destination, err = NewUNLocode(string(destination))
+	if err != nil {
+		return RouteSpecification{}, err
+	}
+
+	if origin == destination {
+		return RouteSpecification{}, ErrSameOriginAndDestination
+	}
+	if !deadline.After(clock.Now()) {
+		return RouteSpecification{}, ErrDeadlineNotInFuture
+	}
+
+	return RouteSpecification{
+		Origin:          origin,
+		Destination:     destination,
+		ArrivalDeadline: deadline,
+	}, nil
+}
+
 // IsSatisfiedBy checks whether provided itinerary satisfies this
-// specification.
+// specification, i.e. that it starts at the origin, ends at the destination
+// and arrives no later than the arrival deadline. A zero deadline is
+// satisfied by any arrival time.
 func (s RouteSpecification) IsSatisfiedBy(itinerary Itinerary) bool {
+	return s.connects(itinerary) && s.ArrivesInTime(itinerary)
+}
+
+// Explain returns the reasons why provided itinerary does not satisfy this
+// specification, one for each constraint it fails, or none if it satisfies
+// it.
+func (s RouteSpecification) Explain(itinerary Itinerary) []string {
+	if itinerary.Legs == nil {
+		return []string{"itinerary has no legs"}
+	}
+
+	var reasons []string
+	if from := itinerary.InitialDepartureLocation(); from != s.Origin {
+		reasons = append(reasons, fmt.Sprintf("departs from %s instead of origin %s", from, s.Origin))
+	}
+	if to := itinerary.FinalArrivalLocation(); to != s.Destination {
+		reasons = append(reasons, fmt.Sprintf("arrives at %s instead of destination %s", to, s.Destination))
+	}
+	if !s.ArrivesInTime(itinerary) {
+		reasons = append(reasons, fmt.Sprintf("arrives %s, after arrival deadline %s",
+			itinerary.FinalArrivalTime().Format(time.RFC3339), s.ArrivalDeadline.Format(time.RFC3339)))
+	}
+	return reasons
+}
+
+// IsPartiallySatisfiedBy checks whether provided partial itinerary could
+// be the first part of one that satisfies this specification, i.e. that it
+// starts at the origin, ends elsewhere than the destination and arrives
+// there no later than the arrival deadline.
+func (s RouteSpecification) IsPartiallySatisfiedBy(itinerary Itinerary) bool {
+	return s.startsPartially(itinerary) && s.ArrivesInTime(itinerary)
+}
+
+// AnySatisfiedBy returns the first of the route specifications that is
+// satisfied by the itinerary.
+func AnySatisfiedBy(specs []RouteSpecification, itinerary Itinerary) (RouteSpecification, bool) {
+	for _, rs := range specs {
+		if rs.IsSatisfiedBy(itinerary) {
+			return rs, true
+		}
+	}
+	return RouteSpecification{}, false
+}
+
+// ArrivesInTime checks whether the itinerary arrives no later than the
+// arrival deadline. Any itinerary arrives in time without a deadline.
+func (s RouteSpecification) ArrivesInTime(itinerary Itinerary) bool {
+	return s.ArrivalDeadline.IsZero() || !itinerary.FinalArrivalTime().After(s.ArrivalDeadline)
+}
+
+// deadlineResolution is the resolution at which arrival deadlines are
+// compared.
+const deadlineResolution = time.Second
+
+// Equal checks whether both specifications have the same origin, destination
+// and arrival deadline. Deadlines are compared as instants, to the second,
+// regardless of their time zone.
+func (s RouteSpecification) Equal(other RouteSpecification) bool {
+	return s.Key() == other.Key()
+}
+
+// Key returns a string that is the same for, and only for, equal route
+// specifications.
+func (s RouteSpecification) Key() string {
+	deadline := ""
+	if !s.ArrivalDeadline.IsZero() {
+		deadline = s.ArrivalDeadline.UTC().Truncate(deadlineResolution).Format(time.RFC3339)
+	}
+	return string(s.Origin) + "|" + string(s.Destination) + "|" + deadline
+}
+
+// connects checks whether provided itinerary goes from the origin to the
+// destination of this specification, regardless of when it arrives.
+func (s RouteSpecification) connects(itinerary Itinerary) bool {
 	return itinerary.Legs != nil &&
 		s.Origin == itinerary.InitialDepartureLocation() &&
 		s.Destination == itinerary.FinalArrivalLocation()
 }
 
+// startsPartially checks whether provided itinerary is partial, and goes
+// from the origin of this specification to somewhere short of its
+// destination.
+func (s RouteSpecification) startsPartially(itinerary Itinerary) bool {
+	return itinerary.Partial && itinerary.Legs != nil &&
+		s.Origin == itinerary.InitialDepartureLocation() &&
+		s.Destination != itinerary.FinalArrivalLocation()
+}
+
 // RoutingStatus describes status of cargo routing.
 type RoutingStatus int
 
@@ -92,6 +792,10 @@ const (
 	NotRouted RoutingStatus = iota
 	Misrouted
 	Routed
+
+	// PartiallyRouted is the status of a cargo assigned to a partial
+	// itinerary that has yet to reach the destination.
+	PartiallyRouted
 )
 
 func (s RoutingStatus) String() string {
@@ -102,10 +806,54 @@ func (s RoutingStatus) String() string {
 		return "Misrouted"
 	case Routed:
 		return "Routed"
+	case PartiallyRouted:
+		return "Partially routed"
+	}
+	return ""
+}
+
+// ServiceLevel describes how urgently a cargo is to be handled.
+type ServiceLevel int
+
+// Valid service levels, from the least to the most urgent.
+const (
+	Standard ServiceLevel = iota
+	Express
+	Critical
+)
+
+func (l ServiceLevel) String() string {
+	switch l {
+	case Standard:
+		return "Standard"
+	case Express:
+		return "Express"
+	case Critical:
+		return "Critical"
 	}
 	return ""
 }
 
+// IsValid returns whether l is one of the valid service levels.
+func (l ServiceLevel) IsValid() bool {
+	return l >= Standard && l <= Critical
+}
+
+// SortByServiceLevel sorts the cargos by service level, the most urgent
+// first, then by arrival deadline and tracking ID.
+func SortByServiceLevel(cargos []*Cargo) {
+	sort.Slice(cargos, func(i, j int) bool {
+		if li, lj := cargos[i].ServiceLevel, cargos[j].ServiceLevel; li != lj {
+			return li > lj
+		}
+		di, dj := cargos[i].RouteSpecification.ArrivalDeadline, cargos[j].RouteSpecification.ArrivalDeadline
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return cargos[i].TrackingID < cargos[j].TrackingID
+	})
+}
+
 // TransportStatus describes status of cargo transportation.
 type TransportStatus int
 
@@ -133,3 +881,41 @@ func (s TransportStatus) String() string {
 	}
 	return ""
 }
+
+// CrossesBorder returns whether the cargo is to be shipped between countries,
+// either by its route specification or by any leg of its itinerary.
+func (c *Cargo) CrossesBorder() bool {
+	country := c.RouteSpecification.Origin.Country()
+	if c.RouteSpecification.Destination.Country() != country {
+		return true
+	}
+	for _, l := range c.Itinerary.Legs {
+		if l.LoadLocation.Country() != country || l.UnloadLocation.Country() != country {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomsStatus describes the customs clearance of a cargo.
+type CustomsStatus int
+
+// Valid customs statuses. A cargo is in customs from a customs event that
+// did not clear it, and cleared by a customs event that did.
+const (
+	NotCleared CustomsStatus = iota
+	InCustoms
+	Cleared
+)
+
+func (s CustomsStatus) String() string {
+	switch s {
+	case NotCleared:
+		return "Not cleared"
+	case InCustoms:
+		return "In customs"
+	case Cleared:
+		return "Cleared"
+	}
+	return ""
+}
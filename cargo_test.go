@@ -1,6 +1,7 @@
 package shipping
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -23,9 +24,9 @@ func TestConstruction(t *testing.T) {
 		t.Errorf("TransportStatus = %v; want = %v",
 			c.Delivery.TransportStatus, NotReceived)
 	}
-	if c.Delivery.LastKnownLocation != "" {
+	if c.Delivery.LastKnownLocation != SESTO {
 		t.Errorf("LastKnownLocation = %s; want = %s",
-			c.Delivery.LastKnownLocation, "")
+			c.Delivery.LastKnownLocation, SESTO)
 	}
 }
 
@@ -74,8 +75,37 @@ func TestLastKnownLocation_WhenNoEvents(t *testing.T) {
 		Destination: CNHKG,
 	})
 
-	if c.Delivery.LastKnownLocation != "" {
-		t.Errorf("should be equal")
+	if c.Delivery.LastKnownLocation != SESTO {
+		t.Errorf("LastKnownLocation = %s; want = %s", c.Delivery.LastKnownLocation, SESTO)
+	}
+	if c.IsAt(SESTO) {
+		t.Errorf("IsAt(%s) = %v; want = %v", SESTO, true, false)
+	}
+}
+
+func TestLastKnownLocation_WhenHandledSeveralTimes(t *testing.T) {
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+
+	var events []HandlingEvent
+	for _, tt := range []struct {
+		activity HandlingActivity
+		want     UNLocode
+	}{
+		{HandlingActivity{Type: Receive, Location: SESTO}, SESTO},
+		{HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, SESTO},
+		{HandlingActivity{Type: Unload, Location: DEHAM, VoyageNumber: "V100"}, DEHAM},
+		{HandlingActivity{Type: Load, Location: DEHAM, VoyageNumber: "V300"}, DEHAM},
+		{HandlingActivity{Type: Unload, Location: AUMEL, VoyageNumber: "V300"}, AUMEL},
+	} {
+		events = append(events, HandlingEvent{TrackingID: "ABC", Activity: tt.activity})
+		c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: events})
+
+		if c.Delivery.LastKnownLocation != tt.want {
+			t.Errorf("after %s: LastKnownLocation = %s; want = %s", tt.activity.Type, c.Delivery.LastKnownLocation, tt.want)
+		}
 	}
 }
 
@@ -97,6 +127,95 @@ func TestLastKnownLocation_WhenClaimed(t *testing.T) {
 	}
 }
 
+func TestRoutingStatus_Partial(t *testing.T) {
+	rs := RouteSpecification{Origin: SESTO, Destination: AUMEL}
+
+	itinerary := func(partial bool, legs ...Leg) Itinerary {
+		return Itinerary{Legs: legs, Partial: partial}
+	}
+
+	var tests = []struct {
+		name      string
+		itinerary Itinerary
+		want      RoutingStatus
+	}{
+		{"partial", itinerary(true, Leg{LoadLocation: SESTO, UnloadLocation: NLRTM}), PartiallyRouted},
+		{"partial to destination", itinerary(true, Leg{LoadLocation: SESTO, UnloadLocation: NLRTM}, Leg{LoadLocation: NLRTM, UnloadLocation: AUMEL}), Routed},
+		{"fully routed", itinerary(false, Leg{LoadLocation: SESTO, UnloadLocation: AUMEL}), Routed},
+		{"short of destination", itinerary(false, Leg{LoadLocation: SESTO, UnloadLocation: NLRTM}), Misrouted},
+		{"partial from elsewhere", itinerary(true, Leg{LoadLocation: CNHKG, UnloadLocation: NLRTM}), Misrouted},
+	}
+
+	for _, tt := range tests {
+		c := NewCargo("ABC", rs)
+		c.AssignToRoute(tt.itinerary)
+
+		if c.Delivery.RoutingStatus != tt.want {
+			t.Errorf("%s: RoutingStatus = %v; want = %v", tt.name, c.Delivery.RoutingStatus, tt.want)
+		}
+	}
+
+	c := NewCargo("ABC", rs)
+	c.AssignToRoute(itinerary(true, Leg{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: NLRTM}))
+	c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}},
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}},
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Unload, Location: NLRTM, VoyageNumber: "V100"}},
+	}})
+
+	if c.Delivery.RoutingStatus != PartiallyRouted {
+		t.Errorf("RoutingStatus = %v; want = %v", c.Delivery.RoutingStatus, PartiallyRouted)
+	}
+	if c.Delivery.IsMisdirected {
+		t.Errorf("IsMisdirected = %v; want = %v", c.Delivery.IsMisdirected, false)
+	}
+}
+
+func TestSortByServiceLevel(t *testing.T) {
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	cargos := []*Cargo{
+		{TrackingID: "ABC", RouteSpecification: RouteSpecification{ArrivalDeadline: deadline}},
+		{TrackingID: "DEF", ServiceLevel: Express, RouteSpecification: RouteSpecification{ArrivalDeadline: deadline.AddDate(0, 0, 1)}},
+		{TrackingID: "GHI", ServiceLevel: Critical, RouteSpecification: RouteSpecification{ArrivalDeadline: deadline.AddDate(0, 0, 2)}},
+		{TrackingID: "JKL", ServiceLevel: Express, RouteSpecification: RouteSpecification{ArrivalDeadline: deadline}},
+	}
+
+	SortByServiceLevel(cargos)
+
+	var got []TrackingID
+	for _, c := range cargos {
+		got = append(got, c.TrackingID)
+	}
+
+	if want := []TrackingID{"GHI", "JKL", "DEF", "ABC"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SortByServiceLevel() = %v; want = %v", got, want)
+	}
+}
+
+var serviceLevelTests = []struct {
+	level    ServiceLevel
+	expected string
+}{
+	{Standard, "Standard"},
+	{Express, "Express"},
+	{Critical, "Critical"},
+	{1000, ""},
+}
+
+func TestServiceLevel_Stringer(t *testing.T) {
+	for _, tt := range serviceLevelTests {
+		if tt.level.String() != tt.expected {
+			t.Errorf("level.String() = %s; want = %s", tt.level.String(), tt.expected)
+		}
+	}
+
+	var c Cargo
+	if c.ServiceLevel != Standard {
+		t.Errorf("ServiceLevel = %v; want = %v", c.ServiceLevel, Standard)
+	}
+}
+
 var routingStatusTests = []struct {
 	routingStatus RoutingStatus
 	expected      string
@@ -104,6 +223,7 @@ var routingStatusTests = []struct {
 	{NotRouted, "Not routed"},
 	{Misrouted, "Misrouted"},
 	{Routed, "Routed"},
+	{PartiallyRouted, "Partially routed"},
 	{1000, ""},
 }
 
@@ -182,3 +302,636 @@ func populateCargoClaimedInMelbourne() *Cargo {
 
 	return c
 }
+
+func TestIsOverdue(t *testing.T) {
+	deadline := time.Date(2009, time.March, 13, 0, 0, 0, 0, time.UTC)
+
+	rs := RouteSpecification{
+		Origin:          SESTO,
+		Destination:     AUMEL,
+		ArrivalDeadline: deadline,
+	}
+
+	routed := func(arrival time.Time) *Cargo {
+		c := NewCargo("ABC", rs)
+		c.AssignToRoute(Itinerary{Legs: []Leg{
+			NewLeg("V100", SESTO, AUMEL, deadline.AddDate(0, 0, -5), arrival),
+		}})
+		return c
+	}
+
+	claimed := populateCargoClaimedInMelbourne()
+	claimed.RouteSpecification.ArrivalDeadline = deadline
+
+	cancelled := NewCargo("ABC", rs)
+	if err := cancelled.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name  string
+		cargo *Cargo
+		now   time.Time
+		want  bool
+	}{
+		{"ETA before deadline", routed(deadline.Add(-time.Hour)), deadline.AddDate(0, 0, -1), false},
+		{"ETA equals deadline", routed(deadline), deadline.AddDate(0, 0, -1), false},
+		{"ETA after deadline", routed(deadline.Add(time.Second)), deadline.AddDate(0, 0, -1), true},
+		{"unrouted before deadline", NewCargo("ABC", rs), deadline, false},
+		{"unrouted after deadline", NewCargo("ABC", rs), deadline.Add(time.Second), true},
+		{"claimed after deadline", claimed, deadline.Add(time.Second), false},
+		{"cancelled after deadline", cancelled, deadline.Add(time.Second), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cargo.IsOverdue(tt.now); got != tt.want {
+			t.Errorf("%s: IsOverdue() = %v; want = %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSlackTime(t *testing.T) {
+	deadline := time.Date(2009, time.March, 13, 0, 0, 0, 0, time.UTC)
+
+	rs := RouteSpecification{
+		Origin:          SESTO,
+		Destination:     AUMEL,
+		ArrivalDeadline: deadline,
+	}
+
+	routed := func(arrival time.Time) *Cargo {
+		c := NewCargo("ABC", rs)
+		c.AssignToRoute(Itinerary{Legs: []Leg{
+			NewLeg("V100", SESTO, AUMEL, deadline.AddDate(0, 0, -5), arrival),
+		}})
+		return c
+	}
+
+	now := deadline.AddDate(0, 0, -4)
+
+	var tests = []struct {
+		name  string
+		cargo *Cargo
+		now   time.Time
+		want  time.Duration
+		err   error
+	}{
+		{"ample slack", routed(deadline.AddDate(0, 0, -2)), now, 48 * time.Hour, nil},
+		{"zero slack", routed(deadline), now, 0, nil},
+		{"late", routed(deadline.Add(3 * time.Hour)), now, -3 * time.Hour, nil},
+		{"past ETA", routed(deadline.AddDate(0, 0, -2)), deadline.AddDate(0, 0, -1), 24 * time.Hour, nil},
+		{"unrouted", NewCargo("ABC", rs), now, 0, ErrNoETA},
+		{"no deadline", NewCargo("ABC", RouteSpecification{Origin: SESTO, Destination: AUMEL}), now, 0, ErrInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.cargo.SlackTime(tt.now)
+		if err != tt.err {
+			t.Errorf("%s: err = %v; want = %v", tt.name, err, tt.err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: SlackTime() = %v; want = %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestETA(t *testing.T) {
+	var (
+		arrival  = time.Date(2009, time.March, 10, 0, 0, 0, 0, time.UTC)
+		rerouted = time.Date(2009, time.March, 12, 0, 0, 0, 0, time.UTC)
+	)
+
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+
+	if !c.Delivery.ETA.IsZero() {
+		t.Errorf("ETA = %s; want zero time when unrouted", c.Delivery.ETA)
+	}
+
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, CNHKG, time.Time{}, arrival),
+	}})
+
+	if !c.Delivery.ETA.IsZero() {
+		t.Errorf("ETA = %s; want zero time when misrouted", c.Delivery.ETA)
+	}
+
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, CNHKG, time.Time{}, arrival.AddDate(0, 0, -2)),
+		NewLeg("V200", CNHKG, AUMEL, time.Time{}, arrival),
+	}})
+
+	if c.Delivery.ETA != arrival {
+		t.Errorf("ETA = %s; want = %s", c.Delivery.ETA, arrival)
+	}
+
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, CNHKG, time.Time{}, arrival.AddDate(0, 0, -2)),
+		NewLeg("V300", CNHKG, AUMEL, time.Time{}, rerouted),
+	}})
+
+	if c.Delivery.ETA != rerouted {
+		t.Errorf("ETA = %s; want = %s", c.Delivery.ETA, rerouted)
+	}
+}
+
+func TestReplay_OutOfOrderEvents(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+	)
+
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, AUMEL, t1, t2),
+	}})
+
+	var (
+		receive = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}, CompletionTime: t0}
+		load    = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, CompletionTime: t1}
+		unload  = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Unload, Location: AUMEL, VoyageNumber: "V100"}, CompletionTime: t2}
+	)
+
+	// The load event is registered late, after the unload.
+	history := HandlingHistory{HandlingEvents: []HandlingEvent{receive, unload, load}}
+
+	c.Replay(history)
+
+	if c.Delivery.LastEvent != unload {
+		t.Errorf("LastEvent = %v; want = %v", c.Delivery.LastEvent, unload)
+	}
+	if c.Delivery.LastKnownLocation != AUMEL {
+		t.Errorf("LastKnownLocation = %s; want = %s", c.Delivery.LastKnownLocation, AUMEL)
+	}
+	if !c.Delivery.IsUnloadedAtDestination {
+		t.Errorf("IsUnloadedAtDestination = %v; want = %v", c.Delivery.IsUnloadedAtDestination, true)
+	}
+
+	// An event inserted with an earlier completion time does not become the
+	// most recent one.
+	late := HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Customs, Location: SESTO}, CompletionTime: t0.Add(time.Hour)}
+	history.HandlingEvents = append(history.HandlingEvents, late)
+
+	c.Replay(history)
+
+	if c.Delivery.LastEvent != unload {
+		t.Errorf("LastEvent = %v; want = %v", c.Delivery.LastEvent, unload)
+	}
+
+	// The history itself is left untouched.
+	if history.HandlingEvents[1] != unload {
+		t.Errorf("HandlingEvents[1] = %v; want = %v", history.HandlingEvents[1], unload)
+	}
+}
+
+func TestDeriveDeliveryProgress_LateReceive(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+	)
+
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, AUMEL, t1, t1.AddDate(0, 0, 1)),
+	}})
+
+	var (
+		receive = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}, CompletionTime: t0}
+		load    = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, CompletionTime: t1}
+	)
+
+	c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{load}})
+
+	// The receive event is uploaded late, after the load.
+	if got := c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{load, receive}}); len(got) != 0 {
+		t.Errorf("DeriveDeliveryProgress() = %v; want no changes", got)
+	}
+
+	if c.Delivery.TransportStatus != OnboardCarrier {
+		t.Errorf("TransportStatus = %v; want = %v", c.Delivery.TransportStatus, OnboardCarrier)
+	}
+	if c.Delivery.LastEvent != load {
+		t.Errorf("LastEvent = %v; want = %v", c.Delivery.LastEvent, load)
+	}
+	if c.Delivery.CurrentVoyage != "V100" {
+		t.Errorf("CurrentVoyage = %v; want = %v", c.Delivery.CurrentVoyage, "V100")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+	)
+
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:          SESTO,
+		Destination:     AUMEL,
+		ArrivalDeadline: t2,
+	})
+	c.AlternateRouteSpecifications = []RouteSpecification{
+		{Origin: SESTO, Destination: CNHKG, ArrivalDeadline: t2},
+		{Origin: SESTO, Destination: DEHAM, ArrivalDeadline: t2},
+	}
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, DEHAM, t0, t1),
+		NewLeg("V100", DEHAM, AUMEL, t1, t2),
+	}})
+
+	history := HandlingHistory{HandlingEvents: []HandlingEvent{
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}, CompletionTime: t0},
+	}}
+	c.DeriveDeliveryProgress(history)
+
+	before := c.Fingerprint()
+
+	// Re-deriving an unchanged delivery keeps the fingerprint.
+	c.DeriveDeliveryProgress(history)
+	if got := c.Fingerprint(); got != before {
+		t.Errorf("Fingerprint() = %s; want = %s", got, before)
+	}
+
+	// So does a difference in representation only.
+	same := *c
+	same.AlternateRouteSpecifications = []RouteSpecification{
+		c.AlternateRouteSpecifications[1],
+		c.AlternateRouteSpecifications[0],
+	}
+	same.Itinerary = c.Itinerary.Normalized()
+	same.RouteSpecification.ArrivalDeadline = t2.In(time.FixedZone("AEST", 10*60*60))
+	if got := same.Fingerprint(); got != before {
+		t.Errorf("Fingerprint() = %s; want = %s", got, before)
+	}
+
+	// A change of status alters it.
+	history.HandlingEvents = append(history.HandlingEvents,
+		HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, CompletionTime: t0.Add(time.Hour)},
+	)
+	c.DeriveDeliveryProgress(history)
+	if got := c.Fingerprint(); got == before {
+		t.Errorf("Fingerprint() = %s; want other than %s", got, before)
+	}
+}
+
+func TestReplay_EmptyHistory(t *testing.T) {
+	c := NewCargo("ABC", RouteSpecification{Origin: SESTO, Destination: AUMEL})
+	c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}},
+	}})
+
+	c.Replay(HandlingHistory{})
+
+	if c.Delivery.TransportStatus != NotReceived {
+		t.Errorf("TransportStatus = %v; want = %v", c.Delivery.TransportStatus, NotReceived)
+	}
+}
+
+func TestRouteSpecification_IsSatisfiedBy(t *testing.T) {
+	deadline := time.Date(2009, time.March, 13, 0, 0, 0, 0, time.UTC)
+
+	rs := RouteSpecification{
+		Origin:          SESTO,
+		Destination:     AUMEL,
+		ArrivalDeadline: deadline,
+	}
+
+	itinerary := func(from, to UNLocode, arrival time.Time) Itinerary {
+		return Itinerary{Legs: []Leg{
+			NewLeg("V100", from, CNHKG, deadline.AddDate(0, 0, -10), deadline.AddDate(0, 0, -5)),
+			NewLeg("V200", CNHKG, to, deadline.AddDate(0, 0, -4), arrival),
+		}}
+	}
+
+	var tests = []struct {
+		name      string
+		itinerary Itinerary
+		want      bool
+	}{
+		{"satisfied", itinerary(SESTO, AUMEL, deadline), true},
+		{"wrong origin", itinerary(NLRTM, AUMEL, deadline), false},
+		{"wrong destination", itinerary(SESTO, JNTKO, deadline), false},
+		{"arrives after deadline", itinerary(SESTO, AUMEL, deadline.Add(time.Second)), false},
+		{"no legs", Itinerary{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := rs.IsSatisfiedBy(tt.itinerary); got != tt.want {
+			t.Errorf("%s: IsSatisfiedBy() = %v; want = %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRouteSpecification_Explain(t *testing.T) {
+	deadline := time.Date(2009, time.March, 13, 0, 0, 0, 0, time.UTC)
+
+	rs := RouteSpecification{
+		Origin:          SESTO,
+		Destination:     AUMEL,
+		ArrivalDeadline: deadline,
+	}
+
+	satisfying := Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, AUMEL, deadline.AddDate(0, 0, -10), deadline),
+	}}
+	if got := rs.Explain(satisfying); len(got) != 0 {
+		t.Errorf("Explain() = %v; want none", got)
+	}
+
+	failing := Itinerary{Legs: []Leg{
+		NewLeg("V100", NLRTM, AUMEL, deadline.AddDate(0, 0, -10), deadline.Add(time.Hour)),
+	}}
+	want := []string{
+		"departs from NLRTM instead of origin SESTO",
+		"arrives 2009-03-13T01:00:00Z, after arrival deadline 2009-03-13T00:00:00Z",
+	}
+	if got := rs.Explain(failing); !reflect.DeepEqual(got, want) {
+		t.Errorf("Explain() = %q; want = %q", got, want)
+	}
+}
+
+func TestDeliveryChanges(t *testing.T) {
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+
+	itinerary := Itinerary{Legs: []Leg{
+		{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: AUMEL},
+	}}
+
+	if got, want := c.AssignToRoute(itinerary), []DeliveryChange{
+		RoutingStatusChanged{From: NotRouted, To: Routed},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AssignToRoute() = %v; want = %v", got, want)
+	}
+
+	history := HandlingHistory{HandlingEvents: []HandlingEvent{
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}},
+	}}
+
+	if got, want := c.DeriveDeliveryProgress(history), []DeliveryChange{
+		TransportStatusChanged{From: NotReceived, To: InPort},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DeriveDeliveryProgress() = %v; want = %v", got, want)
+	}
+
+	if got := c.DeriveDeliveryProgress(history); len(got) != 0 {
+		t.Errorf("DeriveDeliveryProgress() = %v; want no changes", got)
+	}
+
+	if got, want := c.SpecifyNewRoute(RouteSpecification{Origin: SESTO, Destination: CNHKG}), []DeliveryChange{
+		RoutingStatusChanged{From: Routed, To: Misrouted},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SpecifyNewRoute() = %v; want = %v", got, want)
+	}
+
+	if got := c.Replay(history); len(got) != 0 {
+		t.Errorf("Replay() = %v; want no changes", got)
+	}
+}
+
+func TestRouteSpecification_Equal(t *testing.T) {
+	stockholm := time.FixedZone("CET", 60*60)
+
+	deadline := time.Date(2009, time.March, 13, 12, 0, 0, 0, time.UTC)
+
+	rs := RouteSpecification{Origin: SESTO, Destination: AUMEL, ArrivalDeadline: deadline}
+
+	var tests = []struct {
+		name  string
+		other RouteSpecification
+		want  bool
+	}{
+		{"same", rs, true},
+		{"other zone", RouteSpecification{Origin: SESTO, Destination: AUMEL, ArrivalDeadline: deadline.In(stockholm)}, true},
+		{"below resolution", RouteSpecification{Origin: SESTO, Destination: AUMEL, ArrivalDeadline: deadline.Add(time.Millisecond)}, true},
+		{"other deadline", RouteSpecification{Origin: SESTO, Destination: AUMEL, ArrivalDeadline: deadline.Add(time.Second)}, false},
+		{"other destination", RouteSpecification{Origin: SESTO, Destination: CNHKG, ArrivalDeadline: deadline}, false},
+		{"no deadline", RouteSpecification{Origin: SESTO, Destination: AUMEL}, false},
+	}
+
+	for _, tt := range tests {
+		if got := rs.Equal(tt.other); got != tt.want {
+			t.Errorf("%s: Equal() = %v; want = %v", tt.name, got, tt.want)
+		}
+		if got := rs.Key() == tt.other.Key(); got != tt.want {
+			t.Errorf("%s: Key() == other.Key() is %v; want = %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnySatisfiedBy(t *testing.T) {
+	specs := []RouteSpecification{
+		{Origin: SESTO, Destination: NLRTM},
+		{Origin: SESTO, Destination: DEHAM},
+	}
+
+	itinerary := Itinerary{Legs: []Leg{
+		{VoyageNumber: "V400", LoadLocation: SESTO, UnloadLocation: DEHAM},
+	}}
+
+	rs, ok := AnySatisfiedBy(specs, itinerary)
+	if !ok {
+		t.Fatalf("AnySatisfiedBy() = %v; want = %v", ok, true)
+	}
+	if rs.Destination != DEHAM {
+		t.Errorf("rs.Destination = %s; want = %s", rs.Destination, DEHAM)
+	}
+
+	if _, ok := AnySatisfiedBy(specs[:1], itinerary); ok {
+		t.Errorf("AnySatisfiedBy() = %v; want = %v", ok, false)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+		t3 = t0.AddDate(0, 0, 3)
+	)
+
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, AUMEL, t1, t2),
+	}})
+
+	events := []HandlingEvent{
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}, CompletionTime: t0},
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, CompletionTime: t1},
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Unload, Location: AUMEL, VoyageNumber: "V100"}, CompletionTime: t2},
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Claim, Location: AUMEL}, CompletionTime: t3},
+	}
+
+	for i := range events {
+		c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: events[:i+1]})
+	}
+
+	want := []StatusChange{
+		{FromTransportStatus: NotReceived, ToTransportStatus: InPort, FromRoutingStatus: Routed, ToRoutingStatus: Routed, CompletionTime: t0},
+		{FromTransportStatus: InPort, ToTransportStatus: OnboardCarrier, FromRoutingStatus: Routed, ToRoutingStatus: Routed, CompletionTime: t1},
+		{FromTransportStatus: OnboardCarrier, ToTransportStatus: InPort, FromRoutingStatus: Routed, ToRoutingStatus: Routed, CompletionTime: t2},
+		{FromTransportStatus: InPort, ToTransportStatus: Claimed, FromRoutingStatus: Routed, ToRoutingStatus: Routed, CompletionTime: t3},
+	}
+
+	if got := c.History(); !reflect.DeepEqual(got, want) {
+		t.Errorf("History() = %v; want = %v", got, want)
+	}
+
+	// Replaying the same events does not log any duplicates.
+	c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: events})
+	c.Replay(HandlingHistory{HandlingEvents: events})
+
+	replayed := NewCargo("ABC", c.RouteSpecification)
+	replayed.AssignToRoute(c.Itinerary)
+	replayed.StatusChanges = c.History()
+
+	for i := range events {
+		replayed.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: events[:i+1]})
+	}
+
+	if got := replayed.History(); !reflect.DeepEqual(got, want) {
+		t.Errorf("History() = %v; want = %v", got, want)
+	}
+
+	if got := c.History(); len(got) != len(want) {
+		t.Errorf("len(History()) = %d; want = %d", len(got), len(want))
+	}
+
+	// The returned log is a copy.
+	c.History()[0].ToTransportStatus = Unknown
+
+	if got := c.History()[0].ToTransportStatus; got != InPort {
+		t.Errorf("ToTransportStatus = %v; want = %v", got, InPort)
+	}
+}
+
+func TestHistory_RepeatedChange(t *testing.T) {
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+
+	receive := HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}, CompletionTime: t0}
+	superseded := receive
+	superseded.Superseded = true
+
+	// The receipt is superseded, and then registered again.
+	c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{receive}})
+	c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{superseded}})
+	c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{superseded, receive}})
+
+	want := []StatusChange{
+		{FromTransportStatus: NotReceived, ToTransportStatus: InPort, FromRoutingStatus: NotRouted, ToRoutingStatus: NotRouted, CompletionTime: t0},
+		{FromTransportStatus: InPort, ToTransportStatus: NotReceived, FromRoutingStatus: NotRouted, ToRoutingStatus: NotRouted},
+		{FromTransportStatus: NotReceived, ToTransportStatus: InPort, FromRoutingStatus: NotRouted, ToRoutingStatus: NotRouted, CompletionTime: t0},
+	}
+
+	if got := c.History(); !reflect.DeepEqual(got, want) {
+		t.Errorf("History() = %v; want = %v", got, want)
+	}
+}
+
+func TestDeliveryAt(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+	)
+
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, AUMEL, t1, t2),
+	}})
+
+	var (
+		receive = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}, CompletionTime: t0}
+		load    = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, CompletionTime: t1}
+		unload  = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Unload, Location: AUMEL, VoyageNumber: "V100"}, CompletionTime: t2}
+	)
+
+	history := HandlingHistory{HandlingEvents: []HandlingEvent{receive, load, unload}}
+
+	c.DeriveDeliveryProgress(history)
+
+	before := c.DeliveryAt(history, t1.Add(-time.Second))
+
+	if before.TransportStatus != InPort {
+		t.Errorf("TransportStatus = %v; want = %v", before.TransportStatus, InPort)
+	}
+	if before.LastEvent != receive {
+		t.Errorf("LastEvent = %v; want = %v", before.LastEvent, receive)
+	}
+
+	// An event completed at the given time is included.
+	after := c.DeliveryAt(history, t1)
+
+	if after.TransportStatus != OnboardCarrier {
+		t.Errorf("TransportStatus = %v; want = %v", after.TransportStatus, OnboardCarrier)
+	}
+	if after.CurrentVoyage != "V100" {
+		t.Errorf("CurrentVoyage = %v; want = %v", after.CurrentVoyage, "V100")
+	}
+	if after.IsUnloadedAtDestination {
+		t.Errorf("IsUnloadedAtDestination = %v; want = %v", after.IsUnloadedAtDestination, false)
+	}
+
+	// The current delivery is left untouched.
+	if !c.Delivery.IsUnloadedAtDestination {
+		t.Errorf("IsUnloadedAtDestination = %v; want = %v", c.Delivery.IsUnloadedAtDestination, true)
+	}
+}
+
+func TestNewRouteSpecification(t *testing.T) {
+	now := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+	clock := ClockFunc(func() time.Time { return now })
+
+	got, err := NewRouteSpecification("sesto", AUMEL, now.Add(time.Nanosecond), clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := RouteSpecification{Origin: SESTO, Destination: AUMEL, ArrivalDeadline: now.Add(time.Nanosecond)}
+	if got != want {
+		t.Errorf("NewRouteSpecification() = %v; want = %v", got, want)
+	}
+
+	tests := []struct {
+		name                string
+		origin, destination UNLocode
+		deadline            time.Time
+		want                error
+	}{
+		{"malformed origin", "SE-TO", AUMEL, now.AddDate(0, 0, 1), ErrInvalidUNLocode},
+		{"malformed destination", SESTO, "", now.AddDate(0, 0, 1), ErrInvalidUNLocode},
+		{"same origin and destination", SESTO, SESTO, now.AddDate(0, 0, 1), ErrSameOriginAndDestination},
+		{"same origin and destination ignoring case", SESTO, "sesto", now.AddDate(0, 0, 1), ErrSameOriginAndDestination},
+		{"deadline now", SESTO, AUMEL, now, ErrDeadlineNotInFuture},
+		{"deadline in the past", SESTO, AUMEL, now.AddDate(0, 0, -1), ErrDeadlineNotInFuture},
+		{"no deadline", SESTO, AUMEL, time.Time{}, ErrDeadlineNotInFuture},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewRouteSpecification(tt.origin, tt.destination, tt.deadline, clock); err != tt.want {
+			t.Errorf("%s: err = %v; want = %v", tt.name, err, tt.want)
+		}
+	}
+}
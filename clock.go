@@ -0,0 +1,20 @@
+package shipping
+
+import "time"
+
+// Clock tells the current time. Services read the time through a clock, so
+// that tests and replays can fix it.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to allow the use of ordinary functions as clocks.
+type ClockFunc func() time.Time
+
+// Now calls f().
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock tells the current time using time.Now.
+var SystemClock Clock = ClockFunc(time.Now)
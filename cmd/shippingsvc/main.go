@@ -4,6 +4,8 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,11 +15,15 @@ import (
 	"github.com/go-kit/kit/log"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	stdgrpc "google.golang.org/grpc"
 	"gopkg.in/mgo.v2"
 
 	shipping "github.com/marcusolsson/goddd"
 	"github.com/marcusolsson/goddd/booking"
+	"github.com/marcusolsson/goddd/grpc"
+	"github.com/marcusolsson/goddd/grpc/pb"
 	"github.com/marcusolsson/goddd/handling"
+	"github.com/marcusolsson/goddd/health"
 	"github.com/marcusolsson/goddd/inmem"
 	"github.com/marcusolsson/goddd/inspection"
 	"github.com/marcusolsson/goddd/mongo"
@@ -41,10 +47,24 @@ func main() {
 		dbname = envString("DB_NAME", defaultDBName)
 
 		httpAddr          = flag.String("http.addr", ":"+addr, "HTTP listen address")
+		grpcAddr          = flag.String("grpc.addr", "", "gRPC listen address (disabled if empty)")
 		routingServiceURL = flag.String("service.routing", rsurl, "routing service URL")
+		routingCacheTTL   = flag.Duration("service.routing.cache", time.Minute, "how long to cache routes (disabled if zero)")
 		mongoDBURL        = flag.String("db.url", dburl, "MongoDB URL")
 		databaseName      = flag.String("db.name", dbname, "MongoDB database name")
 		inmemory          = flag.Bool("inmem", false, "use in-memory repositories")
+		inmemTenants      = flag.Bool("inmem.tenants", false, "partition the in-memory cargos and handling events by the X-Tenant-ID header of HTTP requests")
+		inmemRetention    = flag.Duration("inmem.retention", 0, "how long to keep claimed cargos in the in-memory repository (forever if zero)")
+		handlingRPS       = flag.Int("handling.rps", 0, "handling registrations allowed per second (unlimited if zero)")
+		handlingBurst     = flag.Int("handling.burst", 100, "handling registrations allowed in a burst")
+		shutdownTimeout   = flag.Duration("shutdown.timeout", 10*time.Second, "how long to wait for in-flight work on shutdown")
+		inspectInterval   = flag.Duration("inspection.interval", 0, "how often to re-inspect cargos in transit (disabled if zero)")
+		inspectWorkers    = flag.Int("inspection.workers", 4, "number of workers inspecting handled cargos in the background (inspected on registration if zero)")
+		sampleCargos      = flag.Int("sample.cargos", 0, "number of generated sample cargos to store on startup")
+		sampleSeed        = flag.Int64("sample.seed", 0, "seed of the generated sample cargos (random if zero)")
+		sampleTime        = flag.String("sample.time", "2016-01-01T00:00:00Z", "RFC 3339 time the generated sample cargos are booked and handled around")
+		outboxInterval    = flag.Duration("handling.outbox", 0, "how often to relay handling events from the outbox, if the backend has one (disabled if zero)")
+		bookingLeadTime   = flag.Duration("booking.leadtime", 0, "arrival deadline from now of cargos booked without one (rejected if zero)")
 
 		ctx = context.Background()
 	)
@@ -61,6 +81,17 @@ func main() {
 		locations      shipping.LocationRepository
 		voyages        shipping.VoyageRepository
 		handlingEvents shipping.HandlingEventRepository
+
+		// inspectionOffsets records how far the background inspections have
+		// come through the handling events.
+		inspectionOffsets handling.OffsetStore
+
+		// closers are closed in reverse order of creation on shutdown, so
+		// that components are closed before their dependencies.
+		closers []shipping.Closer
+
+		// bookingOpts are the booking options that depend on the backend.
+		bookingOpts []booking.Option
 	)
 
 	if *inmemory {
@@ -68,19 +99,71 @@ func main() {
 		locations = inmem.NewLocationRepository()
 		voyages = inmem.NewVoyageRepository()
 		handlingEvents = inmem.NewHandlingEventRepository()
+		inspectionOffsets = handling.NewMemoryOffsetStore()
+
+		if *inmemTenants {
+			if *inmemRetention > 0 {
+				panic("inmem.retention is not supported with inmem.tenants")
+			}
+			cargos = inmem.NewTenantCargoRepository()
+			handlingEvents = inmem.NewTenantHandlingEventRepository()
+		}
+
+		bookingOpts = append(bookingOpts, booking.WithTrackingIDReserver(inmem.NewTrackingIDReserver(cargos)))
+
+		if *inmemRetention > 0 {
+			evictor, err := inmem.NewCargoEvictor(cargos, *inmemRetention, nil)
+			if err != nil {
+				panic(err)
+			}
+
+			evictorCtx, stopEvictor := context.WithCancel(ctx)
+			closers = append(closers, shipping.CloserFunc(func(context.Context) error {
+				stopEvictor()
+				return nil
+			}))
+			go evictor.Run(evictorCtx, time.Hour, shipping.SystemClock)
+		}
 	} else {
 		session, err := mgo.Dial(*mongoDBURL)
 		if err != nil {
 			panic(err)
 		}
-		defer session.Close()
+		closers = append(closers, shipping.CloserFunc(func(context.Context) error {
+			session.Close()
+			return nil
+		}))
 
 		session.SetMode(mgo.Monotonic, true)
 
 		cargos, _ = mongo.NewCargoRepository(*databaseName, session)
 		locations, _ = mongo.NewLocationRepository(*databaseName, session)
 		voyages, _ = mongo.NewVoyageRepository(*databaseName, session)
-		handlingEvents = mongo.NewHandlingEventRepository(*databaseName, session)
+		handlingEvents, _ = mongo.NewHandlingEventRepository(*databaseName, "handling_event", session)
+		inspectionOffsets = mongo.NewOffsetStore(*databaseName, "inspection", session)
+	}
+
+	var (
+		inspectionEventHandler = inspection.NewLoggingEventHandler(log.With(logger, "component", "inspection"))
+		inspectionService      = inspection.NewService(cargos, handlingEvents, inspectionEventHandler)
+	)
+
+	var inspectionHandler handling.EventHandler = handling.NewEventHandler(inspectionService)
+	// Background inspections, and their offsets, are not scoped to a tenant,
+	// so tenants are inspected on registration.
+	if *inspectWorkers > 0 && !*inmemTenants {
+		async := handling.NewAsyncEventHandler(
+			inspectionService,
+			*inspectWorkers, 64,
+			handling.WithOffsetStore(inspectionOffsets, handlingEvents),
+		)
+		closers = append(closers, shipping.CloserFunc(async.Close))
+
+		// Inspect the cargos handled while the service was down.
+		if err := async.Resume(ctx); err != nil {
+			panic(err)
+		}
+		inspectionHandler = async
 	}
 
 	// Configure some questionable dependencies.
@@ -90,21 +173,70 @@ func main() {
 			VoyageRepository:   voyages,
 			LocationRepository: locations,
 		}
-		handlingEventHandler = handling.NewEventHandler(
-			inspection.NewService(cargos, handlingEvents, nil),
+		handlingEventStream  = handling.NewEventStream(16)
+		handlingEventHandler = handling.NewMultiEventHandler(
+			log.With(logger, "component", "handling"),
+			inspectionHandler,
+			handlingEventStream,
 		)
 	)
 
+	if oh, ok := inspectionEventHandler.(inspection.OverdueHandler); ok && *inspectInterval > 0 {
+		scheduler := inspection.NewScheduler(cargos, oh, *inspectInterval)
+
+		schedulerCtx, stopScheduler := context.WithCancel(ctx)
+		closers = append(closers, shipping.CloserFunc(func(context.Context) error {
+			stopScheduler()
+			return nil
+		}))
+		go scheduler.Run(schedulerCtx)
+	}
+
 	// Facilitate testing by adding some cargos.
-	storeTestData(cargos)
+	storeTestData(ctx, cargos)
+
+	if *sampleCargos > 0 {
+		seed := *sampleSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		at, err := time.Parse(time.RFC3339, *sampleTime)
+		if err != nil {
+			panic(err)
+		}
+		logger.Log("msg", "storing sample cargos", "count", *sampleCargos, "seed", seed, "time", at)
+		if err := shipping.SeedSampleCargos(ctx, cargos, handlingEvents, rand.NewSource(seed), at, *sampleCargos); err != nil {
+			panic(err)
+		}
+	}
 
 	fieldKeys := []string{"method"}
 
+	breaker := routing.NewCircuitBreaker(5, 30*time.Second)
+	stdprometheus.MustRegister(stdprometheus.NewGaugeFunc(stdprometheus.GaugeOpts{
+		Namespace: "api",
+		Subsystem: "routing_service",
+		Name:      "circuit_breaker_state",
+		Help:      "State of the circuit breaker: 0 closed, 1 open, 2 half-open.",
+	}, func() float64 {
+		return float64(breaker.State())
+	}))
+
 	var rs shipping.RoutingService
-	rs = routing.NewProxyingMiddleware(ctx, *routingServiceURL)(rs)
+	rs = routing.NewProxyingMiddleware(ctx, *routingServiceURL,
+		routing.Retry(3, 100*time.Millisecond),
+		breaker.Middleware(),
+	)(rs)
+	if *routingCacheTTL > 0 {
+		rs = routing.NewCachingMiddleware(*routingCacheTTL)(rs)
+	}
+
+	if *bookingLeadTime > 0 {
+		bookingOpts = append(bookingOpts, booking.WithDefaultDeadline(booking.FixedLeadTime(*bookingLeadTime)))
+	}
 
 	var bs booking.Service
-	bs = booking.NewService(cargos, locations, handlingEvents, rs)
+	bs = booking.NewService(cargos, locations, handlingEvents, rs, append(bookingOpts, booking.WithVoyageRepository(voyages))...)
 	bs = booking.NewLoggingService(log.With(logger, "component", "booking"), bs)
 	bs = booking.NewInstrumentingService(
 		kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
@@ -123,7 +255,7 @@ func main() {
 	)
 
 	var ts tracking.Service
-	ts = tracking.NewService(cargos, handlingEvents)
+	ts = tracking.NewService(cargos, handlingEvents, tracking.WithVoyageRepository(voyages))
 	ts = tracking.NewLoggingService(log.With(logger, "component", "tracking"), ts)
 	ts = tracking.NewInstrumentingService(
 		kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
@@ -141,16 +273,33 @@ func main() {
 		ts,
 	)
 
+	var handlingOpts []handling.Option
+	if outbox, ok := handlingEvents.(shipping.HandlingEventOutbox); ok && *outboxInterval > 0 {
+		relay := handling.NewRelay(outbox, handlingEventHandler, *outboxInterval, log.With(logger, "component", "relay"))
+
+		relayCtx, stopRelay := context.WithCancel(ctx)
+		closers = append(closers, shipping.CloserFunc(func(context.Context) error {
+			stopRelay()
+			return nil
+		}))
+		go relay.Run(relayCtx)
+
+		handlingOpts = append(handlingOpts, handling.WithOutbox(outbox))
+	}
+
 	var hs handling.Service
-	hs = handling.NewService(handlingEvents, handlingEventFactory, handlingEventHandler)
+	hs = handling.NewService(handlingEvents, handlingEventFactory, handlingEventHandler, handlingOpts...)
+	if *handlingRPS > 0 {
+		hs = handling.RateLimitMiddleware(hs, *handlingRPS, *handlingBurst)
+	}
 	hs = handling.NewLoggingService(log.With(logger, "component", "handling"), hs)
 	hs = handling.NewInstrumentingService(
 		kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
 			Namespace: "api",
 			Subsystem: "handling_service",
 			Name:      "request_count",
-			Help:      "Number of requests received.",
-		}, fieldKeys),
+			Help:      "Number of handling events registered.",
+		}, []string{"method", "event_type"}),
 		kitprometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
 			Namespace: "api",
 			Subsystem: "handling_service",
@@ -160,13 +309,63 @@ func main() {
 		hs,
 	)
 
-	srv := server.New(bs, ts, hs, log.With(logger, "component", "http"))
+	var serverOpts []server.Option
+	if !*inmemTenants {
+		// The stream carries the events of all tenants.
+		serverOpts = append(serverOpts, server.WithEventStream(handlingEventStream))
+	}
+
+	srv := server.New(bs, ts, hs, log.With(logger, "component", "http"), serverOpts...)
+
+	checker := health.NewChecker(
+		health.Component{Name: "routing", Check: health.URLCheck(http.DefaultClient, *routingServiceURL)},
+		health.Component{Name: "cargos", Check: health.CargoRepositoryCheck(cargos)},
+	)
 
-	errs := make(chan error, 2)
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", health.NewHandler(checker))
+	mux.Handle("/", srv)
+
+	httpServer := &http.Server{Addr: *httpAddr, Handler: mux}
+	closers = append(closers, shipping.CloserFunc(httpServer.Shutdown))
+
+	// The event streams are ended before the HTTP server is shut down, as
+	// the server waits for them to finish.
+	closers = append(closers, handlingEventStream)
+
+	errs := make(chan error, 3)
 	go func() {
 		logger.Log("transport", "http", "address", *httpAddr, "msg", "listening")
-		errs <- http.ListenAndServe(*httpAddr, srv)
+		errs <- httpServer.ListenAndServe()
 	}()
+	if *grpcAddr != "" {
+		gs := stdgrpc.NewServer()
+		pb.RegisterHandlingServer(gs, grpc.NewHandlingServer(hs))
+		closers = append(closers, shipping.CloserFunc(func(ctx context.Context) error {
+			done := make(chan struct{})
+			go func() {
+				gs.GracefulStop()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				gs.Stop()
+				return ctx.Err()
+			}
+		}))
+
+		go func() {
+			lis, err := net.Listen("tcp", *grpcAddr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			logger.Log("transport", "grpc", "address", *grpcAddr, "msg", "listening")
+			errs <- gs.Serve(lis)
+		}()
+	}
 	go func() {
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT)
@@ -174,6 +373,17 @@ func main() {
 	}()
 
 	logger.Log("terminated", <-errs)
+
+	for i, j := 0, len(closers)-1; i < j; i, j = i+1, j-1 {
+		closers[i], closers[j] = closers[j], closers[i]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *shutdownTimeout)
+	defer cancel()
+
+	if err := shipping.Shutdown(ctx, closers...); err != nil {
+		logger.Log("msg", "shutdown", "err", err)
+	}
 }
 
 func envString(env, fallback string) string {
@@ -184,22 +394,22 @@ func envString(env, fallback string) string {
 	return e
 }
 
-func storeTestData(r shipping.CargoRepository) {
-	test1 := shipping.NewCargo("FTL456", shipping.RouteSpecification{
-		Origin:          shipping.AUMEL,
-		Destination:     shipping.SESTO,
-		ArrivalDeadline: time.Now().AddDate(0, 0, 7),
-	})
-	if err := r.Store(test1); err != nil {
+func storeTestData(ctx context.Context, r shipping.CargoRepository) {
+	rs1, err := shipping.NewRouteSpecification(shipping.AUMEL, shipping.SESTO, time.Now().AddDate(0, 0, 7), shipping.SystemClock)
+	if err != nil {
+		panic(err)
+	}
+	test1 := shipping.NewCargo("FTL456", rs1)
+	if err := r.Store(ctx, test1); err != nil {
 		panic(err)
 	}
 
-	test2 := shipping.NewCargo("ABC123", shipping.RouteSpecification{
-		Origin:          shipping.SESTO,
-		Destination:     shipping.CNHKG,
-		ArrivalDeadline: time.Now().AddDate(0, 0, 14),
-	})
-	if err := r.Store(test2); err != nil {
+	rs2, err := shipping.NewRouteSpecification(shipping.SESTO, shipping.CNHKG, time.Now().AddDate(0, 0, 14), shipping.SystemClock)
+	if err != nil {
+		panic(err)
+	}
+	test2 := shipping.NewCargo("ABC123", rs2)
+	if err := r.Store(ctx, test2); err != nil {
 		panic(err)
 	}
 }
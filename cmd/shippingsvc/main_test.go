@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -20,6 +21,8 @@ type S struct{}
 var _ = Suite(&S{})
 
 func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
+	ctx := context.Background()
+
 	var err error
 
 	var (
@@ -42,7 +45,7 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	handlingEventHandler := &stubHandlingEventHandler{cargoInspectionService}
 
 	var (
-		bookingService       = booking.NewService(cargoRepository, locationRepository, handlingEventRepository, routingService)
+		bookingService       = booking.NewService(cargoRepository, locationRepository, handlingEventRepository, routingService, booking.WithClock(shipping.ClockFunc(func() time.Time { return toDate(2009, time.March, 1) })))
 		handlingEventService = handling.NewService(handlingEventRepository, handlingEventFactory, handlingEventHandler)
 	)
 
@@ -56,11 +59,11 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	// Use case 1: booking
 	//
 
-	id, err := bookingService.BookNewCargo(origin, destination, deadline)
+	id, err := bookingService.BookNewCargo(ctx, origin, destination, deadline)
 
 	chk.Assert(err, IsNil)
 
-	c, err := cargoRepository.Find(id)
+	c, err := cargoRepository.Find(ctx, id)
 
 	chk.Assert(err, IsNil)
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.NotReceived)
@@ -74,12 +77,12 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	// Use case 2: routing
 	//
 
-	itineraries := bookingService.RequestPossibleRoutesForCargo(id)
+	itineraries := bookingService.RequestPossibleRoutesForCargo(ctx, id)
 	itinerary := selectPreferredItinerary(itineraries)
 
 	c.AssignToRoute(itinerary)
 
-	cargoRepository.Store(c)
+	cargoRepository.Store(ctx, c)
 
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.NotReceived)
 	chk.Check(c.Delivery.RoutingStatus, Equals, shipping.Routed)
@@ -92,20 +95,20 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	// Use case 3: handling
 	//
 
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 1), id, "", shipping.CNHKG, shipping.Receive)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 1), id, "", shipping.CNHKG, shipping.Receive)
 	chk.Check(err, IsNil)
 
 	// Ensure we're not working with stale shipping.
-	c, err = cargoRepository.Find(id)
+	c, err = cargoRepository.Find(ctx, id)
 
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.InPort)
 	chk.Check(c.Delivery.LastKnownLocation, Equals, shipping.CNHKG)
 	chk.Check(c.Delivery.Itinerary.IsEmpty(), Equals, false)
 
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 3), id, shipping.V100.VoyageNumber, shipping.CNHKG, shipping.Load)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 3), id, shipping.V100.VoyageNumber, shipping.CNHKG, shipping.Load)
 	chk.Check(err, IsNil)
 
-	c, err = cargoRepository.Find(id)
+	c, err = cargoRepository.Find(ctx, id)
 
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.OnboardCarrier)
 	chk.Check(c.Delivery.LastKnownLocation, Equals, shipping.CNHKG)
@@ -122,17 +125,17 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 
 	noSuchVoyageNumber := shipping.VoyageNumber("XX000")
 	noSuchUNLocode := shipping.UNLocode("ZZZZZ")
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 5), id, noSuchVoyageNumber, noSuchUNLocode, shipping.Load)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 5), id, noSuchVoyageNumber, noSuchUNLocode, shipping.Load)
 	chk.Check(err, NotNil)
 
 	//
 	// Cargo is incorrectly unloaded in Tokyo
 	//
 
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 5), id, shipping.V100.VoyageNumber, shipping.JNTKO, shipping.Unload)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 5), id, shipping.V100.VoyageNumber, shipping.JNTKO, shipping.Unload)
 	chk.Check(err, IsNil)
 
-	c, err = cargoRepository.Find(id)
+	c, err = cargoRepository.Find(ctx, id)
 
 	chk.Check(c.Delivery.LastKnownLocation, Equals, shipping.JNTKO)
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.InPort)
@@ -156,18 +159,18 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	// Specify a new route, this time from Tokyo (where it was incorrectly unloaded) to Stockholm
 	c.SpecifyNewRoute(rs)
 
-	cargoRepository.Store(c)
+	cargoRepository.Store(ctx, c)
 
 	chk.Check(c.Delivery.RoutingStatus, Equals, shipping.Misrouted)
 	chk.Check(c.Delivery.NextExpectedActivity, Equals, shipping.HandlingActivity{})
 
 	// Repeat procedure of selecting one out of a number of possible routes satisfying the route spec
-	newItineraries := bookingService.RequestPossibleRoutesForCargo(id)
+	newItineraries := bookingService.RequestPossibleRoutesForCargo(ctx, id)
 	newItinerary := selectPreferredItinerary(newItineraries)
 
 	c.AssignToRoute(newItinerary)
 
-	cargoRepository.Store(c)
+	cargoRepository.Store(ctx, c)
 
 	chk.Check(c.Delivery.RoutingStatus, Equals, shipping.Routed)
 
@@ -176,10 +179,10 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	//
 
 	// Load in Tokyo
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 8), id, shipping.V300.VoyageNumber, shipping.JNTKO, shipping.Load)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 8), id, shipping.V300.VoyageNumber, shipping.JNTKO, shipping.Load)
 	chk.Check(err, IsNil)
 
-	c, err = cargoRepository.Find(id)
+	c, err = cargoRepository.Find(ctx, id)
 
 	chk.Check(c.Delivery.LastKnownLocation, Equals, shipping.JNTKO)
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.OnboardCarrier)
@@ -188,10 +191,10 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	chk.Check(c.Delivery.NextExpectedActivity, Equals, shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.DEHAM, VoyageNumber: shipping.V300.VoyageNumber})
 
 	// Unload in Hamburg
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 12), id, shipping.V300.VoyageNumber, shipping.DEHAM, shipping.Unload)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 12), id, shipping.V300.VoyageNumber, shipping.DEHAM, shipping.Unload)
 	chk.Check(err, IsNil)
 
-	c, err = cargoRepository.Find(id)
+	c, err = cargoRepository.Find(ctx, id)
 
 	chk.Check(c.Delivery.LastKnownLocation, Equals, shipping.DEHAM)
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.InPort)
@@ -200,10 +203,10 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	chk.Check(c.Delivery.NextExpectedActivity, Equals, shipping.HandlingActivity{Type: shipping.Load, Location: shipping.DEHAM, VoyageNumber: shipping.V400.VoyageNumber})
 
 	// Load in Hamburg
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 14), id, shipping.V400.VoyageNumber, shipping.DEHAM, shipping.Load)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 14), id, shipping.V400.VoyageNumber, shipping.DEHAM, shipping.Load)
 	chk.Check(err, IsNil)
 
-	c, err = cargoRepository.Find(id)
+	c, err = cargoRepository.Find(ctx, id)
 
 	chk.Check(c.Delivery.LastKnownLocation, Equals, shipping.DEHAM)
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.OnboardCarrier)
@@ -212,10 +215,10 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	chk.Check(c.Delivery.NextExpectedActivity, Equals, shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.SESTO, VoyageNumber: shipping.V400.VoyageNumber})
 
 	// Unload in Stockholm
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 15), id, shipping.V400.VoyageNumber, shipping.SESTO, shipping.Unload)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 15), id, shipping.V400.VoyageNumber, shipping.SESTO, shipping.Unload)
 	chk.Check(err, IsNil)
 
-	c, err = cargoRepository.Find(id)
+	c, err = cargoRepository.Find(ctx, id)
 
 	chk.Check(c.Delivery.LastKnownLocation, Equals, shipping.SESTO)
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.InPort)
@@ -224,10 +227,10 @@ func (s *S) TestCargoFromHongkongToStockholm(chk *C) {
 	chk.Check(c.Delivery.NextExpectedActivity, Equals, shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.SESTO})
 
 	// Finally, cargo is claimed in Stockholm. This ends the cargo lifecycle from our perspective.
-	err = handlingEventService.RegisterHandlingEvent(toDate(2009, time.March, 16), id, shipping.V400.VoyageNumber, shipping.SESTO, shipping.Claim)
+	_, err = handlingEventService.RegisterHandlingEvent(ctx, toDate(2009, time.March, 16), id, shipping.V400.VoyageNumber, shipping.SESTO, shipping.Claim)
 	chk.Check(err, IsNil)
 
-	c, _ = cargoRepository.Find(id)
+	c, _ = cargoRepository.Find(ctx, id)
 
 	chk.Check(c.Delivery.LastKnownLocation, Equals, shipping.SESTO)
 	chk.Check(c.Delivery.TransportStatus, Equals, shipping.Claimed)
@@ -247,7 +250,7 @@ func toDate(year int, month time.Month, day int) time.Time {
 // Stub RoutingService
 type stubRoutingService struct{}
 
-func (s *stubRoutingService) FetchRoutesForSpecification(rs shipping.RouteSpecification) []shipping.Itinerary {
+func (s *stubRoutingService) FetchRoutesForSpecification(ctx context.Context, rs shipping.RouteSpecification) []shipping.Itinerary {
 	if rs.Origin == shipping.CNHKG {
 		return []shipping.Itinerary{
 			{Legs: []shipping.Leg{
@@ -271,16 +274,16 @@ type stubHandlingEventHandler struct {
 	InspectionService inspection.Service
 }
 
-func (h *stubHandlingEventHandler) CargoWasHandled(event shipping.HandlingEvent) {
-	h.InspectionService.InspectCargo(event.TrackingID)
+func (h *stubHandlingEventHandler) CargoWasHandled(ctx context.Context, event shipping.HandlingEvent) {
+	h.InspectionService.InspectCargo(ctx, event.TrackingID)
 }
 
 // Stub CargoEventHandler
 type stubCargoEventHandler struct {
 }
 
-func (h *stubCargoEventHandler) CargoWasMisdirected(c *shipping.Cargo) {
+func (h *stubCargoEventHandler) CargoWasMisdirected(ctx context.Context, id shipping.TrackingID, lastKnownLocation shipping.UNLocode) {
 }
 
-func (h *stubCargoEventHandler) CargoHasArrived(c *shipping.Cargo) {
+func (h *stubCargoEventHandler) CargoHasArrived(ctx context.Context, id shipping.TrackingID) {
 }
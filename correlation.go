@@ -0,0 +1,34 @@
+package shipping
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// CorrelationID ties together the work done on behalf of a single request,
+// such as the registration of a handling event and the inspection and
+// notifications that follow it, so that it can be traced through the logs.
+type CorrelationID string
+
+type correlationKey struct{}
+
+// NewCorrelationID returns a random correlation ID.
+func NewCorrelationID() CorrelationID {
+	var b [8]byte
+	rand.Read(b[:])
+	return CorrelationID(hex.EncodeToString(b[:]))
+}
+
+// NewCorrelationContext returns a copy of ctx that carries the given
+// correlation ID.
+func NewCorrelationContext(ctx context.Context, id CorrelationID) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationFromContext returns the correlation ID carried by the context,
+// or the empty ID if it carries none.
+func CorrelationFromContext(ctx context.Context) CorrelationID {
+	id, _ := ctx.Value(correlationKey{}).(CorrelationID)
+	return id
+}
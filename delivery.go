@@ -6,11 +6,17 @@ import (
 
 // Delivery is the actual transportation of the cargo, as opposed to the
 // customer requirement (RouteSpecification) and the plan (Itinerary).
+//
+// LastKnownLocation is where the cargo was last handled, or the origin of the
+// route specification if it has not been handled yet. CustomsStatus is
+// derived from the complete handling history, and is kept as is when only
+// the routing changes.
 type Delivery struct {
 	Itinerary               Itinerary
 	RouteSpecification      RouteSpecification
 	RoutingStatus           RoutingStatus
 	TransportStatus         TransportStatus
+	CustomsStatus           CustomsStatus
 	NextExpectedActivity    HandlingActivity
 	LastEvent               HandlingEvent
 	LastKnownLocation       UNLocode
@@ -18,13 +24,55 @@ type Delivery struct {
 	ETA                     time.Time
 	IsMisdirected           bool
 	IsUnloadedAtDestination bool
+
+	// UnloadedAtDestinationTime is when the cargo was last unloaded at its
+	// destination, or the zero time if it has not been, or has been loaded
+	// again since.
+	UnloadedAtDestinationTime time.Time
+}
+
+// DeliveryChange is a domain event describing a change of the routing or
+// transport status of a delivery. It is either a RoutingStatusChanged or a
+// TransportStatusChanged.
+type DeliveryChange interface {
+	deliveryChange()
+}
+
+// RoutingStatusChanged is the DeliveryChange of a routing status.
+type RoutingStatusChanged struct {
+	From, To RoutingStatus
+}
+
+// TransportStatusChanged is the DeliveryChange of a transport status.
+type TransportStatusChanged struct {
+	From, To TransportStatus
+}
+
+func (RoutingStatusChanged) deliveryChange()   {}
+func (TransportStatusChanged) deliveryChange() {}
+
+// changesTo returns the status changes from d to next, if any.
+func (d Delivery) changesTo(next Delivery) []DeliveryChange {
+	var changes []DeliveryChange
+	if d.RoutingStatus != next.RoutingStatus {
+		changes = append(changes, RoutingStatusChanged{From: d.RoutingStatus, To: next.RoutingStatus})
+	}
+	if d.TransportStatus != next.TransportStatus {
+		changes = append(changes, TransportStatusChanged{From: d.TransportStatus, To: next.TransportStatus})
+	}
+	return changes
 }
 
 // UpdateOnRouting creates a new delivery snapshot to reflect changes in
 // routing, i.e. when the route specification or the itinerary has changed but
 // no additional handling of the cargo has been performed.
 func (d Delivery) UpdateOnRouting(rs RouteSpecification, itinerary Itinerary) Delivery {
-	return newDelivery(d.LastEvent, itinerary, rs)
+	next := newDelivery(d.LastEvent, itinerary, rs)
+	next.CustomsStatus = d.CustomsStatus
+	if rs.Destination == d.RouteSpecification.Destination {
+		next.UnloadedAtDestinationTime = d.UnloadedAtDestinationTime
+	}
+	return next
 }
 
 // IsOnTrack checks if the delivery is on track.
@@ -32,12 +80,71 @@ func (d Delivery) IsOnTrack() bool {
 	return d.RoutingStatus == Routed && !d.IsMisdirected
 }
 
+// ProgressPercent estimates how far the cargo has come, as the percentage of
+// the legs of its itinerary that it has been unloaded from: 0 when just
+// received, and 100 once unloaded at the destination or claimed. The
+// progress of a misdirected cargo is estimated from the voyage it was last
+// handled on, or else from its last known location.
+func (d Delivery) ProgressPercent() int {
+	if d.TransportStatus == Claimed || d.IsUnloadedAtDestination {
+		return 100
+	}
+	legs := d.Itinerary.Legs
+	if len(legs) == 0 || d.TransportStatus == NotReceived {
+		return 0
+	}
+	return 100 * d.legsUnloaded() / len(legs)
+}
+
+// legsUnloaded returns the number of legs of the itinerary that the cargo has
+// been unloaded from, judging by its last event.
+func (d Delivery) legsUnloaded() int {
+	var (
+		legs     = d.Itinerary.Legs
+		activity = d.LastEvent.Activity
+	)
+
+	switch activity.Type {
+	case Receive:
+		return 0
+	case Load:
+		for i, l := range legs {
+			if l.VoyageNumber == activity.VoyageNumber && l.LoadLocation == activity.Location {
+				return i
+			}
+		}
+	case Unload:
+		for i := len(legs) - 1; i >= 0; i-- {
+			if legs[i].VoyageNumber == activity.VoyageNumber && legs[i].UnloadLocation == activity.Location {
+				return i + 1
+			}
+		}
+		// Unloaded at the wrong location, but at least off the voyage.
+		for i := len(legs) - 1; i >= 0; i-- {
+			if legs[i].VoyageNumber == activity.VoyageNumber {
+				return i + 1
+			}
+		}
+	}
+
+	for i := len(legs) - 1; i >= 0; i-- {
+		if legs[i].UnloadLocation == d.LastKnownLocation {
+			return i + 1
+		}
+	}
+	return 0
+}
+
 // DeriveDeliveryFrom creates a new delivery snapshot based on the complete
 // handling history of a cargo, as well as its route specification and
-// itinerary.
+// itinerary. Superseded events are ignored.
 func DeriveDeliveryFrom(rs RouteSpecification, itinerary Itinerary, history HandlingHistory) Delivery {
+	history = history.Current()
 	lastEvent, _ := history.MostRecentlyCompletedEvent()
-	return newDelivery(lastEvent, itinerary, rs)
+	d := newDelivery(lastEvent, itinerary, rs)
+	d.CustomsStatus = calculateCustomsStatus(history)
+	d.UnloadedAtDestinationTime = calculateUnloadedAtDestinationTime(history, rs)
+	return d
 }
 
 // newDelivery creates a up-to-date delivery based on an handling event,
@@ -46,8 +153,8 @@ func newDelivery(lastEvent HandlingEvent, itinerary Itinerary, rs RouteSpecifica
 	var (
 		routingStatus           = calculateRoutingStatus(itinerary, rs)
 		transportStatus         = calculateTransportStatus(lastEvent)
-		lastKnownLocation       = calculateLastKnownLocation(lastEvent)
-		isMisdirected           = calculateMisdirectedStatus(lastEvent, itinerary)
+		lastKnownLocation       = calculateLastKnownLocation(lastEvent, rs)
+		isMisdirected           = calculateMisdirectedStatus(lastEvent, itinerary, rs)
 		isUnloadedAtDestination = calculateUnloadedAtDestination(lastEvent, rs)
 		currentVoyage           = calculateCurrentVoyage(transportStatus, lastEvent)
 	)
@@ -72,23 +179,48 @@ func newDelivery(lastEvent HandlingEvent, itinerary Itinerary, rs RouteSpecifica
 
 // Below are internal functions used when creating a new delivery.
 
+// calculateRoutingStatus ignores the arrival deadline, so that a routed cargo
+// that is delayed past its deadline stays routed and is reported as overdue.
 func calculateRoutingStatus(itinerary Itinerary, rs RouteSpecification) RoutingStatus {
 	if itinerary.Legs == nil {
 		return NotRouted
 	}
 
-	if rs.IsSatisfiedBy(itinerary) {
+	if rs.connects(itinerary) {
 		return Routed
 	}
 
+	// A partial itinerary is not misrouted for falling short of the
+	// destination.
+	if rs.startsPartially(itinerary) {
+		return PartiallyRouted
+	}
+
 	return Misrouted
 }
 
-func calculateMisdirectedStatus(event HandlingEvent, itinerary Itinerary) bool {
+// isRerouted checks whether the cargo was unloaded off a previous voyage
+// where both its new route and itinerary depart from, which is where a
+// reroute picks up a cargo that was unloaded off course.
+func isRerouted(event HandlingEvent, itinerary Itinerary, rs RouteSpecification) bool {
+	if event.Activity.Type != Unload || itinerary.IsEmpty() {
+		return false
+	}
+	return event.Activity.Location == rs.Origin &&
+		event.Activity.Location == itinerary.InitialDepartureLocation() &&
+		!itinerary.IsOnVoyage(event.Activity.VoyageNumber)
+}
+
+// calculateMisdirectedStatus does not flag a rerouted cargo.
+func calculateMisdirectedStatus(event HandlingEvent, itinerary Itinerary, rs RouteSpecification) bool {
 	if event.Activity.Type == NotHandled {
 		return false
 	}
 
+	if isRerouted(event, itinerary, rs) {
+		return false
+	}
+
 	return !itinerary.IsExpected(event)
 }
 
@@ -100,6 +232,40 @@ func calculateUnloadedAtDestination(event HandlingEvent, rs RouteSpecification)
 	return event.Activity.Type == Unload && rs.Destination == event.Activity.Location
 }
 
+// calculateUnloadedAtDestinationTime walks the history like
+// calculateCustomsStatus. Only a load takes the cargo away from its
+// destination; other events, such as customs, leave it there.
+func calculateUnloadedAtDestinationTime(history HandlingHistory, rs RouteSpecification) time.Time {
+	var t time.Time
+	for _, e := range history.HandlingEvents {
+		switch {
+		case e.Activity.Type == Unload && e.Activity.Location == rs.Destination:
+			t = e.CompletionTime
+		case e.Activity.Type == Load:
+			t = time.Time{}
+		}
+	}
+	return t
+}
+
+// calculateCustomsStatus walks the history in the same order as
+// MostRecentlyCompletedEvent, so that the status agrees with the outcome of
+// the last customs event. Other events leave the status as is.
+func calculateCustomsStatus(history HandlingHistory) CustomsStatus {
+	status := NotCleared
+	for _, e := range history.HandlingEvents {
+		if e.Activity.Type != Customs {
+			continue
+		}
+		if e.CustomsCleared {
+			status = Cleared
+		} else {
+			status = InCustoms
+		}
+	}
+	return status
+}
+
 func calculateTransportStatus(event HandlingEvent) TransportStatus {
 	switch event.Activity.Type {
 	case NotHandled:
@@ -118,10 +284,16 @@ func calculateTransportStatus(event HandlingEvent) TransportStatus {
 	return Unknown
 }
 
-func calculateLastKnownLocation(event HandlingEvent) UNLocode {
+func calculateLastKnownLocation(event HandlingEvent, rs RouteSpecification) UNLocode {
+	if event.Activity.Type == NotHandled {
+		return rs.Origin
+	}
 	return event.Activity.Location
 }
 
+// calculateNextExpectedActivity predicts the next handling of the cargo from
+// its last event and itinerary. There is no expected activity when the cargo
+// has been claimed, or is not on track.
 func calculateNextExpectedActivity(d Delivery) HandlingActivity {
 	if !d.IsOnTrack() {
 		return HandlingActivity{}
@@ -135,13 +307,15 @@ func calculateNextExpectedActivity(d Delivery) HandlingActivity {
 		return HandlingActivity{Type: Load, Location: l.LoadLocation, VoyageNumber: l.VoyageNumber}
 	case Load:
 		for _, l := range d.Itinerary.Legs {
-			if l.LoadLocation == d.LastEvent.Activity.Location {
+			if l.LoadLocation == d.LastEvent.Activity.Location && l.VoyageNumber == d.LastEvent.Activity.VoyageNumber {
 				return HandlingActivity{Type: Unload, Location: l.UnloadLocation, VoyageNumber: l.VoyageNumber}
 			}
 		}
 	case Unload:
+		// The voyage tells apart the legs of an itinerary that calls at a
+		// transshipment hub more than once.
 		for i, l := range d.Itinerary.Legs {
-			if l.UnloadLocation == d.LastEvent.Activity.Location {
+			if l.UnloadLocation == d.LastEvent.Activity.Location && l.VoyageNumber == d.LastEvent.Activity.VoyageNumber {
 				if i < len(d.Itinerary.Legs)-1 {
 					return HandlingActivity{Type: Load, Location: d.Itinerary.Legs[i+1].LoadLocation, VoyageNumber: d.Itinerary.Legs[i+1].VoyageNumber}
 				}
@@ -149,6 +323,12 @@ func calculateNextExpectedActivity(d Delivery) HandlingActivity {
 				return HandlingActivity{Type: Claim, Location: l.UnloadLocation}
 			}
 		}
+
+		// A rerouted cargo waits where it was unloaded for the first leg.
+		if isRerouted(d.LastEvent, d.Itinerary, d.RouteSpecification) {
+			l := d.Itinerary.Legs[0]
+			return HandlingActivity{Type: Load, Location: l.LoadLocation, VoyageNumber: l.VoyageNumber}
+		}
 	}
 
 	return HandlingActivity{}
@@ -0,0 +1,318 @@
+package shipping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextExpectedActivity(t *testing.T) {
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: DEHAM},
+		{VoyageNumber: "V200", LoadLocation: DEHAM, UnloadLocation: AUMEL},
+	}})
+
+	// Events complete an hour apart in the order they are listed.
+	var n int
+	event := func(typ HandlingEventType, loc UNLocode, voyage VoyageNumber) HandlingEvent {
+		n++
+		return HandlingEvent{
+			TrackingID:     "ABC",
+			Activity:       HandlingActivity{Type: typ, Location: loc, VoyageNumber: voyage},
+			CompletionTime: time.Date(2016, 1, 1, n, 0, 0, 0, time.UTC),
+		}
+	}
+
+	tests := []struct {
+		event HandlingEvent
+		want  HandlingActivity
+	}{
+		{event(Receive, SESTO, ""), HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}},
+		{event(Load, SESTO, "V100"), HandlingActivity{Type: Unload, Location: DEHAM, VoyageNumber: "V100"}},
+		{event(Unload, DEHAM, "V100"), HandlingActivity{Type: Load, Location: DEHAM, VoyageNumber: "V200"}},
+		{event(Load, DEHAM, "V200"), HandlingActivity{Type: Unload, Location: AUMEL, VoyageNumber: "V200"}},
+		{event(Unload, AUMEL, "V200"), HandlingActivity{Type: Claim, Location: AUMEL}},
+		{event(Claim, AUMEL, ""), HandlingActivity{}},
+	}
+
+	if got, want := c.Delivery.NextExpectedActivity, (HandlingActivity{Type: Receive, Location: SESTO}); got != want {
+		t.Errorf("NextExpectedActivity = %v; want = %v", got, want)
+	}
+
+	var history HandlingHistory
+	for _, tt := range tests {
+		history.HandlingEvents = append(history.HandlingEvents, tt.event)
+		c.DeriveDeliveryProgress(history)
+
+		if got := c.Delivery.NextExpectedActivity; got != tt.want {
+			t.Errorf("after %s: NextExpectedActivity = %v; want = %v", tt.event.Activity.Type, got, tt.want)
+		}
+	}
+}
+
+func TestDeriveDeliveryProgress_Transshipment(t *testing.T) {
+	// Events complete an hour apart in the order they are listed.
+	var n int
+	event := func(typ HandlingEventType, loc UNLocode, voyage VoyageNumber) HandlingEvent {
+		n++
+		return HandlingEvent{
+			TrackingID:     "ABC",
+			Activity:       HandlingActivity{Type: typ, Location: loc, VoyageNumber: voyage},
+			CompletionTime: time.Date(2016, 1, 1, n, 0, 0, 0, time.UTC),
+		}
+	}
+
+	var tests = []struct {
+		name   string
+		legs   []Leg
+		events []HandlingEvent
+	}{
+		{
+			name: "three legs",
+			legs: []Leg{
+				{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: DEHAM},
+				{VoyageNumber: "V200", LoadLocation: DEHAM, UnloadLocation: NLRTM},
+				{VoyageNumber: "V300", LoadLocation: NLRTM, UnloadLocation: AUMEL},
+			},
+			events: []HandlingEvent{
+				event(Receive, SESTO, ""),
+				event(Load, SESTO, "V100"),
+				event(Unload, DEHAM, "V100"),
+				event(Load, DEHAM, "V200"),
+				event(Unload, NLRTM, "V200"),
+				event(Load, NLRTM, "V300"),
+				event(Unload, AUMEL, "V300"),
+			},
+		},
+		{
+			name: "hub called at twice",
+			legs: []Leg{
+				{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: DEHAM},
+				{VoyageNumber: "V200", LoadLocation: DEHAM, UnloadLocation: NLRTM},
+				{VoyageNumber: "V300", LoadLocation: NLRTM, UnloadLocation: DEHAM},
+				{VoyageNumber: "V400", LoadLocation: DEHAM, UnloadLocation: AUMEL},
+			},
+			events: []HandlingEvent{
+				event(Receive, SESTO, ""),
+				event(Load, SESTO, "V100"),
+				event(Unload, DEHAM, "V100"),
+				event(Load, DEHAM, "V200"),
+				event(Unload, NLRTM, "V200"),
+				event(Load, NLRTM, "V300"),
+				event(Unload, DEHAM, "V300"),
+				event(Load, DEHAM, "V400"),
+				event(Unload, AUMEL, "V400"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := NewCargo("ABC", RouteSpecification{
+			Origin:      SESTO,
+			Destination: AUMEL,
+		})
+		c.AssignToRoute(Itinerary{Legs: tt.legs})
+
+		var history HandlingHistory
+		for i, e := range tt.events {
+			history.HandlingEvents = append(history.HandlingEvents, e)
+			c.DeriveDeliveryProgress(history)
+
+			if c.Delivery.IsMisdirected {
+				t.Errorf("%s: after %s at %s: IsMisdirected = %v; want = %v", tt.name, e.Activity.Type, e.Activity.Location, true, false)
+			}
+
+			// Every event but the last is followed by the next one.
+			if i+1 < len(tt.events) {
+				if got, want := c.Delivery.NextExpectedActivity, tt.events[i+1].Activity; got != want {
+					t.Errorf("%s: after %s at %s: NextExpectedActivity = %v; want = %v", tt.name, e.Activity.Type, e.Activity.Location, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestNextExpectedActivity_Misdirected(t *testing.T) {
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: AUMEL},
+	}})
+
+	c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Unload, Location: CNHKG, VoyageNumber: "V100"}},
+	}})
+
+	if !c.Delivery.IsMisdirected {
+		t.Fatalf("IsMisdirected = %v; want = %v", c.Delivery.IsMisdirected, true)
+	}
+	if got := c.Delivery.NextExpectedActivity; got != (HandlingActivity{}) {
+		t.Errorf("NextExpectedActivity = %v; want = %v", got, HandlingActivity{})
+	}
+}
+
+func TestMisdirected_Rerouted(t *testing.T) {
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: AUMEL},
+	}})
+
+	history := HandlingHistory{HandlingEvents: []HandlingEvent{
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}},
+		{TrackingID: "ABC", Activity: HandlingActivity{Type: Unload, Location: CNHKG, VoyageNumber: "V100"}},
+	}}
+
+	c.DeriveDeliveryProgress(history)
+
+	if !c.Delivery.IsMisdirected {
+		t.Fatalf("IsMisdirected = %v; want = %v", c.Delivery.IsMisdirected, true)
+	}
+
+	c.SpecifyNewRoute(RouteSpecification{Origin: CNHKG, Destination: AUMEL})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		{VoyageNumber: "V200", LoadLocation: CNHKG, UnloadLocation: AUMEL},
+	}})
+	c.DeriveDeliveryProgress(history)
+
+	if c.Delivery.IsMisdirected {
+		t.Errorf("IsMisdirected = %v; want = %v", c.Delivery.IsMisdirected, false)
+	}
+	if !c.Delivery.IsOnTrack() {
+		t.Errorf("IsOnTrack() = %v; want = %v", c.Delivery.IsOnTrack(), true)
+	}
+
+	want := HandlingActivity{Type: Load, Location: CNHKG, VoyageNumber: "V200"}
+	if got := c.Delivery.NextExpectedActivity; got != want {
+		t.Errorf("NextExpectedActivity = %v; want = %v", got, want)
+	}
+}
+
+func TestMisdirected_NotRerouted(t *testing.T) {
+	var tests = []struct {
+		name      string
+		rs        RouteSpecification
+		itinerary Itinerary
+		unload    HandlingActivity
+	}{
+		{
+			name:      "unloaded at origin off the first leg",
+			rs:        RouteSpecification{Origin: SESTO, Destination: AUMEL},
+			itinerary: Itinerary{Legs: []Leg{{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: AUMEL}}},
+			unload:    HandlingActivity{Type: Unload, Location: SESTO, VoyageNumber: "V100"},
+		},
+		{
+			name:      "itinerary departs away from origin",
+			rs:        RouteSpecification{Origin: SESTO, Destination: AUMEL},
+			itinerary: Itinerary{Legs: []Leg{{VoyageNumber: "V200", LoadLocation: CNHKG, UnloadLocation: AUMEL}}},
+			unload:    HandlingActivity{Type: Unload, Location: CNHKG, VoyageNumber: "V100"},
+		},
+	}
+
+	for _, tt := range tests {
+		c := NewCargo("ABC", tt.rs)
+		c.AssignToRoute(tt.itinerary)
+		c.DeriveDeliveryProgress(HandlingHistory{HandlingEvents: []HandlingEvent{
+			{TrackingID: "ABC", Activity: tt.unload},
+		}})
+
+		if !c.Delivery.IsMisdirected {
+			t.Errorf("%s: IsMisdirected = %v; want = %v", tt.name, c.Delivery.IsMisdirected, true)
+		}
+	}
+}
+
+func TestCustomsStatus(t *testing.T) {
+	c := NewCargo("ABC", RouteSpecification{
+		Origin:      SESTO,
+		Destination: AUMEL,
+	})
+
+	tests := []struct {
+		typ     HandlingEventType
+		loc     UNLocode
+		cleared bool
+		want    CustomsStatus
+	}{
+		{Receive, SESTO, false, NotCleared},
+		{Load, SESTO, false, NotCleared},
+		{Unload, AUMEL, false, NotCleared},
+		{Customs, AUMEL, false, InCustoms},
+		{Unload, AUMEL, false, InCustoms},
+		{Customs, AUMEL, true, Cleared},
+		{Claim, AUMEL, false, Cleared},
+	}
+
+	if c.Delivery.CustomsStatus != NotCleared {
+		t.Errorf("CustomsStatus = %v; want = %v", c.Delivery.CustomsStatus, NotCleared)
+	}
+
+	var history HandlingHistory
+	for _, tt := range tests {
+		history.HandlingEvents = append(history.HandlingEvents, HandlingEvent{
+			TrackingID:     "ABC",
+			Activity:       HandlingActivity{Type: tt.typ, Location: tt.loc},
+			CustomsCleared: tt.cleared,
+		})
+		c.DeriveDeliveryProgress(history)
+
+		if got := c.Delivery.CustomsStatus; got != tt.want {
+			t.Errorf("after %s: CustomsStatus = %v; want = %v", tt.typ, got, tt.want)
+		}
+	}
+
+	// Rerouting keeps the customs status of the cargo.
+	c.SpecifyNewRoute(RouteSpecification{Origin: SESTO, Destination: CNHKG})
+
+	if c.Delivery.CustomsStatus != Cleared {
+		t.Errorf("CustomsStatus = %v; want = %v", c.Delivery.CustomsStatus, Cleared)
+	}
+}
+
+func TestDelivery_ProgressPercent(t *testing.T) {
+	itinerary := Itinerary{Legs: []Leg{
+		{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: DEHAM},
+		{VoyageNumber: "V200", LoadLocation: DEHAM, UnloadLocation: NLRTM},
+		{VoyageNumber: "V300", LoadLocation: NLRTM, UnloadLocation: DEHAM},
+		{VoyageNumber: "V400", LoadLocation: DEHAM, UnloadLocation: AUMEL},
+	}}
+
+	tests := []struct {
+		name   string
+		events []HandlingEvent
+		want   int
+	}{
+		{"not received", nil, 0},
+		{"received", []HandlingEvent{
+			{Activity: HandlingActivity{Type: Receive, Location: SESTO}},
+		}, 0},
+		{"loaded", []HandlingEvent{
+			{Activity: HandlingActivity{Type: Load, Location: DEHAM, VoyageNumber: "V200"}},
+		}, 25},
+		{"mid-journey", []HandlingEvent{
+			{Activity: HandlingActivity{Type: Unload, Location: DEHAM, VoyageNumber: "V300"}},
+		}, 75},
+		{"misdirected", []HandlingEvent{
+			{Activity: HandlingActivity{Type: Unload, Location: CNHKG, VoyageNumber: "V200"}},
+		}, 50},
+		{"claimed", []HandlingEvent{
+			{Activity: HandlingActivity{Type: Unload, Location: AUMEL, VoyageNumber: "V400"}},
+			{Activity: HandlingActivity{Type: Claim, Location: AUMEL}},
+		}, 100},
+	}
+
+	for _, tt := range tests {
+		d := DeriveDeliveryFrom(RouteSpecification{Origin: SESTO, Destination: AUMEL}, itinerary, HandlingHistory{HandlingEvents: tt.events})
+
+		if got := d.ProgressPercent(); got != tt.want {
+			t.Errorf("%s: ProgressPercent() = %d; want = %d", tt.name, got, tt.want)
+		}
+	}
+}
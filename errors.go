@@ -0,0 +1,47 @@
+package shipping
+
+import "errors"
+
+// ErrorCode is a stable, machine-readable classification of an error, that
+// transports map onto their own status codes.
+type ErrorCode string
+
+// Valid error codes.
+const (
+	CodeUnknown            ErrorCode = "unknown"
+	CodeInvalidArgument    ErrorCode = "invalid_argument"
+	CodeNotFound           ErrorCode = "not_found"
+	CodeAlreadyExists      ErrorCode = "already_exists"
+	CodeConflict           ErrorCode = "conflict"
+	CodeFailedPrecondition ErrorCode = "failed_precondition"
+	CodeResourceExhausted  ErrorCode = "resource_exhausted"
+	CodeUnavailable        ErrorCode = "unavailable"
+	CodeUnimplemented      ErrorCode = "unimplemented"
+)
+
+// DomainError is an error with a code. The sentinel errors of the domain are
+// all domain errors, and are compared by identity, so errors.Is finds them
+// even when they are wrapped.
+type DomainError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *DomainError) Error() string {
+	return e.Message
+}
+
+// NewError returns a domain error with the given code and message.
+func NewError(code ErrorCode, message string) error {
+	return &DomainError{Code: code, Message: message}
+}
+
+// ErrorCodeOf returns the code of the first domain error in the chain of err,
+// or CodeUnknown if there is none.
+func ErrorCodeOf(err error) ErrorCode {
+	var de *DomainError
+	if errors.As(err, &de) {
+		return de.Code
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,43 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDomainError_Is(t *testing.T) {
+	err := fmt.Errorf("book cargo: %w", ErrInvalidArgument)
+
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("errors.Is(%v, ErrInvalidArgument) = %v; want = %v", err, false, true)
+	}
+	if errors.Is(err, ErrSameOriginAndDestination) {
+		t.Errorf("errors.Is(%v, ErrSameOriginAndDestination) = %v; want = %v", err, true, false)
+	}
+	if got := err.Error(); got != "book cargo: invalid argument" {
+		t.Errorf("Error() = %q; want = %q", got, "book cargo: invalid argument")
+	}
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	var tests = []struct {
+		err  error
+		want ErrorCode
+	}{
+		{ErrInvalidArgument, CodeInvalidArgument},
+		{ErrSameOriginAndDestination, CodeInvalidArgument},
+		{fmt.Errorf("find: %w", ErrUnknownCargo), CodeNotFound},
+		{ErrDuplicateEvent, CodeAlreadyExists},
+		{ErrConcurrentModification, CodeConflict},
+		{ErrVoyageCancelled, CodeFailedPrecondition},
+		{errors.New("disk on fire"), CodeUnknown},
+		{nil, CodeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := ErrorCodeOf(tt.err); got != tt.want {
+			t.Errorf("ErrorCodeOf(%v) = %v; want = %v", tt.err, got, tt.want)
+		}
+	}
+}
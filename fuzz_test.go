@@ -0,0 +1,53 @@
+//go:build go1.18
+
+package shipping
+
+import "testing"
+
+func FuzzParseUNLocode(f *testing.F) {
+	for _, seed := range []string{
+		"SESTO", "sesto", "SE STO", " SESTO\n", "SE  STO", "USNY1",
+		"SESTOX", "SEST", "", "12345", "SE-STO", "ſESTO", "SESTİ", "SE\x00TO",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		c, err := ParseUNLocode(s)
+		if err != nil {
+			if err != ErrInvalidUNLocode {
+				t.Fatalf("ParseUNLocode(%q) err = %v; want = %v", s, err, ErrInvalidUNLocode)
+			}
+			return
+		}
+		if !c.IsValid() {
+			t.Fatalf("ParseUNLocode(%q) = %q, which is not valid", s, c)
+		}
+		again, err := ParseUNLocode(c.String())
+		if err != nil || again != c {
+			t.Fatalf("ParseUNLocode(%q) = %q, %v; want = %q, %v", c.String(), again, err, c, nil)
+		}
+	})
+}
+
+func FuzzParseNumber(f *testing.F) {
+	for _, seed := range []string{
+		"V100", "v100", " V100 ", "0100S", "", " ", "V-100", "V 100", "V100é", "\tV100\r\n", "V\x00",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := ParseVoyageNumber(s)
+		if err != nil {
+			if err != ErrInvalidVoyageNumber {
+				t.Fatalf("ParseVoyageNumber(%q) err = %v; want = %v", s, err, ErrInvalidVoyageNumber)
+			}
+			return
+		}
+		again, err := ParseVoyageNumber(n.String())
+		if err != nil || again != n {
+			t.Fatalf("ParseVoyageNumber(%q) = %q, %v; want = %q, %v", n.String(), again, err, n, nil)
+		}
+	})
+}
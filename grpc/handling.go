@@ -0,0 +1,93 @@
+// Package grpc provides gRPC servers for the application services.
+package grpc
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/grpc/pb"
+	"github.com/marcusolsson/goddd/handling"
+)
+
+type handlingServer struct {
+	s handling.Service
+}
+
+// NewHandlingServer returns a gRPC server for the handling service.
+func NewHandlingServer(s handling.Service) pb.HandlingServer {
+	return &handlingServer{s: s}
+}
+
+func (h *handlingServer) RegisterHandlingEvent(ctx context.Context, req *pb.RegisterHandlingEventRequest) (*pb.RegisterHandlingEventReply, error) {
+	completed, err := ptypes.Timestamp(req.CompletionTime)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid completion time: %v", err)
+	}
+
+	if _, ok := pb.HandlingEventType_name[int32(req.EventType)]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown event type %d", req.EventType)
+	}
+
+	var opts []handling.RegistrationOption
+	if req.IdempotencyKey != "" {
+		opts = append(opts, handling.WithIdempotencyKey(req.IdempotencyKey))
+	}
+	if req.EquipmentId != "" {
+		opts = append(opts, handling.WithEquipmentID(shipping.EquipmentID(req.EquipmentId)))
+	}
+	if req.RecipientName != "" || req.RecipientSignature != "" {
+		opts = append(opts, handling.WithRecipient(req.RecipientName, req.RecipientSignature))
+	}
+	if req.CustomsCleared {
+		opts = append(opts, handling.WithCustomsCleared(true))
+	}
+
+	e, err := h.s.RegisterHandlingEvent(ctx,
+		completed,
+		shipping.TrackingID(req.TrackingId),
+		shipping.VoyageNumber(req.VoyageNumber),
+		shipping.UNLocode(req.Location),
+		shipping.HandlingEventType(req.EventType),
+		opts...,
+	)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	registered, err := ptypes.TimestampProto(e.RegistrationTime)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RegisterHandlingEventReply{RegistrationTime: registered}, nil
+}
+
+// encodeError translates a service error into a gRPC status error.
+func encodeError(err error) error {
+	var code codes.Code
+	switch shipping.ErrorCodeOf(err) {
+	case shipping.CodeInvalidArgument:
+		code = codes.InvalidArgument
+	case shipping.CodeNotFound:
+		code = codes.NotFound
+	case shipping.CodeAlreadyExists:
+		code = codes.AlreadyExists
+	case shipping.CodeConflict:
+		code = codes.Aborted
+	case shipping.CodeFailedPrecondition:
+		code = codes.FailedPrecondition
+	case shipping.CodeResourceExhausted:
+		code = codes.ResourceExhausted
+	case shipping.CodeUnavailable:
+		code = codes.Unavailable
+	case shipping.CodeUnimplemented:
+		code = codes.Unimplemented
+	default:
+		code = codes.Internal
+	}
+	return status.Error(code, err.Error())
+}
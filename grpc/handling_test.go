@@ -0,0 +1,226 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	stdgrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/grpc/pb"
+	"github.com/marcusolsson/goddd/handling"
+)
+
+type stubHandlingService struct {
+	err       error
+	completed time.Time
+	id        shipping.TrackingID
+	voyage    shipping.VoyageNumber
+	location  shipping.UNLocode
+	eventType shipping.HandlingEventType
+
+	registration handling.HandlingEventRegistration
+}
+
+func (s *stubHandlingService) RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+	loc shipping.UNLocode, eventType shipping.HandlingEventType, opts ...handling.RegistrationOption) (shipping.HandlingEvent, error) {
+	if s.err != nil {
+		return shipping.HandlingEvent{}, s.err
+	}
+	s.completed, s.id, s.voyage, s.location, s.eventType = completed, id, voyageNumber, loc, eventType
+	for _, opt := range opts {
+		opt(&s.registration)
+	}
+	return shipping.HandlingEvent{
+		TrackingID:       id,
+		RegistrationTime: completed.Add(time.Hour),
+		CompletionTime:   completed,
+	}, nil
+}
+
+func (s *stubHandlingService) RegisterHandlingEvents(ctx context.Context, events []handling.HandlingEventRegistration) ([]error, error) {
+	return make([]error, len(events)), nil
+}
+
+func (s *stubHandlingService) AmendHandlingEvent(ctx context.Context, original shipping.HandlingEvent, corrections ...handling.Correction) error {
+	return s.err
+}
+
+func (s *stubHandlingService) ConfirmPlannedEvent(ctx context.Context, planned shipping.HandlingEvent) error {
+	return s.err
+}
+
+func (s *stubHandlingService) SearchHandlingEvents(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	return nil, 0, s.err
+}
+
+func dialHandling(t *testing.T, s handling.Service) (pb.HandlingClient, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+
+	srv := stdgrpc.NewServer()
+	pb.RegisterHandlingServer(srv, NewHandlingServer(s))
+	go srv.Serve(lis)
+
+	conn, err := stdgrpc.Dial("bufnet",
+		stdgrpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		stdgrpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pb.NewHandlingClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func TestRegisterHandlingEvent(t *testing.T) {
+	ctx := context.Background()
+
+	var s stubHandlingService
+
+	client, closeFn := dialHandling(t, &s)
+	defer closeFn()
+
+	completed := time.Date(2009, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ts, err := ptypes.TimestampProto(completed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := client.RegisterHandlingEvent(ctx, &pb.RegisterHandlingEventRequest{
+		CompletionTime: ts,
+		TrackingId:     "ABC123",
+		VoyageNumber:   "V100",
+		Location:       "SESTO",
+		EventType:      pb.HandlingEventType_LOAD,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.completed.Equal(completed) {
+		t.Errorf("completed = %v; want = %v", s.completed, completed)
+	}
+	if s.id != "ABC123" {
+		t.Errorf("id = %v; want = %v", s.id, "ABC123")
+	}
+	if s.voyage != "V100" {
+		t.Errorf("voyage = %v; want = %v", s.voyage, "V100")
+	}
+	if s.location != shipping.SESTO {
+		t.Errorf("location = %v; want = %v", s.location, shipping.SESTO)
+	}
+	if s.eventType != shipping.Load {
+		t.Errorf("eventType = %v; want = %v", s.eventType, shipping.Load)
+	}
+
+	registered, err := ptypes.Timestamp(reply.RegistrationTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := completed.Add(time.Hour); !registered.Equal(want) {
+		t.Errorf("registered = %v; want = %v", registered, want)
+	}
+}
+
+func TestRegisterHandlingEvent_Recipient(t *testing.T) {
+	ctx := context.Background()
+
+	var s stubHandlingService
+
+	client, closeFn := dialHandling(t, &s)
+	defer closeFn()
+
+	_, err := client.RegisterHandlingEvent(ctx, &pb.RegisterHandlingEventRequest{
+		CompletionTime:     ptypes.TimestampNow(),
+		TrackingId:         "ABC123",
+		Location:           "AUMEL",
+		EventType:          pb.HandlingEventType_CLAIM,
+		EquipmentId:        "MSKU1234565",
+		RecipientName:      "Jane Doe",
+		RecipientSignature: "c2lnbmF0dXJl",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := s.registration.EquipmentID, shipping.EquipmentID("MSKU1234565"); got != want {
+		t.Errorf("EquipmentID = %v; want = %v", got, want)
+	}
+	if got, want := s.registration.RecipientName, "Jane Doe"; got != want {
+		t.Errorf("RecipientName = %v; want = %v", got, want)
+	}
+	if got, want := s.registration.RecipientSignature, "c2lnbmF0dXJl"; got != want {
+		t.Errorf("RecipientSignature = %v; want = %v", got, want)
+	}
+
+	if _, err := client.RegisterHandlingEvent(ctx, &pb.RegisterHandlingEventRequest{
+		CompletionTime: ptypes.TimestampNow(),
+		TrackingId:     "ABC123",
+		Location:       "AUMEL",
+		EventType:      pb.HandlingEventType_CUSTOMS,
+		CustomsCleared: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.registration.CustomsCleared {
+		t.Errorf("CustomsCleared = %v; want = %v", s.registration.CustomsCleared, true)
+	}
+}
+
+func TestRegisterHandlingEvent_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	var tests = []struct {
+		err  error
+		want codes.Code
+	}{
+		{handling.ErrInvalidArgument, codes.InvalidArgument},
+		{shipping.ErrUnknownCargo, codes.NotFound},
+		{shipping.ErrUnknownVoyage, codes.NotFound},
+		{shipping.ErrUnknownLocation, codes.NotFound},
+		{shipping.ErrDuplicateEvent, codes.AlreadyExists},
+	}
+
+	for _, tt := range tests {
+		client, closeFn := dialHandling(t, &stubHandlingService{err: tt.err})
+
+		_, err := client.RegisterHandlingEvent(ctx, &pb.RegisterHandlingEventRequest{
+			CompletionTime: ptypes.TimestampNow(),
+			TrackingId:     "ABC123",
+			Location:       "SESTO",
+			EventType:      pb.HandlingEventType_RECEIVE,
+		})
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%v: status.Code(err) = %v; want = %v", tt.err, got, tt.want)
+		}
+
+		closeFn()
+	}
+}
+
+func TestRegisterHandlingEvent_MissingCompletionTime(t *testing.T) {
+	ctx := context.Background()
+
+	client, closeFn := dialHandling(t, &stubHandlingService{})
+	defer closeFn()
+
+	_, err := client.RegisterHandlingEvent(ctx, &pb.RegisterHandlingEventRequest{
+		TrackingId: "ABC123",
+		Location:   "SESTO",
+		EventType:  pb.HandlingEventType_RECEIVE,
+	})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("status.Code(err) = %v; want = %v", got, codes.InvalidArgument)
+	}
+}
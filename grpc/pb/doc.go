@@ -0,0 +1,4 @@
+// Package pb contains the protocol buffer definitions of the gRPC services.
+package pb
+
+//go:generate protoc --go_out=plugins=grpc:. handling.proto
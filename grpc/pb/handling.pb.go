@@ -0,0 +1,327 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: handling.proto
+
+package pb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import timestamp "github.com/golang/protobuf/ptypes/timestamp"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// HandlingEventType mirrors the handling event types of the domain.
+type HandlingEventType int32
+
+const (
+	HandlingEventType_NOT_HANDLED HandlingEventType = 0
+	HandlingEventType_LOAD        HandlingEventType = 1
+	HandlingEventType_UNLOAD      HandlingEventType = 2
+	HandlingEventType_RECEIVE     HandlingEventType = 3
+	HandlingEventType_CLAIM       HandlingEventType = 4
+	HandlingEventType_CUSTOMS     HandlingEventType = 5
+)
+
+var HandlingEventType_name = map[int32]string{
+	0: "NOT_HANDLED",
+	1: "LOAD",
+	2: "UNLOAD",
+	3: "RECEIVE",
+	4: "CLAIM",
+	5: "CUSTOMS",
+}
+var HandlingEventType_value = map[string]int32{
+	"NOT_HANDLED": 0,
+	"LOAD":        1,
+	"UNLOAD":      2,
+	"RECEIVE":     3,
+	"CLAIM":       4,
+	"CUSTOMS":     5,
+}
+
+func (x HandlingEventType) String() string {
+	return proto.EnumName(HandlingEventType_name, int32(x))
+}
+func (HandlingEventType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_handling_c0908d67c5ad8554, []int{0}
+}
+
+type RegisterHandlingEventRequest struct {
+	CompletionTime       *timestamp.Timestamp `protobuf:"bytes,1,opt,name=completion_time,json=completionTime,proto3" json:"completion_time,omitempty"`
+	TrackingId           string               `protobuf:"bytes,2,opt,name=tracking_id,json=trackingId,proto3" json:"tracking_id,omitempty"`
+	VoyageNumber         string               `protobuf:"bytes,3,opt,name=voyage_number,json=voyageNumber,proto3" json:"voyage_number,omitempty"`
+	Location             string               `protobuf:"bytes,4,opt,name=location,proto3" json:"location,omitempty"`
+	EventType            HandlingEventType    `protobuf:"varint,5,opt,name=event_type,json=eventType,proto3,enum=pb.HandlingEventType" json:"event_type,omitempty"`
+	IdempotencyKey       string               `protobuf:"bytes,6,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	EquipmentId          string               `protobuf:"bytes,7,opt,name=equipment_id,json=equipmentId,proto3" json:"equipment_id,omitempty"`
+	RecipientName        string               `protobuf:"bytes,8,opt,name=recipient_name,json=recipientName,proto3" json:"recipient_name,omitempty"`
+	RecipientSignature   string               `protobuf:"bytes,9,opt,name=recipient_signature,json=recipientSignature,proto3" json:"recipient_signature,omitempty"`
+	CustomsCleared       bool                 `protobuf:"varint,10,opt,name=customs_cleared,json=customsCleared,proto3" json:"customs_cleared,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *RegisterHandlingEventRequest) Reset()         { *m = RegisterHandlingEventRequest{} }
+func (m *RegisterHandlingEventRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterHandlingEventRequest) ProtoMessage()    {}
+func (*RegisterHandlingEventRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_handling_c0908d67c5ad8554, []int{0}
+}
+func (m *RegisterHandlingEventRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterHandlingEventRequest.Unmarshal(m, b)
+}
+func (m *RegisterHandlingEventRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterHandlingEventRequest.Marshal(b, m, deterministic)
+}
+func (dst *RegisterHandlingEventRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterHandlingEventRequest.Merge(dst, src)
+}
+func (m *RegisterHandlingEventRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterHandlingEventRequest.Size(m)
+}
+func (m *RegisterHandlingEventRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterHandlingEventRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterHandlingEventRequest proto.InternalMessageInfo
+
+func (m *RegisterHandlingEventRequest) GetCompletionTime() *timestamp.Timestamp {
+	if m != nil {
+		return m.CompletionTime
+	}
+	return nil
+}
+
+func (m *RegisterHandlingEventRequest) GetTrackingId() string {
+	if m != nil {
+		return m.TrackingId
+	}
+	return ""
+}
+
+func (m *RegisterHandlingEventRequest) GetVoyageNumber() string {
+	if m != nil {
+		return m.VoyageNumber
+	}
+	return ""
+}
+
+func (m *RegisterHandlingEventRequest) GetLocation() string {
+	if m != nil {
+		return m.Location
+	}
+	return ""
+}
+
+func (m *RegisterHandlingEventRequest) GetEventType() HandlingEventType {
+	if m != nil {
+		return m.EventType
+	}
+	return HandlingEventType_NOT_HANDLED
+}
+
+func (m *RegisterHandlingEventRequest) GetIdempotencyKey() string {
+	if m != nil {
+		return m.IdempotencyKey
+	}
+	return ""
+}
+
+func (m *RegisterHandlingEventRequest) GetEquipmentId() string {
+	if m != nil {
+		return m.EquipmentId
+	}
+	return ""
+}
+
+func (m *RegisterHandlingEventRequest) GetRecipientName() string {
+	if m != nil {
+		return m.RecipientName
+	}
+	return ""
+}
+
+func (m *RegisterHandlingEventRequest) GetRecipientSignature() string {
+	if m != nil {
+		return m.RecipientSignature
+	}
+	return ""
+}
+
+func (m *RegisterHandlingEventRequest) GetCustomsCleared() bool {
+	if m != nil {
+		return m.CustomsCleared
+	}
+	return false
+}
+
+type RegisterHandlingEventReply struct {
+	RegistrationTime     *timestamp.Timestamp `protobuf:"bytes,1,opt,name=registration_time,json=registrationTime,proto3" json:"registration_time,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *RegisterHandlingEventReply) Reset()         { *m = RegisterHandlingEventReply{} }
+func (m *RegisterHandlingEventReply) String() string { return proto.CompactTextString(m) }
+func (*RegisterHandlingEventReply) ProtoMessage()    {}
+func (*RegisterHandlingEventReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_handling_c0908d67c5ad8554, []int{1}
+}
+func (m *RegisterHandlingEventReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterHandlingEventReply.Unmarshal(m, b)
+}
+func (m *RegisterHandlingEventReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterHandlingEventReply.Marshal(b, m, deterministic)
+}
+func (dst *RegisterHandlingEventReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterHandlingEventReply.Merge(dst, src)
+}
+func (m *RegisterHandlingEventReply) XXX_Size() int {
+	return xxx_messageInfo_RegisterHandlingEventReply.Size(m)
+}
+func (m *RegisterHandlingEventReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterHandlingEventReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterHandlingEventReply proto.InternalMessageInfo
+
+func (m *RegisterHandlingEventReply) GetRegistrationTime() *timestamp.Timestamp {
+	if m != nil {
+		return m.RegistrationTime
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*RegisterHandlingEventRequest)(nil), "pb.RegisterHandlingEventRequest")
+	proto.RegisterType((*RegisterHandlingEventReply)(nil), "pb.RegisterHandlingEventReply")
+	proto.RegisterEnum("pb.HandlingEventType", HandlingEventType_name, HandlingEventType_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// HandlingClient is the client API for Handling service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type HandlingClient interface {
+	// RegisterHandlingEvent registers a handling event in the system, and
+	// notifies interested parties that a cargo has been handled.
+	RegisterHandlingEvent(ctx context.Context, in *RegisterHandlingEventRequest, opts ...grpc.CallOption) (*RegisterHandlingEventReply, error)
+}
+
+type handlingClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewHandlingClient(cc *grpc.ClientConn) HandlingClient {
+	return &handlingClient{cc}
+}
+
+func (c *handlingClient) RegisterHandlingEvent(ctx context.Context, in *RegisterHandlingEventRequest, opts ...grpc.CallOption) (*RegisterHandlingEventReply, error) {
+	out := new(RegisterHandlingEventReply)
+	err := c.cc.Invoke(ctx, "/pb.Handling/RegisterHandlingEvent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HandlingServer is the server API for Handling service.
+type HandlingServer interface {
+	// RegisterHandlingEvent registers a handling event in the system, and
+	// notifies interested parties that a cargo has been handled.
+	RegisterHandlingEvent(context.Context, *RegisterHandlingEventRequest) (*RegisterHandlingEventReply, error)
+}
+
+func RegisterHandlingServer(s *grpc.Server, srv HandlingServer) {
+	s.RegisterService(&_Handling_serviceDesc, srv)
+}
+
+func _Handling_RegisterHandlingEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterHandlingEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlingServer).RegisterHandlingEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Handling/RegisterHandlingEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlingServer).RegisterHandlingEvent(ctx, req.(*RegisterHandlingEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Handling_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Handling",
+	HandlerType: (*HandlingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RegisterHandlingEvent",
+			Handler:    _Handling_RegisterHandlingEvent_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "handling.proto",
+}
+
+func init() { proto.RegisterFile("handling.proto", fileDescriptor_handling_c0908d67c5ad8554) }
+
+var fileDescriptor_handling_c0908d67c5ad8554 = []byte{
+	// 470 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0x5f, 0x6f, 0xda, 0x3c,
+	0x14, 0xc6, 0x1b, 0xfe, 0x15, 0x0e, 0x6d, 0x48, 0xfd, 0xaa, 0x92, 0x85, 0x5e, 0xad, 0x8c, 0x69,
+	0x1a, 0xda, 0x45, 0x90, 0xd8, 0xbe, 0x00, 0x02, 0xb4, 0xa2, 0x51, 0x90, 0x02, 0xdd, 0xcd, 0x2e,
+	0x22, 0x93, 0x9c, 0x65, 0x56, 0x93, 0xd8, 0x4d, 0x9c, 0x4a, 0xf9, 0x86, 0xfb, 0x58, 0x93, 0x4d,
+	0x43, 0x3b, 0x6d, 0xab, 0xb4, 0x3b, 0xe7, 0x77, 0x9e, 0xe7, 0xf1, 0x39, 0x39, 0x06, 0xfb, 0x3b,
+	0x4b, 0xc3, 0x98, 0xa7, 0x91, 0x2b, 0x33, 0xa1, 0x04, 0xa9, 0xc9, 0x7d, 0xff, 0x2a, 0x12, 0x22,
+	0x8a, 0x71, 0x6c, 0xc8, 0xbe, 0xf8, 0x36, 0x56, 0x3c, 0xc1, 0x5c, 0xb1, 0x44, 0x1e, 0x44, 0xc3,
+	0x1f, 0x75, 0xf8, 0xdf, 0xc3, 0x88, 0xe7, 0x0a, 0xb3, 0xeb, 0x47, 0xff, 0xe2, 0x01, 0x53, 0xe5,
+	0xe1, 0x7d, 0x81, 0xb9, 0x22, 0x33, 0xe8, 0x05, 0x22, 0x91, 0x31, 0x2a, 0x2e, 0x52, 0x5f, 0xdb,
+	0xa9, 0x35, 0xb0, 0x46, 0xdd, 0x49, 0xdf, 0x3d, 0x64, 0xbb, 0x55, 0xb6, 0xbb, 0xab, 0xb2, 0x3d,
+	0xfb, 0xc9, 0xa2, 0x21, 0xb9, 0x82, 0xae, 0xca, 0x58, 0x70, 0xc7, 0xd3, 0xc8, 0xe7, 0x21, 0xad,
+	0x0d, 0xac, 0x51, 0xc7, 0x83, 0x0a, 0x2d, 0x43, 0xf2, 0x06, 0xce, 0x1f, 0x44, 0xc9, 0x22, 0xf4,
+	0xd3, 0x22, 0xd9, 0x63, 0x46, 0xeb, 0x46, 0x72, 0x76, 0x80, 0x6b, 0xc3, 0x48, 0x1f, 0xda, 0xb1,
+	0x08, 0x98, 0x4e, 0xa5, 0x0d, 0x53, 0x3f, 0x7e, 0x93, 0x8f, 0x00, 0xa8, 0xdb, 0xf6, 0x55, 0x29,
+	0x91, 0x36, 0x07, 0xd6, 0xc8, 0x9e, 0x5c, 0xba, 0x72, 0xef, 0xfe, 0x32, 0xd4, 0xae, 0x94, 0xe8,
+	0x75, 0xb0, 0x3a, 0x92, 0x77, 0xd0, 0xe3, 0x21, 0x26, 0x52, 0x28, 0x4c, 0x83, 0xd2, 0xbf, 0xc3,
+	0x92, 0xb6, 0x4c, 0xb0, 0xfd, 0x0c, 0x7f, 0xc6, 0x92, 0xbc, 0x86, 0x33, 0xbc, 0x2f, 0xb8, 0x4c,
+	0xf4, 0x15, 0x3c, 0xa4, 0xa7, 0x46, 0xd5, 0x3d, 0xb2, 0x65, 0x48, 0xde, 0x82, 0x9d, 0x61, 0xc0,
+	0x25, 0xd7, 0x92, 0x94, 0x25, 0x48, 0xdb, 0x46, 0x74, 0x7e, 0xa4, 0x6b, 0x96, 0x20, 0x19, 0xc3,
+	0x7f, 0x4f, 0xb2, 0x9c, 0x47, 0x29, 0x53, 0x45, 0x86, 0xb4, 0x63, 0xb4, 0xe4, 0x58, 0xda, 0x56,
+	0x15, 0xdd, 0x63, 0x50, 0xe4, 0x4a, 0x24, 0xb9, 0x1f, 0xc4, 0xc8, 0x32, 0x0c, 0x29, 0x0c, 0xac,
+	0x51, 0xdb, 0xb3, 0x1f, 0xf1, 0xec, 0x40, 0x87, 0x08, 0xfd, 0xbf, 0x6c, 0x52, 0xc6, 0x25, 0xf9,
+	0x04, 0x17, 0x99, 0xa9, 0x66, 0xec, 0x5f, 0x36, 0xe9, 0x3c, 0x37, 0x69, 0xfc, 0xde, 0x87, 0x8b,
+	0xdf, 0xfe, 0x29, 0xe9, 0x41, 0x77, 0xbd, 0xd9, 0xf9, 0xd7, 0xd3, 0xf5, 0x7c, 0xb5, 0x98, 0x3b,
+	0x27, 0xa4, 0x0d, 0x8d, 0xd5, 0x66, 0x3a, 0x77, 0x2c, 0x02, 0xd0, 0xba, 0x5d, 0x9b, 0x73, 0x8d,
+	0x74, 0xe1, 0xd4, 0x5b, 0xcc, 0x16, 0xcb, 0x2f, 0x0b, 0xa7, 0x4e, 0x3a, 0xd0, 0x9c, 0xad, 0xa6,
+	0xcb, 0x1b, 0xa7, 0xa1, 0xf9, 0xec, 0x76, 0xbb, 0xdb, 0xdc, 0x6c, 0x9d, 0xe6, 0x24, 0x82, 0x76,
+	0x75, 0x01, 0xf9, 0x0a, 0x97, 0x7f, 0x9c, 0x89, 0x0c, 0xf4, 0x6e, 0x5f, 0x7a, 0xb8, 0xfd, 0x57,
+	0x2f, 0x28, 0x64, 0x5c, 0x0e, 0x4f, 0xf6, 0x2d, 0x33, 0xef, 0x87, 0x9f, 0x03, 0x00, 0x12, 0xc5,
+	0x0b, 0xa3, 0x39, 0x03, 0x00, 0x00,
+}
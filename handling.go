@@ -9,7 +9,12 @@ package shipping
 // It would make sense not having the cargo package depend on handling.
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -27,6 +32,223 @@ type HandlingActivity struct {
 type HandlingEvent struct {
 	TrackingID TrackingID
 	Activity   HandlingActivity
+
+	// RegistrationTime and CompletionTime are in UTC, as normalized by the
+	// HandlingEventFactory, regardless of the zone they were reported in.
+	RegistrationTime time.Time
+	CompletionTime   time.Time
+
+	// IdempotencyKey is an optional, caller-supplied token identifying the
+	// registration that produced this event.
+	IdempotencyKey string
+
+	// EquipmentID optionally identifies the container, truck or other
+	// equipment that handled the cargo.
+	EquipmentID EquipmentID
+
+	// RecipientName and RecipientSignature optionally record who received
+	// the cargo, as proof of delivery. They are only set on claim events.
+	RecipientName      string
+	RecipientSignature string
+
+	// CustomsCleared is set on a customs event at which the cargo was
+	// cleared. A customs event without it holds the cargo in customs.
+	CustomsCleared bool
+
+	// Superseded is set on an event that has been corrected by an amendment.
+	// Superseded events remain in the handling history for audit, but are
+	// ignored when deriving the delivery of a cargo.
+	Superseded bool
+
+	// Amends identifies the event that this event corrects, if any.
+	Amends *HandlingEventKey
+
+	// Planned is set on an event that announces handling expected at its
+	// completion time, such as an appointment to load. Planned events remain
+	// in the handling history, but are ignored when deriving the delivery
+	// of a cargo until they have been confirmed.
+	Planned bool
+
+	// Instructions are those of the itinerary leg that the cargo was loaded
+	// onto or unloaded off, at the time the event was created.
+	Instructions string
+
+	// Sequence is assigned by the repository as the event is stored, and
+	// increases with every event stored, across all cargos. An event that
+	// is later confirmed or superseded is given a new sequence number, so
+	// that consumers see the change. It is zero for an event that has not
+	// been stored.
+	Sequence uint64
+}
+
+// HandlingEventKey identifies a handling event by the fields that make two
+// events duplicates of each other.
+type HandlingEventKey struct {
+	TrackingID     TrackingID
+	Activity       HandlingActivity
+	CompletionTime time.Time
+}
+
+// Key returns the key that identifies the event.
+func (e HandlingEvent) Key() HandlingEventKey {
+	return HandlingEventKey{
+		TrackingID:     e.TrackingID,
+		Activity:       e.Activity,
+		CompletionTime: e.CompletionTime,
+	}
+}
+
+// Matches returns whether e is the event identified by the key.
+func (k HandlingEventKey) Matches(e HandlingEvent) bool {
+	return k.TrackingID == e.TrackingID && k.Activity == e.Activity && k.CompletionTime.Equal(e.CompletionTime)
+}
+
+// EquipmentID identifies a piece of equipment, such as a container or a
+// truck.
+type EquipmentID string
+
+// handlingEventJSON is the flat JSON representation of a HandlingEvent.
+type handlingEventJSON struct {
+	TrackingID       string `json:"trackingId"`
+	Type             string `json:"type"`
+	Location         string `json:"location"`
+	VoyageNumber     string `json:"voyage,omitempty"`
+	RegistrationTime string `json:"registrationTime"`
+	CompletionTime   string `json:"completionTime"`
+	IdempotencyKey   string `json:"idempotencyKey,omitempty"`
+	EquipmentID      string `json:"equipmentId,omitempty"`
+
+	RecipientName      string `json:"recipientName,omitempty"`
+	RecipientSignature string `json:"recipientSignature,omitempty"`
+
+	CustomsCleared bool `json:"customsCleared,omitempty"`
+
+	Superseded bool                  `json:"superseded,omitempty"`
+	Amends     *handlingEventKeyJSON `json:"amends,omitempty"`
+	Planned    bool                  `json:"planned,omitempty"`
+
+	Instructions string `json:"instructions,omitempty"`
+
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+// handlingEventKeyJSON is the JSON representation of a HandlingEventKey. The
+// tracking ID is left out, as it is the same as that of the amending event.
+type handlingEventKeyJSON struct {
+	Type           string `json:"type"`
+	Location       string `json:"location"`
+	VoyageNumber   string `json:"voyage,omitempty"`
+	CompletionTime string `json:"completionTime"`
+}
+
+// MarshalJSON encodes the event as a flat JSON object with the timestamps
+// formatted according to RFC 3339.
+func (e HandlingEvent) MarshalJSON() ([]byte, error) {
+	var amends *handlingEventKeyJSON
+	if e.Amends != nil {
+		amends = &handlingEventKeyJSON{
+			Type:           e.Amends.Activity.Type.String(),
+			Location:       string(e.Amends.Activity.Location),
+			VoyageNumber:   string(e.Amends.Activity.VoyageNumber),
+			CompletionTime: e.Amends.CompletionTime.Format(time.RFC3339Nano),
+		}
+	}
+
+	return json.Marshal(handlingEventJSON{
+		TrackingID:       string(e.TrackingID),
+		Type:             e.Activity.Type.String(),
+		Location:         string(e.Activity.Location),
+		VoyageNumber:     string(e.Activity.VoyageNumber),
+		RegistrationTime: e.RegistrationTime.Format(time.RFC3339Nano),
+		CompletionTime:   e.CompletionTime.Format(time.RFC3339Nano),
+		IdempotencyKey:   e.IdempotencyKey,
+		EquipmentID:      string(e.EquipmentID),
+
+		RecipientName:      e.RecipientName,
+		RecipientSignature: e.RecipientSignature,
+
+		CustomsCleared: e.CustomsCleared,
+
+		Superseded: e.Superseded,
+		Amends:     amends,
+		Planned:    e.Planned,
+
+		Instructions: e.Instructions,
+
+		Sequence: e.Sequence,
+	})
+}
+
+// UnmarshalJSON decodes an event encoded by MarshalJSON.
+func (e *HandlingEvent) UnmarshalJSON(data []byte) error {
+	var v handlingEventJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	typ, ok := handlingEventTypes[v.Type]
+	if !ok {
+		return fmt.Errorf("unknown handling event type %q", v.Type)
+	}
+
+	registered, err := time.Parse(time.RFC3339Nano, v.RegistrationTime)
+	if err != nil {
+		return fmt.Errorf("invalid registration time: %v", err)
+	}
+
+	completed, err := time.Parse(time.RFC3339Nano, v.CompletionTime)
+	if err != nil {
+		return fmt.Errorf("invalid completion time: %v", err)
+	}
+
+	var amends *HandlingEventKey
+	if v.Amends != nil {
+		amendedType, ok := handlingEventTypes[v.Amends.Type]
+		if !ok {
+			return fmt.Errorf("unknown amended handling event type %q", v.Amends.Type)
+		}
+		amendedCompleted, err := time.Parse(time.RFC3339Nano, v.Amends.CompletionTime)
+		if err != nil {
+			return fmt.Errorf("invalid amended completion time: %v", err)
+		}
+		amends = &HandlingEventKey{
+			TrackingID: TrackingID(v.TrackingID),
+			Activity: HandlingActivity{
+				Type:         amendedType,
+				Location:     UNLocode(v.Amends.Location),
+				VoyageNumber: VoyageNumber(v.Amends.VoyageNumber),
+			},
+			CompletionTime: amendedCompleted,
+		}
+	}
+
+	*e = HandlingEvent{
+		TrackingID: TrackingID(v.TrackingID),
+		Activity: HandlingActivity{
+			Type:         typ,
+			Location:     UNLocode(v.Location),
+			VoyageNumber: VoyageNumber(v.VoyageNumber),
+		},
+		RegistrationTime: registered,
+		CompletionTime:   completed,
+		IdempotencyKey:   v.IdempotencyKey,
+		EquipmentID:      EquipmentID(v.EquipmentID),
+
+		RecipientName:      v.RecipientName,
+		RecipientSignature: v.RecipientSignature,
+
+		CustomsCleared: v.CustomsCleared,
+
+		Superseded: v.Superseded,
+		Amends:     amends,
+		Planned:    v.Planned,
+
+		Instructions: v.Instructions,
+
+		Sequence: v.Sequence,
+	}
+
+	return nil
 }
 
 // HandlingEventType describes type of a handling event.
@@ -61,6 +283,35 @@ func (t HandlingEventType) String() string {
 	return ""
 }
 
+// ErrInvalidHandlingEventType is used when parsing a string that does not
+// name a handling event type.
+var ErrInvalidHandlingEventType = NewError(CodeInvalidArgument, "invalid handling event type")
+
+// ParseHandlingEventType parses the name of a handling event type, as
+// returned by String, ignoring case and surrounding white space. It returns
+// ErrInvalidHandlingEventType if s names no handling event type, or names
+// NotHandled, which no handling event can have.
+func ParseHandlingEventType(s string) (HandlingEventType, error) {
+	s = strings.TrimSpace(s)
+	for _, t := range []HandlingEventType{Receive, Load, Unload, Customs, Claim} {
+		if strings.EqualFold(s, t.String()) {
+			return t, nil
+		}
+	}
+	return NotHandled, ErrInvalidHandlingEventType
+}
+
+// handlingEventTypes maps the string representation of every handling event
+// type back to the type.
+var handlingEventTypes = map[string]HandlingEventType{
+	NotHandled.String(): NotHandled,
+	Load.String():       Load,
+	Unload.String():     Unload,
+	Receive.String():    Receive,
+	Claim.String():      Claim,
+	Customs.String():    Customs,
+}
+
 // HandlingHistory is the handling history of a cargo.
 type HandlingHistory struct {
 	HandlingEvents []HandlingEvent
@@ -75,44 +326,376 @@ func (h HandlingHistory) MostRecentlyCompletedEvent() (HandlingEvent, error) {
 	return h.HandlingEvents[len(h.HandlingEvents)-1], nil
 }
 
+// Current returns a copy of the history without the events that have been
+// superseded by amendments, or that are planned but not yet confirmed.
+func (h HandlingHistory) Current() HandlingHistory {
+	var events []HandlingEvent
+	for _, e := range h.HandlingEvents {
+		if !e.Superseded && !e.Planned {
+			events = append(events, e)
+		}
+	}
+	return HandlingHistory{HandlingEvents: events}
+}
+
+// SortedByCompletionTime returns a copy of the history with the events
+// ordered by completion time, as by CompletedBefore.
+func (h HandlingHistory) SortedByCompletionTime() HandlingHistory {
+	events := make([]HandlingEvent, len(h.HandlingEvents))
+	copy(events, h.HandlingEvents)
+	sort.SliceStable(events, func(i, j int) bool {
+		return CompletedBefore(events[i], events[j])
+	})
+	return HandlingHistory{HandlingEvents: events}
+}
+
+// Merge returns the union of the events of both histories, ordered as by
+// SortedByCompletionTime. Events of the other history with the same key as
+// an event already in the union are left out, so that an event reported by
+// both sources is kept only once, as reported by h.
+func (h HandlingHistory) Merge(other HandlingHistory) HandlingHistory {
+	var events []HandlingEvent
+	for _, e := range append(append([]HandlingEvent(nil), h.HandlingEvents...), other.HandlingEvents...) {
+		if !containsKey(events, e.Key()) {
+			events = append(events, e)
+		}
+	}
+	return HandlingHistory{HandlingEvents: events}.SortedByCompletionTime()
+}
+
+func containsKey(events []HandlingEvent, key HandlingEventKey) bool {
+	for _, e := range events {
+		if key.Matches(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// handlingEventPrecedence orders the types of events completed at the same
+// time in the order a cargo goes through them.
+var handlingEventPrecedence = map[HandlingEventType]int{
+	NotHandled: 0,
+	Receive:    1,
+	Load:       2,
+	Unload:     3,
+	Customs:    4,
+	Claim:      5,
+}
+
+// CompletedBefore returns whether a is ordered before b in a handling
+// history. Events are ordered by completion time, then by registration time,
+// and then by type in the order a cargo goes through them: receive, load,
+// unload, customs and claim. Events that tie on all three keep their relative
+// order in a stable sort.
+func CompletedBefore(a, b HandlingEvent) bool {
+	if !a.CompletionTime.Equal(b.CompletionTime) {
+		return a.CompletionTime.Before(b.CompletionTime)
+	}
+	if !a.RegistrationTime.Equal(b.RegistrationTime) {
+		return a.RegistrationTime.Before(b.RegistrationTime)
+	}
+	return handlingEventPrecedence[a.Activity.Type] < handlingEventPrecedence[b.Activity.Type]
+}
+
+// CompletedBy returns a copy of the history with only the events completed
+// on or before t.
+func (h HandlingHistory) CompletedBy(t time.Time) HandlingHistory {
+	var events []HandlingEvent
+	for _, e := range h.HandlingEvents {
+		if !e.CompletionTime.After(t) {
+			events = append(events, e)
+		}
+	}
+	return HandlingHistory{HandlingEvents: events}
+}
+
+// CompletedAfter returns a copy of the history with only the events completed
+// after t.
+func (h HandlingHistory) CompletedAfter(t time.Time) HandlingHistory {
+	var events []HandlingEvent
+	for _, e := range h.HandlingEvents {
+		if e.CompletionTime.After(t) {
+			events = append(events, e)
+		}
+	}
+	return HandlingHistory{HandlingEvents: events}
+}
+
+// FilterByType returns a copy of the history with only the events of the
+// given types, in their original order. Without types, all events are kept.
+func (h HandlingHistory) FilterByType(types ...HandlingEventType) HandlingHistory {
+	if len(types) == 0 {
+		return HandlingHistory{HandlingEvents: append([]HandlingEvent(nil), h.HandlingEvents...)}
+	}
+
+	var events []HandlingEvent
+	for _, e := range h.HandlingEvents {
+		for _, typ := range types {
+			if e.Activity.Type == typ {
+				events = append(events, e)
+				break
+			}
+		}
+	}
+	return HandlingHistory{HandlingEvents: events}
+}
+
+// Validate walks the events in order of completion and reports every
+// inconsistency between consecutive loads and unloads: a cargo loaded twice
+// without being unloaded in between, unloaded without having been loaded,
+// unloaded from another voyage than the one it was loaded onto, or unloaded at
+// the location where it was loaded. Events of other types are ignored.
+//
+// Whether an unload location is part of the voyage schedule can not be
+// checked from the history alone.
+func (h HandlingHistory) Validate() []error {
+	order := make([]int, len(h.HandlingEvents))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return CompletedBefore(h.HandlingEvents[order[i]], h.HandlingEvents[order[j]])
+	})
+
+	var (
+		errs   []error
+		loaded = -1
+	)
+
+	for _, i := range order {
+		e := h.HandlingEvents[i]
+
+		switch e.Activity.Type {
+		case Load:
+			if loaded >= 0 {
+				errs = append(errs, &HandlingSequenceError{
+					Indices: []int{loaded, i},
+					Reason:  "loaded twice without being unloaded",
+				})
+			}
+			loaded = i
+		case Unload:
+			if loaded < 0 {
+				errs = append(errs, &HandlingSequenceError{
+					Indices: []int{i},
+					Reason:  "unloaded without being loaded",
+				})
+				continue
+			}
+
+			load := h.HandlingEvents[loaded].Activity
+			if e.Activity.VoyageNumber != load.VoyageNumber {
+				errs = append(errs, &HandlingSequenceError{
+					Indices: []int{loaded, i},
+					Reason:  fmt.Sprintf("loaded onto voyage %s but unloaded from voyage %s", load.VoyageNumber, e.Activity.VoyageNumber),
+				})
+			} else if e.Activity.Location == load.Location {
+				errs = append(errs, &HandlingSequenceError{
+					Indices: []int{loaded, i},
+					Reason:  fmt.Sprintf("unloaded at %s where it was loaded", e.Activity.Location),
+				})
+			}
+			loaded = -1
+		}
+	}
+
+	return errs
+}
+
+// HandlingSequenceError describes an inconsistency between handling events.
+// Indices refer to the offending events in the handling history.
+type HandlingSequenceError struct {
+	Indices []int
+	Reason  string
+}
+
+func (e *HandlingSequenceError) Error() string {
+	return fmt.Sprintf("handling events %v: %s", e.Indices, e.Reason)
+}
+
+// ErrUnknownHandlingEvent is used when a handling event could not be found.
+var ErrUnknownHandlingEvent = NewError(CodeNotFound, "unknown handling event")
+
+// ErrDuplicateEvent is used when storing a handling event that is identical
+// to one already stored, which most likely is the result of a double scan.
+var ErrDuplicateEvent = NewError(CodeAlreadyExists, "duplicate handling event")
+
+// ErrDuplicateIdempotencyKey is used when storing a handling event with the
+// idempotency key of one already stored, such as when a registration is
+// retried concurrently with itself.
+var ErrDuplicateIdempotencyKey = NewError(CodeAlreadyExists, "duplicate idempotency key")
+
 // HandlingEventRepository provides access a handling event store.
 type HandlingEventRepository interface {
-	Store(e HandlingEvent)
-	QueryHandlingHistory(TrackingID) HandlingHistory
+	// Store stores a handling event. Implementations may reject an event
+	// with the same tracking ID, activity and completion time as a stored
+	// one with ErrDuplicateEvent. They must reject an event with the
+	// idempotency key of a stored one with ErrDuplicateIdempotencyKey, as
+	// part of the same write.
+	Store(ctx context.Context, e HandlingEvent) error
+	QueryHandlingHistory(ctx context.Context, id TrackingID) HandlingHistory
+	FindByIdempotencyKey(ctx context.Context, key string) (HandlingEvent, error)
+
+	// Supersede marks the stored event identified by the key as superseded.
+	// It returns ErrUnknownHandlingEvent if there is no such event.
+	Supersede(ctx context.Context, key HandlingEventKey) error
+
+	// Confirm marks the stored planned event identified by the key as having
+	// happened. It returns ErrUnknownHandlingEvent if there is no such event.
+	Confirm(ctx context.Context, key HandlingEventKey) error
+
+	// QueryHandlingHistoryBetween returns the events completed within the
+	// inclusive range [from, to], ordered by completion time.
+	QueryHandlingHistoryBetween(ctx context.Context, id TrackingID, from, to time.Time) HandlingHistory
+
+	// Stats counts the events of all cargos completed within the inclusive
+	// range [from, to].
+	Stats(ctx context.Context, from, to time.Time) (HandlingStats, error)
+
+	// QueryHandlingEventsSince returns the events of all cargos completed
+	// after the given time, ordered by completion time.
+	QueryHandlingEventsSince(ctx context.Context, since time.Time) []HandlingEvent
+
+	// QuerySince returns the events of all cargos stored after the one with
+	// the given sequence number, ordered by sequence number. Consumers pass
+	// the last sequence number they have seen to fetch only what is new.
+	QuerySince(ctx context.Context, seq uint64) ([]HandlingEvent, error)
+
+	// Search returns at most criteria.Limit of the events of all cargos that
+	// match the criteria, latest completed first, starting at
+	// criteria.Offset, together with the total number of matching events.
+	// It returns ErrInvalidArgument for a negative offset or limit.
+	Search(ctx context.Context, criteria SearchCriteria) ([]HandlingEvent, int, error)
+}
+
+// SearchCriteria selects handling events across cargos. Zero fields match
+// any event.
+type SearchCriteria struct {
+	Type         HandlingEventType
+	Location     UNLocode
+	VoyageNumber VoyageNumber
+
+	// From and To bound the completion time of the events, inclusively.
+	From, To time.Time
+
+	// Offset is the number of matching events to skip, and Limit the
+	// maximum number of events to return. A zero limit returns all events
+	// after the offset.
+	Offset, Limit int
+}
+
+// Matches returns whether the event matches the criteria, disregarding the
+// offset and limit.
+func (c SearchCriteria) Matches(e HandlingEvent) bool {
+	switch {
+	case c.Type != NotHandled && e.Activity.Type != c.Type:
+		return false
+	case c.Location != "" && e.Activity.Location != c.Location:
+		return false
+	case c.VoyageNumber != "" && e.Activity.VoyageNumber != c.VoyageNumber:
+		return false
+	case !c.From.IsZero() && e.CompletionTime.Before(c.From):
+		return false
+	case !c.To.IsZero() && e.CompletionTime.After(c.To):
+		return false
+	}
+	return true
+}
+
+// HandlingStats holds the number of handling events in a time window, in
+// total and grouped by event type, location and day of completion.
+type HandlingStats struct {
+	Total      int
+	ByType     map[HandlingEventType]int
+	ByLocation map[UNLocode]int
+
+	// ByDay is keyed by the date of completion in UTC, formatted as
+	// 2006-01-02.
+	ByDay map[string]int
+}
+
+// CountHandlingEvents tallies the given events.
+func CountHandlingEvents(events []HandlingEvent) HandlingStats {
+	s := HandlingStats{
+		ByType:     make(map[HandlingEventType]int),
+		ByLocation: make(map[UNLocode]int),
+		ByDay:      make(map[string]int),
+	}
+	for _, e := range events {
+		s.Total++
+		s.ByType[e.Activity.Type]++
+		s.ByLocation[e.Activity.Location]++
+		s.ByDay[e.CompletionTime.UTC().Format("2006-01-02")]++
+	}
+	return s
 }
 
 // HandlingEventFactory creates handling events.
 type HandlingEventFactory struct {
+	// CargoRepository is optional. With it, events can only be created for
+	// booked cargos; without it, the cargo of an event is not looked up, so
+	// that handling can be registered independently of booking.
 	CargoRepository    CargoRepository
 	VoyageRepository   VoyageRepository
 	LocationRepository LocationRepository
 }
 
-// CreateHandlingEvent creates a validated handling event.
-func (f *HandlingEventFactory) CreateHandlingEvent(registered time.Time, completed time.Time, id TrackingID,
-	voyageNumber VoyageNumber, unLocode UNLocode, eventType HandlingEventType) (HandlingEvent, error) {
+// CreateHandlingEvent creates a validated handling event. The equipment is
+// optional, and is not validated. The registration and completion times are
+// converted to UTC. Loads and unloads carry the instructions of the leg they
+// handle in the itinerary of the cargo. A cargo on hold may be handled, but
+// not loaded: loading it returns ErrCargoOnHold. It returns ErrUnknownCargo
+// if the factory has a cargo repository without the cargo.
+func (f *HandlingEventFactory) CreateHandlingEvent(ctx context.Context, registered time.Time, completed time.Time, id TrackingID,
+	voyageNumber VoyageNumber, unLocode UNLocode, eventType HandlingEventType, equipment EquipmentID) (HandlingEvent, error) {
 
-	if _, err := f.CargoRepository.Find(id); err != nil {
-		return HandlingEvent{}, err
+	c := &Cargo{TrackingID: id}
+	if f.CargoRepository != nil {
+		var err error
+		if c, err = f.CargoRepository.Find(ctx, id); err != nil {
+			return HandlingEvent{}, err
+		}
 	}
 
-	if _, err := f.VoyageRepository.Find(voyageNumber); err != nil {
-		// TODO: This is pretty ugly, but when creating a Receive event, the voyage number is not known.
-		if len(voyageNumber) > 0 {
+	if eventType == Load && c.OnHold {
+		return HandlingEvent{}, ErrCargoOnHold
+	}
+
+	// Receive, Claim and Customs events are not associated with a voyage, so
+	// there is nothing to look up, and any voyage given is not stored.
+	switch eventType {
+	case Load, Unload:
+		if voyageNumber == "" {
+			return HandlingEvent{}, ErrInvalidArgument
+		}
+		if _, err := f.VoyageRepository.Find(ctx, voyageNumber); err != nil {
 			return HandlingEvent{}, err
 		}
+	default:
+		voyageNumber = ""
 	}
 
-	if _, err := f.LocationRepository.Find(unLocode); err != nil {
+	unLocode, err := NewUNLocode(string(unLocode))
+	if err != nil {
+		return HandlingEvent{}, err
+	}
+	if _, err := f.LocationRepository.Find(ctx, unLocode); err != nil {
 		return HandlingEvent{}, err
 	}
 
+	activity := HandlingActivity{
+		Type:         eventType,
+		Location:     unLocode,
+		VoyageNumber: voyageNumber,
+	}
+
 	return HandlingEvent{
-		TrackingID: id,
-		Activity: HandlingActivity{
-			Type:         eventType,
-			Location:     unLocode,
-			VoyageNumber: voyageNumber,
-		},
+		TrackingID:       id,
+		Activity:         activity,
+		RegistrationTime: registered.UTC(),
+		CompletionTime:   completed.UTC(),
+		EquipmentID:      equipment,
+		Instructions:     c.Itinerary.InstructionsFor(activity),
 	}, nil
 }
@@ -0,0 +1,340 @@
+package handling
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inspection"
+)
+
+// AsyncEventHandler is an EventHandler that inspects handled cargos in the
+// background rather than as part of the registration.
+type AsyncEventHandler interface {
+	EventHandler
+
+	// Close stops accepting new events and blocks until all queued and
+	// in-flight inspections have completed, or the context is done.
+	Close(ctx context.Context) error
+
+	// Resume enqueues the stored events with a sequence number after the
+	// offset recorded in the offset store, so that events handled while the handler was
+	// down are inspected. It is meant to be called once on startup, and is a
+	// no-op without an offset store.
+	Resume(ctx context.Context) error
+}
+
+// DefaultInspectionAttempts is the number of times an inspection is
+// attempted before the event is given up on.
+const DefaultInspectionAttempts = 3
+
+// DeadLetterSink records handled events whose inspection failed.
+type DeadLetterSink interface {
+	Record(event shipping.HandlingEvent, err error)
+}
+
+// OffsetStore records how far an AsyncEventHandler has come, as the
+// sequence number of the latest event it has inspected.
+type OffsetStore interface {
+	// Offset returns the recorded offset, or zero if there is none.
+	Offset(ctx context.Context) (uint64, error)
+	SaveOffset(ctx context.Context, offset uint64) error
+}
+
+// AsyncOption configures an AsyncEventHandler.
+type AsyncOption func(*asyncEventHandler)
+
+// WithDeadLetterSink records events whose inspection failed in every attempt
+// to the given sink. Without a sink, such events are dropped.
+func WithDeadLetterSink(sink DeadLetterSink) AsyncOption {
+	return func(h *asyncEventHandler) {
+		h.deadLetters = sink
+	}
+}
+
+// WithOffsetStore records the offset of inspected events to the given store,
+// and lets Resume replay the events stored in the repository after it.
+//
+// Events are not guaranteed to be inspected in order of sequence when there
+// is more than one worker, in which case events that were in flight when the
+// handler stopped may be skipped on resume.
+func WithOffsetStore(store OffsetStore, events shipping.HandlingEventRepository) AsyncOption {
+	return func(h *asyncEventHandler) {
+		h.offsets = store
+		h.events = events
+	}
+}
+
+// WithInspectionAttempts sets the number of times an inspection is
+// attempted, waiting for backoff between attempts.
+func WithInspectionAttempts(n int, backoff time.Duration) AsyncOption {
+	return func(h *asyncEventHandler) {
+		if n < 1 {
+			n = 1
+		}
+		h.attempts = n
+		h.backoff = backoff
+	}
+}
+
+type asyncEventHandler struct {
+	inspection  inspection.Service
+	deadLetters DeadLetterSink
+	attempts    int
+	backoff     time.Duration
+
+	offsets   OffsetStore
+	events    shipping.HandlingEventRepository
+	offsetMtx sync.Mutex
+	offset    uint64
+
+	mtx    sync.RWMutex
+	closed bool
+	queue  chan queuedEvent
+	wg     sync.WaitGroup
+}
+
+// queuedEvent is an event waiting to be inspected, along with the correlation
+// ID of its registration.
+type queuedEvent struct {
+	event       shipping.HandlingEvent
+	correlation shipping.CorrelationID
+}
+
+// CargoWasHandled enqueues the event for inspection. If the buffer is full,
+// CargoWasHandled blocks until a worker is available. Events handled after
+// Close has been called are inspected synchronously.
+//
+// Queued events are inspected with a background context, since the context of
+// the registration is likely to be done by the time a worker picks them up.
+// Only the correlation ID of the registration is kept.
+func (h *asyncEventHandler) CargoWasHandled(ctx context.Context, event shipping.HandlingEvent) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	if h.closed {
+		h.process(ctx, event)
+		return
+	}
+
+	h.queue <- queuedEvent{event: event, correlation: shipping.CorrelationFromContext(ctx)}
+}
+
+func (h *asyncEventHandler) Close(ctx context.Context) error {
+	h.mtx.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mtx.Unlock()
+
+	return wait(ctx, &h.wg)
+}
+
+// wait blocks until wg is done or the context is done.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *asyncEventHandler) Resume(ctx context.Context) error {
+	if h.offsets == nil {
+		return nil
+	}
+
+	offset, err := h.offsets.Offset(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.offsetMtx.Lock()
+	if offset > h.offset {
+		h.offset = offset
+	}
+	h.offsetMtx.Unlock()
+
+	events, err := h.events.QuerySince(ctx, offset)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		h.CargoWasHandled(ctx, e)
+	}
+	return nil
+}
+
+// advance records the sequence number of an inspected event as the offset,
+// unless a later event has already been recorded. A failure to save the
+// offset is ignored, as it only means the event is inspected again on
+// resume.
+func (h *asyncEventHandler) advance(ctx context.Context, event shipping.HandlingEvent) {
+	if h.offsets == nil {
+		return
+	}
+
+	seq := h.sequence(ctx, event)
+
+	h.offsetMtx.Lock()
+	defer h.offsetMtx.Unlock()
+
+	if seq <= h.offset {
+		return
+	}
+	if err := h.offsets.SaveOffset(ctx, seq); err == nil {
+		h.offset = seq
+	}
+}
+
+// sequence returns the sequence number of the event. Events are handled
+// before the repository has told the caller which sequence number it
+// assigned, so unless the event was replayed, it is looked up in the history
+// of the cargo. It returns zero if the event is not stored.
+func (h *asyncEventHandler) sequence(ctx context.Context, event shipping.HandlingEvent) uint64 {
+	if event.Sequence != 0 {
+		return event.Sequence
+	}
+	key := event.Key()
+	for _, e := range h.events.QueryHandlingHistory(ctx, event.TrackingID).HandlingEvents {
+		if key.Matches(e) {
+			return e.Sequence
+		}
+	}
+	return 0
+}
+
+func (h *asyncEventHandler) work() {
+	defer h.wg.Done()
+	for q := range h.queue {
+		h.process(shipping.NewCorrelationContext(context.Background(), q.correlation), q.event)
+	}
+}
+
+// process inspects the handled cargo, retrying failed inspections. If every
+// attempt fails, the event is recorded as a dead letter.
+func (h *asyncEventHandler) process(ctx context.Context, event shipping.HandlingEvent) {
+	var err error
+	for i := 0; i < h.attempts; i++ {
+		if i > 0 {
+			time.Sleep(h.backoff)
+		}
+		if err = h.inspect(ctx, event.TrackingID); err == nil {
+			h.advance(ctx, event)
+			return
+		}
+	}
+
+	if h.deadLetters != nil {
+		h.deadLetters.Record(event, err)
+	}
+}
+
+// inspect inspects the cargo, turning a panic into an error so that the
+// worker survives it.
+func (h *asyncEventHandler) inspect(ctx context.Context, id shipping.TrackingID) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("inspection panicked: %v", r)
+		}
+	}()
+	_, err = h.inspection.InspectCargoResult(ctx, id)
+	return err
+}
+
+// NewAsyncEventHandler returns a new instance of an AsyncEventHandler that
+// inspects cargos using the given number of workers, and buffers up to buffer
+// events waiting to be inspected. A failed inspection is attempted
+// DefaultInspectionAttempts times, unless configured otherwise.
+func NewAsyncEventHandler(s inspection.Service, workers int, buffer int, opts ...AsyncOption) AsyncEventHandler {
+	if workers < 1 {
+		workers = 1
+	}
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	h := &asyncEventHandler{
+		inspection: s,
+		attempts:   DefaultInspectionAttempts,
+		queue:      make(chan queuedEvent, buffer),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go h.work()
+	}
+
+	return h
+}
+
+// DeadLetter is a handled event whose inspection failed.
+type DeadLetter struct {
+	Event shipping.HandlingEvent
+	Err   error
+}
+
+// DeadLetterQueue is a DeadLetterSink that keeps the recorded events in
+// memory.
+type DeadLetterQueue struct {
+	mtx     sync.Mutex
+	letters []DeadLetter
+}
+
+// NewDeadLetterQueue returns an empty DeadLetterQueue.
+func NewDeadLetterQueue() *DeadLetterQueue {
+	return &DeadLetterQueue{}
+}
+
+// Record appends the event to the queue.
+func (q *DeadLetterQueue) Record(event shipping.HandlingEvent, err error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.letters = append(q.letters, DeadLetter{Event: event, Err: err})
+}
+
+// Letters returns the recorded events, in the order they were recorded.
+func (q *DeadLetterQueue) Letters() []DeadLetter {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return append([]DeadLetter(nil), q.letters...)
+}
+
+// MemoryOffsetStore is an OffsetStore that keeps the offset in memory.
+type MemoryOffsetStore struct {
+	mtx    sync.Mutex
+	offset uint64
+}
+
+// NewMemoryOffsetStore returns a MemoryOffsetStore without an offset.
+func NewMemoryOffsetStore() *MemoryOffsetStore {
+	return &MemoryOffsetStore{}
+}
+
+// Offset returns the saved offset.
+func (s *MemoryOffsetStore) Offset(ctx context.Context) (uint64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.offset, nil
+}
+
+// SaveOffset saves the offset.
+func (s *MemoryOffsetStore) SaveOffset(ctx context.Context, offset uint64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.offset = offset
+	return nil
+}
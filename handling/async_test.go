@@ -0,0 +1,236 @@
+package handling
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
+	"github.com/marcusolsson/goddd/inspection"
+)
+
+type stubInspectionService struct {
+	mtx       sync.Mutex
+	delay     time.Duration
+	inspected []shipping.TrackingID
+}
+
+func (s *stubInspectionService) InspectCargo(ctx context.Context, id shipping.TrackingID) {
+	time.Sleep(s.delay)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.inspected = append(s.inspected, id)
+}
+
+func (s *stubInspectionService) InspectCargoResult(ctx context.Context, id shipping.TrackingID) (inspection.InspectionResult, error) {
+	s.InspectCargo(ctx, id)
+	return inspection.InspectionResult{}, nil
+}
+
+func (s *stubInspectionService) InspectCargos(ctx context.Context, ids []shipping.TrackingID) map[shipping.TrackingID]error {
+	for _, id := range ids {
+		s.InspectCargo(ctx, id)
+	}
+	return nil
+}
+
+func (s *stubInspectionService) PreviewEventImpact(ctx context.Context, candidate shipping.HandlingEvent) (shipping.Delivery, shipping.Delivery, error) {
+	return shipping.Delivery{}, shipping.Delivery{}, nil
+}
+
+func TestAsyncEventHandler(t *testing.T) {
+	ctx := context.Background()
+
+	is := &stubInspectionService{delay: 10 * time.Millisecond}
+
+	h := NewAsyncEventHandler(is, 2, 1)
+
+	ids := []shipping.TrackingID{"A", "B", "C", "D", "E"}
+	for _, id := range ids {
+		h.CargoWasHandled(ctx, shipping.HandlingEvent{TrackingID: id})
+	}
+
+	if err := h.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(is.inspected) != len(ids) {
+		t.Errorf("len(inspected) = %d; want = %d", len(is.inspected), len(ids))
+	}
+
+	// Events handled after closing are inspected synchronously.
+	h.CargoWasHandled(ctx, shipping.HandlingEvent{TrackingID: "F"})
+
+	if len(is.inspected) != len(ids)+1 {
+		t.Errorf("len(inspected) = %d; want = %d", len(is.inspected), len(ids)+1)
+	}
+
+	// Closing again is a no-op.
+	if err := h.Close(ctx); err != nil {
+		t.Errorf("err = %v; want = %v", err, nil)
+	}
+}
+
+func TestAsyncEventHandler_CloseTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	is := &stubInspectionService{delay: 100 * time.Millisecond}
+
+	h := NewAsyncEventHandler(is, 1, 1)
+	h.CargoWasHandled(ctx, shipping.HandlingEvent{TrackingID: "A"})
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	if err := shipping.Shutdown(timeout, h); err != context.DeadlineExceeded {
+		t.Errorf("err = %v; want = %v", err, context.DeadlineExceeded)
+	}
+
+	// The inspection in flight still completes.
+	if err := h.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(is.inspected) != 1 {
+		t.Errorf("len(inspected) = %d; want = %d", len(is.inspected), 1)
+	}
+}
+
+// failingInspectionService fails to inspect the given cargo, by returning an
+// error or by panicking.
+type failingInspectionService struct {
+	stubInspectionService
+	fail     shipping.TrackingID
+	panics   bool
+	attempts int
+}
+
+func (s *failingInspectionService) InspectCargoResult(ctx context.Context, id shipping.TrackingID) (inspection.InspectionResult, error) {
+	if id != s.fail {
+		return s.stubInspectionService.InspectCargoResult(ctx, id)
+	}
+
+	s.mtx.Lock()
+	s.attempts++
+	s.mtx.Unlock()
+
+	if s.panics {
+		panic("inspection failed")
+	}
+	return inspection.InspectionResult{}, errInspection
+}
+
+var errInspection = errors.New("inspection failed")
+
+func TestAsyncEventHandler_DeadLetters(t *testing.T) {
+	ctx := context.Background()
+
+	for _, panics := range []bool{false, true} {
+		is := &failingInspectionService{fail: "B", panics: panics}
+		dlq := NewDeadLetterQueue()
+
+		h := NewAsyncEventHandler(is, 1, 3, WithDeadLetterSink(dlq), WithInspectionAttempts(2, time.Millisecond))
+
+		for _, id := range []shipping.TrackingID{"A", "B", "C"} {
+			h.CargoWasHandled(ctx, shipping.HandlingEvent{TrackingID: id})
+		}
+
+		h.Close(ctx)
+
+		if is.attempts != 2 {
+			t.Errorf("panics = %v: attempts = %d; want = %d", panics, is.attempts, 2)
+		}
+
+		// The worker continues with the events after the failure.
+		if len(is.inspected) != 2 {
+			t.Errorf("panics = %v: len(inspected) = %d; want = %d", panics, len(is.inspected), 2)
+		}
+
+		letters := dlq.Letters()
+		if len(letters) != 1 {
+			t.Fatalf("panics = %v: len(letters) = %d; want = %d", panics, len(letters), 1)
+		}
+		if letters[0].Event.TrackingID != "B" {
+			t.Errorf("panics = %v: TrackingID = %s; want = %s", panics, letters[0].Event.TrackingID, "B")
+		}
+		if letters[0].Err == nil || !panics && letters[0].Err != errInspection {
+			t.Errorf("panics = %v: err = %v; want = %v", panics, letters[0].Err, errInspection)
+		}
+	}
+}
+
+func TestAsyncEventHandler_Resume(t *testing.T) {
+	ctx := context.Background()
+
+	t0 := time.Date(2015, time.November, 10, 0, 0, 0, 0, time.UTC)
+
+	var handled []shipping.HandlingEvent
+	for i, id := range []shipping.TrackingID{"A", "B", "C", "D"} {
+		handled = append(handled, shipping.HandlingEvent{
+			TrackingID: id,
+			Activity: shipping.HandlingActivity{
+				Type:     shipping.Receive,
+				Location: shipping.SESTO,
+			},
+			CompletionTime: t0.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	// The last event is reported late, completed before the others.
+	handled[3].CompletionTime = t0.Add(-time.Hour)
+
+	var (
+		events  = inmem.NewHandlingEventRepository()
+		offsets = NewMemoryOffsetStore()
+	)
+
+	// The first two events are handled before the handler stops.
+	is := &stubInspectionService{}
+
+	h := NewAsyncEventHandler(is, 1, 0, WithOffsetStore(offsets, events))
+	if err := h.Resume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range handled[:2] {
+		if err := events.Store(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		h.CargoWasHandled(ctx, e)
+	}
+	if err := h.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := offsets.Offset(ctx); got != 2 {
+		t.Errorf("Offset() = %v; want = %v", got, 2)
+	}
+
+	// The rest are stored while the handler is down.
+	for _, e := range handled[2:] {
+		if err := events.Store(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// On restart, only the events after the offset are inspected.
+	is = &stubInspectionService{}
+
+	h = NewAsyncEventHandler(is, 1, 0, WithOffsetStore(offsets, events))
+	if err := h.Resume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []shipping.TrackingID{"C", "D"}; !reflect.DeepEqual(is.inspected, want) {
+		t.Errorf("inspected = %v; want = %v", is.inspected, want)
+	}
+	if got, _ := offsets.Offset(ctx); got != 4 {
+		t.Errorf("Offset() = %v; want = %v", got, 4)
+	}
+}
@@ -0,0 +1,108 @@
+package handling
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// ImportError describes a row in an imported file that could not be
+// registered.
+type ImportError struct {
+	Line int
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// csvHeader is the optional first row of an imported file.
+var csvHeader = []string{"tracking_id", "event_type", "location", "voyage_number", "completion_time"}
+
+// ImportCSV registers the handling events read from r with s. Each row
+// describes an event as a tracking ID, an event type, a location, a voyage
+// number and a completion time in RFC 3339 format. The voyage number may be
+// empty for events that are not carried out on a voyage.
+//
+// A row that is malformed, or whose event could not be registered, does not
+// stop the import. Such rows are returned as *ImportError in the order they
+// appear. The returned error is only set if r could not be read.
+func ImportCSV(ctx context.Context, r io.Reader, s Service) ([]error, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	cr.TrimLeadingSpace = true
+
+	var errs []error
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if pe, ok := err.(*csv.ParseError); ok {
+				errs = append(errs, &ImportError{Line: pe.Line, Err: pe.Err})
+				continue
+			}
+			return errs, err
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		if first && isHeader(rec) {
+			continue
+		}
+
+		if err := importEvent(ctx, s, rec); err != nil {
+			errs = append(errs, &ImportError{Line: line, Err: err})
+		}
+	}
+
+	return errs, nil
+}
+
+func isHeader(rec []string) bool {
+	for i, f := range rec {
+		if !strings.EqualFold(f, csvHeader[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func importEvent(ctx context.Context, s Service, rec []string) error {
+	if rec[0] == "" {
+		return fmt.Errorf("missing tracking ID")
+	}
+	id := shipping.TrackingID(rec[0])
+
+	typ, err := shipping.ParseHandlingEventType(rec[1])
+	if err != nil {
+		return fmt.Errorf("invalid event type %q", rec[1])
+	}
+
+	loc, err := shipping.NewUNLocode(rec[2])
+	if err != nil {
+		return fmt.Errorf("invalid location %q", rec[2])
+	}
+
+	var voyage shipping.VoyageNumber
+	if rec[3] != "" {
+		if voyage, err = shipping.NewVoyageNumber(rec[3]); err != nil {
+			return fmt.Errorf("invalid voyage number %q", rec[3])
+		}
+	}
+
+	completed, err := time.Parse(time.RFC3339, rec[4])
+	if err != nil {
+		return fmt.Errorf("invalid completion time %q", rec[4])
+	}
+
+	_, err = s.RegisterHandlingEvent(ctx, completed, id, voyage, loc, typ)
+	return err
+}
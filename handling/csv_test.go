@@ -0,0 +1,84 @@
+package handling
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// registeringService is a service that records the events it registers, and
+// fails to register those of unknown cargos.
+type registeringService struct {
+	stubService
+	registered []shipping.HandlingEvent
+}
+
+func (s *registeringService) RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+	loc shipping.UNLocode, eventType shipping.HandlingEventType, opts ...RegistrationOption) (shipping.HandlingEvent, error) {
+	if id == "XYZ" {
+		return shipping.HandlingEvent{}, shipping.ErrUnknownCargo
+	}
+
+	e := shipping.HandlingEvent{
+		TrackingID: id,
+		Activity: shipping.HandlingActivity{
+			Type:         eventType,
+			Location:     loc,
+			VoyageNumber: voyageNumber,
+		},
+		CompletionTime: completed,
+	}
+	s.registered = append(s.registered, e)
+	return e, nil
+}
+
+func TestImportCSV(t *testing.T) {
+	const in = `tracking_id,event_type,location,voyage_number,completion_time
+ABC,Receive,SESTO,,2009-03-01T00:00:00Z
+ABC,load,SESTO,V100,2009-03-02T00:00:00Z
+ABC,Load,SESTO
+ABC,Jump,SESTO,V100,2009-03-02T00:00:00Z
+,Load,SESTO,V100,2009-03-02T00:00:00Z
+ABC,Unload,SE-STO,V100,2009-03-03T00:00:00Z
+ABC,Unload,AUMEL,V100,yesterday
+XYZ,Receive,SESTO,,2009-03-01T00:00:00Z
+ABC,Unload,AUMEL,V100,2009-03-03T00:00:00Z
+`
+
+	var s registeringService
+
+	errs, err := ImportCSV(context.Background(), strings.NewReader(in), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []int
+	for _, err := range errs {
+		ierr, ok := err.(*ImportError)
+		if !ok {
+			t.Fatalf("err = %v; want = *ImportError", err)
+		}
+		lines = append(lines, ierr.Line)
+	}
+	if want := []int{4, 5, 6, 7, 8, 9}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %v; want = %v", lines, want)
+	}
+	if err := errs[len(errs)-1].(*ImportError).Err; err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	want := []shipping.HandlingEvent{
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}, CompletionTime: t0},
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}, CompletionTime: t0.AddDate(0, 0, 1)},
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.AUMEL, VoyageNumber: "V100"}, CompletionTime: t0.AddDate(0, 0, 2)},
+	}
+	if !reflect.DeepEqual(s.registered, want) {
+		t.Errorf("registered = %v; want = %v", s.registered, want)
+	}
+}
@@ -1,6 +1,7 @@
 package handling
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
@@ -15,6 +16,9 @@ type instrumentingService struct {
 }
 
 // NewInstrumentingService returns an instance of an instrumenting Service.
+// Registrations are counted by method and event type, so the counter is
+// expected to be labeled with "method" and "event_type". The latency is
+// observed by method only.
 func NewInstrumentingService(counter metrics.Counter, latency metrics.Histogram, s Service) Service {
 	return &instrumentingService{
 		requestCount:   counter,
@@ -23,13 +27,51 @@ func NewInstrumentingService(counter metrics.Counter, latency metrics.Histogram,
 	}
 }
 
-func (s *instrumentingService) RegisterHandlingEvent(completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
-	loc shipping.UNLocode, eventType shipping.HandlingEventType) error {
+func (s *instrumentingService) RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+	loc shipping.UNLocode, eventType shipping.HandlingEventType, opts ...RegistrationOption) (shipping.HandlingEvent, error) {
 
 	defer func(begin time.Time) {
-		s.requestCount.With("method", "register_incident").Add(1)
+		s.requestCount.With("method", "register_incident", "event_type", eventType.String()).Add(1)
 		s.requestLatency.With("method", "register_incident").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.RegisterHandlingEvent(completed, id, voyageNumber, loc, eventType)
+	return s.next.RegisterHandlingEvent(ctx, completed, id, voyageNumber, loc, eventType, opts...)
+}
+
+func (s *instrumentingService) RegisterHandlingEvents(ctx context.Context, events []HandlingEventRegistration) ([]error, error) {
+	defer func(begin time.Time) {
+		for _, e := range events {
+			s.requestCount.With("method", "register_incidents", "event_type", e.EventType.String()).Add(1)
+		}
+		s.requestLatency.With("method", "register_incidents").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.RegisterHandlingEvents(ctx, events)
+}
+
+func (s *instrumentingService) SearchHandlingEvents(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "search_events", "event_type", criteria.Type.String()).Add(1)
+		s.requestLatency.With("method", "search_events").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.SearchHandlingEvents(ctx, criteria)
+}
+
+func (s *instrumentingService) AmendHandlingEvent(ctx context.Context, original shipping.HandlingEvent, corrections ...Correction) error {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "amend_incident", "event_type", original.Activity.Type.String()).Add(1)
+		s.requestLatency.With("method", "amend_incident").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.AmendHandlingEvent(ctx, original, corrections...)
+}
+
+func (s *instrumentingService) ConfirmPlannedEvent(ctx context.Context, planned shipping.HandlingEvent) error {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "confirm_planned_incident", "event_type", planned.Activity.Type.String()).Add(1)
+		s.requestLatency.With("method", "confirm_planned_incident").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.ConfirmPlannedEvent(ctx, planned)
 }
@@ -0,0 +1,96 @@
+package handling
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// fakeCounter records the accumulated value per set of label values.
+type fakeCounter struct {
+	values map[string]float64
+	lvs    []string
+}
+
+func (c *fakeCounter) With(labelValues ...string) metrics.Counter {
+	return &fakeCounter{values: c.values, lvs: append(append([]string{}, c.lvs...), labelValues...)}
+}
+
+func (c *fakeCounter) Add(delta float64) {
+	c.values[strings.Join(c.lvs, ",")] += delta
+}
+
+type fakeHistogram struct {
+	observed int
+}
+
+func (h *fakeHistogram) With(labelValues ...string) metrics.Histogram { return h }
+func (h *fakeHistogram) Observe(value float64)                        { h.observed++ }
+
+type stubService struct{}
+
+func (stubService) RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+	loc shipping.UNLocode, eventType shipping.HandlingEventType, opts ...RegistrationOption) (shipping.HandlingEvent, error) {
+	return shipping.HandlingEvent{}, nil
+}
+
+func (stubService) RegisterHandlingEvents(ctx context.Context, events []HandlingEventRegistration) ([]error, error) {
+	return make([]error, len(events)), nil
+}
+
+func (stubService) AmendHandlingEvent(ctx context.Context, original shipping.HandlingEvent, corrections ...Correction) error {
+	return nil
+}
+
+func (stubService) ConfirmPlannedEvent(ctx context.Context, planned shipping.HandlingEvent) error {
+	return nil
+}
+
+func (stubService) SearchHandlingEvents(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	return nil, 0, nil
+}
+
+func TestInstrumentingService(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		counter = &fakeCounter{values: make(map[string]float64)}
+		latency = &fakeHistogram{}
+	)
+
+	s := NewInstrumentingService(counter, latency, stubService{})
+
+	completed := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	s.RegisterHandlingEvent(ctx, completed, "ABC123", "V100", shipping.SESTO, shipping.Load)
+	s.RegisterHandlingEvent(ctx, completed, "ABC123", "V100", shipping.DEHAM, shipping.Unload)
+	s.RegisterHandlingEvent(ctx, completed, "XYZ789", "V100", shipping.SESTO, shipping.Load)
+	s.RegisterHandlingEvents(ctx, []HandlingEventRegistration{
+		{Completed: completed, TrackingID: "ABC123", Location: shipping.DEHAM, EventType: shipping.Customs},
+		{Completed: completed, TrackingID: "XYZ789", Location: shipping.SESTO, EventType: shipping.Receive},
+	})
+
+	want := map[string]float64{
+		"method,register_incident,event_type,Load":     2,
+		"method,register_incident,event_type,Unload":   1,
+		"method,register_incidents,event_type,Customs": 1,
+		"method,register_incidents,event_type,Receive": 1,
+	}
+
+	for lvs, n := range want {
+		if got := counter.values[lvs]; got != n {
+			t.Errorf("counter[%s] = %v; want = %v", lvs, got, n)
+		}
+	}
+	if len(counter.values) != len(want) {
+		t.Errorf("len(counter.values) = %d; want = %d", len(counter.values), len(want))
+	}
+	if latency.observed != 4 {
+		t.Errorf("latency.observed = %d; want = %d", latency.observed, 4)
+	}
+}
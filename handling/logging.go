@@ -1,6 +1,7 @@
 package handling
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -18,8 +19,8 @@ func NewLoggingService(logger log.Logger, s Service) Service {
 	return &loggingService{logger, s}
 }
 
-func (s *loggingService) RegisterHandlingEvent(completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
-	unLocode shipping.UNLocode, eventType shipping.HandlingEventType) (err error) {
+func (s *loggingService) RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+	unLocode shipping.UNLocode, eventType shipping.HandlingEventType, opts ...RegistrationOption) (e shipping.HandlingEvent, err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "register_incident",
@@ -28,9 +29,75 @@ func (s *loggingService) RegisterHandlingEvent(completed time.Time, id shipping.
 			"voyage", voyageNumber,
 			"event_type", eventType,
 			"completion_time", completed,
+			"registration_time", e.RegistrationTime,
+			"idempotency_key", e.IdempotencyKey,
 			"took", time.Since(begin),
 			"err", err,
 		)
 	}(time.Now())
-	return s.next.RegisterHandlingEvent(completed, id, voyageNumber, unLocode, eventType)
+	return s.next.RegisterHandlingEvent(ctx, completed, id, voyageNumber, unLocode, eventType, opts...)
+}
+
+func (s *loggingService) RegisterHandlingEvents(ctx context.Context, events []HandlingEventRegistration) (errs []error, err error) {
+	defer func(begin time.Time) {
+		var failed int
+		for _, e := range errs {
+			if e != nil {
+				failed++
+			}
+		}
+		s.logger.Log(
+			"method", "register_incidents",
+			"count", len(events),
+			"failed", failed,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.RegisterHandlingEvents(ctx, events)
+}
+
+func (s *loggingService) SearchHandlingEvents(ctx context.Context, criteria shipping.SearchCriteria) (events []shipping.HandlingEvent, total int, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "search_events",
+			"event_type", criteria.Type,
+			"location", criteria.Location,
+			"voyage", criteria.VoyageNumber,
+			"total", total,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.SearchHandlingEvents(ctx, criteria)
+}
+
+func (s *loggingService) AmendHandlingEvent(ctx context.Context, original shipping.HandlingEvent, corrections ...Correction) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "amend_incident",
+			"tracking_id", original.TrackingID,
+			"location", original.Activity.Location,
+			"event_type", original.Activity.Type,
+			"completion_time", original.CompletionTime,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.AmendHandlingEvent(ctx, original, corrections...)
+}
+
+func (s *loggingService) ConfirmPlannedEvent(ctx context.Context, planned shipping.HandlingEvent) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "confirm_planned_incident",
+			"tracking_id", planned.TrackingID,
+			"location", planned.Activity.Location,
+			"event_type", planned.Activity.Type,
+			"completion_time", planned.CompletionTime,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.next.ConfirmPlannedEvent(ctx, planned)
 }
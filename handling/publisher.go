@@ -0,0 +1,41 @@
+package handling
+
+import (
+	"context"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// EventPublisher publishes registered handling events to subscribers outside
+// of the process, such as a message broker.
+type EventPublisher interface {
+	Publish(ctx context.Context, e shipping.HandlingEvent) error
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(context.Context, shipping.HandlingEvent) error { return nil }
+
+// NewNopPublisher returns an EventPublisher that discards all events.
+func NewNopPublisher() EventPublisher {
+	return nopPublisher{}
+}
+
+type channelPublisher struct {
+	c chan<- shipping.HandlingEvent
+}
+
+func (p *channelPublisher) Publish(ctx context.Context, e shipping.HandlingEvent) error {
+	select {
+	case p.c <- e:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewChannelPublisher returns an EventPublisher that sends every event on the
+// given channel, blocking until it is received or the context is done.
+func NewChannelPublisher(c chan<- shipping.HandlingEvent) EventPublisher {
+	return &channelPublisher{c: c}
+}
@@ -0,0 +1,134 @@
+package handling
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
+	"github.com/marcusolsson/goddd/mock"
+)
+
+type publisherFunc func(context.Context, shipping.HandlingEvent) error
+
+func (f publisherFunc) Publish(ctx context.Context, e shipping.HandlingEvent) error {
+	return f(ctx, e)
+}
+
+type recordingLogger struct {
+	logged [][]interface{}
+}
+
+func (l *recordingLogger) Log(keyvals ...interface{}) error {
+	l.logged = append(l.logged, keyvals)
+	return nil
+}
+
+func newTestFactory() shipping.HandlingEventFactory {
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return new(shipping.Cargo), nil
+	}
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(n shipping.VoyageNumber) (*shipping.Voyage, error) {
+		return new(shipping.Voyage), nil
+	}
+
+	var locations mock.LocationRepository
+	locations.FindFn = func(l shipping.UNLocode) (*shipping.Location, error) {
+		return nil, nil
+	}
+
+	return shipping.HandlingEventFactory{
+		CargoRepository:    &cargos,
+		VoyageRepository:   &voyages,
+		LocationRepository: &locations,
+	}
+}
+
+func TestRegisterHandlingEvent_Publish(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		events    = inmem.NewHandlingEventRepository()
+		published = make(chan shipping.HandlingEvent, 1)
+		completed = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		id        = shipping.TrackingID("ABC123")
+	)
+
+	// Verify that the event has been stored by the time it is published.
+	p := publisherFunc(func(ctx context.Context, e shipping.HandlingEvent) error {
+		if n := len(events.QueryHandlingHistory(ctx, e.TrackingID).HandlingEvents); n != 1 {
+			t.Errorf("len(HandlingEvents) = %d; want = %d", n, 1)
+		}
+		return NewChannelPublisher(published).Publish(ctx, e)
+	})
+
+	eh := &stubEventHandler{events: make([]interface{}, 0)}
+
+	s := NewService(events, newTestFactory(), eh, WithEventPublisher(p, &recordingLogger{}))
+
+	e, err := s.RegisterHandlingEvent(ctx, completed, id, "V100", shipping.SESTO, shipping.Load)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-published:
+		if !reflect.DeepEqual(got, e) {
+			t.Errorf("published = %v; want = %v", got, e)
+		}
+	default:
+		t.Fatal("no event was published")
+	}
+}
+
+func TestRegisterHandlingEvent_PublishFailure(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		events    = inmem.NewHandlingEventRepository()
+		logger    = &recordingLogger{}
+		completed = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		id        = shipping.TrackingID("ABC123")
+	)
+
+	p := publisherFunc(func(context.Context, shipping.HandlingEvent) error {
+		return errors.New("broker unavailable")
+	})
+
+	eh := &stubEventHandler{events: make([]interface{}, 0)}
+
+	s := NewService(events, newTestFactory(), eh, WithEventPublisher(p, logger))
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, id, "V100", shipping.SESTO, shipping.Load); err != nil {
+		t.Fatalf("err = %v; want = %v", err, nil)
+	}
+
+	if n := len(events.QueryHandlingHistory(ctx, id).HandlingEvents); n != 1 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", n, 1)
+	}
+	if len(eh.events) != 1 {
+		t.Errorf("len(eh.events) = %d; want = %d", len(eh.events), 1)
+	}
+	if len(logger.logged) != 1 {
+		t.Errorf("len(logger.logged) = %d; want = %d", len(logger.logged), 1)
+	}
+}
+
+func TestChannelPublisher_ContextDone(t *testing.T) {
+	ctx := context.Background()
+
+	p := NewChannelPublisher(make(chan shipping.HandlingEvent))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := p.Publish(ctx, shipping.HandlingEvent{}); err != context.Canceled {
+		t.Errorf("err = %v; want = %v", err, context.Canceled)
+	}
+}
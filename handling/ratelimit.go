@@ -0,0 +1,147 @@
+package handling
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// ErrRateLimited is returned when a registration is rejected because too
+// many registrations have been made recently.
+var ErrRateLimited = shipping.NewError(shipping.CodeResourceExhausted, "rate limit exceeded")
+
+type rateLimitingService struct {
+	mtx     sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+	clock   shipping.Clock
+
+	// key selects the bucket that a registration for a cargo draws from.
+	key func(id shipping.TrackingID) string
+
+	next Service
+}
+
+// RateLimitMiddleware returns a Service that limits registrations to rps per
+// second on average, allowing bursts of up to burst registrations. Every
+// handling event in a batch counts as a registration of its own. Rejected
+// registrations fail with ErrRateLimited. The limit applies to all
+// registrations made by the process.
+func RateLimitMiddleware(next Service, rps, burst int) Service {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitingService{
+		rate:    float64(rps),
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		clock:   shipping.SystemClock,
+		key:     func(shipping.TrackingID) string { return "" },
+		next:    next,
+	}
+}
+
+func (s *rateLimitingService) RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+	loc shipping.UNLocode, eventType shipping.HandlingEventType, opts ...RegistrationOption) (shipping.HandlingEvent, error) {
+
+	if !s.allow(id) {
+		return shipping.HandlingEvent{}, ErrRateLimited
+	}
+	return s.next.RegisterHandlingEvent(ctx, completed, id, voyageNumber, loc, eventType, opts...)
+}
+
+func (s *rateLimitingService) AmendHandlingEvent(ctx context.Context, original shipping.HandlingEvent, corrections ...Correction) error {
+	if !s.allow(original.TrackingID) {
+		return ErrRateLimited
+	}
+	return s.next.AmendHandlingEvent(ctx, original, corrections...)
+}
+
+func (s *rateLimitingService) ConfirmPlannedEvent(ctx context.Context, planned shipping.HandlingEvent) error {
+	if !s.allow(planned.TrackingID) {
+		return ErrRateLimited
+	}
+	return s.next.ConfirmPlannedEvent(ctx, planned)
+}
+
+// SearchHandlingEvents is not rate limited, as it registers nothing.
+func (s *rateLimitingService) SearchHandlingEvents(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	return s.next.SearchHandlingEvents(ctx, criteria)
+}
+
+func (s *rateLimitingService) RegisterHandlingEvents(ctx context.Context, events []HandlingEventRegistration) ([]error, error) {
+	if len(events) == 0 {
+		return s.next.RegisterHandlingEvents(ctx, events)
+	}
+
+	var (
+		errs    = make([]error, len(events))
+		allowed []HandlingEventRegistration
+		index   []int
+	)
+	for i, e := range events {
+		if !s.allow(e.TrackingID) {
+			errs[i] = ErrRateLimited
+			continue
+		}
+		allowed = append(allowed, e)
+		index = append(index, i)
+	}
+
+	if len(allowed) == 0 {
+		return errs, nil
+	}
+
+	res, err := s.next.RegisterHandlingEvents(ctx, allowed)
+	if err != nil {
+		return nil, err
+	}
+	for i, err := range res {
+		errs[index[i]] = err
+	}
+
+	return errs, nil
+}
+
+func (s *rateLimitingService) allow(id shipping.TrackingID) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	k := s.key(id)
+
+	b, ok := s.buckets[k]
+	if !ok {
+		b = &tokenBucket{tokens: s.burst, last: s.clock.Now()}
+		s.buckets[k] = b
+	}
+
+	return b.take(s.clock.Now(), s.rate, s.burst)
+}
+
+// tokenBucket holds the tokens available for registrations. Tokens are added
+// at a constant rate, up to the size of the bucket, and every registration
+// takes one.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(now time.Time, rate, size float64) bool {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * rate
+		if b.tokens > size {
+			b.tokens = size
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
@@ -0,0 +1,63 @@
+package handling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2009, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	s := RateLimitMiddleware(stubService{}, 10, 5).(*rateLimitingService)
+	s.clock = shipping.ClockFunc(func() time.Time { return now })
+
+	register := func() error {
+		_, err := s.RegisterHandlingEvent(ctx, now, "ABC123", "", shipping.SESTO, shipping.Receive)
+		return err
+	}
+
+	var rejected int
+	for i := 0; i < 8; i++ {
+		if err := register(); err == ErrRateLimited {
+			rejected++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rejected != 3 {
+		t.Errorf("rejected = %d; want = %d", rejected, 3)
+	}
+
+	// At 10 registrations per second, a token is added every 100ms.
+	now = now.Add(250 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if err := register(); err != nil {
+			t.Errorf("err = %v; want = %v", err, nil)
+		}
+	}
+	if err := register(); err != ErrRateLimited {
+		t.Errorf("err = %v; want = %v", err, ErrRateLimited)
+	}
+
+	// The bucket never holds more than the burst.
+	now = now.Add(time.Hour)
+
+	errs, err := s.RegisterHandlingEvents(ctx, make([]HandlingEventRegistration, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, err := range errs {
+		if i < 5 && err != nil {
+			t.Errorf("errs[%d] = %v; want = %v", i, err, nil)
+		}
+		if i >= 5 && err != ErrRateLimited {
+			t.Errorf("errs[%d] = %v; want = %v", i, err, ErrRateLimited)
+		}
+	}
+}
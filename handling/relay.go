@@ -0,0 +1,73 @@
+package handling
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// Relay notifies the event handler of the handling events in an outbox, and
+// marks them done. Together with WithOutbox, it makes sure that every stored
+// event is eventually notified at least once.
+type Relay interface {
+	// Run relays the pending outbox entries on every interval, until the
+	// context is done.
+	Run(ctx context.Context) error
+
+	// Relay relays the pending outbox entries once.
+	Relay(ctx context.Context)
+}
+
+type relay struct {
+	outbox   shipping.HandlingEventOutbox
+	handler  EventHandler
+	interval time.Duration
+	logger   log.Logger
+}
+
+// NewRelay returns a relay that notifies handler of the pending entries of the
+// outbox on every interval. Failures to read or update the outbox are logged
+// to logger, and retried on the next interval.
+func NewRelay(outbox shipping.HandlingEventOutbox, handler EventHandler, interval time.Duration, logger log.Logger) Relay {
+	return &relay{
+		outbox:   outbox,
+		handler:  handler,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+func (r *relay) Run(ctx context.Context) error {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			r.Relay(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *relay) Relay(ctx context.Context) {
+	entries, err := r.outbox.PendingOutbox(ctx)
+	if err != nil {
+		r.logger.Log("method", "relay", "err", err)
+		return
+	}
+
+	for _, entry := range entries {
+		r.handler.CargoWasHandled(shipping.NewCorrelationContext(ctx, entry.Correlation), entry.Event)
+
+		// An entry that fails to be marked done is notified again on the
+		// next interval, which the handlers must tolerate anyway.
+		if err := r.outbox.MarkOutboxDone(ctx, entry.ID); err != nil {
+			r.logger.Log("method", "relay", "tracking_id", entry.Event.TrackingID, "err", err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package handling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
+)
+
+func TestRelay_SimulatedCrash(t *testing.T) {
+	ctx := shipping.NewCorrelationContext(context.Background(), "abc")
+
+	events := inmem.NewHandlingEventRepository()
+	outbox := events.(shipping.HandlingEventOutbox)
+
+	var handled []shipping.HandlingEvent
+	var correlations []shipping.CorrelationID
+	h := eventHandlerFunc(func(ctx context.Context, e shipping.HandlingEvent) {
+		handled = append(handled, e)
+		correlations = append(correlations, shipping.CorrelationFromContext(ctx))
+	})
+
+	s := NewService(events, newTestFactory(), h, WithOutbox(outbox))
+
+	completed := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	// The process stops after the event is stored, before any relay has run.
+	if _, err := s.RegisterHandlingEvent(ctx, completed, "ABC123", "V100", shipping.SESTO, shipping.Load); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(handled) != 0 {
+		t.Fatalf("len(handled) = %d; want = %d", len(handled), 0)
+	}
+
+	// A relay started after the restart delivers the notification.
+	r := NewRelay(outbox, h, time.Minute, log.NewNopLogger())
+	r.Relay(context.Background())
+
+	if len(handled) != 1 {
+		t.Fatalf("len(handled) = %d; want = %d", len(handled), 1)
+	}
+	if handled[0].TrackingID != "ABC123" {
+		t.Errorf("TrackingID = %v; want = %v", handled[0].TrackingID, "ABC123")
+	}
+	if correlations[0] != "abc" {
+		t.Errorf("correlation = %v; want = %v", correlations[0], "abc")
+	}
+
+	r.Relay(context.Background())
+
+	if len(handled) != 1 {
+		t.Errorf("len(handled) = %d; want = %d", len(handled), 1)
+	}
+
+	pending, err := outbox.PendingOutbox(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("len(pending) = %d; want = %d", len(pending), 0)
+	}
+}
+
+func TestRelay_Amend(t *testing.T) {
+	ctx := context.Background()
+
+	events := inmem.NewHandlingEventRepository()
+	outbox := events.(shipping.HandlingEventOutbox)
+
+	var handled []shipping.HandlingEvent
+	h := eventHandlerFunc(func(ctx context.Context, e shipping.HandlingEvent) {
+		handled = append(handled, e)
+	})
+
+	s := NewService(events, newTestFactory(), h, WithOutbox(outbox))
+
+	completed := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	original, err := s.RegisterHandlingEvent(ctx, completed, "ABC123", "V100", shipping.CNHKG, shipping.Unload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AmendHandlingEvent(ctx, original, CorrectLocation(shipping.JNTKO)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The amendment is only notified through the outbox.
+	if len(handled) != 0 {
+		t.Fatalf("len(handled) = %d; want = %d", len(handled), 0)
+	}
+
+	NewRelay(outbox, h, time.Minute, log.NewNopLogger()).Relay(ctx)
+
+	if len(handled) != 2 {
+		t.Fatalf("len(handled) = %d; want = %d", len(handled), 2)
+	}
+	if got := handled[1].Activity.Location; got != shipping.JNTKO {
+		t.Errorf("Location = %v; want = %v", got, shipping.JNTKO)
+	}
+}
+
+func TestRelay_ConfirmPlannedEvent(t *testing.T) {
+	ctx := shipping.NewCorrelationContext(context.Background(), "abc")
+
+	now := time.Date(2015, time.November, 10, 8, 0, 0, 0, time.UTC)
+
+	events := inmem.NewHandlingEventRepository()
+	outbox := events.(shipping.HandlingEventOutbox)
+
+	var handled []shipping.HandlingEvent
+	var correlations []shipping.CorrelationID
+	h := eventHandlerFunc(func(ctx context.Context, e shipping.HandlingEvent) {
+		handled = append(handled, e)
+		correlations = append(correlations, shipping.CorrelationFromContext(ctx))
+	})
+
+	s := NewService(events, newTestFactory(), h, WithOutbox(outbox), WithClock(shipping.ClockFunc(func() time.Time { return now })))
+
+	planned, err := s.RegisterHandlingEvent(ctx, now, "ABC123", "V100", shipping.SESTO, shipping.Load, AsPlanned())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRelay(outbox, h, time.Minute, log.NewNopLogger())
+	r.Relay(ctx)
+
+	if len(handled) != 0 {
+		t.Fatalf("len(handled) = %d; want = %d", len(handled), 0)
+	}
+
+	if err := s.ConfirmPlannedEvent(ctx, planned); err != nil {
+		t.Fatal(err)
+	}
+
+	// The confirmation is only notified through the outbox.
+	if len(handled) != 0 {
+		t.Fatalf("len(handled) = %d; want = %d", len(handled), 0)
+	}
+
+	r.Relay(context.Background())
+
+	if len(handled) != 1 {
+		t.Fatalf("len(handled) = %d; want = %d", len(handled), 1)
+	}
+	if handled[0].Planned {
+		t.Errorf("Planned = %v; want = %v", handled[0].Planned, false)
+	}
+	if correlations[0] != "abc" {
+		t.Errorf("correlation = %v; want = %v", correlations[0], "abc")
+	}
+}
@@ -3,67 +3,614 @@
 package handling
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
 	"time"
 
+	"github.com/go-kit/kit/log"
+
 	shipping "github.com/marcusolsson/goddd"
 	"github.com/marcusolsson/goddd/inspection"
 )
 
 // ErrInvalidArgument is returned when one or more arguments are invalid.
-var ErrInvalidArgument = errors.New("invalid argument")
+var ErrInvalidArgument = shipping.ErrInvalidArgument
+
+// ErrFutureCompletionTime is returned when an event is registered as
+// completed further ahead of the service clock than the allowed clock skew.
+var ErrFutureCompletionTime = shipping.NewError(shipping.CodeInvalidArgument, "completion time is in the future")
+
+// ErrUnexpectedClearance is returned when a customs outcome is given for an
+// event other than a customs event.
+var ErrUnexpectedClearance = shipping.NewError(shipping.CodeInvalidArgument, "clearance is only recorded for customs events")
+
+// ErrUnexpectedRecipient is returned when a recipient is given for an event
+// other than a claim.
+var ErrUnexpectedRecipient = shipping.NewError(shipping.CodeInvalidArgument, "recipient is only recorded for claims")
+
+// ErrVoyageAmbiguous is returned when a load is registered without a voyage,
+// and the itinerary of the cargo does not load it onto exactly one voyage at
+// the location.
+var ErrVoyageAmbiguous = shipping.NewError(shipping.CodeInvalidArgument, "voyage can not be inferred from the itinerary")
+
+// DefaultClockSkew is how far ahead of the service clock the completion time
+// of an event may be, unless configured otherwise.
+const DefaultClockSkew = 5 * time.Minute
 
 // EventHandler provides a means of subscribing to registered handling events.
+// The context of a notification carries the correlation ID of the
+// registration, as given by shipping.CorrelationFromContext.
 type EventHandler interface {
-	CargoWasHandled(shipping.HandlingEvent)
+	CargoWasHandled(ctx context.Context, e shipping.HandlingEvent)
 }
 
 // Service provides handling operations.
 type Service interface {
 	// RegisterHandlingEvent registers a handling event in the system, and
-	// notifies interested parties that a cargo has been handled.
-	RegisterHandlingEvent(completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
-		unLocode shipping.UNLocode, eventType shipping.HandlingEventType) error
+	// notifies interested parties that a cargo has been handled. The stored
+	// event is returned. The voyage of a load may be left empty, if the
+	// itinerary of the cargo loads it onto a single voyage at the location;
+	// otherwise ErrVoyageAmbiguous is returned.
+	RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+		unLocode shipping.UNLocode, eventType shipping.HandlingEventType, opts ...RegistrationOption) (shipping.HandlingEvent, error)
+
+	// RegisterHandlingEvents registers a batch of handling events. Every
+	// registration is attempted, and the returned errors are aligned by index
+	// with the given registrations. Interested parties are notified at most
+	// once per cargo.
+	RegisterHandlingEvents(ctx context.Context, events []HandlingEventRegistration) ([]error, error)
+
+	// AmendHandlingEvent corrects a stored handling event, such as one
+	// registered at the wrong location. The corrected event is stored as an
+	// amendment of the original, the original is marked as superseded, and
+	// interested parties are notified. Both events remain in the handling
+	// history, but only the corrected one counts towards the delivery.
+	AmendHandlingEvent(ctx context.Context, original shipping.HandlingEvent, corrections ...Correction) error
+
+	// SearchHandlingEvents returns a page of the events of all cargos that
+	// match the criteria, latest completed first, together with the total
+	// number of matching events.
+	SearchHandlingEvents(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error)
+
+	// ConfirmPlannedEvent confirms that a planned handling event has
+	// happened, so that it counts towards the delivery, and notifies
+	// interested parties.
+	ConfirmPlannedEvent(ctx context.Context, planned shipping.HandlingEvent) error
+}
+
+// HandlingEventRegistration holds the arguments for registering a single
+// handling event.
+type HandlingEventRegistration struct {
+	Completed    time.Time
+	TrackingID   shipping.TrackingID
+	VoyageNumber shipping.VoyageNumber
+	Location     shipping.UNLocode
+	EventType    shipping.HandlingEventType
+
+	// IdempotencyKey optionally identifies the registration. Registering an
+	// event with a key that has already been used returns the previously
+	// stored event, without storing or notifying again.
+	IdempotencyKey string
+
+	// EquipmentID optionally identifies the equipment that handled the
+	// cargo.
+	EquipmentID shipping.EquipmentID
+
+	// RecipientName and RecipientSignature optionally record who received a
+	// claimed cargo. They are rejected for other events.
+	RecipientName      string
+	RecipientSignature string
+
+	// CustomsCleared records that a customs event cleared the cargo. It is
+	// rejected for other events.
+	CustomsCleared bool
+
+	// DryRun makes the registration be validated, including the existence
+	// of the cargo, voyage and location, but neither stored nor notified.
+	DryRun bool
+
+	// Planned registers the event as announced rather than having happened.
+	// Its completion time may be any time ahead of the service clock, and
+	// interested parties are not notified until it has been confirmed.
+	Planned bool
+
+	// amends is the key of the event that the registration corrects, if any.
+	amends *shipping.HandlingEventKey
+}
+
+// RegistrationOption sets optional arguments of a registration.
+type RegistrationOption func(*HandlingEventRegistration)
+
+// WithIdempotencyKey sets the idempotency key of a registration.
+func WithIdempotencyKey(key string) RegistrationOption {
+	return func(r *HandlingEventRegistration) {
+		r.IdempotencyKey = key
+	}
+}
+
+// WithEquipmentID sets the equipment that handled the cargo.
+func WithEquipmentID(id shipping.EquipmentID) RegistrationOption {
+	return func(r *HandlingEventRegistration) {
+		r.EquipmentID = id
+	}
+}
+
+// WithRecipient records who received a claimed cargo, as proof of delivery.
+func WithRecipient(name, signature string) RegistrationOption {
+	return func(r *HandlingEventRegistration) {
+		r.RecipientName = name
+		r.RecipientSignature = signature
+	}
+}
+
+// WithCustomsCleared records whether a customs event cleared the cargo.
+func WithCustomsCleared(cleared bool) RegistrationOption {
+	return func(r *HandlingEventRegistration) {
+		r.CustomsCleared = cleared
+	}
+}
+
+// WithDryRun makes the registration only be validated. The event that would
+// have been stored is returned, and a successful dry run means that the
+// registration would succeed, barring concurrent changes.
+func WithDryRun() RegistrationOption {
+	return func(r *HandlingEventRegistration) {
+		r.DryRun = true
+	}
+}
+
+// AsPlanned registers the event as planned, to be confirmed once the cargo
+// has been handled.
+func AsPlanned() RegistrationOption {
+	return func(r *HandlingEventRegistration) {
+		r.Planned = true
+	}
+}
+
+// Correction changes a field of an amended handling event.
+type Correction func(*HandlingEventRegistration)
+
+// CorrectCompletionTime corrects the time the handling was completed.
+func CorrectCompletionTime(t time.Time) Correction {
+	return func(r *HandlingEventRegistration) {
+		r.Completed = t
+	}
+}
+
+// CorrectLocation corrects the location of the handling.
+func CorrectLocation(loc shipping.UNLocode) Correction {
+	return func(r *HandlingEventRegistration) {
+		r.Location = loc
+	}
+}
+
+// CorrectVoyageNumber corrects the voyage of a load or unload.
+func CorrectVoyageNumber(voyageNumber shipping.VoyageNumber) Correction {
+	return func(r *HandlingEventRegistration) {
+		r.VoyageNumber = voyageNumber
+	}
+}
+
+// CorrectEventType corrects the type of the handling.
+func CorrectEventType(eventType shipping.HandlingEventType) Correction {
+	return func(r *HandlingEventRegistration) {
+		r.EventType = eventType
+	}
+}
+
+// Option configures optional dependencies of the service.
+type Option func(*service)
+
+// WithEventPublisher makes the service publish every stored handling event.
+//
+// Events are published after they have been stored. A failure to publish is
+// logged, but the event remains stored and the registration succeeds;
+// subscribers may therefore miss events, and should be able to recover by
+// querying the handling history.
+func WithEventPublisher(p EventPublisher, logger log.Logger) Option {
+	return func(s *service) {
+		s.publisher = p
+		s.logger = logger
+	}
+}
+
+// WithClock makes the service read the registration time of events from c,
+// instead of shipping.SystemClock.
+func WithClock(c shipping.Clock) Option {
+	return func(s *service) {
+		s.clock = c
+	}
+}
+
+// WithClockSkew sets how far ahead of the service clock the completion time
+// of an event may be, to tolerate devices with slightly wrong clocks. It
+// defaults to DefaultClockSkew.
+func WithClockSkew(d time.Duration) Option {
+	return func(s *service) {
+		s.skew = d
+	}
+}
+
+// WithOutbox makes the service store every registered, amended or confirmed
+// event together with an outbox entry for it, in one unit, and leave
+// notifying the event handler to a Relay. Unlike a direct notification, the notification survives the
+// process stopping between storing the event and notifying the handler.
+func WithOutbox(o shipping.HandlingEventOutbox) Option {
+	return func(s *service) {
+		s.outbox = o
+	}
 }
 
 type service struct {
 	handlingEventRepository shipping.HandlingEventRepository
+	outbox                  shipping.HandlingEventOutbox
 	handlingEventFactory    shipping.HandlingEventFactory
 	handlingEventHandler    EventHandler
+	publisher               EventPublisher
+	logger                  log.Logger
+	clock                   shipping.Clock
+	skew                    time.Duration
 }
 
-func (s *service) RegisterHandlingEvent(completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
-	loc shipping.UNLocode, eventType shipping.HandlingEventType) error {
-	if completed.IsZero() || id == "" || loc == "" || eventType == shipping.NotHandled {
+func (s *service) RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+	loc shipping.UNLocode, eventType shipping.HandlingEventType, opts ...RegistrationOption) (shipping.HandlingEvent, error) {
+	r := HandlingEventRegistration{
+		Completed:    completed,
+		TrackingID:   id,
+		VoyageNumber: voyageNumber,
+		Location:     loc,
+		EventType:    eventType,
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	e, registered, err := s.register(ctx, r)
+	if err != nil {
+		return shipping.HandlingEvent{}, err
+	}
+
+	if registered && !e.Planned && s.outbox == nil {
+		s.notify(ctx, e)
+	}
+
+	return e, nil
+}
+
+func (s *service) RegisterHandlingEvents(ctx context.Context, events []HandlingEventRegistration) ([]error, error) {
+	if len(events) == 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	var (
+		errs    = make([]error, len(events))
+		handled = make(map[shipping.TrackingID]shipping.HandlingEvent)
+		order   []shipping.TrackingID
+	)
+
+	for i, r := range events {
+		e, registered, err := s.register(ctx, r)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		if !registered || e.Planned || s.outbox != nil {
+			continue
+		}
+
+		if _, ok := handled[e.TrackingID]; !ok {
+			order = append(order, e.TrackingID)
+		}
+		handled[e.TrackingID] = e
+	}
+
+	// Inspection derives the delivery from the complete handling history, so
+	// notifying once per cargo with its latest event is sufficient.
+	for _, id := range order {
+		s.notify(ctx, handled[id])
+	}
+
+	return errs, nil
+}
+
+func (s *service) SearchHandlingEvents(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	if criteria.Offset < 0 || criteria.Limit < 0 {
+		return nil, 0, ErrInvalidArgument
+	}
+	if !criteria.From.IsZero() && !criteria.To.IsZero() && criteria.From.After(criteria.To) {
+		return nil, 0, ErrInvalidArgument
+	}
+	return s.handlingEventRepository.Search(ctx, criteria)
+}
+
+func (s *service) AmendHandlingEvent(ctx context.Context, original shipping.HandlingEvent, corrections ...Correction) error {
+	if original.TrackingID == "" || len(corrections) == 0 {
 		return ErrInvalidArgument
 	}
 
-	e, err := s.handlingEventFactory.CreateHandlingEvent(time.Now(), completed, id, voyageNumber, loc, eventType)
+	key := original.Key()
+
+	var (
+		stored shipping.HandlingEvent
+		found  bool
+	)
+	for _, e := range s.handlingEventRepository.QueryHandlingHistory(ctx, original.TrackingID).HandlingEvents {
+		if key.Matches(e) && !e.Superseded {
+			stored, found = e, true
+			break
+		}
+	}
+	if !found {
+		return shipping.ErrUnknownHandlingEvent
+	}
+
+	r := HandlingEventRegistration{
+		Completed:          stored.CompletionTime,
+		TrackingID:         stored.TrackingID,
+		VoyageNumber:       stored.Activity.VoyageNumber,
+		Location:           stored.Activity.Location,
+		EventType:          stored.Activity.Type,
+		EquipmentID:        stored.EquipmentID,
+		RecipientName:      stored.RecipientName,
+		RecipientSignature: stored.RecipientSignature,
+		CustomsCleared:     stored.CustomsCleared,
+		Planned:            stored.Planned,
+	}
+	for _, c := range corrections {
+		c(&r)
+	}
+	r.amends = &key
+
+	// The correction is stored before the original is superseded, so that a
+	// failure leaves the original in effect.
+	e, _, err := s.register(ctx, r)
 	if err != nil {
 		return err
 	}
 
-	s.handlingEventRepository.Store(e)
-	s.handlingEventHandler.CargoWasHandled(e)
+	if err := s.handlingEventRepository.Supersede(ctx, key); err != nil {
+		return err
+	}
+
+	// With an outbox, the correction was stored with its outbox entry.
+	if !e.Planned && s.outbox == nil {
+		s.notify(ctx, e)
+	}
 
 	return nil
 }
 
+func (s *service) ConfirmPlannedEvent(ctx context.Context, planned shipping.HandlingEvent) error {
+	if planned.TrackingID == "" {
+		return ErrInvalidArgument
+	}
+
+	key := planned.Key()
+
+	var (
+		stored shipping.HandlingEvent
+		found  bool
+	)
+	for _, e := range s.handlingEventRepository.QueryHandlingHistory(ctx, planned.TrackingID).HandlingEvents {
+		if key.Matches(e) && !e.Superseded {
+			stored, found = e, true
+			break
+		}
+	}
+	if !found {
+		return shipping.ErrUnknownHandlingEvent
+	}
+	if !stored.Planned {
+		return ErrInvalidArgument
+	}
+
+	// A planned event cannot be confirmed before it could have been
+	// registered as having happened.
+	if stored.CompletionTime.After(s.clock.Now().Add(s.skew)) {
+		return ErrFutureCompletionTime
+	}
+
+	if s.outbox != nil {
+		return s.outbox.ConfirmWithOutbox(ctx, key, outboxCorrelation(ctx))
+	}
+
+	if err := s.handlingEventRepository.Confirm(ctx, key); err != nil {
+		return err
+	}
+
+	stored.Planned = false
+	s.notify(ctx, stored)
+
+	return nil
+}
+
+// register validates and stores a handling event without notifying any
+// interested parties. If the registration has already been made, the
+// previously stored event is returned and registered is false.
+func (s *service) register(ctx context.Context, r HandlingEventRegistration) (e shipping.HandlingEvent, registered bool, err error) {
+	if r.Completed.IsZero() || r.TrackingID == "" || r.Location == "" || r.EventType == shipping.NotHandled {
+		return shipping.HandlingEvent{}, false, ErrInvalidArgument
+	}
+
+	// Only loads and unloads happen on a voyage. The voyage of a load may be
+	// left out, and is then inferred from the itinerary.
+	if r.EventType == shipping.Load && r.VoyageNumber == "" {
+		v, err := s.inferVoyage(ctx, r.TrackingID, r.Location)
+		if err != nil {
+			return shipping.HandlingEvent{}, false, err
+		}
+		r.VoyageNumber = v
+	}
+	if r.EventType == shipping.Unload && r.VoyageNumber == "" {
+		return shipping.HandlingEvent{}, false, ErrInvalidArgument
+	}
+
+	if (r.RecipientName != "" || r.RecipientSignature != "") && r.EventType != shipping.Claim {
+		return shipping.HandlingEvent{}, false, ErrUnexpectedRecipient
+	}
+	if r.CustomsCleared && r.EventType != shipping.Customs {
+		return shipping.HandlingEvent{}, false, ErrUnexpectedClearance
+	}
+
+	now := s.clock.Now()
+	if !r.Planned && r.Completed.After(now.Add(s.skew)) {
+		return shipping.HandlingEvent{}, false, ErrFutureCompletionTime
+	}
+
+	if r.IdempotencyKey != "" {
+		prev, err := s.handlingEventRepository.FindByIdempotencyKey(ctx, r.IdempotencyKey)
+		if err == nil {
+			return prev, false, nil
+		}
+		if err != shipping.ErrUnknownHandlingEvent {
+			return shipping.HandlingEvent{}, false, err
+		}
+	}
+
+	e, err = s.handlingEventFactory.CreateHandlingEvent(ctx, now, r.Completed, r.TrackingID, r.VoyageNumber, r.Location, r.EventType, r.EquipmentID)
+	if err != nil {
+		return shipping.HandlingEvent{}, false, err
+	}
+
+	e.IdempotencyKey = r.IdempotencyKey
+	e.RecipientName = r.RecipientName
+	e.RecipientSignature = r.RecipientSignature
+	e.CustomsCleared = r.CustomsCleared
+	e.Amends = r.amends
+	e.Planned = r.Planned
+
+	if r.DryRun {
+		if isDuplicate(e, s.handlingEventRepository.QueryHandlingHistory(ctx, e.TrackingID)) {
+			return shipping.HandlingEvent{}, false, shipping.ErrDuplicateEvent
+		}
+		return e, false, nil
+	}
+
+	// A retry made concurrently with the registration loses the race to
+	// store the event, and returns it like any other retry.
+	if err := s.store(ctx, e); err == shipping.ErrDuplicateIdempotencyKey {
+		prev, err := s.handlingEventRepository.FindByIdempotencyKey(ctx, r.IdempotencyKey)
+		if err != nil {
+			return shipping.HandlingEvent{}, false, err
+		}
+		return prev, false, nil
+	} else if err != nil {
+		return shipping.HandlingEvent{}, false, err
+	}
+
+	if err := s.publisher.Publish(ctx, e); err != nil {
+		s.logger.Log(
+			"method", "publish",
+			"tracking_id", e.TrackingID,
+			"type", e.Activity.Type,
+			"err", err,
+		)
+	}
+
+	return e, true, nil
+}
+
+// inferVoyage returns the voyage that the itinerary of the cargo loads it onto
+// at the location. It returns ErrVoyageAmbiguous unless there is exactly one.
+func (s *service) inferVoyage(ctx context.Context, id shipping.TrackingID, loc shipping.UNLocode) (shipping.VoyageNumber, error) {
+	loc, err := shipping.NewUNLocode(string(loc))
+	if err != nil {
+		return "", err
+	}
+
+	// Without the itinerary of the cargo, there is nothing to infer from.
+	if s.handlingEventFactory.CargoRepository == nil {
+		return "", ErrVoyageAmbiguous
+	}
+
+	c, err := s.handlingEventFactory.CargoRepository.Find(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	var voyage shipping.VoyageNumber
+	for _, l := range c.Itinerary.Legs {
+		if l.LoadLocation != loc || l.VoyageNumber == voyage {
+			continue
+		}
+		if voyage != "" {
+			return "", ErrVoyageAmbiguous
+		}
+		voyage = l.VoyageNumber
+	}
+	if voyage == "" {
+		return "", ErrVoyageAmbiguous
+	}
+	return voyage, nil
+}
+
+// isDuplicate returns whether the history holds an event with the same key as
+// e, as would make the repository reject it.
+func isDuplicate(e shipping.HandlingEvent, h shipping.HandlingHistory) bool {
+	key := e.Key()
+	for _, prev := range h.HandlingEvents {
+		if key.Matches(prev) {
+			return true
+		}
+	}
+	return false
+}
+
 // NewService creates a handling event service with necessary dependencies.
-func NewService(r shipping.HandlingEventRepository, f shipping.HandlingEventFactory, h EventHandler) Service {
-	return &service{
+func NewService(r shipping.HandlingEventRepository, f shipping.HandlingEventFactory, h EventHandler, opts ...Option) Service {
+	s := &service{
 		handlingEventRepository: r,
 		handlingEventFactory:    f,
 		handlingEventHandler:    h,
+		publisher:               NewNopPublisher(),
+		logger:                  log.NewNopLogger(),
+		clock:                   shipping.SystemClock,
+		skew:                    DefaultClockSkew,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// store stores the event, and, with an outbox, an outbox entry for it to be
+// notified by a relay. Planned events are not notified, and so never put in
+// the outbox.
+func (s *service) store(ctx context.Context, e shipping.HandlingEvent) error {
+	if s.outbox == nil || e.Planned {
+		return s.handlingEventRepository.Store(ctx, e)
+	}
+	return s.outbox.StoreWithOutbox(ctx, e, outboxCorrelation(ctx))
+}
+
+// outboxCorrelation returns the correlation ID of the context for an outbox
+// entry, or a new one if the context has none.
+func outboxCorrelation(ctx context.Context) shipping.CorrelationID {
+	if correlation := shipping.CorrelationFromContext(ctx); correlation != "" {
+		return correlation
+	}
+	return shipping.NewCorrelationID()
+}
+
+// notify notifies the event handler that the cargo of the event has been
+// handled. The context carries a correlation ID for the handlers to log,
+// which is generated unless the registration already has one.
+func (s *service) notify(ctx context.Context, e shipping.HandlingEvent) {
+	if shipping.CorrelationFromContext(ctx) == "" {
+		ctx = shipping.NewCorrelationContext(ctx, shipping.NewCorrelationID())
+	}
+	s.handlingEventHandler.CargoWasHandled(ctx, e)
 }
 
 type handlingEventHandler struct {
 	InspectionService inspection.Service
 }
 
-func (h *handlingEventHandler) CargoWasHandled(event shipping.HandlingEvent) {
-	h.InspectionService.InspectCargo(event.TrackingID)
+func (h *handlingEventHandler) CargoWasHandled(ctx context.Context, event shipping.HandlingEvent) {
+	h.InspectionService.InspectCargo(ctx, event.TrackingID)
 }
 
 // NewEventHandler returns a new instance of a EventHandler.
@@ -72,3 +619,47 @@ func NewEventHandler(s inspection.Service) EventHandler {
 		InspectionService: s,
 	}
 }
+
+type multiEventHandler struct {
+	handlers []EventHandler
+	logger   log.Logger
+}
+
+func (m *multiEventHandler) CargoWasHandled(ctx context.Context, event shipping.HandlingEvent) {
+	for _, h := range m.handlers {
+		m.cargoWasHandled(ctx, h, event)
+	}
+}
+
+// cargoWasHandled notifies h, recovering from any panic so that a faulty
+// handler cannot prevent the remaining handlers from being notified.
+func (m *multiEventHandler) cargoWasHandled(ctx context.Context, h EventHandler, event shipping.HandlingEvent) {
+	defer func() {
+		if v := recover(); v != nil {
+			m.logger.Log(
+				"method", "cargo_was_handled",
+				"handler", fmt.Sprintf("%T", h),
+				"tracking_id", event.TrackingID,
+				"panic", fmt.Sprint(v),
+				"stack", string(debug.Stack()),
+			)
+		}
+	}()
+	h.CargoWasHandled(ctx, event)
+}
+
+// MultiEventHandler returns an EventHandler that notifies each of the given
+// handlers in order. A handler that panics is skipped, and the remaining
+// handlers are still notified. Panics are logged to standard error.
+func MultiEventHandler(handlers ...EventHandler) EventHandler {
+	return NewMultiEventHandler(log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr)), handlers...)
+}
+
+// NewMultiEventHandler returns an EventHandler like MultiEventHandler that
+// logs the value and stack of any panic to the given logger.
+func NewMultiEventHandler(logger log.Logger, handlers ...EventHandler) EventHandler {
+	return &multiEventHandler{
+		handlers: append([]EventHandler(nil), handlers...),
+		logger:   logger,
+	}
+}
@@ -1,10 +1,20 @@
 package handling
 
 import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-kit/kit/log"
+
 	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
+	"github.com/marcusolsson/goddd/inspection"
 	"github.com/marcusolsson/goddd/mock"
 )
 
@@ -12,11 +22,13 @@ type stubEventHandler struct {
 	events []interface{}
 }
 
-func (h *stubEventHandler) CargoWasHandled(e shipping.HandlingEvent) {
+func (h *stubEventHandler) CargoWasHandled(ctx context.Context, e shipping.HandlingEvent) {
 	h.events = append(h.events, e)
 }
 
 func TestRegisterHandlingEvent(t *testing.T) {
+	ctx := context.Background()
+
 	var cargos mock.CargoRepository
 	cargos.StoreFn = func(c *shipping.Cargo) error {
 		return nil
@@ -39,7 +51,7 @@ func TestRegisterHandlingEvent(t *testing.T) {
 	}
 
 	var events mock.HandlingEventRepository
-	events.StoreFn = func(e shipping.HandlingEvent) {}
+	events.StoreFn = func(e shipping.HandlingEvent) error { return nil }
 
 	eh := &stubEventHandler{events: make([]interface{}, 0)}
 	ef := shipping.HandlingEventFactory{
@@ -48,7 +60,11 @@ func TestRegisterHandlingEvent(t *testing.T) {
 		LocationRepository: &locations,
 	}
 
-	s := NewService(&events, ef, eh)
+	registered := time.Date(2015, time.November, 11, 8, 0, 0, 0, time.UTC)
+
+	s := NewService(&events, ef, eh, WithClock(shipping.ClockFunc(func() time.Time {
+		return registered
+	})))
 
 	var (
 		completed = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
@@ -58,22 +74,941 @@ func TestRegisterHandlingEvent(t *testing.T) {
 
 	var err error
 
-	err = cargos.Store(shipping.NewCargo(id, shipping.RouteSpecification{}))
+	err = cargos.Store(ctx, shipping.NewCargo(id, shipping.RouteSpecification{}))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = s.RegisterHandlingEvent(completed, id, voyage, shipping.SESTO, shipping.Load)
+	e, err := s.RegisterHandlingEvent(ctx, completed, id, voyage, shipping.SESTO, shipping.Load, WithEquipmentID("MSCU1234565"))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = s.RegisterHandlingEvent(completed, "no_such_id", voyage, shipping.SESTO, shipping.Load)
+	if e.TrackingID != id {
+		t.Errorf("e.TrackingID = %s; want = %s", e.TrackingID, id)
+	}
+	if e.CompletionTime != completed {
+		t.Errorf("e.CompletionTime = %s; want = %s", e.CompletionTime, completed)
+	}
+	if e.EquipmentID != "MSCU1234565" {
+		t.Errorf("e.EquipmentID = %s; want = %s", e.EquipmentID, "MSCU1234565")
+	}
+	if e.RegistrationTime != registered {
+		t.Errorf("e.RegistrationTime = %s; want = %s", e.RegistrationTime, registered)
+	}
+
+	_, err = s.RegisterHandlingEvent(ctx, completed, "no_such_id", voyage, shipping.SESTO, shipping.Load)
 	if err != shipping.ErrUnknownCargo {
 		t.Errorf("err = %s; want = %s", err, shipping.ErrUnknownCargo)
 	}
 
+	if len(eh.events) != 1 {
+		t.Fatalf("len(eh.events) = %d; want = %d", len(eh.events), 1)
+	}
+
+	if !reflect.DeepEqual(eh.events[0], e) {
+		t.Errorf("handled event = %v; want = %v", eh.events[0], e)
+	}
+}
+
+func TestRegisterHandlingEvent_OnHold(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos         = inmem.NewCargoRepository()
+		voyages        = inmem.NewVoyageRepository()
+		locations      = inmem.NewLocationRepository()
+		handlingEvents = inmem.NewHandlingEventRepository()
+	)
+
+	ef := shipping.HandlingEventFactory{
+		CargoRepository:    cargos,
+		VoyageRepository:   voyages,
+		LocationRepository: locations,
+	}
+
+	s := NewService(handlingEvents, ef, &stubEventHandler{})
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.CNHKG,
+	})
+	if err := c.Hold("inspection"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		completed = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		voyage    = shipping.V100.VoyageNumber
+	)
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, c.TrackingID, voyage, shipping.SESTO, shipping.Load); err != shipping.ErrCargoOnHold {
+		t.Errorf("Load: err = %v; want = %v", err, shipping.ErrCargoOnHold)
+	}
+	for _, typ := range []shipping.HandlingEventType{shipping.Unload, shipping.Claim} {
+		if _, err := s.RegisterHandlingEvent(ctx, completed, c.TrackingID, voyage, shipping.CNHKG, typ); err != nil {
+			t.Errorf("%v: err = %v; want = %v", typ, err, nil)
+		}
+	}
+
+	c, err := cargos.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Release()
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed.Add(time.Hour), c.TrackingID, voyage, shipping.SESTO, shipping.Load); err != nil {
+		t.Errorf("Load after release: err = %v; want = %v", err, nil)
+	}
+}
+
+func TestRegisterHandlingEvent_InferredVoyage(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos         = inmem.NewCargoRepository()
+		voyages        = inmem.NewVoyageRepository()
+		locations      = inmem.NewLocationRepository()
+		handlingEvents = inmem.NewHandlingEventRepository()
+	)
+
+	ef := shipping.HandlingEventFactory{
+		CargoRepository:    cargos,
+		VoyageRepository:   voyages,
+		LocationRepository: locations,
+	}
+
+	s := NewService(handlingEvents, ef, &stubEventHandler{})
+
+	routed := shipping.NewCargo("ABC123", shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG})
+	routed.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.DEHAM},
+		{VoyageNumber: "V300", LoadLocation: shipping.DEHAM, UnloadLocation: shipping.CNHKG},
+	}})
+
+	// The itinerary of the roundtrip loads the cargo twice in Stockholm, on
+	// different voyages.
+	roundtrip := shipping.NewCargo("DEF456", shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG})
+	roundtrip.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.DEHAM},
+		{VoyageNumber: "V300", LoadLocation: shipping.DEHAM, UnloadLocation: shipping.SESTO},
+		{VoyageNumber: "V400", LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+	}})
+
+	for _, c := range []*shipping.Cargo{routed, roundtrip} {
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	completed := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	e, err := s.RegisterHandlingEvent(ctx, completed, "ABC123", "", "deham", shipping.Load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Activity.VoyageNumber != "V300" {
+		t.Errorf("e.Activity.VoyageNumber = %s; want = %s", e.Activity.VoyageNumber, "V300")
+	}
+	if h := handlingEvents.QueryHandlingHistory(ctx, "ABC123"); len(h.HandlingEvents) != 1 || h.HandlingEvents[0].Activity.VoyageNumber != "V300" {
+		t.Errorf("stored history = %v; want a load onto V300", h)
+	}
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, "ABC123", "", shipping.CNHKG, shipping.Load); err != ErrVoyageAmbiguous {
+		t.Errorf("no match: err = %v; want = %v", err, ErrVoyageAmbiguous)
+	}
+	if _, err := s.RegisterHandlingEvent(ctx, completed, "DEF456", "", shipping.SESTO, shipping.Load); err != ErrVoyageAmbiguous {
+		t.Errorf("ambiguous: err = %v; want = %v", err, ErrVoyageAmbiguous)
+	}
+	if _, err := s.RegisterHandlingEvent(ctx, completed, "DEF456", "", shipping.DEHAM, shipping.Load); err != nil {
+		t.Errorf("unambiguous leg of roundtrip: err = %v; want = %v", err, nil)
+	}
+}
+
+func TestRegisterHandlingEvent_UnknownEntities(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return new(shipping.Cargo), nil
+	}
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(n shipping.VoyageNumber) (*shipping.Voyage, error) {
+		if n != shipping.V100.VoyageNumber {
+			return nil, shipping.ErrUnknownVoyage
+		}
+		return shipping.V100, nil
+	}
+
+	var locations mock.LocationRepository
+	locations.FindFn = func(l shipping.UNLocode) (*shipping.Location, error) {
+		if l != shipping.SESTO {
+			return nil, shipping.ErrUnknownLocation
+		}
+		return shipping.Stockholm, nil
+	}
+
+	var events mock.HandlingEventRepository
+	events.StoreFn = func(e shipping.HandlingEvent) error { return nil }
+
+	ef := shipping.HandlingEventFactory{
+		CargoRepository:    &cargos,
+		VoyageRepository:   &voyages,
+		LocationRepository: &locations,
+	}
+
+	completed := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		eventType shipping.HandlingEventType
+		voyage    shipping.VoyageNumber
+		location  shipping.UNLocode
+		want      error
+	}{
+		{shipping.Load, shipping.V100.VoyageNumber, shipping.SESTO, nil},
+		{shipping.Load, "", shipping.SESTO, ErrVoyageAmbiguous},
+		{shipping.Load, "XX000", shipping.SESTO, shipping.ErrUnknownVoyage},
+		{shipping.Load, shipping.V100.VoyageNumber, "ZZZZZ", shipping.ErrUnknownLocation},
+		{shipping.Unload, shipping.V100.VoyageNumber, shipping.SESTO, nil},
+		{shipping.Unload, "", shipping.SESTO, ErrInvalidArgument},
+		{shipping.Unload, "XX000", shipping.SESTO, shipping.ErrUnknownVoyage},
+		{shipping.Unload, shipping.V100.VoyageNumber, "ZZZZZ", shipping.ErrUnknownLocation},
+		{shipping.Receive, "", shipping.SESTO, nil},
+		{shipping.Receive, "XX000", shipping.SESTO, nil},
+		{shipping.Receive, "", "ZZZZZ", shipping.ErrUnknownLocation},
+		{shipping.Claim, "", shipping.SESTO, nil},
+		{shipping.Claim, "XX000", shipping.SESTO, nil},
+		{shipping.Claim, "", "ZZZZZ", shipping.ErrUnknownLocation},
+		{shipping.Customs, "", shipping.SESTO, nil},
+		{shipping.Customs, "XX000", shipping.SESTO, nil},
+		{shipping.Customs, "", "ZZZZZ", shipping.ErrUnknownLocation},
+		{shipping.NotHandled, shipping.V100.VoyageNumber, shipping.SESTO, ErrInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		eh := &stubEventHandler{events: make([]interface{}, 0)}
+		s := NewService(&events, ef, eh)
+
+		_, err := s.RegisterHandlingEvent(ctx, completed, "ABC123", tt.voyage, tt.location, tt.eventType)
+		if err != tt.want {
+			t.Errorf("%s(%q, %q): err = %v; want = %v", tt.eventType, tt.voyage, tt.location, err, tt.want)
+		}
+
+		var wantEvents int
+		if tt.want == nil {
+			wantEvents = 1
+		}
+		if len(eh.events) != wantEvents {
+			t.Errorf("%s(%q, %q): len(eh.events) = %d; want = %d", tt.eventType, tt.voyage, tt.location, len(eh.events), wantEvents)
+		}
+	}
+}
+
+func TestRegisterHandlingEvent_CargoCheck(t *testing.T) {
+	ctx := context.Background()
+
+	completed := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	cargos := inmem.NewCargoRepository()
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	f := shipping.HandlingEventFactory{
+		CargoRepository:    cargos,
+		VoyageRepository:   inmem.NewVoyageRepository(),
+		LocationRepository: inmem.NewLocationRepository(),
+	}
+
+	events := inmem.NewHandlingEventRepository()
+	s := NewService(events, f, &stubEventHandler{})
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, "ABC123", "", shipping.SESTO, shipping.Receive); err != nil {
+		t.Errorf("known cargo: err = %v; want = %v", err, nil)
+	}
+	if _, err := s.RegisterHandlingEvent(ctx, completed, "NOTBOOKED", "", shipping.SESTO, shipping.Receive); err != shipping.ErrUnknownCargo {
+		t.Errorf("unknown cargo: err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+	if n := len(events.QueryHandlingHistory(ctx, "NOTBOOKED").HandlingEvents); n != 0 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", n, 0)
+	}
+
+	// Without a cargo repository, handling is registered for any cargo.
+	f.CargoRepository = nil
+	standalone := NewService(inmem.NewHandlingEventRepository(), f, &stubEventHandler{})
+
+	if _, err := standalone.RegisterHandlingEvent(ctx, completed, "NOTBOOKED", "", shipping.SESTO, shipping.Receive); err != nil {
+		t.Errorf("standalone: err = %v; want = %v", err, nil)
+	}
+}
+
+func TestRegisterHandlingEvents(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		if id == "no_such_id" {
+			return nil, shipping.ErrUnknownCargo
+		}
+		return new(shipping.Cargo), nil
+	}
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(n shipping.VoyageNumber) (*shipping.Voyage, error) {
+		return new(shipping.Voyage), nil
+	}
+
+	var locations mock.LocationRepository
+	locations.FindFn = func(l shipping.UNLocode) (*shipping.Location, error) {
+		return nil, nil
+	}
+
+	var stored []shipping.HandlingEvent
+
+	var events mock.HandlingEventRepository
+	events.StoreFn = func(e shipping.HandlingEvent) error {
+		stored = append(stored, e)
+		return nil
+	}
+
+	eh := &stubEventHandler{events: make([]interface{}, 0)}
+	ef := shipping.HandlingEventFactory{
+		CargoRepository:    &cargos,
+		VoyageRepository:   &voyages,
+		LocationRepository: &locations,
+	}
+
+	s := NewService(&events, ef, eh)
+
+	completed := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	errs, err := s.RegisterHandlingEvents(ctx, []HandlingEventRegistration{
+		{Completed: completed, TrackingID: "ABC123", Location: shipping.SESTO, EventType: shipping.Receive},
+		{Completed: completed, TrackingID: "no_such_id", Location: shipping.SESTO, EventType: shipping.Receive},
+		{Completed: completed, TrackingID: "ABC123", VoyageNumber: "V100", Location: shipping.SESTO, EventType: shipping.Load},
+		{Completed: completed, TrackingID: "DEF456", Location: shipping.SESTO, EventType: shipping.Receive},
+		{TrackingID: "DEF456", Location: shipping.SESTO, EventType: shipping.Receive},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []error{nil, shipping.ErrUnknownCargo, nil, nil, ErrInvalidArgument}
+
+	if len(errs) != len(want) {
+		t.Fatalf("len(errs) = %d; want = %d", len(errs), len(want))
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("errs[%d] = %v; want = %v", i, errs[i], want[i])
+		}
+	}
+
+	if len(stored) != 3 {
+		t.Errorf("len(stored) = %d; want = %d", len(stored), 3)
+	}
+
+	if len(eh.events) != 2 {
+		t.Fatalf("len(eh.events) = %d; want = %d", len(eh.events), 2)
+	}
+
+	first := eh.events[0].(shipping.HandlingEvent)
+	if first.TrackingID != "ABC123" || first.Activity.Type != shipping.Load {
+		t.Errorf("eh.events[0] = %v; want latest event for %s", first, "ABC123")
+	}
+
+	if _, err := s.RegisterHandlingEvents(ctx, nil); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+}
+
+// barrierRepository holds back the first n idempotency key lookups until all
+// of them have been made, so that concurrent registrations all miss.
+type barrierRepository struct {
+	shipping.HandlingEventRepository
+	barrier sync.WaitGroup
+	n       int32
+}
+
+func (r *barrierRepository) FindByIdempotencyKey(ctx context.Context, key string) (shipping.HandlingEvent, error) {
+	e, err := r.HandlingEventRepository.FindByIdempotencyKey(ctx, key)
+	if atomic.AddInt32(&r.n, -1) >= 0 {
+		r.barrier.Done()
+		r.barrier.Wait()
+	}
+	return e, err
+}
+
+type countingEventHandler struct {
+	n int32
+}
+
+func (h *countingEventHandler) CargoWasHandled(ctx context.Context, e shipping.HandlingEvent) {
+	atomic.AddInt32(&h.n, 1)
+}
+
+func TestRegisterHandlingEvent_ConcurrentRetries(t *testing.T) {
+	ctx := context.Background()
+
+	voyages := inmem.NewVoyageRepository()
+	locations := inmem.NewLocationRepository()
+
+	const retries = 2
+
+	events := &barrierRepository{HandlingEventRepository: inmem.NewHandlingEventRepository(), n: retries}
+	events.barrier.Add(retries)
+
+	eh := &countingEventHandler{}
+	ef := shipping.HandlingEventFactory{
+		VoyageRepository:   voyages,
+		LocationRepository: locations,
+	}
+
+	s := NewService(events, ef, eh)
+
+	completed := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var (
+		wg   sync.WaitGroup
+		got  [retries]shipping.HandlingEvent
+		errs [retries]error
+	)
+	for i := 0; i < retries; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i], errs[i] = s.RegisterHandlingEvent(ctx, completed, "ABC123", "V100", shipping.SESTO, shipping.Load, WithIdempotencyKey("scan-1"))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("errs[%d] = %v", i, err)
+		}
+	}
+
+	if h := events.QueryHandlingHistory(ctx, "ABC123"); len(h.HandlingEvents) != 1 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", len(h.HandlingEvents), 1)
+	}
+	if eh.n != 1 {
+		t.Errorf("notified = %d; want = %d", eh.n, 1)
+	}
+	if got[0].Key() != got[1].Key() {
+		t.Errorf("retries returned %v and %v; want the same event", got[0], got[1])
+	}
+}
+
+func TestRegisterHandlingEvent_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return new(shipping.Cargo), nil
+	}
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(n shipping.VoyageNumber) (*shipping.Voyage, error) {
+		return new(shipping.Voyage), nil
+	}
+
+	var locations mock.LocationRepository
+	locations.FindFn = func(l shipping.UNLocode) (*shipping.Location, error) {
+		return nil, nil
+	}
+
+	events := inmem.NewHandlingEventRepository()
+
+	eh := &stubEventHandler{events: make([]interface{}, 0)}
+	ef := shipping.HandlingEventFactory{
+		CargoRepository:    &cargos,
+		VoyageRepository:   &voyages,
+		LocationRepository: &locations,
+	}
+
+	s := NewService(events, ef, eh)
+
+	var (
+		completed = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		id        = shipping.TrackingID("ABC123")
+	)
+
+	first, err := s.RegisterHandlingEvent(ctx, completed, id, "V100", shipping.SESTO, shipping.Load, WithIdempotencyKey("scan-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retry, err := s.RegisterHandlingEvent(ctx, completed, id, "V100", shipping.SESTO, shipping.Load, WithIdempotencyKey("scan-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry returns the event as stored, numbered by the repository.
+	first.Sequence = 1
+	if !reflect.DeepEqual(retry, first) {
+		t.Errorf("retry = %v; want = %v", retry, first)
+	}
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, id, "V100", shipping.SESTO, shipping.Unload, WithIdempotencyKey("scan-2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(events.QueryHandlingHistory(ctx, id).HandlingEvents); n != 2 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", n, 2)
+	}
+	if len(eh.events) != 2 {
+		t.Errorf("len(eh.events) = %d; want = %d", len(eh.events), 2)
+	}
+}
+
+func TestRegisterHandlingEvent_Duplicate(t *testing.T) {
+	ctx := context.Background()
+
+	events := inmem.NewHandlingEventRepository()
+
+	eh := &stubEventHandler{events: make([]interface{}, 0)}
+
+	s := NewService(events, newTestFactory(), eh)
+
+	completed := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, "ABC123", "V100", shipping.SESTO, shipping.Load); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, "ABC123", "V100", shipping.SESTO, shipping.Load); err != shipping.ErrDuplicateEvent {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrDuplicateEvent)
+	}
+
+	if n := len(events.QueryHandlingHistory(ctx, "ABC123").HandlingEvents); n != 1 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", n, 1)
+	}
 	if len(eh.events) != 1 {
 		t.Errorf("len(eh.events) = %d; want = %d", len(eh.events), 1)
 	}
 }
+
+func TestRegisterHandlingEvent_FutureCompletionTime(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2015, time.November, 11, 8, 0, 0, 0, time.UTC)
+	clock := shipping.ClockFunc(func() time.Time { return now })
+
+	var tests = []struct {
+		name      string
+		skew      []Option
+		completed time.Time
+		want      error
+	}{
+		{"past", nil, now.AddDate(0, 0, -1), nil},
+		{"now", nil, now, nil},
+		{"at default skew", nil, now.Add(DefaultClockSkew), nil},
+		{"beyond default skew", nil, now.Add(DefaultClockSkew + time.Nanosecond), ErrFutureCompletionTime},
+		{"at skew", []Option{WithClockSkew(time.Hour)}, now.Add(time.Hour), nil},
+		{"beyond skew", []Option{WithClockSkew(time.Hour)}, now.Add(time.Hour + time.Nanosecond), ErrFutureCompletionTime},
+		{"no skew", []Option{WithClockSkew(0)}, now.Add(time.Nanosecond), ErrFutureCompletionTime},
+	}
+
+	for _, tt := range tests {
+		events := inmem.NewHandlingEventRepository()
+		eh := &stubEventHandler{events: make([]interface{}, 0)}
+
+		s := NewService(events, newTestFactory(), eh, append(tt.skew, WithClock(clock))...)
+
+		_, err := s.RegisterHandlingEvent(ctx, tt.completed, "ABC123", "V100", shipping.SESTO, shipping.Load)
+		if err != tt.want {
+			t.Errorf("%s: err = %v; want = %v", tt.name, err, tt.want)
+		}
+
+		if tt.want != nil && len(eh.events) != 0 {
+			t.Errorf("%s: len(eh.events) = %d; want = %d", tt.name, len(eh.events), 0)
+		}
+	}
+}
+
+type eventHandlerFunc func(context.Context, shipping.HandlingEvent)
+
+func (f eventHandlerFunc) CargoWasHandled(ctx context.Context, e shipping.HandlingEvent) {
+	f(ctx, e)
+}
+
+func TestMultiEventHandler(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		first  stubEventHandler
+		second stubEventHandler
+	)
+
+	panicking := eventHandlerFunc(func(context.Context, shipping.HandlingEvent) {
+		panic("handler failed")
+	})
+
+	var buf bytes.Buffer
+	h := NewMultiEventHandler(log.NewLogfmtLogger(&buf), &first, panicking, &second)
+
+	e := shipping.HandlingEvent{TrackingID: "ABC123"}
+
+	h.CargoWasHandled(ctx, e)
+	h.CargoWasHandled(ctx, e)
+
+	if got := strings.Count(buf.String(), `panic="handler failed"`); got != 2 {
+		t.Errorf("logged panics = %d; want = %d", got, 2)
+	}
+	if !strings.Contains(buf.String(), "stack=") {
+		t.Errorf("log = %q; want stack", buf.String())
+	}
+
+	if len(first.events) != 2 {
+		t.Errorf("len(first.events) = %d; want = %d", len(first.events), 2)
+	}
+	if len(second.events) != 2 {
+		t.Errorf("len(second.events) = %d; want = %d", len(second.events), 2)
+	}
+}
+
+// correlationRecorder is an inspection event handler that records the
+// correlation IDs of the misdirections it is notified of.
+type correlationRecorder struct {
+	ids []shipping.CorrelationID
+}
+
+func (h *correlationRecorder) CargoWasMisdirected(ctx context.Context, id shipping.TrackingID, lastKnownLocation shipping.UNLocode) {
+	h.ids = append(h.ids, shipping.CorrelationFromContext(ctx))
+}
+
+func (h *correlationRecorder) CargoHasArrived(ctx context.Context, id shipping.TrackingID) {}
+
+func TestRegisterHandlingEvent_CorrelationID(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos = inmem.NewCargoRepository()
+		events = inmem.NewHandlingEventRepository()
+	)
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{Origin: shipping.JNTKO, Destination: shipping.NLRTM})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V300", LoadLocation: shipping.JNTKO, UnloadLocation: shipping.NLRTM},
+	}})
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		inspected correlationRecorder
+		notified  []shipping.CorrelationID
+	)
+
+	eh := MultiEventHandler(
+		NewEventHandler(inspection.NewService(cargos, events, &inspected)),
+		eventHandlerFunc(func(ctx context.Context, e shipping.HandlingEvent) {
+			notified = append(notified, shipping.CorrelationFromContext(ctx))
+		}),
+	)
+
+	ef := shipping.HandlingEventFactory{
+		CargoRepository:    cargos,
+		VoyageRepository:   inmem.NewVoyageRepository(),
+		LocationRepository: inmem.NewLocationRepository(),
+	}
+
+	s := NewService(events, ef, eh)
+
+	completed := time.Now().Add(-time.Hour)
+
+	if _, err := s.RegisterHandlingEvent(shipping.NewCorrelationContext(ctx, "scan-1"), completed, c.TrackingID, "V300", shipping.DEHAM, shipping.Unload); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RegisterHandlingEvent(ctx, completed.Add(time.Minute), c.TrackingID, "V300", shipping.DEHAM, shipping.Unload); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notified) != 2 {
+		t.Fatalf("len(notified) = %d; want = %d", len(notified), 2)
+	}
+	if notified[0] != "scan-1" {
+		t.Errorf("notified[0] = %q; want = %q", notified[0], "scan-1")
+	}
+	if notified[1] == "" || notified[1] == notified[0] {
+		t.Errorf("notified[1] = %q; want a generated ID", notified[1])
+	}
+	if !reflect.DeepEqual(inspected.ids, notified) {
+		t.Errorf("inspected.ids = %v; want = %v", inspected.ids, notified)
+	}
+}
+
+func TestRegisterHandlingEvent_Recipient(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return new(shipping.Cargo), nil
+	}
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(n shipping.VoyageNumber) (*shipping.Voyage, error) {
+		return new(shipping.Voyage), nil
+	}
+
+	var locations mock.LocationRepository
+	locations.FindFn = func(l shipping.UNLocode) (*shipping.Location, error) {
+		return nil, nil
+	}
+
+	events := inmem.NewHandlingEventRepository()
+
+	ef := shipping.HandlingEventFactory{
+		CargoRepository:    &cargos,
+		VoyageRepository:   &voyages,
+		LocationRepository: &locations,
+	}
+
+	s := NewService(events, ef, &stubEventHandler{})
+
+	var (
+		completed = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		id        = shipping.TrackingID("ABC123")
+	)
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, id, "V100", shipping.SESTO, shipping.Load, WithRecipient("Jane Doe", "signature")); err != ErrUnexpectedRecipient {
+		t.Errorf("err = %v; want = %v", err, ErrUnexpectedRecipient)
+	}
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, id, "", shipping.AUMEL, shipping.Claim, WithRecipient("Jane Doe", "signature")); err != nil {
+		t.Fatal(err)
+	}
+
+	h := events.QueryHandlingHistory(ctx, id)
+	if len(h.HandlingEvents) != 1 {
+		t.Fatalf("len(HandlingEvents) = %d; want = %d", len(h.HandlingEvents), 1)
+	}
+
+	e := h.HandlingEvents[0]
+	if e.RecipientName != "Jane Doe" {
+		t.Errorf("RecipientName = %q; want = %q", e.RecipientName, "Jane Doe")
+	}
+	if e.RecipientSignature != "signature" {
+		t.Errorf("RecipientSignature = %q; want = %q", e.RecipientSignature, "signature")
+	}
+
+	if _, err := s.RegisterHandlingEvent(ctx, completed, id, "", shipping.AUMEL, shipping.Customs, WithCustomsCleared(true)); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := events.QueryHandlingHistory(ctx, id).MostRecentlyCompletedEvent(); e.Activity.Type != shipping.Customs || !e.CustomsCleared {
+		t.Errorf("MostRecentlyCompletedEvent() = %v; want cleared customs event", e)
+	}
+}
+
+func TestAmendHandlingEvent(t *testing.T) {
+	ctx := context.Background()
+
+	events := inmem.NewHandlingEventRepository()
+
+	eh := &stubEventHandler{events: make([]interface{}, 0)}
+
+	s := NewService(events, newTestFactory(), eh)
+
+	var (
+		completed = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+		id        = shipping.TrackingID("ABC123")
+	)
+
+	original, err := s.RegisterHandlingEvent(ctx, completed, id, "V100", shipping.CNHKG, shipping.Unload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AmendHandlingEvent(ctx, original, CorrectLocation(shipping.JNTKO)); err != nil {
+		t.Fatal(err)
+	}
+
+	h := events.QueryHandlingHistory(ctx, id)
+	if len(h.HandlingEvents) != 2 {
+		t.Fatalf("len(HandlingEvents) = %d; want = %d", len(h.HandlingEvents), 2)
+	}
+
+	superseded, corrected := h.HandlingEvents[0], h.HandlingEvents[1]
+	if !superseded.Superseded || superseded.Activity.Location != shipping.CNHKG {
+		t.Errorf("original = %v; want superseded at %s", superseded, shipping.CNHKG)
+	}
+	if corrected.Superseded || corrected.Activity.Location != shipping.JNTKO {
+		t.Errorf("corrected = %v; want current at %s", corrected, shipping.JNTKO)
+	}
+	if corrected.Amends == nil || *corrected.Amends != original.Key() {
+		t.Errorf("Amends = %v; want = %v", corrected.Amends, original.Key())
+	}
+
+	c := shipping.NewCargo(id, shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL})
+	c.DeriveDeliveryProgress(h)
+
+	if c.Delivery.LastKnownLocation != shipping.JNTKO {
+		t.Errorf("LastKnownLocation = %s; want = %s", c.Delivery.LastKnownLocation, shipping.JNTKO)
+	}
+	if len(eh.events) != 2 {
+		t.Errorf("len(eh.events) = %d; want = %d", len(eh.events), 2)
+	}
+
+	if err := s.AmendHandlingEvent(ctx, original, CorrectLocation(shipping.NLRTM)); err != shipping.ErrUnknownHandlingEvent {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownHandlingEvent)
+	}
+}
+
+func TestRegisterHandlingEvent_DryRun(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		if id == "no_such_id" {
+			return nil, shipping.ErrUnknownCargo
+		}
+		return new(shipping.Cargo), nil
+	}
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(n shipping.VoyageNumber) (*shipping.Voyage, error) {
+		return new(shipping.Voyage), nil
+	}
+
+	var locations mock.LocationRepository
+	locations.FindFn = func(l shipping.UNLocode) (*shipping.Location, error) {
+		return nil, nil
+	}
+
+	ef := shipping.HandlingEventFactory{
+		CargoRepository:    &cargos,
+		VoyageRepository:   &voyages,
+		LocationRepository: &locations,
+	}
+
+	var (
+		now       = time.Date(2015, time.November, 11, 8, 0, 0, 0, time.UTC)
+		completed = time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	)
+
+	var tests = []struct {
+		name      string
+		completed time.Time
+		id        shipping.TrackingID
+		voyage    shipping.VoyageNumber
+		eventType shipping.HandlingEventType
+		opts      []RegistrationOption
+		want      error
+	}{
+		{"valid", completed, "ABC123", "V100", shipping.Load, nil, nil},
+		{"unknown cargo", completed, "no_such_id", "V100", shipping.Load, nil, shipping.ErrUnknownCargo},
+		{"unload without voyage", completed, "ABC123", "", shipping.Unload, nil, ErrInvalidArgument},
+		{"future", now.Add(time.Hour), "ABC123", "V100", shipping.Load, nil, ErrFutureCompletionTime},
+		{"recipient", completed, "ABC123", "V100", shipping.Load, []RegistrationOption{WithRecipient("Jane Doe", "")}, ErrUnexpectedRecipient},
+		{"clearance", completed, "ABC123", "V100", shipping.Load, []RegistrationOption{WithCustomsCleared(true)}, ErrUnexpectedClearance},
+		{"duplicate", completed, "DEF456", "V100", shipping.Load, nil, shipping.ErrDuplicateEvent},
+	}
+
+	for _, tt := range tests {
+		events := inmem.NewHandlingEventRepository()
+		eh := &stubEventHandler{events: make([]interface{}, 0)}
+
+		s := NewService(events, ef, eh, WithClock(shipping.ClockFunc(func() time.Time { return now })))
+
+		// The duplicate case registers the same event for real first.
+		if tt.want == shipping.ErrDuplicateEvent {
+			if _, err := s.RegisterHandlingEvent(ctx, tt.completed, tt.id, tt.voyage, shipping.SESTO, tt.eventType); err != nil {
+				t.Fatal(err)
+			}
+			eh.events = eh.events[:0]
+		}
+		stored := len(events.QueryHandlingHistory(ctx, tt.id).HandlingEvents)
+
+		_, err := s.RegisterHandlingEvent(ctx, tt.completed, tt.id, tt.voyage, shipping.SESTO, tt.eventType, append(tt.opts, WithDryRun())...)
+		if err != tt.want {
+			t.Errorf("%s: dry run err = %v; want = %v", tt.name, err, tt.want)
+		}
+		if n := len(events.QueryHandlingHistory(ctx, tt.id).HandlingEvents); n != stored {
+			t.Errorf("%s: len(HandlingEvents) = %d after dry run; want = %d", tt.name, n, stored)
+		}
+		if len(eh.events) != 0 {
+			t.Errorf("%s: len(eh.events) = %d after dry run; want = %d", tt.name, len(eh.events), 0)
+		}
+
+		if _, err := s.RegisterHandlingEvent(ctx, tt.completed, tt.id, tt.voyage, shipping.SESTO, tt.eventType, tt.opts...); err != tt.want {
+			t.Errorf("%s: err = %v; want = %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestConfirmPlannedEvent(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2015, time.November, 10, 8, 0, 0, 0, time.UTC)
+	clock := shipping.ClockFunc(func() time.Time { return now })
+
+	events := inmem.NewHandlingEventRepository()
+
+	eh := &stubEventHandler{events: make([]interface{}, 0)}
+
+	s := NewService(events, newTestFactory(), eh, WithClock(clock))
+
+	var (
+		id       = shipping.TrackingID("ABC123")
+		received = now.Add(-time.Hour)
+		loaded   = now.AddDate(0, 0, 2)
+	)
+
+	if _, err := s.RegisterHandlingEvent(ctx, received, id, "", shipping.SESTO, shipping.Receive); err != nil {
+		t.Fatal(err)
+	}
+
+	planned, err := s.RegisterHandlingEvent(ctx, loaded, id, "V100", shipping.SESTO, shipping.Load, AsPlanned())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !planned.Planned {
+		t.Errorf("Planned = %v; want = %v", planned.Planned, true)
+	}
+	if len(eh.events) != 1 {
+		t.Errorf("len(eh.events) = %d; want = %d", len(eh.events), 1)
+	}
+
+	c := shipping.NewCargo(id, shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL})
+	c.DeriveDeliveryProgress(events.QueryHandlingHistory(ctx, id))
+
+	if c.Delivery.TransportStatus != shipping.InPort {
+		t.Errorf("TransportStatus = %v; want = %v", c.Delivery.TransportStatus, shipping.InPort)
+	}
+
+	if err := s.ConfirmPlannedEvent(ctx, planned); err != ErrFutureCompletionTime {
+		t.Errorf("err = %v; want = %v", err, ErrFutureCompletionTime)
+	}
+
+	now = loaded
+
+	if err := s.ConfirmPlannedEvent(ctx, planned); err != nil {
+		t.Fatal(err)
+	}
+	if len(eh.events) != 2 {
+		t.Errorf("len(eh.events) = %d; want = %d", len(eh.events), 2)
+	}
+
+	c.DeriveDeliveryProgress(events.QueryHandlingHistory(ctx, id))
+
+	if c.Delivery.TransportStatus != shipping.OnboardCarrier {
+		t.Errorf("TransportStatus = %v; want = %v", c.Delivery.TransportStatus, shipping.OnboardCarrier)
+	}
+	if c.Delivery.CurrentVoyage != "V100" {
+		t.Errorf("CurrentVoyage = %s; want = %s", c.Delivery.CurrentVoyage, "V100")
+	}
+
+	if err := s.ConfirmPlannedEvent(ctx, planned); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+}
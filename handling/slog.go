@@ -0,0 +1,83 @@
+//go:build go1.21
+
+package handling
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type slogService struct {
+	next Service
+}
+
+// NewSlogService returns a Service that logs every call to the logger of the
+// context, as by shipping.LogCall.
+func NewSlogService(s Service) Service {
+	return &slogService{s}
+}
+
+func (s *slogService) RegisterHandlingEvent(ctx context.Context, completed time.Time, id shipping.TrackingID, voyageNumber shipping.VoyageNumber,
+	unLocode shipping.UNLocode, eventType shipping.HandlingEventType, opts ...RegistrationOption) (e shipping.HandlingEvent, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "register_incident", begin, err,
+			slog.String("tracking_id", string(id)),
+			slog.String("location", string(unLocode)),
+			slog.String("voyage", string(voyageNumber)),
+			slog.String("event_type", eventType.String()),
+			slog.Time("completion_time", completed),
+		)
+	}(time.Now())
+	return s.next.RegisterHandlingEvent(ctx, completed, id, voyageNumber, unLocode, eventType, opts...)
+}
+
+func (s *slogService) RegisterHandlingEvents(ctx context.Context, events []HandlingEventRegistration) (errs []error, err error) {
+	defer func(begin time.Time) {
+		var failed int
+		for _, e := range errs {
+			if e != nil {
+				failed++
+			}
+		}
+		shipping.LogCall(ctx, "register_incidents", begin, err,
+			slog.Int("count", len(events)),
+			slog.Int("failed", failed),
+		)
+	}(time.Now())
+	return s.next.RegisterHandlingEvents(ctx, events)
+}
+
+func (s *slogService) SearchHandlingEvents(ctx context.Context, criteria shipping.SearchCriteria) (events []shipping.HandlingEvent, total int, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "search_events", begin, err,
+			slog.String("event_type", criteria.Type.String()),
+			slog.String("location", string(criteria.Location)),
+			slog.String("voyage", string(criteria.VoyageNumber)),
+			slog.Int("total", total),
+		)
+	}(time.Now())
+	return s.next.SearchHandlingEvents(ctx, criteria)
+}
+
+func (s *slogService) AmendHandlingEvent(ctx context.Context, original shipping.HandlingEvent, corrections ...Correction) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "amend_incident", begin, err,
+			slog.String("tracking_id", string(original.TrackingID)),
+			slog.String("event_type", original.Activity.Type.String()),
+		)
+	}(time.Now())
+	return s.next.AmendHandlingEvent(ctx, original, corrections...)
+}
+
+func (s *slogService) ConfirmPlannedEvent(ctx context.Context, planned shipping.HandlingEvent) (err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "confirm_planned_incident", begin, err,
+			slog.String("tracking_id", string(planned.TrackingID)),
+			slog.String("event_type", planned.Activity.Type.String()),
+		)
+	}(time.Now())
+	return s.next.ConfirmPlannedEvent(ctx, planned)
+}
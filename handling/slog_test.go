@@ -0,0 +1,46 @@
+//go:build go1.21
+
+package handling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestSlogService_RegisterHandlingEventsError(t *testing.T) {
+	var buf bytes.Buffer
+
+	ctx := shipping.NewLoggerContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	s := NewSlogService(NewService(nil, shipping.HandlingEventFactory{}, nil))
+
+	if _, err := s.RegisterHandlingEvents(ctx, nil); err != ErrInvalidArgument {
+		t.Fatalf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+
+	if line["level"] != "WARN" {
+		t.Errorf("level = %v; want = %v", line["level"], "WARN")
+	}
+	if line["method"] != "register_incidents" {
+		t.Errorf("method = %v; want = %v", line["method"], "register_incidents")
+	}
+	if line["count"] != float64(0) {
+		t.Errorf("count = %v; want = %v", line["count"], 0)
+	}
+	if line["err"] != ErrInvalidArgument.Error() {
+		t.Errorf("err = %v; want = %v", line["err"], ErrInvalidArgument)
+	}
+	if _, ok := line["took"]; !ok {
+		t.Errorf("took should be logged")
+	}
+}
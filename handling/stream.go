@@ -0,0 +1,89 @@
+package handling
+
+import (
+	"context"
+	"sync"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// EventStream is an EventHandler that fans handled events out to its
+// subscribers, such as clients of a live view of the handling.
+type EventStream interface {
+	EventHandler
+
+	// Subscribe returns a channel of the events handled from now on, and a
+	// function that ends the subscription. The channel is closed when the
+	// subscription ends, including when the subscriber is dropped for not
+	// keeping up.
+	Subscribe() (events <-chan shipping.HandlingEvent, cancel func())
+
+	// Close ends all subscriptions, and makes new ones end immediately.
+	Close(ctx context.Context) error
+}
+
+type eventStream struct {
+	buffer int
+
+	mtx    sync.Mutex
+	subs   map[chan shipping.HandlingEvent]struct{}
+	closed bool
+}
+
+// NewEventStream returns an EventStream that buffers up to buffer events for
+// each subscriber. A subscriber whose buffer is full is dropped, so that a
+// slow subscriber never blocks the handling of events.
+func NewEventStream(buffer int) EventStream {
+	return &eventStream{
+		buffer: buffer,
+		subs:   make(map[chan shipping.HandlingEvent]struct{}),
+	}
+}
+
+func (s *eventStream) CargoWasHandled(ctx context.Context, e shipping.HandlingEvent) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (s *eventStream) Subscribe() (<-chan shipping.HandlingEvent, func()) {
+	ch := make(chan shipping.HandlingEvent, s.buffer)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	s.subs[ch] = struct{}{}
+
+	return ch, func() {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (s *eventStream) Close(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.closed = true
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+	return nil
+}
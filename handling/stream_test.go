@@ -0,0 +1,61 @@
+package handling
+
+import (
+	"context"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestEventStream_DropsSlowSubscriber(t *testing.T) {
+	ctx := context.Background()
+
+	s := NewEventStream(1)
+
+	slow, _ := s.Subscribe()
+	fast, cancel := s.Subscribe()
+	defer cancel()
+
+	first := shipping.HandlingEvent{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}}
+	second := shipping.HandlingEvent{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}}
+
+	s.CargoWasHandled(ctx, first)
+	if got := <-fast; got != first {
+		t.Errorf("fast = %v; want = %v", got, first)
+	}
+
+	// The slow subscriber has not read the first event, and is dropped
+	// instead of blocking the second.
+	s.CargoWasHandled(ctx, second)
+	if got := <-fast; got != second {
+		t.Errorf("fast = %v; want = %v", got, second)
+	}
+
+	if got := <-slow; got != first {
+		t.Errorf("slow = %v; want = %v", got, first)
+	}
+	if _, ok := <-slow; ok {
+		t.Errorf("slow subscriber was not dropped")
+	}
+}
+
+func TestEventStream_Close(t *testing.T) {
+	ctx := context.Background()
+
+	s := NewEventStream(1)
+
+	events, _ := s.Subscribe()
+
+	if err := s.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-events; ok {
+		t.Errorf("subscription was not ended")
+	}
+
+	late, _ := s.Subscribe()
+	if _, ok := <-late; ok {
+		t.Errorf("subscription after close was not ended")
+	}
+}
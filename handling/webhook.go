@@ -0,0 +1,146 @@
+package handling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+const (
+	// webhookAttempts is the number of times a notification is attempted
+	// before it is dropped.
+	webhookAttempts = 3
+
+	// webhookTimeout bounds the time spent on a single attempt.
+	webhookTimeout = 5 * time.Second
+)
+
+// WebhookNotifier is an EventHandler that notifies an external endpoint of
+// claimed cargos.
+type WebhookNotifier interface {
+	EventHandler
+
+	// Close stops sending new notifications and blocks until the
+	// notifications in flight have been sent, or the context is done.
+	Close(ctx context.Context) error
+}
+
+type webhookNotifier struct {
+	url     string
+	client  *http.Client
+	logger  log.Logger
+	backoff time.Duration
+
+	mtx      sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+type claimNotification struct {
+	TrackingID     string `json:"trackingId"`
+	Location       string `json:"location"`
+	CompletionTime string `json:"completionTime"`
+}
+
+func (n *webhookNotifier) CargoWasHandled(ctx context.Context, e shipping.HandlingEvent) {
+	if e.Activity.Type != shipping.Claim {
+		return
+	}
+
+	logger := log.With(n.logger, "correlation_id", shipping.CorrelationFromContext(ctx))
+
+	n.mtx.Lock()
+	if n.closed {
+		n.mtx.Unlock()
+		logger.Log("method", "notify", "tracking_id", e.TrackingID, "err", "notifier closed")
+		return
+	}
+	n.inflight.Add(1)
+	n.mtx.Unlock()
+
+	defer n.inflight.Done()
+
+	body, err := json.Marshal(claimNotification{
+		TrackingID:     string(e.TrackingID),
+		Location:       string(e.Activity.Location),
+		CompletionTime: e.CompletionTime.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		logger.Log("method", "notify", "tracking_id", e.TrackingID, "err", err)
+		return
+	}
+
+	for i := 1; i <= webhookAttempts; i++ {
+		retry, err := n.post(ctx, body)
+		if err == nil {
+			return
+		}
+		logger.Log("method", "notify", "tracking_id", e.TrackingID, "attempt", i, "err", err)
+		if !retry {
+			return
+		}
+
+		select {
+		case <-time.After(time.Duration(i) * n.backoff):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *webhookNotifier) Close(ctx context.Context) error {
+	n.mtx.Lock()
+	n.closed = true
+	n.mtx.Unlock()
+
+	return wait(ctx, &n.inflight)
+}
+
+// post sends the notification once, and reports whether a failure may be
+// resolved by trying again.
+func (n *webhookNotifier) post(ctx context.Context, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return ctx.Err() != context.Canceled, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return false, nil
+}
+
+// NewWebhookNotifier returns an EventHandler that POSTs a JSON notification
+// to url whenever a cargo is claimed; other events are ignored. Failed
+// notifications are retried a few times and then logged and dropped. Since
+// event handlers are invoked synchronously, a slow endpoint delays the
+// registration of claims. If client is nil, http.DefaultClient is used.
+func NewWebhookNotifier(url string, client *http.Client, logger log.Logger) WebhookNotifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &webhookNotifier{
+		url:     url,
+		client:  client,
+		logger:  logger,
+		backoff: 500 * time.Millisecond,
+	}
+}
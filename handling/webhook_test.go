@@ -0,0 +1,152 @@
+package handling
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	ctx := context.Background()
+
+	var received []claimNotification
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("r.Method = %s; want = %s", r.Method, "POST")
+		}
+		var n claimNotification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Error(err)
+		}
+		received = append(received, n)
+	}))
+	defer srv.Close()
+
+	h := NewWebhookNotifier(srv.URL, srv.Client(), log.NewNopLogger())
+
+	completed := time.Date(2009, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, typ := range []shipping.HandlingEventType{shipping.Receive, shipping.Load, shipping.Unload, shipping.Customs} {
+		h.CargoWasHandled(ctx, shipping.HandlingEvent{
+			TrackingID:     "ABC123",
+			Activity:       shipping.HandlingActivity{Type: typ, Location: shipping.SESTO},
+			CompletionTime: completed,
+		})
+	}
+
+	if len(received) != 0 {
+		t.Fatalf("len(received) = %d; want = %d", len(received), 0)
+	}
+
+	h.CargoWasHandled(ctx, shipping.HandlingEvent{
+		TrackingID:     "ABC123",
+		Activity:       shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL},
+		CompletionTime: completed,
+	})
+
+	if len(received) != 1 {
+		t.Fatalf("len(received) = %d; want = %d", len(received), 1)
+	}
+
+	want := claimNotification{
+		TrackingID:     "ABC123",
+		Location:       "AUMEL",
+		CompletionTime: "2009-03-01T12:00:00Z",
+	}
+	if received[0] != want {
+		t.Errorf("received[0] = %v; want = %v", received[0], want)
+	}
+}
+
+func TestWebhookNotifier_Retry(t *testing.T) {
+	ctx := context.Background()
+
+	var tests = []struct {
+		status []int
+		want   int
+	}{
+		{[]int{http.StatusOK}, 1},
+		{[]int{http.StatusServiceUnavailable, http.StatusOK}, 2},
+		{[]int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK}, webhookAttempts},
+		{[]int{http.StatusBadRequest, http.StatusOK}, 1},
+	}
+
+	for _, tt := range tests {
+		var attempts int
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status[attempts])
+			attempts++
+		}))
+
+		h := NewWebhookNotifier(srv.URL, srv.Client(), log.NewNopLogger())
+		h.(*webhookNotifier).backoff = 0
+
+		h.CargoWasHandled(ctx, shipping.HandlingEvent{
+			TrackingID: "ABC123",
+			Activity:   shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL},
+		})
+
+		if attempts != tt.want {
+			t.Errorf("%v: attempts = %d; want = %d", tt.status, attempts, tt.want)
+		}
+
+		srv.Close()
+	}
+}
+
+func TestWebhookNotifier_Close(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		mtx       sync.Mutex
+		delivered int
+		received  = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		time.Sleep(50 * time.Millisecond)
+		mtx.Lock()
+		delivered++
+		mtx.Unlock()
+	}))
+	defer srv.Close()
+
+	h := NewWebhookNotifier(srv.URL, srv.Client(), log.NewNopLogger())
+
+	claim := shipping.HandlingEvent{
+		TrackingID: "ABC123",
+		Activity:   shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL},
+	}
+
+	go h.CargoWasHandled(ctx, claim)
+	<-received
+
+	// Close waits for the notification in flight.
+	if err := h.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if delivered != 1 {
+		t.Errorf("delivered = %d; want = %d", delivered, 1)
+	}
+
+	// Notifications are no longer sent once closed.
+	h.CargoWasHandled(ctx, claim)
+
+	if delivered != 1 {
+		t.Errorf("delivered = %d; want = %d", delivered, 1)
+	}
+}
@@ -0,0 +1,507 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlingEvent_JSON(t *testing.T) {
+	var (
+		registered = time.Date(2009, time.March, 1, 12, 30, 0, 0, time.UTC)
+		completed  = time.Date(2009, time.March, 1, 10, 0, 0, 0, time.UTC)
+	)
+
+	tests := []HandlingEvent{
+		{
+			TrackingID: "ABC123",
+			Activity: HandlingActivity{
+				Type:         Load,
+				Location:     SESTO,
+				VoyageNumber: "V100",
+			},
+			RegistrationTime: registered,
+			CompletionTime:   completed,
+			IdempotencyKey:   "scan-1",
+			EquipmentID:      "MSCU1234565",
+			Instructions:     "Keep refrigerated",
+		},
+		{
+			TrackingID: "ABC123",
+			Activity: HandlingActivity{
+				Type:     Customs,
+				Location: CNHKG,
+			},
+			RegistrationTime: registered,
+			CompletionTime:   completed,
+			CustomsCleared:   true,
+		},
+		{
+			TrackingID: "ABC123",
+			Activity: HandlingActivity{
+				Type:     Claim,
+				Location: CNHKG,
+			},
+			RegistrationTime:   registered,
+			CompletionTime:     completed,
+			RecipientName:      "Jane Doe",
+			RecipientSignature: "iVBORw0KGgo=",
+		},
+		{
+			TrackingID: "ABC123",
+			Activity: HandlingActivity{
+				Type:     Unload,
+				Location: JNTKO,
+			},
+			RegistrationTime: registered,
+			CompletionTime:   completed,
+			Superseded:       true,
+			Amends: &HandlingEventKey{
+				TrackingID:     "ABC123",
+				Activity:       HandlingActivity{Type: Unload, Location: CNHKG, VoyageNumber: "V100"},
+				CompletionTime: completed,
+			},
+		},
+		{},
+	}
+
+	for _, want := range tests {
+		b, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got HandlingEvent
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip of %s = %v; want = %v", b, got, want)
+		}
+	}
+}
+
+func TestHandlingEvent_MarshalJSON(t *testing.T) {
+	e := HandlingEvent{
+		TrackingID: "ABC123",
+		Activity: HandlingActivity{
+			Type:         Unload,
+			Location:     AUMEL,
+			VoyageNumber: "V100",
+		},
+		RegistrationTime: time.Date(2009, time.March, 2, 0, 0, 0, 0, time.UTC),
+		CompletionTime:   time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"trackingId":"ABC123","type":"Unload","location":"AUMEL","voyage":"V100","registrationTime":"2009-03-02T00:00:00Z","completionTime":"2009-03-01T00:00:00Z"}`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s; want = %s", b, want)
+	}
+}
+
+func TestHandlingEvent_UnmarshalJSON_UnknownType(t *testing.T) {
+	data := `{"trackingId":"ABC123","type":"Teleport","location":"AUMEL","registrationTime":"2009-03-02T00:00:00Z","completionTime":"2009-03-01T00:00:00Z"}`
+
+	var e HandlingEvent
+	err := json.Unmarshal([]byte(data), &e)
+	if err == nil {
+		t.Fatal("err = nil; want an error")
+	}
+	if !strings.Contains(err.Error(), "Teleport") {
+		t.Errorf("err = %v; want it to mention the unknown type", err)
+	}
+}
+
+func TestHandlingHistory_SortedByCompletionTime(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 10, 0, 0, 0, time.UTC)
+		t1 = t0.Add(time.Hour)
+	)
+
+	var (
+		receive = HandlingEvent{Activity: HandlingActivity{Type: Receive, Location: SESTO}, CompletionTime: t0, RegistrationTime: t1}
+		load    = HandlingEvent{Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, CompletionTime: t0, RegistrationTime: t1}
+		unload  = HandlingEvent{Activity: HandlingActivity{Type: Unload, Location: CNHKG, VoyageNumber: "V100"}, CompletionTime: t1, RegistrationTime: t1}
+		claim   = HandlingEvent{Activity: HandlingActivity{Type: Claim, Location: CNHKG}, CompletionTime: t1, RegistrationTime: t0}
+	)
+
+	want := HandlingHistory{HandlingEvents: []HandlingEvent{receive, load, claim, unload}}
+
+	for _, events := range [][]HandlingEvent{
+		{receive, load, unload, claim},
+		{load, receive, claim, unload},
+		{unload, claim, load, receive},
+	} {
+		h := HandlingHistory{HandlingEvents: events}
+		if got := h.SortedByCompletionTime(); !reflect.DeepEqual(got, want) {
+			t.Errorf("SortedByCompletionTime() = %v; want = %v", got, want)
+		}
+	}
+}
+
+func TestHandlingHistory_Merge(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 10, 0, 0, 0, time.UTC)
+		t1 = t0.Add(time.Hour)
+		t2 = t0.Add(2 * time.Hour)
+	)
+
+	var (
+		receive = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Receive, Location: SESTO}, CompletionTime: t0}
+		load    = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, CompletionTime: t1}
+		unload  = HandlingEvent{TrackingID: "ABC", Activity: HandlingActivity{Type: Unload, Location: CNHKG, VoyageNumber: "V100"}, CompletionTime: t2}
+
+		// The carrier reports the load in another zone, and with its own
+		// registration time.
+		carrierLoad = HandlingEvent{TrackingID: "ABC", Activity: load.Activity, CompletionTime: t1.In(time.FixedZone("CET", 3600)), RegistrationTime: t2}
+	)
+
+	scanners := HandlingHistory{HandlingEvents: []HandlingEvent{load, receive}}
+	carrier := HandlingHistory{HandlingEvents: []HandlingEvent{unload, carrierLoad}}
+
+	want := HandlingHistory{HandlingEvents: []HandlingEvent{receive, load, unload}}
+
+	if got := scanners.Merge(carrier); !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %v; want = %v", got, want)
+	}
+
+	// The merge is stable, and the other way around keeps the event of the
+	// carrier.
+	if got := scanners.Merge(carrier).Merge(carrier); !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge().Merge() = %v; want = %v", got, want)
+	}
+	if got := carrier.Merge(scanners).HandlingEvents; len(got) != 3 || got[1] != carrierLoad {
+		t.Errorf("Merge() = %v; want the load of the carrier", got)
+	}
+}
+
+func TestHandlingHistory_FilterByType(t *testing.T) {
+	var (
+		receive = HandlingEvent{Activity: HandlingActivity{Type: Receive, Location: SESTO}}
+		load1   = HandlingEvent{Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}}
+		unload1 = HandlingEvent{Activity: HandlingActivity{Type: Unload, Location: CNHKG, VoyageNumber: "V100"}}
+		customs = HandlingEvent{Activity: HandlingActivity{Type: Customs, Location: CNHKG}}
+		load2   = HandlingEvent{Activity: HandlingActivity{Type: Load, Location: CNHKG, VoyageNumber: "V200"}}
+		unload2 = HandlingEvent{Activity: HandlingActivity{Type: Unload, Location: AUMEL, VoyageNumber: "V200"}}
+		claim   = HandlingEvent{Activity: HandlingActivity{Type: Claim, Location: AUMEL}}
+	)
+
+	h := HandlingHistory{HandlingEvents: []HandlingEvent{receive, load1, unload1, customs, load2, unload2, claim}}
+
+	got := h.FilterByType(Load, Unload)
+	want := HandlingHistory{HandlingEvents: []HandlingEvent{load1, unload1, load2, unload2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterByType(Load, Unload) = %v; want = %v", got, want)
+	}
+
+	if got := h.FilterByType(); !reflect.DeepEqual(got, h) {
+		t.Errorf("FilterByType() = %v; want = %v", got, h)
+	}
+}
+
+func TestHandlingHistory_Validate(t *testing.T) {
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	event := func(day int, typ HandlingEventType, loc UNLocode, voyage VoyageNumber) HandlingEvent {
+		return HandlingEvent{
+			TrackingID:     "ABC123",
+			Activity:       HandlingActivity{Type: typ, Location: loc, VoyageNumber: voyage},
+			CompletionTime: t0.AddDate(0, 0, day),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		events  []HandlingEvent
+		indices [][]int
+	}{
+		{
+			name: "clean",
+			events: []HandlingEvent{
+				event(0, Receive, SESTO, ""),
+				event(1, Load, SESTO, "V100"),
+				event(2, Unload, DEHAM, "V100"),
+				event(3, Load, DEHAM, "V200"),
+				event(4, Unload, AUMEL, "V200"),
+				event(5, Claim, AUMEL, ""),
+			},
+		},
+		{
+			name: "clean out of order",
+			events: []HandlingEvent{
+				event(2, Unload, DEHAM, "V100"),
+				event(1, Load, SESTO, "V100"),
+			},
+		},
+		{
+			name: "double load",
+			events: []HandlingEvent{
+				event(1, Load, SESTO, "V100"),
+				event(2, Load, SESTO, "V100"),
+				event(3, Unload, DEHAM, "V100"),
+			},
+			indices: [][]int{{0, 1}},
+		},
+		{
+			name: "unload without load",
+			events: []HandlingEvent{
+				event(0, Receive, SESTO, ""),
+				event(1, Unload, DEHAM, "V100"),
+			},
+			indices: [][]int{{1}},
+		},
+		{
+			name: "voyage mismatch",
+			events: []HandlingEvent{
+				event(1, Load, SESTO, "V100"),
+				event(2, Unload, DEHAM, "V200"),
+			},
+			indices: [][]int{{0, 1}},
+		},
+		{
+			name: "unloaded where loaded",
+			events: []HandlingEvent{
+				event(1, Load, SESTO, "V100"),
+				event(2, Unload, SESTO, "V100"),
+			},
+			indices: [][]int{{0, 1}},
+		},
+		{
+			name: "several anomalies",
+			events: []HandlingEvent{
+				event(3, Unload, AUMEL, "V300"),
+				event(1, Unload, DEHAM, "V100"),
+				event(2, Load, DEHAM, "V200"),
+			},
+			indices: [][]int{{1}, {2, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		errs := HandlingHistory{HandlingEvents: tt.events}.Validate()
+
+		if len(errs) != len(tt.indices) {
+			t.Errorf("%s: len(errs) = %d; want = %d (%v)", tt.name, len(errs), len(tt.indices), errs)
+			continue
+		}
+
+		for i, err := range errs {
+			serr, ok := err.(*HandlingSequenceError)
+			if !ok {
+				t.Errorf("%s: errs[%d] = %v; want a *HandlingSequenceError", tt.name, i, err)
+				continue
+			}
+			if !reflect.DeepEqual(serr.Indices, tt.indices[i]) {
+				t.Errorf("%s: errs[%d].Indices = %v; want = %v", tt.name, i, serr.Indices, tt.indices[i])
+			}
+		}
+	}
+}
+
+type stubCargoRepository struct{ CargoRepository }
+
+func (stubCargoRepository) Find(ctx context.Context, id TrackingID) (*Cargo, error) {
+	return NewCargo(id, RouteSpecification{}), nil
+}
+
+type recordingVoyageRepository struct {
+	VoyageRepository
+	found []VoyageNumber
+}
+
+func (r *recordingVoyageRepository) Find(ctx context.Context, n VoyageNumber) (*Voyage, error) {
+	r.found = append(r.found, n)
+	if n != V100.VoyageNumber {
+		return nil, ErrUnknownVoyage
+	}
+	return V100, nil
+}
+
+type stubLocationRepository struct{ LocationRepository }
+
+func (stubLocationRepository) Find(ctx context.Context, l UNLocode) (*Location, error) {
+	return Stockholm, nil
+}
+
+func TestHandlingEventFactory_CreateHandlingEvent_Voyage(t *testing.T) {
+	ctx := context.Background()
+
+	var tests = []struct {
+		eventType HandlingEventType
+		voyage    VoyageNumber
+		want      error
+		lookup    bool
+		stored    VoyageNumber
+	}{
+		{Load, V100.VoyageNumber, nil, true, V100.VoyageNumber},
+		{Load, "", ErrInvalidArgument, false, ""},
+		{Unload, V100.VoyageNumber, nil, true, V100.VoyageNumber},
+		{Unload, "", ErrInvalidArgument, false, ""},
+		{Receive, V100.VoyageNumber, nil, false, ""},
+		{Receive, "", nil, false, ""},
+		{Claim, V100.VoyageNumber, nil, false, ""},
+		{Claim, "", nil, false, ""},
+		{Customs, V100.VoyageNumber, nil, false, ""},
+		{Customs, "", nil, false, ""},
+	}
+
+	for _, tt := range tests {
+		var voyages recordingVoyageRepository
+
+		f := HandlingEventFactory{
+			CargoRepository:    stubCargoRepository{},
+			VoyageRepository:   &voyages,
+			LocationRepository: stubLocationRepository{},
+		}
+
+		now := time.Now()
+		e, err := f.CreateHandlingEvent(ctx, now, now, "ABC123", tt.voyage, SESTO, tt.eventType, "")
+		if err != tt.want {
+			t.Errorf("%s(%q): err = %v; want = %v", tt.eventType, tt.voyage, err, tt.want)
+		}
+		if e.Activity.VoyageNumber != tt.stored {
+			t.Errorf("%s(%q): VoyageNumber = %q; want = %q", tt.eventType, tt.voyage, e.Activity.VoyageNumber, tt.stored)
+		}
+		if got := len(voyages.found) > 0; got != tt.lookup {
+			t.Errorf("%s(%q): voyage looked up = %v; want = %v", tt.eventType, tt.voyage, got, tt.lookup)
+		}
+	}
+}
+
+type routedCargoRepository struct {
+	CargoRepository
+	itinerary Itinerary
+}
+
+func (r routedCargoRepository) Find(ctx context.Context, id TrackingID) (*Cargo, error) {
+	c := NewCargo(id, RouteSpecification{})
+	c.Itinerary = r.itinerary
+	return c, nil
+}
+
+func TestHandlingEventFactory_CreateHandlingEvent_Instructions(t *testing.T) {
+	ctx := context.Background()
+
+	f := HandlingEventFactory{
+		CargoRepository: routedCargoRepository{itinerary: Itinerary{Legs: []Leg{
+			{VoyageNumber: V100.VoyageNumber, LoadLocation: SESTO, UnloadLocation: NLRTM, Instructions: "Keep refrigerated"},
+		}}},
+		VoyageRepository:   &recordingVoyageRepository{},
+		LocationRepository: stubLocationRepository{},
+	}
+
+	var tests = []struct {
+		eventType HandlingEventType
+		location  UNLocode
+		voyage    VoyageNumber
+		want      string
+	}{
+		{Load, SESTO, V100.VoyageNumber, "Keep refrigerated"},
+		{Unload, NLRTM, V100.VoyageNumber, "Keep refrigerated"},
+		{Unload, SESTO, V100.VoyageNumber, ""},
+		{Receive, SESTO, "", ""},
+	}
+
+	for _, tt := range tests {
+		now := time.Now()
+		e, err := f.CreateHandlingEvent(ctx, now, now, "ABC123", tt.voyage, tt.location, tt.eventType, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Instructions != tt.want {
+			t.Errorf("%s in %s: Instructions = %q; want = %q", tt.eventType, tt.location, e.Instructions, tt.want)
+		}
+	}
+}
+
+func TestHandlingEventFactory_CreateHandlingEvent_UTC(t *testing.T) {
+	ctx := context.Background()
+
+	f := HandlingEventFactory{
+		CargoRepository:    stubCargoRepository{},
+		LocationRepository: stubLocationRepository{},
+	}
+
+	var (
+		stockholm = time.FixedZone("CET", 1*60*60)
+		newYork   = time.FixedZone("EST", -5*60*60)
+		tokyo     = time.FixedZone("JST", 9*60*60)
+	)
+
+	// Reported in local time by scanners in different zones, the events
+	// were completed an hour apart, in the order listed.
+	completed := []time.Time{
+		time.Date(2009, time.March, 1, 18, 0, 0, 0, tokyo),
+		time.Date(2009, time.March, 1, 11, 0, 0, 0, stockholm),
+		time.Date(2009, time.March, 1, 6, 0, 0, 0, newYork),
+	}
+
+	var h HandlingHistory
+	for i := len(completed) - 1; i >= 0; i-- {
+		e, err := f.CreateHandlingEvent(ctx, completed[i].In(newYork), completed[i], "ABC123", "", SESTO, Receive, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e.CompletionTime.Location() != time.UTC {
+			t.Errorf("CompletionTime.Location() = %v; want = %v", e.CompletionTime.Location(), time.UTC)
+		}
+		if e.RegistrationTime.Location() != time.UTC {
+			t.Errorf("RegistrationTime.Location() = %v; want = %v", e.RegistrationTime.Location(), time.UTC)
+		}
+		if !e.CompletionTime.Equal(completed[i]) {
+			t.Errorf("CompletionTime = %v; want = %v", e.CompletionTime, completed[i])
+		}
+
+		h.HandlingEvents = append(h.HandlingEvents, e)
+	}
+
+	sorted := h.SortedByCompletionTime()
+	for i, e := range sorted.HandlingEvents {
+		if want := completed[i].UTC(); e.CompletionTime != want {
+			t.Errorf("sorted[%d].CompletionTime = %v; want = %v", i, e.CompletionTime, want)
+		}
+	}
+}
+
+func TestParseHandlingEventType(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want HandlingEventType
+	}{
+		{"Receive", Receive},
+		{"LOAD", Load},
+		{"unload", Unload},
+		{" Customs ", Customs},
+		{"cLaIm", Claim},
+	} {
+		got, err := ParseHandlingEventType(tt.in)
+		if err != nil {
+			t.Errorf("ParseHandlingEventType(%q) err = %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseHandlingEventType(%q) = %v; want = %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "Sail", "Not Handled", NotHandled.String()} {
+		if _, err := ParseHandlingEventType(in); err != ErrInvalidHandlingEventType {
+			t.Errorf("ParseHandlingEventType(%q) err = %v; want = %v", in, err, ErrInvalidHandlingEventType)
+		}
+	}
+
+	for _, typ := range []HandlingEventType{Receive, Load, Unload, Customs, Claim} {
+		if got, err := ParseHandlingEventType(typ.String()); err != nil || got != typ {
+			t.Errorf("ParseHandlingEventType(%q) = %v, %v; want = %v, %v", typ.String(), got, err, typ, nil)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package health provides readiness checks of the dependencies of the
+// service.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// DefaultTimeout is the time a check is given to complete.
+const DefaultTimeout = 5 * time.Second
+
+// Check reports whether a dependency is available, by returning an error if
+// it is not.
+type Check func(ctx context.Context) error
+
+// Component is a named dependency and its check.
+type Component struct {
+	Name  string
+	Check Check
+}
+
+// Status is the outcome of checking a single dependency.
+type Status struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the outcome of checking all dependencies. The service is ready
+// if all of its dependencies are.
+type Report struct {
+	Ready      bool     `json:"ready"`
+	Components []Status `json:"components"`
+}
+
+// Checker checks the dependencies of the service.
+type Checker interface {
+	// Check checks every dependency concurrently, and reports on them in
+	// the order they were given.
+	Check(ctx context.Context) Report
+}
+
+type checker struct {
+	components []Component
+	timeout    time.Duration
+}
+
+func (c *checker) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	statuses := make([]Status, len(c.components))
+
+	var wg sync.WaitGroup
+	for i, comp := range c.components {
+		wg.Add(1)
+		go func(i int, comp Component) {
+			defer wg.Done()
+			statuses[i] = Status{Name: comp.Name, Ready: true}
+			if err := run(ctx, comp.Check); err != nil {
+				statuses[i] = Status{Name: comp.Name, Error: err.Error()}
+			}
+		}(i, comp)
+	}
+	wg.Wait()
+
+	r := Report{Ready: true, Components: statuses}
+	for _, s := range statuses {
+		r.Ready = r.Ready && s.Ready
+	}
+	return r
+}
+
+// run runs the check, failing it if it panics or outlives the context.
+func run(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewChecker returns a Checker of the given dependencies, giving each check
+// DefaultTimeout to complete.
+func NewChecker(components ...Component) Checker {
+	return &checker{
+		components: components,
+		timeout:    DefaultTimeout,
+	}
+}
+
+// URLCheck returns a check that the service at the given URL is reachable.
+// Any response but a server error counts as reachable.
+func URLCheck(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("%s responded %d %s", url, resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		return nil
+	}
+}
+
+// SentinelTrackingID identifies the cargo looked up by CargoRepositoryCheck.
+// It is longer than the generated tracking IDs, so no booked cargo has it.
+const SentinelTrackingID shipping.TrackingID = "HEALTHCHECK"
+
+// CargoRepositoryCheck returns a check that the repository can be read, by
+// looking up the sentinel cargo. The repository is never written to.
+func CargoRepositoryCheck(r shipping.CargoRepository) Check {
+	return func(ctx context.Context) error {
+		_, err := r.Find(ctx, SentinelTrackingID)
+		if err == shipping.ErrUnknownCargo {
+			return nil
+		}
+		return err
+	}
+}
+
+// NewHandler returns a handler that responds with the report of the checker
+// as JSON, with status 200 if ready and 503 otherwise.
+func NewHandler(c Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Check(r.Context())
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
+	"github.com/marcusolsson/goddd/mock"
+)
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+
+	routing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer routing.Close()
+
+	cargos := inmem.NewCargoRepository()
+
+	c := NewChecker(
+		Component{Name: "routing", Check: URLCheck(http.DefaultClient, routing.URL)},
+		Component{Name: "cargos", Check: CargoRepositoryCheck(cargos)},
+	)
+
+	r := c.Check(ctx)
+
+	if r.Ready {
+		t.Errorf("r.Ready = %v; want = %v", r.Ready, false)
+	}
+	if len(r.Components) != 2 {
+		t.Fatalf("len(r.Components) = %d; want = %d", len(r.Components), 2)
+	}
+	if s := r.Components[0]; s.Name != "routing" || s.Ready || s.Error == "" {
+		t.Errorf("r.Components[0] = %+v; want failing routing", s)
+	}
+	if s := r.Components[1]; s.Name != "cargos" || !s.Ready {
+		t.Errorf("r.Components[1] = %+v; want ready cargos", s)
+	}
+
+	// The check does not write to the repository.
+	if _, err := cargos.Find(ctx, SentinelTrackingID); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+	if all := cargos.FindAllIncludingArchived(ctx); len(all) != 0 {
+		t.Errorf("len(FindAllIncludingArchived) = %d; want = %d", len(all), 0)
+	}
+}
+
+func TestCargoRepositoryCheck_Unavailable(t *testing.T) {
+	down := errors.New("connection refused")
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return nil, down
+	}
+
+	if err := CargoRepositoryCheck(&cargos)(context.Background()); err != down {
+		t.Errorf("err = %v; want = %v", err, down)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var tests = []struct {
+		err  error
+		code int
+	}{
+		{nil, http.StatusOK},
+		{errors.New("down"), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		c := NewChecker(Component{Name: "repository", Check: func(context.Context) error { return tt.err }})
+
+		rec := httptest.NewRecorder()
+		NewHandler(c).ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+
+		if rec.Code != tt.code {
+			t.Errorf("rec.Code = %d; want = %d", rec.Code, tt.code)
+		}
+
+		var r Report
+		if err := json.NewDecoder(rec.Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Ready != (tt.err == nil) {
+			t.Errorf("r.Ready = %v; want = %v", r.Ready, tt.err == nil)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// Evictor removes the cargos that were claimed long ago from an in-memory
+// cargo repository, so that a long-running repository does not grow without
+// bound.
+type Evictor interface {
+	// Evict removes the cargos claimed longer than the retention period
+	// before now, and returns how many it removed. Cargos that have not been
+	// claimed are never removed.
+	Evict(now time.Time) int
+
+	// Run evicts cargos on every interval, as of the time of the clock,
+	// until the context is done.
+	Run(ctx context.Context, interval time.Duration, clock shipping.Clock) error
+}
+
+type evictor struct {
+	cargos    *cargoRepository
+	retention time.Duration
+	cold      shipping.CargoRepository
+}
+
+// NewCargoEvictor returns an evictor of the cargos of an in-memory cargo
+// repository that have been claimed longer than retention. Evicted cargos
+// are moved to the cold repository, unless it is nil; a cargo that can not
+// be stored there is kept.
+func NewCargoEvictor(cargos shipping.CargoRepository, retention time.Duration, cold shipping.CargoRepository) (Evictor, error) {
+	cr, ok := cargos.(*cargoRepository)
+	if !ok {
+		return nil, fmt.Errorf("not an in-memory cargo repository: %T", cargos)
+	}
+	return &evictor{cargos: cr, retention: retention, cold: cold}, nil
+}
+
+// Evict collects the expired cargos under the lock, but moves them to the
+// cold repository without it, so that a slow cold repository does not hold
+// up the writers of the in-memory one.
+func (e *evictor) Evict(now time.Time) int {
+	r := e.cargos
+
+	var expired []*shipping.Cargo
+
+	r.mtx.RLock()
+	for _, c := range r.cargos.List() {
+		if e.isExpired(c, now) {
+			expired = append(expired, copyCargo(c))
+		}
+	}
+	r.mtx.RUnlock()
+
+	var n int
+	for _, c := range expired {
+		if e.cold != nil {
+			cp := copyCargo(c)
+			cp.Version = 0
+			if err := e.cold.Store(context.Background(), cp); err != nil {
+				continue
+			}
+		}
+		if e.remove(c) {
+			n++
+		}
+	}
+	return n
+}
+
+// remove removes the cargo, unless it has been changed since it was found
+// expired, and notifies the watchers of the removal.
+func (e *evictor) remove(c *shipping.Cargo) bool {
+	r := e.cargos
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	stored, ok := r.cargos.Get(c.TrackingID)
+	if !ok || stored.Version != c.Version {
+		return false
+	}
+	r.cargos.Delete(c.TrackingID)
+	r.notify(shipping.CargoChange{Type: shipping.CargoRemoved, TrackingID: c.TrackingID})
+	return true
+}
+
+func (e *evictor) Run(ctx context.Context, interval time.Duration, clock shipping.Clock) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			e.Evict(clock.Now())
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// isExpired returns whether the cargo was claimed longer than the retention
+// period before now. A cargo is claimed at the completion of its last event.
+func (e *evictor) isExpired(c *shipping.Cargo, now time.Time) bool {
+	if c.Delivery.TransportStatus != shipping.Claimed {
+		return false
+	}
+	return c.Delivery.LastEvent.CompletionTime.Add(e.retention).Before(now)
+}
@@ -0,0 +1,78 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestEvictor_Evict(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	handled := func(id shipping.TrackingID, typ shipping.HandlingEventType, completed time.Time) *shipping.Cargo {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL})
+		c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+			{TrackingID: id, Activity: shipping.HandlingActivity{Type: typ, Location: shipping.AUMEL}, CompletionTime: completed},
+		}})
+		return c
+	}
+
+	var (
+		cargos = NewCargoRepository()
+		cold   = NewCargoRepository()
+	)
+
+	for _, c := range []*shipping.Cargo{
+		shipping.NewCargo("NEW", shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL}),
+		handled("TRANSIT", shipping.Unload, now.AddDate(0, 0, -30)),
+		handled("RECENT", shipping.Claim, now.AddDate(0, 0, -1)),
+		handled("OLD", shipping.Claim, now.AddDate(0, 0, -10)),
+	} {
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes, err := cargos.Watch(watchCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewCargoEvictor(cargos, 7*24*time.Hour, cold)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := e.Evict(now); n != 1 {
+		t.Errorf("Evict() = %d; want = %d", n, 1)
+	}
+	if got, want := <-changes, (shipping.CargoChange{Type: shipping.CargoRemoved, TrackingID: "OLD"}); got != want {
+		t.Errorf("change = %v; want = %v", got, want)
+	}
+	if n := e.Evict(now); n != 0 {
+		t.Errorf("Evict() = %d; want = %d", n, 0)
+	}
+
+	want := []shipping.TrackingID{"NEW", "RECENT", "TRANSIT"}
+	if got := trackingIDs(cargos.FindAllIncludingArchived(ctx)); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllIncludingArchived() = %v; want = %v", got, want)
+	}
+
+	if _, err := cold.Find(ctx, "OLD"); err != nil {
+		t.Errorf("cold.Find(OLD) err = %v; want = %v", err, nil)
+	}
+
+	if _, err := NewCargoEvictor(NewTenantCargoRepository(), time.Hour, nil); err == nil {
+		t.Errorf("NewCargoEvictor() should reject a repository that is not in-memory")
+	}
+}
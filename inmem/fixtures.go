@@ -0,0 +1,122 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"context"
+	"fmt"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// NewCargoRepositoryWithFixtures returns an in-memory cargo repository that
+// holds the given cargos. It returns an error if a cargo has no tracking ID,
+// shares it with another, or is consolidated into a cargo that is not among
+// them.
+func NewCargoRepositoryWithFixtures(cargos ...*shipping.Cargo) (shipping.CargoRepository, error) {
+	c, err := indexCargos(cargos)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newCargoRepository(NewMapStore[shipping.TrackingID, *shipping.Cargo]())
+	for id, val := range c {
+		if val.ParentID != "" {
+			if _, ok := c[val.ParentID]; !ok {
+				return nil, fmt.Errorf("cargo %s is consolidated into unknown cargo %s", id, val.ParentID)
+			}
+		}
+		r.cargos.Put(id, copyCargo(val))
+	}
+	return r, nil
+}
+
+// NewHandlingEventRepositoryWithFixtures returns an in-memory handling event
+// repository that holds the given events, in order. Every event must refer
+// to a cargo in cargos, or an IntegrityError is returned.
+func NewHandlingEventRepositoryWithFixtures(cargos shipping.CargoRepository, events ...shipping.HandlingEvent) (shipping.HandlingEventRepository, error) {
+	var (
+		unknown []shipping.TrackingID
+		seen    = make(map[shipping.TrackingID]bool)
+		keys    = make(map[string]bool)
+	)
+	for _, e := range events {
+		if e.TrackingID == "" {
+			return nil, fmt.Errorf("handling event without tracking id")
+		}
+		if e.IdempotencyKey != "" {
+			if keys[e.IdempotencyKey] {
+				return nil, fmt.Errorf("duplicate handling event %s", e.IdempotencyKey)
+			}
+			keys[e.IdempotencyKey] = true
+		}
+		if seen[e.TrackingID] {
+			continue
+		}
+		seen[e.TrackingID] = true
+		if _, err := cargos.Find(context.Background(), e.TrackingID); err != nil {
+			unknown = append(unknown, e.TrackingID)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, &IntegrityError{UnknownCargos: unknown}
+	}
+
+	r := newHandlingEventRepository(
+		NewMapStore[shipping.TrackingID, []shipping.HandlingEvent](),
+		NewMapStore[string, shipping.HandlingEvent](),
+	)
+	for _, e := range events {
+		if _, err := r.store(e); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// NewLocationRepositoryWithFixtures returns an in-memory location repository
+// that holds the given locations, instead of the sample ones. It returns an
+// error if a location has an invalid UN/LOCODE, or shares it with another.
+func NewLocationRepositoryWithFixtures(locations ...*shipping.Location) (shipping.LocationRepository, error) {
+	r := newLocationRepository(NewMapStore[shipping.UNLocode, *shipping.Location]())
+	for _, l := range locations {
+		if l == nil {
+			return nil, fmt.Errorf("location without un/locode")
+		}
+		if _, err := shipping.NewUNLocode(string(l.UNLocode)); err != nil {
+			return nil, fmt.Errorf("location %q: %v", l.UNLocode, err)
+		}
+		if _, ok := r.locations.Get(l.UNLocode); ok {
+			return nil, fmt.Errorf("duplicate location %s", l.UNLocode)
+		}
+		r.locations.Put(l.UNLocode, l)
+	}
+	return r, nil
+}
+
+// NewVoyageRepositoryWithFixtures returns an in-memory voyage repository that
+// holds the given voyages, instead of the sample ones. It returns an error if
+// a voyage has no voyage number, shares it with another, or moves between
+// locations that are not in locations.
+func NewVoyageRepositoryWithFixtures(locations shipping.LocationRepository, voyages ...*shipping.Voyage) (shipping.VoyageRepository, error) {
+	ctx := context.Background()
+
+	r := newVoyageRepository(NewMapStore[shipping.VoyageNumber, *shipping.Voyage]())
+	for _, v := range voyages {
+		if v == nil || v.VoyageNumber == "" {
+			return nil, fmt.Errorf("voyage without voyage number")
+		}
+		if _, ok := r.voyages.Get(v.VoyageNumber); ok {
+			return nil, fmt.Errorf("duplicate voyage %s", v.VoyageNumber)
+		}
+		for _, m := range v.Schedule.CarrierMovements {
+			for _, l := range []shipping.UNLocode{m.DepartureLocation, m.ArrivalLocation} {
+				if _, err := locations.Find(ctx, l); err != nil {
+					return nil, fmt.Errorf("voyage %s refers to unknown location %s", v.VoyageNumber, l)
+				}
+			}
+		}
+		r.voyages.Put(v.VoyageNumber, v)
+	}
+	return r, nil
+}
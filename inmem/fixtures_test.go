@@ -0,0 +1,93 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestRepositoriesWithFixtures(t *testing.T) {
+	ctx := context.Background()
+
+	locations, err := NewLocationRepositoryWithFixtures(shipping.Stockholm, shipping.Melbourne)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := locations.Find(ctx, shipping.SESTO); err != nil || got != shipping.Stockholm {
+		t.Errorf("Find(SESTO) = %v, %v; want = %v, %v", got, err, shipping.Stockholm, nil)
+	}
+	if _, err := locations.Find(ctx, shipping.CNHKG); err != shipping.ErrUnknownLocation {
+		t.Errorf("Find(CNHKG) err = %v; want = %v", err, shipping.ErrUnknownLocation)
+	}
+
+	v := &shipping.Voyage{VoyageNumber: "V100", Schedule: shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+		{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.AUMEL},
+	}}}
+	voyages, err := NewVoyageRepositoryWithFixtures(locations, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := voyages.Find(ctx, "V100"); err != nil || got != v {
+		t.Errorf("Find(V100) = %v, %v; want = %v, %v", got, err, v, nil)
+	}
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL})
+	cargos, err := NewCargoRepositoryWithFixtures(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cargos.Find(ctx, "ABC123"); err != nil || !reflect.DeepEqual(got, c) {
+		t.Errorf("Find(ABC123) = %v, %v; want = %v, %v", got, err, c, nil)
+	}
+
+	e := shipping.HandlingEvent{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}}
+	events, err := NewHandlingEventRepositoryWithFixtures(cargos, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Sequence = 1
+	if got, want := events.QueryHandlingHistory(ctx, "ABC123").HandlingEvents, []shipping.HandlingEvent{e}; !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryHandlingHistory(ABC123) = %v; want = %v", got, want)
+	}
+}
+
+func TestRepositoriesWithFixtures_Integrity(t *testing.T) {
+	cargos, err := NewCargoRepositoryWithFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewHandlingEventRepositoryWithFixtures(cargos, shipping.HandlingEvent{TrackingID: "XYZ789"})
+	e, ok := err.(*IntegrityError)
+	if !ok {
+		t.Fatalf("err = %v; want = %T", err, e)
+	}
+	if want := []shipping.TrackingID{"XYZ789"}; !reflect.DeepEqual(e.UnknownCargos, want) {
+		t.Errorf("e.UnknownCargos = %v; want = %v", e.UnknownCargos, want)
+	}
+
+	child := shipping.NewCargo("ABC123", shipping.RouteSpecification{})
+	child.ParentID = "DEF456"
+	if _, err := NewCargoRepositoryWithFixtures(child); err == nil {
+		t.Errorf("a cargo consolidated into an unknown cargo should be rejected")
+	}
+
+	if _, err := NewCargoRepositoryWithFixtures(child, child); err == nil {
+		t.Errorf("duplicate cargos should be rejected")
+	}
+
+	locations, err := NewLocationRepositoryWithFixtures(shipping.Stockholm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &shipping.Voyage{VoyageNumber: "V100", Schedule: shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+		{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.AUMEL},
+	}}}
+	if _, err := NewVoyageRepositoryWithFixtures(locations, v); err == nil {
+		t.Errorf("a voyage to an unknown location should be rejected")
+	}
+}
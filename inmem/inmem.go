@@ -1,140 +1,1079 @@
+//go:build go1.18
+
 // Package inmem provides in-memory implementations of all the domain repositories.
+//
+// The repositories check the context before doing any work, and return the
+// context error, or an empty result, if it is done.
+//
+// All repositories are safe for concurrent use. Methods returning several
+// results return a snapshot that is not affected by later writes. Cargos are
+// copied on the way in and out, so callers may modify them freely.
+//
+// The repositories keep their state in a Store, and are adapters of their
+// repository interfaces onto it.
 package inmem
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	shipping "github.com/marcusolsson/goddd"
 )
 
+// Snapshotter is implemented by the in-memory cargo and handling event
+// repositories, whose state can be saved and reloaded as JSON.
+type Snapshotter interface {
+	ExportJSON(w io.Writer) error
+	ImportJSON(r io.Reader) error
+}
+
+// cargoWatchBuffer is the number of changes buffered for each watcher of a
+// cargo repository.
+const cargoWatchBuffer = 64
+
 type cargoRepository struct {
-	mtx    sync.RWMutex
-	cargos map[shipping.TrackingID]*shipping.Cargo
+	mtx      sync.RWMutex
+	cargos   Store[shipping.TrackingID, *shipping.Cargo]
+	watchers map[chan shipping.CargoChange]struct{}
 }
 
-func (r *cargoRepository) Store(c *shipping.Cargo) error {
+func (r *cargoRepository) Store(ctx context.Context, c *shipping.Cargo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
-	r.cargos[c.TrackingID] = c
+	stored, ok := r.cargos.Get(c.TrackingID)
+	if ok && stored.Version != c.Version {
+		return shipping.ErrConcurrentModification
+	}
+	c.Version++
+	r.cargos.Put(c.TrackingID, copyCargo(c))
+
+	typ := shipping.CargoUpdated
+	if !ok {
+		typ = shipping.CargoCreated
+	}
+	r.notify(shipping.CargoChange{Type: typ, TrackingID: c.TrackingID})
 	return nil
 }
 
-func (r *cargoRepository) Find(id shipping.TrackingID) (*shipping.Cargo, error) {
+func (r *cargoRepository) Watch(ctx context.Context) (<-chan shipping.CargoChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan shipping.CargoChange, cargoWatchBuffer)
+
+	r.mtx.Lock()
+	if r.watchers == nil {
+		r.watchers = make(map[chan shipping.CargoChange]struct{})
+	}
+	r.watchers[ch] = struct{}{}
+	r.mtx.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		delete(r.watchers, ch)
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// notify sends the change to every watcher, dropping the oldest change of a
+// watcher whose buffer is full. It must be called with the lock held.
+func (r *cargoRepository) notify(change shipping.CargoChange) {
+	for ch := range r.watchers {
+		for sent := false; !sent; {
+			select {
+			case ch <- change:
+				sent = true
+			default:
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (r *cargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if val, ok := r.cargos.Get(id); ok {
+		return copyCargo(val), nil
+	}
+	return nil, shipping.ErrUnknownCargo
+}
+
+func (r *cargoRepository) FindAll(ctx context.Context) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return []*shipping.Cargo{}
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	c := []*shipping.Cargo{}
+	for _, val := range r.cargos.List() {
+		if !val.Archived {
+			c = append(c, copyCargo(val))
+		}
+	}
+	return c
+}
+
+func (r *cargoRepository) FindAllIncludingArchived(ctx context.Context) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return []*shipping.Cargo{}
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	vals := r.cargos.List()
+	c := make([]*shipping.Cargo, 0, len(vals))
+	for _, val := range vals {
+		c = append(c, copyCargo(val))
+	}
+	return c
+}
+
+func (r *cargoRepository) FindChildren(ctx context.Context, parent shipping.TrackingID) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if val.ParentID == parent {
+			c = append(c, copyCargo(val))
+		}
+	}
+	return c
+}
+
+func (r *cargoRepository) FindByDestination(ctx context.Context, dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if val.Archived || val.RouteSpecification.Destination != dest {
+			continue
+		}
+		if activeOnly && !val.IsActive() {
+			continue
+		}
+		c = append(c, copyCargo(val))
+	}
+	sortByArrivalDeadline(c)
+	return c
+}
+
+func (r *cargoRepository) FindByExternalRef(ctx context.Context, ref string) (*shipping.Cargo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
-	if val, ok := r.cargos[id]; ok {
-		return val, nil
+	for _, val := range r.cargos.List() {
+		if ref != "" && val.ExternalRef == ref {
+			return copyCargo(val), nil
+		}
 	}
 	return nil, shipping.ErrUnknownCargo
 }
 
-func (r *cargoRepository) FindAll() []*shipping.Cargo {
+func (r *cargoRepository) FindByServiceLevel(ctx context.Context, level shipping.ServiceLevel) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
-	c := make([]*shipping.Cargo, 0, len(r.cargos))
-	for _, val := range r.cargos {
-		c = append(c, val)
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if !val.Archived && val.ServiceLevel == level {
+			c = append(c, copyCargo(val))
+		}
 	}
+	sortByArrivalDeadline(c)
 	return c
 }
 
+// sortByArrivalDeadline sorts the cargos by arrival deadline, and cargos with
+// the same deadline by tracking ID.
+func sortByArrivalDeadline(c []*shipping.Cargo) {
+	sort.Slice(c, func(i, j int) bool {
+		di, dj := c[i].RouteSpecification.ArrivalDeadline, c[j].RouteSpecification.ArrivalDeadline
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return c[i].TrackingID < c[j].TrackingID
+	})
+}
+
+func (r *cargoRepository) Archive(ctx context.Context, id shipping.TrackingID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	val, ok := r.cargos.Get(id)
+	if !ok {
+		return shipping.ErrUnknownCargo
+	}
+	cp := copyCargo(val)
+	if err := cp.Archive(); err != nil {
+		return err
+	}
+	cp.Version++
+	r.cargos.Put(id, cp)
+	r.notify(shipping.CargoChange{Type: shipping.CargoUpdated, TrackingID: id})
+	return nil
+}
+
+func (r *cargoRepository) FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if !val.Archived && !val.Cancelled && val.IsAwaitingClaimBeyond(d, now) {
+			c = append(c, copyCargo(val))
+		}
+	}
+	return c
+}
+
+func (r *cargoRepository) FindOverdue(ctx context.Context, now time.Time) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if val.IsOverdue(now) {
+			c = append(c, copyCargo(val))
+		}
+	}
+	return c
+}
+
+func (r *cargoRepository) FindByRoutingStatus(ctx context.Context, status shipping.RoutingStatus) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if val.Delivery.RoutingStatus == status {
+			c = append(c, copyCargo(val))
+		}
+	}
+	return c
+}
+
+func (r *cargoRepository) FindByTag(ctx context.Context, key, value string) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if v, ok := val.Tags[key]; !val.Archived && ok && v == value {
+			c = append(c, copyCargo(val))
+		}
+	}
+	return c
+}
+
+func (r *cargoRepository) FindByVoyage(ctx context.Context, number shipping.VoyageNumber) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if !val.Archived && !val.Cancelled && val.Itinerary.IsOnVoyage(number) {
+			c = append(c, copyCargo(val))
+		}
+	}
+	return c
+}
+
+func (r *cargoRepository) FindAtLocation(ctx context.Context, loc shipping.UNLocode) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var c []*shipping.Cargo
+	for _, val := range r.cargos.List() {
+		if val.IsAt(loc) {
+			c = append(c, copyCargo(val))
+		}
+	}
+	return c
+}
+
+func (r *cargoRepository) FindAllPaged(ctx context.Context, offset, limit int) ([]*shipping.Cargo, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	if offset < 0 || limit < 0 {
+		return nil, 0, shipping.ErrInvalidArgument
+	}
+
+	c := r.FindAll(ctx)
+	sort.Slice(c, func(i, j int) bool {
+		return c[i].TrackingID < c[j].TrackingID
+	})
+
+	total := len(c)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return c[offset:end], total, nil
+}
+
+// copyCargo returns a deep copy of c that shares no memory with it.
+func copyCargo(c *shipping.Cargo) *shipping.Cargo {
+	cp := *c
+	cp.Itinerary = copyItinerary(c.Itinerary)
+	cp.Delivery.Itinerary = copyItinerary(c.Delivery.Itinerary)
+	if c.AlternateRouteSpecifications != nil {
+		cp.AlternateRouteSpecifications = append([]shipping.RouteSpecification(nil), c.AlternateRouteSpecifications...)
+	}
+	if c.StatusChanges != nil {
+		cp.StatusChanges = c.History()
+	}
+	if c.Tags != nil {
+		cp.Tags = make(map[string]string, len(c.Tags))
+		for k, v := range c.Tags {
+			cp.Tags[k] = v
+		}
+	}
+	if c.OwnershipLog != nil {
+		cp.OwnershipLog = append([]shipping.OwnershipChange(nil), c.OwnershipLog...)
+	}
+	return &cp
+}
+
+func copyItinerary(i shipping.Itinerary) shipping.Itinerary {
+	if i.Legs == nil {
+		return i
+	}
+	legs := make([]shipping.Leg, len(i.Legs))
+	copy(legs, i.Legs)
+	return shipping.Itinerary{Legs: legs, Partial: i.Partial}
+}
+
+// ExportJSON writes all stored cargos to w as a JSON array, ordered by
+// tracking ID.
+func (r *cargoRepository) ExportJSON(w io.Writer) error {
+	c := r.FindAllIncludingArchived(context.Background())
+	sort.Slice(c, func(i, j int) bool {
+		return c[i].TrackingID < c[j].TrackingID
+	})
+	return json.NewEncoder(w).Encode(c)
+}
+
+// ImportJSON replaces all stored cargos with the ones read from rd, as
+// written by ExportJSON. The stored cargos are left untouched on error.
+func (r *cargoRepository) ImportJSON(rd io.Reader) error {
+	cargos, err := decodeCargos(rd)
+	if err != nil {
+		return err
+	}
+	r.replace(cargos)
+	return nil
+}
+
+func (r *cargoRepository) replace(cargos map[shipping.TrackingID]*shipping.Cargo) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, val := range r.cargos.List() {
+		r.cargos.Delete(val.TrackingID)
+	}
+	for id, val := range cargos {
+		r.cargos.Put(id, val)
+	}
+}
+
+func decodeCargos(rd io.Reader) (map[shipping.TrackingID]*shipping.Cargo, error) {
+	var c []*shipping.Cargo
+	if err := json.NewDecoder(rd).Decode(&c); err != nil {
+		return nil, err
+	}
+	return indexCargos(c)
+}
+
+// indexCargos returns the cargos by tracking ID, or an error if any of them
+// has no tracking ID or shares it with another.
+func indexCargos(c []*shipping.Cargo) (map[shipping.TrackingID]*shipping.Cargo, error) {
+	cargos := make(map[shipping.TrackingID]*shipping.Cargo, len(c))
+	for _, val := range c {
+		if val == nil || val.TrackingID == "" {
+			return nil, fmt.Errorf("cargo without tracking id")
+		}
+		if _, ok := cargos[val.TrackingID]; ok {
+			return nil, fmt.Errorf("duplicate cargo %s", val.TrackingID)
+		}
+		cargos[val.TrackingID] = val
+	}
+	return cargos, nil
+}
+
 // NewCargoRepository returns a new instance of a in-memory cargo repository.
 func NewCargoRepository() shipping.CargoRepository {
-	return &cargoRepository{
-		cargos: make(map[shipping.TrackingID]*shipping.Cargo),
-	}
+	return newCargoRepository(NewMapStore[shipping.TrackingID, *shipping.Cargo]())
+}
+
+func newCargoRepository(s Store[shipping.TrackingID, *shipping.Cargo]) *cargoRepository {
+	return &cargoRepository{cargos: s}
 }
 
 type locationRepository struct {
-	locations map[shipping.UNLocode]*shipping.Location
+	mtx       sync.RWMutex
+	locations Store[shipping.UNLocode, *shipping.Location]
 }
 
-func (r *locationRepository) Find(locode shipping.UNLocode) (*shipping.Location, error) {
-	if l, ok := r.locations[locode]; ok {
+func (r *locationRepository) Find(ctx context.Context, locode shipping.UNLocode) (*shipping.Location, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	locode, err := shipping.NewUNLocode(string(locode))
+	if err != nil {
+		return nil, shipping.ErrUnknownLocation
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if l, ok := r.locations.Get(locode); ok {
 		return l, nil
 	}
 	return nil, shipping.ErrUnknownLocation
 }
 
-func (r *locationRepository) FindAll() []*shipping.Location {
-	l := make([]*shipping.Location, 0, len(r.locations))
-	for _, val := range r.locations {
-		l = append(l, val)
+func (r *locationRepository) FindAll(ctx context.Context) []*shipping.Location {
+	if ctx.Err() != nil {
+		return []*shipping.Location{}
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.locations.List()
+}
+
+func (r *locationRepository) FindByNamePrefix(ctx context.Context, prefix string) []*shipping.Location {
+	l := []*shipping.Location{}
+	if prefix == "" || ctx.Err() != nil {
+		return l
+	}
+	prefix = strings.ToLower(prefix)
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	for _, val := range r.locations.List() {
+		if strings.HasPrefix(strings.ToLower(val.Name), prefix) {
+			l = append(l, val)
+		}
 	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
 	return l
 }
 
 // NewLocationRepository returns a new instance of a in-memory location repository.
 func NewLocationRepository() shipping.LocationRepository {
-	r := &locationRepository{
-		locations: make(map[shipping.UNLocode]*shipping.Location),
-	}
+	r := newLocationRepository(NewMapStore[shipping.UNLocode, *shipping.Location]())
 
-	r.locations[shipping.SESTO] = shipping.Stockholm
-	r.locations[shipping.AUMEL] = shipping.Melbourne
-	r.locations[shipping.CNHKG] = shipping.Hongkong
-	r.locations[shipping.JNTKO] = shipping.Tokyo
-	r.locations[shipping.NLRTM] = shipping.Rotterdam
-	r.locations[shipping.DEHAM] = shipping.Hamburg
+	r.locations.Put(shipping.SESTO, shipping.Stockholm)
+	r.locations.Put(shipping.AUMEL, shipping.Melbourne)
+	r.locations.Put(shipping.CNHKG, shipping.Hongkong)
+	r.locations.Put(shipping.JNTKO, shipping.Tokyo)
+	r.locations.Put(shipping.NLRTM, shipping.Rotterdam)
+	r.locations.Put(shipping.DEHAM, shipping.Hamburg)
 
 	return r
 }
 
+func newLocationRepository(s Store[shipping.UNLocode, *shipping.Location]) *locationRepository {
+	return &locationRepository{locations: s}
+}
+
 type voyageRepository struct {
-	voyages map[shipping.VoyageNumber]*shipping.Voyage
+	mtx     sync.RWMutex
+	voyages Store[shipping.VoyageNumber, *shipping.Voyage]
+}
+
+func (r *voyageRepository) Store(ctx context.Context, v *shipping.Voyage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if stored, ok := r.voyages.Get(v.VoyageNumber); ok {
+		if !stored.Schedule.Equal(v.Schedule) {
+			return shipping.ErrVoyageConflict
+		}
+		return nil
+	}
+	r.voyages.Put(v.VoyageNumber, v)
+	return nil
 }
 
-func (r *voyageRepository) Find(voyageNumber shipping.VoyageNumber) (*shipping.Voyage, error) {
-	if v, ok := r.voyages[voyageNumber]; ok {
+func (r *voyageRepository) Update(ctx context.Context, v *shipping.Voyage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.voyages.Get(v.VoyageNumber); !ok {
+		return shipping.ErrUnknownVoyage
+	}
+	r.voyages.Put(v.VoyageNumber, v)
+	return nil
+}
+
+func (r *voyageRepository) Find(ctx context.Context, voyageNumber shipping.VoyageNumber) (*shipping.Voyage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if v, ok := r.voyages.Get(voyageNumber); ok {
 		return v, nil
 	}
 
 	return nil, shipping.ErrUnknownVoyage
 }
 
-// NewVoyageRepository returns a new instance of a in-memory voyage repository.
-func NewVoyageRepository() shipping.VoyageRepository {
-	r := &voyageRepository{
-		voyages: make(map[shipping.VoyageNumber]*shipping.Voyage),
+func (r *voyageRepository) FindByRoute(ctx context.Context, from, to shipping.UNLocode) []*shipping.Voyage {
+	if ctx.Err() != nil {
+		return []*shipping.Voyage{}
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	type match struct {
+		voyage    *shipping.Voyage
+		departure time.Time
 	}
 
-	r.voyages[shipping.V100.VoyageNumber] = shipping.V100
-	r.voyages[shipping.V300.VoyageNumber] = shipping.V300
-	r.voyages[shipping.V400.VoyageNumber] = shipping.V400
+	var matches []match
+	for _, v := range r.voyages.List() {
+		if m, ok := v.Schedule.FindMovement(from, to); ok {
+			matches = append(matches, match{v, m.DepartureTime})
+		}
+	}
 
-	r.voyages[shipping.V0100S.VoyageNumber] = shipping.V0100S
-	r.voyages[shipping.V0200T.VoyageNumber] = shipping.V0200T
-	r.voyages[shipping.V0300A.VoyageNumber] = shipping.V0300A
-	r.voyages[shipping.V0301S.VoyageNumber] = shipping.V0301S
-	r.voyages[shipping.V0400S.VoyageNumber] = shipping.V0400S
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].departure.Before(matches[j].departure)
+	})
+
+	result := make([]*shipping.Voyage, len(matches))
+	for i, m := range matches {
+		result[i] = m.voyage
+	}
+	return result
+}
+
+func (r *voyageRepository) FindDepartingBetween(ctx context.Context, from shipping.UNLocode, start, end time.Time) []*shipping.Voyage {
+	if ctx.Err() != nil {
+		return []*shipping.Voyage{}
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	type match struct {
+		voyage    *shipping.Voyage
+		departure time.Time
+	}
+
+	var matches []match
+	for _, v := range r.voyages.List() {
+		if m, ok := v.Schedule.NextDepartureFrom(from, start); ok && m.DepartureTime.Before(end) {
+			matches = append(matches, match{v, m.DepartureTime})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].departure.Before(matches[j].departure)
+	})
+
+	result := make([]*shipping.Voyage, len(matches))
+	for i, m := range matches {
+		result[i] = m.voyage
+	}
+	return result
+}
+
+// NewVoyageRepository returns a new instance of a in-memory voyage repository.
+func NewVoyageRepository() shipping.VoyageRepository {
+	r := newVoyageRepository(NewMapStore[shipping.VoyageNumber, *shipping.Voyage]())
+
+	for _, v := range []*shipping.Voyage{
+		shipping.V100, shipping.V300, shipping.V400,
+		shipping.V0100S, shipping.V0200T, shipping.V0300A, shipping.V0301S, shipping.V0400S,
+	} {
+		r.voyages.Put(v.VoyageNumber, v)
+	}
 
 	return r
 }
 
+func newVoyageRepository(s Store[shipping.VoyageNumber, *shipping.Voyage]) *voyageRepository {
+	return &voyageRepository{voyages: s}
+}
+
 type handlingEventRepository struct {
 	mtx    sync.RWMutex
-	events map[shipping.TrackingID][]shipping.HandlingEvent
+	events Store[shipping.TrackingID, []shipping.HandlingEvent]
+	keys   Store[string, shipping.HandlingEvent]
+
+	// outbox holds the entries not yet marked done, oldest first.
+	outbox    []shipping.OutboxEntry
+	outboxSeq int64
+
+	// seq is the sequence number of the last stored event.
+	seq uint64
 }
 
-func (r *handlingEventRepository) Store(e shipping.HandlingEvent) {
+func (r *handlingEventRepository) Store(ctx context.Context, e shipping.HandlingEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
-	// Make array if it's the first event with this tracking ID.
-	if _, ok := r.events[e.TrackingID]; !ok {
-		r.events[e.TrackingID] = make([]shipping.HandlingEvent, 0)
+	_, err := r.store(e)
+	return err
+}
+
+// StoreWithOutbox stores the event and its outbox entry under the same lock,
+// which makes them a unit.
+func (r *handlingEventRepository) StoreWithOutbox(ctx context.Context, e shipping.HandlingEvent, correlation shipping.CorrelationID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	e, err := r.store(e)
+	if err != nil {
+		return err
+	}
+	r.outboxSeq++
+	r.outbox = append(r.outbox, shipping.OutboxEntry{ID: r.outboxSeq, Event: e, Correlation: correlation})
+	return nil
+}
+
+func (r *handlingEventRepository) PendingOutbox(ctx context.Context) ([]shipping.OutboxEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return append([]shipping.OutboxEntry(nil), r.outbox...), nil
+}
+
+func (r *handlingEventRepository) MarkOutboxDone(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for i, entry := range r.outbox {
+		if entry.ID == id {
+			r.outbox = append(r.outbox[:i], r.outbox[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// store stores the event and returns it with its assigned sequence number.
+// The caller must hold the write lock.
+func (r *handlingEventRepository) store(e shipping.HandlingEvent) (shipping.HandlingEvent, error) {
+	if e.IdempotencyKey != "" {
+		if _, ok := r.keys.Get(e.IdempotencyKey); ok {
+			return shipping.HandlingEvent{}, shipping.ErrDuplicateIdempotencyKey
+		}
+	}
+	history, _ := r.events.Get(e.TrackingID)
+	for _, prev := range history {
+		if prev.Activity == e.Activity && prev.CompletionTime.Equal(e.CompletionTime) {
+			return shipping.HandlingEvent{}, shipping.ErrDuplicateEvent
+		}
+	}
+	e.Sequence = atomic.AddUint64(&r.seq, 1)
+	r.events.Put(e.TrackingID, append(history, e))
+	if e.IdempotencyKey != "" {
+		r.keys.Put(e.IdempotencyKey, e)
+	}
+	return e, nil
+}
+
+func (r *handlingEventRepository) QueryHandlingHistory(ctx context.Context, id shipping.TrackingID) shipping.HandlingHistory {
+	if ctx.Err() != nil {
+		return shipping.HandlingHistory{}
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	history, _ := r.events.Get(id)
+	events := append([]shipping.HandlingEvent(nil), history...)
+	return shipping.HandlingHistory{HandlingEvents: events}
+}
+
+func (r *handlingEventRepository) QueryHandlingHistoryBetween(ctx context.Context, id shipping.TrackingID, from, to time.Time) shipping.HandlingHistory {
+	if ctx.Err() != nil {
+		return shipping.HandlingHistory{}
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	history, _ := r.events.Get(id)
+	var events []shipping.HandlingEvent
+	for _, e := range history {
+		if e.CompletionTime.Before(from) || e.CompletionTime.After(to) {
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return shipping.CompletedBefore(events[i], events[j])
+	})
+	return shipping.HandlingHistory{HandlingEvents: events}
+}
+
+func (r *handlingEventRepository) Stats(ctx context.Context, from, to time.Time) (shipping.HandlingStats, error) {
+	if err := ctx.Err(); err != nil {
+		return shipping.HandlingStats{}, err
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var events []shipping.HandlingEvent
+	for _, history := range r.events.List() {
+		for _, e := range history {
+			if e.CompletionTime.Before(from) || e.CompletionTime.After(to) {
+				continue
+			}
+			events = append(events, e)
+		}
+	}
+	return shipping.CountHandlingEvents(events), nil
+}
+
+func (r *handlingEventRepository) QueryHandlingEventsSince(ctx context.Context, since time.Time) []shipping.HandlingEvent {
+	if ctx.Err() != nil {
+		return nil
 	}
-	r.events[e.TrackingID] = append(r.events[e.TrackingID], e)
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var events []shipping.HandlingEvent
+	for _, history := range r.events.List() {
+		for _, e := range history {
+			if e.CompletionTime.After(since) {
+				events = append(events, e)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return shipping.CompletedBefore(events[i], events[j])
+	})
+	return events
 }
 
-func (r *handlingEventRepository) QueryHandlingHistory(id shipping.TrackingID) shipping.HandlingHistory {
+func (r *handlingEventRepository) QuerySince(ctx context.Context, seq uint64) ([]shipping.HandlingEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
-	return shipping.HandlingHistory{HandlingEvents: r.events[id]}
+	var events []shipping.HandlingEvent
+	for _, history := range r.events.List() {
+		for _, e := range history {
+			if e.Sequence > seq {
+				events = append(events, e)
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+	return events, nil
+}
+
+func (r *handlingEventRepository) Search(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	if criteria.Offset < 0 || criteria.Limit < 0 {
+		return nil, 0, shipping.ErrInvalidArgument
+	}
+
+	r.mtx.RLock()
+	var events []shipping.HandlingEvent
+	for _, history := range r.events.List() {
+		for _, e := range history {
+			if criteria.Matches(e) {
+				events = append(events, e)
+			}
+		}
+	}
+	r.mtx.RUnlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CompletionTime.After(events[j].CompletionTime)
+	})
+
+	total := len(events)
+	offset := criteria.Offset
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if criteria.Limit > 0 && offset+criteria.Limit < total {
+		end = offset + criteria.Limit
+	}
+
+	return events[offset:end], total, nil
+}
+
+func (r *handlingEventRepository) FindByIdempotencyKey(ctx context.Context, key string) (shipping.HandlingEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return shipping.HandlingEvent{}, err
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if e, ok := r.keys.Get(key); ok {
+		return e, nil
+	}
+	return shipping.HandlingEvent{}, shipping.ErrUnknownHandlingEvent
+}
+
+func (r *handlingEventRepository) Supersede(ctx context.Context, key shipping.HandlingEventKey) error {
+	return r.update(ctx, key, func(e *shipping.HandlingEvent) {
+		e.Superseded = true
+	})
+}
+
+func (r *handlingEventRepository) Confirm(ctx context.Context, key shipping.HandlingEventKey) error {
+	return r.update(ctx, key, func(e *shipping.HandlingEvent) {
+		e.Planned = false
+	})
+}
+
+// ConfirmWithOutbox confirms the event and stores its outbox entry under the
+// same lock, like StoreWithOutbox.
+func (r *handlingEventRepository) ConfirmWithOutbox(ctx context.Context, key shipping.HandlingEventKey, correlation shipping.CorrelationID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	e, err := r.updateLocked(key, func(e *shipping.HandlingEvent) {
+		e.Planned = false
+	})
+	if err != nil {
+		return err
+	}
+	r.outboxSeq++
+	r.outbox = append(r.outbox, shipping.OutboxEntry{ID: r.outboxSeq, Event: e, Correlation: correlation})
+	return nil
+}
+
+// update applies fn to the stored event identified by the key.
+func (r *handlingEventRepository) update(ctx context.Context, key shipping.HandlingEventKey, fn func(*shipping.HandlingEvent)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	_, err := r.updateLocked(key, fn)
+	return err
+}
+
+// updateLocked applies fn to the stored event identified by the key, and
+// returns the updated event with a new sequence number. The caller must hold
+// the write lock.
+func (r *handlingEventRepository) updateLocked(key shipping.HandlingEventKey, fn func(*shipping.HandlingEvent)) (shipping.HandlingEvent, error) {
+	history, _ := r.events.Get(key.TrackingID)
+	for i, e := range history {
+		if !key.Matches(e) {
+			continue
+		}
+		fn(&e)
+		e.Sequence = atomic.AddUint64(&r.seq, 1)
+		updated := append([]shipping.HandlingEvent(nil), history...)
+		updated[i] = e
+		r.events.Put(key.TrackingID, updated)
+		if e.IdempotencyKey != "" {
+			r.keys.Put(e.IdempotencyKey, e)
+		}
+		return e, nil
+	}
+	return shipping.HandlingEvent{}, shipping.ErrUnknownHandlingEvent
+}
+
+// ExportJSON writes all stored handling events to w as a JSON array, ordered
+// by tracking ID and then in the order they were stored.
+func (r *handlingEventRepository) ExportJSON(w io.Writer) error {
+	r.mtx.RLock()
+	histories := r.events.List()
+	r.mtx.RUnlock()
+
+	sort.Slice(histories, func(i, j int) bool {
+		return histories[i][0].TrackingID < histories[j][0].TrackingID
+	})
+	events := []shipping.HandlingEvent{}
+	for _, history := range histories {
+		events = append(events, history...)
+	}
+
+	return json.NewEncoder(w).Encode(events)
+}
+
+// ImportJSON replaces all stored handling events with the ones read from rd,
+// as written by ExportJSON. The stored events are left untouched on error.
+// Use Restore to also check that the events refer to known cargos.
+func (r *handlingEventRepository) ImportJSON(rd io.Reader) error {
+	events, err := decodeHandlingEvents(rd)
+	if err != nil {
+		return err
+	}
+	r.replace(events)
+	return nil
+}
+
+func (r *handlingEventRepository) replace(events []shipping.HandlingEvent) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, history := range r.events.List() {
+		r.events.Delete(history[0].TrackingID)
+	}
+	for _, e := range r.keys.List() {
+		r.keys.Delete(e.IdempotencyKey)
+	}
+
+	// Events keep the sequence numbers they were exported with. Those
+	// without one, from older snapshots, are numbered after the rest.
+	var seq uint64
+	for _, e := range events {
+		if e.Sequence > seq {
+			seq = e.Sequence
+		}
+	}
+	for _, e := range events {
+		if e.Sequence == 0 {
+			seq++
+			e.Sequence = seq
+		}
+		history, _ := r.events.Get(e.TrackingID)
+		r.events.Put(e.TrackingID, append(history, e))
+		if e.IdempotencyKey != "" {
+			r.keys.Put(e.IdempotencyKey, e)
+		}
+	}
+	atomic.StoreUint64(&r.seq, seq)
+}
+
+func decodeHandlingEvents(rd io.Reader) ([]shipping.HandlingEvent, error) {
+	var events []shipping.HandlingEvent
+	if err := json.NewDecoder(rd).Decode(&events); err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if e.TrackingID == "" {
+			return nil, fmt.Errorf("handling event without tracking id")
+		}
+	}
+	return events, nil
+}
+
+// IntegrityError is returned by Restore and
+// NewHandlingEventRepositoryWithFixtures when handling events refer to cargos
+// that are not part of the snapshot, or the fixtures.
+type IntegrityError struct {
+	UnknownCargos []shipping.TrackingID
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("handling events refer to unknown cargos: %v", e.UnknownCargos)
+}
+
+// Restore replaces the state of the given in-memory repositories with the
+// snapshots read from cargoSnapshot and eventSnapshot, as written by their ExportJSON
+// methods. Nothing is replaced unless both snapshots can be read and every
+// handling event refers to a cargo in the cargo snapshot.
+func Restore(cargos shipping.CargoRepository, events shipping.HandlingEventRepository, cargoSnapshot, eventSnapshot io.Reader) error {
+	cr, ok := cargos.(*cargoRepository)
+	if !ok {
+		return fmt.Errorf("not an in-memory cargo repository: %T", cargos)
+	}
+	er, ok := events.(*handlingEventRepository)
+	if !ok {
+		return fmt.Errorf("not an in-memory handling event repository: %T", events)
+	}
+
+	c, err := decodeCargos(cargoSnapshot)
+	if err != nil {
+		return err
+	}
+	e, err := decodeHandlingEvents(eventSnapshot)
+	if err != nil {
+		return err
+	}
+
+	var (
+		unknown []shipping.TrackingID
+		seen    = make(map[shipping.TrackingID]bool)
+	)
+	for _, val := range e {
+		if _, ok := c[val.TrackingID]; !ok && !seen[val.TrackingID] {
+			unknown = append(unknown, val.TrackingID)
+			seen[val.TrackingID] = true
+		}
+	}
+	if len(unknown) > 0 {
+		return &IntegrityError{UnknownCargos: unknown}
+	}
+
+	cr.replace(c)
+	er.replace(e)
+
+	return nil
 }
 
 // NewHandlingEventRepository returns a new instance of a in-memory handling event repository.
 func NewHandlingEventRepository() shipping.HandlingEventRepository {
-	return &handlingEventRepository{
-		events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
-	}
+	return newHandlingEventRepository(
+		NewMapStore[shipping.TrackingID, []shipping.HandlingEvent](),
+		NewMapStore[string, shipping.HandlingEvent](),
+	)
+}
+
+func newHandlingEventRepository(events Store[shipping.TrackingID, []shipping.HandlingEvent], keys Store[string, shipping.HandlingEvent]) *handlingEventRepository {
+	return &handlingEventRepository{events: events, keys: keys}
 }
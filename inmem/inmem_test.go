@@ -0,0 +1,1353 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestCargoRepository_FindOverdue(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2009, time.March, 13, 0, 0, 0, 0, time.UTC)
+
+	r := NewCargoRepository()
+
+	onTime := shipping.NewCargo("ONTIME", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: deadline,
+	})
+	onTime.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		shipping.NewLeg("V100", shipping.SESTO, shipping.AUMEL, deadline.AddDate(0, 0, -5), deadline),
+	}})
+
+	late := shipping.NewCargo("LATE", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: deadline,
+	})
+	late.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		shipping.NewLeg("V100", shipping.SESTO, shipping.AUMEL, deadline.AddDate(0, 0, -5), deadline.Add(time.Hour)),
+	}})
+
+	for _, c := range []*shipping.Cargo{onTime, late} {
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	overdue := r.FindOverdue(ctx, deadline.AddDate(0, 0, -1))
+
+	if len(overdue) != 1 {
+		t.Fatalf("len(overdue) = %d; want = %d", len(overdue), 1)
+	}
+	if overdue[0].TrackingID != late.TrackingID {
+		t.Errorf("overdue[0].TrackingID = %s; want = %s", overdue[0].TrackingID, late.TrackingID)
+	}
+}
+
+func TestCargoRepository_FindAllPaged(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	for _, id := range []shipping.TrackingID{"DEF", "ABC", "GHI", "JKL", "BCD"} {
+		if err := r.Store(ctx, shipping.NewCargo(id, shipping.RouteSpecification{})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		offset, limit int
+		want          []shipping.TrackingID
+	}{
+		{0, 2, []shipping.TrackingID{"ABC", "BCD"}},
+		{2, 2, []shipping.TrackingID{"DEF", "GHI"}},
+		{4, 2, []shipping.TrackingID{"JKL"}},
+		{5, 2, []shipping.TrackingID{}},
+		{0, 0, []shipping.TrackingID{}},
+	}
+
+	for _, tt := range tests {
+		page, total, err := r.FindAllPaged(ctx, tt.offset, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 5 {
+			t.Errorf("total = %d; want = %d", total, 5)
+		}
+
+		got := make([]shipping.TrackingID, 0, len(page))
+		for _, c := range page {
+			got = append(got, c.TrackingID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindAllPaged(%d, %d) = %v; want = %v", tt.offset, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestCargoRepository_FindAllPaged_InvalidArgument(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	for _, args := range [][2]int{{-1, 10}, {0, -1}} {
+		if _, _, err := r.FindAllPaged(ctx, args[0], args[1]); err != shipping.ErrInvalidArgument {
+			t.Errorf("FindAllPaged(%d, %d) err = %v; want = %v", args[0], args[1], err, shipping.ErrInvalidArgument)
+		}
+	}
+}
+
+func TestCargoRepository_FindByRoutingStatus(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	if err := r.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.FindByRoutingStatus(ctx, shipping.NotRouted); len(got) != 1 {
+		t.Errorf("len(FindByRoutingStatus(NotRouted)) = %d; want = %d", len(got), 1)
+	}
+
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.AUMEL},
+	}})
+	if err := r.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.FindByRoutingStatus(ctx, shipping.NotRouted); len(got) != 0 {
+		t.Errorf("len(FindByRoutingStatus(NotRouted)) = %d; want = %d", len(got), 0)
+	}
+	if got := r.FindByRoutingStatus(ctx, shipping.Routed); len(got) != 1 {
+		t.Errorf("len(FindByRoutingStatus(Routed)) = %d; want = %d", len(got), 1)
+	}
+}
+
+func TestCargoRepository_FindByServiceLevel(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	for _, c := range []*shipping.Cargo{
+		{TrackingID: "ABC", RouteSpecification: shipping.RouteSpecification{ArrivalDeadline: deadline}},
+		{TrackingID: "DEF", ServiceLevel: shipping.Critical, RouteSpecification: shipping.RouteSpecification{ArrivalDeadline: deadline.AddDate(0, 0, 1)}},
+		{TrackingID: "GHI", ServiceLevel: shipping.Critical, RouteSpecification: shipping.RouteSpecification{ArrivalDeadline: deadline}},
+		{TrackingID: "JKL", ServiceLevel: shipping.Critical, Archived: true},
+	} {
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		level shipping.ServiceLevel
+		want  []shipping.TrackingID
+	}{
+		{shipping.Standard, []shipping.TrackingID{"ABC"}},
+		{shipping.Express, nil},
+		{shipping.Critical, []shipping.TrackingID{"GHI", "DEF"}},
+	}
+
+	for _, tt := range tests {
+		var got []shipping.TrackingID
+		for _, c := range r.FindByServiceLevel(ctx, tt.level) {
+			got = append(got, c.TrackingID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindByServiceLevel(%s) = %v; want = %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestCargoRepository_FindByDestination(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	deadline := time.Date(2009, time.March, 13, 0, 0, 0, 0, time.UTC)
+
+	cargos := []struct {
+		id          shipping.TrackingID
+		destination shipping.UNLocode
+		deadline    time.Time
+		cancelled   bool
+	}{
+		{"LATE", shipping.USNYC, deadline.AddDate(0, 0, 2), false},
+		{"EARLY", shipping.USNYC, deadline, false},
+		{"CANCELLED", shipping.USNYC, deadline.AddDate(0, 0, 1), true},
+		{"ELSEWHERE", shipping.AUMEL, deadline, false},
+	}
+	for _, tt := range cargos {
+		c := shipping.NewCargo(tt.id, shipping.RouteSpecification{
+			Origin:          shipping.SESTO,
+			Destination:     tt.destination,
+			ArrivalDeadline: tt.deadline,
+		})
+		c.Cancelled = tt.cancelled
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		destination shipping.UNLocode
+		activeOnly  bool
+		want        []shipping.TrackingID
+	}{
+		{shipping.USNYC, false, []shipping.TrackingID{"EARLY", "CANCELLED", "LATE"}},
+		{shipping.USNYC, true, []shipping.TrackingID{"EARLY", "LATE"}},
+		{shipping.AUMEL, true, []shipping.TrackingID{"ELSEWHERE"}},
+		{shipping.CNHKG, false, nil},
+	}
+
+	for _, tt := range tests {
+		var got []shipping.TrackingID
+		for _, c := range r.FindByDestination(ctx, tt.destination, tt.activeOnly) {
+			got = append(got, c.TrackingID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindByDestination(%s, %v) = %v; want = %v", tt.destination, tt.activeOnly, got, tt.want)
+		}
+	}
+}
+
+func TestCargoRepository_FindByVoyage(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	newCargo := func(id shipping.TrackingID, voyages ...shipping.VoyageNumber) *shipping.Cargo {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG})
+		var legs []shipping.Leg
+		for _, v := range voyages {
+			legs = append(legs, shipping.Leg{VoyageNumber: v})
+		}
+		c.AssignToRoute(shipping.Itinerary{Legs: legs})
+		return c
+	}
+
+	cancelled := newCargo("CAN", "V100")
+	cancelled.Cancelled = true
+
+	for _, c := range []*shipping.Cargo{
+		newCargo("ABC", "V100", "V400"),
+		newCargo("DEF", "V300", "V100"),
+		newCargo("GHI", "V300"),
+		newCargo("JKL"),
+		cancelled,
+	} {
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := trackingIDs(r.FindByVoyage(ctx, "V100")), []shipping.TrackingID{"ABC", "DEF"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindByVoyage(V100) = %v; want = %v", got, want)
+	}
+	if got := r.FindByVoyage(ctx, "V500"); len(got) != 0 {
+		t.Errorf("len(FindByVoyage(V500)) = %d; want = %d", len(got), 0)
+	}
+}
+
+func TestCargoRepository_FindAwaitingClaimBeyond(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	unloaded := time.Date(2009, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	newCargo := func(id shipping.TrackingID, types ...shipping.HandlingEventType) *shipping.Cargo {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG})
+		var events []shipping.HandlingEvent
+		for i, typ := range types {
+			events = append(events, shipping.HandlingEvent{
+				TrackingID:     id,
+				Activity:       shipping.HandlingActivity{Type: typ, Location: shipping.CNHKG, VoyageNumber: "V100"},
+				CompletionTime: unloaded.Add(time.Duration(i) * time.Hour),
+			})
+		}
+		c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: events})
+		return c
+	}
+
+	for _, c := range []*shipping.Cargo{
+		newCargo("ABC", shipping.Unload),
+		newCargo("DEF", shipping.Unload, shipping.Claim),
+		newCargo("GHI", shipping.Load),
+		newCargo("JKL"),
+		newCargo("MNO", shipping.Unload, shipping.Customs),
+		newCargo("PQR", shipping.Unload, shipping.Load),
+	} {
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	window := 72 * time.Hour
+
+	if got := r.FindAwaitingClaimBeyond(ctx, window, unloaded.Add(window)); len(got) != 0 {
+		t.Errorf("len(FindAwaitingClaimBeyond(inside)) = %d; want = %d", len(got), 0)
+	}
+	if got, want := trackingIDs(r.FindAwaitingClaimBeyond(ctx, window, unloaded.Add(window+time.Minute))), []shipping.TrackingID{"ABC", "MNO"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAwaitingClaimBeyond(beyond) = %v; want = %v", got, want)
+	}
+}
+
+func TestCargoRepository_FindByTag(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	newCargo := func(id shipping.TrackingID, tags map[string]string) *shipping.Cargo {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG})
+		c.SetTags(tags)
+		return c
+	}
+
+	for _, c := range []*shipping.Cargo{
+		newCargo("ABC", map[string]string{"project": "apollo", "customer": "acme"}),
+		newCargo("DEF", map[string]string{"project": "apollo"}),
+		newCargo("GHI", map[string]string{"project": "gemini"}),
+		newCargo("JKL", nil),
+	} {
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := trackingIDs(r.FindByTag(ctx, "project", "apollo")), []shipping.TrackingID{"ABC", "DEF"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindByTag(project, apollo) = %v; want = %v", got, want)
+	}
+	if got := r.FindByTag(ctx, "customer", ""); len(got) != 0 {
+		t.Errorf("len(FindByTag(customer, \"\")) = %d; want = %d", len(got), 0)
+	}
+
+	// The found cargos are copies, whose tags can be changed without
+	// changing the stored cargos.
+	found := r.FindByTag(ctx, "project", "gemini")
+	if len(found) != 1 {
+		t.Fatalf("len(FindByTag(project, gemini)) = %d; want = %d", len(found), 1)
+	}
+	found[0].Tags["project"] = "apollo"
+	if got := r.FindByTag(ctx, "project", "apollo"); len(got) != 2 {
+		t.Errorf("len(FindByTag(project, apollo)) = %d; want = %d", len(got), 2)
+	}
+}
+
+func TestCargoRepository_FindAtLocation(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.AUMEL},
+	}})
+
+	var events []shipping.HandlingEvent
+	handle := func(typ shipping.HandlingEventType, loc shipping.UNLocode) {
+		events = append(events, shipping.HandlingEvent{
+			TrackingID: c.TrackingID,
+			Activity:   shipping.HandlingActivity{Type: typ, Location: loc, VoyageNumber: "V100"},
+		})
+		c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: events})
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(loc shipping.UNLocode) int {
+		return len(r.FindAtLocation(ctx, loc))
+	}
+
+	if got := count(shipping.SESTO); got != 0 {
+		t.Errorf("len(FindAtLocation(SESTO)) = %d; want = %d", got, 0)
+	}
+
+	handle(shipping.Receive, shipping.SESTO)
+
+	if got := count(shipping.SESTO); got != 1 {
+		t.Errorf("len(FindAtLocation(SESTO)) = %d; want = %d", got, 1)
+	}
+
+	handle(shipping.Load, shipping.SESTO)
+	handle(shipping.Unload, shipping.AUMEL)
+
+	if got := count(shipping.SESTO); got != 0 {
+		t.Errorf("len(FindAtLocation(SESTO)) = %d; want = %d", got, 0)
+	}
+	if got := count(shipping.AUMEL); got != 1 {
+		t.Errorf("len(FindAtLocation(AUMEL)) = %d; want = %d", got, 1)
+	}
+
+	handle(shipping.Claim, shipping.AUMEL)
+
+	if got := count(shipping.AUMEL); got != 0 {
+		t.Errorf("len(FindAtLocation(AUMEL)) = %d; want = %d", got, 0)
+	}
+}
+
+func TestCargoRepository_Archive(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	claimed := shipping.NewCargo("ABC123", shipping.RouteSpecification{})
+	claimed.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL}},
+	}})
+
+	unclaimed := shipping.NewCargo("XYZ789", shipping.RouteSpecification{})
+
+	for _, c := range []*shipping.Cargo{claimed, unclaimed} {
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.Archive(ctx, unclaimed.TrackingID); err != shipping.ErrCargoNotClaimed {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrCargoNotClaimed)
+	}
+	if err := r.Archive(ctx, "no_such_id"); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+	if err := r.Archive(ctx, claimed.TrackingID); err != nil {
+		t.Fatal(err)
+	}
+
+	if all := r.FindAll(ctx); len(all) != 1 || all[0].TrackingID != unclaimed.TrackingID {
+		t.Errorf("FindAll() = %v; want = [%s]", all, unclaimed.TrackingID)
+	}
+	if _, total, _ := r.FindAllPaged(ctx, 0, 10); total != 1 {
+		t.Errorf("total = %d; want = %d", total, 1)
+	}
+	if all := r.FindAllIncludingArchived(ctx); len(all) != 2 {
+		t.Errorf("len(FindAllIncludingArchived()) = %d; want = %d", len(all), 2)
+	}
+
+	found, err := r.Find(ctx, claimed.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found.Archived {
+		t.Errorf("Archived = %v; want = %v", found.Archived, true)
+	}
+
+	// The stored cargo is archived, not the one passed to Store.
+	if claimed.Archived {
+		t.Errorf("Archived = %v; want = %v", claimed.Archived, false)
+	}
+}
+
+func TestCargoRepository_StaleWrite(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	if err := r.Store(ctx, shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two operators read the same cargo.
+	first, err := r.Find(ctx, "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := r.Find(ctx, "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.Weight = 1200
+	if err := r.Store(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Version = %d; want = %d", first.Version, 2)
+	}
+
+	second.Volume = 14.5
+	if err := r.Store(ctx, second); err != shipping.ErrConcurrentModification {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrConcurrentModification)
+	}
+
+	found, err := r.Find(ctx, "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Weight != 1200 || found.Volume != 0 {
+		t.Errorf("Weight, Volume = %v, %v; want = %v, %v", found.Weight, found.Volume, 1200, 0)
+	}
+
+	// The second operator retries on a fresh read.
+	found.Volume = 14.5
+	if err := r.Store(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCargoRepository_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewCargoRepository()
+
+	changes, err := r.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := shipping.NewCargo("ABC", shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL})
+	for i := 0; i < 2; i++ {
+		if err := r.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []shipping.CargoChange{
+		{Type: shipping.CargoCreated, TrackingID: "ABC"},
+		{Type: shipping.CargoUpdated, TrackingID: "ABC"},
+	} {
+		if got := <-changes; got != want {
+			t.Errorf("change = %v; want = %v", got, want)
+		}
+	}
+
+	// A watcher that does not keep up loses its oldest changes.
+	for i := 0; i <= cargoWatchBuffer; i++ {
+		if err := r.Store(ctx, shipping.NewCargo(shipping.TrackingID(fmt.Sprintf("C%d", i)), c.RouteSpecification)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := <-changes; got.TrackingID != "C1" {
+		t.Errorf("TrackingID = %s; want = %s", got.TrackingID, "C1")
+	}
+
+	cancel()
+
+	for range changes {
+	}
+}
+
+func TestHandlingEventRepository_QueryHandlingHistoryBetween(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewHandlingEventRepository()
+
+	var (
+		id   = shipping.TrackingID("ABC123")
+		from = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		to   = time.Date(2009, time.March, 10, 0, 0, 0, 0, time.UTC)
+	)
+
+	event := func(typ shipping.HandlingEventType, completed time.Time) shipping.HandlingEvent {
+		return shipping.HandlingEvent{
+			TrackingID:     id,
+			Activity:       shipping.HandlingActivity{Type: typ, Location: shipping.SESTO},
+			CompletionTime: completed,
+		}
+	}
+
+	var (
+		before = event(shipping.Receive, from.Add(-time.Second))
+		onFrom = event(shipping.Load, from)
+		onTo   = event(shipping.Customs, to)
+		middle = event(shipping.Unload, from.AddDate(0, 0, 3))
+		after  = event(shipping.Claim, to.Add(time.Second))
+		events = []shipping.HandlingEvent{before, onTo, onFrom, middle, after}
+	)
+
+	for _, e := range events {
+		r.Store(ctx, e)
+	}
+	onTo.Sequence, onFrom.Sequence, middle.Sequence = 2, 3, 4
+
+	h := r.QueryHandlingHistoryBetween(ctx, id, from, to)
+
+	want := []shipping.HandlingEvent{onFrom, middle, onTo}
+	if !reflect.DeepEqual(h.HandlingEvents, want) {
+		t.Errorf("HandlingEvents = %v; want = %v", h.HandlingEvents, want)
+	}
+
+	last, err := h.MostRecentlyCompletedEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(last, onTo) {
+		t.Errorf("MostRecentlyCompletedEvent() = %v; want = %v", last, onTo)
+	}
+}
+
+func TestHandlingEventRepository_Stats(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewHandlingEventRepository()
+
+	var (
+		from = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		to   = time.Date(2009, time.March, 10, 0, 0, 0, 0, time.UTC)
+	)
+
+	for _, e := range []shipping.HandlingEvent{
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}, CompletionTime: from},
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}, CompletionTime: from.Add(time.Hour)},
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.DEHAM, VoyageNumber: "V100"}, CompletionTime: from.AddDate(0, 0, 2)},
+		{TrackingID: "XYZ789", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.DEHAM}, CompletionTime: from.AddDate(0, 0, 2)},
+		{TrackingID: "XYZ789", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.DEHAM, VoyageNumber: "V300"}, CompletionTime: to},
+
+		// Outside the window.
+		{TrackingID: "XYZ789", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.FIHEL}, CompletionTime: from.Add(-time.Second)},
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.DEHAM}, CompletionTime: to.Add(time.Second)},
+	} {
+		if err := r.Store(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := r.Stats(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Total != 5 {
+		t.Errorf("Total = %d; want = %d", got.Total, 5)
+	}
+
+	wantByType := map[shipping.HandlingEventType]int{
+		shipping.Receive: 2,
+		shipping.Load:    2,
+		shipping.Unload:  1,
+	}
+	if !reflect.DeepEqual(got.ByType, wantByType) {
+		t.Errorf("ByType = %v; want = %v", got.ByType, wantByType)
+	}
+
+	wantByLocation := map[shipping.UNLocode]int{
+		shipping.SESTO: 2,
+		shipping.DEHAM: 3,
+	}
+	if !reflect.DeepEqual(got.ByLocation, wantByLocation) {
+		t.Errorf("ByLocation = %v; want = %v", got.ByLocation, wantByLocation)
+	}
+
+	wantByDay := map[string]int{
+		"2009-03-01": 2,
+		"2009-03-03": 2,
+		"2009-03-10": 1,
+	}
+	if !reflect.DeepEqual(got.ByDay, wantByDay) {
+		t.Errorf("ByDay = %v; want = %v", got.ByDay, wantByDay)
+	}
+}
+
+func TestHandlingEventRepository_Search(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewHandlingEventRepository()
+
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	events := []shipping.HandlingEvent{
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}, CompletionTime: t0},
+		{TrackingID: "XYZ789", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}, CompletionTime: t0.Add(time.Hour)},
+		{TrackingID: "JKL567", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}, CompletionTime: t0.Add(2 * time.Hour)},
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}, CompletionTime: t0.AddDate(0, 0, 7)},
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.SESTO, VoyageNumber: "V100"}, CompletionTime: t0.Add(3 * time.Hour)},
+		{TrackingID: "XYZ789", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.DEHAM, VoyageNumber: "V100"}, CompletionTime: t0.Add(4 * time.Hour)},
+		{TrackingID: "JKL567", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V200"}, CompletionTime: t0.Add(5 * time.Hour)},
+	}
+	for i, e := range events {
+		if err := r.Store(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		events[i].Sequence = uint64(i + 1)
+	}
+
+	criteria := shipping.SearchCriteria{
+		Type:         shipping.Load,
+		Location:     shipping.SESTO,
+		VoyageNumber: "V100",
+		From:         t0,
+		To:           t0.AddDate(0, 0, 1),
+	}
+
+	var tests = []struct {
+		offset, limit int
+		want          []shipping.HandlingEvent
+	}{
+		{0, 0, []shipping.HandlingEvent{events[2], events[1], events[0]}},
+		{0, 2, []shipping.HandlingEvent{events[2], events[1]}},
+		{2, 2, []shipping.HandlingEvent{events[0]}},
+		{3, 2, []shipping.HandlingEvent{}},
+		{5, 0, []shipping.HandlingEvent{}},
+	}
+
+	for _, tt := range tests {
+		criteria.Offset, criteria.Limit = tt.offset, tt.limit
+
+		got, total, err := r.Search(ctx, criteria)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 3 {
+			t.Errorf("offset %d, limit %d: total = %d; want = %d", tt.offset, tt.limit, total, 3)
+		}
+		if len(got) != len(tt.want) || len(got) > 0 && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("offset %d, limit %d: Search() = %v; want = %v", tt.offset, tt.limit, got, tt.want)
+		}
+	}
+
+	if _, total, _ := r.Search(ctx, shipping.SearchCriteria{}); total != len(events) {
+		t.Errorf("total = %d; want = %d", total, len(events))
+	}
+
+	if _, _, err := r.Search(ctx, shipping.SearchCriteria{Offset: -1}); err != shipping.ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrInvalidArgument)
+	}
+}
+
+func TestVoyageRepository_Store(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewVoyageRepository()
+
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	v, err := shipping.NewVoyage("V901", shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+		{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t0, ArrivalTime: t0.AddDate(0, 0, 1)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Store(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+
+	// Storing an identical voyage again does nothing.
+	same := &shipping.Voyage{VoyageNumber: "V901", Schedule: v.Schedule.Delay(0, t0)}
+	if err := r.Store(ctx, same); err != nil {
+		t.Errorf("err = %v; want = %v", err, nil)
+	}
+
+	delayed := &shipping.Voyage{VoyageNumber: "V901", Schedule: v.Schedule.Delay(time.Hour, t0)}
+	if err := r.Store(ctx, delayed); err != shipping.ErrVoyageConflict {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrVoyageConflict)
+	}
+
+	got, err := r.Find(ctx, "V901")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != v {
+		t.Errorf("Find() = %v; want = %v", got, v)
+	}
+
+	if err := r.Update(ctx, delayed); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Find(ctx, "V901"); got != delayed {
+		t.Errorf("Find() = %v; want = %v", got, delayed)
+	}
+
+	if err := r.Update(ctx, &shipping.Voyage{VoyageNumber: "V902"}); err != shipping.ErrUnknownVoyage {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownVoyage)
+	}
+}
+
+func TestVoyageRepository_FindByRoute(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewVoyageRepository()
+
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+		t3 = t0.AddDate(0, 0, 3)
+	)
+
+	late, err := shipping.NewVoyage("V901", shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+		{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.DEHAM, DepartureTime: t0, ArrivalTime: t1},
+		{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t2, ArrivalTime: t3},
+		{DepartureLocation: shipping.NLRTM, ArrivalLocation: shipping.USNYC, DepartureTime: t3, ArrivalTime: t3.AddDate(0, 0, 7)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	early, err := shipping.NewVoyage("V902", shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+		{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t1, ArrivalTime: t2},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []*shipping.Voyage{late, early} {
+		if err := r.Store(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := r.FindByRoute(ctx, shipping.DEHAM, shipping.NLRTM)
+
+	var numbers []shipping.VoyageNumber
+	for _, v := range got {
+		numbers = append(numbers, v.VoyageNumber)
+	}
+
+	// V300 also sails from Hamburg, but to Melbourne.
+	want := []shipping.VoyageNumber{"V902", "V901"}
+	if !reflect.DeepEqual(numbers, want) {
+		t.Errorf("FindByRoute(DEHAM, NLRTM) = %v; want = %v", numbers, want)
+	}
+
+	if got := r.FindByRoute(ctx, shipping.SESTO, shipping.NLRTM); len(got) != 0 {
+		t.Errorf("len(FindByRoute(SESTO, NLRTM)) = %d; want = %d", len(got), 0)
+	}
+}
+
+func TestVoyageRepository_FindDepartingBetween(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewVoyageRepository()
+
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+		t3 = t0.AddDate(0, 0, 3)
+		t9 = t0.AddDate(0, 0, 9)
+	)
+
+	voyages := []struct {
+		number    shipping.VoyageNumber
+		movements []shipping.CarrierMovement
+	}{
+		// Calls at Hamburg on its second movement, inside the window.
+		{"V901", []shipping.CarrierMovement{
+			{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.DEHAM, DepartureTime: t0, ArrivalTime: t1},
+			{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t3, ArrivalTime: t3.AddDate(0, 0, 1)},
+		}},
+		// Departs from Hamburg first, inside the window.
+		{"V902", []shipping.CarrierMovement{
+			{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t2, ArrivalTime: t3},
+		}},
+		// Departs from Hamburg before the window.
+		{"V903", []shipping.CarrierMovement{
+			{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t0, ArrivalTime: t1},
+		}},
+		// Departs from Hamburg after the window.
+		{"V904", []shipping.CarrierMovement{
+			{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t9, ArrivalTime: t9.AddDate(0, 0, 1)},
+		}},
+		// Departs inside the window, but not from Hamburg.
+		{"V905", []shipping.CarrierMovement{
+			{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.DEHAM, DepartureTime: t2, ArrivalTime: t3},
+		}},
+	}
+
+	for _, tt := range voyages {
+		v, err := shipping.NewVoyage(tt.number, shipping.Schedule{CarrierMovements: tt.movements})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Store(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := r.FindDepartingBetween(ctx, shipping.DEHAM, t1, t1.AddDate(0, 0, 7))
+
+	var numbers []shipping.VoyageNumber
+	for _, v := range got {
+		numbers = append(numbers, v.VoyageNumber)
+	}
+
+	// The sample voyages do not depart within the window.
+	want := []shipping.VoyageNumber{"V902", "V901"}
+	if !reflect.DeepEqual(numbers, want) {
+		t.Errorf("FindDepartingBetween(DEHAM) = %v; want = %v", numbers, want)
+	}
+}
+
+func TestLocationRepository_FindByNamePrefix(t *testing.T) {
+	ctx := context.Background()
+
+	r := newLocationRepository(NewMapStore[shipping.UNLocode, *shipping.Location]())
+	for _, l := range []*shipping.Location{
+		shipping.Stockholm,
+		shipping.Hamburg,
+		shipping.Hongkong,
+		shipping.Helsinki,
+		{UNLocode: "SEGOT", Name: "Göteborg"},
+		{UNLocode: "DEMUC", Name: "München"},
+	} {
+		r.locations.Put(l.UNLocode, l)
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"h", []string{"Hamburg", "Helsinki", "Hongkong"}},
+		{"HAM", []string{"Hamburg"}},
+		{"stock", []string{"Stockholm"}},
+		{"GÖT", []string{"Göteborg"}},
+		{"mü", []string{"München"}},
+		{"x", []string{}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, l := range r.FindByNamePrefix(ctx, tt.prefix) {
+			got = append(got, l.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindByNamePrefix(%q) = %v; want = %v", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestCargoRepository_ContextDone(t *testing.T) {
+	r := NewCargoRepository()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := r.Store(ctx, shipping.NewCargo("ABC123", shipping.RouteSpecification{})); err != context.Canceled {
+		t.Errorf("Store() err = %v; want = %v", err, context.Canceled)
+	}
+	if _, err := r.Find(ctx, "ABC123"); err != context.Canceled {
+		t.Errorf("Find() err = %v; want = %v", err, context.Canceled)
+	}
+	if got := r.FindAll(ctx); len(got) != 0 {
+		t.Errorf("len(FindAll()) = %d; want = %d", len(got), 0)
+	}
+}
+
+func TestRepositories_Concurrent(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos    = NewCargoRepository()
+		events    = NewHandlingEventRepository()
+		locations = NewLocationRepository()
+		voyages   = NewVoyageRepository()
+	)
+
+	const n = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+
+		id := shipping.TrackingID(fmt.Sprintf("ID%03d", i))
+
+		go func() {
+			defer wg.Done()
+			if err := cargos.Store(ctx, shipping.NewCargo(id, shipping.RouteSpecification{})); err != nil {
+				t.Error(err)
+			}
+			events.Store(ctx, shipping.HandlingEvent{TrackingID: id})
+			voyages.Store(ctx, shipping.V100)
+		}()
+
+		go func() {
+			defer wg.Done()
+			cargos.Find(ctx, id)
+			cargos.FindAll(ctx)
+			cargos.FindAllPaged(ctx, 0, 10)
+			events.QueryHandlingHistory(ctx, id)
+			locations.FindAll(ctx)
+			locations.FindByNamePrefix(ctx, "s")
+			voyages.FindByRoute(ctx, shipping.SESTO, shipping.CNHKG)
+		}()
+	}
+	wg.Wait()
+
+	if got := len(cargos.FindAll(ctx)); got != n {
+		t.Errorf("len(FindAll()) = %d; want = %d", got, n)
+	}
+}
+
+func TestCargoRepository_FindAllSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+	r.Store(ctx, shipping.NewCargo("ABC123", shipping.RouteSpecification{}))
+
+	all := r.FindAll(ctx)
+
+	r.Store(ctx, shipping.NewCargo("DEF456", shipping.RouteSpecification{}))
+
+	if len(all) != 1 {
+		t.Errorf("len(all) = %d; want = %d", len(all), 1)
+	}
+}
+
+func TestCargoRepository_Copies(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewCargoRepository()
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.CNHKG,
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+	}})
+
+	if err := r.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modifying the stored cargo must not leak into the repository.
+	c.RouteSpecification.Destination = shipping.AUMEL
+	c.Itinerary.Legs[0].UnloadLocation = shipping.AUMEL
+
+	found, err := r.Find(ctx, "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither must modifying a returned cargo.
+	found.RouteSpecification.Destination = shipping.USNYC
+	found.Itinerary.Legs[0].UnloadLocation = shipping.USNYC
+	found.Delivery.Itinerary.Legs[0].UnloadLocation = shipping.USNYC
+
+	for _, got := range []*shipping.Cargo{mustFind(t, r, "ABC123"), r.FindAll(ctx)[0]} {
+		if got.RouteSpecification.Destination != shipping.CNHKG {
+			t.Errorf("RouteSpecification.Destination = %v; want = %v", got.RouteSpecification.Destination, shipping.CNHKG)
+		}
+		if got.Itinerary.Legs[0].UnloadLocation != shipping.CNHKG {
+			t.Errorf("Itinerary.Legs[0].UnloadLocation = %v; want = %v", got.Itinerary.Legs[0].UnloadLocation, shipping.CNHKG)
+		}
+		if got.Delivery.Itinerary.Legs[0].UnloadLocation != shipping.CNHKG {
+			t.Errorf("Delivery.Itinerary.Legs[0].UnloadLocation = %v; want = %v", got.Delivery.Itinerary.Legs[0].UnloadLocation, shipping.CNHKG)
+		}
+	}
+}
+
+func mustFind(t *testing.T, r shipping.CargoRepository, id shipping.TrackingID) *shipping.Cargo {
+	c, err := r.Find(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos = NewCargoRepository()
+		events = NewHandlingEventRepository()
+	)
+
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: t0.AddDate(0, 0, 30),
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		shipping.NewLeg("V100", shipping.SESTO, shipping.CNHKG, t0.AddDate(0, 0, 1), t0.AddDate(0, 0, 20)),
+	}})
+
+	history := []shipping.HandlingEvent{
+		{
+			TrackingID:       "ABC123",
+			Activity:         shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO},
+			RegistrationTime: t0.Add(time.Hour),
+			CompletionTime:   t0,
+			IdempotencyKey:   "scan-1",
+		},
+		{
+			TrackingID:       "ABC123",
+			Activity:         shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"},
+			RegistrationTime: t0.AddDate(0, 0, 1).Add(time.Hour),
+			CompletionTime:   t0.AddDate(0, 0, 1),
+		},
+	}
+	// The event of XYZ789 is stored first, so that the events are not
+	// numbered in the order of their tracking IDs.
+	events.Store(ctx, shipping.HandlingEvent{
+		TrackingID:     "XYZ789",
+		Activity:       shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.AUMEL},
+		CompletionTime: t0,
+	})
+	for _, e := range history {
+		events.Store(ctx, e)
+	}
+	c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: history})
+
+	cargos.Store(ctx, c)
+	cargos.Store(ctx, shipping.NewCargo("XYZ789", shipping.RouteSpecification{
+		Origin:      shipping.AUMEL,
+		Destination: shipping.USNYC,
+	}))
+
+	var cargoSnapshot, eventSnapshot bytes.Buffer
+	if err := cargos.(Snapshotter).ExportJSON(&cargoSnapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := events.(Snapshotter).ExportJSON(&eventSnapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		restoredCargos = NewCargoRepository()
+		restoredEvents = NewHandlingEventRepository()
+	)
+
+	restoredCargos.Store(ctx, shipping.NewCargo("OLD000", shipping.RouteSpecification{}))
+
+	if err := Restore(restoredCargos, restoredEvents, &cargoSnapshot, &eventSnapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := restoredCargos.Find(ctx, "OLD000"); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+
+	for _, id := range []shipping.TrackingID{"ABC123", "XYZ789"} {
+		want, _ := cargos.Find(ctx, id)
+		got, err := restoredCargos.Find(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Find(%s) = %#v; want = %#v", id, got, want)
+		}
+	}
+
+	for _, id := range []shipping.TrackingID{"ABC123", "XYZ789"} {
+		if got, want := restoredEvents.QueryHandlingHistory(ctx, id), events.QueryHandlingHistory(ctx, id); !reflect.DeepEqual(got, want) {
+			t.Errorf("QueryHandlingHistory(%s) = %v; want = %v", id, got.HandlingEvents, want.HandlingEvents)
+		}
+	}
+
+	// Events keep their sequence numbers, so that a consumer resumes after
+	// a restore where it left off.
+	got, err := restoredEvents.QuerySince(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := events.QuerySince(ctx, 1)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QuerySince(1) = %v; want = %v", got, want)
+	}
+	if len(got) != 2 || got[0].TrackingID != "ABC123" {
+		t.Errorf("QuerySince(1) = %v; want the events of ABC123", got)
+	}
+
+	if _, err := restoredEvents.FindByIdempotencyKey(ctx, "scan-1"); err != nil {
+		t.Errorf("FindByIdempotencyKey() err = %v; want = %v", err, nil)
+	}
+}
+
+func TestRestore_UnknownCargo(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos = NewCargoRepository()
+		events = NewHandlingEventRepository()
+	)
+
+	cargos.Store(ctx, shipping.NewCargo("ABC123", shipping.RouteSpecification{}))
+
+	cargoSnapshot := bytes.NewBufferString(`[]`)
+	eventSnapshot := bytes.NewBufferString(`[{"trackingId":"XYZ789","type":"Receive","location":"SESTO","registrationTime":"2009-03-01T00:00:00Z","completionTime":"2009-03-01T00:00:00Z"}]`)
+
+	err := Restore(cargos, events, cargoSnapshot, eventSnapshot)
+
+	e, ok := err.(*IntegrityError)
+	if !ok {
+		t.Fatalf("err = %v; want = %T", err, e)
+	}
+	if want := []shipping.TrackingID{"XYZ789"}; !reflect.DeepEqual(e.UnknownCargos, want) {
+		t.Errorf("e.UnknownCargos = %v; want = %v", e.UnknownCargos, want)
+	}
+
+	if _, err := cargos.Find(ctx, "ABC123"); err != nil {
+		t.Errorf("the existing state should be left untouched: %v", err)
+	}
+}
+
+func TestHandlingEventRepository_StoreDuplicate(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewHandlingEventRepository()
+
+	completed := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	e := shipping.HandlingEvent{
+		TrackingID:       "ABC123",
+		Activity:         shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"},
+		RegistrationTime: completed,
+		CompletionTime:   completed,
+	}
+
+	if err := r.Store(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+
+	dup := e
+	dup.RegistrationTime = completed.Add(time.Minute)
+	dup.IdempotencyKey = "scan-2"
+
+	if err := r.Store(ctx, dup); err != shipping.ErrDuplicateEvent {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrDuplicateEvent)
+	}
+
+	later := e
+	later.CompletionTime = completed.Add(time.Hour)
+
+	if err := r.Store(ctx, later); err != nil {
+		t.Errorf("err = %v; want = %v", err, nil)
+	}
+
+	h := r.QueryHandlingHistory(ctx, "ABC123")
+	if len(h.HandlingEvents) != 2 {
+		t.Fatalf("len(h.HandlingEvents) = %d; want = %d", len(h.HandlingEvents), 2)
+	}
+	if !h.HandlingEvents[0].RegistrationTime.Equal(completed) {
+		t.Errorf("the original event should be left in place")
+	}
+	if _, err := r.FindByIdempotencyKey(ctx, "scan-2"); err != shipping.ErrUnknownHandlingEvent {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownHandlingEvent)
+	}
+}
+
+func TestTrackingIDReserver(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := NewCargoRepository()
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC", shipping.RouteSpecification{})); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewTrackingIDReserver(cargos)
+
+	var tests = []struct {
+		id   shipping.TrackingID
+		want error
+	}{
+		{"ABC", shipping.ErrTrackingIDTaken},
+		{"DEF", nil},
+		{"DEF", shipping.ErrTrackingIDTaken},
+		{"GHI", nil},
+	}
+
+	for _, tt := range tests {
+		if err := r.Reserve(ctx, tt.id); err != tt.want {
+			t.Errorf("Reserve(%s) = %v; want = %v", tt.id, err, tt.want)
+		}
+	}
+}
+
+func TestHandlingEventRepository_QuerySince(t *testing.T) {
+	ctx := context.Background()
+
+	r := NewHandlingEventRepository()
+
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	// The events are stored out of completion order, and across cargos.
+	events := []shipping.HandlingEvent{
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO}, CompletionTime: t0.AddDate(0, 0, 1)},
+		{TrackingID: "XYZ789", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.AUMEL}, CompletionTime: t0},
+		{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}, CompletionTime: t0},
+		{TrackingID: "XYZ789", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.AUMEL}, CompletionTime: t0.AddDate(0, 0, 2)},
+	}
+
+	var seqs []uint64
+	for _, e := range events {
+		if err := r.Store(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		all, err := r.QuerySince(ctx, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, all[len(all)-1].Sequence)
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Errorf("sequences = %v; want increasing", seqs)
+		}
+	}
+
+	got, err := r.QuerySince(ctx, seqs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("len(QuerySince(%d)) = %d; want = %d", seqs[1], len(got), 2)
+	}
+	for i, e := range got {
+		want := events[i+2]
+		want.Sequence = seqs[i+2]
+		if !reflect.DeepEqual(e, want) {
+			t.Errorf("QuerySince(%d)[%d] = %v; want = %v", seqs[1], i, e, want)
+		}
+	}
+
+	if got, _ := r.QuerySince(ctx, seqs[3]); len(got) != 0 {
+		t.Errorf("QuerySince(%d) = %v; want none", seqs[3], got)
+	}
+
+	// Confirming and superseding an event gives it a new sequence number,
+	// so that consumers see the change.
+	planned := shipping.HandlingEvent{TrackingID: "ABC123", Activity: shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.AUMEL}, CompletionTime: t0.AddDate(0, 0, 3), Planned: true}
+	if err := r.Store(ctx, planned); err != nil {
+		t.Fatal(err)
+	}
+	last := seqs[3] + 1
+
+	for _, update := range []func(context.Context, shipping.HandlingEventKey) error{r.Confirm, r.Supersede} {
+		if err := update(ctx, planned.Key()); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.QuerySince(ctx, last)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Key() != planned.Key() {
+			t.Fatalf("QuerySince(%d) = %v; want the updated event", last, got)
+		}
+		if got[0].Sequence <= last {
+			t.Errorf("Sequence = %d; want > %d", got[0].Sequence, last)
+		}
+		last = got[0].Sequence
+	}
+}
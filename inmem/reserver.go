@@ -0,0 +1,46 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"context"
+	"sync"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type trackingIDReserver struct {
+	mtx      sync.Mutex
+	cargos   shipping.CargoRepository
+	reserved map[shipping.TrackingID]struct{}
+}
+
+// NewTrackingIDReserver returns a reserver of the tracking IDs that are not
+// used by any cargo in the repository. Reserved IDs are remembered, so that
+// an ID is only handed out once, even before its cargo has been stored.
+func NewTrackingIDReserver(cargos shipping.CargoRepository) shipping.TrackingIDReserver {
+	return &trackingIDReserver{
+		cargos:   cargos,
+		reserved: make(map[shipping.TrackingID]struct{}),
+	}
+}
+
+func (r *trackingIDReserver) Reserve(ctx context.Context, id shipping.TrackingID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.reserved[id]; ok {
+		return shipping.ErrTrackingIDTaken
+	}
+	_, err := r.cargos.Find(ctx, id)
+	if err == nil {
+		return shipping.ErrTrackingIDTaken
+	}
+	if err != shipping.ErrUnknownCargo {
+		return err
+	}
+	r.reserved[id] = struct{}{}
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build go1.18
+
+package inmem
+
+// Store is a key-value store that the in-memory repositories keep their state
+// in. Implementing it is enough to give every repository a new backend. The
+// repositories serialize access to it, so implementations need not be safe
+// for concurrent use.
+type Store[K comparable, V any] interface {
+	// Get returns the value stored under the key, and whether there was one.
+	Get(key K) (V, bool)
+
+	// Put stores the value under the key, replacing any previous value.
+	Put(key K, val V)
+
+	// Delete removes the value stored under the key, if any.
+	Delete(key K)
+
+	// List returns all stored values, in no particular order.
+	List() []V
+}
+
+type mapStore[K comparable, V any] struct {
+	m map[K]V
+}
+
+// NewMapStore returns a store backed by a map.
+func NewMapStore[K comparable, V any]() Store[K, V] {
+	return &mapStore[K, V]{m: make(map[K]V)}
+}
+
+func (s *mapStore[K, V]) Get(key K) (V, bool) {
+	val, ok := s.m[key]
+	return val, ok
+}
+
+func (s *mapStore[K, V]) Put(key K, val V) {
+	s.m[key] = val
+}
+
+func (s *mapStore[K, V]) Delete(key K) {
+	delete(s.m, key)
+}
+
+func (s *mapStore[K, V]) List() []V {
+	vals := make([]V, 0, len(s.m))
+	for _, val := range s.m {
+		vals = append(vals, val)
+	}
+	return vals
+}
@@ -0,0 +1,167 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestMapStore(t *testing.T) {
+	s := NewMapStore[string, int]()
+
+	if _, ok := s.Get("a"); ok {
+		t.Errorf("Get(a) ok = %v; want = %v", ok, false)
+	}
+
+	s.Put("a", 1)
+	s.Put("b", 2)
+	s.Put("a", 3)
+
+	if got, ok := s.Get("a"); !ok || got != 3 {
+		t.Errorf("Get(a) = %v, %v; want = %v, %v", got, ok, 3, true)
+	}
+
+	s.Delete("b")
+	s.Delete("c")
+
+	if got := s.List(); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("List() = %v; want = %v", got, []int{3})
+	}
+}
+
+// recordingStore is a store backed by another store, that records the keys
+// that values are put under.
+type recordingStore[K comparable, V any] struct {
+	Store[K, V]
+	puts []K
+}
+
+func (s *recordingStore[K, V]) Put(key K, val V) {
+	s.puts = append(s.puts, key)
+	s.Store.Put(key, val)
+}
+
+func TestCargoRepository_Store(t *testing.T) {
+	ctx := context.Background()
+
+	s := &recordingStore[shipping.TrackingID, *shipping.Cargo]{
+		Store: NewMapStore[shipping.TrackingID, *shipping.Cargo](),
+	}
+
+	var (
+		stored = newCargoRepository(s)
+		mapped = NewCargoRepository()
+	)
+
+	claimed := shipping.NewCargo("ABC", shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL})
+	claimed.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL}},
+	}})
+	unclaimed := shipping.NewCargo("DEF", shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG})
+
+	for _, r := range []shipping.CargoRepository{stored, mapped} {
+		for _, c := range []shipping.Cargo{*claimed, *unclaimed} {
+			if err := r.Store(ctx, &c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.Archive(ctx, "ABC"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := trackingIDs(stored.FindAll(ctx)), trackingIDs(mapped.FindAll(ctx)); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAll() = %v; want = %v", got, want)
+	}
+	if got, want := trackingIDs(stored.FindAllIncludingArchived(ctx)), trackingIDs(mapped.FindAllIncludingArchived(ctx)); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllIncludingArchived() = %v; want = %v", got, want)
+	}
+
+	got, err := stored.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := mapped.Find(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find(ABC) = %v; want = %v", got, want)
+	}
+
+	wantPuts := []shipping.TrackingID{"ABC", "DEF", "ABC"}
+	if !reflect.DeepEqual(s.puts, wantPuts) {
+		t.Errorf("puts = %v; want = %v", s.puts, wantPuts)
+	}
+}
+
+func TestHandlingEventRepository_Store(t *testing.T) {
+	ctx := context.Background()
+
+	s := &recordingStore[shipping.TrackingID, []shipping.HandlingEvent]{
+		Store: NewMapStore[shipping.TrackingID, []shipping.HandlingEvent](),
+	}
+
+	var (
+		stored = newHandlingEventRepository(s, NewMapStore[string, shipping.HandlingEvent]())
+		mapped = NewHandlingEventRepository()
+	)
+
+	events := []shipping.HandlingEvent{
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}, IdempotencyKey: "1"},
+		{TrackingID: "ABC", Activity: shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL}},
+		{TrackingID: "DEF", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}},
+	}
+
+	for _, r := range []shipping.HandlingEventRepository{stored, mapped} {
+		for _, e := range events {
+			if err := r.Store(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.Store(ctx, events[2]); err != shipping.ErrDuplicateEvent {
+			t.Errorf("err = %v; want = %v", err, shipping.ErrDuplicateEvent)
+		}
+		retry := shipping.HandlingEvent{TrackingID: "DEF", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO}, IdempotencyKey: "1"}
+		if err := r.Store(ctx, retry); err != shipping.ErrDuplicateIdempotencyKey {
+			t.Errorf("err = %v; want = %v", err, shipping.ErrDuplicateIdempotencyKey)
+		}
+	}
+
+	for _, id := range []shipping.TrackingID{"ABC", "DEF", "GHI"} {
+		got, want := stored.QueryHandlingHistory(ctx, id), mapped.QueryHandlingHistory(ctx, id)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("QueryHandlingHistory(%s) = %v; want = %v", id, got, want)
+		}
+	}
+
+	got, err := stored.FindByIdempotencyKey(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := events[0]
+	want.Sequence = 1
+	if got != want {
+		t.Errorf("FindByIdempotencyKey(1) = %v; want = %v", got, want)
+	}
+
+	if len(s.puts) != len(events) {
+		t.Errorf("len(puts) = %d; want = %d", len(s.puts), len(events))
+	}
+}
+
+func trackingIDs(cargos []*shipping.Cargo) []shipping.TrackingID {
+	ids := make([]shipping.TrackingID, len(cargos))
+	for i, c := range cargos {
+		ids[i] = c.TrackingID
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
@@ -0,0 +1,170 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// tenants keeps a repository for each tenant, created on first use.
+type tenants[R any] struct {
+	mtx   sync.Mutex
+	repos map[shipping.TenantID]R
+	new   func() R
+}
+
+func newTenants[R any](new func() R) tenants[R] {
+	return tenants[R]{repos: make(map[shipping.TenantID]R), new: new}
+}
+
+// scope returns the repository of the tenant the context is scoped to.
+func (t *tenants[R]) scope(ctx context.Context) R {
+	id := shipping.TenantFromContext(ctx)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	r, ok := t.repos[id]
+	if !ok {
+		r = t.new()
+		t.repos[id] = r
+	}
+	return r
+}
+
+type tenantCargoRepository struct {
+	tenants[shipping.CargoRepository]
+}
+
+// NewTenantCargoRepository returns a new instance of an in-memory cargo
+// repository that partitions the cargos by the tenant of the context, as
+// given by shipping.TenantFromContext. A cargo stored by one tenant is
+// unknown to all others.
+func NewTenantCargoRepository() shipping.CargoRepository {
+	return &tenantCargoRepository{newTenants(NewCargoRepository)}
+}
+
+func (r *tenantCargoRepository) Store(ctx context.Context, c *shipping.Cargo) error {
+	return r.scope(ctx).Store(ctx, c)
+}
+
+func (r *tenantCargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
+	return r.scope(ctx).Find(ctx, id)
+}
+
+func (r *tenantCargoRepository) FindAll(ctx context.Context) []*shipping.Cargo {
+	return r.scope(ctx).FindAll(ctx)
+}
+
+func (r *tenantCargoRepository) FindAllIncludingArchived(ctx context.Context) []*shipping.Cargo {
+	return r.scope(ctx).FindAllIncludingArchived(ctx)
+}
+
+func (r *tenantCargoRepository) FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*shipping.Cargo {
+	return r.scope(ctx).FindAwaitingClaimBeyond(ctx, d, now)
+}
+
+func (r *tenantCargoRepository) FindOverdue(ctx context.Context, now time.Time) []*shipping.Cargo {
+	return r.scope(ctx).FindOverdue(ctx, now)
+}
+
+func (r *tenantCargoRepository) FindAllPaged(ctx context.Context, offset, limit int) ([]*shipping.Cargo, int, error) {
+	return r.scope(ctx).FindAllPaged(ctx, offset, limit)
+}
+
+func (r *tenantCargoRepository) FindByExternalRef(ctx context.Context, ref string) (*shipping.Cargo, error) {
+	return r.scope(ctx).FindByExternalRef(ctx, ref)
+}
+
+// Watch returns the changes made to the cargos of the tenant the context is
+// scoped to.
+func (r *tenantCargoRepository) Watch(ctx context.Context) (<-chan shipping.CargoChange, error) {
+	return r.scope(ctx).Watch(ctx)
+}
+
+func (r *tenantCargoRepository) FindByTag(ctx context.Context, key, value string) []*shipping.Cargo {
+	return r.scope(ctx).FindByTag(ctx, key, value)
+}
+
+func (r *tenantCargoRepository) FindByVoyage(ctx context.Context, number shipping.VoyageNumber) []*shipping.Cargo {
+	return r.scope(ctx).FindByVoyage(ctx, number)
+}
+
+func (r *tenantCargoRepository) FindByServiceLevel(ctx context.Context, level shipping.ServiceLevel) []*shipping.Cargo {
+	return r.scope(ctx).FindByServiceLevel(ctx, level)
+}
+
+func (r *tenantCargoRepository) FindByRoutingStatus(ctx context.Context, status shipping.RoutingStatus) []*shipping.Cargo {
+	return r.scope(ctx).FindByRoutingStatus(ctx, status)
+}
+
+func (r *tenantCargoRepository) FindAtLocation(ctx context.Context, loc shipping.UNLocode) []*shipping.Cargo {
+	return r.scope(ctx).FindAtLocation(ctx, loc)
+}
+
+func (r *tenantCargoRepository) FindChildren(ctx context.Context, parent shipping.TrackingID) []*shipping.Cargo {
+	return r.scope(ctx).FindChildren(ctx, parent)
+}
+
+func (r *tenantCargoRepository) FindByDestination(ctx context.Context, dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo {
+	return r.scope(ctx).FindByDestination(ctx, dest, activeOnly)
+}
+
+func (r *tenantCargoRepository) Archive(ctx context.Context, id shipping.TrackingID) error {
+	return r.scope(ctx).Archive(ctx, id)
+}
+
+type tenantHandlingEventRepository struct {
+	tenants[shipping.HandlingEventRepository]
+}
+
+// NewTenantHandlingEventRepository returns a new instance of an in-memory
+// handling event repository that partitions the events by the tenant of the
+// context, like NewTenantCargoRepository.
+func NewTenantHandlingEventRepository() shipping.HandlingEventRepository {
+	return &tenantHandlingEventRepository{newTenants(NewHandlingEventRepository)}
+}
+
+func (r *tenantHandlingEventRepository) Store(ctx context.Context, e shipping.HandlingEvent) error {
+	return r.scope(ctx).Store(ctx, e)
+}
+
+func (r *tenantHandlingEventRepository) QueryHandlingHistory(ctx context.Context, id shipping.TrackingID) shipping.HandlingHistory {
+	return r.scope(ctx).QueryHandlingHistory(ctx, id)
+}
+
+func (r *tenantHandlingEventRepository) FindByIdempotencyKey(ctx context.Context, key string) (shipping.HandlingEvent, error) {
+	return r.scope(ctx).FindByIdempotencyKey(ctx, key)
+}
+
+func (r *tenantHandlingEventRepository) Supersede(ctx context.Context, key shipping.HandlingEventKey) error {
+	return r.scope(ctx).Supersede(ctx, key)
+}
+
+func (r *tenantHandlingEventRepository) Confirm(ctx context.Context, key shipping.HandlingEventKey) error {
+	return r.scope(ctx).Confirm(ctx, key)
+}
+
+func (r *tenantHandlingEventRepository) QueryHandlingHistoryBetween(ctx context.Context, id shipping.TrackingID, from, to time.Time) shipping.HandlingHistory {
+	return r.scope(ctx).QueryHandlingHistoryBetween(ctx, id, from, to)
+}
+
+func (r *tenantHandlingEventRepository) Stats(ctx context.Context, from, to time.Time) (shipping.HandlingStats, error) {
+	return r.scope(ctx).Stats(ctx, from, to)
+}
+
+func (r *tenantHandlingEventRepository) Search(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	return r.scope(ctx).Search(ctx, criteria)
+}
+
+func (r *tenantHandlingEventRepository) QueryHandlingEventsSince(ctx context.Context, since time.Time) []shipping.HandlingEvent {
+	return r.scope(ctx).QueryHandlingEventsSince(ctx, since)
+}
+
+func (r *tenantHandlingEventRepository) QuerySince(ctx context.Context, seq uint64) ([]shipping.HandlingEvent, error) {
+	return r.scope(ctx).QuerySince(ctx, seq)
+}
@@ -0,0 +1,77 @@
+//go:build go1.18
+
+package inmem
+
+import (
+	"context"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestTenantCargoRepository(t *testing.T) {
+	var (
+		acme   = shipping.NewTenantContext(context.Background(), "acme")
+		globex = shipping.NewTenantContext(context.Background(), "globex")
+	)
+
+	r := NewTenantCargoRepository()
+
+	rs := shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL}
+
+	if err := r.Store(acme, shipping.NewCargo("ABC", rs)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Store(globex, shipping.NewCargo("DEF", rs)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Find(acme, "ABC"); err != nil {
+		t.Errorf("err = %v; want = %v", err, nil)
+	}
+	if _, err := r.Find(globex, "ABC"); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+	if _, err := r.Find(context.Background(), "ABC"); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+
+	if got := trackingIDs(r.FindAll(globex)); len(got) != 1 || got[0] != "DEF" {
+		t.Errorf("FindAll() = %v; want = %v", got, []shipping.TrackingID{"DEF"})
+	}
+
+	// A tenant may use a tracking ID already used by another.
+	if err := r.Store(globex, shipping.NewCargo("ABC", rs)); err != nil {
+		t.Errorf("err = %v; want = %v", err, nil)
+	}
+	if got := trackingIDs(r.FindAll(acme)); len(got) != 1 || got[0] != "ABC" {
+		t.Errorf("FindAll() = %v; want = %v", got, []shipping.TrackingID{"ABC"})
+	}
+}
+
+func TestTenantHandlingEventRepository(t *testing.T) {
+	var (
+		acme   = shipping.NewTenantContext(context.Background(), "acme")
+		globex = shipping.NewTenantContext(context.Background(), "globex")
+	)
+
+	r := NewTenantHandlingEventRepository()
+
+	e := shipping.HandlingEvent{
+		TrackingID: "ABC",
+		Activity:   shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO},
+	}
+	if err := r.Store(acme, e); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(r.QueryHandlingHistory(acme, "ABC").HandlingEvents); got != 1 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", got, 1)
+	}
+	if got := len(r.QueryHandlingHistory(globex, "ABC").HandlingEvents); got != 0 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", got, 0)
+	}
+	if err := r.Supersede(globex, e.Key()); err != shipping.ErrUnknownHandlingEvent {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownHandlingEvent)
+	}
+}
@@ -2,13 +2,85 @@
 package inspection
 
 import (
+	"context"
+	"time"
+
 	shipping "github.com/marcusolsson/goddd"
 )
 
+// MisdirectionHandler is notified when an inspection finds a cargo
+// misdirected, along with the location where it was last handled.
+type MisdirectionHandler interface {
+	CargoWasMisdirected(ctx context.Context, id shipping.TrackingID, lastKnownLocation shipping.UNLocode)
+}
+
+// OverdueHandler is notified when an inspection finds that a cargo has gone
+// overdue. It is optional: only an EventHandler that also implements it is
+// notified.
+type OverdueHandler interface {
+	CargoIsOverdue(ctx context.Context, id shipping.TrackingID, deadline time.Time)
+}
+
 // EventHandler provides means of subscribing to inspection events.
 type EventHandler interface {
-	CargoWasMisdirected(*shipping.Cargo)
-	CargoHasArrived(*shipping.Cargo)
+	MisdirectionHandler
+
+	// CargoHasArrived is called once when a cargo has been unloaded at its
+	// final destination, and is ready to be claimed.
+	CargoHasArrived(ctx context.Context, id shipping.TrackingID)
+}
+
+// InspectionPolicy decides what an inspection concludes about a cargo whose
+// delivery progress has been derived from its handling history.
+type InspectionPolicy interface {
+	// IsMisdirected returns whether the cargo has been handled in a way that
+	// does not fit its itinerary.
+	IsMisdirected(c *shipping.Cargo) bool
+
+	// IsArrived returns whether the cargo has arrived at its final
+	// destination.
+	IsArrived(c *shipping.Cargo) bool
+}
+
+// DefaultPolicy follows the delivery of the cargo: it is misdirected if the
+// delivery says so, and arrived once it has been unloaded at its destination.
+var DefaultPolicy InspectionPolicy = defaultPolicy{}
+
+type defaultPolicy struct{}
+
+func (defaultPolicy) IsMisdirected(c *shipping.Cargo) bool {
+	return c.Delivery.IsMisdirected
+}
+
+func (defaultPolicy) IsArrived(c *shipping.Cargo) bool {
+	return c.Delivery.IsUnloadedAtDestination
+}
+
+// InspectionFlag marks an irregularity found by an inspection.
+type InspectionFlag int
+
+// Irregularities that an inspection may find.
+const (
+	// ClaimedBeforeClearance is set when a cargo crossing a border has been
+	// claimed without having cleared customs.
+	ClaimedBeforeClearance InspectionFlag = 1 << iota
+
+	// Overdue is set when a cargo has missed, or is expected to miss, its
+	// arrival deadline by more than the grace period of the service.
+	Overdue
+)
+
+// InspectionResult is the conclusion of inspecting a cargo.
+type InspectionResult struct {
+	Misdirected           bool
+	UnloadedAtDestination bool
+	LastKnownLocation     shipping.UNLocode
+	Flags                 InspectionFlag
+}
+
+// Has returns whether the inspection found the given irregularity.
+func (r InspectionResult) Has(f InspectionFlag) bool {
+	return r.Flags&f != 0
 }
 
 // Service provides cargo inspection operations.
@@ -16,38 +88,187 @@ type Service interface {
 	// InspectCargo inspects cargo and send relevant notifications to
 	// interested parties, for example if a cargo has been misdirected, or
 	// unloaded at the final destination.
-	InspectCargo(id shipping.TrackingID)
+	InspectCargo(ctx context.Context, id shipping.TrackingID)
+
+	// InspectCargoResult inspects cargo like InspectCargo, and returns the
+	// conclusion of the inspection.
+	InspectCargoResult(ctx context.Context, id shipping.TrackingID) (InspectionResult, error)
+
+	// InspectCargos inspects each of the given cargos, and returns the error
+	// of every inspection that failed, keyed by tracking ID. A failure does
+	// not stop the remaining cargos from being inspected.
+	InspectCargos(ctx context.Context, ids []shipping.TrackingID) map[shipping.TrackingID]error
+
+	// PreviewEventImpact returns the delivery of the cargo of the candidate
+	// event as it is, and as it would be if the event was registered.
+	// Nothing is stored, and no one is notified. A candidate without a
+	// completion time is previewed as completed now. Like a registration, a
+	// candidate needs a tracking ID, a type and a location, and a load or an
+	// unload needs a voyage.
+	PreviewEventImpact(ctx context.Context, candidate shipping.HandlingEvent) (before, after shipping.Delivery, err error)
+}
+
+// Option configures optional dependencies of the service.
+type Option func(*service)
+
+// WithPolicy makes the service inspect cargos according to p, instead of
+// DefaultPolicy.
+func WithPolicy(p InspectionPolicy) Option {
+	return func(s *service) {
+		s.policy = p
+	}
+}
+
+// WithGracePeriod makes the service consider a cargo overdue only once it
+// misses its arrival deadline by more than d. Without it, a cargo is overdue
+// as soon as it misses its deadline.
+func WithGracePeriod(d time.Duration) Option {
+	return func(s *service) {
+		s.grace = d
+	}
+}
+
+// WithClock makes the service tell whether cargos are overdue by the given
+// clock, instead of shipping.SystemClock.
+func WithClock(c shipping.Clock) Option {
+	return func(s *service) {
+		s.clock = c
+	}
 }
 
 type service struct {
 	cargos  shipping.CargoRepository
 	events  shipping.HandlingEventRepository
 	handler EventHandler
+	policy  InspectionPolicy
+	clock   shipping.Clock
+	grace   time.Duration
+}
+
+func (s *service) InspectCargo(ctx context.Context, id shipping.TrackingID) {
+	s.inspect(ctx, id)
+}
+
+func (s *service) InspectCargoResult(ctx context.Context, id shipping.TrackingID) (InspectionResult, error) {
+	return s.inspect(ctx, id)
+}
+
+func (s *service) InspectCargos(ctx context.Context, ids []shipping.TrackingID) map[shipping.TrackingID]error {
+	errs := make(map[shipping.TrackingID]error)
+	for _, id := range ids {
+		if _, err := s.inspect(ctx, id); err != nil {
+			errs[id] = err
+		}
+	}
+	return errs
+}
+
+func (s *service) PreviewEventImpact(ctx context.Context, candidate shipping.HandlingEvent) (shipping.Delivery, shipping.Delivery, error) {
+	if candidate.TrackingID == "" || candidate.Activity.Location == "" || candidate.Activity.Type == shipping.NotHandled {
+		return shipping.Delivery{}, shipping.Delivery{}, shipping.ErrInvalidArgument
+	}
+	if t := candidate.Activity.Type; (t == shipping.Load || t == shipping.Unload) && candidate.Activity.VoyageNumber == "" {
+		return shipping.Delivery{}, shipping.Delivery{}, shipping.ErrInvalidArgument
+	}
+
+	if candidate.CompletionTime.IsZero() {
+		candidate.CompletionTime = s.clock.Now()
+	}
+
+	c, err := s.cargos.Find(ctx, candidate.TrackingID)
+	if err != nil {
+		return shipping.Delivery{}, shipping.Delivery{}, err
+	}
+
+	h := s.events.QueryHandlingHistory(ctx, candidate.TrackingID)
+
+	// The deliveries are derived the way registration derives them, on
+	// copies of the cargo, so that a backdated candidate takes its place in
+	// the history by completion time.
+	derive := func(history shipping.HandlingHistory) shipping.Delivery {
+		cp := *c
+		cp.StatusChanges = nil
+		cp.DeriveDeliveryProgress(history)
+		return cp.Delivery
+	}
+
+	events := append([]shipping.HandlingEvent(nil), h.HandlingEvents...)
+
+	return derive(h), derive(shipping.HandlingHistory{HandlingEvents: append(events, candidate)}), nil
 }
 
 // TODO: Should be transactional
-func (s *service) InspectCargo(id shipping.TrackingID) {
-	c, err := s.cargos.Find(id)
+func (s *service) inspect(ctx context.Context, id shipping.TrackingID) (InspectionResult, error) {
+	c, err := s.cargos.Find(ctx, id)
 	if err != nil {
-		return
+		return InspectionResult{}, err
 	}
 
-	h := s.events.QueryHandlingHistory(id)
+	h := s.events.QueryHandlingHistory(ctx, id)
+
+	now := s.clock.Now()
+
+	var (
+		wasArrived = s.policy.IsArrived(c)
+		wasOverdue = c.IsOverdueWithGrace(now, s.grace)
+	)
 
 	c.DeriveDeliveryProgress(h)
 
-	if c.Delivery.IsMisdirected {
-		s.handler.CargoWasMisdirected(c)
+	var (
+		misdirected = s.policy.IsMisdirected(c)
+		arrived     = s.policy.IsArrived(c)
+		overdue     = c.IsOverdueWithGrace(now, s.grace)
+	)
+
+	// Handlers are only notified of a delivery that has been stored, so
+	// that a rejected write does not announce a change that never happened.
+	if err := s.cargos.Store(ctx, c); err != nil {
+		return InspectionResult{}, err
 	}
 
-	if c.Delivery.IsUnloadedAtDestination {
-		s.handler.CargoHasArrived(c)
+	if misdirected {
+		s.handler.CargoWasMisdirected(ctx, c.TrackingID, c.Delivery.LastKnownLocation)
 	}
 
-	s.cargos.Store(c)
+	// Only notify on arrival, not on every inspection of an arrived cargo.
+	if arrived && !wasArrived {
+		s.handler.CargoHasArrived(ctx, c.TrackingID)
+	}
+
+	// Likewise, only notify when the new delivery makes the cargo overdue. A
+	// cargo that goes overdue as time passes is left to the Scheduler.
+	if oh, ok := s.handler.(OverdueHandler); ok && overdue && !wasOverdue {
+		oh.CargoIsOverdue(ctx, c.TrackingID, c.RouteSpecification.ArrivalDeadline)
+	}
+
+	var flags InspectionFlag
+	if c.Delivery.TransportStatus == shipping.Claimed && c.Delivery.CustomsStatus != shipping.Cleared && c.CrossesBorder() {
+		flags |= ClaimedBeforeClearance
+	}
+	if overdue {
+		flags |= Overdue
+	}
+
+	return InspectionResult{
+		Misdirected:           misdirected,
+		UnloadedAtDestination: arrived,
+		LastKnownLocation:     c.Delivery.LastKnownLocation,
+		Flags:                 flags,
+	}, nil
 }
 
 // NewService creates a inspection service with necessary dependencies.
-func NewService(cargos shipping.CargoRepository, events shipping.HandlingEventRepository, handler EventHandler) Service {
-	return &service{cargos, events, handler}
+func NewService(cargos shipping.CargoRepository, events shipping.HandlingEventRepository, handler EventHandler, opts ...Option) Service {
+	s := &service{
+		cargos:  cargos,
+		events:  events,
+		handler: handler,
+		policy:  DefaultPolicy,
+		clock:   shipping.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
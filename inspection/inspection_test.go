@@ -1,7 +1,10 @@
 package inspection
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	shipping "github.com/marcusolsson/goddd"
 )
@@ -10,15 +13,17 @@ type stubEventHandler struct {
 	events []interface{}
 }
 
-func (h *stubEventHandler) CargoWasMisdirected(c *shipping.Cargo) {
-	h.events = append(h.events, c)
+func (h *stubEventHandler) CargoWasMisdirected(ctx context.Context, id shipping.TrackingID, lastKnownLocation shipping.UNLocode) {
+	h.events = append(h.events, id)
 }
 
-func (h *stubEventHandler) CargoHasArrived(c *shipping.Cargo) {
-	h.events = append(h.events, c)
+func (h *stubEventHandler) CargoHasArrived(ctx context.Context, id shipping.TrackingID) {
+	h.events = append(h.events, id)
 }
 
 func TestInspectMisdirectedCargo(t *testing.T) {
+	ctx := context.Background()
+
 	var cargos mockCargoRepository
 
 	events := mockHandlingEventRepository{
@@ -42,7 +47,7 @@ func TestInspectMisdirectedCargo(t *testing.T) {
 		{VoyageNumber: voyage, LoadLocation: shipping.AUMEL, UnloadLocation: shipping.CNHKG},
 	}})
 
-	if err := cargos.Store(c); err != nil {
+	if err := cargos.Store(ctx, c); err != nil {
 		t.Fatal(err)
 	}
 
@@ -54,13 +59,13 @@ func TestInspectMisdirectedCargo(t *testing.T) {
 		t.Errorf("no events should be handled")
 	}
 
-	s.InspectCargo(id)
+	s.InspectCargo(ctx, id)
 
 	if len(handler.events) != 1 {
 		t.Errorf("1 event should be handled")
 	}
 
-	s.InspectCargo("no_such_id")
+	s.InspectCargo(ctx, "no_such_id")
 
 	// no events was published
 	if len(handler.events) != 1 {
@@ -68,7 +73,197 @@ func TestInspectMisdirectedCargo(t *testing.T) {
 	}
 }
 
+// conflictingCargoRepository rejects every write as a concurrent
+// modification.
+type conflictingCargoRepository struct {
+	mockCargoRepository
+}
+
+func (r *conflictingCargoRepository) Store(ctx context.Context, c *shipping.Cargo) error {
+	return shipping.ErrConcurrentModification
+}
+
+func TestInspectCargo_StoreFails(t *testing.T) {
+	ctx := context.Background()
+
+	id := shipping.TrackingID("ABC123")
+	c := shipping.NewCargo(id, shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.CNHKG,
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "001A", LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+	}})
+
+	cargos := conflictingCargoRepository{mockCargoRepository{cargo: c}}
+
+	events := mockHandlingEventRepository{
+		events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+	}
+	storeEvent(&events, id, "001A", shipping.Receive, shipping.SESTO)
+	storeEvent(&events, id, "001A", shipping.Load, shipping.SESTO)
+	storeEvent(&events, id, "001A", shipping.Unload, shipping.USNYC)
+
+	handler := stubEventHandler{make([]interface{}, 0)}
+
+	s := NewService(&cargos, &events, &handler)
+
+	if _, err := s.InspectCargoResult(ctx, id); err != shipping.ErrConcurrentModification {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrConcurrentModification)
+	}
+	if len(handler.events) != 0 {
+		t.Errorf("len(handler.events) = %d; want = %d", len(handler.events), 0)
+	}
+}
+
+type misdirection struct {
+	id       shipping.TrackingID
+	location shipping.UNLocode
+}
+
+// chanMisdirectionHandler sends every misdirection on a channel.
+type chanMisdirectionHandler struct {
+	stubEventHandler
+	misdirected chan misdirection
+}
+
+func (h *chanMisdirectionHandler) CargoWasMisdirected(ctx context.Context, id shipping.TrackingID, lastKnownLocation shipping.UNLocode) {
+	h.misdirected <- misdirection{id, lastKnownLocation}
+}
+
+func TestInspectCargo_MisdirectionAlert(t *testing.T) {
+	ctx := context.Background()
+
+	id := shipping.TrackingID("ABC123")
+	voyage := shipping.VoyageNumber("001A")
+
+	var tests = []struct {
+		name   string
+		unload shipping.UNLocode
+		want   []misdirection
+	}{
+		{"on route", shipping.AUMEL, nil},
+		{"misdirected", shipping.USNYC, []misdirection{{id, shipping.USNYC}}},
+	}
+
+	for _, tt := range tests {
+		var cargos mockCargoRepository
+
+		events := mockHandlingEventRepository{
+			events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+		}
+
+		handler := &chanMisdirectionHandler{misdirected: make(chan misdirection, 1)}
+
+		s := NewService(&cargos, &events, handler)
+
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:      shipping.SESTO,
+			Destination: shipping.CNHKG,
+		})
+		c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+			{VoyageNumber: voyage, LoadLocation: shipping.SESTO, UnloadLocation: shipping.AUMEL},
+			{VoyageNumber: voyage, LoadLocation: shipping.AUMEL, UnloadLocation: shipping.CNHKG},
+		}})
+
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+
+		storeEvent(&events, id, voyage, shipping.Receive, shipping.SESTO)
+		storeEvent(&events, id, voyage, shipping.Load, shipping.SESTO)
+		storeEvent(&events, id, voyage, shipping.Unload, tt.unload)
+
+		s.InspectCargo(ctx, id)
+		close(handler.misdirected)
+
+		var got []misdirection
+		for m := range handler.misdirected {
+			got = append(got, m)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: misdirected = %v; want = %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// invertedPolicy considers cargos misdirected when the default policy does
+// not, and vice versa.
+type invertedPolicy struct {
+	InspectionPolicy
+}
+
+func (p invertedPolicy) IsMisdirected(c *shipping.Cargo) bool {
+	return !p.InspectionPolicy.IsMisdirected(c)
+}
+
+func TestInspectCargo_CustomPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	id := shipping.TrackingID("ABC123")
+	voyage := shipping.VoyageNumber("001A")
+
+	var tests = []struct {
+		name   string
+		unload shipping.UNLocode
+		want   []misdirection
+	}{
+		{"on route", shipping.AUMEL, []misdirection{{id, shipping.AUMEL}}},
+		{"misdirected", shipping.USNYC, nil},
+	}
+
+	for _, tt := range tests {
+		var cargos mockCargoRepository
+
+		events := mockHandlingEventRepository{
+			events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+		}
+
+		handler := &chanMisdirectionHandler{misdirected: make(chan misdirection, 1)}
+
+		s := NewService(&cargos, &events, handler, WithPolicy(invertedPolicy{DefaultPolicy}))
+
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:      shipping.SESTO,
+			Destination: shipping.CNHKG,
+		})
+		c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+			{VoyageNumber: voyage, LoadLocation: shipping.SESTO, UnloadLocation: shipping.AUMEL},
+			{VoyageNumber: voyage, LoadLocation: shipping.AUMEL, UnloadLocation: shipping.CNHKG},
+		}})
+
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+
+		storeEvent(&events, id, voyage, shipping.Receive, shipping.SESTO)
+		storeEvent(&events, id, voyage, shipping.Load, shipping.SESTO)
+		storeEvent(&events, id, voyage, shipping.Unload, tt.unload)
+
+		res, err := s.InspectCargoResult(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		close(handler.misdirected)
+
+		var got []misdirection
+		for m := range handler.misdirected {
+			got = append(got, m)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: misdirected = %v; want = %v", tt.name, got, tt.want)
+		}
+		if res.Misdirected != (tt.want != nil) {
+			t.Errorf("%s: Misdirected = %v; want = %v", tt.name, res.Misdirected, tt.want != nil)
+		}
+	}
+}
+
 func TestInspectUnloadedCargo(t *testing.T) {
+	ctx := context.Background()
+
 	var cargos mockCargoRepository
 
 	events := mockHandlingEventRepository{
@@ -81,6 +276,8 @@ func TestInspectUnloadedCargo(t *testing.T) {
 		cargos:  &cargos,
 		events:  &events,
 		handler: &handler,
+		policy:  DefaultPolicy,
+		clock:   shipping.SystemClock,
 	}
 
 	id := shipping.TrackingID("ABC123")
@@ -96,7 +293,7 @@ func TestInspectUnloadedCargo(t *testing.T) {
 		{VoyageNumber: voyage, LoadLocation: shipping.AUMEL, UnloadLocation: shipping.CNHKG},
 	}})
 
-	cargos.Store(unloadedCargo)
+	cargos.Store(ctx, unloadedCargo)
 
 	storeEvent(&events, id, voyage, shipping.Receive, shipping.SESTO)
 	storeEvent(&events, id, voyage, shipping.Load, shipping.SESTO)
@@ -108,14 +305,381 @@ func TestInspectUnloadedCargo(t *testing.T) {
 		t.Errorf("len(handler.events) = %d; want = %d", len(handler.events), 0)
 	}
 
-	s.InspectCargo(id)
+	s.InspectCargo(ctx, id)
 
 	if len(handler.events) != 1 {
 		t.Errorf("len(handler.events) = %d; want = %d", len(handler.events), 1)
 	}
+
+	// An arrived cargo is not notified again.
+	s.InspectCargo(ctx, id)
+
+	if len(handler.events) != 1 {
+		t.Errorf("len(handler.events) = %d; want = %d", len(handler.events), 1)
+	}
+}
+
+func TestPreviewEventImpact(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	events := mockHandlingEventRepository{
+		events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+	}
+
+	handler := stubEventHandler{make([]interface{}, 0)}
+
+	now := time.Date(2015, time.November, 10, 12, 0, 0, 0, time.UTC)
+
+	s := NewService(&cargos, &events, &handler, WithClock(shipping.ClockFunc(func() time.Time { return now })))
+
+	id := shipping.TrackingID("ABC123")
+	c := shipping.NewCargo(id, shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.CNHKG,
+	})
+
+	var voyage shipping.VoyageNumber = "001A"
+
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: voyage, LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+	}})
+
+	cargos.Store(ctx, c)
+
+	storeEvent(&events, id, voyage, shipping.Receive, shipping.SESTO)
+	storeEvent(&events, id, voyage, shipping.Load, shipping.SESTO)
+
+	unload := shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.CNHKG, VoyageNumber: voyage}
+
+	before, after, err := s.PreviewEventImpact(ctx, shipping.HandlingEvent{
+		TrackingID:     id,
+		Activity:       unload,
+		CompletionTime: now.Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before.IsUnloadedAtDestination {
+		t.Errorf("before.IsUnloadedAtDestination = %v; want = %v", before.IsUnloadedAtDestination, false)
+	}
+	if before.TransportStatus != shipping.OnboardCarrier {
+		t.Errorf("before.TransportStatus = %v; want = %v", before.TransportStatus, shipping.OnboardCarrier)
+	}
+	if !after.IsUnloadedAtDestination {
+		t.Errorf("after.IsUnloadedAtDestination = %v; want = %v", after.IsUnloadedAtDestination, true)
+	}
+	if after.TransportStatus != shipping.InPort {
+		t.Errorf("after.TransportStatus = %v; want = %v", after.TransportStatus, shipping.InPort)
+	}
+
+	if got, want := after.LastEvent.CompletionTime, now.Add(-time.Hour); !got.Equal(want) {
+		t.Errorf("after.LastEvent.CompletionTime = %v; want = %v", got, want)
+	}
+
+	if len(events.events[id]) != 2 {
+		t.Errorf("len(events) = %d; want = %d", len(events.events[id]), 2)
+	}
+	if len(handler.events) != 0 {
+		t.Errorf("len(handler.events) = %d; want = %d", len(handler.events), 0)
+	}
+
+	// A candidate without a completion time is completed now.
+	_, after, err = s.PreviewEventImpact(ctx, shipping.HandlingEvent{TrackingID: id, Activity: unload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := after.LastEvent.CompletionTime; !got.Equal(now) {
+		t.Errorf("after.LastEvent.CompletionTime = %v; want = %v", got, now)
+	}
+
+	var invalid = []shipping.HandlingActivity{
+		{},
+		{Type: shipping.Unload, Location: shipping.CNHKG},
+		{Type: shipping.Receive},
+	}
+	for _, a := range invalid {
+		if _, _, err := s.PreviewEventImpact(ctx, shipping.HandlingEvent{TrackingID: id, Activity: a}); err != shipping.ErrInvalidArgument {
+			t.Errorf("%v: err = %v; want = %v", a, err, shipping.ErrInvalidArgument)
+		}
+	}
+
+	if _, _, err := s.PreviewEventImpact(ctx, shipping.HandlingEvent{TrackingID: "no_such_id", Activity: unload}); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+}
+
+func TestPreviewEventImpact_Backdated(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	events := mockHandlingEventRepository{
+		events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+	}
+
+	s := NewService(&cargos, &events, &stubEventHandler{})
+
+	id := shipping.TrackingID("ABC123")
+	c := shipping.NewCargo(id, shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.CNHKG,
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.USNYC},
+		{VoyageNumber: "V200", LoadLocation: shipping.USNYC, UnloadLocation: shipping.CNHKG},
+	}})
+	cargos.Store(ctx, c)
+
+	t0 := time.Date(2015, time.November, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, a := range []shipping.HandlingActivity{
+		{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"},
+		{Type: shipping.Unload, Location: shipping.USNYC, VoyageNumber: "V100"},
+		{Type: shipping.Load, Location: shipping.USNYC, VoyageNumber: "V200"},
+	} {
+		events.Store(ctx, shipping.HandlingEvent{TrackingID: id, Activity: a, CompletionTime: t0.AddDate(0, 0, i+1)})
+	}
+
+	// The receipt is reported late, completed before the first load.
+	_, after, err := s.PreviewEventImpact(ctx, shipping.HandlingEvent{
+		TrackingID:     id,
+		Activity:       shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO},
+		CompletionTime: t0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after.TransportStatus != shipping.OnboardCarrier {
+		t.Errorf("after.TransportStatus = %v; want = %v", after.TransportStatus, shipping.OnboardCarrier)
+	}
+	if after.CurrentVoyage != "V200" {
+		t.Errorf("after.CurrentVoyage = %v; want = %v", after.CurrentVoyage, "V200")
+	}
+	if after.LastKnownLocation != shipping.USNYC {
+		t.Errorf("after.LastKnownLocation = %v; want = %v", after.LastKnownLocation, shipping.USNYC)
+	}
+	if len(c.StatusChanges) != 0 {
+		t.Errorf("len(StatusChanges) = %d; want = %d", len(c.StatusChanges), 0)
+	}
+}
+
+type overdueRecorder struct {
+	stubEventHandler
+	overdue []shipping.TrackingID
+}
+
+func (h *overdueRecorder) CargoIsOverdue(ctx context.Context, id shipping.TrackingID, deadline time.Time) {
+	h.overdue = append(h.overdue, id)
+}
+
+func TestInspectCargoResult_GracePeriod(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name  string
+		grace time.Duration
+		now   time.Time
+		want  bool
+	}{
+		{"at deadline", 6 * time.Hour, deadline, false},
+		{"within grace", 6 * time.Hour, deadline.Add(6 * time.Hour), false},
+		{"beyond grace", 6 * time.Hour, deadline.Add(6*time.Hour + time.Minute), true},
+		{"no grace", 0, deadline.Add(time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		var cargos mockCargoRepository
+
+		events := mockHandlingEventRepository{
+			events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+		}
+
+		var handler overdueRecorder
+
+		s := NewService(&cargos, &events, &handler,
+			WithGracePeriod(tt.grace),
+			WithClock(shipping.ClockFunc(func() time.Time { return tt.now })),
+		)
+
+		id := shipping.TrackingID("ABC123")
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:          shipping.SESTO,
+			Destination:     shipping.CNHKG,
+			ArrivalDeadline: deadline,
+		})
+
+		// The cargo was last seen in time, at its destination.
+		c.Delivery.IsUnloadedAtDestination = true
+
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+
+		storeEvent(&events, id, "", shipping.Receive, shipping.SESTO)
+
+		res, err := s.InspectCargoResult(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.Has(Overdue); got != tt.want {
+			t.Errorf("%s: Has(Overdue) = %v; want = %v", tt.name, got, tt.want)
+		}
+
+		// Inspecting an overdue cargo again does not notify again.
+		if _, err := s.InspectCargoResult(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+
+		want := 0
+		if tt.want {
+			want = 1
+		}
+		if len(handler.overdue) != want {
+			t.Errorf("%s: len(handler.overdue) = %d; want = %d", tt.name, len(handler.overdue), want)
+		}
+	}
+}
+
+func TestInspectCargos(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	events := mockHandlingEventRepository{
+		events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+	}
+
+	handler := stubEventHandler{make([]interface{}, 0)}
+
+	s := NewService(&cargos, &events, &handler)
+
+	id := shipping.TrackingID("ABC123")
+	c := shipping.NewCargo(id, shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.CNHKG,
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "001A", LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+	}})
+
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	storeEvent(&events, id, "001A", shipping.Receive, shipping.SESTO)
+	storeEvent(&events, id, "001A", shipping.Load, shipping.SESTO)
+	storeEvent(&events, id, "001A", shipping.Unload, shipping.USNYC)
+
+	errs := s.InspectCargos(ctx, []shipping.TrackingID{"no_such_id", id, "other_id"})
+
+	if len(errs) != 2 {
+		t.Errorf("len(errs) = %d; want = %d", len(errs), 2)
+	}
+	for _, unknown := range []shipping.TrackingID{"no_such_id", "other_id"} {
+		if errs[unknown] != shipping.ErrUnknownCargo {
+			t.Errorf("errs[%s] = %v; want = %v", unknown, errs[unknown], shipping.ErrUnknownCargo)
+		}
+	}
+	if err, ok := errs[id]; ok {
+		t.Errorf("errs[%s] = %v; want none", id, err)
+	}
+
+	if len(handler.events) != 1 {
+		t.Errorf("len(handler.events) = %d; want = %d", len(handler.events), 1)
+	}
+}
+
+func TestInspectCargoResult(t *testing.T) {
+	ctx := context.Background()
+
+	id := shipping.TrackingID("ABC123")
+	voyage := shipping.VoyageNumber("001A")
+
+	var tests = []struct {
+		name   string
+		events []shipping.HandlingActivity
+		want   InspectionResult
+	}{
+		{
+			name: "in transit",
+			events: []shipping.HandlingActivity{
+				{Type: shipping.Receive, Location: shipping.SESTO},
+				{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: voyage},
+			},
+			want: InspectionResult{LastKnownLocation: shipping.SESTO},
+		},
+		{
+			name: "misdirected",
+			events: []shipping.HandlingActivity{
+				{Type: shipping.Receive, Location: shipping.SESTO},
+				{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: voyage},
+				{Type: shipping.Unload, Location: shipping.USNYC, VoyageNumber: voyage},
+			},
+			want: InspectionResult{Misdirected: true, LastKnownLocation: shipping.USNYC},
+		},
+		{
+			name: "arrived",
+			events: []shipping.HandlingActivity{
+				{Type: shipping.Receive, Location: shipping.SESTO},
+				{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: voyage},
+				{Type: shipping.Unload, Location: shipping.CNHKG, VoyageNumber: voyage},
+			},
+			want: InspectionResult{UnloadedAtDestination: true, LastKnownLocation: shipping.CNHKG},
+		},
+	}
+
+	for _, tt := range tests {
+		var cargos mockCargoRepository
+
+		events := mockHandlingEventRepository{
+			events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+		}
+
+		s := NewService(&cargos, &events, &stubEventHandler{})
+
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:      shipping.SESTO,
+			Destination: shipping.CNHKG,
+		})
+		c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+			{VoyageNumber: voyage, LoadLocation: shipping.SESTO, UnloadLocation: shipping.CNHKG},
+		}})
+
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, a := range tt.events {
+			storeEvent(&events, id, a.VoyageNumber, a.Type, a.Location)
+		}
+
+		got, err := s.InspectCargoResult(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: result = %+v; want = %+v", tt.name, got, tt.want)
+		}
+	}
+
+	var cargos mockCargoRepository
+	s := NewService(&cargos, &mockHandlingEventRepository{}, &stubEventHandler{})
+
+	if _, err := s.InspectCargoResult(ctx, "no_such_id"); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
 }
 
 func storeEvent(r shipping.HandlingEventRepository, id shipping.TrackingID, voyageNumber shipping.VoyageNumber, typ shipping.HandlingEventType, loc shipping.UNLocode) {
+	ctx := context.Background()
+
 	e := shipping.HandlingEvent{
 		TrackingID: id,
 		Activity: shipping.HandlingActivity{
@@ -125,40 +689,253 @@ func storeEvent(r shipping.HandlingEventRepository, id shipping.TrackingID, voya
 		},
 	}
 
-	r.Store(e)
+	r.Store(ctx, e)
 }
 
 type mockCargoRepository struct {
 	cargo *shipping.Cargo
 }
 
-func (r *mockCargoRepository) Store(c *shipping.Cargo) error {
+func (r *mockCargoRepository) Store(ctx context.Context, c *shipping.Cargo) error {
 	r.cargo = c
 	return nil
 }
 
-func (r *mockCargoRepository) Find(id shipping.TrackingID) (*shipping.Cargo, error) {
-	if r.cargo != nil {
+func (r *mockCargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
+	if r.cargo != nil && r.cargo.TrackingID == id {
 		return r.cargo, nil
 	}
 	return nil, shipping.ErrUnknownCargo
 }
 
-func (r *mockCargoRepository) FindAll() []*shipping.Cargo {
+func (r *mockCargoRepository) FindAll(ctx context.Context) []*shipping.Cargo {
 	return []*shipping.Cargo{r.cargo}
 }
 
+func (r *mockCargoRepository) FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*shipping.Cargo {
+	if r.cargo != nil && !r.cargo.Cancelled && r.cargo.IsAwaitingClaimBeyond(d, now) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindOverdue(ctx context.Context, now time.Time) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.IsOverdue(now) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAllPaged(ctx context.Context, offset, limit int) ([]*shipping.Cargo, int, error) {
+	return []*shipping.Cargo{r.cargo}, 1, nil
+}
+
+func (r *mockCargoRepository) FindByRoutingStatus(ctx context.Context, status shipping.RoutingStatus) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.Delivery.RoutingStatus == status {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByExternalRef(ctx context.Context, ref string) (*shipping.Cargo, error) {
+	if r.cargo != nil && ref != "" && r.cargo.ExternalRef == ref {
+		return r.cargo, nil
+	}
+	return nil, shipping.ErrUnknownCargo
+}
+
+func (r *mockCargoRepository) Watch(ctx context.Context) (<-chan shipping.CargoChange, error) {
+	return nil, shipping.ErrWatchNotSupported
+}
+
+func (r *mockCargoRepository) FindByServiceLevel(ctx context.Context, level shipping.ServiceLevel) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.ServiceLevel == level {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByTag(ctx context.Context, key, value string) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.Tags[key] == value && value != "" {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByVoyage(ctx context.Context, number shipping.VoyageNumber) []*shipping.Cargo {
+	if r.cargo != nil && !r.cargo.Cancelled && r.cargo.Itinerary.IsOnVoyage(number) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAtLocation(ctx context.Context, loc shipping.UNLocode) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.IsAt(loc) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAllIncludingArchived(ctx context.Context) []*shipping.Cargo {
+	return []*shipping.Cargo{r.cargo}
+}
+
+func (r *mockCargoRepository) FindChildren(ctx context.Context, parent shipping.TrackingID) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.ParentID == parent {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByDestination(ctx context.Context, dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.RouteSpecification.Destination == dest && (!activeOnly || r.cargo.IsActive()) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) Archive(ctx context.Context, id shipping.TrackingID) error {
+	if r.cargo == nil || r.cargo.TrackingID != id {
+		return shipping.ErrUnknownCargo
+	}
+	return r.cargo.Archive()
+}
+
 type mockHandlingEventRepository struct {
 	events map[shipping.TrackingID][]shipping.HandlingEvent
 }
 
-func (r *mockHandlingEventRepository) Store(e shipping.HandlingEvent) {
+func (r *mockHandlingEventRepository) Store(ctx context.Context, e shipping.HandlingEvent) error {
 	if _, ok := r.events[e.TrackingID]; !ok {
 		r.events[e.TrackingID] = make([]shipping.HandlingEvent, 0)
 	}
 	r.events[e.TrackingID] = append(r.events[e.TrackingID], e)
+	return nil
 }
 
-func (r *mockHandlingEventRepository) QueryHandlingHistory(id shipping.TrackingID) shipping.HandlingHistory {
+func (r *mockHandlingEventRepository) QueryHandlingHistory(ctx context.Context, id shipping.TrackingID) shipping.HandlingHistory {
 	return shipping.HandlingHistory{HandlingEvents: r.events[id]}
 }
+
+func (r *mockHandlingEventRepository) FindByIdempotencyKey(ctx context.Context, key string) (shipping.HandlingEvent, error) {
+	return shipping.HandlingEvent{}, shipping.ErrUnknownHandlingEvent
+}
+
+func (r *mockHandlingEventRepository) QueryHandlingHistoryBetween(ctx context.Context, id shipping.TrackingID, from, to time.Time) shipping.HandlingHistory {
+	var events []shipping.HandlingEvent
+	for _, e := range r.events[id] {
+		if !e.CompletionTime.Before(from) && !e.CompletionTime.After(to) {
+			events = append(events, e)
+		}
+	}
+	return shipping.HandlingHistory{HandlingEvents: events}
+}
+
+func (r *mockHandlingEventRepository) Supersede(ctx context.Context, key shipping.HandlingEventKey) error {
+	for i, e := range r.events[key.TrackingID] {
+		if key.Matches(e) {
+			r.events[key.TrackingID][i].Superseded = true
+			return nil
+		}
+	}
+	return shipping.ErrUnknownHandlingEvent
+}
+
+func (r *mockHandlingEventRepository) Confirm(ctx context.Context, key shipping.HandlingEventKey) error {
+	for i, e := range r.events[key.TrackingID] {
+		if key.Matches(e) {
+			r.events[key.TrackingID][i].Planned = false
+			return nil
+		}
+	}
+	return shipping.ErrUnknownHandlingEvent
+}
+
+func (r *mockHandlingEventRepository) Stats(ctx context.Context, from, to time.Time) (shipping.HandlingStats, error) {
+	var events []shipping.HandlingEvent
+	for id := range r.events {
+		events = append(events, r.QueryHandlingHistoryBetween(ctx, id, from, to).HandlingEvents...)
+	}
+	return shipping.CountHandlingEvents(events), nil
+}
+
+func (r *mockHandlingEventRepository) Search(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	var events []shipping.HandlingEvent
+	for _, history := range r.events {
+		for _, e := range history {
+			if criteria.Matches(e) {
+				events = append(events, e)
+			}
+		}
+	}
+	return events, len(events), nil
+}
+
+func (r *mockHandlingEventRepository) QueryHandlingEventsSince(ctx context.Context, since time.Time) []shipping.HandlingEvent {
+	var events []shipping.HandlingEvent
+	for _, history := range r.events {
+		for _, e := range history {
+			if e.CompletionTime.After(since) {
+				events = append(events, e)
+			}
+		}
+	}
+	return events
+}
+
+func (r *mockHandlingEventRepository) QuerySince(ctx context.Context, seq uint64) ([]shipping.HandlingEvent, error) {
+	return nil, nil
+}
+
+func TestInspectCargoResult_ClaimedBeforeClearance(t *testing.T) {
+	ctx := context.Background()
+
+	var tests = []struct {
+		name        string
+		destination shipping.UNLocode
+		history     []shipping.HandlingEventType
+		cleared     bool
+		want        bool
+	}{
+		{"cleared", shipping.CNHKG, []shipping.HandlingEventType{shipping.Receive, shipping.Customs, shipping.Claim}, true, false},
+		{"claimed in customs", shipping.CNHKG, []shipping.HandlingEventType{shipping.Receive, shipping.Customs, shipping.Claim}, false, true},
+		{"not cleared", shipping.CNHKG, []shipping.HandlingEventType{shipping.Receive, shipping.Claim}, false, true},
+		{"in customs", shipping.CNHKG, []shipping.HandlingEventType{shipping.Receive, shipping.Customs}, false, false},
+		{"domestic", "SEGOT", []shipping.HandlingEventType{shipping.Receive, shipping.Claim}, false, false},
+	}
+
+	for _, tt := range tests {
+		var cargos mockCargoRepository
+
+		events := mockHandlingEventRepository{
+			events: make(map[shipping.TrackingID][]shipping.HandlingEvent),
+		}
+
+		s := NewService(&cargos, &events, &stubEventHandler{})
+
+		c := shipping.NewCargo("ABC123", shipping.RouteSpecification{
+			Origin:      shipping.SESTO,
+			Destination: tt.destination,
+		})
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, typ := range tt.history {
+			events.Store(ctx, shipping.HandlingEvent{
+				TrackingID:     "ABC123",
+				Activity:       shipping.HandlingActivity{Type: typ, Location: shipping.SESTO},
+				CustomsCleared: typ == shipping.Customs && tt.cleared,
+			})
+		}
+
+		res, err := s.InspectCargoResult(ctx, "ABC123")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := res.Has(ClaimedBeforeClearance); got != tt.want {
+			t.Errorf("%s: Has(ClaimedBeforeClearance) = %v; want = %v", tt.name, got, tt.want)
+		}
+	}
+}
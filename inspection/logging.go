@@ -0,0 +1,46 @@
+package inspection
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type loggingEventHandler struct {
+	logger log.Logger
+}
+
+// NewLoggingEventHandler returns an EventHandler that logs every inspection
+// event.
+func NewLoggingEventHandler(logger log.Logger) EventHandler {
+	return &loggingEventHandler{logger}
+}
+
+func (h *loggingEventHandler) CargoWasMisdirected(ctx context.Context, id shipping.TrackingID, lastKnownLocation shipping.UNLocode) {
+	h.logger.Log(
+		"event", "cargo_misdirected",
+		"tracking_id", id,
+		"last_known_location", lastKnownLocation,
+		"correlation_id", shipping.CorrelationFromContext(ctx),
+	)
+}
+
+func (h *loggingEventHandler) CargoIsOverdue(ctx context.Context, id shipping.TrackingID, deadline time.Time) {
+	h.logger.Log(
+		"event", "cargo_overdue",
+		"tracking_id", id,
+		"arrival_deadline", deadline,
+		"correlation_id", shipping.CorrelationFromContext(ctx),
+	)
+}
+
+func (h *loggingEventHandler) CargoHasArrived(ctx context.Context, id shipping.TrackingID) {
+	h.logger.Log(
+		"event", "cargo_arrived",
+		"tracking_id", id,
+		"correlation_id", shipping.CorrelationFromContext(ctx),
+	)
+}
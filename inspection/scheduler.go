@@ -0,0 +1,120 @@
+package inspection
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// Scheduler periodically re-inspects the cargos in transit. The delivery of a
+// cargo is inspected as the cargo is handled, but a cargo can go overdue
+// without being handled at all, as time passes. The scheduler notices.
+type Scheduler interface {
+	// Run re-inspects the cargos in transit on every interval, until the
+	// context is done.
+	Run(ctx context.Context) error
+
+	// Inspect re-inspects the cargos in transit once, and notifies the
+	// handler of the cargos that have gone overdue since the last time. A
+	// cargo is notified again only if it has been back on time in between.
+	Inspect(ctx context.Context)
+}
+
+// SchedulerOption configures optional dependencies of the scheduler.
+type SchedulerOption func(*scheduler)
+
+// WithSchedulerGracePeriod makes the scheduler consider a cargo overdue only
+// once it misses its arrival deadline by more than d, like WithGracePeriod
+// does for the service.
+func WithSchedulerGracePeriod(d time.Duration) SchedulerOption {
+	return func(s *scheduler) {
+		s.grace = d
+	}
+}
+
+// WithSchedulerClock makes the scheduler tell whether cargos are overdue by
+// the given clock, instead of shipping.SystemClock.
+func WithSchedulerClock(c shipping.Clock) SchedulerOption {
+	return func(s *scheduler) {
+		s.clock = c
+	}
+}
+
+type scheduler struct {
+	cargos   shipping.CargoRepository
+	handler  OverdueHandler
+	interval time.Duration
+	clock    shipping.Clock
+	grace    time.Duration
+
+	mtx      sync.Mutex
+	notified map[shipping.TrackingID]bool
+}
+
+// NewScheduler returns a scheduler that re-inspects the cargos in transit on
+// every interval, and notifies handler of the cargos that have gone overdue.
+func NewScheduler(cargos shipping.CargoRepository, handler OverdueHandler, interval time.Duration, opts ...SchedulerOption) Scheduler {
+	s := &scheduler{
+		cargos:   cargos,
+		handler:  handler,
+		interval: interval,
+		clock:    shipping.SystemClock,
+		notified: make(map[shipping.TrackingID]bool),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *scheduler) Run(ctx context.Context) error {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			s.Inspect(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *scheduler) Inspect(ctx context.Context) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := s.clock.Now()
+
+	overdue := make(map[shipping.TrackingID]bool)
+	for _, c := range s.cargos.FindAll(ctx) {
+		if !isInTransit(c) || !c.IsOverdueWithGrace(now, s.grace) {
+			continue
+		}
+		overdue[c.TrackingID] = true
+
+		if !s.notified[c.TrackingID] {
+			s.handler.CargoIsOverdue(ctx, c.TrackingID, c.RouteSpecification.ArrivalDeadline)
+		}
+	}
+
+	// Forget the cargos that are no longer overdue, so that they are notified
+	// should they go overdue again.
+	s.notified = overdue
+}
+
+// isInTransit returns whether the cargo has been received, but not yet
+// claimed.
+func isInTransit(c *shipping.Cargo) bool {
+	if c.Cancelled {
+		return false
+	}
+	switch c.Delivery.TransportStatus {
+	case shipping.InPort, shipping.OnboardCarrier:
+		return true
+	}
+	return false
+}
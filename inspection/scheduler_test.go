@@ -0,0 +1,92 @@
+package inspection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestScheduler_Inspect(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	var cargos mockCargoRepository
+
+	id := shipping.TrackingID("ABC123")
+	c := shipping.NewCargo(id, shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.CNHKG,
+		ArrivalDeadline: deadline,
+	})
+	c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+		{TrackingID: id, Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}},
+	}})
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		handler overdueRecorder
+		now     = deadline.Add(-time.Hour)
+	)
+
+	s := NewScheduler(&cargos, &handler, time.Minute,
+		WithSchedulerClock(shipping.ClockFunc(func() time.Time { return now })),
+	)
+
+	s.Inspect(ctx)
+	if len(handler.overdue) != 0 {
+		t.Errorf("len(handler.overdue) = %d; want = %d", len(handler.overdue), 0)
+	}
+
+	// Advance the clock past the deadline, and keep ticking.
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Hour)
+		s.Inspect(ctx)
+	}
+	if len(handler.overdue) != 1 {
+		t.Errorf("len(handler.overdue) = %d; want = %d", len(handler.overdue), 1)
+	}
+
+	// Once the cargo is on time again, it is notified should it go overdue
+	// again.
+	c.RouteSpecification.ArrivalDeadline = now.Add(time.Hour)
+	s.Inspect(ctx)
+
+	now = now.Add(2 * time.Hour)
+	s.Inspect(ctx)
+	if len(handler.overdue) != 2 {
+		t.Errorf("len(handler.overdue) = %d; want = %d", len(handler.overdue), 2)
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var cargos mockCargoRepository
+	if err := cargos.Store(ctx, shipping.NewCargo("ABC123", shipping.RouteSpecification{})); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(&cargos, &overdueRecorder{}, time.Millisecond)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Run(ctx)
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("err = %v; want = %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return when the context was done")
+	}
+}
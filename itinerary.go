@@ -1,6 +1,9 @@
 package shipping
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -11,6 +14,10 @@ type Leg struct {
 	UnloadLocation UNLocode     `json:"to"`
 	LoadTime       time.Time    `json:"load_time"`
 	UnloadTime     time.Time    `json:"unload_time"`
+
+	// Instructions optionally describe special handling that the cargo
+	// requires on the leg, such as refrigeration or hazmat placarding.
+	Instructions string `json:"instructions,omitempty"`
 }
 
 // NewLeg creates a new itinerary leg.
@@ -28,6 +35,178 @@ func NewLeg(voyageNumber VoyageNumber, loadLocation, unloadLocation UNLocode, lo
 // destination.
 type Itinerary struct {
 	Legs []Leg `json:"legs"`
+
+	// Partial is set on an itinerary that covers only the first part of the
+	// journey, from the origin of the cargo, with the rest to be routed
+	// later.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Delay returns a copy of the itinerary where the load and unload times of
+// the legs on the given voyage that have not yet been unloaded at the given
+// time are shifted by d.
+func (i Itinerary) Delay(voyageNumber VoyageNumber, d time.Duration, now time.Time) Itinerary {
+	legs := make([]Leg, len(i.Legs))
+	for j, l := range i.Legs {
+		if l.VoyageNumber == voyageNumber && l.UnloadTime.After(now) {
+			l.LoadTime = l.LoadTime.Add(d)
+			l.UnloadTime = l.UnloadTime.Add(d)
+		}
+		legs[j] = l
+	}
+	return Itinerary{Legs: legs, Partial: i.Partial}
+}
+
+// WithActualDelays returns a copy of the itinerary projected by the handling
+// history. The load and unload times of the legs that the cargo has been
+// loaded onto or unloaded from are replaced by the actual times, and the
+// delay last observed is carried forward to the planned times of the
+// remaining legs. A cargo handled ahead of plan carries no delay.
+func (i Itinerary) WithActualDelays(history HandlingHistory) Itinerary {
+	events := history.Current().HandlingEvents
+
+	// actual returns the completion time of the handling that carried out
+	// the activity, if any.
+	actual := func(a HandlingActivity) (time.Time, bool) {
+		for _, e := range events {
+			if e.Activity == a {
+				return e.CompletionTime, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	// observe shifts the planned time t by the carried delay, unless the
+	// activity has been carried out, in which case the actual time is used
+	// and the delay is updated.
+	var delay time.Duration
+	observe := func(t *time.Time, a HandlingActivity) {
+		at, ok := actual(a)
+		if !ok {
+			*t = t.Add(delay)
+			return
+		}
+		if delay = at.Sub(*t); delay < 0 {
+			delay = 0
+		}
+		*t = at
+	}
+
+	legs := make([]Leg, len(i.Legs))
+	for j, l := range i.Legs {
+		observe(&l.LoadTime, HandlingActivity{Type: Load, Location: l.LoadLocation, VoyageNumber: l.VoyageNumber})
+		observe(&l.UnloadTime, HandlingActivity{Type: Unload, Location: l.UnloadLocation, VoyageNumber: l.VoyageNumber})
+		legs[j] = l
+	}
+	return Itinerary{Legs: legs, Partial: i.Partial}
+}
+
+// Normalized returns a copy of the itinerary where consecutive legs on the
+// same voyage are merged into a single leg, loaded where and when the first
+// of them is loaded and unloaded where and when the last of them is unloaded.
+// The distinct instructions of the merged legs are joined.
+func (i Itinerary) Normalized() Itinerary {
+	if i.Legs == nil {
+		return i
+	}
+	var (
+		legs = make([]Leg, 0, len(i.Legs))
+
+		// instructions are the distinct instructions of each merged leg.
+		instructions = make([][]string, 0, len(i.Legs))
+	)
+	for _, l := range i.Legs {
+		if n := len(legs); n > 0 && legs[n-1].VoyageNumber == l.VoyageNumber {
+			legs[n-1].UnloadLocation = l.UnloadLocation
+			legs[n-1].UnloadTime = l.UnloadTime
+			if l.Instructions != "" && !containsString(instructions[n-1], l.Instructions) {
+				instructions[n-1] = append(instructions[n-1], l.Instructions)
+				legs[n-1].Instructions = strings.Join(instructions[n-1], "; ")
+			}
+			continue
+		}
+		legs = append(legs, l)
+
+		var merged []string
+		if l.Instructions != "" {
+			merged = []string{l.Instructions}
+		}
+		instructions = append(instructions, merged)
+	}
+	return Itinerary{Legs: legs, Partial: i.Partial}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitTime returns the time from the first load to the final unload of
+// the itinerary.
+func (i Itinerary) TransitTime() time.Duration {
+	if i.IsEmpty() {
+		return 0
+	}
+	return i.FinalArrivalTime().Sub(i.Legs[0].LoadTime)
+}
+
+// LayoverTime returns the total time spent between unloading from one leg
+// and loading onto the next.
+func (i Itinerary) LayoverTime() time.Duration {
+	var d time.Duration
+	for j := 1; j < len(i.Legs); j++ {
+		d += i.Legs[j].LoadTime.Sub(i.Legs[j-1].UnloadTime)
+	}
+	return d
+}
+
+// ErrConnectionTooShort is used when an itinerary transfers a cargo from one
+// voyage to another in less than the minimum connection time.
+var ErrConnectionTooShort = NewError(CodeInvalidArgument, "connection between voyages too short")
+
+// ShortestConnection returns the shortest time between unloading from one
+// leg and loading onto the next, among the legs on different voyages, and
+// whether the itinerary has any such connection. Consecutive legs on the
+// same voyage are no connection, as the cargo stays on board. A connection
+// is left out if either of its times is unknown.
+func (i Itinerary) ShortestConnection() (time.Duration, bool) {
+	var (
+		shortest time.Duration
+		found    bool
+	)
+	for j := 1; j < len(i.Legs); j++ {
+		prev, next := i.Legs[j-1], i.Legs[j]
+		if prev.VoyageNumber == next.VoyageNumber || prev.UnloadTime.IsZero() || next.LoadTime.IsZero() {
+			continue
+		}
+		if d := next.LoadTime.Sub(prev.UnloadTime); !found || d < shortest {
+			shortest, found = d, true
+		}
+	}
+	return shortest, found
+}
+
+// ValidateConnections returns ErrConnectionTooShort if any connection of the
+// itinerary is shorter than min.
+func (i Itinerary) ValidateConnections(min time.Duration) error {
+	if d, ok := i.ShortestConnection(); ok && d < min {
+		return ErrConnectionTooShort
+	}
+	return nil
+}
+
+// IsOnVoyage returns true if any leg of the itinerary is on the given voyage.
+func (i Itinerary) IsOnVoyage(voyageNumber VoyageNumber) bool {
+	for _, l := range i.Legs {
+		if l.VoyageNumber == voyageNumber {
+			return true
+		}
+	}
+	return false
 }
 
 // InitialDepartureLocation returns the start of the itinerary.
@@ -48,6 +227,9 @@ func (i Itinerary) FinalArrivalLocation() UNLocode {
 
 // FinalArrivalTime returns the expected arrival time at final destination.
 func (i Itinerary) FinalArrivalTime() time.Time {
+	if i.IsEmpty() {
+		return time.Time{}
+	}
 	return i.Legs[len(i.Legs)-1].UnloadTime
 }
 
@@ -56,6 +238,20 @@ func (i Itinerary) IsEmpty() bool {
 	return i.Legs == nil || len(i.Legs) == 0
 }
 
+// InstructionsFor returns the instructions of the leg that the activity loads
+// the cargo onto or unloads it off, if any.
+func (i Itinerary) InstructionsFor(a HandlingActivity) string {
+	for _, l := range i.Legs {
+		if l.VoyageNumber != a.VoyageNumber {
+			continue
+		}
+		if (a.Type == Load && l.LoadLocation == a.Location) || (a.Type == Unload && l.UnloadLocation == a.Location) {
+			return l.Instructions
+		}
+	}
+	return ""
+}
+
 // IsExpected checks if the given handling event is expected when executing
 // this itinerary.
 func (i Itinerary) IsExpected(event HandlingEvent) bool {
@@ -86,3 +282,103 @@ func (i Itinerary) IsExpected(event HandlingEvent) bool {
 
 	return true
 }
+
+// MissingCoordinatesError is returned by Itinerary.GeoJSON when a location on
+// the itinerary has no known coordinates.
+type MissingCoordinatesError struct {
+	Location UNLocode
+}
+
+func (e *MissingCoordinatesError) Error() string {
+	return fmt.Sprintf("location %s has no coordinates", e.Location)
+}
+
+// GeoJSON returns the itinerary as a GeoJSON LineString through the load and
+// unload locations of each leg, in order, as found by locate. A location that
+// is the same as the one before it, such as where a cargo is transshipped,
+// is included only once. Locations without coordinates give a
+// *MissingCoordinatesError.
+func (i Itinerary) GeoJSON(locate func(UNLocode) (*Location, error)) ([]byte, error) {
+	coords := [][2]float64{}
+
+	var prev UNLocode
+	for _, l := range i.Legs {
+		for _, code := range []UNLocode{l.LoadLocation, l.UnloadLocation} {
+			if code == prev {
+				continue
+			}
+			prev = code
+
+			loc, err := locate(code)
+			if err != nil {
+				return nil, err
+			}
+			if !loc.HasCoordinates() {
+				return nil, &MissingCoordinatesError{Location: code}
+			}
+
+			// GeoJSON positions are given as longitude first.
+			coords = append(coords, [2]float64{loc.Longitude, loc.Latitude})
+		}
+	}
+
+	return json.Marshal(struct {
+		Type        string       `json:"type"`
+		Coordinates [][2]float64 `json:"coordinates"`
+	}{"LineString", coords})
+}
+
+// EmissionFactors holds the estimated emissions of CO2, in kilograms per
+// kilometer travelled, of each transport mode.
+type EmissionFactors map[TransportMode]float64
+
+// ErrMissingEmissionFactor is used when emissions are estimated for a voyage
+// whose transport mode has no emission factor.
+var ErrMissingEmissionFactor = NewError(CodeFailedPrecondition, "no emission factor for transport mode")
+
+// EstimatedEmissions returns a rough estimate of the CO2 emitted, in
+// kilograms, when transporting a cargo along the itinerary. The emissions of
+// each leg are its great-circle distance times the factor of the transport
+// mode of its voyage. The locations and voyages of the legs are found by
+// locate and voyage.
+func (i Itinerary) EstimatedEmissions(factors EmissionFactors, locate func(UNLocode) (*Location, error), voyage func(VoyageNumber) (*Voyage, error)) (float64, error) {
+	var total float64
+	for _, l := range i.Legs {
+		v, err := voyage(l.VoyageNumber)
+		if err != nil {
+			return 0, err
+		}
+		factor, ok := factors[v.Mode]
+		if !ok {
+			return 0, ErrMissingEmissionFactor
+		}
+
+		from, err := locate(l.LoadLocation)
+		if err != nil {
+			return 0, err
+		}
+		to, err := locate(l.UnloadLocation)
+		if err != nil {
+			return 0, err
+		}
+
+		d, err := Distance(*from, *to)
+		if err != nil {
+			return 0, err
+		}
+
+		total += d * factor
+	}
+	return total, nil
+}
+
+// EstimatedEmissionsIn returns the estimated emissions like
+// EstimatedEmissions, in kilograms or pounds depending on the unit system.
+// The emission factors are in kilograms per kilometer regardless.
+func (i Itinerary) EstimatedEmissionsIn(u UnitSystem, factors EmissionFactors, locate func(UNLocode) (*Location, error), voyage func(VoyageNumber) (*Voyage, error)) (float64, error) {
+	total, err := i.EstimatedEmissions(factors, locate, voyage)
+	if err != nil {
+		return 0, err
+	}
+	return u.Mass(total), nil
+}
@@ -1,8 +1,10 @@
 package shipping
 
 import (
+	"math"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestItinerary_CreateEmpty(t *testing.T) {
@@ -73,3 +75,257 @@ func TestItinerary_IsExpected(t *testing.T) {
 		}
 	}
 }
+
+func TestItinerary_FinalArrivalTime_EmptyItinerary(t *testing.T) {
+	i := Itinerary{Legs: []Leg{}}
+
+	if got := i.FinalArrivalTime(); !got.IsZero() {
+		t.Errorf("FinalArrivalTime() = %s; want zero time", got)
+	}
+}
+
+func TestItinerary_Normalized(t *testing.T) {
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	i := Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, NLRTM, t0, t0.AddDate(0, 0, 2)),
+		NewLeg("V100", NLRTM, DEHAM, t0.AddDate(0, 0, 3), t0.AddDate(0, 0, 4)),
+		NewLeg("V200", DEHAM, CNHKG, t0.AddDate(0, 0, 5), t0.AddDate(0, 0, 20)),
+	}}
+
+	want := Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, DEHAM, t0, t0.AddDate(0, 0, 4)),
+		NewLeg("V200", DEHAM, CNHKG, t0.AddDate(0, 0, 5), t0.AddDate(0, 0, 20)),
+	}}
+
+	got := i.Normalized()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Normalized() = %v; want = %v", got, want)
+	}
+	if len(i.Legs) != 3 || i.Legs[0].UnloadLocation != NLRTM {
+		t.Errorf("the original itinerary should not be modified")
+	}
+
+	i.Legs[0].Instructions = "Keep refrigerated"
+	i.Legs[1].Instructions = "Hazmat placarding required"
+
+	if got, want := i.Normalized().Legs[0].Instructions, "Keep refrigerated; Hazmat placarding required"; got != want {
+		t.Errorf("Instructions = %q; want = %q", got, want)
+	}
+
+	// An instruction that is part of another is still distinct.
+	i.Legs[0].Instructions = "Keep refrigerated below 4C"
+	i.Legs[1].Instructions = "Keep refrigerated"
+
+	if got, want := i.Normalized().Legs[0].Instructions, "Keep refrigerated below 4C; Keep refrigerated"; got != want {
+		t.Errorf("Instructions = %q; want = %q", got, want)
+	}
+
+	i.Legs[1].Instructions = "Keep refrigerated below 4C"
+
+	if got, want := i.Normalized().Legs[0].Instructions, "Keep refrigerated below 4C"; got != want {
+		t.Errorf("Instructions = %q; want = %q", got, want)
+	}
+}
+
+func TestItinerary_WithActualDelays(t *testing.T) {
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	i := Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, NLRTM, t0, t0.Add(48*time.Hour)),
+		NewLeg("V200", NLRTM, DEHAM, t0.Add(60*time.Hour), t0.Add(72*time.Hour)),
+		NewLeg("V300", DEHAM, CNHKG, t0.Add(80*time.Hour), t0.Add(200*time.Hour)),
+	}}
+
+	history := HandlingHistory{HandlingEvents: []HandlingEvent{
+		{Activity: HandlingActivity{Type: Load, Location: SESTO, VoyageNumber: "V100"}, CompletionTime: t0.Add(time.Hour)},
+		{Activity: HandlingActivity{Type: Unload, Location: NLRTM, VoyageNumber: "V100"}, CompletionTime: t0.Add(54 * time.Hour)},
+	}}
+
+	got := i.WithActualDelays(history)
+
+	want := []Leg{
+		NewLeg("V100", SESTO, NLRTM, t0.Add(time.Hour), t0.Add(54*time.Hour)),
+		NewLeg("V200", NLRTM, DEHAM, t0.Add(66*time.Hour), t0.Add(78*time.Hour)),
+		NewLeg("V300", DEHAM, CNHKG, t0.Add(86*time.Hour), t0.Add(206*time.Hour)),
+	}
+	if !reflect.DeepEqual(got.Legs, want) {
+		t.Errorf("Legs = %v; want = %v", got.Legs, want)
+	}
+
+	if i.Legs[1].LoadTime != t0.Add(60*time.Hour) {
+		t.Errorf("the original itinerary should not be modified")
+	}
+
+	// A cargo unloaded ahead of plan does not move the next legs earlier.
+	early := HandlingHistory{HandlingEvents: []HandlingEvent{
+		{Activity: HandlingActivity{Type: Unload, Location: NLRTM, VoyageNumber: "V100"}, CompletionTime: t0.Add(40 * time.Hour)},
+	}}
+	if got := i.WithActualDelays(early).Legs[1].LoadTime; got != t0.Add(60*time.Hour) {
+		t.Errorf("LoadTime = %v; want = %v", got, t0.Add(60*time.Hour))
+	}
+}
+
+func TestItinerary_TransitAndLayoverTime(t *testing.T) {
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	i := Itinerary{Legs: []Leg{
+		NewLeg("V100", SESTO, NLRTM, t0, t0.Add(48*time.Hour)),
+		NewLeg("V200", NLRTM, DEHAM, t0.Add(60*time.Hour), t0.Add(72*time.Hour)),
+		NewLeg("V300", DEHAM, CNHKG, t0.Add(72*time.Hour), t0.Add(500*time.Hour)),
+	}}
+
+	if got, want := i.TransitTime(), 500*time.Hour; got != want {
+		t.Errorf("TransitTime() = %v; want = %v", got, want)
+	}
+	if got, want := i.LayoverTime(), 12*time.Hour; got != want {
+		t.Errorf("LayoverTime() = %v; want = %v", got, want)
+	}
+
+	var empty Itinerary
+
+	if got := empty.TransitTime(); got != 0 {
+		t.Errorf("TransitTime() = %v; want = %v", got, 0)
+	}
+	if got := empty.LayoverTime(); got != 0 {
+		t.Errorf("LayoverTime() = %v; want = %v", got, 0)
+	}
+}
+
+func TestItinerary_ValidateConnections(t *testing.T) {
+	t0 := time.Date(2009, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		legs []Leg
+		want error
+	}{
+		{"too tight", []Leg{
+			NewLeg("V100", SESTO, NLRTM, t0.Add(-48*time.Hour), t0),
+			NewLeg("V200", NLRTM, DEHAM, t0.Add(5*time.Minute), t0.Add(24*time.Hour)),
+		}, ErrConnectionTooShort},
+		{"acceptable", []Leg{
+			NewLeg("V100", SESTO, NLRTM, t0.Add(-48*time.Hour), t0),
+			NewLeg("V200", NLRTM, DEHAM, t0.Add(3*time.Hour), t0.Add(24*time.Hour)),
+		}, nil},
+		{"same voyage", []Leg{
+			NewLeg("V100", SESTO, NLRTM, t0.Add(-48*time.Hour), t0),
+			NewLeg("V100", NLRTM, DEHAM, t0.Add(5*time.Minute), t0.Add(24*time.Hour)),
+		}, nil},
+		{"single leg", []Leg{
+			NewLeg("V100", SESTO, NLRTM, t0.Add(-48*time.Hour), t0),
+		}, nil},
+		{"unknown unload time", []Leg{
+			{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: NLRTM},
+			NewLeg("V200", NLRTM, DEHAM, t0.Add(5*time.Minute), t0.Add(24*time.Hour)),
+		}, nil},
+		{"unknown load time", []Leg{
+			NewLeg("V100", SESTO, NLRTM, t0.Add(-48*time.Hour), t0),
+			{VoyageNumber: "V200", LoadLocation: NLRTM, UnloadLocation: DEHAM},
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		i := Itinerary{Legs: tt.legs}
+		if err := i.ValidateConnections(2 * time.Hour); err != tt.want {
+			t.Errorf("%s: ValidateConnections() = %v; want = %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestItinerary_GeoJSON(t *testing.T) {
+	locations := map[UNLocode]*Location{
+		SESTO: Stockholm,
+		CNHKG: Hongkong,
+		AUMEL: Melbourne,
+	}
+	locate := func(code UNLocode) (*Location, error) {
+		if l, ok := locations[code]; ok {
+			return l, nil
+		}
+		return nil, ErrUnknownLocation
+	}
+
+	i := Itinerary{Legs: []Leg{
+		{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: CNHKG},
+		{VoyageNumber: "V200", LoadLocation: CNHKG, UnloadLocation: AUMEL},
+	}}
+
+	got, err := i.GeoJSON(locate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"type":"LineString","coordinates":[[18.0686,59.3293],[114.1694,22.3193],[144.9631,-37.8136]]}`
+	if string(got) != want {
+		t.Errorf("GeoJSON() = %s; want = %s", got, want)
+	}
+
+	locations[CNHKG] = &Location{UNLocode: CNHKG, Name: "Hongkong"}
+
+	_, err = i.GeoJSON(locate)
+	if merr, ok := err.(*MissingCoordinatesError); !ok || merr.Location != CNHKG {
+		t.Errorf("err = %v; want = %v", err, &MissingCoordinatesError{Location: CNHKG})
+	}
+}
+
+func TestItinerary_EstimatedEmissions(t *testing.T) {
+	locations := map[UNLocode]*Location{
+		SESTO: Stockholm,
+		DEHAM: Hamburg,
+		NLRTM: Rotterdam,
+		CNHKG: Hongkong,
+	}
+	locate := func(code UNLocode) (*Location, error) {
+		if l, ok := locations[code]; ok {
+			return l, nil
+		}
+		return nil, ErrUnknownLocation
+	}
+
+	voyages := map[VoyageNumber]*Voyage{
+		"V100": {VoyageNumber: "V100", Mode: Rail},
+		"V200": {VoyageNumber: "V200", Mode: Road},
+		"V300": {VoyageNumber: "V300"},
+	}
+	voyage := func(n VoyageNumber) (*Voyage, error) {
+		if v, ok := voyages[n]; ok {
+			return v, nil
+		}
+		return nil, ErrUnknownVoyage
+	}
+
+	i := Itinerary{Legs: []Leg{
+		{VoyageNumber: "V100", LoadLocation: SESTO, UnloadLocation: DEHAM},
+		{VoyageNumber: "V200", LoadLocation: DEHAM, UnloadLocation: NLRTM},
+		{VoyageNumber: "V300", LoadLocation: NLRTM, UnloadLocation: CNHKG},
+	}}
+
+	factors := EmissionFactors{Sea: 0.01, Rail: 0.02, Road: 0.1}
+
+	// 810.7 km by rail, 412.9 km by road and 9326.3 km by sea.
+	want := 810.7*0.02 + 412.9*0.1 + 9326.3*0.01
+
+	got, err := i.EstimatedEmissions(factors, locate, voyage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-want) > 0.1 {
+		t.Errorf("EstimatedEmissions() = %f; want = %f", got, want)
+	}
+
+	lb, err := i.EstimatedEmissionsIn(Imperial, factors, locate, voyage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(lb-got*2.20462262) > 1e-6 {
+		t.Errorf("EstimatedEmissionsIn(Imperial) = %f; want = %f", lb, got*2.20462262)
+	}
+
+	delete(factors, Road)
+
+	if _, err := i.EstimatedEmissions(factors, locate, voyage); err != ErrMissingEmissionFactor {
+		t.Errorf("err = %v; want = %v", err, ErrMissingEmissionFactor)
+	}
+}
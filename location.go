@@ -1,6 +1,10 @@
 package shipping
 
-import "errors"
+import (
+	"context"
+	"math"
+	"strings"
+)
 
 // UNLocode is the United Nations location code that uniquely identifies a
 // particular location.
@@ -9,18 +13,139 @@ import "errors"
 // http://www.unece.org/cefact/locode/DocColumnDescription.htm#LOCODE
 type UNLocode string
 
+// ErrInvalidUNLocode is used when a UN/LOCODE is not properly formatted.
+var ErrInvalidUNLocode = NewError(CodeInvalidArgument, "invalid UN/LOCODE")
+
+// NewUNLocode creates a UN/LOCODE from s, ignoring the case of ASCII
+// letters. It returns ErrInvalidUNLocode if s is not formatted as a
+// UN/LOCODE.
+func NewUNLocode(s string) (UNLocode, error) {
+	// Only ASCII is upper-cased, as some other letters, such as the long s,
+	// upper-case to ASCII letters.
+	b := []byte(s)
+	for i, r := range b {
+		if r >= 'a' && r <= 'z' {
+			b[i] = r - 'a' + 'A'
+		}
+	}
+	c := UNLocode(b)
+	if !c.IsValid() {
+		return "", ErrInvalidUNLocode
+	}
+	return c, nil
+}
+
+// ParseUNLocode parses a UN/LOCODE as entered by a user. Unlike NewUNLocode,
+// it ignores surrounding white space, and accepts the code written with a
+// space between the country and the location, such as "SE STO". It returns
+// ErrInvalidUNLocode if s is not a UN/LOCODE.
+func ParseUNLocode(s string) (UNLocode, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 6 && s[2] == ' ' {
+		s = s[:2] + s[3:]
+	}
+	return NewUNLocode(s)
+}
+
+// String returns the UN/LOCODE as parsed by ParseUNLocode.
+func (c UNLocode) String() string {
+	return string(c)
+}
+
+// Country returns the ISO 3166 country code of the location.
+func (c UNLocode) Country() string {
+	if len(c) < 2 {
+		return ""
+	}
+	return string(c[:2])
+}
+
+// IsValid returns whether the code is formatted as a UN/LOCODE: a two-letter
+// country code followed by a three-character location code of upper-case
+// letters and digits.
+func (c UNLocode) IsValid() bool {
+	if len(c) != 5 {
+		return false
+	}
+	for i := 0; i < len(c); i++ {
+		switch r := c[i]; {
+		case r >= 'A' && r <= 'Z':
+		case i >= 2 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // Location is a location is our model is stops on a journey, such as cargo
 // origin or destination, or carrier movement endpoints.
+//
+// Latitude and Longitude are given in decimal degrees. A location at exactly
+// 0°N 0°E is considered to have no known coordinates.
 type Location struct {
-	UNLocode UNLocode
-	Name     string
+	UNLocode  UNLocode
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+// HasCoordinates returns true if the geographic position of the location is
+// known.
+func (l Location) HasCoordinates() bool {
+	return l.Latitude != 0 || l.Longitude != 0
 }
 
 // ErrUnknownLocation is used when a location could not be found.
-var ErrUnknownLocation = errors.New("unknown location")
+var ErrUnknownLocation = NewError(CodeNotFound, "unknown location")
+
+// ErrMissingCoordinates is used when a location lacks the coordinates
+// required for a computation.
+var ErrMissingCoordinates = NewError(CodeFailedPrecondition, "location has no coordinates")
+
+// earthRadius is the mean radius of the Earth in kilometers.
+const earthRadius = 6371.0
+
+// Distance returns the great-circle distance in kilometers between two
+// locations, using the haversine formula.
+func Distance(a, b Location) (float64, error) {
+	if !a.HasCoordinates() || !b.HasCoordinates() {
+		return 0, ErrMissingCoordinates
+	}
+
+	var (
+		lat1 = radians(a.Latitude)
+		lat2 = radians(b.Latitude)
+		dlat = radians(b.Latitude - a.Latitude)
+		dlon = radians(b.Longitude - a.Longitude)
+	)
+
+	h := math.Pow(math.Sin(dlat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dlon/2), 2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(h)), nil
+}
+
+// DistanceIn returns the great-circle distance between two locations like
+// Distance, in kilometers or miles depending on the unit system.
+func DistanceIn(a, b Location, u UnitSystem) (float64, error) {
+	d, err := Distance(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return u.Length(d), nil
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
 
 // LocationRepository provides access a location store.
 type LocationRepository interface {
-	Find(locode UNLocode) (*Location, error)
-	FindAll() []*Location
+	Find(ctx context.Context, locode UNLocode) (*Location, error)
+	FindAll(ctx context.Context) []*Location
+
+	// FindByNamePrefix returns the locations whose name starts with the
+	// given prefix, ignoring case, sorted by name. An empty prefix matches
+	// no locations.
+	FindByNamePrefix(ctx context.Context, prefix string) []*Location
 }
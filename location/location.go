@@ -0,0 +1,119 @@
+// Package location provides means to seed the location registry from the
+// UN/LOCODE code list.
+//
+// http://www.unece.org/cefact/locode/
+package location
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// Columns of the UN/LOCODE code list.
+//
+// http://www.unece.org/cefact/locode/DocColumnDescription.htm
+const (
+	colCountry     = 1
+	colLocation    = 2
+	colName        = 3
+	colCoordinates = 10
+)
+
+// LoadFromReader reads locations from r, formatted as the comma-separated
+// UN/LOCODE code list. Entries that do not make up a valid UN/LOCODE, such as
+// the country headings, are skipped. Locations without coordinates are left
+// with no known coordinates.
+func LoadFromReader(r io.Reader) ([]*shipping.Location, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	var locations []*shipping.Location
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		if len(rec) <= colCoordinates {
+			return nil, fmt.Errorf("line %d: expected at least %d fields, got %d", line, colCoordinates+1, len(rec))
+		}
+
+		code, err := shipping.NewUNLocode(rec[colCountry] + rec[colLocation])
+		if err != nil {
+			continue
+		}
+
+		l := &shipping.Location{
+			UNLocode: code,
+			Name:     rec[colName],
+		}
+		if c := rec[colCoordinates]; c != "" {
+			if l.Latitude, l.Longitude, err = parseCoordinates(c); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+
+		locations = append(locations, l)
+	}
+
+	return locations, nil
+}
+
+// parseCoordinates parses coordinates as given in the code list, in degrees
+// and minutes, for example "5920N 01803E", into decimal degrees.
+func parseCoordinates(s string) (lat, lon float64, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid coordinates %q", s)
+	}
+
+	if lat, err = parseDegrees(fields[0], 2, 'N', 'S'); err != nil {
+		return 0, 0, fmt.Errorf("invalid coordinates %q", s)
+	}
+	if lon, err = parseDegrees(fields[1], 3, 'E', 'W'); err != nil {
+		return 0, 0, fmt.Errorf("invalid coordinates %q", s)
+	}
+
+	return lat, lon, nil
+}
+
+// parseDegrees parses a number of degrees, given with the number of digits,
+// followed by two digits of minutes and the hemisphere.
+func parseDegrees(s string, digits int, pos, neg byte) (float64, error) {
+	if len(s) != digits+3 {
+		return 0, fmt.Errorf("invalid length")
+	}
+
+	deg, err := strconv.Atoi(s[:digits])
+	if err != nil {
+		return 0, err
+	}
+	min, err := strconv.Atoi(s[digits : digits+2])
+	if err != nil {
+		return 0, err
+	}
+	if min >= 60 {
+		return 0, fmt.Errorf("invalid minutes")
+	}
+
+	d := float64(deg) + float64(min)/60
+
+	switch s[digits+2] {
+	case pos:
+		return d, nil
+	case neg:
+		return -d, nil
+	}
+	return 0, fmt.Errorf("invalid hemisphere")
+}
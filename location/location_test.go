@@ -0,0 +1,54 @@
+package location
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestLoadFromReader(t *testing.T) {
+	f, err := os.Open("testdata/unlocode.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := LoadFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []shipping.Location{
+		{UNLocode: "SESTO", Name: "Stockholm", Latitude: 59 + 20.0/60, Longitude: 18 + 3.0/60},
+		{UNLocode: "SEGOT", Name: "Göteborg", Latitude: 57 + 43.0/60, Longitude: 11 + 58.0/60},
+		{UNLocode: "AUMEL", Name: "Melbourne", Latitude: -(37 + 49.0/60), Longitude: 144 + 58.0/60},
+		{UNLocode: "USNYC", Name: "New York"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("len(locations) = %d; want = %d", len(got), len(want))
+	}
+
+	for i, l := range got {
+		if !l.UNLocode.IsValid() {
+			t.Errorf("UNLocode = %s; want valid", l.UNLocode)
+		}
+		if l.UNLocode != want[i].UNLocode || l.Name != want[i].Name {
+			t.Errorf("location = %s %s; want = %s %s", l.UNLocode, l.Name, want[i].UNLocode, want[i].Name)
+		}
+		if math.Abs(l.Latitude-want[i].Latitude) > 1e-9 || math.Abs(l.Longitude-want[i].Longitude) > 1e-9 {
+			t.Errorf("%s: coordinates = %f, %f; want = %f, %f", l.UNLocode, l.Latitude, l.Longitude, want[i].Latitude, want[i].Longitude)
+		}
+	}
+}
+
+func TestLoadFromReader_InvalidCoordinates(t *testing.T) {
+	in := `,"SE","STO","Stockholm","Stockholm","AB","AI","12345---","0207","","5920X 01803E",""` + "\n"
+
+	if _, err := LoadFromReader(strings.NewReader(in)); err == nil {
+		t.Errorf("err = %v; want error", err)
+	}
+}
@@ -0,0 +1,155 @@
+package shipping
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b *Location
+		want float64
+	}{
+		{Stockholm, Helsinki, 396},
+		{NewYork, Chicago, 1144},
+		{Hongkong, Tokyo, 2876},
+		{Hamburg, Rotterdam, 413},
+		{Stockholm, Stockholm, 0},
+	}
+
+	for _, tt := range tests {
+		got, err := Distance(*tt.a, *tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Allow for one percent deviation, or one kilometer for short
+		// distances.
+		if math.Abs(got-tt.want) > math.Max(tt.want*0.01, 1) {
+			t.Errorf("Distance(%s, %s) = %.1f; want = %.1f", tt.a.Name, tt.b.Name, got, tt.want)
+		}
+
+		reverse, _ := Distance(*tt.b, *tt.a)
+		if math.Abs(reverse-got) > 1e-9 {
+			t.Errorf("Distance(%s, %s) = %.1f; want = %.1f", tt.b.Name, tt.a.Name, reverse, got)
+		}
+	}
+}
+
+func TestDistanceIn(t *testing.T) {
+	km, err := DistanceIn(*Stockholm, *Helsinki, Metric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := DistanceIn(*Stockholm, *Helsinki, Imperial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 396 kilometers are about 246 miles.
+	if math.Abs(km-396) > 4 {
+		t.Errorf("DistanceIn(Metric) = %.1f; want = %.1f", km, 396.0)
+	}
+	if math.Abs(mi-246) > 2.5 {
+		t.Errorf("DistanceIn(Imperial) = %.1f; want = %.1f", mi, 246.0)
+	}
+	if math.Abs(mi-km*0.621371192) > 1e-9 {
+		t.Errorf("DistanceIn(Imperial) = %f; want = %f", mi, km*0.621371192)
+	}
+}
+
+func TestDistance_MissingCoordinates(t *testing.T) {
+	unknown := Location{UNLocode: "XXXXX", Name: "Nowhere"}
+
+	if _, err := Distance(*Stockholm, unknown); err != ErrMissingCoordinates {
+		t.Errorf("err = %v; want = %v", err, ErrMissingCoordinates)
+	}
+	if _, err := Distance(unknown, *Stockholm); err != ErrMissingCoordinates {
+		t.Errorf("err = %v; want = %v", err, ErrMissingCoordinates)
+	}
+}
+
+func TestUNLocode_IsValid(t *testing.T) {
+	tests := []struct {
+		code UNLocode
+		want bool
+	}{
+		{SESTO, true},
+		{"USNY2", true},
+		{"", false},
+		{"SESTOC", false},
+		{"sesto", false},
+		{"S1STO", false},
+		{"SEST1", true},
+		{"SE-TO", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.code.IsValid(); got != tt.want {
+			t.Errorf("UNLocode(%q).IsValid() = %v; want = %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestNewUNLocode(t *testing.T) {
+	valid := []struct {
+		in   string
+		want UNLocode
+	}{
+		{"SESTO", SESTO},
+		{"sesto", SESTO},
+		{"UsNyC", USNYC},
+		{"DE2AB", "DE2AB"},
+	}
+
+	for _, tt := range valid {
+		got, err := NewUNLocode(tt.in)
+		if err != nil {
+			t.Errorf("NewUNLocode(%q) err = %v; want = %v", tt.in, err, nil)
+		}
+		if got != tt.want {
+			t.Errorf("NewUNLocode(%q) = %q; want = %q", tt.in, got, tt.want)
+		}
+	}
+
+	malformed := []string{
+		"",
+		"SEST",
+		"SESTOC",
+		" SESTO",
+		"S1STO",
+		"12STO",
+		"SE-TO",
+		"SE_TO",
+		"SEÖTO",
+		"ſESTO",
+	}
+
+	for _, in := range malformed {
+		if _, err := NewUNLocode(in); err != ErrInvalidUNLocode {
+			t.Errorf("NewUNLocode(%q) err = %v; want = %v", in, err, ErrInvalidUNLocode)
+		}
+	}
+}
+
+func TestParseUNLocode(t *testing.T) {
+	var tests = []struct {
+		in   string
+		want UNLocode
+		err  error
+	}{
+		{"SESTO", SESTO, nil},
+		{" sesto\n", SESTO, nil},
+		{"SE STO", SESTO, nil},
+		{"SE  STO", "", ErrInvalidUNLocode},
+		{"SES TO", "", ErrInvalidUNLocode},
+		{"", "", ErrInvalidUNLocode},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseUNLocode(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseUNLocode(%q) = %q, %v; want = %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
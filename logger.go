@@ -0,0 +1,57 @@
+//go:build go1.21
+
+package shipping
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type loggerKey struct{}
+
+// NewLoggerContext returns a copy of ctx that carries the given logger, along
+// with any attributes it has been given with With, such as those of the
+// request being served.
+func NewLoggerContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by the context, or
+// slog.Default if it carries none. The correlation ID of the context, if
+// any, is added to the attributes of the logger.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok || logger == nil {
+		logger = slog.Default()
+	}
+	if id := CorrelationFromContext(ctx); id != "" {
+		logger = logger.With(slog.String("correlation_id", string(id)))
+	}
+	return logger
+}
+
+// LogCall logs the outcome of a call to a service method that began at the
+// given time, to the logger of the context. Successful calls are logged at
+// the info level, and failed calls at the warning level if the caller is to
+// blame, as told by the code of the error, and at the error level otherwise.
+func LogCall(ctx context.Context, method string, begin time.Time, err error, attrs ...slog.Attr) {
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		switch ErrorCodeOf(err) {
+		case CodeInvalidArgument, CodeNotFound, CodeAlreadyExists, CodeConflict, CodeFailedPrecondition, CodeResourceExhausted:
+			level = slog.LevelWarn
+		}
+	}
+
+	attrs = append(attrs,
+		slog.String("method", method),
+		slog.Duration("took", time.Since(begin)),
+	)
+	if err != nil {
+		attrs = append(attrs, slog.String("err", err.Error()))
+	}
+
+	LoggerFromContext(ctx).LogAttrs(ctx, level, method, attrs...)
+}
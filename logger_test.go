@@ -0,0 +1,114 @@
+//go:build go1.21
+
+package shipping
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// recordingHandler is a slog.Handler that records the attributes of every
+// record it handles, including those the logger was given with With.
+type recordingHandler struct {
+	attrs   []slog.Attr
+	records *[]recordedLog
+}
+
+type recordedLog struct {
+	level slog.Level
+	msg   string
+	attrs map[string]slog.Value
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{records: new([]recordedLog)}
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	l := recordedLog{level: r.Level, msg: r.Message, attrs: make(map[string]slog.Value)}
+	for _, a := range h.attrs {
+		l.attrs[a.Key] = a.Value
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		l.attrs[a.Key] = a.Value
+		return true
+	})
+	*h.records = append(*h.records, l)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recordingHandler{
+		attrs:   append(append([]slog.Attr{}, h.attrs...), attrs...),
+		records: h.records,
+	}
+}
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func TestLoggerFromContext(t *testing.T) {
+	if got := LoggerFromContext(context.Background()); got != slog.Default() {
+		t.Errorf("LoggerFromContext() = %v; want = %v", got, slog.Default())
+	}
+
+	h := newRecordingHandler()
+
+	ctx := NewLoggerContext(context.Background(), slog.New(h).With(slog.String("request_id", "r1")))
+	ctx = NewCorrelationContext(ctx, "c1")
+
+	LoggerFromContext(ctx).Info("hello")
+
+	if len(*h.records) != 1 {
+		t.Fatalf("len(records) = %d; want = %d", len(*h.records), 1)
+	}
+	l := (*h.records)[0]
+	if got := l.attrs["request_id"].String(); got != "r1" {
+		t.Errorf("request_id = %v; want = %v", got, "r1")
+	}
+	if got := l.attrs["correlation_id"].String(); got != "c1" {
+		t.Errorf("correlation_id = %v; want = %v", got, "c1")
+	}
+}
+
+func TestLogCall(t *testing.T) {
+	tests := []struct {
+		err   error
+		level slog.Level
+	}{
+		{nil, slog.LevelInfo},
+		{ErrUnknownCargo, slog.LevelWarn},
+		{errors.New("connection refused"), slog.LevelError},
+	}
+
+	for _, tt := range tests {
+		h := newRecordingHandler()
+		ctx := NewLoggerContext(context.Background(), slog.New(h))
+
+		LogCall(ctx, "track", time.Now(), tt.err, slog.String("tracking_id", "ABC123"))
+
+		if len(*h.records) != 1 {
+			t.Fatalf("len(records) = %d; want = %d", len(*h.records), 1)
+		}
+		l := (*h.records)[0]
+		if l.level != tt.level {
+			t.Errorf("level(%v) = %v; want = %v", tt.err, l.level, tt.level)
+		}
+		if got := l.attrs["method"].String(); got != "track" {
+			t.Errorf("method = %v; want = %v", got, "track")
+		}
+		if got := l.attrs["tracking_id"].String(); got != "ABC123" {
+			t.Errorf("tracking_id = %v; want = %v", got, "ABC123")
+		}
+		if _, ok := l.attrs["took"]; !ok {
+			t.Errorf("took should be logged")
+		}
+		if _, ok := l.attrs["err"]; ok != (tt.err != nil) {
+			t.Errorf("err logged = %v; want = %v", ok, tt.err != nil)
+		}
+	}
+}
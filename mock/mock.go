@@ -1,6 +1,11 @@
+// Package mock provides mock implementations of the domain repositories and
+// services. The mock functions are called without the context.
 package mock
 
 import (
+	"context"
+	"time"
+
 	shipping "github.com/marcusolsson/goddd"
 )
 
@@ -14,26 +19,152 @@ type CargoRepository struct {
 
 	FindAllFn      func() []*shipping.Cargo
 	FindAllInvoked bool
+
+	FindAllIncludingArchivedFn      func() []*shipping.Cargo
+	FindAllIncludingArchivedInvoked bool
+
+	FindOverdueFn      func(now time.Time) []*shipping.Cargo
+	FindOverdueInvoked bool
+
+	FindAwaitingClaimBeyondFn      func(d time.Duration, now time.Time) []*shipping.Cargo
+	FindAwaitingClaimBeyondInvoked bool
+
+	FindAllPagedFn      func(offset, limit int) ([]*shipping.Cargo, int, error)
+	FindAllPagedInvoked bool
+
+	FindByRoutingStatusFn      func(status shipping.RoutingStatus) []*shipping.Cargo
+	FindByRoutingStatusInvoked bool
+
+	FindByServiceLevelFn      func(level shipping.ServiceLevel) []*shipping.Cargo
+	FindByServiceLevelInvoked bool
+
+	FindByExternalRefFn      func(ref string) (*shipping.Cargo, error)
+	FindByExternalRefInvoked bool
+
+	FindAtLocationFn      func(loc shipping.UNLocode) []*shipping.Cargo
+	FindAtLocationInvoked bool
+
+	FindByVoyageFn      func(number shipping.VoyageNumber) []*shipping.Cargo
+	FindByVoyageInvoked bool
+
+	FindByTagFn      func(key, value string) []*shipping.Cargo
+	FindByTagInvoked bool
+
+	FindChildrenFn      func(parent shipping.TrackingID) []*shipping.Cargo
+	FindChildrenInvoked bool
+
+	FindByDestinationFn      func(dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo
+	FindByDestinationInvoked bool
+
+	ArchiveFn      func(id shipping.TrackingID) error
+	ArchiveInvoked bool
+
+	WatchFn      func() (<-chan shipping.CargoChange, error)
+	WatchInvoked bool
 }
 
 // Store calls the StoreFn.
-func (r *CargoRepository) Store(c *shipping.Cargo) error {
+func (r *CargoRepository) Store(ctx context.Context, c *shipping.Cargo) error {
 	r.StoreInvoked = true
 	return r.StoreFn(c)
 }
 
 // Find calls the FindFn.
-func (r *CargoRepository) Find(id shipping.TrackingID) (*shipping.Cargo, error) {
+func (r *CargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
 	r.FindInvoked = true
 	return r.FindFn(id)
 }
 
 // FindAll calls the FindAllFn.
-func (r *CargoRepository) FindAll() []*shipping.Cargo {
+func (r *CargoRepository) FindAll(ctx context.Context) []*shipping.Cargo {
 	r.FindAllInvoked = true
 	return r.FindAllFn()
 }
 
+// FindAllIncludingArchived calls the FindAllIncludingArchivedFn.
+func (r *CargoRepository) FindAllIncludingArchived(ctx context.Context) []*shipping.Cargo {
+	r.FindAllIncludingArchivedInvoked = true
+	return r.FindAllIncludingArchivedFn()
+}
+
+// FindAwaitingClaimBeyond calls the FindAwaitingClaimBeyondFn.
+func (r *CargoRepository) FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*shipping.Cargo {
+	r.FindAwaitingClaimBeyondInvoked = true
+	return r.FindAwaitingClaimBeyondFn(d, now)
+}
+
+// FindOverdue calls the FindOverdueFn.
+func (r *CargoRepository) FindOverdue(ctx context.Context, now time.Time) []*shipping.Cargo {
+	r.FindOverdueInvoked = true
+	return r.FindOverdueFn(now)
+}
+
+// FindAllPaged calls the FindAllPagedFn.
+func (r *CargoRepository) FindAllPaged(ctx context.Context, offset, limit int) ([]*shipping.Cargo, int, error) {
+	r.FindAllPagedInvoked = true
+	return r.FindAllPagedFn(offset, limit)
+}
+
+// FindByExternalRef calls the FindByExternalRefFn.
+func (r *CargoRepository) FindByExternalRef(ctx context.Context, ref string) (*shipping.Cargo, error) {
+	r.FindByExternalRefInvoked = true
+	return r.FindByExternalRefFn(ref)
+}
+
+// FindByServiceLevel calls the FindByServiceLevelFn.
+func (r *CargoRepository) FindByServiceLevel(ctx context.Context, level shipping.ServiceLevel) []*shipping.Cargo {
+	r.FindByServiceLevelInvoked = true
+	return r.FindByServiceLevelFn(level)
+}
+
+// FindByRoutingStatus calls the FindByRoutingStatusFn.
+func (r *CargoRepository) FindByRoutingStatus(ctx context.Context, status shipping.RoutingStatus) []*shipping.Cargo {
+	r.FindByRoutingStatusInvoked = true
+	return r.FindByRoutingStatusFn(status)
+}
+
+// FindAtLocation calls the FindAtLocationFn.
+func (r *CargoRepository) FindAtLocation(ctx context.Context, loc shipping.UNLocode) []*shipping.Cargo {
+	r.FindAtLocationInvoked = true
+	return r.FindAtLocationFn(loc)
+}
+
+// FindByVoyage calls the FindByVoyageFn.
+func (r *CargoRepository) FindByVoyage(ctx context.Context, number shipping.VoyageNumber) []*shipping.Cargo {
+	r.FindByVoyageInvoked = true
+	return r.FindByVoyageFn(number)
+}
+
+// FindByTag calls the FindByTagFn.
+func (r *CargoRepository) FindByTag(ctx context.Context, key, value string) []*shipping.Cargo {
+	r.FindByTagInvoked = true
+	return r.FindByTagFn(key, value)
+}
+
+// FindChildren calls the FindChildrenFn.
+func (r *CargoRepository) FindChildren(ctx context.Context, parent shipping.TrackingID) []*shipping.Cargo {
+	r.FindChildrenInvoked = true
+	return r.FindChildrenFn(parent)
+}
+
+// FindByDestination calls the FindByDestinationFn.
+func (r *CargoRepository) FindByDestination(ctx context.Context, dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo {
+	r.FindByDestinationInvoked = true
+	return r.FindByDestinationFn(dest, activeOnly)
+}
+
+// Archive calls the ArchiveFn.
+func (r *CargoRepository) Archive(ctx context.Context, id shipping.TrackingID) error {
+	r.ArchiveInvoked = true
+	return r.ArchiveFn(id)
+}
+
+// Watch calls the WatchFn.
+func (r *CargoRepository) Watch(ctx context.Context) (<-chan shipping.CargoChange, error) {
+	r.WatchInvoked = true
+	return r.WatchFn()
+}
+
 // LocationRepository is a mock location repository.
 type LocationRepository struct {
 	FindFn      func(shipping.UNLocode) (*shipping.Location, error)
@@ -41,53 +172,170 @@ type LocationRepository struct {
 
 	FindAllFn      func() []*shipping.Location
 	FindAllInvoked bool
+
+	FindByNamePrefixFn      func(prefix string) []*shipping.Location
+	FindByNamePrefixInvoked bool
 }
 
 // Find calls the FindFn.
-func (r *LocationRepository) Find(locode shipping.UNLocode) (*shipping.Location, error) {
+func (r *LocationRepository) Find(ctx context.Context, locode shipping.UNLocode) (*shipping.Location, error) {
 	r.FindInvoked = true
 	return r.FindFn(locode)
 }
 
 // FindAll calls the FindAllFn.
-func (r *LocationRepository) FindAll() []*shipping.Location {
+func (r *LocationRepository) FindAll(ctx context.Context) []*shipping.Location {
 	r.FindAllInvoked = true
 	return r.FindAllFn()
 }
 
+// FindByNamePrefix calls the FindByNamePrefixFn.
+func (r *LocationRepository) FindByNamePrefix(ctx context.Context, prefix string) []*shipping.Location {
+	r.FindByNamePrefixInvoked = true
+	return r.FindByNamePrefixFn(prefix)
+}
+
 // VoyageRepository is a mock voyage repository.
 type VoyageRepository struct {
+	StoreFn      func(*shipping.Voyage) error
+	StoreInvoked bool
+
+	UpdateFn      func(*shipping.Voyage) error
+	UpdateInvoked bool
+
 	FindFn      func(shipping.VoyageNumber) (*shipping.Voyage, error)
 	FindInvoked bool
+
+	FindByRouteFn      func(from, to shipping.UNLocode) []*shipping.Voyage
+	FindByRouteInvoked bool
+
+	FindDepartingBetweenFn      func(from shipping.UNLocode, start, end time.Time) []*shipping.Voyage
+	FindDepartingBetweenInvoked bool
+}
+
+// Store calls the StoreFn.
+func (r *VoyageRepository) Store(ctx context.Context, v *shipping.Voyage) error {
+	r.StoreInvoked = true
+	return r.StoreFn(v)
+}
+
+// Update calls the UpdateFn.
+func (r *VoyageRepository) Update(ctx context.Context, v *shipping.Voyage) error {
+	r.UpdateInvoked = true
+	return r.UpdateFn(v)
 }
 
 // Find calls the FindFn.
-func (r *VoyageRepository) Find(number shipping.VoyageNumber) (*shipping.Voyage, error) {
+func (r *VoyageRepository) Find(ctx context.Context, number shipping.VoyageNumber) (*shipping.Voyage, error) {
 	r.FindInvoked = true
 	return r.FindFn(number)
 }
 
+// FindByRoute calls the FindByRouteFn.
+func (r *VoyageRepository) FindByRoute(ctx context.Context, from, to shipping.UNLocode) []*shipping.Voyage {
+	r.FindByRouteInvoked = true
+	return r.FindByRouteFn(from, to)
+}
+
+// FindDepartingBetween calls the FindDepartingBetweenFn.
+func (r *VoyageRepository) FindDepartingBetween(ctx context.Context, from shipping.UNLocode, start, end time.Time) []*shipping.Voyage {
+	r.FindDepartingBetweenInvoked = true
+	return r.FindDepartingBetweenFn(from, start, end)
+}
+
 // HandlingEventRepository is a mock handling events repository.
 type HandlingEventRepository struct {
-	StoreFn      func(shipping.HandlingEvent)
+	StoreFn      func(shipping.HandlingEvent) error
 	StoreInvoked bool
 
 	QueryHandlingHistoryFn      func(shipping.TrackingID) shipping.HandlingHistory
 	QueryHandlingHistoryInvoked bool
+
+	FindByIdempotencyKeyFn      func(string) (shipping.HandlingEvent, error)
+	FindByIdempotencyKeyInvoked bool
+
+	QueryHandlingHistoryBetweenFn      func(shipping.TrackingID, time.Time, time.Time) shipping.HandlingHistory
+	QueryHandlingHistoryBetweenInvoked bool
+
+	StatsFn      func(from, to time.Time) (shipping.HandlingStats, error)
+	StatsInvoked bool
+
+	SupersedeFn      func(shipping.HandlingEventKey) error
+	SupersedeInvoked bool
+
+	ConfirmFn      func(shipping.HandlingEventKey) error
+	ConfirmInvoked bool
+
+	QueryHandlingEventsSinceFn      func(time.Time) []shipping.HandlingEvent
+	QueryHandlingEventsSinceInvoked bool
+
+	QuerySinceFn      func(uint64) ([]shipping.HandlingEvent, error)
+	QuerySinceInvoked bool
+
+	SearchFn      func(shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error)
+	SearchInvoked bool
 }
 
 // Store calls the StoreFn.
-func (r *HandlingEventRepository) Store(e shipping.HandlingEvent) {
+func (r *HandlingEventRepository) Store(ctx context.Context, e shipping.HandlingEvent) error {
 	r.StoreInvoked = true
-	r.StoreFn(e)
+	return r.StoreFn(e)
 }
 
 // QueryHandlingHistory calls the QueryHandlingHistoryFn.
-func (r *HandlingEventRepository) QueryHandlingHistory(id shipping.TrackingID) shipping.HandlingHistory {
+func (r *HandlingEventRepository) QueryHandlingHistory(ctx context.Context, id shipping.TrackingID) shipping.HandlingHistory {
 	r.QueryHandlingHistoryInvoked = true
 	return r.QueryHandlingHistoryFn(id)
 }
 
+// FindByIdempotencyKey calls the FindByIdempotencyKeyFn.
+func (r *HandlingEventRepository) FindByIdempotencyKey(ctx context.Context, key string) (shipping.HandlingEvent, error) {
+	r.FindByIdempotencyKeyInvoked = true
+	return r.FindByIdempotencyKeyFn(key)
+}
+
+// QueryHandlingHistoryBetween calls the QueryHandlingHistoryBetweenFn.
+func (r *HandlingEventRepository) QueryHandlingHistoryBetween(ctx context.Context, id shipping.TrackingID, from, to time.Time) shipping.HandlingHistory {
+	r.QueryHandlingHistoryBetweenInvoked = true
+	return r.QueryHandlingHistoryBetweenFn(id, from, to)
+}
+
+// Stats calls the StatsFn.
+func (r *HandlingEventRepository) Stats(ctx context.Context, from, to time.Time) (shipping.HandlingStats, error) {
+	r.StatsInvoked = true
+	return r.StatsFn(from, to)
+}
+
+// QueryHandlingEventsSince calls the QueryHandlingEventsSinceFn.
+func (r *HandlingEventRepository) QueryHandlingEventsSince(ctx context.Context, since time.Time) []shipping.HandlingEvent {
+	r.QueryHandlingEventsSinceInvoked = true
+	return r.QueryHandlingEventsSinceFn(since)
+}
+
+// QuerySince calls the QuerySinceFn.
+func (r *HandlingEventRepository) QuerySince(ctx context.Context, seq uint64) ([]shipping.HandlingEvent, error) {
+	r.QuerySinceInvoked = true
+	return r.QuerySinceFn(seq)
+}
+
+// Search calls the SearchFn.
+func (r *HandlingEventRepository) Search(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	r.SearchInvoked = true
+	return r.SearchFn(criteria)
+}
+
+// Supersede calls the SupersedeFn.
+func (r *HandlingEventRepository) Supersede(ctx context.Context, key shipping.HandlingEventKey) error {
+	r.SupersedeInvoked = true
+	return r.SupersedeFn(key)
+}
+
+// Confirm calls the ConfirmFn.
+func (r *HandlingEventRepository) Confirm(ctx context.Context, key shipping.HandlingEventKey) error {
+	r.ConfirmInvoked = true
+	return r.ConfirmFn(key)
+}
+
 // RoutingService provides a mock routing service.
 type RoutingService struct {
 	FetchRoutesFn      func(shipping.RouteSpecification) []shipping.Itinerary
@@ -95,7 +343,7 @@ type RoutingService struct {
 }
 
 // FetchRoutesForSpecification calls the FetchRoutesFn.
-func (s *RoutingService) FetchRoutesForSpecification(rs shipping.RouteSpecification) []shipping.Itinerary {
+func (s *RoutingService) FetchRoutesForSpecification(ctx context.Context, rs shipping.RouteSpecification) []shipping.Itinerary {
 	s.FetchRoutesInvoked = true
 	return s.FetchRoutesFn(rs)
 }
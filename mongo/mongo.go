@@ -1,10 +1,16 @@
 package mongo
 
 import (
+	"context"
+	"regexp"
+	"sort"
+	"time"
+
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
 	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/handling"
 )
 
 type cargoRepository struct {
@@ -12,18 +18,43 @@ type cargoRepository struct {
 	session *mgo.Session
 }
 
-func (r *cargoRepository) Store(cargo *shipping.Cargo) error {
+func (r *cargoRepository) Store(ctx context.Context, cargo *shipping.Cargo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
 	c := sess.DB(r.db).C("cargo")
 
-	_, err := c.Upsert(bson.M{"trackingid": cargo.TrackingID}, bson.M{"$set": cargo})
+	// Only the stored version is replaced. Any other version makes the upsert
+	// insert a duplicate tracking ID, which the unique index rejects.
+	selector := bson.M{"trackingid": cargo.TrackingID, "version": cargo.Version}
+	if cargo.Version == 0 {
+		selector["version"] = bson.M{"$in": []interface{}{0, nil}}
+	}
 
-	return err
+	next := *cargo
+	next.Version++
+
+	if _, err := c.Upsert(selector, bson.M{"$set": &next}); err != nil {
+		if mgo.IsDup(err) {
+			return shipping.ErrConcurrentModification
+		}
+		return err
+	}
+
+	cargo.Version = next.Version
+
+	return nil
 }
 
-func (r *cargoRepository) Find(id shipping.TrackingID) (*shipping.Cargo, error) {
+func (r *cargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
@@ -40,7 +71,53 @@ func (r *cargoRepository) Find(id shipping.TrackingID) (*shipping.Cargo, error)
 	return &result, nil
 }
 
-func (r *cargoRepository) FindAll() []*shipping.Cargo {
+func (r *cargoRepository) FindByExternalRef(ctx context.Context, ref string) (*shipping.Cargo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ref == "" {
+		return nil, shipping.ErrUnknownCargo
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C("cargo")
+
+	var result shipping.Cargo
+	if err := c.Find(bson.M{"externalref": ref}).One(&result); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, shipping.ErrUnknownCargo
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (r *cargoRepository) FindAll(ctx context.Context) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return []*shipping.Cargo{}
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C("cargo")
+
+	var result []*shipping.Cargo
+	if err := c.Find(notArchived).All(&result); err != nil {
+		return []*shipping.Cargo{}
+	}
+
+	return result
+}
+
+func (r *cargoRepository) FindAllIncludingArchived(ctx context.Context) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return []*shipping.Cargo{}
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
@@ -54,6 +131,168 @@ func (r *cargoRepository) FindAll() []*shipping.Cargo {
 	return result
 }
 
+func (r *cargoRepository) FindChildren(ctx context.Context, parent shipping.TrackingID) []*shipping.Cargo {
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C("cargo")
+
+	var result []*shipping.Cargo
+	if err := c.Find(bson.M{"parentid": parent}).All(&result); err != nil {
+		return nil
+	}
+
+	return result
+}
+
+func (r *cargoRepository) FindByDestination(ctx context.Context, dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if c.RouteSpecification.Destination != dest || (activeOnly && !c.IsActive()) {
+			continue
+		}
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		di, dj := result[i].RouteSpecification.ArrivalDeadline, result[j].RouteSpecification.ArrivalDeadline
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return result[i].TrackingID < result[j].TrackingID
+	})
+	return result
+}
+
+// Watch returns shipping.ErrWatchNotSupported, as changes made by other
+// processes sharing the database would go unnoticed.
+func (r *cargoRepository) Watch(ctx context.Context) (<-chan shipping.CargoChange, error) {
+	return nil, shipping.ErrWatchNotSupported
+}
+
+func (r *cargoRepository) FindByServiceLevel(ctx context.Context, level shipping.ServiceLevel) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if c.ServiceLevel == level {
+			result = append(result, c)
+		}
+	}
+	// The cargos share a service level, so they are ordered by deadline.
+	shipping.SortByServiceLevel(result)
+	return result
+}
+
+func (r *cargoRepository) Archive(ctx context.Context, id shipping.TrackingID) error {
+	cargo, err := r.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := cargo.Archive(); err != nil {
+		return err
+	}
+
+	return r.Store(ctx, cargo)
+}
+
+// notArchived selects the cargos that have not been archived, including those
+// stored before archiving was introduced.
+var notArchived = bson.M{"archived": bson.M{"$ne": true}}
+
+func (r *cargoRepository) FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if !c.Cancelled && c.IsAwaitingClaimBeyond(d, now) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindOverdue(ctx context.Context, now time.Time) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if c.IsOverdue(now) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindByRoutingStatus(ctx context.Context, status shipping.RoutingStatus) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if c.Delivery.RoutingStatus == status {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindByTag(ctx context.Context, key, value string) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if v, ok := c.Tags[key]; ok && v == value {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindByVoyage(ctx context.Context, number shipping.VoyageNumber) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if !c.Cancelled && c.Itinerary.IsOnVoyage(number) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindAtLocation(ctx context.Context, loc shipping.UNLocode) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if c.IsAt(loc) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindAllPaged(ctx context.Context, offset, limit int) ([]*shipping.Cargo, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if offset < 0 || limit < 0 {
+		return nil, 0, shipping.ErrInvalidArgument
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C("cargo")
+
+	total, err := c.Find(notArchived).Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := []*shipping.Cargo{}
+	if limit == 0 {
+		return result, total, nil
+	}
+
+	if err := c.Find(notArchived).Sort("trackingid").Skip(offset).Limit(limit).All(&result); err != nil {
+		return nil, 0, err
+	}
+
+	return result, total, nil
+}
+
 // NewCargoRepository returns a new instance of a MongoDB cargo repository.
 func NewCargoRepository(db string, session *mgo.Session) (shipping.CargoRepository, error) {
 	r := &cargoRepository{
@@ -86,7 +325,16 @@ type locationRepository struct {
 	session *mgo.Session
 }
 
-func (r *locationRepository) Find(locode shipping.UNLocode) (*shipping.Location, error) {
+func (r *locationRepository) Find(ctx context.Context, locode shipping.UNLocode) (*shipping.Location, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	locode, err := shipping.NewUNLocode(string(locode))
+	if err != nil {
+		return nil, shipping.ErrUnknownLocation
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
@@ -103,7 +351,11 @@ func (r *locationRepository) Find(locode shipping.UNLocode) (*shipping.Location,
 	return &result, nil
 }
 
-func (r *locationRepository) FindAll() []*shipping.Location {
+func (r *locationRepository) FindAll(ctx context.Context) []*shipping.Location {
+	if ctx.Err() != nil {
+		return []*shipping.Location{}
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
@@ -117,6 +369,30 @@ func (r *locationRepository) FindAll() []*shipping.Location {
 	return result
 }
 
+func (r *locationRepository) FindByNamePrefix(ctx context.Context, prefix string) []*shipping.Location {
+	if ctx.Err() != nil {
+		return []*shipping.Location{}
+	}
+
+	if prefix == "" {
+		return []*shipping.Location{}
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C("location")
+
+	query := bson.M{"name": bson.RegEx{Pattern: "^" + regexp.QuoteMeta(prefix), Options: "i"}}
+
+	var result []*shipping.Location
+	if err := c.Find(query).Sort("name").All(&result); err != nil {
+		return []*shipping.Location{}
+	}
+
+	return result
+}
+
 func (r *locationRepository) store(l *shipping.Location) error {
 	sess := r.session.Copy()
 	defer sess.Close()
@@ -173,7 +449,11 @@ type voyageRepository struct {
 	session *mgo.Session
 }
 
-func (r *voyageRepository) Find(voyageNumber shipping.VoyageNumber) (*shipping.Voyage, error) {
+func (r *voyageRepository) Find(ctx context.Context, voyageNumber shipping.VoyageNumber) (*shipping.Voyage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
@@ -190,15 +470,132 @@ func (r *voyageRepository) Find(voyageNumber shipping.VoyageNumber) (*shipping.V
 	return &result, nil
 }
 
-func (r *voyageRepository) store(v *shipping.Voyage) error {
+func (r *voyageRepository) FindByRoute(ctx context.Context, from, to shipping.UNLocode) []*shipping.Voyage {
+	if ctx.Err() != nil {
+		return []*shipping.Voyage{}
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
 	c := sess.DB(r.db).C("voyage")
 
-	_, err := c.Upsert(bson.M{"number": v.VoyageNumber}, bson.M{"$set": v})
+	var voyages []*shipping.Voyage
+	if err := c.Find(bson.M{}).All(&voyages); err != nil {
+		return []*shipping.Voyage{}
+	}
 
-	return err
+	type match struct {
+		voyage    *shipping.Voyage
+		departure time.Time
+	}
+
+	var matches []match
+	for _, v := range voyages {
+		if m, ok := v.Schedule.FindMovement(from, to); ok {
+			matches = append(matches, match{v, m.DepartureTime})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].departure.Before(matches[j].departure)
+	})
+
+	result := make([]*shipping.Voyage, len(matches))
+	for i, m := range matches {
+		result[i] = m.voyage
+	}
+	return result
+}
+
+func (r *voyageRepository) FindDepartingBetween(ctx context.Context, from shipping.UNLocode, start, end time.Time) []*shipping.Voyage {
+	if ctx.Err() != nil {
+		return []*shipping.Voyage{}
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C("voyage")
+
+	var voyages []*shipping.Voyage
+	if err := c.Find(bson.M{"schedule.carriermovements.departurelocation": from}).All(&voyages); err != nil {
+		return []*shipping.Voyage{}
+	}
+
+	type match struct {
+		voyage    *shipping.Voyage
+		departure time.Time
+	}
+
+	var matches []match
+	for _, v := range voyages {
+		if m, ok := v.Schedule.NextDepartureFrom(from, start); ok && m.DepartureTime.Before(end) {
+			matches = append(matches, match{v, m.DepartureTime})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].departure.Before(matches[j].departure)
+	})
+
+	result := make([]*shipping.Voyage, len(matches))
+	for i, m := range matches {
+		result[i] = m.voyage
+	}
+	return result
+}
+
+func (r *voyageRepository) Store(ctx context.Context, v *shipping.Voyage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C("voyage")
+
+	var stored shipping.Voyage
+	err := c.Find(bson.M{"number": v.VoyageNumber}).One(&stored)
+	if err == nil {
+		if !stored.Schedule.Equal(v.Schedule) {
+			return shipping.ErrVoyageConflict
+		}
+		return nil
+	}
+	if err != mgo.ErrNotFound {
+		return err
+	}
+
+	if err := c.Insert(v); err != nil {
+		if mgo.IsDup(err) {
+			return shipping.ErrVoyageConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *voyageRepository) Update(ctx context.Context, v *shipping.Voyage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C("voyage")
+
+	if err := c.Update(bson.M{"number": v.VoyageNumber}, bson.M{"$set": v}); err != nil {
+		if err == mgo.ErrNotFound {
+			return shipping.ErrUnknownVoyage
+		}
+		return err
+	}
+
+	return nil
 }
 
 // NewVoyageRepository returns a new instance of a MongoDB voyage repository.
@@ -237,42 +634,530 @@ func NewVoyageRepository(db string, session *mgo.Session) (shipping.VoyageReposi
 	}
 
 	for _, v := range initial {
-		r.store(v)
+		r.Store(context.Background(), v)
 	}
 
 	return r, nil
 }
 
 type handlingEventRepository struct {
-	db      string
-	session *mgo.Session
+	db         string
+	collection string
+	session    *mgo.Session
+}
+
+// handlingEventDocument is the BSON representation of a handling event. The
+// activity is flattened into the document so that queries and indexes can
+// refer to its fields directly.
+type handlingEventDocument struct {
+	TrackingID         string    `bson:"tracking_id"`
+	Type               int       `bson:"type"`
+	Location           string    `bson:"location"`
+	VoyageNumber       string    `bson:"voyage_number,omitempty"`
+	RegistrationTime   time.Time `bson:"registration_time"`
+	CompletionTime     time.Time `bson:"completion_time"`
+	IdempotencyKey     string    `bson:"idempotency_key,omitempty"`
+	EquipmentID        string    `bson:"equipment_id,omitempty"`
+	RecipientName      string    `bson:"recipient_name,omitempty"`
+	RecipientSignature string    `bson:"recipient_signature,omitempty"`
+	CustomsCleared     bool      `bson:"customs_cleared,omitempty"`
+	Superseded         bool      `bson:"superseded,omitempty"`
+	Planned            bool      `bson:"planned,omitempty"`
+	Instructions       string    `bson:"instructions,omitempty"`
+	Sequence           int64     `bson:"sequence"`
+
+	Amends *handlingEventKeyDocument `bson:"amends,omitempty"`
+}
+
+// handlingEventKeyDocument is the BSON representation of the key of an
+// amended event, within the same cargo as the amending event.
+type handlingEventKeyDocument struct {
+	Type           int       `bson:"type"`
+	Location       string    `bson:"location"`
+	VoyageNumber   string    `bson:"voyage_number,omitempty"`
+	CompletionTime time.Time `bson:"completion_time"`
+}
+
+// handlingEventSelector selects the document of the event identified by the
+// key.
+func handlingEventSelector(key shipping.HandlingEventKey) bson.M {
+	sel := bson.M{
+		"tracking_id":     key.TrackingID,
+		"type":            int(key.Activity.Type),
+		"location":        key.Activity.Location,
+		"completion_time": key.CompletionTime,
+	}
+	if key.Activity.VoyageNumber != "" {
+		sel["voyage_number"] = key.Activity.VoyageNumber
+	} else {
+		sel["voyage_number"] = bson.M{"$exists": false}
+	}
+	return sel
+}
+
+func newHandlingEventDocument(e shipping.HandlingEvent) handlingEventDocument {
+	var amends *handlingEventKeyDocument
+	if e.Amends != nil {
+		amends = &handlingEventKeyDocument{
+			Type:           int(e.Amends.Activity.Type),
+			Location:       string(e.Amends.Activity.Location),
+			VoyageNumber:   string(e.Amends.Activity.VoyageNumber),
+			CompletionTime: e.Amends.CompletionTime,
+		}
+	}
+
+	return handlingEventDocument{
+		TrackingID:         string(e.TrackingID),
+		Type:               int(e.Activity.Type),
+		Location:           string(e.Activity.Location),
+		VoyageNumber:       string(e.Activity.VoyageNumber),
+		RegistrationTime:   e.RegistrationTime,
+		CompletionTime:     e.CompletionTime,
+		IdempotencyKey:     e.IdempotencyKey,
+		EquipmentID:        string(e.EquipmentID),
+		RecipientName:      e.RecipientName,
+		RecipientSignature: e.RecipientSignature,
+		CustomsCleared:     e.CustomsCleared,
+		Superseded:         e.Superseded,
+		Planned:            e.Planned,
+		Instructions:       e.Instructions,
+		Sequence:           int64(e.Sequence),
+		Amends:             amends,
+	}
 }
 
-func (r *handlingEventRepository) Store(e shipping.HandlingEvent) {
+// handlingEvent converts the document back into a domain event. MongoDB
+// decodes timestamps in local time, so they are normalized to UTC.
+func (d handlingEventDocument) handlingEvent() shipping.HandlingEvent {
+	var amends *shipping.HandlingEventKey
+	if d.Amends != nil {
+		amends = &shipping.HandlingEventKey{
+			TrackingID: shipping.TrackingID(d.TrackingID),
+			Activity: shipping.HandlingActivity{
+				Type:         shipping.HandlingEventType(d.Amends.Type),
+				Location:     shipping.UNLocode(d.Amends.Location),
+				VoyageNumber: shipping.VoyageNumber(d.Amends.VoyageNumber),
+			},
+			CompletionTime: d.Amends.CompletionTime.UTC(),
+		}
+	}
+
+	return shipping.HandlingEvent{
+		TrackingID: shipping.TrackingID(d.TrackingID),
+		Activity: shipping.HandlingActivity{
+			Type:         shipping.HandlingEventType(d.Type),
+			Location:     shipping.UNLocode(d.Location),
+			VoyageNumber: shipping.VoyageNumber(d.VoyageNumber),
+		},
+		RegistrationTime:   d.RegistrationTime.UTC(),
+		CompletionTime:     d.CompletionTime.UTC(),
+		IdempotencyKey:     d.IdempotencyKey,
+		EquipmentID:        shipping.EquipmentID(d.EquipmentID),
+		RecipientName:      d.RecipientName,
+		RecipientSignature: d.RecipientSignature,
+		CustomsCleared:     d.CustomsCleared,
+		Superseded:         d.Superseded,
+		Planned:            d.Planned,
+		Instructions:       d.Instructions,
+		Sequence:           uint64(d.Sequence),
+		Amends:             amends,
+	}
+}
+
+func (r *handlingEventRepository) Store(ctx context.Context, e shipping.HandlingEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
-	c := sess.DB(r.db).C("handling_event")
+	seq, err := r.nextSequence(sess)
+	if err != nil {
+		return err
+	}
+	e.Sequence = seq
+
+	c := sess.DB(r.db).C(r.collection)
 
-	_ = c.Insert(e)
+	if err := c.Insert(newHandlingEventDocument(e)); err != nil {
+		if mgo.IsDup(err) {
+			return shipping.ErrDuplicateIdempotencyKey
+		}
+		return err
+	}
+	return nil
 }
 
-func (r *handlingEventRepository) QueryHandlingHistory(id shipping.TrackingID) shipping.HandlingHistory {
+func (r *handlingEventRepository) QueryHandlingHistory(ctx context.Context, id shipping.TrackingID) shipping.HandlingHistory {
+	if ctx.Err() != nil {
+		return shipping.HandlingHistory{}
+	}
+
 	sess := r.session.Copy()
 	defer sess.Close()
 
-	c := sess.DB(r.db).C("handling_event")
+	c := sess.DB(r.db).C(r.collection)
+
+	var docs []handlingEventDocument
+	_ = c.Find(bson.M{"tracking_id": id}).Sort("completion_time").All(&docs)
 
 	var result []shipping.HandlingEvent
-	_ = c.Find(bson.M{"trackingid": id}).All(&result)
+	for _, d := range docs {
+		result = append(result, d.handlingEvent())
+	}
 
 	return shipping.HandlingHistory{HandlingEvents: result}
 }
 
-// NewHandlingEventRepository returns a new instance of a MongoDB handling event repository.
-func NewHandlingEventRepository(db string, session *mgo.Session) shipping.HandlingEventRepository {
-	return &handlingEventRepository{
+func (r *handlingEventRepository) QueryHandlingHistoryBetween(ctx context.Context, id shipping.TrackingID, from, to time.Time) shipping.HandlingHistory {
+	if ctx.Err() != nil {
+		return shipping.HandlingHistory{}
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C(r.collection)
+
+	query := bson.M{
+		"tracking_id":     id,
+		"completion_time": bson.M{"$gte": from, "$lte": to},
+	}
+
+	var docs []handlingEventDocument
+	_ = c.Find(query).Sort("completion_time").All(&docs)
+
+	var result []shipping.HandlingEvent
+	for _, d := range docs {
+		result = append(result, d.handlingEvent())
+	}
+
+	return shipping.HandlingHistory{HandlingEvents: result}
+}
+
+func (r *handlingEventRepository) Stats(ctx context.Context, from, to time.Time) (shipping.HandlingStats, error) {
+	if err := ctx.Err(); err != nil {
+		return shipping.HandlingStats{}, err
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C(r.collection)
+
+	query := bson.M{
+		"completion_time": bson.M{"$gte": from, "$lte": to},
+	}
+
+	var docs []handlingEventDocument
+	if err := c.Find(query).All(&docs); err != nil {
+		return shipping.HandlingStats{}, err
+	}
+
+	events := make([]shipping.HandlingEvent, len(docs))
+	for i, d := range docs {
+		events[i] = d.handlingEvent()
+	}
+
+	return shipping.CountHandlingEvents(events), nil
+}
+
+func (r *handlingEventRepository) QueryHandlingEventsSince(ctx context.Context, since time.Time) []shipping.HandlingEvent {
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C(r.collection)
+
+	query := bson.M{
+		"completion_time": bson.M{"$gt": since},
+	}
+
+	var docs []handlingEventDocument
+	_ = c.Find(query).Sort("completion_time").All(&docs)
+
+	var result []shipping.HandlingEvent
+	for _, d := range docs {
+		result = append(result, d.handlingEvent())
+	}
+
+	return result
+}
+
+func (r *handlingEventRepository) QuerySince(ctx context.Context, seq uint64) ([]shipping.HandlingEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C(r.collection)
+
+	var docs []handlingEventDocument
+	if err := c.Find(bson.M{"sequence": bson.M{"$gt": int64(seq)}}).Sort("sequence").All(&docs); err != nil {
+		return nil, err
+	}
+
+	var result []shipping.HandlingEvent
+	for _, d := range docs {
+		result = append(result, d.handlingEvent())
+	}
+
+	return result, nil
+}
+
+// nextSequence returns the next sequence number of the collection. The
+// counter is incremented atomically, so that concurrent stores are given
+// distinct, increasing sequence numbers.
+func (r *handlingEventRepository) nextSequence(sess *mgo.Session) (uint64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	change := mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"seq": 1}},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	if _, err := sess.DB(r.db).C("counters").FindId(r.collection).Apply(change, &counter); err != nil {
+		return 0, err
+	}
+	return uint64(counter.Seq), nil
+}
+
+// backfillSequences numbers the events stored before events were given
+// sequence numbers, in the order they were completed, so that QuerySince
+// returns them too.
+func (r *handlingEventRepository) backfillSequences(sess *mgo.Session) error {
+	c := sess.DB(r.db).C(r.collection)
+
+	var docs []struct {
+		ID bson.ObjectId `bson:"_id"`
+	}
+	if err := c.Find(bson.M{"sequence": bson.M{"$exists": false}}).Select(bson.M{"_id": 1}).Sort("completion_time").All(&docs); err != nil {
+		return err
+	}
+
+	for _, d := range docs {
+		seq, err := r.nextSequence(sess)
+		if err != nil {
+			return err
+		}
+		if err := c.UpdateId(d.ID, bson.M{"$set": bson.M{"sequence": int64(seq)}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *handlingEventRepository) Search(ctx context.Context, criteria shipping.SearchCriteria) ([]shipping.HandlingEvent, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	if criteria.Offset < 0 || criteria.Limit < 0 {
+		return nil, 0, shipping.ErrInvalidArgument
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C(r.collection)
+
+	query := bson.M{}
+	if criteria.Type != shipping.NotHandled {
+		query["type"] = int(criteria.Type)
+	}
+	if criteria.Location != "" {
+		query["location"] = criteria.Location
+	}
+	if criteria.VoyageNumber != "" {
+		query["voyage_number"] = criteria.VoyageNumber
+	}
+	completion := bson.M{}
+	if !criteria.From.IsZero() {
+		completion["$gte"] = criteria.From
+	}
+	if !criteria.To.IsZero() {
+		completion["$lte"] = criteria.To
+	}
+	if len(completion) > 0 {
+		query["completion_time"] = completion
+	}
+
+	total, err := c.Find(query).Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var docs []handlingEventDocument
+	if err := c.Find(query).Sort("-completion_time").Skip(criteria.Offset).Limit(criteria.Limit).All(&docs); err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]shipping.HandlingEvent, len(docs))
+	for i, d := range docs {
+		result[i] = d.handlingEvent()
+	}
+
+	return result, total, nil
+}
+
+func (r *handlingEventRepository) FindByIdempotencyKey(ctx context.Context, key string) (shipping.HandlingEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return shipping.HandlingEvent{}, err
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C(r.collection)
+
+	var result handlingEventDocument
+	if err := c.Find(bson.M{"idempotency_key": key}).One(&result); err != nil {
+		if err == mgo.ErrNotFound {
+			return shipping.HandlingEvent{}, shipping.ErrUnknownHandlingEvent
+		}
+		return shipping.HandlingEvent{}, err
+	}
+
+	return result.handlingEvent(), nil
+}
+
+func (r *handlingEventRepository) Supersede(ctx context.Context, key shipping.HandlingEventKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	// The event is given a new sequence number, so that consumers of
+	// QuerySince see the change.
+	seq, err := r.nextSequence(sess)
+	if err != nil {
+		return err
+	}
+
+	c := sess.DB(r.db).C(r.collection)
+
+	err = c.Update(handlingEventSelector(key), bson.M{"$set": bson.M{"superseded": true, "sequence": int64(seq)}})
+	if err == mgo.ErrNotFound {
+		return shipping.ErrUnknownHandlingEvent
+	}
+	return err
+}
+
+func (r *handlingEventRepository) Confirm(ctx context.Context, key shipping.HandlingEventKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	seq, err := r.nextSequence(sess)
+	if err != nil {
+		return err
+	}
+
+	c := sess.DB(r.db).C(r.collection)
+
+	err = c.Update(handlingEventSelector(key), bson.M{"$unset": bson.M{"planned": ""}, "$set": bson.M{"sequence": int64(seq)}})
+	if err == mgo.ErrNotFound {
+		return shipping.ErrUnknownHandlingEvent
+	}
+	return err
+}
+
+// NewHandlingEventRepository returns a new instance of a MongoDB handling
+// event repository storing its events in the given collection.
+func NewHandlingEventRepository(db string, collection string, session *mgo.Session) (shipping.HandlingEventRepository, error) {
+	r := &handlingEventRepository{
+		db:         db,
+		collection: collection,
+		session:    session,
+	}
+
+	sess := r.session.Copy()
+	defer sess.Close()
+
+	c := sess.DB(r.db).C(r.collection)
+
+	index := mgo.Index{
+		Key:        []string{"tracking_id", "completion_time"},
+		Background: true,
+	}
+
+	if err := c.EnsureIndex(index); err != nil {
+		return nil, err
+	}
+
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"sequence"}, Background: true}); err != nil {
+		return nil, err
+	}
+
+	if err := r.backfillSequences(sess); err != nil {
+		return nil, err
+	}
+
+	// Idempotency keys are unique among the events that have one, so that
+	// concurrent retries of a registration store it once.
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"idempotency_key"}, Unique: true, Sparse: true, Background: true}); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+type offsetStore struct {
+	db      string
+	name    string
+	session *mgo.Session
+}
+
+func (s *offsetStore) Offset(ctx context.Context) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	sess := s.session.Copy()
+	defer sess.Close()
+
+	var doc struct {
+		Offset int64 `bson:"offset"`
+	}
+	err := sess.DB(s.db).C("offsets").FindId(s.name).One(&doc)
+	if err == mgo.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint64(doc.Offset), nil
+}
+
+func (s *offsetStore) SaveOffset(ctx context.Context, offset uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sess := s.session.Copy()
+	defer sess.Close()
+
+	_, err := sess.DB(s.db).C("offsets").UpsertId(s.name, bson.M{"$set": bson.M{"offset": int64(offset)}})
+	return err
+}
+
+// NewOffsetStore returns a new instance of an offset store of an async event
+// handler, kept in the offsets collection under the given name.
+func NewOffsetStore(db string, name string, session *mgo.Session) handling.OffsetStore {
+	return &offsetStore{
 		db:      db,
+		name:    name,
 		session: session,
 	}
 }
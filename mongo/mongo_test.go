@@ -0,0 +1,150 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+const testDBName = "goddd_test"
+
+// dial connects to the server given by MONGO_URL, e.g. 127.0.0.1. The test is
+// skipped if MONGO_URL is not set.
+func dial(t *testing.T) *mgo.Session {
+	url := os.Getenv("MONGO_URL")
+	if url == "" {
+		t.Skip("MONGO_URL not set")
+	}
+
+	session, err := mgo.Dial(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.SetMode(mgo.Monotonic, true)
+
+	return session
+}
+
+func TestHandlingEventDocument(t *testing.T) {
+	completed := time.Date(2009, time.March, 2, 12, 0, 0, 0, time.UTC)
+
+	e := shipping.HandlingEvent{
+		TrackingID: "ABC123",
+		Activity: shipping.HandlingActivity{
+			Type:         shipping.Load,
+			Location:     shipping.SESTO,
+			VoyageNumber: "V100",
+		},
+		RegistrationTime: completed.Add(time.Hour),
+		CompletionTime:   completed,
+		IdempotencyKey:   "load-1",
+		EquipmentID:      "MSKU1234565",
+		Instructions:     "Keep refrigerated",
+		Sequence:         7,
+	}
+
+	claim := shipping.HandlingEvent{
+		TrackingID: "ABC123",
+		Activity: shipping.HandlingActivity{
+			Type:     shipping.Claim,
+			Location: shipping.AUMEL,
+		},
+		RegistrationTime:   completed.Add(time.Hour),
+		CompletionTime:     completed,
+		RecipientName:      "Jane Doe",
+		RecipientSignature: "c2lnbmF0dXJl",
+		Sequence:           8,
+	}
+
+	customs := shipping.HandlingEvent{
+		TrackingID: "ABC123",
+		Activity: shipping.HandlingActivity{
+			Type:     shipping.Customs,
+			Location: shipping.AUMEL,
+		},
+		RegistrationTime: completed.Add(time.Hour),
+		CompletionTime:   completed,
+		CustomsCleared:   true,
+		Sequence:         9,
+	}
+
+	for _, e := range []shipping.HandlingEvent{e, claim, customs} {
+		if got := newHandlingEventDocument(e).handlingEvent(); !reflect.DeepEqual(got, e) {
+			t.Errorf("handlingEvent() = %v; want = %v", got, e)
+		}
+	}
+}
+
+func TestHandlingEventRepository(t *testing.T) {
+	ctx := context.Background()
+
+	session := dial(t)
+	defer session.Close()
+
+	collection := fmt.Sprintf("handling_event_%d", time.Now().UnixNano())
+	defer session.DB(testDBName).C(collection).DropCollection()
+
+	r, err := NewHandlingEventRepository(testDBName, collection, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		id       = shipping.TrackingID("ABC123")
+		received = time.Date(2009, time.March, 1, 12, 0, 0, 0, time.UTC)
+		loaded   = time.Date(2009, time.March, 2, 12, 0, 0, 0, time.UTC)
+	)
+
+	load := shipping.HandlingEvent{
+		TrackingID: id,
+		Activity: shipping.HandlingActivity{
+			Type:         shipping.Load,
+			Location:     shipping.SESTO,
+			VoyageNumber: "V100",
+		},
+		RegistrationTime: loaded.Add(time.Hour),
+		CompletionTime:   loaded,
+		IdempotencyKey:   "load-1",
+		EquipmentID:      "MSKU1234565",
+	}
+	receive := shipping.HandlingEvent{
+		TrackingID: id,
+		Activity: shipping.HandlingActivity{
+			Type:     shipping.Receive,
+			Location: shipping.SESTO,
+		},
+		RegistrationTime: received.Add(time.Hour),
+		CompletionTime:   received,
+	}
+
+	// Store out of order to verify that the history is sorted.
+	r.Store(ctx, load)
+	r.Store(ctx, receive)
+	r.Store(ctx, shipping.HandlingEvent{TrackingID: "XYZ789"})
+
+	got := r.QueryHandlingHistory(ctx, id).HandlingEvents
+	want := []shipping.HandlingEvent{receive, load}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryHandlingHistory(%q) = %v; want = %v", id, got, want)
+	}
+
+	e, err := r.FindByIdempotencyKey(ctx, "load-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e, load) {
+		t.Errorf("FindByIdempotencyKey() = %v; want = %v", e, load)
+	}
+
+	if _, err := r.FindByIdempotencyKey(ctx, "no_such_key"); err != shipping.ErrUnknownHandlingEvent {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownHandlingEvent)
+	}
+}
@@ -0,0 +1,36 @@
+package shipping
+
+import "context"
+
+// OutboxEntry records that the handlers of a stored handling event are yet to
+// be notified of it.
+type OutboxEntry struct {
+	ID          int64
+	Event       HandlingEvent
+	Correlation CorrelationID
+}
+
+// HandlingEventOutbox is implemented by the handling event repositories that
+// can store an event together with an outbox entry for it, in one unit. A
+// relay notifies the handlers of the pending entries, so that a handled
+// cargo is eventually inspected even if the process stops right after the
+// event is stored.
+type HandlingEventOutbox interface {
+	// StoreWithOutbox stores the event like Store does, and an outbox entry
+	// for it with the given correlation ID. Either both are stored or
+	// neither is.
+	StoreWithOutbox(ctx context.Context, e HandlingEvent, correlation CorrelationID) error
+
+	// ConfirmWithOutbox confirms the planned event like Confirm does, and
+	// stores an outbox entry for the confirmed event with the given
+	// correlation ID. Either both are stored or neither is.
+	ConfirmWithOutbox(ctx context.Context, key HandlingEventKey, correlation CorrelationID) error
+
+	// PendingOutbox returns the entries not yet marked done, in the order
+	// they were stored.
+	PendingOutbox(ctx context.Context) ([]OutboxEntry, error)
+
+	// MarkOutboxDone marks the entry as done. Marking an unknown entry, or
+	// one already done, has no effect.
+	MarkOutboxDone(ctx context.Context, id int64) error
+}
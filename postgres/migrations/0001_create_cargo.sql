@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS cargo (
+	tracking_id      TEXT PRIMARY KEY,
+	origin           TEXT NOT NULL,
+	spec_origin      TEXT NOT NULL,
+	spec_destination TEXT NOT NULL,
+	arrival_deadline TIMESTAMPTZ NOT NULL,
+	itinerary        JSONB NOT NULL,
+	delivery         JSONB NOT NULL,
+	cancelled        BOOLEAN NOT NULL DEFAULT FALSE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS weight DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS volume DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS alternates JSONB NOT NULL DEFAULT 'null';
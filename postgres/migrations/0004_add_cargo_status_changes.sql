@@ -0,0 +1 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS status_changes JSONB NOT NULL DEFAULT 'null';
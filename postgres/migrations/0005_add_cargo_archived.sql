@@ -0,0 +1 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS parent_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS cargo_parent_id ON cargo (parent_id);
@@ -0,0 +1,3 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS service_level INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS cargo_service_level ON cargo (service_level);
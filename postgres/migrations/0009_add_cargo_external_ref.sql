@@ -0,0 +1,3 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS external_ref TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS cargo_external_ref ON cargo (external_ref) WHERE external_ref <> '';
@@ -0,0 +1,2 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS on_hold BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS hold_reason TEXT NOT NULL DEFAULT '';
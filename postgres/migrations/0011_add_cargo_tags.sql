@@ -0,0 +1 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT 'null';
@@ -0,0 +1,2 @@
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS customer_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE cargo ADD COLUMN IF NOT EXISTS ownership_log JSONB NOT NULL DEFAULT 'null';
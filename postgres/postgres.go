@@ -0,0 +1,412 @@
+// Package postgres provides PostgreSQL implementations of the domain
+// repositories.
+//
+// The schema is found in the migrations directory and is expected to be
+// applied before the repositories are used.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type cargoRepository struct {
+	db *sql.DB
+}
+
+func (r *cargoRepository) Store(ctx context.Context, c *shipping.Cargo) error {
+	itinerary, err := json.Marshal(c.Itinerary)
+	if err != nil {
+		return err
+	}
+
+	delivery, err := json.Marshal(c.Delivery)
+	if err != nil {
+		return err
+	}
+
+	alternates, err := json.Marshal(c.AlternateRouteSpecifications)
+	if err != nil {
+		return err
+	}
+
+	statusChanges, err := json.Marshal(c.StatusChanges)
+	if err != nil {
+		return err
+	}
+
+	tags, err := json.Marshal(c.Tags)
+	if err != nil {
+		return err
+	}
+
+	ownershipLog, err := json.Marshal(c.OwnershipLog)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO cargo (tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+		ON CONFLICT (tracking_id) DO UPDATE SET
+			origin = EXCLUDED.origin,
+			spec_origin = EXCLUDED.spec_origin,
+			spec_destination = EXCLUDED.spec_destination,
+			arrival_deadline = EXCLUDED.arrival_deadline,
+			itinerary = EXCLUDED.itinerary,
+			delivery = EXCLUDED.delivery,
+			cancelled = EXCLUDED.cancelled,
+			weight = EXCLUDED.weight,
+			volume = EXCLUDED.volume,
+			alternates = EXCLUDED.alternates,
+			status_changes = EXCLUDED.status_changes,
+			archived = EXCLUDED.archived,
+			version = EXCLUDED.version,
+			parent_id = EXCLUDED.parent_id,
+			service_level = EXCLUDED.service_level,
+			external_ref = EXCLUDED.external_ref,
+			on_hold = EXCLUDED.on_hold,
+			hold_reason = EXCLUDED.hold_reason,
+			tags = EXCLUDED.tags,
+			customer_id = EXCLUDED.customer_id,
+			ownership_log = EXCLUDED.ownership_log
+		WHERE cargo.version = EXCLUDED.version - 1`,
+		c.TrackingID,
+		c.Origin,
+		c.RouteSpecification.Origin,
+		c.RouteSpecification.Destination,
+		c.RouteSpecification.ArrivalDeadline,
+		itinerary,
+		delivery,
+		c.Cancelled,
+		c.Weight,
+		c.Volume,
+		alternates,
+		statusChanges,
+		c.Archived,
+		c.Version+1,
+		c.ParentID,
+		c.ServiceLevel,
+		c.ExternalRef,
+		c.OnHold,
+		c.HoldReason,
+		tags,
+		c.CustomerID,
+		ownershipLog,
+	)
+	if err != nil {
+		return err
+	}
+
+	// The row is left untouched if it has been stored with another version.
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return shipping.ErrConcurrentModification
+	}
+
+	c.Version++
+
+	return nil
+}
+
+func (r *cargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log
+		FROM cargo
+		WHERE tracking_id = $1`, id)
+
+	c, err := scanCargo(row)
+	if err == sql.ErrNoRows {
+		return nil, shipping.ErrUnknownCargo
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *cargoRepository) FindByExternalRef(ctx context.Context, ref string) (*shipping.Cargo, error) {
+	if ref == "" {
+		return nil, shipping.ErrUnknownCargo
+	}
+
+	row := r.db.QueryRowContext(ctx, `
+		SELECT tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log
+		FROM cargo
+		WHERE external_ref = $1`, ref)
+
+	c, err := scanCargo(row)
+	if err == sql.ErrNoRows {
+		return nil, shipping.ErrUnknownCargo
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *cargoRepository) FindAll(ctx context.Context) []*shipping.Cargo {
+	return r.findAll(ctx, `
+		SELECT tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log
+		FROM cargo
+		WHERE NOT archived`)
+}
+
+func (r *cargoRepository) FindAllIncludingArchived(ctx context.Context) []*shipping.Cargo {
+	return r.findAll(ctx, `
+		SELECT tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log
+		FROM cargo`)
+}
+
+func (r *cargoRepository) FindChildren(ctx context.Context, parent shipping.TrackingID) []*shipping.Cargo {
+	return r.findAll(ctx, `
+		SELECT tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log
+		FROM cargo
+		WHERE parent_id = $1`, parent)
+}
+
+func (r *cargoRepository) FindByDestination(ctx context.Context, dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo {
+	cargos := r.findAll(ctx, `
+		SELECT tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log
+		FROM cargo
+		WHERE spec_destination = $1 AND NOT archived
+		ORDER BY arrival_deadline, tracking_id`, dest)
+	if !activeOnly {
+		return cargos
+	}
+
+	// The transport status is only found in the encoded delivery.
+	var result []*shipping.Cargo
+	for _, c := range cargos {
+		if c.IsActive() {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// Watch returns shipping.ErrWatchNotSupported, as changes made by other
+// processes sharing the database would go unnoticed.
+func (r *cargoRepository) Watch(ctx context.Context) (<-chan shipping.CargoChange, error) {
+	return nil, shipping.ErrWatchNotSupported
+}
+
+func (r *cargoRepository) FindByServiceLevel(ctx context.Context, level shipping.ServiceLevel) []*shipping.Cargo {
+	return r.findAll(ctx, `
+		SELECT tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log
+		FROM cargo
+		WHERE service_level = $1 AND NOT archived
+		ORDER BY arrival_deadline, tracking_id`, level)
+}
+
+func (r *cargoRepository) findAll(ctx context.Context, query string, args ...interface{}) []*shipping.Cargo {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return []*shipping.Cargo{}
+	}
+	defer rows.Close()
+
+	var result []*shipping.Cargo
+	for rows.Next() {
+		c, err := scanCargo(rows)
+		if err != nil {
+			return []*shipping.Cargo{}
+		}
+		result = append(result, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []*shipping.Cargo{}
+	}
+
+	return result
+}
+
+func (r *cargoRepository) FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if !c.Cancelled && c.IsAwaitingClaimBeyond(d, now) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindOverdue(ctx context.Context, now time.Time) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if c.IsOverdue(now) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindByRoutingStatus(ctx context.Context, status shipping.RoutingStatus) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if c.Delivery.RoutingStatus == status {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindByTag(ctx context.Context, key, value string) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if v, ok := c.Tags[key]; ok && v == value {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindByVoyage(ctx context.Context, number shipping.VoyageNumber) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if !c.Cancelled && c.Itinerary.IsOnVoyage(number) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) FindAtLocation(ctx context.Context, loc shipping.UNLocode) []*shipping.Cargo {
+	var result []*shipping.Cargo
+	for _, c := range r.FindAll(ctx) {
+		if c.IsAt(loc) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *cargoRepository) Archive(ctx context.Context, id shipping.TrackingID) error {
+	c, err := r.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Archive(); err != nil {
+		return err
+	}
+
+	return r.Store(ctx, c)
+}
+
+func (r *cargoRepository) FindAllPaged(ctx context.Context, offset, limit int) ([]*shipping.Cargo, int, error) {
+	if offset < 0 || limit < 0 {
+		return nil, 0, shipping.ErrInvalidArgument
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM cargo WHERE NOT archived`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT tracking_id, origin, spec_origin, spec_destination, arrival_deadline, itinerary, delivery, cancelled, weight, volume, alternates, status_changes, archived, version, parent_id, service_level, external_ref, on_hold, hold_reason, tags, customer_id, ownership_log
+		FROM cargo
+		WHERE NOT archived
+		ORDER BY tracking_id
+		LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	result := []*shipping.Cargo{}
+	for rows.Next() {
+		c, err := scanCargo(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return result, total, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCargo(s scanner) (*shipping.Cargo, error) {
+	var (
+		c          shipping.Cargo
+		itinerary  []byte
+		delivery   []byte
+		alternates []byte
+		changes    []byte
+		tags       []byte
+		ownership  []byte
+	)
+
+	err := s.Scan(
+		&c.TrackingID,
+		&c.Origin,
+		&c.RouteSpecification.Origin,
+		&c.RouteSpecification.Destination,
+		&c.RouteSpecification.ArrivalDeadline,
+		&itinerary,
+		&delivery,
+		&c.Cancelled,
+		&c.Weight,
+		&c.Volume,
+		&alternates,
+		&changes,
+		&c.Archived,
+		&c.Version,
+		&c.ParentID,
+		&c.ServiceLevel,
+		&c.ExternalRef,
+		&c.OnHold,
+		&c.HoldReason,
+		&tags,
+		&c.CustomerID,
+		&ownership,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(itinerary, &c.Itinerary); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(delivery, &c.Delivery); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(alternates, &c.AlternateRouteSpecifications); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(changes, &c.StatusChanges); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(tags, &c.Tags); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(ownership, &c.OwnershipLog); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// NewCargoRepository returns a new instance of a PostgreSQL cargo repository.
+func NewCargoRepository(db *sql.DB) shipping.CargoRepository {
+	return &cargoRepository{db: db}
+}
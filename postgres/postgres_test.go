@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// openDB connects to the database given by POSTGRES_URL, e.g.
+// postgres://postgres@localhost/dddsample?sslmode=disable, and applies the
+// schema. The test is skipped if POSTGRES_URL is not set.
+func openDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("POSTGRES_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := filepath.Glob("migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+
+	for _, m := range migrations {
+		schema, err := ioutil.ReadFile(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(schema)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("TRUNCATE cargo"); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestCargoRepository(t *testing.T) {
+	ctx := context.Background()
+
+	db := openDB(t)
+	defer db.Close()
+
+	r := NewCargoRepository(db)
+
+	deadline := time.Date(2009, time.March, 13, 0, 0, 0, 0, time.UTC)
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.AUMEL,
+		ArrivalDeadline: deadline,
+	})
+
+	if _, err := r.Find(ctx, c.TrackingID); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+
+	if err := r.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		shipping.NewLeg("V100", shipping.SESTO, shipping.AUMEL, deadline.AddDate(0, 0, -5), deadline.AddDate(0, 0, -1)),
+	}})
+
+	if err := r.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := r.Find(ctx, c.TrackingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found.RouteSpecification.Destination != shipping.AUMEL {
+		t.Errorf("found.RouteSpecification.Destination = %s; want = %s", found.RouteSpecification.Destination, shipping.AUMEL)
+	}
+	if !found.RouteSpecification.ArrivalDeadline.Equal(deadline) {
+		t.Errorf("found.RouteSpecification.ArrivalDeadline = %s; want = %s", found.RouteSpecification.ArrivalDeadline, deadline)
+	}
+	if len(found.Itinerary.Legs) != 1 {
+		t.Errorf("len(found.Itinerary.Legs) = %d; want = %d", len(found.Itinerary.Legs), 1)
+	}
+	if found.Delivery.RoutingStatus != shipping.Routed {
+		t.Errorf("found.Delivery.RoutingStatus = %s; want = %s", found.Delivery.RoutingStatus, shipping.Routed)
+	}
+
+	if all := r.FindAll(ctx); len(all) != 1 {
+		t.Errorf("len(FindAll()) = %d; want = %d", len(all), 1)
+	}
+}
@@ -1,8 +1,10 @@
 package shipping
 
+import "context"
+
 // RoutingService is a domain service for routing cargos.
 type RoutingService interface {
 	// FetchRoutesForSpecification finds all possible routes that satisfy a
 	// given specification.
-	FetchRoutesForSpecification(rs RouteSpecification) []Itinerary
+	FetchRoutesForSpecification(ctx context.Context, rs RouteSpecification) []Itinerary
 }
@@ -0,0 +1,150 @@
+package routing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// ErrRoutingUnavailable is returned while the circuit breaker is open.
+var ErrRoutingUnavailable = shipping.NewError(shipping.CodeUnavailable, "routing service unavailable")
+
+// BreakerState describes the state of a circuit breaker.
+type BreakerState int
+
+// Valid circuit breaker states.
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "Closed"
+	case BreakerOpen:
+		return "Open"
+	case BreakerHalfOpen:
+		return "Half-open"
+	}
+	return ""
+}
+
+// CircuitBreaker stops requests to the routing service after a number of
+// consecutive failures. Once the cooldown has passed, a single request is let
+// through to probe whether the service has recovered.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	clock     shipping.Clock
+
+	mtx      sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker returns a closed circuit breaker that opens after
+// threshold consecutive failures, and half-opens after cooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		clock:     shipping.SystemClock,
+	}
+}
+
+// State returns the current state of the circuit breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.currentState()
+}
+
+// currentState half-opens the breaker once the cooldown has passed. The
+// caller must hold the lock.
+func (b *CircuitBreaker) currentState() BreakerState {
+	if b.state == BreakerOpen && !b.clock.Now().Before(b.openedAt.Add(b.cooldown)) {
+		b.state = BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a request may be made, and whether it is the probe
+// of a half-open breaker.
+func (b *CircuitBreaker) allow() (ok, probe bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	switch b.currentState() {
+	case BreakerOpen:
+		return false, false
+	case BreakerHalfOpen:
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	}
+	return true, false
+}
+
+func (b *CircuitBreaker) done(probe bool, err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if probe || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.clock.Now()
+	}
+}
+
+// abandon ends a request that the caller gave up on, which says nothing
+// about the health of the routing service.
+func (b *CircuitBreaker) abandon(probe bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+}
+
+// Middleware returns an endpoint middleware that is guarded by the circuit
+// breaker.
+func (b *CircuitBreaker) Middleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ok, probe := b.allow()
+			if !ok {
+				return nil, ErrRoutingUnavailable
+			}
+
+			response, err := next(ctx, request)
+			if err != nil && ctx.Err() != nil {
+				b.abandon(probe)
+			} else {
+				b.done(probe, err)
+			}
+
+			return response, err
+		}
+	}
+}
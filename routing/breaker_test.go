@@ -0,0 +1,101 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewCircuitBreaker(2, time.Minute)
+	b.clock = shipping.ClockFunc(func() time.Time { return now })
+
+	var (
+		calls   int
+		failure = errors.New("unreachable")
+		err     error
+	)
+
+	e := b.Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		calls++
+		return nil, err
+	})
+
+	assertState := func(want BreakerState) {
+		t.Helper()
+		if got := b.State(); got != want {
+			t.Errorf("State() = %s; want = %s", got, want)
+		}
+	}
+
+	err = failure
+	e(ctx, nil)
+	assertState(BreakerClosed)
+	e(ctx, nil)
+	assertState(BreakerOpen)
+
+	if _, got := e(ctx, nil); got != ErrRoutingUnavailable {
+		t.Errorf("err = %v; want = %v", got, ErrRoutingUnavailable)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d; want = %d", calls, 2)
+	}
+
+	now = now.Add(time.Minute)
+	assertState(BreakerHalfOpen)
+
+	// A failed probe opens the breaker again.
+	e(ctx, nil)
+	assertState(BreakerOpen)
+	if calls != 3 {
+		t.Errorf("calls = %d; want = %d", calls, 3)
+	}
+
+	now = now.Add(time.Minute)
+	assertState(BreakerHalfOpen)
+
+	err = nil
+	if _, got := e(ctx, nil); got != nil {
+		t.Errorf("err = %v; want = %v", got, nil)
+	}
+	assertState(BreakerClosed)
+
+	// The failures before the recovery are forgotten.
+	err = failure
+	e(ctx, nil)
+	assertState(BreakerClosed)
+}
+
+func TestCircuitBreaker_CallerCancelled(t *testing.T) {
+	now := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewCircuitBreaker(1, time.Minute)
+	b.clock = shipping.ClockFunc(func() time.Time { return now })
+
+	e := b.Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancel := context.WithDeadline(context.Background(), now)
+	defer cancel()
+
+	for _, ctx := range []context.Context{cancelled, expired} {
+		if _, err := e(ctx, nil); err != ctx.Err() {
+			t.Errorf("err = %v; want = %v", err, ctx.Err())
+		}
+		if got := b.State(); got != BreakerClosed {
+			t.Errorf("State() = %s; want = %s", got, BreakerClosed)
+		}
+	}
+}
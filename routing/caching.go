@@ -0,0 +1,110 @@
+package routing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type cacheEntry struct {
+	itineraries []shipping.Itinerary
+	expires     time.Time
+}
+
+// flight is an upstream request that concurrent identical requests wait for.
+type flight struct {
+	done        chan struct{}
+	itineraries []shipping.Itinerary
+
+	// cancelled is set if the context of the request was done before the
+	// next service replied, in which case there is nothing to share.
+	cancelled bool
+}
+
+type cachingService struct {
+	next  shipping.RoutingService
+	ttl   time.Duration
+	clock shipping.Clock
+
+	mtx     sync.Mutex
+	entries map[string]cacheEntry
+	flights map[string]*flight
+}
+
+func (s *cachingService) FetchRoutesForSpecification(ctx context.Context, rs shipping.RouteSpecification) []shipping.Itinerary {
+	key := rs.Key()
+
+	for {
+		s.mtx.Lock()
+		if e, ok := s.entries[key]; ok && s.clock.Now().Before(e.expires) {
+			s.mtx.Unlock()
+			return copyItineraries(e.itineraries)
+		}
+		if f, ok := s.flights[key]; ok {
+			s.mtx.Unlock()
+			select {
+			case <-f.done:
+				if f.cancelled {
+					// Retry under the context of this request.
+					continue
+				}
+				return copyItineraries(f.itineraries)
+			case <-ctx.Done():
+				return []shipping.Itinerary{}
+			}
+		}
+		f := &flight{done: make(chan struct{})}
+		s.flights[key] = f
+		s.mtx.Unlock()
+
+		f.itineraries = s.next.FetchRoutesForSpecification(ctx, rs)
+		f.cancelled = ctx.Err() != nil
+
+		s.mtx.Lock()
+		delete(s.flights, key)
+		// The proxy reports failures as no routes, so they are not cached.
+		if len(f.itineraries) > 0 && !f.cancelled {
+			s.entries[key] = cacheEntry{
+				itineraries: f.itineraries,
+				expires:     s.clock.Now().Add(s.ttl),
+			}
+		}
+		s.mtx.Unlock()
+
+		close(f.done)
+
+		return copyItineraries(f.itineraries)
+	}
+}
+
+// copyItineraries returns a copy of the itineraries that callers can modify
+// without affecting the cached ones.
+func copyItineraries(itineraries []shipping.Itinerary) []shipping.Itinerary {
+	if itineraries == nil {
+		return nil
+	}
+	result := make([]shipping.Itinerary, len(itineraries))
+	for i, it := range itineraries {
+		it.Legs = append([]shipping.Leg(nil), it.Legs...)
+		result[i] = it
+	}
+	return result
+}
+
+// NewCachingMiddleware returns a middleware that remembers the routes
+// fetched for a route specification for the given duration. Concurrent
+// requests for the same route specification share a single request to the
+// next service.
+func NewCachingMiddleware(ttl time.Duration) ServiceMiddleware {
+	return func(next shipping.RoutingService) shipping.RoutingService {
+		return &cachingService{
+			next:    next,
+			ttl:     ttl,
+			clock:   shipping.SystemClock,
+			entries: make(map[string]cacheEntry),
+			flights: make(map[string]*flight),
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package routing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type countingRoutingService struct {
+	mtx     sync.Mutex
+	calls   int
+	release chan struct{}
+}
+
+func (s *countingRoutingService) FetchRoutesForSpecification(ctx context.Context, rs shipping.RouteSpecification) []shipping.Itinerary {
+	s.mtx.Lock()
+	s.calls++
+	s.mtx.Unlock()
+
+	if s.release != nil {
+		<-s.release
+	}
+
+	return []shipping.Itinerary{
+		{Legs: []shipping.Leg{{LoadLocation: rs.Origin, UnloadLocation: rs.Destination}}},
+	}
+}
+
+func TestCachingMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	var next countingRoutingService
+
+	s := NewCachingMiddleware(time.Minute)(&next).(*cachingService)
+	s.clock = shipping.ClockFunc(func() time.Time { return now })
+
+	rs := shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL}
+
+	s.FetchRoutesForSpecification(ctx, rs)
+	s.FetchRoutesForSpecification(ctx, rs)
+
+	if next.calls != 1 {
+		t.Errorf("calls = %d; want = %d", next.calls, 1)
+	}
+
+	s.FetchRoutesForSpecification(ctx, shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.CNHKG})
+
+	if next.calls != 2 {
+		t.Errorf("calls = %d; want = %d", next.calls, 2)
+	}
+
+	now = now.Add(time.Minute)
+
+	if got := s.FetchRoutesForSpecification(ctx, rs); len(got) != 1 {
+		t.Errorf("len(FetchRoutesForSpecification()) = %d; want = %d", len(got), 1)
+	}
+
+	if next.calls != 3 {
+		t.Errorf("calls = %d; want = %d", next.calls, 3)
+	}
+}
+
+func TestCachingMiddleware_Concurrent(t *testing.T) {
+	ctx := context.Background()
+
+	next := countingRoutingService{release: make(chan struct{})}
+
+	s := NewCachingMiddleware(time.Minute)(&next)
+
+	rs := shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := s.FetchRoutesForSpecification(ctx, rs); len(got) != 1 {
+				t.Errorf("len(FetchRoutesForSpecification()) = %d; want = %d", len(got), 1)
+			}
+		}()
+	}
+
+	// Give the requests time to line up behind the first one.
+	time.Sleep(10 * time.Millisecond)
+	close(next.release)
+
+	wg.Wait()
+
+	if next.calls != 1 {
+		t.Errorf("calls = %d; want = %d", next.calls, 1)
+	}
+}
+
+func TestCachingMiddleware_Copies(t *testing.T) {
+	ctx := context.Background()
+
+	var next countingRoutingService
+
+	s := NewCachingMiddleware(time.Minute)(&next)
+
+	rs := shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL}
+
+	got := s.FetchRoutesForSpecification(ctx, rs)
+	got[0].Legs[0].UnloadLocation = shipping.CNHKG
+	got[0] = shipping.Itinerary{}
+
+	got = s.FetchRoutesForSpecification(ctx, rs)
+	if len(got[0].Legs) != 1 || got[0].Legs[0].UnloadLocation != shipping.AUMEL {
+		t.Errorf("FetchRoutesForSpecification() = %v; want unmodified routes", got)
+	}
+}
+
+// cancellingRoutingService blocks the first request until its context is
+// done, and replies to the following ones immediately.
+type cancellingRoutingService struct {
+	mtx     sync.Mutex
+	calls   int
+	started chan struct{}
+}
+
+func (s *cancellingRoutingService) FetchRoutesForSpecification(ctx context.Context, rs shipping.RouteSpecification) []shipping.Itinerary {
+	s.mtx.Lock()
+	s.calls++
+	first := s.calls == 1
+	s.mtx.Unlock()
+
+	if first {
+		close(s.started)
+		<-ctx.Done()
+		return nil
+	}
+
+	return []shipping.Itinerary{
+		{Legs: []shipping.Leg{{LoadLocation: rs.Origin, UnloadLocation: rs.Destination}}},
+	}
+}
+
+func TestCachingMiddleware_LeaderCancelled(t *testing.T) {
+	next := cancellingRoutingService{started: make(chan struct{})}
+
+	s := NewCachingMiddleware(time.Minute)(&next)
+
+	rs := shipping.RouteSpecification{Origin: shipping.SESTO, Destination: shipping.AUMEL}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.FetchRoutesForSpecification(leaderCtx, rs)
+	}()
+
+	<-next.started
+
+	result := make(chan []shipping.Itinerary)
+	go func() {
+		result <- s.FetchRoutesForSpecification(context.Background(), rs)
+	}()
+
+	// Give the follower time to line up behind the leader.
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	<-done
+
+	if got := <-result; len(got) != 1 {
+		t.Errorf("len(FetchRoutesForSpecification()) = %d; want = %d", len(got), 1)
+	}
+	if next.calls != 2 {
+		t.Errorf("calls = %d; want = %d", next.calls, 2)
+	}
+}
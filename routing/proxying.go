@@ -3,11 +3,11 @@ package routing
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"time"
 
-	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
 	kithttp "github.com/go-kit/kit/transport/http"
 
@@ -15,59 +15,68 @@ import (
 )
 
 type proxyService struct {
-	context.Context
 	FetchRoutesEndpoint endpoint.Endpoint
 	shipping.RoutingService
 }
 
-func (s proxyService) FetchRoutesForSpecification(rs shipping.RouteSpecification) []shipping.Itinerary {
-	response, err := s.FetchRoutesEndpoint(s.Context, fetchRoutesRequest{
-		From: string(rs.Origin),
-		To:   string(rs.Destination),
-	})
+func (s proxyService) FetchRoutesForSpecification(ctx context.Context, rs shipping.RouteSpecification) []shipping.Itinerary {
+	response, err := s.FetchRoutesEndpoint(ctx, rs)
 	if err != nil {
 		return []shipping.Itinerary{}
 	}
 
-	resp := response.(fetchRoutesResponse)
-
-	var itineraries []shipping.Itinerary
-	for _, r := range resp.Paths {
-		var legs []shipping.Leg
-		for _, e := range r.Edges {
-			legs = append(legs, shipping.Leg{
-				VoyageNumber:   shipping.VoyageNumber(e.Voyage),
-				LoadLocation:   shipping.UNLocode(e.Origin),
-				UnloadLocation: shipping.UNLocode(e.Destination),
-				LoadTime:       e.Departure,
-				UnloadTime:     e.Arrival,
-			})
-		}
-
-		itineraries = append(itineraries, shipping.Itinerary{Legs: legs})
-	}
-
-	return itineraries
+	return response.([]shipping.Itinerary)
 }
 
 // ServiceMiddleware defines a middleware for a routing service.
 type ServiceMiddleware func(shipping.RoutingService) shipping.RoutingService
 
-// NewProxyingMiddleware returns a new instance of a proxying middleware.
-func NewProxyingMiddleware(ctx context.Context, proxyURL string) ServiceMiddleware {
+// NewProxyingMiddleware returns a new instance of a proxying middleware,
+// speaking the wire format of DefaultCodec. The given endpoint middlewares,
+// such as Retry or a CircuitBreaker, are applied to the request to the
+// routing service in order, so that the last one is the outermost.
+func NewProxyingMiddleware(ctx context.Context, proxyURL string, mw ...endpoint.Middleware) ServiceMiddleware {
+	return NewProxyingMiddlewareWithCodec(ctx, proxyURL, DefaultCodec, mw...)
+}
+
+// NewProxyingMiddlewareWithCodec returns a new instance of a proxying
+// middleware, speaking the wire format of the given codec.
+func NewProxyingMiddlewareWithCodec(ctx context.Context, proxyURL string, codec RouteCodec, mw ...endpoint.Middleware) ServiceMiddleware {
 	return func(next shipping.RoutingService) shipping.RoutingService {
 		var e endpoint.Endpoint
-		e = makeFetchRoutesEndpoint(ctx, proxyURL)
-		e = circuitbreaker.Hystrix("fetch-routes")(e)
-		return proxyService{ctx, e, next}
+		e = makeFetchRoutesEndpoint(ctx, proxyURL, codec)
+		for _, m := range mw {
+			e = m(e)
+		}
+		return proxyService{e, next}
 	}
 }
 
-type fetchRoutesRequest struct {
-	From string
-	To   string
+// StatusError is returned when the routing service responds with a status
+// code other than 2xx.
+type StatusError struct {
+	Code int
 }
 
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("routing service responded %d %s", e.Code, http.StatusText(e.Code))
+}
+
+// RouteCodec translates route requests and responses to and from the wire
+// format of a routing service.
+type RouteCodec interface {
+	// EncodeRequest encodes the route specification into the request.
+	EncodeRequest(r *http.Request, rs shipping.RouteSpecification) error
+
+	// DecodeResponse decodes the itineraries of a successful response.
+	DecodeResponse(resp *http.Response) ([]shipping.Itinerary, error)
+}
+
+// DefaultCodec speaks the wire format of the pathfinder routing service.
+var DefaultCodec RouteCodec = pathfinderCodec{}
+
+type pathfinderCodec struct{}
+
 type fetchRoutesResponse struct {
 	Paths []struct {
 		Edges []struct {
@@ -80,7 +89,41 @@ type fetchRoutesResponse struct {
 	} `json:"paths"`
 }
 
-func makeFetchRoutesEndpoint(ctx context.Context, instance string) endpoint.Endpoint {
+func (pathfinderCodec) EncodeRequest(r *http.Request, rs shipping.RouteSpecification) error {
+	vals := r.URL.Query()
+	vals.Add("from", string(rs.Origin))
+	vals.Add("to", string(rs.Destination))
+	r.URL.RawQuery = vals.Encode()
+
+	return nil
+}
+
+func (pathfinderCodec) DecodeResponse(resp *http.Response) ([]shipping.Itinerary, error) {
+	var response fetchRoutesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	var itineraries []shipping.Itinerary
+	for _, r := range response.Paths {
+		var legs []shipping.Leg
+		for _, e := range r.Edges {
+			legs = append(legs, shipping.Leg{
+				VoyageNumber:   shipping.VoyageNumber(e.Voyage),
+				LoadLocation:   shipping.UNLocode(e.Origin),
+				UnloadLocation: shipping.UNLocode(e.Destination),
+				LoadTime:       e.Departure,
+				UnloadTime:     e.Arrival,
+			})
+		}
+
+		itineraries = append(itineraries, shipping.Itinerary{Legs: legs})
+	}
+
+	return itineraries, nil
+}
+
+func makeFetchRoutesEndpoint(ctx context.Context, instance string, codec RouteCodec) endpoint.Endpoint {
 	u, err := url.Parse(instance)
 	if err != nil {
 		panic(err)
@@ -90,26 +133,14 @@ func makeFetchRoutesEndpoint(ctx context.Context, instance string) endpoint.Endp
 	}
 	return kithttp.NewClient(
 		"GET", u,
-		encodeFetchRoutesRequest,
-		decodeFetchRoutesResponse,
+		func(_ context.Context, r *http.Request, request interface{}) error {
+			return codec.EncodeRequest(r, request.(shipping.RouteSpecification))
+		},
+		func(_ context.Context, resp *http.Response) (interface{}, error) {
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return nil, &StatusError{Code: resp.StatusCode}
+			}
+			return codec.DecodeResponse(resp)
+		},
 	).Endpoint()
 }
-
-func decodeFetchRoutesResponse(_ context.Context, resp *http.Response) (interface{}, error) {
-	var response fetchRoutesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, err
-	}
-	return response, nil
-}
-
-func encodeFetchRoutesRequest(_ context.Context, r *http.Request, request interface{}) error {
-	req := request.(fetchRoutesRequest)
-
-	vals := r.URL.Query()
-	vals.Add("from", req.From)
-	vals.Add("to", req.To)
-	r.URL.RawQuery = vals.Encode()
-
-	return nil
-}
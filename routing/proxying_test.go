@@ -0,0 +1,96 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// partnerCodec speaks a wire format with different field names than the
+// pathfinder.
+type partnerCodec struct{}
+
+func (partnerCodec) EncodeRequest(r *http.Request, rs shipping.RouteSpecification) error {
+	vals := r.URL.Query()
+	vals.Set("origin", string(rs.Origin))
+	vals.Set("destination", string(rs.Destination))
+	r.URL.RawQuery = vals.Encode()
+	return nil
+}
+
+func (partnerCodec) DecodeResponse(resp *http.Response) ([]shipping.Itinerary, error) {
+	var response struct {
+		Routes [][]struct {
+			From   string    `json:"pol"`
+			To     string    `json:"pod"`
+			Vessel string    `json:"vessel"`
+			ETD    time.Time `json:"etd"`
+			ETA    time.Time `json:"eta"`
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	var itineraries []shipping.Itinerary
+	for _, route := range response.Routes {
+		var legs []shipping.Leg
+		for _, l := range route {
+			legs = append(legs, shipping.NewLeg(shipping.VoyageNumber(l.Vessel), shipping.UNLocode(l.From), shipping.UNLocode(l.To), l.ETD, l.ETA))
+		}
+		itineraries = append(itineraries, shipping.Itinerary{Legs: legs})
+	}
+	return itineraries, nil
+}
+
+func TestProxyingMiddlewareWithCodec(t *testing.T) {
+	ctx := context.Background()
+
+	etd := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+	eta := etd.Add(72 * time.Hour)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("origin") != "SESTO" || q.Get("destination") != "CNHKG" {
+			http.Error(w, "unknown route", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"routes": [][]map[string]interface{}{
+				{{"pol": "SESTO", "pod": "CNHKG", "vessel": "V100", "etd": etd, "eta": eta}},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	s := NewProxyingMiddlewareWithCodec(ctx, srv.URL, partnerCodec{})(nil)
+
+	got := s.FetchRoutesForSpecification(ctx, shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.CNHKG,
+	})
+
+	if len(got) != 1 || len(got[0].Legs) != 1 {
+		t.Fatalf("FetchRoutesForSpecification() = %v; want a single leg", got)
+	}
+
+	want := shipping.NewLeg("V100", shipping.SESTO, shipping.CNHKG, etd, eta)
+	if leg := got[0].Legs[0]; leg.VoyageNumber != want.VoyageNumber ||
+		leg.LoadLocation != want.LoadLocation || leg.UnloadLocation != want.UnloadLocation ||
+		!leg.LoadTime.Equal(want.LoadTime) || !leg.UnloadTime.Equal(want.UnloadTime) {
+		t.Errorf("leg = %v; want = %v", leg, want)
+	}
+
+	got = s.FetchRoutesForSpecification(ctx, shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.AUMEL,
+	})
+	if len(got) != 0 {
+		t.Errorf("len(FetchRoutesForSpecification()) = %d; want = %d", len(got), 0)
+	}
+}
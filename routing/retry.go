@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Retry returns an endpoint middleware that makes up to attempts requests
+// while they fail with a transient error, that is a network failure or a 5xx
+// or 429 response. The wait before each retry doubles from backoff, with random
+// jitter. Retrying stops early once the context is done.
+func Retry(attempts int, backoff time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var (
+				response interface{}
+				err      error
+			)
+			for i := 0; i < attempts; i++ {
+				if i > 0 {
+					select {
+					case <-time.After(jitter(backoff << uint(i-1))):
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+				}
+
+				response, err = next(ctx, request)
+				if err == nil || !isTransient(err) {
+					break
+				}
+			}
+			return response, err
+		}
+	}
+}
+
+// jitter returns a random duration between d/2 and d.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+func isTransient(err error) bool {
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		return serr.Code >= 500 || serr.Code == 429
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyEndpoint fails with err until it has been called succeedOn times.
+type flakyEndpoint struct {
+	calls     int
+	succeedOn int
+	err       error
+}
+
+func (e *flakyEndpoint) endpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	e.calls++
+	if e.calls < e.succeedOn {
+		return nil, e.err
+	}
+	return "ok", nil
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+
+	f := &flakyEndpoint{succeedOn: 3, err: &StatusError{Code: 503}}
+
+	resp, err := Retry(5, time.Millisecond)(f.endpoint)(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "ok" {
+		t.Errorf("resp = %v; want = %v", resp, "ok")
+	}
+	if f.calls != 3 {
+		t.Errorf("calls = %d; want = %d", f.calls, 3)
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	ctx := context.Background()
+
+	f := &flakyEndpoint{succeedOn: 10, err: &StatusError{Code: 500}}
+
+	if _, err := Retry(3, time.Millisecond)(f.endpoint)(ctx, nil); err != f.err {
+		t.Errorf("err = %v; want = %v", err, f.err)
+	}
+	if f.calls != 3 {
+		t.Errorf("calls = %d; want = %d", f.calls, 3)
+	}
+}
+
+func TestRetry_NotTransient(t *testing.T) {
+	ctx := context.Background()
+
+	for _, err := range []error{&StatusError{Code: 404}, errors.New("invalid response")} {
+		f := &flakyEndpoint{succeedOn: 3, err: err}
+
+		if _, got := Retry(3, time.Millisecond)(f.endpoint)(ctx, nil); got != err {
+			t.Errorf("err = %v; want = %v", got, err)
+		}
+		if f.calls != 1 {
+			t.Errorf("calls = %d; want = %d", f.calls, 1)
+		}
+	}
+}
+
+func TestRetry_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	f := &flakyEndpoint{succeedOn: 3, err: &StatusError{Code: 503}}
+
+	e := Retry(3, time.Hour)(func(ctx context.Context, request interface{}) (interface{}, error) {
+		defer cancel()
+		return f.endpoint(ctx, request)
+	})
+
+	if _, err := e(ctx, nil); err != context.Canceled {
+		t.Errorf("err = %v; want = %v", err, context.Canceled)
+	}
+	if f.calls != 1 {
+		t.Errorf("calls = %d; want = %d", f.calls, 1)
+	}
+}
@@ -0,0 +1,115 @@
+package shipping
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// sampleCargoLocations are the locations that sample cargos are booked
+// between.
+var sampleCargoLocations = []UNLocode{SESTO, AUMEL, CNHKG, USNYC, USCHI, JNTKO, DEHAM, NLRTM, FIHEL}
+
+// sampleCargoVoyages are the voyages that sample cargos are carried on.
+var sampleCargoVoyages = []VoyageNumber{"V100", "V300", "V400"}
+
+const sampleTrackingIDChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// SampleCargos generates n sample cargos, routed and handled as of now,
+// together with their handling events. Everything random about them, such
+// as their tracking IDs, locations and times, is drawn from src, so that a
+// source seeded alike, given the same time, generates the same cargos.
+func SampleCargos(src rand.Source, now time.Time, n int) ([]*Cargo, []HandlingEvent) {
+	var (
+		r      = rand.New(src)
+		cargos = make([]*Cargo, 0, n)
+		events []HandlingEvent
+		ids    = make(map[TrackingID]bool)
+	)
+
+	for len(cargos) < n {
+		id := sampleTrackingID(r)
+		if ids[id] {
+			continue
+		}
+		ids[id] = true
+
+		var (
+			origin      = sampleCargoLocations[r.Intn(len(sampleCargoLocations))]
+			destination = sampleCargoLocations[r.Intn(len(sampleCargoLocations))]
+		)
+		for destination == origin {
+			destination = sampleCargoLocations[r.Intn(len(sampleCargoLocations))]
+		}
+
+		var (
+			booked  = now.Add(-time.Duration(r.Intn(10*24)) * time.Hour)
+			load    = booked.Add(time.Duration(1+r.Intn(3*24)) * time.Hour)
+			unload  = load.Add(time.Duration(2*24+r.Intn(8*24)) * time.Hour)
+			claim   = unload.Add(time.Duration(1+r.Intn(2*24)) * time.Hour)
+			voyage  = sampleCargoVoyages[r.Intn(len(sampleCargoVoyages))]
+			receive = booked.Add(time.Hour)
+		)
+
+		c := NewCargo(id, RouteSpecification{
+			Origin:          origin,
+			Destination:     destination,
+			ArrivalDeadline: unload.Add(time.Duration(r.Intn(5*24)-24) * time.Hour),
+		})
+		c.AssignToRoute(Itinerary{Legs: []Leg{
+			NewLeg(voyage, origin, destination, load, unload),
+		}})
+
+		var history HandlingHistory
+		for _, a := range []struct {
+			at       time.Time
+			activity HandlingActivity
+		}{
+			{receive, HandlingActivity{Type: Receive, Location: origin}},
+			{load, HandlingActivity{Type: Load, Location: origin, VoyageNumber: voyage}},
+			{unload, HandlingActivity{Type: Unload, Location: destination, VoyageNumber: voyage}},
+			{claim, HandlingActivity{Type: Claim, Location: destination}},
+		} {
+			if a.at.After(now) {
+				break
+			}
+			history.HandlingEvents = append(history.HandlingEvents, HandlingEvent{
+				TrackingID:       id,
+				Activity:         a.activity,
+				RegistrationTime: a.at.UTC(),
+				CompletionTime:   a.at.UTC(),
+			})
+		}
+		c.DeriveDeliveryProgress(history)
+
+		cargos = append(cargos, c)
+		events = append(events, history.HandlingEvents...)
+	}
+
+	return cargos, events
+}
+
+// SeedSampleCargos stores n sample cargos generated by SampleCargos, and
+// their handling events, in the repositories.
+func SeedSampleCargos(ctx context.Context, cargos CargoRepository, events HandlingEventRepository, src rand.Source, now time.Time, n int) error {
+	cs, es := SampleCargos(src, now, n)
+	for _, c := range cs {
+		if err := cargos.Store(ctx, c); err != nil {
+			return err
+		}
+	}
+	for _, e := range es {
+		if err := events.Store(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sampleTrackingID(r *rand.Rand) TrackingID {
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = sampleTrackingIDChars[r.Intn(len(sampleTrackingIDChars))]
+	}
+	return TrackingID(b)
+}
@@ -0,0 +1,36 @@
+package shipping
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSampleCargos(t *testing.T) {
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	cargos1, events1 := SampleCargos(rand.NewSource(42), now, 10)
+	cargos2, events2 := SampleCargos(rand.NewSource(42), now, 10)
+
+	if len(cargos1) != 10 {
+		t.Fatalf("len(cargos) = %d; want = %d", len(cargos1), 10)
+	}
+	if !reflect.DeepEqual(cargos1, cargos2) {
+		t.Errorf("cargos = %v; want = %v", cargos2, cargos1)
+	}
+	if !reflect.DeepEqual(events1, events2) {
+		t.Errorf("events = %v; want = %v", events2, events1)
+	}
+
+	for _, e := range events1 {
+		if e.CompletionTime.After(now) {
+			t.Errorf("%s: CompletionTime = %v; want before %v", e.TrackingID, e.CompletionTime, now)
+		}
+	}
+
+	other, _ := SampleCargos(rand.NewSource(43), now, 10)
+	if reflect.DeepEqual(other, cargos1) {
+		t.Errorf("a different seed should generate different cargos")
+	}
+}
@@ -15,13 +15,13 @@ var (
 
 // Sample locations.
 var (
-	Stockholm = &Location{SESTO, "Stockholm"}
-	Melbourne = &Location{AUMEL, "Melbourne"}
-	Hongkong  = &Location{CNHKG, "Hongkong"}
-	NewYork   = &Location{USNYC, "New York"}
-	Chicago   = &Location{USCHI, "Chicago"}
-	Tokyo     = &Location{JNTKO, "Tokyo"}
-	Hamburg   = &Location{DEHAM, "Hamburg"}
-	Rotterdam = &Location{NLRTM, "Rotterdam"}
-	Helsinki  = &Location{FIHEL, "Helsinki"}
+	Stockholm = &Location{SESTO, "Stockholm", 59.3293, 18.0686}
+	Melbourne = &Location{AUMEL, "Melbourne", -37.8136, 144.9631}
+	Hongkong  = &Location{CNHKG, "Hongkong", 22.3193, 114.1694}
+	NewYork   = &Location{USNYC, "New York", 40.7128, -74.0060}
+	Chicago   = &Location{USCHI, "Chicago", 41.8781, -87.6298}
+	Tokyo     = &Location{JNTKO, "Tokyo", 35.6762, 139.6503}
+	Hamburg   = &Location{DEHAM, "Hamburg", 53.5511, 9.9937}
+	Rotterdam = &Location{NLRTM, "Rotterdam", 51.9244, 4.4777}
+	Helsinki  = &Location{FIHEL, "Helsinki", 60.1699, 24.9384}
 )
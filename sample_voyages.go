@@ -2,14 +2,14 @@ package shipping
 
 // A set of sample voyages.
 var (
-	V100 = NewVoyage("V100", Schedule{
+	V100 = mustNewVoyage("V100", Schedule{
 		[]CarrierMovement{
 			{DepartureLocation: CNHKG, ArrivalLocation: JNTKO},
 			{DepartureLocation: JNTKO, ArrivalLocation: USNYC},
 		},
 	})
 
-	V300 = NewVoyage("V300", Schedule{
+	V300 = mustNewVoyage("V300", Schedule{
 		[]CarrierMovement{
 			{DepartureLocation: JNTKO, ArrivalLocation: NLRTM},
 			{DepartureLocation: NLRTM, ArrivalLocation: DEHAM},
@@ -18,7 +18,7 @@ var (
 		},
 	})
 
-	V400 = NewVoyage("V400", Schedule{
+	V400 = mustNewVoyage("V400", Schedule{
 		[]CarrierMovement{
 			{DepartureLocation: DEHAM, ArrivalLocation: SESTO},
 			{DepartureLocation: SESTO, ArrivalLocation: FIHEL},
@@ -30,9 +30,9 @@ var (
 // These voyages are hard-coded into the current pathfinder. Make sure
 // they exist.
 var (
-	V0100S = NewVoyage("0100S", Schedule{[]CarrierMovement{}})
-	V0200T = NewVoyage("0200T", Schedule{[]CarrierMovement{}})
-	V0300A = NewVoyage("0300A", Schedule{[]CarrierMovement{}})
-	V0301S = NewVoyage("0301S", Schedule{[]CarrierMovement{}})
-	V0400S = NewVoyage("0400S", Schedule{[]CarrierMovement{}})
+	V0100S = mustNewVoyage("0100S", Schedule{[]CarrierMovement{}})
+	V0200T = mustNewVoyage("0200T", Schedule{[]CarrierMovement{}})
+	V0300A = mustNewVoyage("0300A", Schedule{[]CarrierMovement{}})
+	V0301S = mustNewVoyage("0301S", Schedule{[]CarrierMovement{}})
+	V0400S = mustNewVoyage("0400S", Schedule{[]CarrierMovement{}})
 )
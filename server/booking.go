@@ -1,7 +1,6 @@
 package server
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -41,13 +40,9 @@ func (h *bookingHandler) router() chi.Router {
 }
 
 func (h *bookingHandler) bookCargo(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
-	var request struct {
-		Origin          shipping.UNLocode
-		Destination     shipping.UNLocode
-		ArrivalDeadline time.Time
-	}
+	var request bookCargoRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		h.logger.Log("error", err)
@@ -55,15 +50,16 @@ func (h *bookingHandler) bookCargo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.s.BookNewCargo(request.Origin, request.Destination, request.ArrivalDeadline)
+	id, err := h.s.BookNewCargo(ctx, request.Origin, request.Destination, request.ArrivalDeadline,
+		booking.WithWeight(request.Weight),
+		booking.WithVolume(request.Volume),
+	)
 	if err != nil {
 		encodeError(ctx, err, w)
 		return
 	}
 
-	var response = struct {
-		ID shipping.TrackingID `json:"tracking_id"`
-	}{
+	var response = bookCargoResponse{
 		ID: id,
 	}
 
@@ -76,19 +72,17 @@ func (h *bookingHandler) bookCargo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *bookingHandler) loadCargo(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	trackingID := shipping.TrackingID(chi.URLParam(r, "trackingID"))
 
-	c, err := h.s.LoadCargo(trackingID)
+	c, err := h.s.LoadCargo(ctx, trackingID)
 	if err != nil {
 		encodeError(ctx, err, w)
 		return
 	}
 
-	var response = struct {
-		Cargo booking.Cargo `json:"cargo"`
-	}{
+	var response = loadCargoResponse{
 		Cargo: c,
 	}
 
@@ -101,15 +95,13 @@ func (h *bookingHandler) loadCargo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *bookingHandler) requestRoutes(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	trackingID := shipping.TrackingID(chi.URLParam(r, "trackingID"))
 
-	itin := h.s.RequestPossibleRoutesForCargo(trackingID)
+	itin := h.s.RequestPossibleRoutesForCargo(ctx, trackingID)
 
-	var response = struct {
-		Routes []shipping.Itinerary `json:"routes"`
-	}{
+	var response = requestRoutesResponse{
 		Routes: itin,
 	}
 
@@ -122,13 +114,11 @@ func (h *bookingHandler) requestRoutes(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *bookingHandler) assignToRoute(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	trackingID := shipping.TrackingID(chi.URLParam(r, "trackingID"))
 
-	var request struct {
-		Itinerary shipping.Itinerary `json:"route"`
-	}
+	var request assignToRouteRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		h.logger.Log("error", err)
@@ -136,7 +126,7 @@ func (h *bookingHandler) assignToRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.s.AssignCargoToRoute(trackingID, request.Itinerary)
+	err := h.s.AssignCargoToRoute(ctx, trackingID, request.Itinerary)
 	if err != nil {
 		encodeError(ctx, err, w)
 		return
@@ -144,13 +134,11 @@ func (h *bookingHandler) assignToRoute(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *bookingHandler) changeDestination(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	trackingID := shipping.TrackingID(chi.URLParam(r, "trackingID"))
 
-	var request struct {
-		Destination shipping.UNLocode `json:"destination"`
-	}
+	var request changeDestinationRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		h.logger.Log("error", err)
@@ -158,7 +146,7 @@ func (h *bookingHandler) changeDestination(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err := h.s.ChangeDestination(trackingID, request.Destination)
+	err := h.s.ChangeDestination(ctx, trackingID, request.Destination)
 	if err != nil {
 		encodeError(ctx, err, w)
 		return
@@ -166,13 +154,11 @@ func (h *bookingHandler) changeDestination(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *bookingHandler) listCargos(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
-	cs := h.s.Cargos()
+	cs := h.s.Cargos(ctx)
 
-	var response = struct {
-		Cargos []booking.Cargo `json:"cargos"`
-	}{
+	var response = listCargosResponse{
 		Cargos: cs,
 	}
 
@@ -185,13 +171,11 @@ func (h *bookingHandler) listCargos(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *bookingHandler) listLocations(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
-	ls := h.s.Locations()
+	ls := h.s.Locations(ctx)
 
-	var response = struct {
-		Locations []booking.Location `json:"cargos"`
-	}{
+	var response = listLocationsResponse{
 		Locations: ls,
 	}
 
@@ -202,3 +186,39 @@ func (h *bookingHandler) listLocations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type bookCargoRequest struct {
+	Origin          shipping.UNLocode
+	Destination     shipping.UNLocode
+	ArrivalDeadline time.Time
+	Weight          float64
+	Volume          float64
+}
+
+type bookCargoResponse struct {
+	ID shipping.TrackingID `json:"tracking_id"`
+}
+
+type loadCargoResponse struct {
+	Cargo booking.Cargo `json:"cargo"`
+}
+
+type requestRoutesResponse struct {
+	Routes []shipping.Itinerary `json:"routes"`
+}
+
+type assignToRouteRequest struct {
+	Itinerary shipping.Itinerary `json:"route"`
+}
+
+type changeDestinationRequest struct {
+	Destination shipping.UNLocode `json:"destination"`
+}
+
+type listCargosResponse struct {
+	Cargos []booking.Cargo `json:"cargos"`
+}
+
+type listLocationsResponse struct {
+	Locations []booking.Location `json:"cargos"`
+}
@@ -1,9 +1,12 @@
 package server
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -14,7 +17,8 @@ import (
 )
 
 type handlingHandler struct {
-	s handling.Service
+	s      handling.Service
+	events handling.EventStream
 
 	logger kitlog.Logger
 }
@@ -22,33 +26,44 @@ type handlingHandler struct {
 func (h *handlingHandler) router() chi.Router {
 	r := chi.NewRouter()
 	r.Post("/incidents", h.registerIncident)
+	r.Get("/events", h.searchEvents)
+	if h.events != nil {
+		r.Get("/events/stream", h.streamEvents)
+	}
 	r.Method("GET", "/docs", http.StripPrefix("/handling/v1/docs", http.FileServer(http.Dir("handling/docs"))))
 	return r
 }
 
 func (h *handlingHandler) registerIncident(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
-
-	var request struct {
-		CompletionTime time.Time `json:"completion_time"`
-		TrackingID     string    `json:"tracking_id"`
-		VoyageNumber   string    `json:"voyage"`
-		Location       string    `json:"location"`
-		EventType      string    `json:"event_type"`
+	ctx := r.Context()
+	if id := r.Header.Get("X-Correlation-ID"); id != "" {
+		ctx = shipping.NewCorrelationContext(ctx, shipping.CorrelationID(id))
 	}
 
+	var request registerIncidentRequest
+
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		h.logger.Log("error", err)
 		encodeError(ctx, err, w)
 		return
 	}
 
-	err := h.s.RegisterHandlingEvent(
+	eventType, err := shipping.ParseHandlingEventType(request.EventType)
+	if err != nil {
+		encodeError(ctx, err, w)
+		return
+	}
+
+	_, err = h.s.RegisterHandlingEvent(ctx,
 		request.CompletionTime,
 		shipping.TrackingID(request.TrackingID),
 		shipping.VoyageNumber(request.VoyageNumber),
 		shipping.UNLocode(request.Location),
-		stringToEventType(request.EventType),
+		eventType,
+		handling.WithIdempotencyKey(r.Header.Get("Idempotency-Key")),
+		handling.WithEquipmentID(shipping.EquipmentID(request.EquipmentID)),
+		handling.WithRecipient(request.RecipientName, request.RecipientSignature),
+		handling.WithCustomsCleared(request.CustomsCleared),
 	)
 	if err != nil {
 		encodeError(ctx, err, w)
@@ -56,13 +71,146 @@ func (h *handlingHandler) registerIncident(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-func stringToEventType(s string) shipping.HandlingEventType {
-	types := map[string]shipping.HandlingEventType{
-		shipping.Receive.String(): shipping.Receive,
-		shipping.Load.String():    shipping.Load,
-		shipping.Unload.String():  shipping.Unload,
-		shipping.Customs.String(): shipping.Customs,
-		shipping.Claim.String():   shipping.Claim,
+func (h *handlingHandler) searchEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	criteria, err := decodeSearchCriteria(r.URL.Query())
+	if err != nil {
+		encodeError(ctx, err, w)
+		return
 	}
-	return types[s]
+
+	events, total, err := h.s.SearchHandlingEvents(ctx, criteria)
+	if err != nil {
+		encodeError(ctx, err, w)
+		return
+	}
+
+	var response = searchEventsResponse{
+		Events: append([]shipping.HandlingEvent{}, events...),
+		Total:  total,
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Log("error", err)
+		encodeError(ctx, err, w)
+		return
+	}
+}
+
+// decodeSearchCriteria reads the search criteria from the query parameters
+// type, location, voyage, from, to, offset and limit, all of which are
+// optional. Times are in RFC 3339 format.
+func decodeSearchCriteria(q url.Values) (shipping.SearchCriteria, error) {
+	criteria := shipping.SearchCriteria{
+		Location:     shipping.UNLocode(q.Get("location")),
+		VoyageNumber: shipping.VoyageNumber(q.Get("voyage")),
+	}
+
+	if t := q.Get("type"); t != "" {
+		typ, err := shipping.ParseHandlingEventType(t)
+		if err != nil {
+			return shipping.SearchCriteria{}, err
+		}
+		criteria.Type = typ
+	}
+
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"from", &criteria.From},
+		{"to", &criteria.To},
+	} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return shipping.SearchCriteria{}, handling.ErrInvalidArgument
+			}
+			*p.t = t
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		n    *int
+	}{
+		{"offset", &criteria.Offset},
+		{"limit", &criteria.Limit},
+	} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return shipping.SearchCriteria{}, handling.ErrInvalidArgument
+			}
+			*p.n = n
+		}
+	}
+
+	return criteria, nil
+}
+
+type searchEventsResponse struct {
+	Events []shipping.HandlingEvent `json:"events"`
+	Total  int                      `json:"total"`
+}
+
+// errStreamingUnsupported is returned when the response can not be flushed
+// while streaming.
+var errStreamingUnsupported = errors.New("streaming unsupported")
+
+// streamEvents streams handled events as Server-Sent Events, each holding an
+// event encoded as by shipping.HandlingEvent.MarshalJSON, until the client
+// goes away or is dropped for not keeping up.
+func (h *handlingHandler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		encodeError(ctx, errStreamingUnsupported, w)
+		return
+	}
+
+	events, cancel := h.events.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			b, err := json.Marshal(e)
+			if err != nil {
+				h.logger.Log("error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+type registerIncidentRequest struct {
+	CompletionTime time.Time `json:"completion_time"`
+	TrackingID     string    `json:"tracking_id"`
+	VoyageNumber   string    `json:"voyage"`
+	Location       string    `json:"location"`
+	EventType      string    `json:"event_type"`
+	EquipmentID    string    `json:"equipment_id"`
+
+	RecipientName      string `json:"recipient_name,omitempty"`
+	RecipientSignature string `json:"recipient_signature,omitempty"`
+
+	CustomsCleared bool `json:"customs_cleared,omitempty"`
 }
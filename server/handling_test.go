@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/handling"
+	"github.com/marcusolsson/goddd/inmem"
+)
+
+func TestStreamEvents(t *testing.T) {
+	events := handling.NewEventStream(1)
+
+	srv := httptest.NewServer(New(nil, nil, nil, log.NewLogfmtLogger(ioutil.Discard), WithEventStream(events)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/handling/v1/events/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q; want = %q", ct, "text/event-stream")
+	}
+
+	want := shipping.HandlingEvent{
+		TrackingID:       "ABC123",
+		Activity:         shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO},
+		RegistrationTime: time.Date(2009, time.March, 1, 12, 30, 0, 0, time.UTC),
+		CompletionTime:   time.Date(2009, time.March, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	// The client subscribes before the response headers are sent.
+	events.CargoWasHandled(context.Background(), want)
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("line = %q; want data", line)
+	}
+
+	var got shipping.HandlingEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("event = %v; want = %v", got, want)
+	}
+}
+
+func TestSearchEvents(t *testing.T) {
+	ctx := context.Background()
+
+	events := inmem.NewHandlingEventRepository()
+
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for i, loc := range []shipping.UNLocode{shipping.SESTO, shipping.DEHAM, shipping.SESTO, shipping.SESTO} {
+		if err := events.Store(ctx, shipping.HandlingEvent{
+			TrackingID:     "ABC123",
+			Activity:       shipping.HandlingActivity{Type: shipping.Receive, Location: loc},
+			CompletionTime: t0.Add(time.Duration(i) * time.Hour),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hs := handling.NewService(events, shipping.HandlingEventFactory{}, nil)
+
+	srv := httptest.NewServer(New(nil, nil, hs, log.NewLogfmtLogger(ioutil.Discard)))
+	defer srv.Close()
+
+	// The type is matched regardless of case.
+	for _, typ := range []string{"Receive", "receive"} {
+		resp, err := http.Get(srv.URL + "/handling/v1/events?type=" + typ + "&location=SESTO&to=2009-03-01T02:00:00Z&limit=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got searchEventsResponse
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: StatusCode = %d; want = %d", typ, resp.StatusCode, http.StatusOK)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Total != 2 {
+			t.Errorf("%s: Total = %d; want = %d", typ, got.Total, 2)
+		}
+		if len(got.Events) != 1 {
+			t.Fatalf("%s: len(Events) = %d; want = %d", typ, len(got.Events), 1)
+		}
+		if want := t0.Add(2 * time.Hour); !got.Events[0].CompletionTime.Equal(want) {
+			t.Errorf("%s: CompletionTime = %v; want = %v", typ, got.Events[0].CompletionTime, want)
+		}
+	}
+
+	for _, query := range []string{"type=Sail", "type=Not+Handled", "from=yesterday", "limit=-1", "offset=first"} {
+		resp, err := http.Get(srv.URL + "/handling/v1/events?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: StatusCode = %d; want = %d", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
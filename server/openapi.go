@@ -0,0 +1,183 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/server/openapi"
+)
+
+// OpenAPI returns an OpenAPI 3 document describing the HTTP API. The schemas
+// are generated from the request and response types used by the handlers.
+func OpenAPI() *openapi.Document {
+	d := openapi.NewDocument("goddd", "1.0.0")
+
+	// Handling events are encoded by shipping.HandlingEvent.MarshalJSON.
+	d.Define(shipping.HandlingEvent{}, &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"trackingId":       {Type: "string"},
+			"type":             {Type: "string"},
+			"location":         {Type: "string"},
+			"voyage":           {Type: "string"},
+			"registrationTime": {Type: "string", Format: "date-time"},
+			"completionTime":   {Type: "string", Format: "date-time"},
+			"idempotencyKey":   {Type: "string"},
+			"equipmentId":      {Type: "string"},
+
+			"recipientName":      {Type: "string"},
+			"recipientSignature": {Type: "string"},
+
+			"superseded": {Type: "boolean"},
+			"planned":    {Type: "boolean"},
+
+			"instructions": {Type: "string"},
+			"amends": {
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"type":           {Type: "string"},
+					"location":       {Type: "string"},
+					"voyage":         {Type: "string"},
+					"completionTime": {Type: "string", Format: "date-time"},
+				},
+				Required: []string{"completionTime", "location", "type"},
+			},
+		},
+		Required: []string{"completionTime", "location", "registrationTime", "trackingId", "type"},
+	})
+
+	trackingID := openapi.Parameter{
+		Name:     "trackingID",
+		In:       "path",
+		Required: true,
+		Schema:   &openapi.Schema{Type: "string"},
+	}
+
+	responses := func(v interface{}) map[string]*openapi.Response {
+		ok := &openapi.Response{Description: "OK"}
+		if v != nil {
+			ok.Content = d.JSON(v)
+		}
+		return map[string]*openapi.Response{
+			"200":     ok,
+			"default": {Description: "Error", Content: d.JSON(errorResponse{})},
+		}
+	}
+
+	body := func(v interface{}) *openapi.RequestBody {
+		return &openapi.RequestBody{Required: true, Content: d.JSON(v)}
+	}
+
+	d.Handle("POST", "/booking/v1/cargos", &openapi.Operation{
+		OperationID: "bookCargo",
+		Summary:     "Book a new cargo",
+		RequestBody: body(bookCargoRequest{}),
+		Responses:   responses(bookCargoResponse{}),
+	})
+	d.Handle("GET", "/booking/v1/cargos", &openapi.Operation{
+		OperationID: "listCargos",
+		Summary:     "List all cargos",
+		Responses:   responses(listCargosResponse{}),
+	})
+	d.Handle("GET", "/booking/v1/cargos/{trackingID}", &openapi.Operation{
+		OperationID: "loadCargo",
+		Summary:     "Load a cargo",
+		Parameters:  []openapi.Parameter{trackingID},
+		Responses:   responses(loadCargoResponse{}),
+	})
+	d.Handle("GET", "/booking/v1/cargos/{trackingID}/request_routes", &openapi.Operation{
+		OperationID: "requestRoutes",
+		Summary:     "Request possible routes for a cargo",
+		Parameters:  []openapi.Parameter{trackingID},
+		Responses:   responses(requestRoutesResponse{}),
+	})
+	d.Handle("POST", "/booking/v1/cargos/{trackingID}/assign_to_route", &openapi.Operation{
+		OperationID: "assignToRoute",
+		Summary:     "Assign a cargo to a route",
+		Parameters:  []openapi.Parameter{trackingID},
+		RequestBody: body(assignToRouteRequest{}),
+		Responses:   responses(nil),
+	})
+	d.Handle("POST", "/booking/v1/cargos/{trackingID}/change_destination", &openapi.Operation{
+		OperationID: "changeDestination",
+		Summary:     "Change the destination of a cargo",
+		Parameters:  []openapi.Parameter{trackingID},
+		RequestBody: body(changeDestinationRequest{}),
+		Responses:   responses(nil),
+	})
+	d.Handle("GET", "/booking/v1/locations", &openapi.Operation{
+		OperationID: "listLocations",
+		Summary:     "List all locations",
+		Responses:   responses(listLocationsResponse{}),
+	})
+
+	track := &openapi.Operation{
+		OperationID: "trackCargo",
+		Summary:     "Track a cargo",
+		Parameters: []openapi.Parameter{
+			trackingID,
+			{Name: "tz", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "If-None-Match", In: "header", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Responses: responses(trackCargoResponse{}),
+	}
+	track.Responses["304"] = &openapi.Response{Description: "Not Modified"}
+	d.Handle("GET", "/tracking/v1/cargos/{trackingID}", track)
+	d.Handle("GET", "/tracking/v1/cargos/{trackingID}/events", &openapi.Operation{
+		OperationID: "handlingEvents",
+		Summary:     "List the handling history of a cargo",
+		Parameters:  []openapi.Parameter{trackingID},
+		Responses:   responses([]shipping.HandlingEvent{}),
+	})
+	d.Handle("POST", "/tracking/v1/cargos/status", &openapi.Operation{
+		OperationID: "batchStatus",
+		Summary:     "Get the status of several cargos",
+		RequestBody: body([]string{}),
+		Responses:   responses(batchStatusResponse{}),
+	})
+
+	d.Handle("POST", "/handling/v1/incidents", &openapi.Operation{
+		OperationID: "registerIncident",
+		Summary:     "Register a handling event",
+		Parameters: []openapi.Parameter{
+			{Name: "Idempotency-Key", In: "header", Schema: &openapi.Schema{Type: "string"}},
+		},
+		RequestBody: body(registerIncidentRequest{}),
+		Responses:   responses(nil),
+	})
+	d.Handle("GET", "/handling/v1/events", &openapi.Operation{
+		OperationID: "searchEvents",
+		Summary:     "Search the handling events of all cargos, latest completed first",
+		Parameters: []openapi.Parameter{
+			{Name: "type", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "location", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "voyage", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "from", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "to", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "offset", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Responses: responses(searchEventsResponse{}),
+	})
+	d.Handle("GET", "/handling/v1/events/stream", &openapi.Operation{
+		OperationID: "streamEvents",
+		Summary:     "Stream handling events as they are registered, as Server-Sent Events",
+		Responses: map[string]*openapi.Response{
+			"200": {
+				Description: "A stream of events, each with a handling event as data",
+				Content: map[string]openapi.MediaType{
+					"text/event-stream": {Schema: d.SchemaOf(shipping.HandlingEvent{})},
+				},
+			},
+			"default": {Description: "Error", Content: d.JSON(errorResponse{})},
+		},
+	})
+
+	return d
+}
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(OpenAPI())
+}
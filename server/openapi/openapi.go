@@ -0,0 +1,434 @@
+// Package openapi provides a model of an OpenAPI 3 document, with schemas
+// generated from the Go types that are encoded by encoding/json.
+//
+// https://spec.openapis.org/oas/v3.0.3
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the version of the OpenAPI specification that documents
+// conform to.
+const Version = "3.0.3"
+
+// Document is the root object of an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	overrides map[reflect.Type]*Schema
+	errs      []error
+}
+
+// Info describes the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem describes the operations available on a single path.
+type PathItem struct {
+	Get  *Operation `json:"get,omitempty"`
+	Post *Operation `json:"post,omitempty"`
+}
+
+// Operation describes a single operation on a path.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a path, query or header parameter of an operation.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a single response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes the content of a request or response body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referred to from elsewhere in the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema describes a JSON value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// NewDocument returns an empty document describing the given API.
+func NewDocument(title, version string) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Version: version},
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+		overrides:  make(map[reflect.Type]*Schema),
+	}
+}
+
+// Define sets the schema of values of the given type, for types whose
+// encoding can not be derived from their fields, such as those implementing
+// json.Marshaler.
+func (d *Document) Define(v interface{}, s *Schema) {
+	d.overrides[reflect.TypeOf(v)] = s
+}
+
+// Handle adds an operation on the given method and path.
+func (d *Document) Handle(method, path string, op *Operation) {
+	p, ok := d.Paths[path]
+	if !ok {
+		p = &PathItem{}
+		d.Paths[path] = p
+	}
+
+	switch method {
+	case "GET":
+		p.Get = op
+	case "POST":
+		p.Post = op
+	default:
+		d.errs = append(d.errs, fmt.Errorf("%s %s: unsupported method", method, path))
+	}
+}
+
+// JSON returns a media type holding the schema of v encoded as JSON.
+func (d *Document) JSON(v interface{}) map[string]MediaType {
+	return map[string]MediaType{
+		"application/json": {Schema: d.SchemaOf(v)},
+	}
+}
+
+// SchemaOf returns the schema of v as encoded by encoding/json. Named struct
+// types are added to the components of the document and referred to.
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	return d.schema(reflect.TypeOf(v))
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+func (d *Document) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Name() != "" && (d.overrides[t] != nil || t.Kind() == reflect.Struct && t != timeType) {
+		return d.component(t)
+	}
+
+	return d.inline(t)
+}
+
+func (d *Document) component(t reflect.Type) *Schema {
+	name := path.Base(t.PkgPath()) + "." + t.Name()
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+
+	if _, ok := d.Components.Schemas[name]; ok {
+		return ref
+	}
+
+	// Register the component before generating its schema, so that
+	// recursive types refer to themselves rather than recurse forever.
+	d.Components.Schemas[name] = &Schema{}
+	*d.Components.Schemas[name] = *d.inline(t)
+
+	return ref
+}
+
+func (d *Document) inline(t reflect.Type) *Schema {
+	if s, ok := d.overrides[t]; ok {
+		return s
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		d.errs = append(d.errs, fmt.Errorf("%s: implements json.Marshaler and has no defined schema", t))
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		d.fields(s, t)
+		sort.Strings(s.Required)
+		return s
+	}
+
+	d.errs = append(d.errs, fmt.Errorf("%s: unsupported kind %s", t, t.Kind()))
+	return &Schema{}
+}
+
+// fields adds the fields of the struct type t to s, following the rules of
+// encoding/json for field names, omitted fields and embedded structs.
+func (d *Document) fields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				d.fields(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = d.schema(f.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+var (
+	pathParamRegexp     = regexp.MustCompile(`\{([^{}/]+)\}`)
+	responseCodeRegexp  = regexp.MustCompile(`^(default|[1-5][0-9][0-9])$`)
+	componentNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+)
+
+// Validate checks that the document is well-formed: that paths and
+// operations are complete, that path parameters match the path templates
+// and that every reference resolves to a component.
+func (d *Document) Validate() error {
+	if len(d.errs) > 0 {
+		return d.errs[0]
+	}
+
+	if !strings.HasPrefix(d.OpenAPI, "3.") {
+		return fmt.Errorf("unsupported OpenAPI version %q", d.OpenAPI)
+	}
+	if d.Info.Title == "" || d.Info.Version == "" {
+		return fmt.Errorf("info requires a title and a version")
+	}
+
+	ids := make(map[string]bool)
+	for p, item := range d.Paths {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("%s: path must begin with a slash", p)
+		}
+
+		ops := map[string]*Operation{"GET": item.Get, "POST": item.Post}
+		if item.Get == nil && item.Post == nil {
+			return fmt.Errorf("%s: path has no operations", p)
+		}
+
+		for method, op := range ops {
+			if op == nil {
+				continue
+			}
+			if err := d.validateOperation(p, op, ids); err != nil {
+				return fmt.Errorf("%s %s: %v", method, p, err)
+			}
+		}
+	}
+
+	for name, s := range d.Components.Schemas {
+		if !componentNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid component name %q", name)
+		}
+		if err := d.validateSchema(s); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) validateOperation(p string, op *Operation, ids map[string]bool) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("missing operation id")
+	}
+	if ids[op.OperationID] {
+		return fmt.Errorf("duplicate operation id %q", op.OperationID)
+	}
+	ids[op.OperationID] = true
+
+	templated := make(map[string]bool)
+	for _, m := range pathParamRegexp.FindAllStringSubmatch(p, -1) {
+		templated[m[1]] = true
+	}
+
+	declared := make(map[string]bool)
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			if !templated[param.Name] {
+				return fmt.Errorf("path parameter %q is not in the path", param.Name)
+			}
+			if !param.Required {
+				return fmt.Errorf("path parameter %q must be required", param.Name)
+			}
+			declared[param.Name] = true
+		case "query", "header", "cookie":
+		default:
+			return fmt.Errorf("parameter %q: invalid location %q", param.Name, param.In)
+		}
+		if err := d.validateSchema(param.Schema); err != nil {
+			return fmt.Errorf("parameter %q: %v", param.Name, err)
+		}
+	}
+	for name := range templated {
+		if !declared[name] {
+			return fmt.Errorf("path parameter %q is not declared", name)
+		}
+	}
+
+	if op.RequestBody != nil {
+		if err := d.validateContent(op.RequestBody.Content); err != nil {
+			return fmt.Errorf("request body: %v", err)
+		}
+	}
+
+	if len(op.Responses) == 0 {
+		return fmt.Errorf("missing responses")
+	}
+	for code, r := range op.Responses {
+		if !responseCodeRegexp.MatchString(code) {
+			return fmt.Errorf("invalid response code %q", code)
+		}
+		if r.Description == "" {
+			return fmt.Errorf("response %s: missing description", code)
+		}
+		if err := d.validateContent(r.Content); err != nil {
+			return fmt.Errorf("response %s: %v", code, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) validateContent(content map[string]MediaType) error {
+	for typ, m := range content {
+		if err := d.validateSchema(m.Schema); err != nil {
+			return fmt.Errorf("%s: %v", typ, err)
+		}
+	}
+	return nil
+}
+
+func (d *Document) validateSchema(s *Schema) error {
+	if s == nil {
+		return fmt.Errorf("missing schema")
+	}
+
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if _, ok := d.Components.Schemas[name]; !ok || name == s.Ref {
+			return fmt.Errorf("unresolved reference %q", s.Ref)
+		}
+		return nil
+	}
+
+	switch s.Type {
+	case "", "boolean", "integer", "number", "string", "object":
+	case "array":
+		if s.Items == nil {
+			return fmt.Errorf("array requires items")
+		}
+	default:
+		return fmt.Errorf("invalid type %q", s.Type)
+	}
+
+	if s.Items != nil {
+		if err := d.validateSchema(s.Items); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil {
+		if err := d.validateSchema(s.AdditionalProperties); err != nil {
+			return err
+		}
+	}
+	for name, p := range s.Properties {
+		if err := d.validateSchema(p); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			return fmt.Errorf("required property %q is not defined", name)
+		}
+	}
+
+	return nil
+}
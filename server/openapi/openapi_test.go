@@ -0,0 +1,74 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type embedded struct {
+	ID string `json:"id"`
+}
+
+type node struct {
+	embedded
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created,omitempty"`
+	Children []*node   `json:"children"`
+	Skipped  string    `json:"-"`
+	private  string
+}
+
+func TestSchemaOf(t *testing.T) {
+	d := NewDocument("test", "1")
+
+	got := d.SchemaOf(node{})
+	if got.Ref != "#/components/schemas/openapi.node" {
+		t.Errorf("Ref = %s; want = %s", got.Ref, "#/components/schemas/openapi.node")
+	}
+
+	want := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":       {Type: "string"},
+			"name":     {Type: "string"},
+			"created":  {Type: "string", Format: "date-time"},
+			"children": {Type: "array", Items: &Schema{Ref: "#/components/schemas/openapi.node"}},
+		},
+		Required: []string{"children", "id", "name"},
+	}
+
+	if s := d.Components.Schemas["openapi.node"]; !reflect.DeepEqual(s, want) {
+		t.Errorf("schema = %+v; want = %+v", s, want)
+	}
+
+	if err := d.Validate(); err != nil {
+		t.Errorf("err = %v; want = %v", err, nil)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		name   string
+		params []Parameter
+		valid  bool
+	}{
+		{"declared", []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}}, true},
+		{"undeclared", nil, false},
+		{"optional", []Parameter{{Name: "id", In: "path", Schema: &Schema{Type: "string"}}}, false},
+		{"unknown", []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Ref: "#/components/schemas/missing"}}}, false},
+	}
+
+	for _, tt := range tests {
+		d := NewDocument("test", "1")
+		d.Handle("GET", "/items/{id}", &Operation{
+			OperationID: "item",
+			Parameters:  tt.params,
+			Responses:   map[string]*Response{"200": {Description: "OK"}},
+		})
+
+		if err := d.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v; want valid = %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-kit/kit/log"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestOpenAPI(t *testing.T) {
+	d := OpenAPI()
+
+	if err := d.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := New(nil, nil, nil, log.NewLogfmtLogger(ioutil.Discard))
+
+	// Every route served by the handlers must be described by the document.
+	var n int
+	err := chi.Walk(srv.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route == "/metrics" || route == "/openapi.json" || strings.HasSuffix(route, "/docs") {
+			return nil
+		}
+		// Mounted routers show up as wildcards in the walked routes.
+		route = strings.TrimSuffix(strings.Replace(route, "/*", "", -1), "/")
+
+		n++
+
+		p, ok := d.Paths[route]
+		if !ok {
+			t.Errorf("%s %s: path is not documented", method, route)
+			return nil
+		}
+		if method == "GET" && p.Get == nil || method == "POST" && p.Post == nil {
+			t.Errorf("%s %s: operation is not documented", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 12 {
+		t.Errorf("routes = %d; want = %d", n, 12)
+	}
+
+	for _, name := range []string{"booking.Cargo", "tracking.Cargo", "goddd.Itinerary", "goddd.HandlingEvent"} {
+		if _, ok := d.Components.Schemas[name]; !ok {
+			t.Errorf("missing component %s", name)
+		}
+	}
+}
+
+func TestOpenAPI_HandlingEvent(t *testing.T) {
+	s := OpenAPI().Components.Schemas["goddd.HandlingEvent"]
+
+	b, err := json.Marshal(shipping.HandlingEvent{
+		TrackingID: "ABC",
+		Activity: shipping.HandlingActivity{
+			Type:         shipping.Load,
+			Location:     shipping.SESTO,
+			VoyageNumber: "V100",
+		},
+		RegistrationTime: time.Now(),
+		CompletionTime:   time.Now(),
+		IdempotencyKey:   "key",
+		EquipmentID:      "CONT1",
+
+		RecipientName:      "Jane Doe",
+		RecipientSignature: "signature",
+
+		Superseded: true,
+		Planned:    true,
+		Amends: &shipping.HandlingEventKey{
+			TrackingID:     "ABC",
+			Activity:       shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO},
+			CompletionTime: time.Now(),
+		},
+
+		Instructions: "Keep refrigerated",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fields) != len(s.Properties) {
+		t.Errorf("len(properties) = %d; want = %d", len(s.Properties), len(fields))
+	}
+	for name := range fields {
+		if _, ok := s.Properties[name]; !ok {
+			t.Errorf("property %s is not documented", name)
+		}
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	srv := New(nil, nil, nil, log.NewLogfmtLogger(ioutil.Discard))
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	rec := httptest.NewRecorder()
+
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("rec.Code = %d; want = %d", rec.Code, http.StatusOK)
+	}
+
+	var v struct {
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(v.OpenAPI, "3.") {
+		t.Errorf("openapi = %s; want = 3.x", v.OpenAPI)
+	}
+}
@@ -21,23 +21,41 @@ type Server struct {
 	Tracking tracking.Service
 	Handling handling.Service
 
+	// Events is streamed to clients of the handling API, if set.
+	Events handling.EventStream
+
 	Logger kitlog.Logger
 
 	router chi.Router
 }
 
+// Option configures optional dependencies of the server.
+type Option func(*Server)
+
+// WithEventStream makes the server stream the handled events of es to
+// clients as Server-Sent Events at GET /handling/v1/events/stream.
+func WithEventStream(es handling.EventStream) Option {
+	return func(s *Server) {
+		s.Events = es
+	}
+}
+
 // New returns a new HTTP server.
-func New(bs booking.Service, ts tracking.Service, hs handling.Service, logger kitlog.Logger) *Server {
+func New(bs booking.Service, ts tracking.Service, hs handling.Service, logger kitlog.Logger, opts ...Option) *Server {
 	s := &Server{
 		Booking:  bs,
 		Tracking: ts,
 		Handling: hs,
 		Logger:   logger,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
 
 	r := chi.NewRouter()
 
 	r.Use(accessControl)
+	r.Use(tenantScope)
 
 	r.Route("/booking", func(r chi.Router) {
 		h := bookingHandler{s.Booking, s.Logger}
@@ -48,11 +66,12 @@ func New(bs booking.Service, ts tracking.Service, hs handling.Service, logger ki
 		r.Mount("/v1", h.router())
 	})
 	r.Route("/handling", func(r chi.Router) {
-		h := handlingHandler{s.Handling, s.Logger}
+		h := handlingHandler{s.Handling, s.Events, s.Logger}
 		r.Mount("/v1", h.router())
 	})
 
 	r.Method("GET", "/metrics", promhttp.Handler())
+	r.Get("/openapi.json", serveOpenAPI)
 
 	s.router = r
 
@@ -67,7 +86,8 @@ func accessControl(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Idempotency-Key, If-None-Match, X-Tenant-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -77,17 +97,45 @@ func accessControl(h http.Handler) http.Handler {
 	})
 }
 
+// tenantScope scopes the request to the tenant given by the X-Tenant-ID
+// header. Requests without one are served by the empty tenant.
+func tenantScope(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get("X-Tenant-ID"); id != "" {
+			r = r.WithContext(shipping.NewTenantContext(r.Context(), shipping.TenantID(id)))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	switch err {
-	case shipping.ErrUnknownCargo:
-		w.WriteHeader(http.StatusNotFound)
-	case tracking.ErrInvalidArgument:
-		w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(httpStatus(shipping.ErrorCodeOf(err)))
+	json.NewEncoder(w).Encode(errorResponse{
+		Error: err.Error(),
+	})
+}
+
+// httpStatus returns the HTTP status code that an error code is reported with.
+func httpStatus(code shipping.ErrorCode) int {
+	switch code {
+	case shipping.CodeNotFound:
+		return http.StatusNotFound
+	case shipping.CodeInvalidArgument, shipping.CodeFailedPrecondition:
+		return http.StatusBadRequest
+	case shipping.CodeAlreadyExists, shipping.CodeConflict:
+		return http.StatusConflict
+	case shipping.CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case shipping.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case shipping.CodeUnimplemented:
+		return http.StatusNotImplemented
 	default:
-		w.WriteHeader(http.StatusInternalServerError)
+		return http.StatusInternalServerError
 	}
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": err.Error(),
-	})
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
 }
@@ -1,9 +1,13 @@
 package server
 
 import (
-	"context"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	kitlog "github.com/go-kit/kit/log"
@@ -19,30 +23,122 @@ type trackingHandler struct {
 
 func (h *trackingHandler) router() chi.Router {
 	r := chi.NewRouter()
+	r.Post("/cargos/status", h.batchStatus)
 	r.Get("/cargos/{trackingID}", h.track)
+	r.Get("/cargos/{trackingID}/events", h.handlingEvents)
 	r.Method("GET", "/docs", http.StripPrefix("/tracking/v1/docs", http.FileServer(http.Dir("tracking/docs"))))
 	return r
 }
 
 func (h *trackingHandler) track(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	trackingID := chi.URLParam(r, "trackingID")
 
-	c, err := h.s.Track(trackingID)
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			encodeError(ctx, tracking.ErrInvalidArgument, w)
+			return
+		}
+	}
+
+	c, err := h.s.TrackIn(ctx, trackingID, loc)
+	if err != nil {
+		encodeError(ctx, err, w)
+		return
+	}
+
+	var response = trackCargoResponse{Cargo: &c}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(response); err != nil {
+		h.logger.Log("error", err)
+		encodeError(ctx, err, w)
+		return
+	}
+
+	// The tracking read model holds no version, so the ETag is derived from
+	// the encoded response, which changes along with the delivery.
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		h.logger.Log("error", err)
+	}
+}
+
+// etagMatch reports whether the If-None-Match header matches etag, using
+// the weak comparison required for conditional GET requests.
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *trackingHandler) handlingEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	trackingID := chi.URLParam(r, "trackingID")
+
+	events, err := h.s.HandlingEvents(ctx, trackingID)
 	if err != nil {
 		encodeError(ctx, err, w)
 		return
 	}
 
-	var response = struct {
-		Cargo *tracking.Cargo `json:"cargo"`
-	}{Cargo: &c}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		h.logger.Log("error", err)
+		encodeError(ctx, err, w)
+		return
+	}
+}
+
+func (h *trackingHandler) batchStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		h.logger.Log("error", err)
+		encodeError(ctx, tracking.ErrInvalidArgument, w)
+		return
+	}
+
+	statuses, err := h.s.BatchStatus(ctx, ids)
+	if err != nil {
+		encodeError(ctx, err, w)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(batchStatusResponse{Statuses: statuses}); err != nil {
 		h.logger.Log("error", err)
 		encodeError(ctx, err, w)
 		return
 	}
 }
+
+type batchStatusResponse struct {
+	Statuses map[string]tracking.Status `json:"statuses"`
+}
+
+type trackCargoResponse struct {
+	Cargo *tracking.Cargo `json:"cargo"`
+}
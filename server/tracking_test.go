@@ -1,11 +1,13 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,6 +19,8 @@ import (
 )
 
 func TestTrackCargo(t *testing.T) {
+	ctx := context.Background()
+
 	var cargos mockCargoRepository
 
 	var events mock.HandlingEventRepository
@@ -32,7 +36,7 @@ func TestTrackCargo(t *testing.T) {
 		ArrivalDeadline: time.Date(2005, 12, 4, 0, 0, 0, 0, time.UTC),
 	})
 
-	cargos.Store(c)
+	cargos.Store(ctx, c)
 
 	logger := log.NewLogfmtLogger(ioutil.Discard)
 
@@ -72,6 +76,7 @@ func TestTrackCargo(t *testing.T) {
 		ArrivalDeadline:      time.Date(2005, 12, 4, 0, 0, 0, 0, time.UTC),
 		ETA:                  eta.In(time.UTC),
 		StatusText:           "Not received",
+		CustomsStatus:        "Not cleared",
 		NextExpectedActivity: "There are currently no expected activities for this shipping.",
 		Events:               nil,
 	}
@@ -81,6 +86,152 @@ func TestTrackCargo(t *testing.T) {
 	}
 }
 
+func TestTrackCargo_ETag(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
+	}
+
+	s := tracking.NewService(&cargos, &events)
+
+	c := shipping.NewCargo("TEST", shipping.RouteSpecification{
+		Origin:          "SESTO",
+		Destination:     "FIHEL",
+		ArrivalDeadline: time.Date(2005, 12, 4, 0, 0, 0, 0, time.UTC),
+	})
+
+	cargos.Store(ctx, c)
+
+	h := New(nil, s, nil, log.NewLogfmtLogger(ioutil.Discard))
+
+	track := func(etag string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "http://example.com/tracking/v1/cargos/TEST", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := track("")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rec.Code = %d; want = %d", rec.Code, http.StatusOK)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	rec = track(etag)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("rec.Code = %d; want = %d", rec.Code, http.StatusNotModified)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("len(body) = %d; want = %d", rec.Body.Len(), 0)
+	}
+
+	c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+		{TrackingID: "TEST", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}},
+	}})
+	cargos.Store(ctx, c)
+
+	rec = track(etag)
+	if rec.Code != http.StatusOK {
+		t.Errorf("rec.Code = %d; want = %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("ETag"); got == etag {
+		t.Errorf("ETag = %s; want changed", got)
+	}
+}
+
+func TestTrackCargo_LegDelay(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		t0 = time.Date(2009, time.March, 1, 12, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 2)
+	)
+
+	var cargos mockCargoRepository
+
+	c := shipping.NewCargo("TEST", shipping.RouteSpecification{
+		Origin:      shipping.SESTO,
+		Destination: shipping.FIHEL,
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		{VoyageNumber: "V100", LoadLocation: shipping.SESTO, UnloadLocation: shipping.FIHEL},
+	}})
+	cargos.Store(ctx, c)
+
+	var voyages mock.VoyageRepository
+	voyages.FindFn = func(n shipping.VoyageNumber) (*shipping.Voyage, error) {
+		return &shipping.Voyage{VoyageNumber: n, Schedule: shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+			{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.FIHEL, DepartureTime: t0, ArrivalTime: t1},
+		}}}, nil
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+			{
+				TrackingID:     "TEST",
+				Activity:       shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"},
+				CompletionTime: t0,
+			},
+			{
+				TrackingID:     "TEST",
+				Activity:       shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.FIHEL, VoyageNumber: "V100"},
+				CompletionTime: t1.Add(90 * time.Minute),
+			},
+		}}
+	}
+
+	s := tracking.NewService(&cargos, &events, tracking.WithVoyageRepository(&voyages))
+
+	h := New(nil, s, nil, log.NewLogfmtLogger(ioutil.Discard))
+
+	req, _ := http.NewRequest("GET", "http://example.com/tracking/v1/cargos/TEST", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rec.Code = %d; want = %d", rec.Code, http.StatusOK)
+	}
+
+	var response struct {
+		Cargo tracking.Cargo `json:"cargo"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Cargo.Legs) != 1 {
+		t.Fatalf("len(Legs) = %d; want = %d", len(response.Cargo.Legs), 1)
+	}
+
+	leg := response.Cargo.Legs[0]
+
+	if !leg.LoadTime.Equal(t0) || !leg.UnloadTime.Equal(t1) {
+		t.Errorf("planned = %v, %v; want = %v, %v", leg.LoadTime, leg.UnloadTime, t0, t1)
+	}
+	if leg.ActualLoadTime == nil || !leg.ActualLoadTime.Equal(t0) {
+		t.Errorf("ActualLoadTime = %v; want = %v", leg.ActualLoadTime, t0)
+	}
+	if want := t1.Add(90 * time.Minute); leg.ActualUnloadTime == nil || !leg.ActualUnloadTime.Equal(want) {
+		t.Errorf("ActualUnloadTime = %v; want = %v", leg.ActualUnloadTime, want)
+	}
+	if leg.DelaySeconds != 90*60 {
+		t.Errorf("DelaySeconds = %d; want = %d", leg.DelaySeconds, 90*60)
+	}
+}
+
 func TestTrackUnknownCargo(t *testing.T) {
 	var cargos mockCargoRepository
 
@@ -123,22 +274,304 @@ func TestTrackUnknownCargo(t *testing.T) {
 	}
 }
 
+func TestTrackCargo_Tenant(t *testing.T) {
+	cargos := tenantCargoRepository{tenant: "acme"}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
+	}
+
+	cargos.Store(context.Background(), shipping.NewCargo("TEST", shipping.RouteSpecification{
+		Origin:          "SESTO",
+		Destination:     "FIHEL",
+		ArrivalDeadline: time.Date(2005, 12, 4, 0, 0, 0, 0, time.UTC),
+	}))
+
+	h := New(nil, tracking.NewService(&cargos, &events), nil, log.NewLogfmtLogger(ioutil.Discard))
+
+	var tests = []struct {
+		tenant string
+		want   int
+	}{
+		{"acme", http.StatusOK},
+		{"globex", http.StatusNotFound},
+		{"", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "http://example.com/tracking/v1/cargos/TEST", nil)
+		if tt.tenant != "" {
+			req.Header.Set("X-Tenant-ID", tt.tenant)
+		}
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("tenant = %q: rec.Code = %d; want = %d", tt.tenant, rec.Code, tt.want)
+		}
+	}
+}
+
+// tenantCargoRepository is a cargo repository that only knows its cargo in
+// the context of the given tenant.
+type tenantCargoRepository struct {
+	mockCargoRepository
+	tenant shipping.TenantID
+}
+
+func (r *tenantCargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
+	if shipping.TenantFromContext(ctx) != r.tenant {
+		return nil, shipping.ErrUnknownCargo
+	}
+	return r.mockCargoRepository.Find(ctx, id)
+}
+
+func TestCargoHandlingEvents(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mockCargoRepository
+	cargos.Store(ctx, shipping.NewCargo("TEST", shipping.RouteSpecification{
+		Origin:      "SESTO",
+		Destination: "FIHEL",
+	}))
+
+	t0 := time.Date(2005, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	var (
+		received = shipping.HandlingEvent{
+			TrackingID:       "TEST",
+			Activity:         shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO},
+			RegistrationTime: t0,
+			CompletionTime:   t0,
+		}
+		loaded = shipping.HandlingEvent{
+			TrackingID:       "TEST",
+			Activity:         shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"},
+			RegistrationTime: t0.Add(2 * time.Hour),
+			CompletionTime:   t0.Add(time.Hour),
+		}
+		unloaded = shipping.HandlingEvent{
+			TrackingID:       "TEST",
+			Activity:         shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.FIHEL, VoyageNumber: "V100"},
+			RegistrationTime: t0.Add(48 * time.Hour),
+			CompletionTime:   t0.Add(47 * time.Hour),
+		}
+	)
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{loaded, unloaded, received}}
+	}
+
+	s := tracking.NewService(&cargos, &events)
+
+	h := New(nil, s, nil, log.NewLogfmtLogger(ioutil.Discard))
+
+	req, _ := http.NewRequest("GET", "http://example.com/tracking/v1/cargos/TEST/events", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("rec.Code = %d; want = %d", rec.Code, http.StatusOK)
+	}
+
+	if content := rec.Header().Get("Content-Type"); content != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q; want = %q", content, "application/json; charset=utf-8")
+	}
+
+	var got []shipping.HandlingEvent
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []shipping.HandlingEvent{unloaded, loaded, received}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v; want = %v", got, want)
+	}
+}
+
+func TestCargoHandlingEvents_UnknownCargo(t *testing.T) {
+	var cargos mockCargoRepository
+
+	var events mock.HandlingEventRepository
+
+	s := tracking.NewService(&cargos, &events)
+
+	h := New(nil, s, nil, log.NewLogfmtLogger(ioutil.Discard))
+
+	req, _ := http.NewRequest("GET", "http://example.com/tracking/v1/cargos/not_found/events", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("rec.Code = %d; want = %d", rec.Code, http.StatusNotFound)
+	}
+
+	var response map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response["error"] != "unknown cargo" {
+		t.Errorf(`"error": %q; want = %q`, response["error"], "unknown cargo")
+	}
+	if events.QueryHandlingHistoryInvoked {
+		t.Errorf("the handling history of an unknown cargo should not be queried")
+	}
+}
+
 type mockCargoRepository struct {
 	cargo *shipping.Cargo
 }
 
-func (r *mockCargoRepository) Store(c *shipping.Cargo) error {
+func (r *mockCargoRepository) Store(ctx context.Context, c *shipping.Cargo) error {
 	r.cargo = c
 	return nil
 }
 
-func (r *mockCargoRepository) Find(id shipping.TrackingID) (*shipping.Cargo, error) {
+func (r *mockCargoRepository) Find(ctx context.Context, id shipping.TrackingID) (*shipping.Cargo, error) {
 	if r.cargo != nil {
 		return r.cargo, nil
 	}
 	return nil, shipping.ErrUnknownCargo
 }
 
-func (r *mockCargoRepository) FindAll() []*shipping.Cargo {
+func (r *mockCargoRepository) FindAll(ctx context.Context) []*shipping.Cargo {
 	return []*shipping.Cargo{r.cargo}
 }
+
+func (r *mockCargoRepository) FindAwaitingClaimBeyond(ctx context.Context, d time.Duration, now time.Time) []*shipping.Cargo {
+	if r.cargo != nil && !r.cargo.Cancelled && r.cargo.IsAwaitingClaimBeyond(d, now) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindOverdue(ctx context.Context, now time.Time) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.IsOverdue(now) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAllPaged(ctx context.Context, offset, limit int) ([]*shipping.Cargo, int, error) {
+	return []*shipping.Cargo{r.cargo}, 1, nil
+}
+
+func (r *mockCargoRepository) FindByRoutingStatus(ctx context.Context, status shipping.RoutingStatus) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.Delivery.RoutingStatus == status {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByExternalRef(ctx context.Context, ref string) (*shipping.Cargo, error) {
+	if r.cargo != nil && ref != "" && r.cargo.ExternalRef == ref {
+		return r.cargo, nil
+	}
+	return nil, shipping.ErrUnknownCargo
+}
+
+func (r *mockCargoRepository) Watch(ctx context.Context) (<-chan shipping.CargoChange, error) {
+	return nil, shipping.ErrWatchNotSupported
+}
+
+func (r *mockCargoRepository) FindByServiceLevel(ctx context.Context, level shipping.ServiceLevel) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.ServiceLevel == level {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByTag(ctx context.Context, key, value string) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.Tags[key] == value && value != "" {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByVoyage(ctx context.Context, number shipping.VoyageNumber) []*shipping.Cargo {
+	if r.cargo != nil && !r.cargo.Cancelled && r.cargo.Itinerary.IsOnVoyage(number) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAtLocation(ctx context.Context, loc shipping.UNLocode) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.IsAt(loc) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindAllIncludingArchived(ctx context.Context) []*shipping.Cargo {
+	return []*shipping.Cargo{r.cargo}
+}
+
+func (r *mockCargoRepository) FindChildren(ctx context.Context, parent shipping.TrackingID) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.ParentID == parent {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) FindByDestination(ctx context.Context, dest shipping.UNLocode, activeOnly bool) []*shipping.Cargo {
+	if r.cargo != nil && r.cargo.RouteSpecification.Destination == dest && (!activeOnly || r.cargo.IsActive()) {
+		return []*shipping.Cargo{r.cargo}
+	}
+	return nil
+}
+
+func (r *mockCargoRepository) Archive(ctx context.Context, id shipping.TrackingID) error {
+	if r.cargo == nil || r.cargo.TrackingID != id {
+		return shipping.ErrUnknownCargo
+	}
+	return r.cargo.Archive()
+}
+
+func TestBatchStatus(t *testing.T) {
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		if id != "TEST" {
+			return nil, shipping.ErrUnknownCargo
+		}
+		return shipping.NewCargo("TEST", shipping.RouteSpecification{
+			Origin:      shipping.SESTO,
+			Destination: shipping.AUMEL,
+		}), nil
+	}
+
+	var events mock.HandlingEventRepository
+
+	s := tracking.NewService(&cargos, &events)
+
+	h := New(nil, s, nil, log.NewLogfmtLogger(ioutil.Discard))
+
+	req, _ := http.NewRequest("POST", "http://example.com/tracking/v1/cargos/status", strings.NewReader(`["TEST", "not_found"]`))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("rec.Code = %d; want = %d", rec.Code, http.StatusOK)
+	}
+
+	var response struct {
+		Statuses map[string]tracking.Status `json:"statuses"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]tracking.Status{
+		"TEST":      {StatusText: "Not received", TransportStatus: "Not received"},
+		"not_found": {NotFound: true},
+	}
+	if !reflect.DeepEqual(response.Statuses, want) {
+		t.Errorf("Statuses = %+v; want = %+v", response.Statuses, want)
+	}
+}
@@ -0,0 +1,35 @@
+package shipping
+
+import "context"
+
+// Closer is implemented by components that must be stopped before the
+// process exits, for example to drain buffered work. Close blocks until the
+// component has stopped or the context is done.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// CloserFunc is an adapter to allow the use of ordinary functions as closers.
+type CloserFunc func(ctx context.Context) error
+
+// Close calls f(ctx).
+func (f CloserFunc) Close(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Shutdown closes each of the closers in order, so that components are
+// closed before the components they depend on. A closer that fails does not
+// stop the remaining ones from being closed, but once the context is done,
+// no further closers are called. The first error encountered is returned.
+func Shutdown(ctx context.Context, closers ...Closer) error {
+	var first error
+	for _, c := range closers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.Close(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
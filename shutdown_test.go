@@ -0,0 +1,55 @@
+package shipping
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	ctx := context.Background()
+
+	var closed []string
+	closer := func(name string, err error) Closer {
+		return CloserFunc(func(ctx context.Context) error {
+			closed = append(closed, name)
+			return err
+		})
+	}
+
+	errFailed := errors.New("failed")
+
+	err := Shutdown(ctx, closer("server", nil), closer("handler", errFailed), closer("repository", nil))
+	if err != errFailed {
+		t.Errorf("err = %v; want = %v", err, errFailed)
+	}
+
+	if want := []string{"server", "handler", "repository"}; !reflect.DeepEqual(closed, want) {
+		t.Errorf("closed = %v; want = %v", closed, want)
+	}
+}
+
+func TestShutdown_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var called bool
+
+	blocking := CloserFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	next := CloserFunc(func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+
+	if err := Shutdown(ctx, blocking, next); err != context.DeadlineExceeded {
+		t.Errorf("err = %v; want = %v", err, context.DeadlineExceeded)
+	}
+	if called {
+		t.Errorf("closer called after the deadline")
+	}
+}
@@ -0,0 +1,22 @@
+package shipping
+
+import "context"
+
+// TenantID identifies a customer of a deployment shared by several. Cargos
+// and their handling belong to a tenant, and are never visible to another.
+type TenantID string
+
+type tenantKey struct{}
+
+// NewTenantContext returns a copy of ctx that scopes the repository
+// operations done with it to the given tenant.
+func NewTenantContext(ctx context.Context, id TenantID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant the context is scoped to, or the
+// empty tenant if it is not scoped to any.
+func TenantFromContext(ctx context.Context) TenantID {
+	id, _ := ctx.Value(tenantKey{}).(TenantID)
+	return id
+}
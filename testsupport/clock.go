@@ -0,0 +1,42 @@
+// Package testsupport provides helpers for tests that exercise the shipping
+// domain, such as a clock that only moves when told to and a builder of
+// cargo scenarios. It is not meant for use in production code.
+package testsupport
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a shipping.Clock whose time is set by the test. It is safe for
+// concurrent use.
+type Clock struct {
+	mtx sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock that tells the given time until it is moved.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// Set moves the clock to t.
+func (c *Clock) Set(t time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = t
+}
+
+// Advance moves the clock d ahead.
+func (c *Clock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+}
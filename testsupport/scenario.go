@@ -0,0 +1,185 @@
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/booking"
+	"github.com/marcusolsson/goddd/handling"
+	"github.com/marcusolsson/goddd/inmem"
+	"github.com/marcusolsson/goddd/inspection"
+)
+
+// Scenario is a cargo that has been booked, routed and handled, together
+// with the repositories that hold it.
+type Scenario struct {
+	// Cargo is the cargo as stored once every event has been registered.
+	Cargo *shipping.Cargo
+
+	// Clock has been moved to the completion time of the last event.
+	Clock *Clock
+
+	Cargos         shipping.CargoRepository
+	Locations      shipping.LocationRepository
+	Voyages        shipping.VoyageRepository
+	HandlingEvents shipping.HandlingEventRepository
+}
+
+// Leg is a leg of a route, with its load and unload times relative to the
+// start of the scenario.
+type Leg struct {
+	VoyageNumber shipping.VoyageNumber
+	From, To     shipping.UNLocode
+	Load, Unload time.Duration
+}
+
+// ScenarioBuilder builds a Scenario step by step. The steps are taken in the
+// order they are given when the scenario is built, using the in-memory
+// repositories and sample data.
+type ScenarioBuilder struct {
+	start time.Time
+
+	id                  shipping.TrackingID
+	origin, destination shipping.UNLocode
+	deadline            time.Duration
+	legs                []Leg
+	events              []scheduledEvent
+}
+
+type scheduledEvent struct {
+	at           time.Duration
+	eventType    shipping.HandlingEventType
+	location     shipping.UNLocode
+	voyageNumber shipping.VoyageNumber
+}
+
+// NewScenario returns a builder of a scenario that starts at the given time.
+// The cargo is booked at the start, and all other times are relative to it.
+func NewScenario(start time.Time) *ScenarioBuilder {
+	return &ScenarioBuilder{start: start}
+}
+
+// BookCargo books a cargo from origin to destination, to arrive within
+// deadline of the start.
+func (b *ScenarioBuilder) BookCargo(origin, destination shipping.UNLocode, deadline time.Duration) *ScenarioBuilder {
+	b.origin, b.destination, b.deadline = origin, destination, deadline
+	return b
+}
+
+// WithTrackingID makes the booked cargo have the given tracking ID, instead
+// of a random one.
+func (b *ScenarioBuilder) WithTrackingID(id shipping.TrackingID) *ScenarioBuilder {
+	b.id = id
+	return b
+}
+
+// AssignRoute assigns the cargo to an itinerary of the given legs.
+func (b *ScenarioBuilder) AssignRoute(legs ...Leg) *ScenarioBuilder {
+	b.legs = legs
+	return b
+}
+
+// Register registers a handling event completed at the given time after
+// the start.
+func (b *ScenarioBuilder) Register(at time.Duration, eventType shipping.HandlingEventType, loc shipping.UNLocode, voyageNumber shipping.VoyageNumber) *ScenarioBuilder {
+	b.events = append(b.events, scheduledEvent{
+		at:           at,
+		eventType:    eventType,
+		location:     loc,
+		voyageNumber: voyageNumber,
+	})
+	return b
+}
+
+// Receive registers the receipt of the cargo at loc.
+func (b *ScenarioBuilder) Receive(at time.Duration, loc shipping.UNLocode) *ScenarioBuilder {
+	return b.Register(at, shipping.Receive, loc, "")
+}
+
+// Load registers the loading of the cargo onto a voyage at loc.
+func (b *ScenarioBuilder) Load(at time.Duration, loc shipping.UNLocode, voyageNumber shipping.VoyageNumber) *ScenarioBuilder {
+	return b.Register(at, shipping.Load, loc, voyageNumber)
+}
+
+// Unload registers the unloading of the cargo off a voyage at loc.
+func (b *ScenarioBuilder) Unload(at time.Duration, loc shipping.UNLocode, voyageNumber shipping.VoyageNumber) *ScenarioBuilder {
+	return b.Register(at, shipping.Unload, loc, voyageNumber)
+}
+
+// Claim registers the claim of the cargo at loc.
+func (b *ScenarioBuilder) Claim(at time.Duration, loc shipping.UNLocode) *ScenarioBuilder {
+	return b.Register(at, shipping.Claim, loc, "")
+}
+
+// Build takes the steps of the scenario. Events are registered in the order
+// they were given, with the clock moved to the completion time of each, and
+// the delivery of the cargo is inspected after every one of them.
+func (b *ScenarioBuilder) Build(ctx context.Context) (*Scenario, error) {
+	s := &Scenario{
+		Clock:          NewClock(b.start),
+		Cargos:         inmem.NewCargoRepository(),
+		Locations:      inmem.NewLocationRepository(),
+		Voyages:        inmem.NewVoyageRepository(),
+		HandlingEvents: inmem.NewHandlingEventRepository(),
+	}
+
+	opts := []booking.Option{booking.WithClock(s.Clock)}
+	if b.id != "" {
+		opts = append(opts, booking.WithTrackingIDFactory(shipping.TrackingIDFactoryFunc(func() shipping.TrackingID {
+			return b.id
+		})))
+	}
+	bs := booking.NewService(s.Cargos, s.Locations, s.HandlingEvents, nil, opts...)
+
+	hs := handling.NewService(s.HandlingEvents,
+		shipping.HandlingEventFactory{
+			CargoRepository:    s.Cargos,
+			VoyageRepository:   s.Voyages,
+			LocationRepository: s.Locations,
+		},
+		handling.NewEventHandler(inspection.NewService(s.Cargos, s.HandlingEvents, nopEventHandler{})),
+		handling.WithClock(s.Clock),
+	)
+
+	id, err := bs.BookNewCargo(ctx, b.origin, b.destination, b.start.Add(b.deadline))
+	if err != nil {
+		return nil, fmt.Errorf("book cargo: %v", err)
+	}
+
+	if len(b.legs) > 0 {
+		var itinerary shipping.Itinerary
+		for _, l := range b.legs {
+			itinerary.Legs = append(itinerary.Legs, shipping.Leg{
+				VoyageNumber:   l.VoyageNumber,
+				LoadLocation:   l.From,
+				UnloadLocation: l.To,
+				LoadTime:       b.start.Add(l.Load),
+				UnloadTime:     b.start.Add(l.Unload),
+			})
+		}
+		if err := bs.AssignCargoToRoute(ctx, id, itinerary); err != nil {
+			return nil, fmt.Errorf("assign route: %v", err)
+		}
+	}
+
+	for _, e := range b.events {
+		s.Clock.Set(b.start.Add(e.at))
+		if _, err := hs.RegisterHandlingEvent(ctx, s.Clock.Now(), id, e.voyageNumber, e.location, e.eventType); err != nil {
+			return nil, fmt.Errorf("register %s at %s: %v", e.eventType, e.location, err)
+		}
+	}
+
+	if s.Cargo, err = s.Cargos.Find(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// nopEventHandler ignores the findings of inspections.
+type nopEventHandler struct{}
+
+func (nopEventHandler) CargoWasMisdirected(context.Context, shipping.TrackingID, shipping.UNLocode) {}
+func (nopEventHandler) CargoHasArrived(context.Context, shipping.TrackingID)                        {}
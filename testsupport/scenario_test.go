@@ -0,0 +1,94 @@
+package testsupport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2015, time.November, 10, 8, 0, 0, 0, time.UTC)
+
+	c := NewClock(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %v; want = %v", got, start)
+	}
+
+	c.Advance(time.Hour)
+	if got, want := c.Now(), start.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("Now() = %v; want = %v", got, want)
+	}
+
+	c.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %v; want = %v", got, start)
+	}
+}
+
+func TestScenarioBuilder_Delivered(t *testing.T) {
+	ctx := context.Background()
+
+	const day = 24 * time.Hour
+
+	start := time.Date(2015, time.November, 10, 8, 0, 0, 0, time.UTC)
+
+	s, err := NewScenario(start).
+		WithTrackingID("ABC123").
+		BookCargo(shipping.CNHKG, shipping.AUMEL, 30*day).
+		AssignRoute(
+			Leg{VoyageNumber: "V100", From: shipping.CNHKG, To: shipping.JNTKO, Load: 1 * day, Unload: 3 * day},
+			Leg{VoyageNumber: "V300", From: shipping.JNTKO, To: shipping.AUMEL, Load: 4 * day, Unload: 10 * day},
+		).
+		Receive(0, shipping.CNHKG).
+		Load(1*day, shipping.CNHKG, "V100").
+		Unload(3*day, shipping.JNTKO, "V100").
+		Load(4*day, shipping.JNTKO, "V300").
+		Unload(10*day, shipping.AUMEL, "V300").
+		Claim(11*day, shipping.AUMEL).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := s.Cargo
+
+	if c.TrackingID != "ABC123" {
+		t.Errorf("TrackingID = %s; want = %s", c.TrackingID, "ABC123")
+	}
+	if c.Delivery.RoutingStatus != shipping.Routed {
+		t.Errorf("RoutingStatus = %v; want = %v", c.Delivery.RoutingStatus, shipping.Routed)
+	}
+	if c.Delivery.TransportStatus != shipping.Claimed {
+		t.Errorf("TransportStatus = %v; want = %v", c.Delivery.TransportStatus, shipping.Claimed)
+	}
+	if c.Delivery.IsMisdirected {
+		t.Errorf("IsMisdirected = %v; want = %v", c.Delivery.IsMisdirected, false)
+	}
+	if c.Delivery.LastKnownLocation != shipping.AUMEL {
+		t.Errorf("LastKnownLocation = %s; want = %s", c.Delivery.LastKnownLocation, shipping.AUMEL)
+	}
+
+	if got, want := s.Clock.Now(), start.Add(11*day); !got.Equal(want) {
+		t.Errorf("Clock.Now() = %v; want = %v", got, want)
+	}
+	if got := len(s.HandlingEvents.QueryHandlingHistory(ctx, c.TrackingID).HandlingEvents); got != 6 {
+		t.Errorf("len(HandlingEvents) = %d; want = %d", got, 6)
+	}
+}
+
+func TestScenarioBuilder_UnknownVoyage(t *testing.T) {
+	ctx := context.Background()
+
+	start := time.Date(2015, time.November, 10, 8, 0, 0, 0, time.UTC)
+
+	_, err := NewScenario(start).
+		BookCargo(shipping.CNHKG, shipping.AUMEL, 30*24*time.Hour).
+		Receive(0, shipping.CNHKG).
+		Load(time.Hour, shipping.CNHKG, "V999").
+		Build(ctx)
+	if err == nil {
+		t.Errorf("err = %v; want error", err)
+	}
+}
@@ -0,0 +1,80 @@
+package shipping
+
+import "time"
+
+// TimelineEntry pairs an activity planned by the itinerary of a cargo with
+// the handling event that carried it out.
+type TimelineEntry struct {
+	// Expected is the planned activity, or nil if the event was not planned.
+	Expected *HandlingActivity
+
+	// ExpectedTime is when the planned activity is scheduled to happen.
+	ExpectedTime time.Time
+
+	// Actual is the event that carried out the planned activity, or nil if
+	// it has not happened yet.
+	Actual *HandlingEvent
+
+	// Deviation is set for events that were not planned, and for planned
+	// activities that were skipped by a later event.
+	Deviation bool
+}
+
+// Timeline lists the loads and unloads planned by the itinerary next to the
+// events of the handling history that carried them out, in order. Events are
+// matched to the plan by type, location and voyage, in order of completion.
+// Receive, claim and customs events are not part of any leg, and are left
+// out, as are superseded and planned events.
+func (c *Cargo) Timeline(history HandlingHistory) []TimelineEntry {
+	var planned []TimelineEntry
+	for _, leg := range c.Itinerary.Legs {
+		planned = append(planned,
+			TimelineEntry{
+				Expected:     &HandlingActivity{Type: Load, Location: leg.LoadLocation, VoyageNumber: leg.VoyageNumber},
+				ExpectedTime: leg.LoadTime,
+			},
+			TimelineEntry{
+				Expected:     &HandlingActivity{Type: Unload, Location: leg.UnloadLocation, VoyageNumber: leg.VoyageNumber},
+				ExpectedTime: leg.UnloadTime,
+			},
+		)
+	}
+
+	var (
+		entries []TimelineEntry
+		next    int
+	)
+
+	for _, e := range history.Current().SortedByCompletionTime().HandlingEvents {
+		if e.Activity.Type != Load && e.Activity.Type != Unload {
+			continue
+		}
+
+		e := e
+
+		j := next
+		for ; j < len(planned); j++ {
+			if *planned[j].Expected == e.Activity {
+				break
+			}
+		}
+
+		if j == len(planned) {
+			entries = append(entries, TimelineEntry{Actual: &e, Deviation: true})
+			continue
+		}
+
+		for ; next < j; next++ {
+			skipped := planned[next]
+			skipped.Deviation = true
+			entries = append(entries, skipped)
+		}
+
+		matched := planned[j]
+		matched.Actual = &e
+		entries = append(entries, matched)
+		next = j + 1
+	}
+
+	return append(entries, planned[next:]...)
+}
@@ -0,0 +1,125 @@
+package shipping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCargo_Timeline(t *testing.T) {
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewCargo("ABC", RouteSpecification{Origin: SESTO, Destination: CNHKG})
+	c.AssignToRoute(Itinerary{Legs: []Leg{
+		NewLeg("V400", SESTO, DEHAM, t0, t0.Add(24*time.Hour)),
+		NewLeg("V300", DEHAM, CNHKG, t0.Add(48*time.Hour), t0.Add(96*time.Hour)),
+	}})
+
+	event := func(typ HandlingEventType, loc UNLocode, voyage VoyageNumber, hours int) HandlingEvent {
+		return HandlingEvent{
+			TrackingID:     "ABC",
+			Activity:       HandlingActivity{Type: typ, Location: loc, VoyageNumber: voyage},
+			CompletionTime: t0.Add(time.Duration(hours) * time.Hour),
+		}
+	}
+
+	superseded := func(e HandlingEvent) HandlingEvent {
+		e.Superseded = true
+		return e
+	}
+	planned := func(e HandlingEvent) HandlingEvent {
+		e.Planned = true
+		return e
+	}
+
+	type entry struct {
+		expected  bool
+		actual    bool
+		deviation bool
+	}
+
+	var tests = []struct {
+		name   string
+		events []HandlingEvent
+		want   []entry
+	}{
+		{
+			name: "on plan",
+			events: []HandlingEvent{
+				event(Receive, SESTO, "", -1),
+				event(Load, SESTO, "V400", 0),
+				event(Unload, DEHAM, "V400", 24),
+				event(Load, DEHAM, "V300", 48),
+			},
+			want: []entry{
+				{expected: true, actual: true},
+				{expected: true, actual: true},
+				{expected: true, actual: true},
+				{expected: true},
+			},
+		},
+		{
+			name: "detour",
+			events: []HandlingEvent{
+				event(Load, SESTO, "V400", 0),
+				event(Unload, FIHEL, "V400", 12),
+				event(Load, FIHEL, "V400", 16),
+				event(Unload, DEHAM, "V400", 30),
+				event(Load, DEHAM, "V300", 48),
+			},
+			want: []entry{
+				{expected: true, actual: true},
+				{actual: true, deviation: true},
+				{actual: true, deviation: true},
+				{expected: true, actual: true},
+				{expected: true, actual: true},
+				{expected: true},
+			},
+		},
+		{
+			name: "skipped",
+			events: []HandlingEvent{
+				event(Unload, DEHAM, "V400", 24),
+			},
+			want: []entry{
+				{expected: true, deviation: true},
+				{expected: true, actual: true},
+				{expected: true},
+				{expected: true},
+			},
+		},
+		{
+			name: "superseded and planned",
+			events: []HandlingEvent{
+				event(Load, SESTO, "V400", 0),
+				superseded(event(Unload, FIHEL, "V400", 12)),
+				event(Unload, DEHAM, "V400", 24),
+				planned(event(Load, DEHAM, "V300", 48)),
+			},
+			want: []entry{
+				{expected: true, actual: true},
+				{expected: true, actual: true},
+				{expected: true},
+				{expected: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := c.Timeline(HandlingHistory{HandlingEvents: tt.events})
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: len(Timeline()) = %d; want = %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+
+		for i, e := range got {
+			g := entry{e.Expected != nil, e.Actual != nil, e.Deviation}
+			if g != tt.want[i] {
+				t.Errorf("%s: entry %d = %+v; want = %+v", tt.name, i, g, tt.want[i])
+			}
+			if e.Expected != nil && e.Actual != nil && *e.Expected != e.Actual.Activity {
+				t.Errorf("%s: entry %d matched %v with %v", tt.name, i, *e.Expected, e.Actual.Activity)
+			}
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package tracking
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
+
+	shipping "github.com/marcusolsson/goddd"
 )
 
 type instrumentingService struct {
@@ -21,11 +24,65 @@ func NewInstrumentingService(counter metrics.Counter, latency metrics.Histogram,
 	}
 }
 
-func (s *instrumentingService) Track(id string) (Cargo, error) {
+func (s *instrumentingService) Track(ctx context.Context, id string) (Cargo, error) {
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "track").Add(1)
 		s.requestLatency.With("method", "track").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.next.Track(id)
+	return s.next.Track(ctx, id)
+}
+
+func (s *instrumentingService) TrackIn(ctx context.Context, id string, loc *time.Location) (Cargo, error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "track_in").Add(1)
+		s.requestLatency.With("method", "track_in").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.TrackIn(ctx, id, loc)
+}
+
+func (s *instrumentingService) HandlingEvents(ctx context.Context, id string) ([]shipping.HandlingEvent, error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "handling_events").Add(1)
+		s.requestLatency.With("method", "handling_events").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.HandlingEvents(ctx, id)
+}
+
+func (s *instrumentingService) BuildReport(ctx context.Context, id string) (Report, error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "build_report").Add(1)
+		s.requestLatency.With("method", "build_report").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.BuildReport(ctx, id)
+}
+
+func (s *instrumentingService) OnTimePerformance(ctx context.Context, from, to time.Time) (int, int, error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "on_time_performance").Add(1)
+		s.requestLatency.With("method", "on_time_performance").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.OnTimePerformance(ctx, from, to)
+}
+
+func (s *instrumentingService) InventoryByLocation(ctx context.Context) map[shipping.UNLocode]int {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "inventory_by_location").Add(1)
+		s.requestLatency.With("method", "inventory_by_location").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.InventoryByLocation(ctx)
+}
+
+func (s *instrumentingService) BatchStatus(ctx context.Context, ids []string) (map[string]Status, error) {
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "batch_status").Add(1)
+		s.requestLatency.With("method", "batch_status").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.next.BatchStatus(ctx, ids)
 }
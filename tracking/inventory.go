@@ -0,0 +1,18 @@
+package tracking
+
+import (
+	"context"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func (s *service) InventoryByLocation(ctx context.Context) map[shipping.UNLocode]int {
+	inventory := make(map[shipping.UNLocode]int)
+	for _, c := range s.cargos.FindAll(ctx) {
+		if c.Cancelled || c.Delivery.TransportStatus != shipping.InPort {
+			continue
+		}
+		inventory[c.Delivery.LastKnownLocation]++
+	}
+	return inventory
+}
@@ -0,0 +1,69 @@
+package tracking
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
+)
+
+func TestInventoryByLocation(t *testing.T) {
+	ctx := context.Background()
+
+	cargos := inmem.NewCargoRepository()
+
+	// handle books a cargo from SESTO to AUMEL, and handles it with the
+	// given activities.
+	handle := func(id shipping.TrackingID, activities ...shipping.HandlingActivity) *shipping.Cargo {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:      shipping.SESTO,
+			Destination: shipping.AUMEL,
+		})
+
+		var history shipping.HandlingHistory
+		for _, a := range activities {
+			history.HandlingEvents = append(history.HandlingEvents, shipping.HandlingEvent{TrackingID: id, Activity: a})
+		}
+		c.DeriveDeliveryProgress(history)
+
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	var (
+		receive = shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}
+		load    = shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}
+		unload  = shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.CNHKG, VoyageNumber: "V100"}
+		claim   = shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL}
+	)
+
+	handle("RECEIVED1", receive)
+	handle("RECEIVED2", receive)
+	handle("UNLOADED", receive, load, unload)
+	handle("ONBOARD", receive, load)
+	handle("CLAIMED", receive, claim)
+	handle("BOOKED")
+
+	// A cancelled cargo left in port is no longer part of the inventory.
+	cancelled := handle("CANCELLED", receive)
+	if err := cancelled.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cargos.Store(ctx, cancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewService(cargos, inmem.NewHandlingEventRepository())
+
+	want := map[shipping.UNLocode]int{
+		shipping.SESTO: 2,
+		shipping.CNHKG: 1,
+	}
+	if got := s.InventoryByLocation(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("InventoryByLocation() = %v; want = %v", got, want)
+	}
+}
@@ -1,9 +1,12 @@
 package tracking
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-kit/kit/log"
+
+	shipping "github.com/marcusolsson/goddd"
 )
 
 type loggingService struct {
@@ -16,9 +19,51 @@ func NewLoggingService(logger log.Logger, s Service) Service {
 	return &loggingService{logger, s}
 }
 
-func (s *loggingService) Track(id string) (c Cargo, err error) {
+func (s *loggingService) Track(ctx context.Context, id string) (c Cargo, err error) {
 	defer func(begin time.Time) {
 		s.logger.Log("method", "track", "tracking_id", id, "took", time.Since(begin), "err", err)
 	}(time.Now())
-	return s.next.Track(id)
+	return s.next.Track(ctx, id)
+}
+
+func (s *loggingService) TrackIn(ctx context.Context, id string, loc *time.Location) (c Cargo, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "track_in", "tracking_id", id, "location", loc, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.next.TrackIn(ctx, id, loc)
+}
+
+func (s *loggingService) HandlingEvents(ctx context.Context, id string) (events []shipping.HandlingEvent, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "handling_events", "tracking_id", id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.next.HandlingEvents(ctx, id)
+}
+
+func (s *loggingService) BuildReport(ctx context.Context, id string) (r Report, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "build_report", "tracking_id", id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.next.BuildReport(ctx, id)
+}
+
+func (s *loggingService) OnTimePerformance(ctx context.Context, from, to time.Time) (delivered, onTime int, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "on_time_performance", "from", from, "to", to, "delivered", delivered, "on_time", onTime, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.next.OnTimePerformance(ctx, from, to)
+}
+
+func (s *loggingService) InventoryByLocation(ctx context.Context) (inventory map[shipping.UNLocode]int) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "inventory_by_location", "locations", len(inventory), "took", time.Since(begin))
+	}(time.Now())
+	return s.next.InventoryByLocation(ctx)
+}
+
+func (s *loggingService) BatchStatus(ctx context.Context, ids []string) (statuses map[string]Status, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "batch_status", "count", len(ids), "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.next.BatchStatus(ctx, ids)
 }
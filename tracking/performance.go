@@ -0,0 +1,56 @@
+package tracking
+
+import (
+	"context"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func (s *service) OnTimePerformance(ctx context.Context, from, to time.Time) (int, int, error) {
+	if to.Before(from) {
+		return 0, 0, ErrInvalidArgument
+	}
+
+	var delivered, onTime int
+	for _, c := range s.cargos.FindAllIncludingArchived(ctx) {
+		if c.Delivery.TransportStatus != shipping.Claimed {
+			continue
+		}
+
+		h := s.handlingEvents.QueryHandlingHistory(ctx, c.TrackingID).Current().SortedByCompletionTime()
+
+		claimed, arrived, ok := deliveryTimes(h, c.RouteSpecification.Destination)
+		if !ok || claimed.Before(from) || claimed.After(to) {
+			continue
+		}
+
+		delivered++
+
+		deadline := c.RouteSpecification.ArrivalDeadline
+		if deadline.IsZero() || !arrived.After(deadline) {
+			onTime++
+		}
+	}
+
+	return delivered, onTime, nil
+}
+
+// deliveryTimes returns when the cargo was last claimed, and when it was last
+// unloaded at the destination before that. A cargo claimed without being
+// unloaded at the destination arrived when it was claimed. It returns false
+// if the cargo has not been claimed.
+func deliveryTimes(h shipping.HandlingHistory, destination shipping.UNLocode) (claimed, arrived time.Time, ok bool) {
+	for _, e := range h.HandlingEvents {
+		switch {
+		case e.Activity.Type == shipping.Unload && e.Activity.Location == destination:
+			arrived = e.CompletionTime
+		case e.Activity.Type == shipping.Claim:
+			claimed, ok = e.CompletionTime, true
+		}
+	}
+	if arrived.IsZero() || arrived.After(claimed) {
+		arrived = claimed
+	}
+	return claimed, arrived, ok
+}
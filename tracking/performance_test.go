@@ -0,0 +1,75 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
+)
+
+func TestOnTimePerformance(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		cargos = inmem.NewCargoRepository()
+		events = inmem.NewHandlingEventRepository()
+	)
+
+	t0 := time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	// deliver books a cargo due on the deadline day, unloads it at its
+	// destination on the arrival day, and claims it a day later. A negative
+	// arrival day leaves the cargo unclaimed.
+	deliver := func(id shipping.TrackingID, deadline, arrival int) {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:          shipping.SESTO,
+			Destination:     shipping.AUMEL,
+			ArrivalDeadline: t0.AddDate(0, 0, deadline),
+		})
+
+		history := []shipping.HandlingEvent{
+			{TrackingID: id, Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}, CompletionTime: t0},
+		}
+		if arrival >= 0 {
+			history = append(history,
+				shipping.HandlingEvent{TrackingID: id, Activity: shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.AUMEL, VoyageNumber: "V100"}, CompletionTime: t0.AddDate(0, 0, arrival)},
+				shipping.HandlingEvent{TrackingID: id, Activity: shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL}, CompletionTime: t0.AddDate(0, 0, arrival+1)},
+			)
+		}
+		for _, e := range history {
+			if err := events.Store(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: history})
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliver("ONTIME", 10, 9)
+	deliver("ATDEADLINE", 10, 10)
+	deliver("LATE", 10, 12)
+	deliver("OUTSIDE", 30, 25)
+	deliver("UNCLAIMED", 10, -1)
+
+	s := NewService(cargos, events)
+
+	delivered, onTime, err := s.OnTimePerformance(ctx, t0, t0.AddDate(0, 0, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 3 {
+		t.Errorf("delivered = %d; want = %d", delivered, 3)
+	}
+	if onTime != 2 {
+		t.Errorf("onTime = %d; want = %d", onTime, 2)
+	}
+
+	if _, _, err := s.OnTimePerformance(ctx, t0, t0.AddDate(0, 0, -1)); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+}
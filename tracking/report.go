@@ -0,0 +1,111 @@
+package tracking
+
+import (
+	"context"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// Report is a formal report of the journey of a cargo, meant to be rendered
+// as a document for the customer. Times are in UTC, and measures in the units
+// of Units, which are metric unless converted with In.
+type Report struct {
+	TrackingID         string                   `json:"tracking_id"`
+	Origin             string                   `json:"origin"`
+	Units              string                   `json:"units"`
+	Weight             float64                  `json:"weight,omitempty"`
+	Volume             float64                  `json:"volume,omitempty"`
+	ServiceLevel       string                   `json:"service_level"`
+	RouteSpecification ReportRouteSpecification `json:"route_specification"`
+	Itinerary          []Leg                    `json:"itinerary"`
+	HandlingHistory    []ReportEvent            `json:"handling_history"`
+	Status             ReportStatus             `json:"status"`
+	ETA                time.Time                `json:"eta"`
+}
+
+// In returns a copy of the report with its measures in the given unit
+// system. The report is expected to be metric, as returned by BuildReport.
+func (r Report) In(u shipping.UnitSystem) Report {
+	r.Units = u.String()
+	r.Weight = u.Mass(r.Weight)
+	r.Volume = u.Volume(r.Volume)
+	return r
+}
+
+// ReportRouteSpecification is the route specification of a reported cargo.
+type ReportRouteSpecification struct {
+	Origin          string    `json:"origin"`
+	Destination     string    `json:"destination"`
+	ArrivalDeadline time.Time `json:"arrival_deadline"`
+}
+
+// ReportEvent is a handling event of a reported cargo, as it was actually
+// carried out.
+type ReportEvent struct {
+	Type             string    `json:"type"`
+	Location         string    `json:"location"`
+	VoyageNumber     string    `json:"voyage_number,omitempty"`
+	CompletionTime   time.Time `json:"completion_time"`
+	RegistrationTime time.Time `json:"registration_time"`
+	Expected         bool      `json:"expected"`
+}
+
+// ReportStatus is the current status of a reported cargo.
+type ReportStatus struct {
+	Text              string `json:"text"`
+	TransportStatus   string `json:"transport_status"`
+	RoutingStatus     string `json:"routing_status"`
+	CustomsStatus     string `json:"customs_status"`
+	LastKnownLocation string `json:"last_known_location"`
+	Misdirected       bool   `json:"misdirected"`
+}
+
+func (s *service) BuildReport(ctx context.Context, id string) (Report, error) {
+	if id == "" {
+		return Report{}, ErrInvalidArgument
+	}
+	c, err := s.cargos.Find(ctx, shipping.TrackingID(id))
+	if err != nil {
+		return Report{}, err
+	}
+
+	h := s.handlingEvents.QueryHandlingHistory(ctx, c.TrackingID)
+
+	var events []ReportEvent
+	for _, e := range h.Current().SortedByCompletionTime().HandlingEvents {
+		events = append(events, ReportEvent{
+			Type:             e.Activity.Type.String(),
+			Location:         string(e.Activity.Location),
+			VoyageNumber:     string(e.Activity.VoyageNumber),
+			CompletionTime:   e.CompletionTime.UTC(),
+			RegistrationTime: e.RegistrationTime.UTC(),
+			Expected:         c.Itinerary.IsExpected(e),
+		})
+	}
+
+	return Report{
+		TrackingID:   string(c.TrackingID),
+		Origin:       string(c.Origin),
+		Units:        shipping.Metric.String(),
+		Weight:       c.Weight,
+		Volume:       c.Volume,
+		ServiceLevel: c.ServiceLevel.String(),
+		RouteSpecification: ReportRouteSpecification{
+			Origin:          string(c.RouteSpecification.Origin),
+			Destination:     string(c.RouteSpecification.Destination),
+			ArrivalDeadline: c.RouteSpecification.ArrivalDeadline.UTC(),
+		},
+		Itinerary:       s.assembleLegs(ctx, c, h, time.UTC),
+		HandlingHistory: events,
+		Status: ReportStatus{
+			Text:              assembleStatusText(c),
+			TransportStatus:   c.Delivery.TransportStatus.String(),
+			RoutingStatus:     c.Delivery.RoutingStatus.String(),
+			CustomsStatus:     c.Delivery.CustomsStatus.String(),
+			LastKnownLocation: string(c.Delivery.LastKnownLocation),
+			Misdirected:       c.Delivery.IsMisdirected,
+		},
+		ETA: c.Delivery.ETA.UTC(),
+	}, nil
+}
@@ -0,0 +1,122 @@
+package tracking
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/mock"
+)
+
+func TestBuildReport(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		deadline = time.Date(2009, time.March, 20, 0, 0, 0, 0, time.UTC)
+		t0       = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	history := shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+		{TrackingID: "FTL456", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.SESTO}, CompletionTime: t0},
+		{TrackingID: "FTL456", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.SESTO, VoyageNumber: "V100"}, CompletionTime: t0.AddDate(0, 0, 1)},
+		{TrackingID: "FTL456", Activity: shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.DEHAM, VoyageNumber: "V100"}, CompletionTime: t0.AddDate(0, 0, 3)},
+		{TrackingID: "FTL456", Activity: shipping.HandlingActivity{Type: shipping.Load, Location: shipping.DEHAM, VoyageNumber: "V200"}, CompletionTime: t0.AddDate(0, 0, 4)},
+		{TrackingID: "FTL456", Activity: shipping.HandlingActivity{Type: shipping.Unload, Location: shipping.AUMEL, VoyageNumber: "V200"}, CompletionTime: t0.AddDate(0, 0, 10)},
+		{TrackingID: "FTL456", Activity: shipping.HandlingActivity{Type: shipping.Claim, Location: shipping.AUMEL}, CompletionTime: t0.AddDate(0, 0, 11)},
+	}}
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		if id != "FTL456" {
+			return nil, shipping.ErrUnknownCargo
+		}
+		c := shipping.NewCargo("FTL456", shipping.RouteSpecification{
+			Origin:          shipping.SESTO,
+			Destination:     shipping.AUMEL,
+			ArrivalDeadline: deadline,
+		})
+		c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V100", shipping.SESTO, shipping.DEHAM, t0.AddDate(0, 0, 1), t0.AddDate(0, 0, 3)),
+			shipping.NewLeg("V200", shipping.DEHAM, shipping.AUMEL, t0.AddDate(0, 0, 4), t0.AddDate(0, 0, 9)),
+		}})
+		c.Weight = 1000
+		c.Volume = 10
+		c.DeriveDeliveryProgress(history)
+		return c, nil
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return history
+	}
+
+	s := NewService(&cargos, &events)
+
+	r, err := s.BuildReport(ctx, "FTL456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.TrackingID != "FTL456" {
+		t.Errorf("r.TrackingID = %s; want = %s", r.TrackingID, "FTL456")
+	}
+	if want := (ReportRouteSpecification{Origin: "SESTO", Destination: "AUMEL", ArrivalDeadline: deadline}); r.RouteSpecification != want {
+		t.Errorf("r.RouteSpecification = %v; want = %v", r.RouteSpecification, want)
+	}
+
+	if len(r.Itinerary) != 2 {
+		t.Fatalf("len(r.Itinerary) = %d; want = %d", len(r.Itinerary), 2)
+	}
+	if want := t0.AddDate(0, 0, 9); !r.Itinerary[1].UnloadTime.Equal(want) {
+		t.Errorf("r.Itinerary[1].UnloadTime = %v; want = %v", r.Itinerary[1].UnloadTime, want)
+	}
+	if want := t0.AddDate(0, 0, 10); r.Itinerary[1].ActualUnloadTime == nil || !r.Itinerary[1].ActualUnloadTime.Equal(want) {
+		t.Errorf("r.Itinerary[1].ActualUnloadTime = %v; want = %v", r.Itinerary[1].ActualUnloadTime, want)
+	}
+
+	if len(r.HandlingHistory) != len(history.HandlingEvents) {
+		t.Fatalf("len(r.HandlingHistory) = %d; want = %d", len(r.HandlingHistory), len(history.HandlingEvents))
+	}
+	for i, e := range r.HandlingHistory {
+		if !e.Expected {
+			t.Errorf("r.HandlingHistory[%d].Expected = %v; want = %v", i, e.Expected, true)
+		}
+	}
+	if got := r.HandlingHistory[5]; got.Type != "Claim" || !got.CompletionTime.Equal(t0.AddDate(0, 0, 11)) {
+		t.Errorf("r.HandlingHistory[5] = %v; want a claim at %v", got, t0.AddDate(0, 0, 11))
+	}
+
+	if r.Status.TransportStatus != shipping.Claimed.String() {
+		t.Errorf("r.Status.TransportStatus = %s; want = %s", r.Status.TransportStatus, shipping.Claimed.String())
+	}
+	if r.Status.RoutingStatus != shipping.Routed.String() {
+		t.Errorf("r.Status.RoutingStatus = %s; want = %s", r.Status.RoutingStatus, shipping.Routed.String())
+	}
+	if want := t0.AddDate(0, 0, 9); !r.ETA.Equal(want) {
+		t.Errorf("r.ETA = %v; want = %v", r.ETA, want)
+	}
+
+	if r.Units != "Metric" {
+		t.Errorf("r.Units = %s; want = %s", r.Units, "Metric")
+	}
+
+	imperial := r.In(shipping.Imperial)
+	if imperial.Units != "Imperial" {
+		t.Errorf("imperial.Units = %s; want = %s", imperial.Units, "Imperial")
+	}
+	if want := 2204.62262; math.Abs(imperial.Weight-want) > 1e-6 {
+		t.Errorf("imperial.Weight = %f; want = %f", imperial.Weight, want)
+	}
+	if want := 353.146667; math.Abs(imperial.Volume-want) > 1e-6 {
+		t.Errorf("imperial.Volume = %f; want = %f", imperial.Volume, want)
+	}
+	if r.Weight != 1000 {
+		t.Errorf("r.Weight = %f; want = %f", r.Weight, 1000.0)
+	}
+
+	if _, err := s.BuildReport(ctx, "no_such_id"); err != shipping.ErrUnknownCargo {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownCargo)
+	}
+}
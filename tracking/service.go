@@ -3,7 +3,7 @@
 package tracking
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -12,57 +12,137 @@ import (
 )
 
 // ErrInvalidArgument is returned when one or more arguments are invalid.
-var ErrInvalidArgument = errors.New("invalid argument")
+var ErrInvalidArgument = shipping.ErrInvalidArgument
 
 // Service is the interface that provides the basic Track method.
 type Service interface {
-	// Track returns a cargo matching a tracking ID.
-	Track(id string) (Cargo, error)
+	// Track returns a cargo matching a tracking ID, with times in UTC.
+	Track(ctx context.Context, id string) (Cargo, error)
+
+	// TrackIn returns a cargo matching a tracking ID, with times displayed
+	// in the given location.
+	TrackIn(ctx context.Context, id string, loc *time.Location) (Cargo, error)
+
+	// HandlingEvents returns the handling history of a cargo, most recently
+	// completed event first.
+	HandlingEvents(ctx context.Context, id string) ([]shipping.HandlingEvent, error)
+
+	// BuildReport returns a report of the journey of a cargo, from its
+	// booking to its current status. It returns shipping.ErrUnknownCargo if
+	// there is no cargo with the tracking ID.
+	BuildReport(ctx context.Context, id string) (Report, error)
+
+	// OnTimePerformance returns how many cargos were claimed between from
+	// and to, and how many of them arrived on or before their arrival
+	// deadline. A cargo arrived when it was last unloaded at its
+	// destination.
+	OnTimePerformance(ctx context.Context, from, to time.Time) (delivered, onTime int, err error)
+
+	// BatchStatus returns the status of each of the cargos, by tracking ID.
+	// Unknown cargos are reported with a Status that is NotFound, rather
+	// than as an error.
+	BatchStatus(ctx context.Context, ids []string) (map[string]Status, error)
+
+	// InventoryByLocation returns how many cargos are in port at each
+	// location, by their last known location. Cargos onboard a carrier are
+	// in transit, and counted at neither end of the voyage; cargos not yet
+	// received, already claimed or cancelled are not counted at all.
+	InventoryByLocation(ctx context.Context) map[shipping.UNLocode]int
 }
 
 type service struct {
 	cargos         shipping.CargoRepository
 	handlingEvents shipping.HandlingEventRepository
+	voyages        shipping.VoyageRepository
 }
 
-func (s *service) Track(id string) (Cargo, error) {
-	if id == "" {
+// Option configures optional dependencies of the service.
+type Option func(*service)
+
+// WithVoyageRepository makes the service plan the legs of a cargo by the
+// schedules of their voyages. Without it, the legs are planned by the
+// itinerary alone.
+func WithVoyageRepository(voyages shipping.VoyageRepository) Option {
+	return func(s *service) {
+		s.voyages = voyages
+	}
+}
+
+func (s *service) Track(ctx context.Context, id string) (Cargo, error) {
+	return s.TrackIn(ctx, id, time.UTC)
+}
+
+func (s *service) TrackIn(ctx context.Context, id string, loc *time.Location) (Cargo, error) {
+	if id == "" || loc == nil {
 		return Cargo{}, ErrInvalidArgument
 	}
-	c, err := s.cargos.Find(shipping.TrackingID(id))
+	c, err := s.cargos.Find(ctx, shipping.TrackingID(id))
 	if err != nil {
 		return Cargo{}, err
 	}
-	return assemble(c, s.handlingEvents), nil
+	return s.assemble(ctx, c, loc), nil
+}
+
+func (s *service) HandlingEvents(ctx context.Context, id string) ([]shipping.HandlingEvent, error) {
+	if id == "" {
+		return nil, ErrInvalidArgument
+	}
+	if _, err := s.cargos.Find(ctx, shipping.TrackingID(id)); err != nil {
+		return nil, err
+	}
+
+	sorted := s.handlingEvents.QueryHandlingHistory(ctx, shipping.TrackingID(id)).SortedByCompletionTime()
+
+	events := make([]shipping.HandlingEvent, len(sorted.HandlingEvents))
+	for i, e := range sorted.HandlingEvents {
+		events[len(events)-1-i] = e
+	}
+	return events, nil
 }
 
 // NewService returns a new instance of the default Service.
-func NewService(cargos shipping.CargoRepository, events shipping.HandlingEventRepository) Service {
-	return &service{
+func NewService(cargos shipping.CargoRepository, events shipping.HandlingEventRepository, opts ...Option) Service {
+	s := &service{
 		cargos:         cargos,
 		handlingEvents: events,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Cargo is a read model for tracking views.
 type Cargo struct {
 	TrackingID           string    `json:"tracking_id"`
 	StatusText           string    `json:"status_text"`
+	CustomsStatus        string    `json:"customs_status"`
 	Origin               string    `json:"origin"`
 	Destination          string    `json:"destination"`
 	ETA                  time.Time `json:"eta"`
 	NextExpectedActivity string    `json:"next_expected_activity"`
 	ArrivalDeadline      time.Time `json:"arrival_deadline"`
+	Cancelled            bool      `json:"cancelled"`
+	OnHold               bool      `json:"on_hold"`
+	HoldReason           string    `json:"hold_reason,omitempty"`
 	Events               []Event   `json:"events"`
+	Legs                 []Leg     `json:"legs"`
 }
 
-// Leg is a read model for booking views.
+// Leg is a read model for tracking views. LoadTime and UnloadTime are
+// planned, and the actual times are set once the cargo has been loaded and
+// unloaded. DelaySeconds is how late the cargo was last handled on the leg,
+// compared to the plan, or zero if it is on time or has not been handled.
 type Leg struct {
-	VoyageNumber string    `json:"voyage_number"`
-	From         string    `json:"from"`
-	To           string    `json:"to"`
-	LoadTime     time.Time `json:"load_time"`
-	UnloadTime   time.Time `json:"unload_time"`
+	VoyageNumber     string     `json:"voyage_number"`
+	From             string     `json:"from"`
+	To               string     `json:"to"`
+	LoadTime         time.Time  `json:"load_time"`
+	UnloadTime       time.Time  `json:"unload_time"`
+	ActualLoadTime   *time.Time `json:"actual_load_time,omitempty"`
+	ActualUnloadTime *time.Time `json:"actual_unload_time,omitempty"`
+	DelaySeconds     int64      `json:"delay_seconds"`
+	Instructions     string     `json:"instructions,omitempty"`
 }
 
 // Event is a read model for tracking views.
@@ -71,42 +151,119 @@ type Event struct {
 	Expected    bool   `json:"expected"`
 }
 
-func assemble(c *shipping.Cargo, events shipping.HandlingEventRepository) Cargo {
+// FormatTime formats t according to RFC 3339, as displayed in loc.
+func FormatTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339)
+}
+
+func (s *service) assemble(ctx context.Context, c *shipping.Cargo, loc *time.Location) Cargo {
+	h := s.handlingEvents.QueryHandlingHistory(ctx, c.TrackingID)
+
 	return Cargo{
 		TrackingID:           string(c.TrackingID),
 		Origin:               string(c.Origin),
 		Destination:          string(c.RouteSpecification.Destination),
-		ETA:                  c.Delivery.ETA,
+		ETA:                  c.Delivery.ETA.In(loc),
 		NextExpectedActivity: nextExpectedActivity(c),
-		ArrivalDeadline:      c.RouteSpecification.ArrivalDeadline,
+		ArrivalDeadline:      c.RouteSpecification.ArrivalDeadline.In(loc),
+		Cancelled:            c.Cancelled,
+		OnHold:               c.OnHold,
+		HoldReason:           c.HoldReason,
 		StatusText:           assembleStatusText(c),
-		Events:               assembleEvents(c, events),
+		CustomsStatus:        c.Delivery.CustomsStatus.String(),
+		Events:               assembleEvents(c, h, loc),
+		Legs:                 s.assembleLegs(ctx, c, h, loc),
 	}
 }
 
-func assembleLegs(c shipping.Cargo) []Leg {
+func (s *service) assembleLegs(ctx context.Context, c *shipping.Cargo, h shipping.HandlingHistory, loc *time.Location) []Leg {
+	timeline := c.Timeline(h)
+
+	// actual returns the completion time of the event that carried out the
+	// planned activity, if any.
+	actual := func(a shipping.HandlingActivity) *time.Time {
+		for _, e := range timeline {
+			if e.Expected != nil && *e.Expected == a && e.Actual != nil {
+				t := e.Actual.CompletionTime.In(loc)
+				return &t
+			}
+		}
+		return nil
+	}
+
 	var legs []Leg
 	for _, l := range c.Itinerary.Legs {
-		legs = append(legs, Leg{
-			VoyageNumber: string(l.VoyageNumber),
-			From:         string(l.LoadLocation),
-			To:           string(l.UnloadLocation),
-			LoadTime:     l.LoadTime,
-			UnloadTime:   l.UnloadTime,
-		})
+		load, unload := s.plannedTimes(ctx, l)
+
+		leg := Leg{
+			VoyageNumber:     string(l.VoyageNumber),
+			From:             string(l.LoadLocation),
+			To:               string(l.UnloadLocation),
+			LoadTime:         load.In(loc),
+			UnloadTime:       unload.In(loc),
+			ActualLoadTime:   actual(shipping.HandlingActivity{Type: shipping.Load, Location: l.LoadLocation, VoyageNumber: l.VoyageNumber}),
+			ActualUnloadTime: actual(shipping.HandlingActivity{Type: shipping.Unload, Location: l.UnloadLocation, VoyageNumber: l.VoyageNumber}),
+			Instructions:     l.Instructions,
+		}
+
+		var delay time.Duration
+		switch {
+		case leg.ActualUnloadTime != nil:
+			delay = leg.ActualUnloadTime.Sub(unload)
+		case leg.ActualLoadTime != nil:
+			delay = leg.ActualLoadTime.Sub(load)
+		}
+		if delay > 0 {
+			leg.DelaySeconds = int64(delay / time.Second)
+		}
+
+		legs = append(legs, leg)
 	}
 	return legs
 }
 
+// plannedTimes returns when the voyage of the leg is scheduled to depart
+// from the load location and arrive at the unload location. If the voyage or
+// the movements are unknown, the times of the leg are used.
+func (s *service) plannedTimes(ctx context.Context, l shipping.Leg) (load, unload time.Time) {
+	load, unload = l.LoadTime, l.UnloadTime
+
+	if s.voyages == nil {
+		return load, unload
+	}
+
+	v, err := s.voyages.Find(ctx, l.VoyageNumber)
+	if err != nil {
+		return load, unload
+	}
+
+	departed := false
+	for _, m := range v.Schedule.CarrierMovements {
+		if !departed && m.DepartureLocation == l.LoadLocation {
+			load, departed = m.DepartureTime, true
+		}
+		if departed && m.ArrivalLocation == l.UnloadLocation {
+			return load, m.ArrivalTime
+		}
+	}
+	return l.LoadTime, l.UnloadTime
+}
+
 func nextExpectedActivity(c *shipping.Cargo) string {
 	a := c.Delivery.NextExpectedActivity
 	prefix := "Next expected activity is to"
 
+	// Dock workers are told of any special handling of the leg.
+	var instructions string
+	if s := c.Itinerary.InstructionsFor(a); s != "" {
+		instructions = fmt.Sprintf(" Instructions: %s.", strings.TrimSuffix(s, "."))
+	}
+
 	switch a.Type {
 	case shipping.Load:
-		return fmt.Sprintf("%s %s cargo onto voyage %s in %s.", prefix, strings.ToLower(a.Type.String()), a.VoyageNumber, a.Location)
+		return fmt.Sprintf("%s %s cargo onto voyage %s in %s.%s", prefix, strings.ToLower(a.Type.String()), a.VoyageNumber, a.Location, instructions)
 	case shipping.Unload:
-		return fmt.Sprintf("%s %s cargo off of voyage %s in %s.", prefix, strings.ToLower(a.Type.String()), a.VoyageNumber, a.Location)
+		return fmt.Sprintf("%s %s cargo off of voyage %s in %s.%s", prefix, strings.ToLower(a.Type.String()), a.VoyageNumber, a.Location, instructions)
 	case shipping.NotHandled:
 		return "There are currently no expected activities for this shipping."
 	}
@@ -115,6 +272,10 @@ func nextExpectedActivity(c *shipping.Cargo) string {
 }
 
 func assembleStatusText(c *shipping.Cargo) string {
+	if c.Cancelled {
+		return "Cancelled"
+	}
+
 	switch c.Delivery.TransportStatus {
 	case shipping.NotReceived:
 		return "Not received"
@@ -129,26 +290,31 @@ func assembleStatusText(c *shipping.Cargo) string {
 	}
 }
 
-func assembleEvents(c *shipping.Cargo, handlingEvents shipping.HandlingEventRepository) []Event {
-	h := handlingEvents.QueryHandlingHistory(c.TrackingID)
-
+func assembleEvents(c *shipping.Cargo, h shipping.HandlingHistory, loc *time.Location) []Event {
 	var events []Event
 	for _, e := range h.HandlingEvents {
-		var description string
+		var (
+			description string
+			completed   = FormatTime(e.CompletionTime, loc)
+		)
 
 		switch e.Activity.Type {
 		case shipping.NotHandled:
 			description = "Cargo has not yet been received."
 		case shipping.Receive:
-			description = fmt.Sprintf("Received in %s, at %s", e.Activity.Location, time.Now().Format(time.RFC3339))
+			description = fmt.Sprintf("Received in %s, at %s", e.Activity.Location, completed)
 		case shipping.Load:
-			description = fmt.Sprintf("Loaded onto voyage %s in %s, at %s.", e.Activity.VoyageNumber, e.Activity.Location, time.Now().Format(time.RFC3339))
+			description = fmt.Sprintf("Loaded onto voyage %s in %s, at %s.", e.Activity.VoyageNumber, e.Activity.Location, completed)
 		case shipping.Unload:
-			description = fmt.Sprintf("Unloaded off voyage %s in %s, at %s.", e.Activity.VoyageNumber, e.Activity.Location, time.Now().Format(time.RFC3339))
+			description = fmt.Sprintf("Unloaded off voyage %s in %s, at %s.", e.Activity.VoyageNumber, e.Activity.Location, completed)
 		case shipping.Claim:
-			description = fmt.Sprintf("Claimed in %s, at %s.", e.Activity.Location, time.Now().Format(time.RFC3339))
+			description = fmt.Sprintf("Claimed in %s, at %s.", e.Activity.Location, completed)
 		case shipping.Customs:
-			description = fmt.Sprintf("Cleared customs in %s, at %s.", e.Activity.Location, time.Now().Format(time.RFC3339))
+			if e.CustomsCleared {
+				description = fmt.Sprintf("Cleared customs in %s, at %s.", e.Activity.Location, completed)
+			} else {
+				description = fmt.Sprintf("Held in customs in %s, at %s.", e.Activity.Location, completed)
+			}
 		default:
 			description = "[Unknown status]"
 		}
@@ -1,13 +1,17 @@
 package tracking
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	shipping "github.com/marcusolsson/goddd"
 	"github.com/marcusolsson/goddd/mock"
 )
 
 func TestTrack(t *testing.T) {
+	ctx := context.Background()
+
 	var cargos mock.CargoRepository
 	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
 		return shipping.NewCargo("FTL456", shipping.RouteSpecification{
@@ -23,7 +27,7 @@ func TestTrack(t *testing.T) {
 
 	s := NewService(&cargos, &events)
 
-	c, err := s.Track("FTL456")
+	c, err := s.Track(ctx, "FTL456")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -41,3 +45,132 @@ func TestTrack(t *testing.T) {
 		t.Errorf("c.StatusText = %v; want = %v", c.StatusText, shipping.NotReceived.String())
 	}
 }
+
+func TestTrack_OnHold(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		c := shipping.NewCargo("FTL456", shipping.RouteSpecification{
+			Origin:      shipping.AUMEL,
+			Destination: shipping.SESTO,
+		})
+		c.Hold("payment dispute")
+		return c, nil
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
+	}
+
+	s := NewService(&cargos, &events)
+
+	c, err := s.Track(ctx, "FTL456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.OnHold {
+		t.Errorf("c.OnHold = %v; want = %v", c.OnHold, true)
+	}
+	if c.HoldReason != "payment dispute" {
+		t.Errorf("c.HoldReason = %v; want = %v", c.HoldReason, "payment dispute")
+	}
+}
+
+func TestTrack_Instructions(t *testing.T) {
+	ctx := context.Background()
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		c := shipping.NewCargo("FTL456", shipping.RouteSpecification{
+			Origin:      shipping.AUMEL,
+			Destination: shipping.SESTO,
+		})
+		c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+			{VoyageNumber: "V100", LoadLocation: shipping.AUMEL, UnloadLocation: shipping.SESTO, Instructions: "Hazmat placarding required"},
+		}})
+		c.DeriveDeliveryProgress(shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+			{TrackingID: "FTL456", Activity: shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.AUMEL}},
+		}})
+		return c, nil
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
+	}
+
+	s := NewService(&cargos, &events)
+
+	c, err := s.Track(ctx, "FTL456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Legs) != 1 {
+		t.Fatalf("len(c.Legs) = %d; want = %d", len(c.Legs), 1)
+	}
+	if want := "Hazmat placarding required"; c.Legs[0].Instructions != want {
+		t.Errorf("c.Legs[0].Instructions = %q; want = %q", c.Legs[0].Instructions, want)
+	}
+	if want := "Next expected activity is to load cargo onto voyage V100 in AUMEL. Instructions: Hazmat placarding required."; c.NextExpectedActivity != want {
+		t.Errorf("c.NextExpectedActivity = %q; want = %q", c.NextExpectedActivity, want)
+	}
+}
+
+func TestTrackIn(t *testing.T) {
+	ctx := context.Background()
+
+	deadline := time.Date(2009, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return shipping.NewCargo("FTL456", shipping.RouteSpecification{
+			Origin:          shipping.AUMEL,
+			Destination:     shipping.SESTO,
+			ArrivalDeadline: deadline,
+		}), nil
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{HandlingEvents: []shipping.HandlingEvent{
+			{
+				TrackingID:     "FTL456",
+				Activity:       shipping.HandlingActivity{Type: shipping.Receive, Location: shipping.AUMEL},
+				CompletionTime: time.Date(2009, time.March, 1, 23, 30, 0, 0, time.UTC),
+			},
+		}}
+	}
+
+	s := NewService(&cargos, &events)
+
+	melbourne := time.FixedZone("AEDT", 11*60*60)
+
+	c, err := s.TrackIn(ctx, "FTL456", melbourne)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Received in AUMEL, at 2009-03-02T10:30:00+11:00"; c.Events[0].Description != want {
+		t.Errorf("c.Events[0].Description = %q; want = %q", c.Events[0].Description, want)
+	}
+	if c.ArrivalDeadline.Location() != melbourne || !c.ArrivalDeadline.Equal(deadline) {
+		t.Errorf("c.ArrivalDeadline = %v; want = %v", c.ArrivalDeadline, deadline.In(melbourne))
+	}
+
+	c, err = s.Track(ctx, "FTL456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Received in AUMEL, at 2009-03-01T23:30:00Z"; c.Events[0].Description != want {
+		t.Errorf("c.Events[0].Description = %q; want = %q", c.Events[0].Description, want)
+	}
+
+	if _, err := s.TrackIn(ctx, "FTL456", nil); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+}
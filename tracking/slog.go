@@ -0,0 +1,75 @@
+//go:build go1.21
+
+package tracking
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+type slogService struct {
+	next Service
+}
+
+// NewSlogService returns a Service that logs every call to the logger of the
+// context, as by shipping.LogCall.
+func NewSlogService(s Service) Service {
+	return &slogService{s}
+}
+
+func (s *slogService) Track(ctx context.Context, id string) (c Cargo, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "track", begin, err, slog.String("tracking_id", id))
+	}(time.Now())
+	return s.next.Track(ctx, id)
+}
+
+func (s *slogService) TrackIn(ctx context.Context, id string, loc *time.Location) (c Cargo, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "track_in", begin, err, slog.String("tracking_id", id), slog.Any("location", loc))
+	}(time.Now())
+	return s.next.TrackIn(ctx, id, loc)
+}
+
+func (s *slogService) HandlingEvents(ctx context.Context, id string) (events []shipping.HandlingEvent, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "handling_events", begin, err, slog.String("tracking_id", id))
+	}(time.Now())
+	return s.next.HandlingEvents(ctx, id)
+}
+
+func (s *slogService) BuildReport(ctx context.Context, id string) (r Report, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "build_report", begin, err, slog.String("tracking_id", id))
+	}(time.Now())
+	return s.next.BuildReport(ctx, id)
+}
+
+func (s *slogService) OnTimePerformance(ctx context.Context, from, to time.Time) (delivered, onTime int, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "on_time_performance", begin, err,
+			slog.Time("from", from),
+			slog.Time("to", to),
+			slog.Int("delivered", delivered),
+			slog.Int("on_time", onTime),
+		)
+	}(time.Now())
+	return s.next.OnTimePerformance(ctx, from, to)
+}
+
+func (s *slogService) InventoryByLocation(ctx context.Context) (inventory map[shipping.UNLocode]int) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "inventory_by_location", begin, nil, slog.Int("locations", len(inventory)))
+	}(time.Now())
+	return s.next.InventoryByLocation(ctx)
+}
+
+func (s *slogService) BatchStatus(ctx context.Context, ids []string) (statuses map[string]Status, err error) {
+	defer func(begin time.Time) {
+		shipping.LogCall(ctx, "batch_status", begin, err, slog.Int("count", len(ids)))
+	}(time.Now())
+	return s.next.BatchStatus(ctx, ids)
+}
@@ -0,0 +1,64 @@
+//go:build go1.21
+
+package tracking
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/mock"
+)
+
+func TestSlogService_Track(t *testing.T) {
+	var cargos mock.CargoRepository
+	cargos.FindFn = func(id shipping.TrackingID) (*shipping.Cargo, error) {
+		return shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:      shipping.AUMEL,
+			Destination: shipping.SESTO,
+		}), nil
+	}
+
+	var events mock.HandlingEventRepository
+	events.QueryHandlingHistoryFn = func(id shipping.TrackingID) shipping.HandlingHistory {
+		return shipping.HandlingHistory{}
+	}
+
+	var buf bytes.Buffer
+
+	ctx := shipping.NewLoggerContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	ctx = shipping.NewCorrelationContext(ctx, "c1")
+
+	s := NewSlogService(NewService(&cargos, &events))
+
+	if _, err := s.Track(ctx, "FTL456"); err != nil {
+		t.Fatal(err)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+
+	if line["level"] != "INFO" {
+		t.Errorf("level = %v; want = %v", line["level"], "INFO")
+	}
+	if line["method"] != "track" {
+		t.Errorf("method = %v; want = %v", line["method"], "track")
+	}
+	if line["tracking_id"] != "FTL456" {
+		t.Errorf("tracking_id = %v; want = %v", line["tracking_id"], "FTL456")
+	}
+	if line["correlation_id"] != "c1" {
+		t.Errorf("correlation_id = %v; want = %v", line["correlation_id"], "c1")
+	}
+	if _, ok := line["took"]; !ok {
+		t.Errorf("took should be logged")
+	}
+	if _, ok := line["err"]; ok {
+		t.Errorf("err should not be logged")
+	}
+}
@@ -0,0 +1,54 @@
+package tracking
+
+import (
+	"context"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// Status is a read model of the current status of a cargo, for views listing
+// many cargos at once.
+type Status struct {
+	// NotFound is set if there is no cargo with the tracking ID, in which
+	// case the other fields are empty.
+	NotFound bool `json:"not_found,omitempty"`
+
+	StatusText      string     `json:"status_text,omitempty"`
+	TransportStatus string     `json:"transport_status,omitempty"`
+	Misdirected     bool       `json:"misdirected,omitempty"`
+	ETA             *time.Time `json:"eta,omitempty"`
+}
+
+func (s *service) BatchStatus(ctx context.Context, ids []string) (map[string]Status, error) {
+	if len(ids) == 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	statuses := make(map[string]Status, len(ids))
+	for _, id := range ids {
+		if id == "" {
+			return nil, ErrInvalidArgument
+		}
+
+		c, err := s.cargos.Find(ctx, shipping.TrackingID(id))
+		if err == shipping.ErrUnknownCargo {
+			statuses[id] = Status{NotFound: true}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		status := Status{
+			StatusText:      assembleStatusText(c),
+			TransportStatus: c.Delivery.TransportStatus.String(),
+			Misdirected:     c.Delivery.IsMisdirected,
+		}
+		if eta := c.Delivery.ETA; !eta.IsZero() {
+			status.ETA = &eta
+		}
+		statuses[id] = status
+	}
+	return statuses, nil
+}
@@ -0,0 +1,55 @@
+package shipping
+
+// UnitSystem is a system of units that measures are displayed in. Measures
+// are always kept in metric units, and only converted for display.
+type UnitSystem int
+
+// Valid unit systems.
+const (
+	Metric UnitSystem = iota
+	Imperial
+)
+
+func (u UnitSystem) String() string {
+	switch u {
+	case Metric:
+		return "Metric"
+	case Imperial:
+		return "Imperial"
+	}
+	return ""
+}
+
+// Conversion factors from metric units.
+const (
+	milesPerKilometer      = 0.621371192
+	poundsPerKilogram      = 2.20462262
+	cubicFeetPerCubicMeter = 35.3146667
+)
+
+// Length converts a length in kilometers to the unit system, that is to
+// kilometers or miles.
+func (u UnitSystem) Length(km float64) float64 {
+	if u == Imperial {
+		return km * milesPerKilometer
+	}
+	return km
+}
+
+// Mass converts a mass in kilograms to the unit system, that is to kilograms
+// or pounds.
+func (u UnitSystem) Mass(kg float64) float64 {
+	if u == Imperial {
+		return kg * poundsPerKilogram
+	}
+	return kg
+}
+
+// Volume converts a volume in cubic meters to the unit system, that is to
+// cubic meters or cubic feet.
+func (u UnitSystem) Volume(m3 float64) float64 {
+	if u == Imperial {
+		return m3 * cubicFeetPerCubicMeter
+	}
+	return m3
+}
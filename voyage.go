@@ -1,22 +1,104 @@
 package shipping
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"strings"
 	"time"
 )
 
 // VoyageNumber uniquely identifies a particular Voyage.
 type VoyageNumber string
 
+// ErrInvalidVoyageNumber is used when a voyage number is not properly
+// formatted.
+var ErrInvalidVoyageNumber = NewError(CodeInvalidArgument, "invalid voyage number")
+
+// NewVoyageNumber creates a voyage number from s, which must be a non-empty
+// string of ASCII letters and digits. It returns ErrInvalidVoyageNumber
+// otherwise.
+func NewVoyageNumber(s string) (VoyageNumber, error) {
+	if s == "" {
+		return "", ErrInvalidVoyageNumber
+	}
+	for i := 0; i < len(s); i++ {
+		switch r := s[i]; {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		default:
+			return "", ErrInvalidVoyageNumber
+		}
+	}
+	return VoyageNumber(s), nil
+}
+
+// ParseVoyageNumber parses a voyage number as entered by a user. Unlike
+// NewVoyageNumber, it ignores surrounding white space. It returns
+// ErrInvalidVoyageNumber if s is not a voyage number.
+func ParseVoyageNumber(s string) (VoyageNumber, error) {
+	return NewVoyageNumber(strings.TrimSpace(s))
+}
+
+// String returns the voyage number as parsed by ParseVoyageNumber.
+func (n VoyageNumber) String() string {
+	return string(n)
+}
+
 // Voyage is a uniquely identifiable series of carrier movements.
 type Voyage struct {
 	VoyageNumber VoyageNumber
 	Schedule     Schedule
+
+	// Mode is how the voyage is carried out. Voyages go by sea unless
+	// stated otherwise.
+	Mode TransportMode
+
+	// Cancelled voyages will not sail, and cargos can no longer be routed
+	// on them.
+	Cancelled bool
+}
+
+// TransportMode describes how a voyage is carried out.
+type TransportMode int
+
+// Valid transport modes.
+const (
+	Sea TransportMode = iota
+	Rail
+	Road
+)
+
+func (m TransportMode) String() string {
+	switch m {
+	case Sea:
+		return "Sea"
+	case Rail:
+		return "Rail"
+	case Road:
+		return "Road"
+	}
+	return ""
 }
 
 // NewVoyage creates a voyage with a voyage number and a provided schedule.
-func NewVoyage(n VoyageNumber, s Schedule) *Voyage {
-	return &Voyage{VoyageNumber: n, Schedule: s}
+// It returns an error if the voyage number or the schedule is invalid.
+func NewVoyage(n VoyageNumber, s Schedule) (*Voyage, error) {
+	if _, err := NewVoyageNumber(string(n)); err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &Voyage{VoyageNumber: n, Schedule: s}, nil
+}
+
+// mustNewVoyage is like NewVoyage but panics if the schedule is invalid. It
+// is intended for the sample voyages.
+func mustNewVoyage(n VoyageNumber, s Schedule) *Voyage {
+	v, err := NewVoyage(n, s)
+	if err != nil {
+		panic(fmt.Sprintf("voyage %s: %v", n, err))
+	}
+	return v
 }
 
 // Schedule describes a voyage schedule.
@@ -24,18 +106,192 @@ type Schedule struct {
 	CarrierMovements []CarrierMovement
 }
 
+// Validate checks that each carrier movement arrives no earlier than it
+// departs, departs no earlier than the previous movement arrives, and
+// departs from where the previous movement arrived.
+func (s Schedule) Validate() error {
+	for i, m := range s.CarrierMovements {
+		if m.ArrivalTime.Before(m.DepartureTime) {
+			return &ScheduleError{Index: i, Reason: "arrives before it departs"}
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		prev := s.CarrierMovements[i-1]
+		if m.DepartureTime.Before(prev.ArrivalTime) {
+			return &ScheduleError{Index: i, Reason: "departs before the previous movement arrives"}
+		}
+		if m.DepartureLocation != prev.ArrivalLocation {
+			return &ScheduleError{
+				Index:  i,
+				Reason: fmt.Sprintf("departs from %s but the previous movement arrives at %s", m.DepartureLocation, prev.ArrivalLocation),
+			}
+		}
+	}
+	return nil
+}
+
+// Equal returns whether both schedules consist of the same carrier
+// movements, at the same instants.
+func (s Schedule) Equal(other Schedule) bool {
+	if len(s.CarrierMovements) != len(other.CarrierMovements) {
+		return false
+	}
+	for i, m := range s.CarrierMovements {
+		o := other.CarrierMovements[i]
+		if m.DepartureLocation != o.DepartureLocation ||
+			m.ArrivalLocation != o.ArrivalLocation ||
+			!m.DepartureTime.Equal(o.DepartureTime) ||
+			!m.ArrivalTime.Equal(o.ArrivalTime) ||
+			m.CapacityWeight != o.CapacityWeight ||
+			m.CapacityVolume != o.CapacityVolume {
+			return false
+		}
+	}
+	return true
+}
+
+// FindMovement returns the first carrier movement departing from one location
+// and arriving directly at the other.
+func (s Schedule) FindMovement(from, to UNLocode) (CarrierMovement, bool) {
+	for _, m := range s.CarrierMovements {
+		if m.DepartureLocation == from && m.ArrivalLocation == to {
+			return m, true
+		}
+	}
+	return CarrierMovement{}, false
+}
+
+// NextDepartureFrom returns the first carrier movement departing from the
+// location at or after the given time.
+func (s Schedule) NextDepartureFrom(loc UNLocode, after time.Time) (CarrierMovement, bool) {
+	for _, m := range s.CarrierMovements {
+		if m.DepartureLocation == loc && !m.DepartureTime.Before(after) {
+			return m, true
+		}
+	}
+	return CarrierMovement{}, false
+}
+
+// LastArrivalAt returns the last carrier movement arriving at the location at
+// or before the given time.
+func (s Schedule) LastArrivalAt(loc UNLocode, before time.Time) (CarrierMovement, bool) {
+	for i := len(s.CarrierMovements) - 1; i >= 0; i-- {
+		m := s.CarrierMovements[i]
+		if m.ArrivalLocation == loc && !m.ArrivalTime.After(before) {
+			return m, true
+		}
+	}
+	return CarrierMovement{}, false
+}
+
+// MovementsBetween returns the consecutive carrier movements sailed from one
+// location to the other, or nil if the schedule does not connect them.
+func (s Schedule) MovementsBetween(from, to UNLocode) []CarrierMovement {
+	for i, m := range s.CarrierMovements {
+		if m.DepartureLocation != from {
+			continue
+		}
+		for j := i; j < len(s.CarrierMovements); j++ {
+			if s.CarrierMovements[j].ArrivalLocation == to {
+				return s.CarrierMovements[i : j+1]
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// Delay returns a copy of the schedule where the departure and arrival times
+// of all movements not yet completed at the given time are shifted by d.
+// Movements that have already arrived are left untouched.
+func (s Schedule) Delay(d time.Duration, now time.Time) Schedule {
+	movements := make([]CarrierMovement, len(s.CarrierMovements))
+	for i, m := range s.CarrierMovements {
+		if m.ArrivalTime.After(now) {
+			m.DepartureTime = m.DepartureTime.Add(d)
+			m.ArrivalTime = m.ArrivalTime.Add(d)
+		}
+		movements[i] = m
+	}
+	return Schedule{CarrierMovements: movements}
+}
+
+// ScheduleError describes an invalid carrier movement in a schedule.
+type ScheduleError struct {
+	Index  int
+	Reason string
+}
+
+func (e *ScheduleError) Error() string {
+	return fmt.Sprintf("invalid carrier movement %d: %s", e.Index, e.Reason)
+}
+
 // CarrierMovement is a vessel voyage from one location to another.
 type CarrierMovement struct {
 	DepartureLocation UNLocode
 	ArrivalLocation   UNLocode
 	DepartureTime     time.Time
 	ArrivalTime       time.Time
+
+	// CapacityWeight in kilograms and CapacityVolume in cubic meters is the
+	// most the vessel can carry on this movement. Zero means unlimited.
+	CapacityWeight float64
+	CapacityVolume float64
+}
+
+// Fits returns whether a cargo of the given weight and volume can be carried
+// on this movement.
+func (m CarrierMovement) Fits(weight, volume float64) bool {
+	if m.CapacityWeight > 0 && weight > m.CapacityWeight {
+		return false
+	}
+	if m.CapacityVolume > 0 && volume > m.CapacityVolume {
+		return false
+	}
+	return true
 }
 
+// ErrInsufficientCapacity is used when a cargo does not fit on a voyage.
+var ErrInsufficientCapacity = NewError(CodeFailedPrecondition, "insufficient capacity")
+
+// ErrLegNotScheduled is used when routing a cargo on a voyage whose schedule
+// does not sail from the load to the unload location of the leg.
+var ErrLegNotScheduled = NewError(CodeFailedPrecondition, "leg not in voyage schedule")
+
 // ErrUnknownVoyage is used when a voyage could not be found.
-var ErrUnknownVoyage = errors.New("unknown voyage")
+var ErrUnknownVoyage = NewError(CodeNotFound, "unknown voyage")
+
+// ErrVoyageCancelled is used when routing a cargo on a cancelled voyage.
+var ErrVoyageCancelled = NewError(CodeFailedPrecondition, "voyage cancelled")
+
+// ErrVoyageConflict is used when storing a voyage whose number is already
+// used by a voyage with a different schedule.
+var ErrVoyageConflict = NewError(CodeAlreadyExists, "voyage conflicts with a stored voyage")
 
 // VoyageRepository provides access a voyage store.
 type VoyageRepository interface {
-	Find(VoyageNumber) (*Voyage, error)
+	// Store adds a new voyage. Storing a voyage identical to one already
+	// stored does nothing, while storing a different schedule under the same
+	// number returns ErrVoyageConflict.
+	Store(ctx context.Context, v *Voyage) error
+
+	// Update replaces the schedule of a stored voyage, or returns
+	// ErrUnknownVoyage if there is none.
+	Update(ctx context.Context, v *Voyage) error
+
+	Find(ctx context.Context, number VoyageNumber) (*Voyage, error)
+
+	// FindByRoute returns the voyages with a movement sailing directly
+	// between the given locations, ordered by the departure time of that
+	// movement.
+	FindByRoute(ctx context.Context, from, to UNLocode) []*Voyage
+
+	// FindDepartingBetween returns the voyages with any movement departing
+	// from the location at or after start and before end, ordered by the
+	// first such departure. A voyage calling at the location more than once
+	// in the window is returned once.
+	FindDepartingBetween(ctx context.Context, from UNLocode, start, end time.Time) []*Voyage
 }
@@ -0,0 +1,130 @@
+package voyage
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// ImportError describes a malformed row in an imported file.
+type ImportError struct {
+	Line int
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// csvHeader is the optional first row of an imported file.
+var csvHeader = []string{"voyage_number", "departure_location", "arrival_location", "departure_time", "arrival_time"}
+
+// ImportCSV reads voyages from r. Each row describes a carrier movement as a
+// voyage number, a departure and an arrival location, and a departure and an
+// arrival time in RFC 3339 format. Empty times are left as zero.
+//
+// Movements are grouped by voyage number, in the order they appear, and the
+// voyages are returned in the order of their first movement. The first
+// malformed row, or movement that makes a schedule invalid, is returned as an
+// *ImportError.
+func ImportCSV(r io.Reader) ([]*shipping.Voyage, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	cr.TrimLeadingSpace = true
+
+	var (
+		numbers   []shipping.VoyageNumber
+		schedules = make(map[shipping.VoyageNumber]*shipping.Schedule)
+		lines     = make(map[shipping.VoyageNumber][]int)
+	)
+
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if pe, ok := err.(*csv.ParseError); ok {
+				return nil, &ImportError{Line: pe.Line, Err: pe.Err}
+			}
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		if first && isHeader(rec) {
+			continue
+		}
+
+		number, m, err := parseMovement(rec)
+		if err != nil {
+			return nil, &ImportError{Line: line, Err: err}
+		}
+
+		s, ok := schedules[number]
+		if !ok {
+			s = &shipping.Schedule{}
+			schedules[number] = s
+			numbers = append(numbers, number)
+		}
+		s.CarrierMovements = append(s.CarrierMovements, m)
+		lines[number] = append(lines[number], line)
+	}
+
+	voyages := make([]*shipping.Voyage, 0, len(numbers))
+	for _, n := range numbers {
+		v, err := shipping.NewVoyage(n, *schedules[n])
+		if err != nil {
+			if se, ok := err.(*shipping.ScheduleError); ok {
+				return nil, &ImportError{Line: lines[n][se.Index], Err: fmt.Errorf("voyage %s: %s", n, se.Reason)}
+			}
+			return nil, err
+		}
+		voyages = append(voyages, v)
+	}
+
+	return voyages, nil
+}
+
+func isHeader(rec []string) bool {
+	for i, f := range rec {
+		if !strings.EqualFold(f, csvHeader[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseMovement(rec []string) (shipping.VoyageNumber, shipping.CarrierMovement, error) {
+	number, err := shipping.NewVoyageNumber(rec[0])
+	if err != nil {
+		return "", shipping.CarrierMovement{}, fmt.Errorf("invalid voyage number %q", rec[0])
+	}
+
+	var m shipping.CarrierMovement
+	if m.DepartureLocation, err = shipping.NewUNLocode(rec[1]); err != nil {
+		return "", shipping.CarrierMovement{}, fmt.Errorf("invalid departure location %q", rec[1])
+	}
+	if m.ArrivalLocation, err = shipping.NewUNLocode(rec[2]); err != nil {
+		return "", shipping.CarrierMovement{}, fmt.Errorf("invalid arrival location %q", rec[2])
+	}
+	if m.DepartureTime, err = parseTime(rec[3]); err != nil {
+		return "", shipping.CarrierMovement{}, fmt.Errorf("invalid departure time %q", rec[3])
+	}
+	if m.ArrivalTime, err = parseTime(rec[4]); err != nil {
+		return "", shipping.CarrierMovement{}, fmt.Errorf("invalid arrival time %q", rec[4])
+	}
+
+	return number, m, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
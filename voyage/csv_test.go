@@ -0,0 +1,75 @@
+package voyage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+func TestImportCSV(t *testing.T) {
+	const in = `voyage_number,departure_location,arrival_location,departure_time,arrival_time
+V500,SESTO,DEHAM,2009-03-01T00:00:00Z,2009-03-02T00:00:00Z
+V600,CNHKG,JNTKO,,
+V500,DEHAM,NLRTM,2009-03-03T00:00:00Z,2009-03-04T00:00:00Z
+`
+
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+		t3 = t0.AddDate(0, 0, 3)
+	)
+
+	want := []*shipping.Voyage{
+		{VoyageNumber: "V500", Schedule: shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+			{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.DEHAM, DepartureTime: t0, ArrivalTime: t1},
+			{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t2, ArrivalTime: t3},
+		}}},
+		{VoyageNumber: "V600", Schedule: shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+			{DepartureLocation: shipping.CNHKG, ArrivalLocation: shipping.JNTKO},
+		}}},
+	}
+
+	got, err := ImportCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ImportCSV() = %v; want = %v", got, want)
+	}
+}
+
+func TestImportCSV_Malformed(t *testing.T) {
+	var tests = []struct {
+		name string
+		in   string
+		line int
+	}{
+		{"too few fields", "V500,SESTO,DEHAM,,\nV500,DEHAM\n", 2},
+		{"missing voyage number", ",SESTO,DEHAM,,\n", 1},
+		{"malformed voyage number", "V-500,SESTO,DEHAM,,\n", 1},
+		{"missing location", "V500,SESTO,,,\n", 1},
+		{"malformed location", "V500,SESTO,DE-HAM,,\n", 1},
+		{"invalid time", "V500,SESTO,DEHAM,yesterday,\n", 1},
+		{"header not first", "V500,SESTO,DEHAM,,\nvoyage_number,departure_location,arrival_location,departure_time,arrival_time\n", 2},
+		{"invalid schedule", "V500,SESTO,DEHAM,,\nV600,CNHKG,JNTKO,,\nV500,NLRTM,SESTO,,\n", 3},
+		{"arrives before departure", "V500,SESTO,DEHAM,2009-03-02T00:00:00Z,2009-03-01T00:00:00Z\n", 1},
+	}
+
+	for _, tt := range tests {
+		_, err := ImportCSV(strings.NewReader(tt.in))
+
+		ierr, ok := err.(*ImportError)
+		if !ok {
+			t.Errorf("%s: err = %v; want = *ImportError", tt.name, err)
+			continue
+		}
+		if ierr.Line != tt.line {
+			t.Errorf("%s: Line = %d; want = %d", tt.name, ierr.Line, tt.line)
+		}
+	}
+}
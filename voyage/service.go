@@ -0,0 +1,159 @@
+// Package voyage provides the use-case of managing voyage schedules. Used by
+// views facing the carrier operations staff.
+package voyage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+)
+
+// ErrInvalidArgument is returned when one or more arguments are invalid.
+var ErrInvalidArgument = shipping.ErrInvalidArgument
+
+// Service is the interface that provides voyage methods.
+type Service interface {
+	// DelayVoyage shifts the remaining carrier movements of a voyage by the
+	// given duration, and updates the itineraries and deliveries of the
+	// cargos travelling on it. Cargos that the delay makes arrive after their
+	// arrival deadline are reported to the EventHandler.
+	DelayVoyage(ctx context.Context, number shipping.VoyageNumber, delay time.Duration) error
+
+	// CancelVoyage marks a voyage as cancelled, and returns the tracking IDs
+	// of the active cargos whose itineraries travel on it, so that they can
+	// be rerouted.
+	CancelVoyage(ctx context.Context, number shipping.VoyageNumber) ([]shipping.TrackingID, error)
+}
+
+// EventHandler provides a means of subscribing to the consequences of voyage
+// delays.
+type EventHandler interface {
+	// CargoWillBeLate is called when a delay pushes the estimated time of
+	// arrival of a cargo, that was on time, past its arrival deadline.
+	CargoWillBeLate(ctx context.Context, id shipping.TrackingID, eta, deadline time.Time)
+}
+
+type nopEventHandler struct{}
+
+func (nopEventHandler) CargoWillBeLate(context.Context, shipping.TrackingID, time.Time, time.Time) {}
+
+// Option configures optional dependencies of the service.
+type Option func(*service)
+
+// WithEventHandler makes the service notify h of cargos that will be late
+// because of a delay.
+func WithEventHandler(h EventHandler) Option {
+	return func(s *service) {
+		s.handler = h
+	}
+}
+
+type service struct {
+	voyages        shipping.VoyageRepository
+	cargos         shipping.CargoRepository
+	handlingEvents shipping.HandlingEventRepository
+	handler        EventHandler
+	now            func() time.Time
+}
+
+func (s *service) DelayVoyage(ctx context.Context, number shipping.VoyageNumber, delay time.Duration) error {
+	if number == "" || delay <= 0 {
+		return ErrInvalidArgument
+	}
+
+	v, err := s.voyages.Find(ctx, number)
+	if err != nil {
+		return err
+	}
+	if v.Cancelled {
+		return shipping.ErrVoyageCancelled
+	}
+
+	now := s.now()
+
+	delayed, err := shipping.NewVoyage(number, v.Schedule.Delay(delay, now))
+	if err != nil {
+		return err
+	}
+	delayed.Mode = v.Mode
+
+	if err := s.voyages.Update(ctx, delayed); err != nil {
+		return err
+	}
+
+	for _, c := range s.cargos.FindAll(ctx) {
+		if !c.Itinerary.IsOnVoyage(number) {
+			continue
+		}
+
+		wasLate := willBeLate(c)
+
+		c.Itinerary = c.Itinerary.Delay(number, delay, now)
+		c.DeriveDeliveryProgress(s.handlingEvents.QueryHandlingHistory(ctx, c.TrackingID))
+
+		if err := s.cargos.Store(ctx, c); err != nil {
+			return err
+		}
+
+		if !wasLate && willBeLate(c) {
+			s.handler.CargoWillBeLate(ctx, c.TrackingID, c.Delivery.ETA, c.RouteSpecification.ArrivalDeadline)
+		}
+	}
+
+	return nil
+}
+
+func (s *service) CancelVoyage(ctx context.Context, number shipping.VoyageNumber) ([]shipping.TrackingID, error) {
+	if number == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	v, err := s.voyages.Find(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled := *v
+	cancelled.Cancelled = true
+
+	if err := s.voyages.Update(ctx, &cancelled); err != nil {
+		return nil, err
+	}
+
+	ids := []shipping.TrackingID{}
+	for _, c := range s.cargos.FindAll(ctx) {
+		// Claimed and cancelled cargos are not stranded by the voyage.
+		if c.IsActive() && c.Itinerary.IsOnVoyage(number) {
+			ids = append(ids, c.TrackingID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	return ids, nil
+}
+
+// willBeLate returns whether the cargo is expected to arrive after its
+// arrival deadline.
+func willBeLate(c *shipping.Cargo) bool {
+	deadline := c.RouteSpecification.ArrivalDeadline
+	return !deadline.IsZero() && !c.Delivery.ETA.IsZero() && c.Delivery.ETA.After(deadline)
+}
+
+// NewService creates a voyage service with necessary dependencies.
+func NewService(voyages shipping.VoyageRepository, cargos shipping.CargoRepository, events shipping.HandlingEventRepository, opts ...Option) Service {
+	s := &service{
+		voyages:        voyages,
+		cargos:         cargos,
+		handlingEvents: events,
+		handler:        nopEventHandler{},
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
@@ -0,0 +1,270 @@
+package voyage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	shipping "github.com/marcusolsson/goddd"
+	"github.com/marcusolsson/goddd/inmem"
+)
+
+func TestDelayVoyage(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+		t3 = t0.AddDate(0, 0, 3)
+
+		now   = t1.Add(time.Hour)
+		delay = 12 * time.Hour
+	)
+
+	var (
+		voyages = inmem.NewVoyageRepository()
+		cargos  = inmem.NewCargoRepository()
+		events  = inmem.NewHandlingEventRepository()
+	)
+
+	v, err := shipping.NewVoyage("V500", shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+		{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.DEHAM, DepartureTime: t0, ArrivalTime: t1},
+		{DepartureLocation: shipping.DEHAM, ArrivalLocation: shipping.NLRTM, DepartureTime: t2, ArrivalTime: t3},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := voyages.Store(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+
+	c := shipping.NewCargo("ABC123", shipping.RouteSpecification{
+		Origin:          shipping.SESTO,
+		Destination:     shipping.NLRTM,
+		ArrivalDeadline: t3.AddDate(0, 0, 7),
+	})
+	c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+		shipping.NewLeg("V500", shipping.SESTO, shipping.DEHAM, t0, t1),
+		shipping.NewLeg("V500", shipping.DEHAM, shipping.NLRTM, t2, t3),
+	}})
+	if err := cargos.Store(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &service{
+		voyages:        voyages,
+		cargos:         cargos,
+		handlingEvents: events,
+		handler:        nopEventHandler{},
+		now:            func() time.Time { return now },
+	}
+
+	if err := s.DelayVoyage(ctx, "V500", delay); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := voyages.Find(ctx, "V500")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	completed := got.Schedule.CarrierMovements[0]
+	if !completed.ArrivalTime.Equal(t1) {
+		t.Errorf("completed.ArrivalTime = %v; want = %v", completed.ArrivalTime, t1)
+	}
+
+	remaining := got.Schedule.CarrierMovements[1]
+	if !remaining.DepartureTime.Equal(t2.Add(delay)) {
+		t.Errorf("remaining.DepartureTime = %v; want = %v", remaining.DepartureTime, t2.Add(delay))
+	}
+	if !remaining.ArrivalTime.Equal(t3.Add(delay)) {
+		t.Errorf("remaining.ArrivalTime = %v; want = %v", remaining.ArrivalTime, t3.Add(delay))
+	}
+
+	routed, err := cargos.Find(ctx, "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !routed.Itinerary.Legs[0].UnloadTime.Equal(t1) {
+		t.Errorf("Legs[0].UnloadTime = %v; want = %v", routed.Itinerary.Legs[0].UnloadTime, t1)
+	}
+	if !routed.Itinerary.Legs[1].UnloadTime.Equal(t3.Add(delay)) {
+		t.Errorf("Legs[1].UnloadTime = %v; want = %v", routed.Itinerary.Legs[1].UnloadTime, t3.Add(delay))
+	}
+	if !routed.Delivery.ETA.Equal(t3.Add(delay)) {
+		t.Errorf("Delivery.ETA = %v; want = %v", routed.Delivery.ETA, t3.Add(delay))
+	}
+}
+
+type lateCargo struct {
+	id            shipping.TrackingID
+	eta, deadline time.Time
+}
+
+type stubEventHandler struct {
+	late []lateCargo
+}
+
+func (h *stubEventHandler) CargoWillBeLate(ctx context.Context, id shipping.TrackingID, eta, deadline time.Time) {
+	h.late = append(h.late, lateCargo{id, eta, deadline})
+}
+
+func TestDelayVoyage_CargoWillBeLate(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+
+		delay = 12 * time.Hour
+	)
+
+	var (
+		voyages = inmem.NewVoyageRepository()
+		cargos  = inmem.NewCargoRepository()
+		events  = inmem.NewHandlingEventRepository()
+	)
+
+	v, err := shipping.NewVoyage("V500", shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+		{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.DEHAM, DepartureTime: t0, ArrivalTime: t1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := voyages.Store(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+
+	deadlines := map[shipping.TrackingID]time.Time{
+		"TIGHT": t1.Add(time.Hour),
+		"LOOSE": t1.AddDate(0, 0, 1),
+	}
+	for id, deadline := range deadlines {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:          shipping.SESTO,
+			Destination:     shipping.DEHAM,
+			ArrivalDeadline: deadline,
+		})
+		c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg("V500", shipping.SESTO, shipping.DEHAM, t0, t1),
+		}})
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &stubEventHandler{}
+
+	s := &service{
+		voyages:        voyages,
+		cargos:         cargos,
+		handlingEvents: events,
+		handler:        h,
+		now:            func() time.Time { return t0.Add(-time.Hour) },
+	}
+
+	if err := s.DelayVoyage(ctx, "V500", delay); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []lateCargo{{"TIGHT", t1.Add(delay), deadlines["TIGHT"]}}
+	if !reflect.DeepEqual(h.late, want) {
+		t.Errorf("late = %v; want = %v", h.late, want)
+	}
+
+	// A cargo that is already late is not reported again.
+	if err := s.DelayVoyage(ctx, "V500", delay); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.late) != 1 {
+		t.Errorf("len(late) = %d; want = %d", len(h.late), 1)
+	}
+}
+
+func TestDelayVoyage_UnknownVoyage(t *testing.T) {
+	ctx := context.Background()
+
+	s := NewService(inmem.NewVoyageRepository(), inmem.NewCargoRepository(), inmem.NewHandlingEventRepository())
+
+	if err := s.DelayVoyage(ctx, "NOSUCH", time.Hour); err != shipping.ErrUnknownVoyage {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownVoyage)
+	}
+	if err := s.DelayVoyage(ctx, "V100", 0); err != ErrInvalidArgument {
+		t.Errorf("err = %v; want = %v", err, ErrInvalidArgument)
+	}
+}
+
+func TestCancelVoyage(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+	)
+
+	var (
+		voyages = inmem.NewVoyageRepository()
+		cargos  = inmem.NewCargoRepository()
+		events  = inmem.NewHandlingEventRepository()
+	)
+
+	v, err := shipping.NewVoyage("V500", shipping.Schedule{CarrierMovements: []shipping.CarrierMovement{
+		{DepartureLocation: shipping.SESTO, ArrivalLocation: shipping.DEHAM, DepartureTime: t0, ArrivalTime: t1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := voyages.Store(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, voyage := range map[shipping.TrackingID]shipping.VoyageNumber{"DEF": "V500", "ABC": "V500", "GHI": "V600", "JKL": "V500", "MNO": "V500"} {
+		c := shipping.NewCargo(id, shipping.RouteSpecification{
+			Origin:      shipping.SESTO,
+			Destination: shipping.DEHAM,
+		})
+		c.AssignToRoute(shipping.Itinerary{Legs: []shipping.Leg{
+			shipping.NewLeg(voyage, shipping.SESTO, shipping.DEHAM, t0, t1),
+		}})
+		switch id {
+		case "JKL":
+			c.Delivery.TransportStatus = shipping.Claimed
+		case "MNO":
+			if err := c.Cancel(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cargos.Store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewService(voyages, cargos, events)
+
+	ids, err := s.CancelVoyage(ctx, "V500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []shipping.TrackingID{"ABC", "DEF"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("CancelVoyage() = %v; want = %v", ids, want)
+	}
+
+	got, err := voyages.Find(ctx, "V500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Cancelled {
+		t.Errorf("Cancelled = %v; want = %v", got.Cancelled, true)
+	}
+
+	if err := s.DelayVoyage(ctx, "V500", time.Hour); err != shipping.ErrVoyageCancelled {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrVoyageCancelled)
+	}
+
+	if _, err := s.CancelVoyage(ctx, "V700"); err != shipping.ErrUnknownVoyage {
+		t.Errorf("err = %v; want = %v", err, shipping.ErrUnknownVoyage)
+	}
+}
@@ -0,0 +1,210 @@
+package shipping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Validate(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+		t3 = t0.AddDate(0, 0, 3)
+	)
+
+	tests := []struct {
+		name      string
+		movements []CarrierMovement
+		index     int
+	}{
+		{
+			name: "valid",
+			movements: []CarrierMovement{
+				{DepartureLocation: SESTO, ArrivalLocation: DEHAM, DepartureTime: t0, ArrivalTime: t1},
+				{DepartureLocation: DEHAM, ArrivalLocation: NLRTM, DepartureTime: t1, ArrivalTime: t2},
+			},
+			index: -1,
+		},
+		{
+			name: "overlap",
+			movements: []CarrierMovement{
+				{DepartureLocation: SESTO, ArrivalLocation: DEHAM, DepartureTime: t0, ArrivalTime: t2},
+				{DepartureLocation: DEHAM, ArrivalLocation: NLRTM, DepartureTime: t1, ArrivalTime: t3},
+			},
+			index: 1,
+		},
+		{
+			name: "location mismatch",
+			movements: []CarrierMovement{
+				{DepartureLocation: SESTO, ArrivalLocation: DEHAM, DepartureTime: t0, ArrivalTime: t1},
+				{DepartureLocation: DEHAM, ArrivalLocation: NLRTM, DepartureTime: t1, ArrivalTime: t2},
+				{DepartureLocation: USNYC, ArrivalLocation: CNHKG, DepartureTime: t2, ArrivalTime: t3},
+			},
+			index: 2,
+		},
+		{
+			name: "arrives before departure",
+			movements: []CarrierMovement{
+				{DepartureLocation: SESTO, ArrivalLocation: DEHAM, DepartureTime: t1, ArrivalTime: t0},
+			},
+			index: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		err := Schedule{CarrierMovements: tt.movements}.Validate()
+
+		if tt.index < 0 {
+			if err != nil {
+				t.Errorf("%s: err = %v; want = %v", tt.name, err, nil)
+			}
+			continue
+		}
+
+		serr, ok := err.(*ScheduleError)
+		if !ok {
+			t.Errorf("%s: err = %v; want a *ScheduleError", tt.name, err)
+			continue
+		}
+		if serr.Index != tt.index {
+			t.Errorf("%s: Index = %d; want = %d", tt.name, serr.Index, tt.index)
+		}
+	}
+}
+
+func TestNewVoyage_InvalidSchedule(t *testing.T) {
+	s := Schedule{CarrierMovements: []CarrierMovement{
+		{DepartureLocation: SESTO, ArrivalLocation: DEHAM},
+		{DepartureLocation: NLRTM, ArrivalLocation: USNYC},
+	}}
+
+	v, err := NewVoyage("V999", s)
+	if err == nil {
+		t.Fatalf("err = %v; want an error", err)
+	}
+	if v != nil {
+		t.Errorf("v = %v; want = %v", v, nil)
+	}
+}
+
+func TestNewVoyageNumber(t *testing.T) {
+	for _, s := range []string{"V100", "0100S", "v100", "A"} {
+		if n, err := NewVoyageNumber(s); err != nil || string(n) != s {
+			t.Errorf("NewVoyageNumber(%q) = %q, %v; want = %q, %v", s, n, err, s, nil)
+		}
+	}
+
+	for _, s := range []string{"", " V100", "V-100", "V100 ", "V1ö0"} {
+		if _, err := NewVoyageNumber(s); err != ErrInvalidVoyageNumber {
+			t.Errorf("NewVoyageNumber(%q) err = %v; want = %v", s, err, ErrInvalidVoyageNumber)
+		}
+	}
+
+	if _, err := NewVoyage("V-100", Schedule{}); err != ErrInvalidVoyageNumber {
+		t.Errorf("NewVoyage() err = %v; want = %v", err, ErrInvalidVoyageNumber)
+	}
+}
+
+func TestCarrierMovement_Fits(t *testing.T) {
+	m := CarrierMovement{CapacityWeight: 1000, CapacityVolume: 20}
+
+	for _, tt := range []struct {
+		weight, volume float64
+		want           bool
+	}{
+		{0, 0, true},
+		{1000, 20, true},
+		{1001, 20, false},
+		{1000, 21, false},
+	} {
+		if got := m.Fits(tt.weight, tt.volume); got != tt.want {
+			t.Errorf("Fits(%v, %v) = %v; want = %v", tt.weight, tt.volume, got, tt.want)
+		}
+	}
+
+	if got := (CarrierMovement{}).Fits(1e9, 1e9); !got {
+		t.Errorf("Fits() = %v; want = %v", got, true)
+	}
+}
+
+func TestSchedule_MovementsBetween(t *testing.T) {
+	s := V300.Schedule
+
+	if got := s.MovementsBetween(NLRTM, AUMEL); len(got) != 2 {
+		t.Errorf("len(MovementsBetween(NLRTM, AUMEL)) = %d; want = %d", len(got), 2)
+	}
+	if got := s.MovementsBetween(JNTKO, NLRTM); len(got) != 1 {
+		t.Errorf("len(MovementsBetween(JNTKO, NLRTM)) = %d; want = %d", len(got), 1)
+	}
+	if got := s.MovementsBetween(SESTO, AUMEL); got != nil {
+		t.Errorf("MovementsBetween(SESTO, AUMEL) = %v; want = %v", got, nil)
+	}
+}
+
+func TestSchedule_NextDepartureFromAndLastArrivalAt(t *testing.T) {
+	var (
+		t0 = time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)
+		t1 = t0.AddDate(0, 0, 1)
+		t2 = t0.AddDate(0, 0, 2)
+		t3 = t0.AddDate(0, 0, 3)
+		t4 = t0.AddDate(0, 0, 4)
+		t5 = t0.AddDate(0, 0, 5)
+	)
+
+	// A round trip calling at Stockholm and Hamburg twice.
+	s := Schedule{CarrierMovements: []CarrierMovement{
+		{DepartureLocation: SESTO, ArrivalLocation: DEHAM, DepartureTime: t0, ArrivalTime: t1},
+		{DepartureLocation: DEHAM, ArrivalLocation: SESTO, DepartureTime: t1, ArrivalTime: t2},
+		{DepartureLocation: SESTO, ArrivalLocation: DEHAM, DepartureTime: t3, ArrivalTime: t4},
+		{DepartureLocation: DEHAM, ArrivalLocation: NLRTM, DepartureTime: t4, ArrivalTime: t5},
+	}}
+
+	departures := []struct {
+		loc   UNLocode
+		after time.Time
+		want  int
+	}{
+		{SESTO, t0, 0},
+		{SESTO, t0.Add(time.Second), 2},
+		{SESTO, t3, 2},
+		{SESTO, t3.Add(time.Second), -1},
+		{DEHAM, t2, 3},
+		{NLRTM, t0, -1},
+	}
+
+	for _, tt := range departures {
+		got, ok := s.NextDepartureFrom(tt.loc, tt.after)
+		if ok != (tt.want >= 0) {
+			t.Errorf("NextDepartureFrom(%s, %s) ok = %v; want = %v", tt.loc, tt.after, ok, tt.want >= 0)
+			continue
+		}
+		if ok && got != s.CarrierMovements[tt.want] {
+			t.Errorf("NextDepartureFrom(%s, %s) = %v; want = %v", tt.loc, tt.after, got, s.CarrierMovements[tt.want])
+		}
+	}
+
+	arrivals := []struct {
+		loc    UNLocode
+		before time.Time
+		want   int
+	}{
+		{DEHAM, t5, 2},
+		{DEHAM, t4, 2},
+		{DEHAM, t4.Add(-time.Second), 0},
+		{DEHAM, t1.Add(-time.Second), -1},
+		{SESTO, t5, 1},
+		{FIHEL, t5, -1},
+	}
+
+	for _, tt := range arrivals {
+		got, ok := s.LastArrivalAt(tt.loc, tt.before)
+		if ok != (tt.want >= 0) {
+			t.Errorf("LastArrivalAt(%s, %s) ok = %v; want = %v", tt.loc, tt.before, ok, tt.want >= 0)
+			continue
+		}
+		if ok && got != s.CarrierMovements[tt.want] {
+			t.Errorf("LastArrivalAt(%s, %s) = %v; want = %v", tt.loc, tt.before, got, s.CarrierMovements[tt.want])
+		}
+	}
+}